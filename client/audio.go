@@ -0,0 +1,313 @@
+package client
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// AudioPreset is a combination of the Mumble audio settings that
+// works well for a given latency of the connection to the meeting
+type AudioPreset struct {
+	ID string
+
+	// Bitrate is the quality of the encoded audio, in bits per second
+	Bitrate int
+
+	// FramesPerPacket is the amount of 10ms audio frames sent in every
+	// packet. More frames mean less packets over a slow circuit
+	FramesPerPacket int
+
+	// JitterBuffer is the amount of 10ms frames that Mumble waits
+	// for before playing the audio of the other participants
+	JitterBuffer int
+
+	// MaxBandwidth is what the server of a meeting hosted with the
+	// preset lets every participant use, in bits per second, so the
+	// Mumble clients of everybody lower their quality to fit in it.
+	// Zero keeps the limit of the server
+	MaxBandwidth int
+
+	maxRTT time.Duration
+}
+
+const (
+	// AudioPresetHigh is the preset used for fast circuits
+	AudioPresetHigh = "high"
+
+	// AudioPresetBalanced is the preset used for average circuits
+	AudioPresetBalanced = "balanced"
+
+	// AudioPresetLow is the preset used for slow circuits
+	AudioPresetLow = "low"
+
+	// AudioPresetLowLatency keeps the delay of the audio as short
+	// as Tor allows, for connections with bandwidth to spare
+	AudioPresetLowLatency = "low-latency"
+
+	// AudioPresetLowBandwidth uses as little of the connection as
+	// possible, in the meeting and in the server of the host
+	AudioPresetLowBandwidth = "low-bandwidth"
+)
+
+// The presets are ordered from the fastest to the slowest circuit.
+// The last one is used for any latency bigger than the others
+var audioPresets = []AudioPreset{
+	{ID: AudioPresetHigh, Bitrate: 40000, FramesPerPacket: 2, JitterBuffer: 2, maxRTT: 500 * time.Millisecond},
+	{ID: AudioPresetBalanced, Bitrate: 24000, FramesPerPacket: 4, JitterBuffer: 5, maxRTT: 1200 * time.Millisecond},
+	{ID: AudioPresetLow, Bitrate: 16000, FramesPerPacket: 6, JitterBuffer: 10},
+}
+
+// torAudioPresets are only used when they are chosen in the settings.
+// Tor sends at least a cell of 514 bytes for every packet, so packets of
+// less than 20ms cost much more than the audio in them. The low
+// bandwidth preset sends the longest packets Mumble allows and limits
+// the server, so the participants with other presets use less too
+var torAudioPresets = []AudioPreset{
+	{ID: AudioPresetLowLatency, Bitrate: 32000, FramesPerPacket: 2, JitterBuffer: 1},
+	{ID: AudioPresetLowBandwidth, Bitrate: 12000, FramesPerPacket: 6, JitterBuffer: 8, MaxBandwidth: 24000},
+}
+
+// ErrUnknownAudioPreset is an error to be trown when
+// the given identifier doesn't belong to any preset
+var ErrUnknownAudioPreset = failure.New("client.unknown-audio-preset", failure.CategoryConfiguration, "the audio preset is not known", "choose another audio quality in the settings")
+
+// AudioPresetByID returns the preset with the given identifier
+func AudioPresetByID(id string) (AudioPreset, error) {
+	for _, p := range append(audioPresets, torAudioPresets...) {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+
+	return AudioPreset{}, ErrUnknownAudioPreset
+}
+
+// HostingBandwidth returns the bandwidth that the server of a hosted
+// meeting lets every participant use with the given preset, or zero
+// when the preset doesn't limit it
+func HostingBandwidth(id string) int {
+	p, err := AudioPresetByID(id)
+	if err != nil {
+		return 0
+	}
+	return p.MaxBandwidth
+}
+
+// LimitedHostingBandwidth returns the bandwidth of HostingBandwidth,
+// lowered to the given kilobits per second when it's not zero
+func LimitedHostingBandwidth(id string, kbps int) int {
+	bps := HostingBandwidth(id)
+	if limit := kbps * 1000; kbps > 0 && (bps == 0 || limit < bps) {
+		return limit
+	}
+	return bps
+}
+
+// AudioPresetForRTT returns the preset that fits better
+// the given round trip time of the circuit
+func AudioPresetForRTT(rtt time.Duration) AudioPreset {
+	for _, p := range audioPresets {
+		if p.maxRTT > 0 && rtt < p.maxRTT {
+			return p
+		}
+	}
+
+	return audioPresets[len(audioPresets)-1]
+}
+
+// MeasureRTT returns the round trip time to the given address through
+// Tor. The first connection also builds the circuit to the onion
+// service, so it's only used to warm up and the fastest of the
+// following ones is returned
+func MeasureRTT(t tor.Instance, address string, samples int) (time.Duration, error) {
+	var best time.Duration
+
+	for i := 0; i <= samples; i++ {
+		d, err := measureConnection(t, address)
+		if err != nil {
+			return 0, err
+		}
+
+		if i > 0 && (best == 0 || d < best) {
+			best = d
+		}
+	}
+
+	return best, nil
+}
+
+func measureConnection(t tor.Instance, address string) (time.Duration, error) {
+	start := time.Now()
+
+	conn, err := t.Dial("tcp", address)
+	if err != nil {
+		return 0, err
+	}
+
+	d := time.Since(start)
+	_ = conn.Close()
+
+	return d, nil
+}
+
+func (c *client) SetAudioPreset(p AudioPreset) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.audioPreset = &p
+}
+
+// applyAudioPreset writes the selected preset in the
+// audio section of the Mumble configuration file
+func (c *client) applyAudioPreset() error {
+	c.Lock()
+	p := c.audioPreset
+	c.Unlock()
+
+	if p == nil || !pathExists(c.configFile) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(c.configFile)
+	if err != nil {
+		return err
+	}
+
+	result := setIniValues(string(content), "audio", [][2]string{
+		{"quality", strconv.Itoa(p.Bitrate)},
+		{"frames", strconv.Itoa(p.FramesPerPacket)},
+		{"jitterbuffer", strconv.Itoa(p.JitterBuffer)},
+	})
+
+	return ioutil.WriteFile(c.configFile, []byte(result), 0600)
+}
+
+func (c *client) SetAudioSettings(s config.AudioSettings) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.audioSettings = s
+}
+
+// applyAudioSettings writes the audio settings tuned by the user in the
+// Mumble configuration file. They are applied after the audio preset,
+// so the values given by the user are the ones used
+func (c *client) applyAudioSettings() error {
+	c.Lock()
+	s := c.audioSettings
+	c.Unlock()
+
+	if !pathExists(c.configFile) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(c.configFile)
+	if err != nil {
+		return err
+	}
+
+	result := string(content)
+
+	audio := [][2]string{}
+	if s.Quality > 0 {
+		audio = append(audio, [2]string{"quality", strconv.Itoa(s.Quality)})
+	}
+	if s.JitterBuffer > 0 {
+		audio = append(audio, [2]string{"jitterbuffer", strconv.Itoa(s.JitterBuffer)})
+	}
+	if s.NoiseSuppression > 0 {
+		// Mumble keeps the noise suppression as a negative number
+		audio = append(audio, [2]string{"noisesupress", strconv.Itoa(-s.NoiseSuppression)})
+	}
+	if len(audio) > 0 {
+		result = setIniValues(result, "audio", audio)
+	}
+
+	devices := [][2]string{}
+	if s.InputDevice != "" {
+		devices = append(devices, [2]string{"input", s.InputDevice})
+	}
+	if s.OutputDevice != "" {
+		devices = append(devices, [2]string{"output", s.OutputDevice})
+	}
+	if len(devices) > 0 {
+		// The devices are only used by their own audio system,
+		// which might not be the one Mumble would choose
+		result = setIniValues(result, "audio", [][2]string{
+			{"input", audioSystemName},
+			{"output", audioSystemName},
+		})
+		result = setIniValues(result, audioSystem, devices)
+	}
+
+	if shortcut, ok := pushToTalkShortcut(s.PushToTalkKey); ok {
+		result = setIniValues(result, "shortcuts", shortcut)
+	}
+
+	return ioutil.WriteFile(c.configFile, []byte(result), 0600)
+}
+
+// setIniValues replaces the given keys in the section of the ini
+// content, adding the ones that don't exist at the end of it
+func setIniValues(content, section string, values [][2]string) string {
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines)+len(values))
+
+	pending := map[string]string{}
+	for _, v := range values {
+		pending[v[0]] = v[1]
+	}
+
+	addPending := func() {
+		for _, v := range values {
+			if value, ok := pending[v[0]]; ok {
+				result = append(result, v[0]+"="+value)
+				delete(pending, v[0])
+			}
+		}
+	}
+
+	inSection := false
+	found := false
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l)
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if inSection {
+				addPending()
+			}
+			inSection = trimmed == "["+section+"]"
+			found = found || inSection
+			result = append(result, l)
+			continue
+		}
+
+		if inSection {
+			key := strings.TrimSpace(strings.SplitN(trimmed, "=", 2)[0])
+			if value, ok := pending[key]; ok {
+				result = append(result, key+"="+value)
+				delete(pending, key)
+				continue
+			}
+		}
+
+		result = append(result, l)
+	}
+
+	if inSection {
+		addPending()
+	}
+
+	if !found {
+		result = append(result, "["+section+"]")
+		addPending()
+	}
+
+	return strings.Join(result, "\n")
+}
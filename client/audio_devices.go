@@ -0,0 +1,166 @@
+package client
+
+import (
+	"encoding/json"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The devices are listed with the tools of the sound server, like the
+// devices are checked. pw-dump gives the nodes of PipeWire, with the
+// names that its PulseAudio server uses too, which are the names Mumble
+// knows. pactl is used when PipeWire is not running. The monitors of the
+// outputs are not listed as inputs, since they record what is played
+
+// ErrAudioDevicesNotListed is an error to be trown when
+// the sound server can't tell which devices it has
+var ErrAudioDevicesNotListed = failure.New("client.audio-devices-not-listed", failure.CategoryUnknown, "the audio devices can't be listed", "write the name of the devices in the configuration file")
+
+const (
+	pipeWireNodeType        = "PipeWire:Interface:Node"
+	pipeWireInputClass      = "Audio/Source"
+	pipeWireOutputClass     = "Audio/Sink"
+	pulseAudioMonitorSuffix = ".monitor"
+)
+
+// AudioDevice is an input or an output device of the sound server
+type AudioDevice struct {
+	// Name is the name that the sound server and Mumble use
+	Name string
+
+	// Description is the name for people, which might be empty
+	Description string
+}
+
+// Label is the name of the device shown to the user
+func (d AudioDevice) Label() string {
+	if d.Description != "" {
+		return d.Description
+	}
+	return d.Name
+}
+
+// AudioDevices are the devices of the sound server, sorted by their label
+type AudioDevices struct {
+	Inputs  []AudioDevice
+	Outputs []AudioDevice
+}
+
+// ListAudioDevices asks the sound server for its devices
+func ListAudioDevices() (AudioDevices, error) {
+	if path, err := exec.LookPath("pw-dump"); err == nil {
+		/* #nosec G204 */
+		out, err := exec.Command(path).Output()
+		if err == nil {
+			if d, err := parsePipeWireDump(out); err == nil {
+				return d, nil
+			}
+		}
+	}
+
+	path, err := exec.LookPath("pactl")
+	if err != nil {
+		return AudioDevices{}, ErrAudioDevicesNotListed
+	}
+
+	var d AudioDevices
+	for _, kind := range []string{"sources", "sinks"} {
+		/* #nosec G204 */
+		out, err := exec.Command(path, "list", "short", kind).Output()
+		if err != nil {
+			return AudioDevices{}, ErrAudioDevicesNotListed.Wrap(err)
+		}
+
+		devices := parsePactlShortList(string(out))
+		if kind == "sources" {
+			d.Inputs = withoutMonitors(devices)
+		} else {
+			d.Outputs = devices
+		}
+	}
+
+	return d, nil
+}
+
+type pipeWireObject struct {
+	Type string `json:"type"`
+	Info *struct {
+		Props map[string]interface{} `json:"props"`
+	} `json:"info"`
+}
+
+func (o pipeWireObject) prop(name string) string {
+	if o.Info == nil {
+		return ""
+	}
+	v, _ := o.Info.Props[name].(string)
+	return v
+}
+
+func parsePipeWireDump(content []byte) (AudioDevices, error) {
+	var objects []pipeWireObject
+	if err := json.Unmarshal(content, &objects); err != nil {
+		return AudioDevices{}, err
+	}
+
+	var d AudioDevices
+	for _, o := range objects {
+		if o.Type != pipeWireNodeType || o.prop("node.name") == "" {
+			continue
+		}
+
+		device := AudioDevice{
+			Name:        o.prop("node.name"),
+			Description: o.prop("node.description"),
+		}
+
+		switch o.prop("media.class") {
+		case pipeWireInputClass:
+			d.Inputs = append(d.Inputs, device)
+		case pipeWireOutputClass:
+			d.Outputs = append(d.Outputs, device)
+		}
+	}
+
+	sortAudioDevices(d.Inputs)
+	sortAudioDevices(d.Outputs)
+
+	return d, nil
+}
+
+// parsePactlShortList reads the devices in the short list of pactl,
+// which has a line for each device with its fields separated by tabs,
+// the name being the second one
+func parsePactlShortList(content string) []AudioDevice {
+	var devices []AudioDevice
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+			continue
+		}
+		devices = append(devices, AudioDevice{Name: strings.TrimSpace(fields[1])})
+	}
+
+	sortAudioDevices(devices)
+
+	return devices
+}
+
+func withoutMonitors(devices []AudioDevice) []AudioDevice {
+	result := []AudioDevice{}
+	for _, d := range devices {
+		if !strings.HasSuffix(d.Name, pulseAudioMonitorSuffix) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+func sortAudioDevices(devices []AudioDevice) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		return strings.ToLower(devices[i].Label()) < strings.ToLower(devices[j].Label())
+	})
+}
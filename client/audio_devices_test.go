@@ -0,0 +1,230 @@
+package client
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type AudioDevicesSuite struct{}
+
+var _ = Suite(&AudioDevicesSuite{})
+
+// pipeWireDump is part of the output of pw-dump in a laptop with
+// PipeWire, with a client, a card and a stream left in
+const pipeWireDump = `[
+  {
+    "id": 0,
+    "type": "PipeWire:Interface:Core",
+    "version": 4,
+    "permissions": [ "r", "w", "x", "m" ],
+    "info": {
+      "cookie": 1333097977,
+      "user-name": "alice",
+      "name": "pipewire-0",
+      "props": {
+        "core.name": "pipewire-0",
+        "object.id": 0
+      }
+    }
+  },
+  {
+    "id": 46,
+    "type": "PipeWire:Interface:Device",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "props": {
+        "device.api": "alsa",
+        "device.description": "Built-in Audio",
+        "device.name": "alsa_card.pci-0000_00_1f.3",
+        "media.class": "Audio/Device",
+        "object.id": 46
+      }
+    }
+  },
+  {
+    "id": 52,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "max-input-ports": 65,
+      "max-output-ports": 0,
+      "state": "suspended",
+      "props": {
+        "device.id": 46,
+        "media.class": "Audio/Sink",
+        "node.description": "Built-in Audio Analog Stereo",
+        "node.name": "alsa_output.pci-0000_00_1f.3.analog-stereo",
+        "node.nick": "ALC257 Analog",
+        "object.id": 52,
+        "priority.session": 1009
+      }
+    }
+  },
+  {
+    "id": 53,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "state": "suspended",
+      "props": {
+        "device.id": 46,
+        "media.class": "Audio/Source",
+        "node.description": "Built-in Audio Analog Stereo",
+        "node.name": "alsa_input.pci-0000_00_1f.3.analog-stereo",
+        "object.id": 53
+      }
+    }
+  },
+  {
+    "id": 61,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "state": "running",
+      "props": {
+        "media.class": "Audio/Source",
+        "node.description": "AirPods Pro",
+        "node.name": "bluez_input.A0:B1:C2:D3:E4:F5.0",
+        "object.id": 61
+      }
+    }
+  },
+  {
+    "id": 70,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "state": "running",
+      "props": {
+        "application.name": "Firefox",
+        "media.class": "Stream/Output/Audio",
+        "node.name": "Firefox",
+        "object.id": 70
+      }
+    }
+  },
+  {
+    "id": 71,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": {
+      "props": {
+        "media.class": "Audio/Sink",
+        "node.description": "A sink without name",
+        "object.id": 71
+      }
+    }
+  },
+  {
+    "id": 72,
+    "type": "PipeWire:Interface:Node",
+    "version": 3,
+    "permissions": [ "r", "x", "m" ],
+    "info": null
+  }
+]`
+
+// pactlSources is the output of "pactl list short sources" with
+// PulseAudio, where every output has its monitor
+const pactlSources = "0\talsa_output.pci-0000_00_1f.3.analog-stereo.monitor\tmodule-alsa-card.c\ts16le 2ch 44100Hz\tSUSPENDED\n" +
+	"1\talsa_input.pci-0000_00_1f.3.analog-stereo\tmodule-alsa-card.c\ts16le 2ch 44100Hz\tSUSPENDED\n" +
+	"4\tbluez_sink.A0_B1_C2_D3_E4_F5.a2dp_sink.monitor\tmodule-bluez5-device.c\ts16le 2ch 44100Hz\tIDLE\n" +
+	"5\tbluez_source.A0_B1_C2_D3_E4_F5.handsfree_head_unit\tmodule-bluez5-device.c\ts16le 1ch 16000Hz\tRUNNING\n"
+
+// pactlSinks is the output of "pactl list short sinks" with the
+// PulseAudio server of PipeWire
+const pactlSinks = "52\talsa_output.pci-0000_00_1f.3.analog-stereo\tPipeWire\ts32le 2ch 48000Hz\tSUSPENDED\n" +
+	"60\tbluez_output.A0_B1_C2_D3_E4_F5.1\tPipeWire\ts16le 2ch 48000Hz\tRUNNING\n"
+
+func names(devices []AudioDevice) []string {
+	result := []string{}
+	for _, d := range devices {
+		result = append(result, d.Name)
+	}
+	return result
+}
+
+func (s *AudioDevicesSuite) Test_parsePipeWireDump_listsTheInputsAndOutputs(c *C) {
+	d, err := parsePipeWireDump([]byte(pipeWireDump))
+	c.Assert(err, IsNil)
+
+	c.Assert(d.Inputs, DeepEquals, []AudioDevice{
+		{Name: "bluez_input.A0:B1:C2:D3:E4:F5.0", Description: "AirPods Pro"},
+		{Name: "alsa_input.pci-0000_00_1f.3.analog-stereo", Description: "Built-in Audio Analog Stereo"},
+	})
+	c.Assert(d.Outputs, DeepEquals, []AudioDevice{
+		{Name: "alsa_output.pci-0000_00_1f.3.analog-stereo", Description: "Built-in Audio Analog Stereo"},
+	})
+}
+
+func (s *AudioDevicesSuite) Test_parsePipeWireDump_withDifferentContent(c *C) {
+	cases := []struct {
+		content string
+		valid   bool
+		inputs  []string
+		outputs []string
+	}{
+		{"", false, nil, nil},
+		{"[", false, nil, nil},
+		{`{"type": "PipeWire:Interface:Node"}`, false, nil, nil},
+		{"[]", true, []string{}, []string{}},
+		{"null", true, []string{}, []string{}},
+		{`[{"id": 1, "type": "PipeWire:Interface:Node"}]`, true, []string{}, []string{}},
+		{`[{"type": "PipeWire:Interface:Node", "info": {"props": {"node.name": 5, "media.class": "Audio/Sink"}}}]`, true, []string{}, []string{}},
+		{`[{"type": "PipeWire:Interface:Port", "info": {"props": {"node.name": "a", "media.class": "Audio/Sink"}}}]`, true, []string{}, []string{}},
+		{`[{"type": "PipeWire:Interface:Node", "info": {"props": {"node.name": "mic", "media.class": "Audio/Source"}}}]`, true, []string{"mic"}, []string{}},
+		{`[{"type": "PipeWire:Interface:Node", "info": {"props": {"node.name": "Zoom", "media.class": "Stream/Input/Audio"}}}]`, true, []string{}, []string{}},
+	}
+
+	for _, t := range cases {
+		d, err := parsePipeWireDump([]byte(t.content))
+		if !t.valid {
+			c.Assert(err, NotNil, Commentf("%q", t.content))
+			continue
+		}
+
+		c.Assert(err, IsNil, Commentf("%q", t.content))
+		c.Assert(names(d.Inputs), DeepEquals, t.inputs, Commentf("%q", t.content))
+		c.Assert(names(d.Outputs), DeepEquals, t.outputs, Commentf("%q", t.content))
+	}
+}
+
+func (s *AudioDevicesSuite) Test_parsePactlShortList_withDifferentContent(c *C) {
+	cases := []struct {
+		content string
+		names   []string
+	}{
+		{pactlSinks, []string{"alsa_output.pci-0000_00_1f.3.analog-stereo", "bluez_output.A0_B1_C2_D3_E4_F5.1"}},
+		{"", []string{}},
+		{"\n\n", []string{}},
+		{"No PulseAudio daemon running, or not running as session daemon.\n", []string{}},
+		{"3\t\tPipeWire\n7\t  \n", []string{}},
+		{"1\tspeakers", []string{"speakers"}},
+		{"2\t headphones \tPipeWire\ts16le 2ch 48000Hz\tIDLE\r\n", []string{"headphones"}},
+		{"9\tZ\tPipeWire\n8\ta\tPipeWire\n", []string{"a", "Z"}},
+	}
+
+	for _, t := range cases {
+		c.Assert(names(parsePactlShortList(t.content)), DeepEquals, t.names, Commentf("%q", t.content))
+	}
+}
+
+func (s *AudioDevicesSuite) Test_withoutMonitors_leavesTheRealInputs(c *C) {
+	c.Assert(names(withoutMonitors(parsePactlShortList(pactlSources))), DeepEquals, []string{
+		"alsa_input.pci-0000_00_1f.3.analog-stereo",
+		"bluez_source.A0_B1_C2_D3_E4_F5.handsfree_head_unit",
+	})
+
+	c.Assert(withoutMonitors(parsePactlShortList("")), DeepEquals, []AudioDevice{})
+	c.Assert(names(withoutMonitors(parsePactlShortList("0\tmonitor\tPipeWire\n1\tx.monitor\tPipeWire\n"))), DeepEquals, []string{"monitor"})
+}
+
+func (s *AudioDevicesSuite) Test_AudioDevice_Label_usesTheNameWithoutDescription(c *C) {
+	c.Assert(AudioDevice{Name: "alsa_input.usb"}.Label(), Equals, "alsa_input.usb")
+	c.Assert(AudioDevice{Name: "alsa_input.usb", Description: "USB Microphone"}.Label(), Equals, "USB Microphone")
+}
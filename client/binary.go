@@ -1,8 +1,6 @@
 package client
 
 import (
-	"errors"
-	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -11,20 +9,26 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 var (
-	errInvalidCommand             = errors.New("invalid command")
-	errInvalidBinaryFile          = errors.New("the defined binary file don't exists")
-	errBinaryAlreadyExists        = errors.New("the binary already exists in the destination directory")
-	errDestinationIsNotADirectory = errors.New("the destination to copy the binary is not a directory")
-	errNoClientInConfiguredPath   = errors.New("no client in the configured path")
+	errInvalidCommand             = failure.New("client.invalid-command", failure.CategoryMumble, "invalid command", "")
+	errInvalidBinaryFile          = failure.New("client.binary-file-not-found", failure.CategoryMumble, "the defined binary file don't exists", "")
+	errBinaryAlreadyExists        = failure.New("client.binary-already-exists", failure.CategoryMumble, "the binary already exists in the destination directory", "")
+	errDestinationIsNotADirectory = failure.New("client.destination-not-directory", failure.CategoryMumble, "the destination to copy the binary is not a directory", "")
+	errInvalidBinaryPath          = failure.New("client.invalid-binary-path", failure.CategoryConfiguration, "not valid binary path", "check the location of Mumble in the settings")
+	errNoClientInConfiguredPath   = failure.New("client.not-in-configured-path", failure.CategoryConfiguration, "no client in the configured path", "check the location of Mumble in the settings")
 )
 
 const (
 	mumbleBundleLibsDir   = "lib"
-	mumbleBundlePath      = "mumble/mumble"
-	wahayMumbleBundlePath = "wahay/mumble/mumble"
+	mumbleBundlePath      = "mumble/" + mumbleExecutable
+	wahayMumbleBundlePath = "wahay/" + mumbleBundlePath
+
+	// mumbleAppBinary is the binary inside of the application bundle of
+	// Mumble on macOS
+	mumbleAppBinary = "Contents/MacOS/Mumble"
 )
 
 type binary struct {
@@ -53,6 +57,23 @@ type binary struct {
 
 	// The last occurred error during Mumble binary detection
 	lastError error
+
+	// version is the detected version of Mumble, once it's needed
+	version *mumbleVersion
+
+	// sandbox is set when Mumble is installed with Flatpak or Snap,
+	// and then path is the command that runs it from the sandbox
+	sandbox *sandbox
+
+	// configDir is where the configuration of Mumble is written when it
+	// can't be placed next to the binary, which is removed with it
+	configDir string
+}
+
+// readsConfigNextToIt returns true when Mumble reads the configuration
+// placed next to its binary, instead of getting it with --config
+func (b *binary) readsConfigNextToIt() bool {
+	return b.sandbox == nil && configNextToBinary
 }
 
 func (b *binary) envIfBundle() []string {
@@ -80,7 +101,7 @@ func (b *binary) copyTo(path string) error {
 		return errDestinationIsNotADirectory
 	}
 
-	destination := filepath.Join(path, "mumble")
+	destination := filepath.Join(path, mumbleExecutable)
 
 	if pathExists(destination) {
 		return errBinaryAlreadyExists
@@ -102,6 +123,10 @@ func (b *binary) destroy() {
 }
 
 func (b *binary) remove() {
+	if b.configDir != "" {
+		wipeProfileDir(b.configDir)
+	}
+
 	if b.isTemporary {
 		err := os.RemoveAll(filepath.Dir(b.path))
 		if err != nil {
@@ -156,13 +181,17 @@ func newBinary(path string) *binary {
 	b.path = realBinaryPath(path)
 	if !pathExists(b.path) {
 		b.isValid = false
-		b.lastError = errors.New("not valid binary path")
+		b.lastError = errInvalidBinaryPath
 	}
 
 	return b
 }
 
 func realBinaryPath(path string) string {
+	if filepath.Ext(path) == ".app" {
+		return filepath.Join(path, mumbleAppBinary)
+	}
+
 	if isADirectory(path) {
 		// TODO: should we find all the Mumble binary possibilities inside the directory?
 		// Examples:
@@ -181,7 +210,11 @@ func searchBinary(conf *config.ApplicationConfig) *binary {
 		searchBinaryInLocalDir,
 		searchBinaryInCurrentWorkingDir,
 		searchBinaryInDataDir,
-		searchBinaryInSystem,
+		searchBinaryInSystem(conf),
+		searchBinaryInstalled,
+		searchBinaryInFlatpak,
+		searchBinaryInSnap,
+		searchBinaryInDownloads,
 	}
 
 	for _, c := range callbacks {
@@ -269,15 +302,17 @@ func searchBinaryInDataDir() (*binary, error) {
 	return nil, nil
 }
 
-func searchBinaryInSystem() (*binary, error) {
-	path, err := exec.LookPath("mumble")
-	if err != nil {
-		return nil, nil
-	}
+func searchBinaryInSystem(conf *config.ApplicationConfig) func() (*binary, error) {
+	return func() (*binary, error) {
+		path, err := conf.LookPath(mumbleExecutable, exec.LookPath)
+		if err != nil {
+			return nil, nil
+		}
 
-	b := isThereAnAvailableBinary(path)
+		b := isThereAnAvailableBinary(path)
 
-	return b, nil
+		return b, nil
+	}
 }
 
 func isThereAnAvailableBinary(path string) *binary {
@@ -285,6 +320,10 @@ func isThereAnAvailableBinary(path string) *binary {
 		"path": path,
 	}).Debug("isThereAnAvailableBinary()")
 
+	if b := sandboxedBinaryAt(path); b != nil {
+		return b
+	}
+
 	b := newBinary(path)
 	if !b.isValid {
 		return b
@@ -302,7 +341,7 @@ func isThereAnAvailableBinary(path string) *binary {
 	}
 
 	b.isBundle = isBundle
-	b.shouldBeCopied = !isBundle
+	b.shouldBeCopied = !isBundle && configNextToBinary
 
 	output, err := command.Output()
 	if len(output) == 0 && err != nil {
@@ -318,7 +357,7 @@ func checkLibsDependenciesInPath(path string) (isBundle bool, env []string) {
 	libsDir := filepath.Join(filepath.Dir(path), mumbleBundleLibsDir)
 
 	if pathExists(libsDir) {
-		env = append(env, fmt.Sprintf("LD_LIBRARY_PATH=%s", libsDir))
+		env = append(env, libsDirEnv(libsDir)...)
 		isBundle = true
 	}
 
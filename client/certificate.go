@@ -1,52 +1,88 @@
 package client
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
-
-	// #nosec
-	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/pem"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"net"
 	"net/url"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
 	log "github.com/sirupsen/logrus"
 )
 
-const certServerPort = 8181
+const (
+	certServerPort       = 8181
+	invitationTokenParam = "token"
+	certificateURLParam  = "certificate"
+	fingerprintParam     = "fingerprint"
+	embeddedCertParam    = "cert"
+
+	// fingerprintFragmentParam gives the fingerprint in the
+	// fragment of the URL, like "#fp=SHA256:<fingerprint>"
+	fingerprintFragmentParam = "fp"
+
+	// maxCertificateSize is much bigger than any PEM certificate, but
+	// keeps a malicious server from sending us an endless response
+	maxCertificateSize = 64 * 1024
+)
+
+var (
+	// ErrInvalidCertificateURL is an error to be trown when the location
+	// of the certificate of an external server is not valid
+	ErrInvalidCertificateURL = failure.New("client.invalid-certificate-url", failure.CategoryMeeting, "the certificate location is not valid", "check the certificate location given by the host")
+
+	// ErrInvalidCertificate is an error to be trown when the
+	// server sends something that is not a certificate
+	ErrInvalidCertificate = failure.New("client.invalid-certificate", failure.CategoryMeeting, "invalid certificate", "")
+
+	// ErrMeetingUnreachable is an error to be trown when the
+	// server of the meeting can't be reached over Tor
+	ErrMeetingUnreachable = failure.New("client.meeting-unreachable", failure.CategoryMeeting, "the meeting can't be reached", "check the meeting ID, the meeting might have finished")
+
+	// ErrCertificateFingerprintMismatch is an error to be trown when the
+	// certificate sent by the server is not the one in the invitation
+	ErrCertificateFingerprintMismatch = failure.New("client.certificate-fingerprint-mismatch", failure.CategoryMeeting, "the certificate of the meeting doesn't match the fingerprint in the invitation", "ask the host of the meeting for a new invitation")
+
+	// ErrCertificateNotTrusted is an error to be trown when the
+	// user doesn't confirm the fingerprint of the certificate
+	ErrCertificateNotTrusted = failure.New("client.certificate-not-trusted", failure.CategoryMeeting, "the certificate of the meeting was not trusted", "compare the fingerprint with the host of the meeting")
+
+	errInvalidMeetingAddress = failure.New("client.invalid-meeting-address", failure.CategoryMeeting, "invalid certificate url", "")
+)
 
 func (c *client) requestCertificate(address string) error {
+	defer config.LogDuration("join: request the certificate", time.Now())
+
 	hostname, port, err := extractHostAndPort(address)
 	if err != nil {
-		return errors.New("invalid certificate url")
+		return errInvalidMeetingAddress
 	}
 
-	u := &url.URL{
-		Scheme: "http",
-		Host:   net.JoinHostPort(hostname, strconv.Itoa(certServerPort)),
+	expected, err := invitation.NormalizeFingerprint(extractFingerprint(address))
+	if err != nil {
+		return err
 	}
 
-	content, err := c.tor.HTTPrequest(u.String())
-	if err != nil {
+	cert, withoutTLS, err := c.certificateFor(hostname, address, expected != "")
+	if err != nil || cert == nil {
 		return err
 	}
 
-	cert := []byte(content)
 	p, _ := strconv.Atoi(port)
-	err = c.storeCertificate(hostname, p, cert)
+	err = c.storeCertificate(hostname, p, cert, expected, withoutTLS)
 	if err != nil {
 		return err
 	}
@@ -54,184 +90,330 @@ func (c *client) requestCertificate(address string) error {
 	return c.saveCertificateConfigFile()
 }
 
-func extractHostAndPort(address string) (host string, port string, err error) {
-	u, err := url.Parse(address)
+// certificateFor returns the certificate of the server, in PEM format.
+// The host might have included it in the invitation, otherwise it's
+// requested from the meeting. Nil is returned for the servers that
+// don't publish their certificate. withoutTLS is true when the
+// certificate server of the meeting is too old to use TLS
+func (c *client) certificateFor(hostname, address string, hasFingerprint bool) (cert []byte, withoutTLS bool, err error) {
+	der, err := invitation.DecodeCertificate(extractEmbeddedCertificate(address))
 	if err != nil {
-		return
+		return nil, false, ErrInvalidCertificate
 	}
 
-	host, port, err = net.SplitHostPort(u.Host)
+	if der != nil {
+		log.WithFields(log.Fields{
+			"hostname": hostname,
+		}).Info("The certificate of the server is included in the invitation")
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), false, nil
+	}
+
+	// Only Wahay meetings, hosted in onion services, have a
+	// certificate server. For other servers, Mumble will ask
+	// the user to verify the certificate
+	if !strings.HasSuffix(hostname, ".onion") {
+		log.WithFields(log.Fields{
+			"hostname": hostname,
+		}).Info("The server is not an onion service, its certificate will not be requested")
+		return nil, false, nil
+	}
+
+	u, err := certificateURLFor(hostname, address)
 	if err != nil {
-		return
+		return nil, false, err
 	}
 
-	return host, port, nil
+	if extractCertificateURL(address) == "" {
+		cert, withoutTLS, err = c.requestCertificateWithTLS(u, hasFingerprint)
+	} else {
+		var content bytes.Buffer
+		_, err = c.tor.HTTPDownload(u.String(), &content, maxCertificateSize, nil)
+		cert = content.Bytes()
+	}
+
+	switch {
+	case err == tor.ErrResponseTooLarge:
+		return nil, false, ErrInvalidCertificate
+	case err == ErrInvalidCertificate || err == ErrCertificateNotPinned || err == ErrCertificateWithoutTLS:
+		return nil, false, err
+	case err != nil:
+		return nil, false, ErrMeetingUnreachable.Wrap(err)
+	}
+
+	return cert, withoutTLS, nil
 }
 
-func (c *client) storeCertificate(hostname string, port int, cert []byte) error {
-	if c.isTheCertificateInDB(hostname) {
-		return nil
+// certificateURLFor returns the location of the certificate for the
+// server in the given address. Wahay meetings serve it in their
+// certificate server, while an external Mumble server can publish it
+// anywhere in its own onion service
+func certificateURLFor(hostname, address string) (*url.URL, error) {
+	if location := extractCertificateURL(address); location != "" {
+		return externalCertificateURL(hostname, location)
 	}
 
-	block, _ := pem.Decode(cert)
-	if block == nil || block.Type != "CERTIFICATE" {
-		return errors.New("invalid certificate")
+	u := &url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(hostname, strconv.Itoa(certServerPort)),
+		Path:   "/",
+	}
+
+	token := extractInvitationToken(address)
+	if token != "" {
+		u.RawQuery = url.Values{invitationTokenParam: {token}}.Encode()
 	}
 
-	digest, err := digestForCertificate(block.Bytes)
+	return u, nil
+}
+
+// externalCertificateURL resolves the given location, that can be a path
+// in the onion service of the server or a full URL of another onion service
+func externalCertificateURL(hostname, location string) (*url.URL, error) {
+	ref, err := url.Parse(location)
 	if err != nil {
-		return err
+		return nil, ErrInvalidCertificateURL
 	}
 
-	log.WithFields(log.Fields{
-		"hostname": hostname,
-		"port":     port,
-		"digest":   digest,
-	}).Info("Storing Mumble client certificate")
+	base := &url.URL{
+		Scheme: "http",
+		Host:   hostname,
+		Path:   "/",
+	}
+
+	u := base.ResolveReference(ref)
 
-	return c.storeCertificateInDB(hostname, port, digest)
+	// The certificate must never be requested outside of Tor onion
+	// services, since that would reveal which server we are joining
+	if u.Scheme != "http" || !strings.HasSuffix(u.Hostname(), ".onion") {
+		return nil, ErrInvalidCertificateURL
+	}
+
+	return u, nil
 }
 
-const (
-	defaultHostToReplace   = "ffaaffaabbddaabbddeeaaddccaaffeebbaabbeeddeeaaddbbeeeeff.onion"
-	defaultPortToReplace   = 64738
-	defaultDigestToReplace = "AAABACADAFBABBBCBDBEBFCACBCCCDCECFDADBDC"
-)
+func extractHostAndPort(address string) (host string, port string, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return
+	}
 
-func (c *client) storeCertificateInDB(id string, port int, digest string) error {
-	db, err := c.db()
+	host, port, err = net.SplitHostPort(u.Host)
 	if err != nil {
-		return err
+		return
 	}
 
-	log.WithFields(log.Fields{
-		"defaultHost":   defaultHostToReplace,
-		"defaultPort":   defaultPortToReplace,
-		"defaultDigest": defaultDigestToReplace,
-		"newHost":       id,
-		"newPort":       port,
-		"newDigest":     digest,
-	}).Debug("Replacing content in Mumble sqlite database")
-
-	db.replaceString(defaultHostToReplace, id)
-	db.replaceString(defaultDigestToReplace, digest)
-	db.replaceInteger(uint16(defaultPortToReplace), uint16(port))
+	return host, port, nil
+}
 
-	return db.write()
+func extractInvitationToken(address string) string {
+	return extractQueryParam(address, invitationTokenParam)
 }
 
-func (c *client) isTheCertificateInDB(hostname string) bool {
-	d, err := c.db()
+func extractCertificateURL(address string) string {
+	return extractQueryParam(address, certificateURLParam)
+}
+
+func extractFingerprint(address string) string {
+	if f := extractQueryParam(address, fingerprintParam); f != "" {
+		return f
+	}
+
+	u, err := url.Parse(address)
 	if err != nil {
-		return false
+		return ""
 	}
 
-	return d.exists(hostname)
+	values, _ := url.ParseQuery(u.Fragment)
+	return values.Get(fingerprintFragmentParam)
+}
+
+func extractEmbeddedCertificate(address string) string {
+	return extractQueryParam(address, embeddedCertParam)
+}
+
+func extractQueryParam(address, param string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get(param)
 }
 
-func digestForCertificate(cert []byte) (string, error) {
-	// #nosec
-	h := sha1.New()
-	_, err := h.Write(cert)
+// removeWahayParams returns the given url without the invitation token,
+// the certificate, its location and its fingerprint, since that information
+// is only meant for getting the certificate and Mumble doesn't understand it
+func removeWahayParams(address string) string {
+	u, err := url.Parse(address)
 	if err != nil {
-		return "", err
+		return address
 	}
 
-	bs := h.Sum(nil)
+	q := u.Query()
+	q.Del(invitationTokenParam)
+	q.Del(certificateURLParam)
+	q.Del(fingerprintParam)
+	q.Del(embeddedCertParam)
+	u.RawQuery = q.Encode()
+	u.Fragment = ""
 
-	return fmt.Sprintf("%x", bs), nil
+	return u.String()
 }
 
-// openssl req -newkey rsa:2048 -nodes -keyout key.pem -x509 -days 365 -out certificate.pem
-func genCertInto(certFilename, keyFilename string) error {
-	now := time.Now()
-	tmpl := &x509.Certificate{
-		SerialNumber: big.NewInt(0),
-		Subject: pkix.Name{
-			CommonName: "Wahay Autogenerated Certificate",
-		},
-		NotBefore: now.Add(-300 * time.Second),
-		NotAfter:  now.Add(24 * time.Hour * 365),
+// storeCertificate trusts the given certificate for the server, once
+// its fingerprint has been verified. A certificate we already have
+// is verified by Mumble itself, so it's not checked again
+func (c *client) storeCertificate(hostname string, port int, cert []byte, expected string, withoutTLS bool) error {
+	if c.isTheCertificateInDB(hostname, port) {
+		return nil
+	}
 
-		SubjectKeyId: []byte{1, 2, 3, 4},
-		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	block, _ := pem.Decode(cert)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return ErrInvalidCertificate
 	}
 
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	digest := digestForCertificate(block.Bytes)
+
+	err := c.verifyCertificate(hostname, port, digest, expected, withoutTLS)
 	if err != nil {
 		return err
 	}
 
-	certbuf, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
-	if err != nil {
-		return err
+	log.WithFields(log.Fields{
+		"hostname":    hostname,
+		"port":        port,
+		"fingerprint": digest.SHA256,
+	}).Info("Storing Mumble client certificate")
+
+	c.rememberCertificate(hostname, port, digest)
+
+	return c.storeCertificateInDB(hostname, port, digest.SHA1)
+}
+
+// CertificateVerifier asks the user to confirm the fingerprint of the
+// certificate of the given server. withoutTLS tells that the certificate
+// server of the meeting is too old to use TLS. It's called from the
+// goroutine that launches the client, so it can block until the user answers
+type CertificateVerifier func(hostname, fingerprint string, withoutTLS bool) bool
+
+func (c *client) SetCertificateVerifier(v CertificateVerifier) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.verifier = v
+}
+
+// verifyCertificate compares the fingerprint with the one given in the
+// invitation. Without it, the user has to confirm the fingerprint,
+// unless the same certificate was already trusted for the server.
+// Without a verifier the certificate is never trusted the first time
+// it's received, so trusting it on first use needs a verifier that does it
+func (c *client) verifyCertificate(hostname string, port int, d CertificateDigest, expected string, withoutTLS bool) error {
+	if expected != "" {
+		if d.SHA256 != expected {
+			log.WithFields(log.Fields{
+				"hostname":    hostname,
+				"fingerprint": d.SHA256,
+				"expected":    expected,
+			}).Warning("The certificate of the server doesn't match the invitation")
+			return ErrCertificateFingerprintMismatch
+		}
+		return nil
 	}
-	certblk := pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certbuf,
+
+	if c.isTrusted(hostname, port, d) {
+		return nil
 	}
 
-	keybuf := x509.MarshalPKCS1PrivateKey(priv)
-	keyblk := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: keybuf,
+	c.Lock()
+	v := c.verifier
+	c.Unlock()
+
+	// Without anybody to ask, nothing vouches for the certificate
+	if v == nil {
+		log.WithFields(log.Fields{
+			"hostname":    hostname,
+			"fingerprint": d.SHA256,
+		}).Warning("Nobody can confirm the certificate of the server")
+		return ErrCertificateNotTrusted
 	}
 
-	file, err := os.OpenFile(certFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
+	if !v(hostname, d.SHA256, withoutTLS) {
+		return ErrCertificateNotTrusted
 	}
-	defer closeAndIgnore(file)
-	err = pem.Encode(file, &certblk)
+
+	return nil
+}
+
+// defaultHostToReplace is the host of the example certificate
+// in the database template, which is never needed
+const defaultHostToReplace = "ffaaffaabbddaabbddeeaaddccaaffeebbaabbeeddeeaaddbbeeeeff.onion"
+
+func (c *client) storeCertificateInDB(hostname string, port int, digest string) error {
+	db, err := c.db()
 	if err != nil {
 		return err
 	}
 
-	file, err = os.OpenFile(keyFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	log.WithFields(log.Fields{
+		"hostname": hostname,
+		"port":     port,
+		"digest":   digest,
+	}).Debug("Storing the certificate in the Mumble sqlite database")
+
+	err = db.removeCertificates(defaultHostToReplace)
 	if err != nil {
 		return err
 	}
-	defer closeAndIgnore(file)
-	err = pem.Encode(file, &keyblk)
+
+	err = db.storeCertificate(hostname, port, digest)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return db.write()
 }
 
-// generateTemporaryMumbleCertificate will generate a certificate and private key and
-// then format that in PKCS12, finally formatting it in the @ByteArray format that
-// Mumble configuration files use
-// This will fail if OpenSSL is not installed on the system.
-func generateTemporaryMumbleCertificate() (string, error) {
-	dir, err := ioutil.TempDir("", "wahay_cert_generation")
+func (c *client) isTheCertificateInDB(hostname string, port int) bool {
+	d, err := c.db()
 	if err != nil {
-		return "", err
+		return false
 	}
-	defer os.RemoveAll(dir)
 
-	err = genCertInto(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	return d.exists(hostname, port)
+}
+
+// newTemporaryCertificate returns a new self signed certificate
+// and its private key, using the given key algorithm
+func newTemporaryCertificate(algorithm string) ([]byte, crypto.Signer, error) {
+	return newSelfSignedCertificate(algorithm, 24*time.Hour*365)
+}
+
+func newSelfSignedCertificate(algorithm string, lifetime time.Duration) ([]byte, crypto.Signer, error) {
+	priv, usage, err := generateCertificateKey(algorithm)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	args := []string{"pkcs12", "-passout", "pass:", "-inkey", filepath.Join(dir, "key.pem"),
-		"-in", filepath.Join(dir, "cert.pem"), "-export", "-out", filepath.Join(dir, "transformed.p12")}
-	// This executes the openssl command. The args are completely under our control
-	/* #nosec G204 */
-	cmd := exec.Command("openssl", args...)
-	_, err = cmd.Output()
-	if err != nil {
-		return "", err
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(0),
+		Subject: pkix.Name{
+			CommonName: "Wahay Autogenerated Certificate",
+		},
+		NotBefore: now.Add(-300 * time.Second),
+		NotAfter:  now.Add(lifetime),
+
+		SubjectKeyId: []byte{1, 2, 3, 4},
+		KeyUsage:     usage,
 	}
 
-	data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(dir, "transformed.p12")))
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	return byteArrayUnparse(data), nil
+	return cert, priv, nil
 }
 
 // Implement functions that match the QByteArray used in Mumble among other things
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+const (
+	// CertificateKeyRSA2048 is the algorithm of the keys used by default
+	CertificateKeyRSA2048 = "rsa-2048"
+
+	// CertificateKeyRSA4096 is the algorithm of the bigger RSA keys
+	CertificateKeyRSA4096 = "rsa-4096"
+
+	// DefaultCertificateKey is the algorithm used when none has been configured
+	DefaultCertificateKey = CertificateKeyRSA2048
+)
+
+// ErrUnknownCertificateKey is an error to be trown when the
+// given algorithm can't be used for the Mumble certificate
+var ErrUnknownCertificateKey = failure.New("client.unknown-certificate-key", failure.CategoryConfiguration,
+	"the key algorithm of the certificate is not known", "choose another certificate key in the settings")
+
+// certificateKeys are the algorithms that can be used for the Mumble
+// certificate. Only RSA keys are known to be imported by every Mumble
+var certificateKeys = map[string]func() (crypto.Signer, error){
+	CertificateKeyRSA2048: func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	},
+	CertificateKeyRSA4096: func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 4096)
+	},
+}
+
+// unverifiedCertificateKeys are the algorithms that were offered for the
+// Mumble certificate before knowing if Mumble can import them. They are
+// replaced by the default one until that's verified with a real Mumble
+var unverifiedCertificateKeys = map[string]bool{
+	"ecdsa-p256": true,
+	"ed25519":    true,
+}
+
+// IsValidCertificateKey returns true if the given algorithm can be used
+// for the Mumble certificate. The empty value means the default one
+func IsValidCertificateKey(algorithm string) bool {
+	_, ok := certificateKeys[certificateKeyOrDefault(algorithm)]
+	return ok
+}
+
+func certificateKeyOrDefault(algorithm string) string {
+	if algorithm == "" || unverifiedCertificateKeys[algorithm] {
+		return DefaultCertificateKey
+	}
+	return algorithm
+}
+
+// generateCertificateKey returns a new key of the given algorithm
+// and the usage that the certificate for it must declare
+func generateCertificateKey(algorithm string) (crypto.Signer, x509.KeyUsage, error) {
+	generate, ok := certificateKeys[certificateKeyOrDefault(algorithm)]
+	if !ok {
+		return nil, 0, ErrUnknownCertificateKey
+	}
+
+	key, err := generate()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only RSA keys are used for encrypting the key exchange in
+	// TLS, the others are only used for signing it
+	usage := x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		usage |= x509.KeyUsageKeyEncipherment
+	}
+
+	return key, usage, nil
+}
+
+func (c *client) SetCertificateKey(algorithm string) error {
+	if !IsValidCertificateKey(algorithm) {
+		return ErrUnknownCertificateKey
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.certificateKey = certificateKeyOrDefault(algorithm)
+
+	return nil
+}
+
+func (c *client) getCertificateKey() string {
+	c.Lock()
+	defer c.Unlock()
+
+	return certificateKeyOrDefault(c.certificateKey)
+}
@@ -0,0 +1,156 @@
+package client
+
+import "crypto"
+
+// ClientCertificate is the certificate, in DER format, and the private
+// key the Mumble client uses to identify itself in the meetings
+type ClientCertificate struct {
+	Certificate []byte
+	Key         crypto.Signer
+}
+
+// CertificateProvider creates and keeps the certificates of the client.
+// Integrators can plug other backends, like a system keychain or a
+// hardware token, as long as the key can be given to Mumble
+type CertificateProvider interface {
+	// Generate creates a new certificate with a key of the given algorithm
+	Generate(algorithm string) (*ClientCertificate, error)
+
+	// Load returns the stored certificate, or nil if there is none
+	Load() (*ClientCertificate, error)
+
+	// Store keeps the certificate, so Load returns it from now on
+	Store(*ClientCertificate) error
+
+	// Fingerprint returns the SHA-256 fingerprint of the certificate
+	Fingerprint(*ClientCertificate) (string, error)
+}
+
+// identityCertificateProvider is the default provider. It loads the
+// identity set in the client and generates temporary certificates
+// when there is none, so every meeting uses a different one
+type identityCertificateProvider struct {
+	c *client
+}
+
+func (p identityCertificateProvider) Generate(algorithm string) (*ClientCertificate, error) {
+	cert, key, err := newTemporaryCertificate(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientCertificate{Certificate: cert, Key: key}, nil
+}
+
+func (p identityCertificateProvider) Load() (*ClientCertificate, error) {
+	p.c.Lock()
+	identity := p.c.identity
+	p.c.Unlock()
+
+	if identity == "" {
+		return nil, nil
+	}
+
+	cert, key, err := parseIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientCertificate{Certificate: cert, Key: key}, nil
+}
+
+func (p identityCertificateProvider) Store(cert *ClientCertificate) error {
+	identity, err := encodeIdentity(cert.Certificate, cert.Key)
+	if err != nil {
+		return err
+	}
+
+	return p.c.SetIdentity(identity)
+}
+
+func (p identityCertificateProvider) Fingerprint(cert *ClientCertificate) (string, error) {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return "", ErrInvalidIdentity
+	}
+
+	return digestForCertificate(cert.Certificate).SHA256, nil
+}
+
+func (c *client) SetCertificateProvider(p CertificateProvider) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.certificateProvider = p
+}
+
+func (c *client) getCertificateProvider() CertificateProvider {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.certificateProvider == nil {
+		return identityCertificateProvider{c}
+	}
+
+	return c.certificateProvider
+}
+
+// hasPersistentIdentity returns true if the client doesn't use temporary
+// certificates, so its configuration contains the identity of the user
+func (c *client) hasPersistentIdentity() bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.identity != "" || c.certificateProvider != nil
+}
+
+// mumbleCertificate returns the certificate given by the provider, or
+// a new one when it has none, in the format used by the Mumble
+// configuration file. The new certificate is not stored, the
+// provider decides when a certificate has to be kept
+func (c *client) mumbleCertificate() (string, error) {
+	p := c.getCertificateProvider()
+
+	cert, err := p.Load()
+	if err == nil && cert == nil {
+		cert, err = p.Generate(c.getCertificateKey())
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := encodeMumbleCertificate(cert)
+	if err != nil {
+		return "", err
+	}
+
+	c.setCertificateHash(digestForCertificate(cert.Certificate).SHA1)
+
+	return encoded, nil
+}
+
+func (c *client) setCertificateHash(hash string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.certificateHash = hash
+}
+
+func (c *client) CertificateHash() string {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.certificateHash
+}
+
+// encodeMumbleCertificate formats the certificate and its private key in
+// PKCS12, finally formatting it in the @ByteArray format that Mumble
+// configuration files use
+func encodeMumbleCertificate(cert *ClientCertificate) (string, error) {
+	data, err := encodePKCS12(cert.Certificate, cert.Key)
+	if err != nil {
+		return "", err
+	}
+
+	return byteArrayUnparse(data), nil
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"github.com/digitalautonomy/wahay/config"
+
+	. "gopkg.in/check.v1"
+)
+
+type CertificateSuite struct{}
+
+var _ = Suite(&CertificateSuite{})
+
+type certificateTestTrustStore struct {
+	trusted config.TrustedCertificate
+}
+
+func (s *certificateTestTrustStore) TrustedCertificate(host string, port int) (config.TrustedCertificate, bool) {
+	return s.trusted, s.trusted.Host == host && s.trusted.Port == port
+}
+
+func (s *certificateTestTrustStore) TrustCertificate(c config.TrustedCertificate) {
+	s.trusted = c
+}
+
+func (s *certificateTestTrustStore) CertificateChanged(string, int, string) {}
+
+var certificateTestDigest = CertificateDigest{SHA1: "aaaa", SHA256: "bbbb"}
+
+func (s *CertificateSuite) Test_verifyCertificate_comparesTheFingerprintOfTheInvitation(c *C) {
+	cl := &client{}
+
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "bbbb", false), IsNil)
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "cccc", false), Equals, ErrCertificateFingerprintMismatch)
+}
+
+func (s *CertificateSuite) Test_verifyCertificate_doesntTrustTheCertificateWithoutAVerifier(c *C) {
+	cl := &client{}
+
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "", false), Equals, ErrCertificateNotTrusted)
+}
+
+func (s *CertificateSuite) Test_verifyCertificate_asksTheVerifier(c *C) {
+	var asked []bool
+	answer := false
+
+	cl := &client{}
+	cl.SetCertificateVerifier(func(hostname, fingerprint string, withoutTLS bool) bool {
+		c.Assert(hostname, Equals, "example.onion")
+		c.Assert(fingerprint, Equals, "bbbb")
+		asked = append(asked, withoutTLS)
+		return answer
+	})
+
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "", true), Equals, ErrCertificateNotTrusted)
+
+	answer = true
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "", false), IsNil)
+	c.Assert(asked, DeepEquals, []bool{true, false})
+}
+
+func (s *CertificateSuite) Test_verifyCertificate_trustsTheCertificateTrustedBefore(c *C) {
+	cl := &client{}
+	cl.SetTrustStore(&certificateTestTrustStore{config.TrustedCertificate{Host: "example.onion", Port: 64738, SHA256: "bbbb"}})
+
+	c.Assert(cl.verifyCertificate("example.onion", 64738, certificateTestDigest, "", false), IsNil)
+	c.Assert(cl.verifyCertificate("example.onion", 443, certificateTestDigest, "", false), Equals, ErrCertificateNotTrusted)
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The certificate server of a Wahay meeting uses the certificate it gives
+// for TLS too. Requesting it over TLS, and checking that the certificate
+// received is the one the connection was made with, means that nobody
+// between Tor and the server of the host can replace it. Older versions
+// of Wahay don't use TLS, so their certificate is requested without it,
+// but only when the invitation has no fingerprint to compare it with.
+// The user is then told that the certificate was received without TLS
+// when asked to confirm its fingerprint
+
+// certificateRequestTimeout is generous because onion services
+// can take a long time to answer the first request
+const certificateRequestTimeout = 2 * time.Minute
+
+var (
+	// ErrCertificateNotPinned is an error to be trown when the certificate
+	// sent by the server is not the one it uses for the connection
+	ErrCertificateNotPinned = failure.New("client.certificate-not-pinned", failure.CategoryMeeting, "the certificate of the meeting is not the one its server uses", "ask the host of the meeting for a new invitation")
+
+	// ErrCertificateWithoutTLS is an error to be trown when the invitation
+	// has a fingerprint but the certificate server doesn't use TLS
+	ErrCertificateWithoutTLS = failure.New("client.certificate-without-tls", failure.CategoryMeeting, "the certificate server of the meeting doesn't use TLS", "ask the host of the meeting for a new invitation")
+
+	errNoPresentedCertificate = errors.New("the server didn't present a certificate")
+	errServerWithoutTLS       = errors.New("the server doesn't use TLS")
+)
+
+// requestCertificateWithTLS requests the certificate of a Wahay meeting
+// over TLS. Without TLS, the certificate is only requested again when
+// the invitation has no fingerprint, and withoutTLS is true then
+func (c *client) requestCertificateWithTLS(u *url.URL, hasFingerprint bool) (cert []byte, withoutTLS bool, err error) {
+	secure := *u
+	secure.Scheme = "https"
+
+	cert, err = c.downloadPinnedCertificate(&secure)
+	if !errors.Is(err, errServerWithoutTLS) {
+		return cert, false, err
+	}
+
+	if hasFingerprint {
+		log.WithFields(log.Fields{
+			"hostname": u.Hostname(),
+		}).Warning("The certificate server of the meeting doesn't use TLS, and the invitation has a fingerprint")
+		return nil, false, ErrCertificateWithoutTLS
+	}
+
+	log.WithFields(log.Fields{
+		"hostname": u.Hostname(),
+	}).Warning("The certificate server of the meeting doesn't use TLS")
+
+	var content bytes.Buffer
+	_, err = c.tor.HTTPDownload(u.String(), &content, maxCertificateSize, nil)
+	return content.Bytes(), true, err
+}
+
+// downloadPinnedCertificate requests the certificate over TLS, and checks
+// that it's the certificate that the server presented for the connection
+func (c *client) downloadPinnedCertificate(u *url.URL) ([]byte, error) {
+	var presented []byte
+
+	tlsConfig := &tls.Config{
+		// The certificate is self signed, so instead of verifying
+		// it, we compare it with the one sent by the server
+		/* #nosec G402 */
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errNoPresentedCertificate
+			}
+			presented = rawCerts[0]
+			return nil
+		},
+	}
+
+	// The handshake is made here, since the HTTP client doesn't tell
+	// which error made it fail when the server answers without TLS
+	dialTLS := func(network, address string) (net.Conn, error) {
+		conn, err := c.tor.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		tc := tls.Client(conn, tlsConfig)
+		err = tc.Handshake()
+
+		var notTLS tls.RecordHeaderError
+		if errors.As(err, &notTLS) {
+			_ = conn.Close()
+			return nil, errServerWithoutTLS
+		}
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return tc, nil
+	}
+
+	hc := &http.Client{
+		Transport: &http.Transport{
+			DialTLS: dialTLS,
+		},
+		Timeout: certificateRequestTimeout,
+	}
+
+	resp, err := hc.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the request failed with status %d", resp.StatusCode)
+	}
+
+	// We read one more byte to know if the content goes over the limit
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCertificateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxCertificateSize {
+		return nil, ErrInvalidCertificate
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, ErrInvalidCertificate
+	}
+
+	if !bytes.Equal(block.Bytes, presented) {
+		log.WithFields(log.Fields{
+			"hostname": u.Hostname(),
+		}).Warning("The certificate sent by the server is not the one it uses for TLS")
+		return nil, ErrCertificateNotPinned
+	}
+
+	return content, nil
+}
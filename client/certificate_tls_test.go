@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/digitalautonomy/wahay/tor"
+
+	. "gopkg.in/check.v1"
+)
+
+type CertificateTLSSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&CertificateTLSSuite{})
+
+// certificateTestTor connects every request to the certificate server
+// of the test, and remembers what was downloaded without TLS
+type certificateTestTor struct {
+	tor.Instance
+	address   string
+	downloads []string
+}
+
+func (t *certificateTestTor) Dial(network, _ string) (net.Conn, error) {
+	return net.Dial(network, t.address)
+}
+
+func (t *certificateTestTor) HTTPDownload(u string, w io.Writer, _ int64, _ tor.DownloadProgress) (int64, error) {
+	t.downloads = append(t.downloads, u)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/", t.address))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return io.Copy(w, resp.Body)
+}
+
+func (s *CertificateTLSSuite) SetUpSuite(c *C) {
+	// A certificate server of an older Wahay, without TLS
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n")
+	}))
+}
+
+func (s *CertificateTLSSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *CertificateTLSSuite) client() (*client, *certificateTestTor) {
+	t := &certificateTestTor{address: s.server.Listener.Addr().String()}
+	return &client{tor: t}, t
+}
+
+func (s *CertificateTLSSuite) certificateURL() *url.URL {
+	return &url.URL{Scheme: "http", Host: "example.onion:8181", Path: "/"}
+}
+
+func (s *CertificateTLSSuite) Test_requestCertificateWithTLS_neverRequestsAPinnedCertificateWithoutTLS(c *C) {
+	cl, t := s.client()
+
+	cert, withoutTLS, err := cl.requestCertificateWithTLS(s.certificateURL(), true)
+	c.Assert(err, Equals, ErrCertificateWithoutTLS)
+	c.Assert(cert, IsNil)
+	c.Assert(withoutTLS, Equals, false)
+	c.Assert(t.downloads, HasLen, 0)
+}
+
+func (s *CertificateTLSSuite) Test_requestCertificateWithTLS_requestsTheCertificateWithoutTLSWhenItCantBeCompared(c *C) {
+	cl, t := s.client()
+
+	cert, withoutTLS, err := cl.requestCertificateWithTLS(s.certificateURL(), false)
+	c.Assert(err, IsNil)
+	c.Assert(string(cert), Matches, "(?s)-----BEGIN CERTIFICATE-----.*")
+	c.Assert(withoutTLS, Equals, true)
+	c.Assert(t.downloads, DeepEquals, []string{"http://example.onion:8181/"})
+}
+
+func (s *CertificateTLSSuite) Test_certificateFor_failsForAPinnedInvitationWithoutTLS(c *C) {
+	cl, t := s.client()
+
+	cert, _, err := cl.certificateFor("example.onion", "mumble://example.onion:64738/?fp=abc", true)
+	c.Assert(err, Equals, ErrCertificateWithoutTLS)
+	c.Assert(cert, IsNil)
+	c.Assert(t.downloads, HasLen, 0)
+	c.Assert(isUnverifiedCertificate(err), Equals, true)
+}
@@ -3,7 +3,6 @@ package client
 //go:generate ../.build-tools/esc -o gen_client_files.go -pkg client -ignore "Makefile" files
 
 import (
-	"errors"
 	"io/ioutil"
 	"os/exec"
 	"sync"
@@ -11,6 +10,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 	"github.com/digitalautonomy/wahay/tor"
 )
 
@@ -28,6 +28,53 @@ type Instance interface {
 	// based on the given url.
 	Launch(url string, onClose func()) (tor.Service, error)
 
+	// UseTor sets the Tor instance used to run the client. It must be called
+	// before Launch when the system was initialized while Tor was bootstrapping
+	UseTor(tor.Instance)
+
+	// SetAudioPreset sets the audio settings used the next time the client is launched
+	SetAudioPreset(AudioPreset)
+
+	// SetAudioSettings sets the audio settings tuned by the user,
+	// which replace the ones of the audio preset
+	SetAudioSettings(config.AudioSettings)
+
+	// SetCertificateKey sets the key algorithm of the certificate
+	// generated the next time the client is launched
+	SetCertificateKey(string) error
+
+	// SetIdentity sets the certificate and key used in all the meetings.
+	// With an empty identity a temporary certificate is generated instead
+	SetIdentity(string) error
+
+	// SetCertificateVerifier sets who confirms the fingerprint of the
+	// certificate of a meeting when the invitation doesn't contain it.
+	// Without a verifier, those certificates are not trusted
+	SetCertificateVerifier(CertificateVerifier)
+
+	// SetTrustStore sets where the trusted certificates are remembered
+	SetTrustStore(TrustStore)
+
+	// SetIsolatedProfile sets if every meeting uses its own configuration,
+	// in a temporary directory wiped when the meeting is closed
+	SetIsolatedProfile(bool)
+
+	// SetCertificateProvider sets where the certificate of the client
+	// comes from. With a nil provider the identity is used
+	SetCertificateProvider(CertificateProvider)
+
+	// SetMuted mutes or unmutes the microphone in the current meeting
+	SetMuted(bool) error
+
+	// Version returns the version of the Mumble binary,
+	// or an empty string when there is no valid one
+	Version() string
+
+	// CertificateHash returns the SHA-1 hash of the certificate the
+	// client was given in the last launch, which is how Mumble servers
+	// identify it, or an empty string when it wasn't given one
+	CertificateHash() string
+
 	Destroy()
 }
 
@@ -42,6 +89,16 @@ type client struct {
 	err                   error
 	torCmdModifier        tor.ModifyCommand
 	tor                   tor.Instance
+	audioPreset           *AudioPreset
+	audioSettings         config.AudioSettings
+	certificateKey        string
+	identity              string
+	verifier              CertificateVerifier
+	trustStore            TrustStore
+	certificateProvider   CertificateProvider
+	isolatedProfile       bool
+	profile               *profile
+	certificateHash       string
 }
 
 func newMumbleClient(p mumbleIniProvider, d databaseProvider, t tor.Instance) *client {
@@ -61,14 +118,19 @@ type mumbleIniProvider func() string
 type databaseProvider func() []byte
 
 // InitSystem do the checking of the current system looking
-// for the  appropriate Mumble binary and check for errors
+// for the  appropriate Mumble binary and check for errors.
+// None of this needs Tor, so the given instance can be nil
+// and set later with UseTor. The isolated profiles left behind
+// by a Wahay that crashed are wiped too
 func InitSystem(conf *config.ApplicationConfig, tor tor.Instance) Instance {
+	wipeLeftoverProfiles()
+
 	i := newMumbleClient(rederMumbleIniConfig, readerMumbleDB, tor)
 
 	b := searchBinary(conf)
 
 	if b == nil {
-		return invalidInstance(errors.New("a valid binary of Mumble is no available in your system"))
+		return invalidInstance(ErrMumbleNotAvailable)
 	}
 
 	if b.shouldBeCopied {
@@ -88,6 +150,11 @@ func InitSystem(conf *config.ApplicationConfig, tor tor.Instance) Instance {
 		return invalidInstance(err)
 	}
 
+	err = i.newConfigDir()
+	if err != nil {
+		return invalidInstance(err)
+	}
+
 	err = i.ensureConfiguration()
 	if err != nil {
 		return invalidInstance(err)
@@ -95,10 +162,18 @@ func InitSystem(conf *config.ApplicationConfig, tor tor.Instance) Instance {
 
 	log.Infof("Using Mumble located at: %s\n", i.pathToBinary())
 	log.Infof("Using Mumble environment variables: %s\n", i.binaryEnv())
+	log.Infof("Using Mumble version: %s\n", b.mumbleVersion())
 
 	return i
 }
 
+func (c *client) Version() string {
+	if !c.isValid || c.binary == nil {
+		return ""
+	}
+	return c.binary.mumbleVersion().String()
+}
+
 func invalidInstance(err error) Instance {
 	invalidInstance := &client{
 		isValid: false,
@@ -108,24 +183,74 @@ func invalidInstance(err error) Instance {
 	return invalidInstance
 }
 
+func isUnverifiedCertificate(err error) bool {
+	return failure.Is(err, ErrCertificateFingerprintMismatch) ||
+		failure.Is(err, ErrCertificateNotTrusted) ||
+		failure.Is(err, ErrCertificateNotPinned) ||
+		failure.Is(err, ErrCertificateWithoutTLS)
+}
+
 func (c *client) Launch(url string, onClose func()) (tor.Service, error) {
+	c.setCertificateHash("")
+
+	err := c.startProfile()
+	if err != nil {
+		return nil, err
+	}
+
 	// First, we load the certificate from the remote server and if a
-	// valid certificate is found then we execute the client through Tor
-	err := c.requestCertificate(url)
+	// valid certificate is found then we execute the client through Tor.
+	// A certificate that can't be verified stops the launch
+	err = c.requestCertificate(url)
+	if isUnverifiedCertificate(err) {
+		c.closeProfile()
+		return nil, err
+	}
 	if err != nil {
 		log.WithFields(log.Fields{"url": url}).Errorf("Launch() client: %s", err.Error())
 	}
 
-	return c.execute([]string{url}, onClose)
+	err = c.applyAudioPreset()
+	if err != nil {
+		log.WithFields(log.Fields{"url": url}).Errorf("Launch() client: the audio preset can't be applied: %s", err.Error())
+	}
+
+	err = c.applyAudioSettings()
+	if err != nil {
+		log.WithFields(log.Fields{"url": url}).Errorf("Launch() client: the audio settings can't be applied: %s", err.Error())
+	}
+
+	err = c.applyProxySettings()
+	if err != nil {
+		c.closeProfile()
+		return nil, err
+	}
+
+	err = c.writeSettingsForVersion()
+	if err != nil {
+		log.WithFields(log.Fields{"url": url}).Errorf("Launch() client: the settings for Mumble %s can't be written: %s", c.binary.mumbleVersion(), err.Error())
+	}
+
+	return c.execute([]string{removeWahayParams(url)}, onClose)
+}
+
+func (c *client) UseTor(t tor.Instance) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.tor = t
 }
 
 func (c *client) execute(args []string, onClose func()) (tor.Service, error) {
-	s, err := c.tor.NewService(c.pathToBinary(), args, c.torCommandModifier())
+	s, err := c.tor.NewService(c.pathToBinary(), c.configArgs(args), c.torCommandModifier())
 	if err != nil {
-		return nil, errors.New("error: the service can't be started")
+		c.closeProfile()
+		return nil, errServiceCantStart.Wrap(err)
 	}
 
 	s.OnClose(func() {
+		c.closeProfile()
+
 		err := c.regenerateConfiguration()
 		if err != nil {
 			log.Errorf("Mumble client Destroy(): %s", err.Error())
@@ -139,7 +264,15 @@ func (c *client) execute(args []string, onClose func()) (tor.Service, error) {
 	return s, nil
 }
 
-var errInvalidBinary = errors.New("invalid client binary")
+var (
+	// ErrMumbleNotAvailable is an error to be trown when no
+	// valid binary of Mumble can be found in the system
+	ErrMumbleNotAvailable = failure.New("client.mumble-not-available", failure.CategoryMumble, "a valid binary of Mumble is no available in your system", "install Mumble or set its location in the settings")
+
+	errInvalidBinary         = failure.New("client.invalid-binary", failure.CategoryMumble, "invalid client binary", "install Mumble or set its location in the settings")
+	errInvalidProvidedBinary = failure.New("client.invalid-provided-binary", failure.CategoryMumble, "the provided binary is not valid", "")
+	errServiceCantStart      = failure.New("client.cant-start", failure.CategoryMumble, "error: the service can't be started", "")
+)
 
 func (c *client) validate() error {
 	c.isValid = false
@@ -172,12 +305,7 @@ func (c *client) pathToBinary() string {
 }
 
 func (c *client) binaryEnv() []string {
-	// This is a temporary fix for making sure that
-	// Mumble doesn't run under Wayland.
-	// Once the torsocks problem with Wayland has been
-	// fixed, we can make this conditional on the version
-	// of torsocks
-	env := []string{"QT_QPA_PLATFORM=xcb"}
+	env := platformBinaryEnv()
 	if c.isValid && c.binary != nil {
 		return append(env, c.binary.envIfBundle()...)
 	}
@@ -190,7 +318,7 @@ func (c *client) LastError() error {
 
 func (c *client) setBinary(b *binary) error {
 	if !b.isValid {
-		return errors.New("the provided binary is not valid")
+		return errInvalidProvidedBinary
 	}
 
 	c.binary = b
@@ -207,18 +335,30 @@ func (c *client) torCommandModifier() tor.ModifyCommand {
 	}
 
 	env := c.binaryEnv()
-	if len(env) == 0 {
-		return nil
+	confine := tor.ConfineWithAppArmor(tor.AppArmorProfileMumble)
+	if c.binary.sandbox != nil {
+		// The sandbox confines Mumble already
+		confine = func(*exec.Cmd) {}
 	}
 
 	c.torCmdModifier = func(command *exec.Cmd) {
 		command.Env = append(command.Env, env...)
+		confine(command)
 	}
 
 	return c.torCmdModifier
 }
 
 func (c *client) Destroy() {
+	c.closeProfile()
+
+	// We don't want to keep anything from the last session
+	// in the Mumble configuration, like the remote certificate
+	err := c.regenerateConfiguration()
+	if err != nil {
+		log.Errorf("Mumble client Destroy(): %s", err.Error())
+	}
+
 	c.binary.destroy()
 }
 
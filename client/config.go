@@ -1,7 +1,6 @@
 package client
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,12 +10,14 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 var (
-	errInvalidConfigFileDir    = errors.New("invalid client configuration directory")
-	errInvalidConfigFileDBFile = errors.New("invalid client data file")
-	errInvalidConfigFile       = errors.New("invalid client configuration")
+	errInvalidConfigFileDir    = failure.New("client.invalid-config-dir", failure.CategoryMumble, "invalid client configuration directory", "")
+	errInvalidConfigFileDBFile = failure.New("client.invalid-data-file", failure.CategoryMumble, "invalid client data file", "")
+	errInvalidConfigFile       = failure.New("client.invalid-config", failure.CategoryMumble, "invalid client configuration", "")
+	errInvalidMumbleIni        = failure.New("client.invalid-mumble-ini", failure.CategoryMumble, "invalid mumble.ini file", "")
 
 	mumbleFolders = []string{
 		"Overlay",
@@ -49,6 +50,8 @@ func (c *client) regenerateConfiguration() error {
 		log.Errorf("Mumble client regenerateConfiguration(): %s", err.Error())
 	}
 
+	c.removeJSONSettings()
+
 	return c.ensureConfiguration()
 }
 
@@ -111,6 +114,9 @@ func (c *client) ensureConfigurationDBFile() error {
 		return err
 	}
 
+	// The database gets the certificates of the meetings
+	config.TrackSensitiveFile(filepath.Join(c.configDir, configDBName), false)
+
 	return nil
 }
 
@@ -163,13 +169,14 @@ func (c *client) writeConfigToFile(path string) error {
 	}
 
 	c.configFile = configFile
+	config.TrackSensitiveFile(configFile, false)
 
 	return nil
 }
 
 func (c *client) saveCertificateConfigFile() error {
 	if !pathExists(c.configFile) {
-		return errors.New("invalid mumble.ini file")
+		return errInvalidMumbleIni
 	}
 
 	content, err := ioutil.ReadFile(c.configFile)
@@ -177,9 +184,9 @@ func (c *client) saveCertificateConfigFile() error {
 		return err
 	}
 
-	tmc, err := generateTemporaryMumbleCertificate()
+	tmc, err := c.mumbleCertificate()
 	if err != nil {
-		log.Debugf("Error generating temporary mumble certificate: %v, assigning empty string", err)
+		log.Debugf("Error generating mumble certificate: %v, assigning empty string", err)
 		tmc = ""
 	}
 
@@ -202,5 +209,8 @@ func (c *client) saveCertificateConfigFile() error {
 		return err
 	}
 
+	// The configuration now has the certificate of the client
+	config.TrackSensitiveFile(c.configFile, c.hasPersistentIdentity())
+
 	return nil
 }
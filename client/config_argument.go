@@ -0,0 +1,107 @@
+package client
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// When Mumble is sandboxed, or on Windows, it can't read a configuration
+// placed next to its binary and it doesn't run through torsocks. Its
+// configuration is then written in a temporary directory, given to it
+// with --config, and it connects through the SOCKS proxy of Tor
+
+const (
+	configDirPrefix = "wahay-mumble"
+
+	// socks5ProxyType is the Socks5Proxy value of the proxy type in Mumble
+	socks5ProxyType = "2"
+)
+
+var errNoTorForProxy = failure.New("client.no-tor-for-proxy", failure.CategoryTor, "Tor is not available for the proxy of Mumble", "")
+
+// newConfigDir creates the directory where the configuration of
+// Mumble is written, when it can't be placed next to the binary
+func (c *client) newConfigDir() error {
+	if c.binary.readsConfigNextToIt() {
+		return nil
+	}
+
+	var dir string
+	var err error
+
+	if c.binary.sandbox != nil {
+		dir, err = c.binary.sandbox.tempDir(configDirPrefix)
+	} else {
+		dir, err = ioutil.TempDir("", configDirPrefix)
+	}
+	if err != nil {
+		return err
+	}
+
+	config.TrackSensitiveFile(dir, false)
+	c.binary.configDir = dir
+	c.configDir = dir
+
+	return nil
+}
+
+// configArgs returns the arguments for running Mumble with our
+// configuration, when it can't read it next to the binary. They
+// are the given ones otherwise
+func (c *client) configArgs(args []string) []string {
+	if c.binary.readsConfigNextToIt() {
+		return args
+	}
+
+	configFile := c.configFile
+	if c.binary.mumbleVersion().usesJSONSettings() {
+		configFile = filepath.Join(filepath.Dir(c.configFile), jsonSettingsFileName)
+	}
+
+	args = append([]string{"--config", configFile}, args...)
+
+	if c.binary.sandbox == nil {
+		return args
+	}
+	return c.binary.sandbox.runArgs(args, c.binaryEnv(), filepath.Dir(configFile))
+}
+
+// applyProxySettings makes Mumble connect through the SOCKS proxy of Tor
+// and keep its database with our configuration, when it gets it with --config
+func (c *client) applyProxySettings() error {
+	if c.binary.readsConfigNextToIt() || !pathExists(c.configFile) {
+		return nil
+	}
+
+	if c.tor == nil {
+		return errNoTorForProxy
+	}
+
+	content, err := ioutil.ReadFile(c.configFile)
+	if err != nil {
+		return err
+	}
+
+	host, port := c.tor.SocksAddress()
+
+	result := setIniValues(string(content), "net", [][2]string{
+		{"proxytype", socks5ProxyType},
+		{"proxyhost", host},
+		{"proxyport", strconv.Itoa(port)},
+	})
+	result = setIniValues(result, "General", [][2]string{
+		{"databaselocation", filepath.Join(filepath.Dir(c.configFile), configDBName)},
+	})
+
+	log.WithFields(log.Fields{
+		"binary": c.pathToBinary(),
+	}).Debug("Using the SOCKS proxy of Tor in Mumble")
+
+	return ioutil.WriteFile(c.configFile, []byte(result), 0600)
+}
@@ -1,8 +1,6 @@
 package client
 
 import (
-	"bytes"
-	b "encoding/binary"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -33,44 +31,45 @@ func (c *client) db() (*dbData, error) {
 	return d, nil
 }
 
+// dbData is the sqlite database where Mumble keeps the
+// servers and the certificates it has accepted
 type dbData struct {
 	filename string
-	content  []byte
+	db       *sqliteDB
 }
 
-func (d *dbData) exists(k string) bool {
-	return bytes.Contains(d.content, []byte(k))
-}
+// exists returns true if there is a certificate for the given server
+func (d *dbData) exists(hostname string, port int) bool {
+	rows, err := d.db.rows("cert")
+	if err != nil {
+		return false
+	}
 
-func (d *dbData) replaceString(find, replace string) {
-	newContent := bytes.Replace(d.content, []byte(find), []byte(replace), -1)
-	d.content = newContent
-}
+	for _, r := range rows {
+		h, _ := r.values[1].(string)
+		p, _ := r.values[2].(int64)
+		if h == hostname && p == int64(port) {
+			return true
+		}
+	}
 
-func (d *dbData) replaceInteger(find, replace uint16) {
-	d.replaceString(
-		intToStringToSearch(find),
-		intToStringToSearch(replace),
-	)
+	return false
 }
 
-func (d *dbData) write() error {
-	err := ioutil.WriteFile(d.filename, d.content, 0)
-	if err != nil {
-		return err
-	}
-	return nil
+// storeCertificate adds the certificate digest for the server,
+// replacing the one we had before for the same host and port
+func (d *dbData) storeCertificate(hostname string, port int, digest string) error {
+	return d.db.upsert("cert",
+		map[string]interface{}{"hostname": hostname, "port": port},
+		map[string]interface{}{"digest": digest})
 }
 
-func intToStringToSearch(n uint16) string {
-	n1 := n >> 8
-	n2 := n % 256
-
-	buf := new(bytes.Buffer)
-	_ = b.Write(buf, b.LittleEndian, uint8(n1))
-	_ = b.Write(buf, b.LittleEndian, uint8(n2))
+func (d *dbData) removeCertificates(hostname string) error {
+	return d.db.delete("cert", map[string]interface{}{"hostname": hostname})
+}
 
-	return buf.String()
+func (d *dbData) write() error {
+	return ioutil.WriteFile(d.filename, d.db.bytes(), 0600)
 }
 
 func loadDBFromFile(filename string) (*dbData, error) {
@@ -78,14 +77,25 @@ func loadDBFromFile(filename string) (*dbData, error) {
 		"filepath": filename,
 	}).Debug("Loading Mumble sqlite database")
 
+	// The changes that are still in the write-ahead log of a database
+	// in WAL mode would be lost if the main file was rewritten
+	if fi, err := os.Stat(filename + "-wal"); err == nil && fi.Size() > 0 {
+		return nil, errUnsupportedSQLite
+	}
+
 	content, err := readBinaryContent(filename)
 	if err != nil {
 		return nil, err
 	}
 
+	db, err := parseSQLite(content)
+	if err != nil {
+		return nil, err
+	}
+
 	d := &dbData{
 		filename: filename,
-		content:  content,
+		db:       db,
 	}
 
 	return d, nil
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The audio devices are checked before joining a meeting by recording a
+// few seconds from the input device and playing them back in the output
+// device. Mumble is not running yet, so the recording is made with the
+// tools of the sound server: pacat works with PulseAudio and with the
+// PulseAudio server that PipeWire has, and pw-cat with PipeWire alone.
+// The audio is kept in memory, as raw 16 bits samples, and never saved
+
+const (
+	// deviceCheckRate and deviceCheckChannels are the format of the
+	// recording, which is the one Mumble uses
+	deviceCheckRate     = 48000
+	deviceCheckChannels = 1
+
+	// bytesPerSample is the size of a 16 bits sample
+	bytesPerSample = 2
+
+	// silenceLevel is the level under which a recording
+	// is considered to have no sound at all
+	silenceLevel = 0.02
+)
+
+var (
+	// ErrNoAudioTools is an error to be trown when none of
+	// the tools used to record and play audio are installed
+	ErrNoAudioTools = failure.New("client.no-audio-tools", failure.CategoryUnknown, "the audio devices can't be checked, since neither pacat nor pw-cat are installed", "install the utilities of PulseAudio or PipeWire")
+
+	// ErrAudioRecordingFailed is an error to be trown when the
+	// input device stops before recording what was asked for
+	ErrAudioRecordingFailed = failure.New("client.audio-recording-failed", failure.CategoryConfiguration, "the input device can't be recorded", "check the name of the input device")
+
+	// ErrAudioPlaybackFailed is an error to be trown when
+	// the recording can't be played in the output device
+	ErrAudioPlaybackFailed = failure.New("client.audio-playback-failed", failure.CategoryConfiguration, "the recording can't be played in the output device", "check the name of the output device")
+)
+
+// audioTool is a program of the sound server that records and plays
+// raw audio through its standard output and input
+type audioTool struct {
+	name      string
+	format    []string
+	device    string
+	record    string
+	playback  string
+	stdioName []string
+}
+
+var audioTools = []audioTool{
+	{
+		name:     "pacat",
+		format:   []string{"--raw", "--format=s16le", "--rate=" + strconv.Itoa(deviceCheckRate), "--channels=" + strconv.Itoa(deviceCheckChannels)},
+		device:   "--device=",
+		record:   "--record",
+		playback: "--playback",
+	},
+	{
+		name:      "pw-cat",
+		format:    []string{"--raw", "--format=s16", "--rate=" + strconv.Itoa(deviceCheckRate), "--channels=" + strconv.Itoa(deviceCheckChannels)},
+		device:    "--target=",
+		record:    "--record",
+		playback:  "--playback",
+		stdioName: []string{"-"},
+	},
+}
+
+func (t audioTool) args(mode, device string) []string {
+	args := append([]string{mode}, t.format...)
+	if device != "" {
+		args = append(args, t.device+device)
+	}
+	return append(args, t.stdioName...)
+}
+
+// findAudioTool returns the first of the audio tools that is installed
+func findAudioTool() (audioTool, string, error) {
+	for _, t := range audioTools {
+		if path, err := exec.LookPath(t.name); err == nil {
+			return t, path, nil
+		}
+	}
+	return audioTool{}, "", ErrNoAudioTools
+}
+
+// AudioSample is a short recording made to check the audio devices
+type AudioSample struct {
+	data []byte
+}
+
+// RecordAudioSample records from the input device for the given time.
+// An empty device is the default input device of the sound server. The
+// recording stops early, without an error, when the context is done
+func RecordAudioSample(ctx context.Context, device string, d time.Duration) (*AudioSample, error) {
+	t, path, err := findAudioTool()
+	if err != nil {
+		return nil, err
+	}
+
+	recording, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var stderr bytes.Buffer
+	/* #nosec G204 */
+	cmd := exec.CommandContext(recording, path, t.args(t.record, device)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, ErrAudioRecordingFailed.Wrap(err)
+	}
+
+	size := int(d/time.Millisecond) * deviceCheckRate * deviceCheckChannels * bytesPerSample / 1000
+	data := make([]byte, size)
+	n, readErr := io.ReadFull(out, data)
+
+	// The tools record until they are stopped
+	stop()
+	_ = cmd.Wait()
+
+	if readErr != nil && ctx.Err() == nil {
+		log.WithFields(log.Fields{
+			"context": "audio",
+			"tool":    t.name,
+			"device":  device,
+			"output":  strings.TrimSpace(stderr.String()),
+		}).Errorf("the input device can't be recorded: %s", readErr)
+		return nil, ErrAudioRecordingFailed.Wrap(readErr)
+	}
+
+	return &AudioSample{data: data[:n-n%bytesPerSample]}, nil
+}
+
+// Play plays the recording in the output device, and returns once it
+// has been played. An empty device is the default output device of
+// the sound server
+func (s *AudioSample) Play(ctx context.Context, device string) error {
+	t, path, err := findAudioTool()
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	/* #nosec G204 */
+	cmd := exec.CommandContext(ctx, path, t.args(t.playback, device)...)
+	cmd.Stdin = bytes.NewReader(s.data)
+	cmd.Stderr = &stderr
+
+	if err = cmd.Run(); err != nil && ctx.Err() == nil {
+		log.WithFields(log.Fields{
+			"context": "audio",
+			"tool":    t.name,
+			"device":  device,
+			"output":  strings.TrimSpace(stderr.String()),
+		}).Errorf("the recording can't be played: %s", err)
+		return ErrAudioPlaybackFailed.Wrap(err)
+	}
+
+	return nil
+}
+
+// Level is the loudest sample of the recording, from 0 to 1
+func (s *AudioSample) Level() float64 {
+	peak := 0
+	for i := 0; i+bytesPerSample <= len(s.data); i += bytesPerSample {
+		// The samples are little endian
+		v := int(int16(uint16(s.data[i]) | uint16(s.data[i+1])<<8))
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+	return float64(peak) / 32768
+}
+
+// IsSilent is true when nothing was heard in the recording,
+// which usually means that the wrong input device is used
+func (s *AudioSample) IsSilent() bool {
+	return s.Level() < silenceLevel
+}
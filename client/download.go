@@ -0,0 +1,131 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// The known-good Mumble AppImage for the architecture Wahay is built
+// for. They are set when building, from the MUMBLE_DOWNLOAD_URL and
+// MUMBLE_DOWNLOAD_SHA256 variables of the Makefile. Without them
+// Mumble is never downloaded
+var (
+	mumbleDownloadURL    string
+	mumbleDownloadSHA256 string
+)
+
+const (
+	mumbleDownloadDir     = "wahay/mumble"
+	mumbleDownloadFile    = "Mumble.AppImage"
+	maxMumbleDownloadSize = 256 * 1024 * 1024
+)
+
+var (
+	errMumbleDownloadNotAvailable = failure.New("client.mumble-download-not-available", failure.CategoryMumble, "there is no known version of Mumble to download for this system", "install Mumble or set its location in the settings")
+	errMumbleDownloadFailed       = failure.New("client.mumble-download-failed", failure.CategoryTor, "Mumble couldn't be downloaded", "check your connection to the Tor network")
+	errMumbleDownloadInvalid      = failure.New("client.mumble-download-invalid", failure.CategoryMumble, "the downloaded Mumble is not the expected one", "")
+)
+
+// CanDownloadMumble returns true if Wahay knows which Mumble
+// to download for the current system
+func CanDownloadMumble() bool {
+	if runtime.GOOS != "linux" || mumbleDownloadURL == "" {
+		return false
+	}
+
+	d, err := hex.DecodeString(mumbleDownloadSHA256)
+	return err == nil && len(d) == sha256.Size
+}
+
+func downloadedMumblePath() string {
+	return filepath.Join(config.XdgDataHome(), mumbleDownloadDir, mumbleDownloadFile)
+}
+
+// DownloadMumble downloads the known-good Mumble over Tor and keeps it in
+// the Wahay data directory once its digest has been verified. It will be
+// used the next time the system is initialized if no other Mumble is found
+func DownloadMumble(t tor.Instance, progress tor.DownloadProgress) error {
+	if !CanDownloadMumble() || t == nil {
+		return errMumbleDownloadNotAvailable
+	}
+
+	path := downloadedMumblePath()
+	config.EnsureDir(filepath.Dir(path), 0700)
+
+	f, err := ioutil.TempFile(filepath.Dir(path), mumbleDownloadFile+".")
+	if err != nil {
+		return errMumbleDownloadFailed.Wrap(err)
+	}
+
+	// Nothing is left behind when the download fails
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	_, err = t.HTTPDownload(mumbleDownloadURL, io.MultiWriter(f, h), maxMumbleDownloadSize, progress)
+	closeAndIgnore(f)
+	if err != nil {
+		return errMumbleDownloadFailed.Wrap(err)
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), mumbleDownloadSHA256) {
+		return errMumbleDownloadInvalid
+	}
+
+	err = os.Chmod(f.Name(), 0700)
+	if err != nil {
+		return errMumbleDownloadFailed.Wrap(err)
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return errMumbleDownloadFailed.Wrap(err)
+	}
+
+	log.Infof("Mumble downloaded to: %s", path)
+
+	return nil
+}
+
+// isDownloadedMumbleValid checks the downloaded Mumble again before
+// using it, so a file changed since the download is never run
+func isDownloadedMumbleValid(path string) bool {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	defer closeAndIgnore(f)
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+
+	return err == nil && strings.EqualFold(hex.EncodeToString(h.Sum(nil)), mumbleDownloadSHA256)
+}
+
+func searchBinaryInDownloads() (*binary, error) {
+	path := downloadedMumblePath()
+	if !CanDownloadMumble() || !pathExists(path) {
+		return nil, nil
+	}
+
+	if !isDownloadedMumbleValid(path) {
+		log.Warningf("The downloaded Mumble at %s is not the expected one, removing it", path)
+		_ = os.Remove(path)
+		return nil, nil
+	}
+
+	return isThereAnAvailableBinary(path), nil
+}
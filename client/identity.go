@@ -0,0 +1,175 @@
+package client
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+const (
+	// IdentityFileExtension is the extension of the exported identity files
+	IdentityFileExtension = ".wahayid"
+
+	identityLifetime = 24 * time.Hour * 365 * 20
+
+	pemCertificateType = "CERTIFICATE"
+	pemPrivateKeyType  = "PRIVATE KEY"
+)
+
+var (
+	// ErrInvalidIdentity is an error to be trown when the identity
+	// doesn't contain a valid certificate and private key
+	ErrInvalidIdentity = failure.New("client.invalid-identity", failure.CategoryMumble, "the identity is not valid", "generate a new identity in the settings")
+
+	// ErrIdentityPasswordRequired is an error to be trown when
+	// an identity is exported without a password
+	ErrIdentityPasswordRequired = failure.New("client.identity-password-required", failure.CategoryInput, "a password is required to protect the identity", "")
+
+	// ErrInvalidIdentityFile is an error to be trown when the imported
+	// file can't be decrypted or doesn't contain an identity
+	ErrInvalidIdentityFile = failure.New("client.invalid-identity-file", failure.CategoryInput, "the identity file is not valid or the password is wrong", "check the password used when the identity was exported")
+)
+
+// NewIdentity generates a long lived certificate, with a key of the given
+// algorithm, so the same identity can be used in all the meetings. The
+// certificate and its private key are returned encoded in PEM
+func NewIdentity(algorithm string) (string, error) {
+	cert, key, err := newSelfSignedCertificate(algorithm, identityLifetime)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeIdentity(cert, key)
+}
+
+func encodeIdentity(cert []byte, key crypto.Signer) (string, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	result := pem.EncodeToMemory(&pem.Block{Type: pemCertificateType, Bytes: cert})
+	result = append(result, pem.EncodeToMemory(&pem.Block{Type: pemPrivateKeyType, Bytes: keyDER})...)
+
+	return string(result), nil
+}
+
+// parseIdentity returns the certificate and the private key of the
+// identity, checking that the key belongs to the certificate
+func parseIdentity(identity string) ([]byte, crypto.Signer, error) {
+	var cert, keyDER []byte
+
+	rest := []byte(identity)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case pemCertificateType:
+			cert = block.Bytes
+		case pemPrivateKeyType:
+			keyDER = block.Bytes
+		}
+	}
+
+	if cert == nil || keyDER == nil {
+		return nil, nil, ErrInvalidIdentity
+	}
+
+	parsed, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, nil, ErrInvalidIdentity.Wrap(err)
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, nil, ErrInvalidIdentity.Wrap(err)
+	}
+
+	key, ok := k.(crypto.Signer)
+	if !ok || !samePublicKey(parsed.PublicKey, key.Public()) {
+		return nil, nil, ErrInvalidIdentity
+	}
+
+	return cert, key, nil
+}
+
+func samePublicKey(a, b crypto.PublicKey) bool {
+	ad, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+
+	bd, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+
+	return string(ad) == string(bd)
+}
+
+// IdentityFingerprint returns the SHA-256 digest of the identity certificate
+func IdentityFingerprint(identity string) (string, error) {
+	cert, _, err := parseIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+
+	return digestForCertificate(cert).SHA256, nil
+}
+
+// ExportIdentity encrypts the identity with the given password, so it
+// can be moved to another device and imported there
+func ExportIdentity(identity, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrIdentityPasswordRequired
+	}
+
+	_, _, err := parseIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.EncryptWithPassword([]byte(identity), password)
+}
+
+// ImportIdentity returns the identity contained in a file created by ExportIdentity
+func ImportIdentity(content []byte, password string) (string, error) {
+	decrypted, err := config.DecryptWithPassword(content, password)
+	if err != nil {
+		return "", ErrInvalidIdentityFile.Wrap(err)
+	}
+
+	identity := string(decrypted)
+
+	_, _, err = parseIdentity(identity)
+	if err != nil {
+		return "", ErrInvalidIdentityFile.Wrap(err)
+	}
+
+	return identity, nil
+}
+
+func (c *client) SetIdentity(identity string) error {
+	var err error
+	if identity != "" {
+		_, _, err = parseIdentity(identity)
+		if err != nil {
+			identity = ""
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.identity = identity
+
+	return err
+}
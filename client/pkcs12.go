@@ -0,0 +1,240 @@
+package client
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+
+	// #nosec
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+// Mumble keeps its own certificate in the configuration file as a
+// PKCS#12 archive without password. This file implements just enough of
+// PKCS#12 (RFC 7292) for writing that archive: one certificate and its
+// private key, in plain bags, protected by the mandatory MAC. No external
+// tool is needed, so it also works in minimal systems and sandboxes.
+
+var (
+	oidDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidKeyBag          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidCertBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA1            = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+const (
+	pkcs12Version       = 3
+	pkcs12MacIterations = 2048
+	pkcs12MacSaltSize   = 8
+
+	// pkcs12MacKeyID is the diversifier of the key derivation for MAC keys
+	pkcs12MacKeyID = 3
+)
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// encodePKCS12 returns the PKCS#12 archive with the given certificate and
+// key, protected with an empty password, like `openssl pkcs12 -passout pass:`
+func encodePKCS12(certDER []byte, key crypto.Signer) ([]byte, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The local key ID tells which certificate belongs to the key
+	// #nosec
+	keyID := sha1.Sum(certDER)
+	attributes, err := pkcs12LocalKeyID(keyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := asn1.Marshal(pkcs12CertBag{ID: oidCertTypeX509, Data: certDER})
+	if err != nil {
+		return nil, err
+	}
+
+	safeContents, err := asn1.Marshal([]pkcs12SafeBag{
+		{ID: oidCertBag, Value: explicitContent(cert), Attributes: attributes},
+		{ID: oidKeyBag, Value: explicitContent(keyDER), Attributes: attributes},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := pkcs12Data(safeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticatedSafe, err := asn1.Marshal([]pkcs12ContentInfo{data})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := pkcs12Data(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, pkcs12MacSaltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs12PFX{
+		Version:  pkcs12Version,
+		AuthSafe: authSafe,
+		MacData: pkcs12MacData{
+			Mac: pkcs12DigestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest:    pkcs12MAC(authenticatedSafe, salt, pkcs12MacIterations),
+			},
+			MacSalt:    salt,
+			Iterations: pkcs12MacIterations,
+		},
+	})
+}
+
+// explicitContent wraps the given DER value in an explicit [0] tag
+func explicitContent(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// pkcs12Data returns the content info of type data for the given content
+func pkcs12Data(content []byte) (pkcs12ContentInfo, error) {
+	octets, err := asn1.Marshal(content)
+	if err != nil {
+		return pkcs12ContentInfo{}, err
+	}
+
+	return pkcs12ContentInfo{
+		ContentType: oidDataContentType,
+		Content:     explicitContent(octets),
+	}, nil
+}
+
+func pkcs12LocalKeyID(id []byte) ([]pkcs12Attribute, error) {
+	octets, err := asn1.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return []pkcs12Attribute{{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: octets},
+	}}, nil
+}
+
+// pkcs12MAC returns the HMAC-SHA1 of the content with a key
+// derived from the empty password, as described in RFC 7292
+func pkcs12MAC(content, salt []byte, iterations int) []byte {
+	// The empty password is encoded as a BMPString with its null terminator
+	password := []byte{0, 0}
+
+	key := pkcs12DeriveKey(password, salt, pkcs12MacKeyID, iterations, sha1.Size)
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(content)
+
+	return mac.Sum(nil)
+}
+
+// pkcs12DeriveKey implements the key derivation of RFC 7292, appendix B.2, with SHA-1
+func pkcs12DeriveKey(password, salt []byte, id byte, iterations, size int) []byte {
+	const u = sha1.Size
+	const v = 64
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	i := append(fillBlocks(salt, v), fillBlocks(password, v)...)
+
+	result := make([]byte, 0, size+u)
+	for len(result) < size {
+		// #nosec
+		a := sha1.Sum(append(append([]byte{}, d...), i...))
+		for j := 1; j < iterations; j++ {
+			// #nosec
+			a = sha1.Sum(a[:])
+		}
+		result = append(result, a[:]...)
+
+		if len(result) >= size {
+			break
+		}
+
+		// Every block of I is replaced by (I + B + 1) mod 2^(v*8),
+		// where B is A repeated up to the size of the block
+		b := fillBlocks(a[:], v)
+		for start := 0; start < len(i); start += v {
+			block := i[start : start+v]
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(block[k]) + int(b[k]) + carry
+				block[k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+
+	return result[:size]
+}
+
+// fillBlocks repeats the given value up to a multiple of the block size
+func fillBlocks(value []byte, size int) []byte {
+	if len(value) == 0 {
+		return nil
+	}
+
+	n := size * ((len(value) + size - 1) / size)
+	result := make([]byte, n)
+	for i := range result {
+		result[i] = value[i%len(value)]
+	}
+
+	return result
+}
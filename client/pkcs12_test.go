@@ -0,0 +1,155 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type PKCS12Suite struct {
+	certDER []byte
+	key     *ecdsa.PrivateKey
+}
+
+var _ = Suite(&PKCS12Suite{})
+
+func (s *PKCS12Suite) SetUpSuite(c *C) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Wahay"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	s.certDER, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	c.Assert(err, IsNil)
+	s.key = key
+}
+
+func unhex(c *C, s string) []byte {
+	result, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return result
+}
+
+// dataContent returns the content of a content info of type data
+func dataContent(c *C, ci pkcs12ContentInfo) []byte {
+	c.Assert(ci.ContentType.Equal(oidDataContentType), Equals, true)
+
+	var octets []byte
+	rest, err := asn1.Unmarshal(ci.Content.Bytes, &octets)
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+
+	return octets
+}
+
+func localKeyID(c *C, bag pkcs12SafeBag) []byte {
+	c.Assert(bag.Attributes, HasLen, 1)
+	c.Assert(bag.Attributes[0].ID.Equal(oidLocalKeyID), Equals, true)
+
+	var id []byte
+	_, err := asn1.Unmarshal(bag.Attributes[0].Value.Bytes, &id)
+	c.Assert(err, IsNil)
+
+	return id
+}
+
+func (s *PKCS12Suite) Test_pkcs12DeriveKey_givesTheSameKeysAsOpenSSL(c *C) {
+	// "smeg" as a BMPString with its null terminator
+	password := unhex(c, "0073006d006500670000")
+	c.Assert(pkcs12DeriveKey(password, unhex(c, "0a58cf64530d823f"), 1, 1, 24), DeepEquals,
+		unhex(c, "8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3"))
+
+	c.Assert(pkcs12DeriveKey([]byte{0, 0}, unhex(c, "0102030405060708"), pkcs12MacKeyID, 2048, 40), DeepEquals,
+		unhex(c, "422271a0229a8e68e59672d9278fe548eb74b0135a845d1641ed2763f3bb6d06c87669c91c90d086"))
+}
+
+func (s *PKCS12Suite) Test_encodePKCS12_hasTheCertificateAndTheKeyWithAValidMAC(c *C) {
+	data, err := encodePKCS12(s.certDER, s.key)
+	c.Assert(err, IsNil)
+
+	var pfx pkcs12PFX
+	rest, err := asn1.Unmarshal(data, &pfx)
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Assert(pfx.Version, Equals, 3)
+
+	authenticatedSafe := dataContent(c, pfx.AuthSafe)
+
+	mac := pfx.MacData
+	c.Assert(mac.Mac.Algorithm.Algorithm.Equal(oidSHA1), Equals, true)
+	c.Assert(mac.MacSalt, HasLen, 8)
+	c.Assert(mac.Iterations, Equals, 2048)
+
+	h := hmac.New(sha1.New, pkcs12DeriveKey([]byte{0, 0}, mac.MacSalt, pkcs12MacKeyID, mac.Iterations, sha1.Size))
+	_, _ = h.Write(authenticatedSafe)
+	c.Assert(mac.Mac.Digest, DeepEquals, h.Sum(nil))
+
+	var contents []pkcs12ContentInfo
+	_, err = asn1.Unmarshal(authenticatedSafe, &contents)
+	c.Assert(err, IsNil)
+	c.Assert(contents, HasLen, 1)
+
+	var bags []pkcs12SafeBag
+	_, err = asn1.Unmarshal(dataContent(c, contents[0]), &bags)
+	c.Assert(err, IsNil)
+	c.Assert(bags, HasLen, 2)
+
+	c.Assert(bags[0].ID.Equal(oidCertBag), Equals, true)
+	var cert pkcs12CertBag
+	_, err = asn1.Unmarshal(bags[0].Value.Bytes, &cert)
+	c.Assert(err, IsNil)
+	c.Assert(cert.ID.Equal(oidCertTypeX509), Equals, true)
+	c.Assert(cert.Data, DeepEquals, s.certDER)
+
+	c.Assert(bags[1].ID.Equal(oidKeyBag), Equals, true)
+	key, err := x509.ParsePKCS8PrivateKey(bags[1].Value.Bytes)
+	c.Assert(err, IsNil)
+	c.Assert(key.(*ecdsa.PrivateKey).D.Cmp(s.key.D), Equals, 0)
+
+	id := sha1.Sum(s.certDER)
+	c.Assert(localKeyID(c, bags[0]), DeepEquals, id[:])
+	c.Assert(localKeyID(c, bags[1]), DeepEquals, id[:])
+}
+
+func (s *PKCS12Suite) Test_encodePKCS12_isReadByOpenSSL(c *C) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		c.Skip("openssl is not installed")
+	}
+
+	data, err := encodePKCS12(s.certDER, s.key)
+	c.Assert(err, IsNil)
+
+	dir, err := ioutil.TempDir("", "wahay-pkcs12")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "identity.p12")
+	c.Assert(ioutil.WriteFile(fileName, data, 0600), IsNil)
+
+	// OpenSSL fails when the MAC is not valid
+	output, err := exec.Command(openssl, "pkcs12", "-in", fileName, "-passin", "pass:", "-nodes").CombinedOutput()
+	c.Assert(err, IsNil, Commentf("%s", output))
+	c.Assert(bytes.Contains(output, []byte("BEGIN CERTIFICATE")), Equals, true)
+	c.Assert(bytes.Contains(output, []byte("BEGIN PRIVATE KEY")), Equals, true)
+}
@@ -0,0 +1,60 @@
+//go:build darwin
+// +build darwin
+
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/digitalautonomy/wahay/config"
+)
+
+const (
+	mumbleExecutable = "mumble"
+
+	// configNextToBinary is false since the application bundle of Mumble is
+	// signed and can't be copied, and macOS ignores the LD_PRELOAD of
+	// torsocks. The configuration is given to it with --config, and it
+	// connects through the SOCKS proxy of Tor
+	configNextToBinary = false
+
+	// audioSystem is the section of mumble.ini with the audio devices
+	audioSystem = "coreaudio"
+
+	// audioSystemName is how Mumble calls the audio system of the
+	// devices, to use it for the input and the output
+	audioSystemName = "CoreAudio"
+
+	mumbleAppBundle = "Mumble.app"
+)
+
+func platformBinaryEnv() []string {
+	return nil
+}
+
+func libsDirEnv(libsDir string) []string {
+	return []string{fmt.Sprintf("DYLD_LIBRARY_PATH=%s", libsDir)}
+}
+
+// searchBinaryInstalled finds the application bundle of Mumble in the
+// Applications directory of the system or in the one of the user
+func searchBinaryInstalled() (*binary, error) {
+	places := []string{
+		filepath.Join("/Applications", mumbleAppBundle),
+		filepath.Join(config.WithHome("Applications"), mumbleAppBundle),
+	}
+
+	for _, p := range places {
+		if !pathExists(p) {
+			continue
+		}
+
+		b := isThereAnAvailableBinary(p)
+		if b != nil && b.isValid {
+			return b, nil
+		}
+	}
+
+	return nil, nil
+}
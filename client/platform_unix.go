@@ -0,0 +1,41 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package client
+
+import "fmt"
+
+const (
+	mumbleExecutable = "mumble"
+
+	// configNextToBinary is true when Mumble reads the configuration
+	// placed next to its binary, which is copied to a temporary
+	// directory for that. It's then run through torsocks
+	configNextToBinary = true
+
+	// audioSystem is the section of mumble.ini with the audio devices
+	audioSystem = "pulseaudio"
+
+	// audioSystemName is how Mumble calls the audio system of the
+	// devices, to use it for the input and the output
+	audioSystemName = "PulseAudio"
+)
+
+func platformBinaryEnv() []string {
+	// This is a temporary fix for making sure that
+	// Mumble doesn't run under Wayland.
+	// Once the torsocks problem with Wayland has been
+	// fixed, we can make this conditional on the version
+	// of torsocks
+	return []string{"QT_QPA_PLATFORM=xcb"}
+}
+
+func libsDirEnv(libsDir string) []string {
+	return []string{fmt.Sprintf("LD_LIBRARY_PATH=%s", libsDir)}
+}
+
+// searchBinaryInstalled finds nothing, since Mumble is
+// installed in the PATH or sandboxed in these systems
+func searchBinaryInstalled() (*binary, error) {
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+//go:build windows
+// +build windows
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mumbleExecutable = "mumble.exe"
+
+	// configNextToBinary is false since the Mumble of Windows needs the
+	// libraries installed with it, so it's not copied. The configuration
+	// is given to it with --config, and without torsocks it connects
+	// through the SOCKS proxy of Tor
+	configNextToBinary = false
+
+	// audioSystem is the section of mumble.ini with the audio devices
+	audioSystem = "wasapi"
+
+	// audioSystemName is how Mumble calls the audio system of the
+	// devices, to use it for the input and the output
+	audioSystemName = "WASAPI"
+
+	// mumbleAppPathsKey is where the installer of Mumble registers it
+	mumbleAppPathsKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\App Paths\` + mumbleExecutable
+)
+
+// mumbleProgramFilesPaths are the places of Mumble inside of
+// the Program Files directory, for the newer and older installers
+var mumbleProgramFilesPaths = []string{
+	`Mumble\client\` + mumbleExecutable,
+	`Mumble\` + mumbleExecutable,
+}
+
+func platformBinaryEnv() []string {
+	return nil
+}
+
+func libsDirEnv(libsDir string) []string {
+	return []string{fmt.Sprintf("PATH=%s%c%s", libsDir, os.PathListSeparator, os.Getenv("PATH"))}
+}
+
+// searchBinaryInstalled finds Mumble where its installer says, and in
+// the Program Files directories when it's not registered
+func searchBinaryInstalled() (*binary, error) {
+	places := []string{}
+
+	for _, root := range []syscall.Handle{syscall.HKEY_CURRENT_USER, syscall.HKEY_LOCAL_MACHINE} {
+		if path, ok := registryDefaultValue(root, mumbleAppPathsKey); ok {
+			places = append(places, strings.Trim(path, `"`))
+		}
+	}
+
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+		dir := os.Getenv(env)
+		if dir == "" {
+			continue
+		}
+		for _, p := range mumbleProgramFilesPaths {
+			places = append(places, filepath.Join(dir, p))
+		}
+	}
+
+	for _, p := range places {
+		if !pathExists(p) {
+			continue
+		}
+
+		b := isThereAnAvailableBinary(p)
+		if b != nil && b.isValid {
+			return b, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func registryDefaultValue(root syscall.Handle, path string) (string, bool) {
+	subkey, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", false
+	}
+
+	var key syscall.Handle
+	err = syscall.RegOpenKeyEx(root, subkey, 0, syscall.KEY_READ, &key)
+	if err != nil {
+		return "", false
+	}
+	defer func() {
+		_ = syscall.RegCloseKey(key)
+	}()
+
+	var kind, size uint32
+	err = syscall.RegQueryValueEx(key, nil, nil, &kind, nil, &size)
+	if err != nil || kind != syscall.REG_SZ || size == 0 {
+		return "", false
+	}
+
+	buf := make([]uint16, size/2+1)
+	err = syscall.RegQueryValueEx(key, nil, nil, &kind, (*byte)(unsafe.Pointer(&buf[0])), &size)
+	if err != nil {
+		return "", false
+	}
+
+	return syscall.UTF16ToString(buf), true
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package client
+
+import (
+	"os"
+	"syscall"
+)
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package client
+
+import "os"
+
+func processRunning(pid int) bool {
+	// FindProcess opens the process on Windows, which fails when it's not running
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	_ = p.Release()
+	return true
+}
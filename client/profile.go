@@ -0,0 +1,187 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/config"
+)
+
+const (
+	// profileDirPrefix names the directories of the isolated profiles,
+	// so the ones left behind when Wahay doesn't close normally are found
+	profileDirPrefix = "wahay-mumble-profile"
+
+	// profileOwnerFile has the PID of the Wahay that uses the profile
+	profileOwnerFile = "wahay.pid"
+
+	// newProfileTime is how long a profile without owner is kept,
+	// since its owner might be writing it
+	newProfileTime = time.Minute
+)
+
+// profile is the Mumble configuration of a single meeting. It lives in
+// its own temporary directory, with a copy of the binary since Mumble
+// only reads a configuration placed next to it, and it's wiped when the
+// meeting is closed so nothing about the meeting is kept on disk
+type profile struct {
+	dir string
+
+	// The binary and configuration directory shared by all the meetings,
+	// used again once the meeting is closed
+	sharedBinary     *binary
+	sharedConfigDir  string
+	sharedConfigFile string
+}
+
+func (c *client) SetIsolatedProfile(v bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.isolatedProfile = v
+}
+
+// startProfile moves the client to a new isolated profile, when
+// they are enabled. It does nothing if one was already started
+func (c *client) startProfile() error {
+	c.Lock()
+	enabled := c.isolatedProfile && c.profile == nil
+	c.Unlock()
+
+	if !enabled {
+		return nil
+	}
+
+	dir, err := newProfileDir(c.binary.sandbox)
+	if err != nil {
+		return err
+	}
+
+	b := *c.binary
+	b.isTemporary = false
+
+	// When Mumble can't read it next to the binary, it gets the profile
+	// with --config instead
+	if b.readsConfigNextToIt() {
+		destination := filepath.Join(dir, mumbleExecutable)
+		err = b.copyBinaryToDir(destination)
+		if err != nil {
+			wipeProfileDir(dir)
+			return errInvalidBinaryFile.Wrap(err)
+		}
+		b.path = destination
+	}
+
+	// The profile is also wiped if Wahay is closed during the meeting
+	config.TrackSensitiveFile(dir, false)
+
+	p := &profile{
+		dir:              dir,
+		sharedBinary:     c.binary,
+		sharedConfigDir:  c.configDir,
+		sharedConfigFile: c.configFile,
+	}
+
+	c.binary = &b
+	c.configDir = dir
+	c.configFile = filepath.Join(dir, configFileName)
+	c.profile = p
+
+	err = c.ensureConfiguration()
+	if err != nil {
+		c.closeProfile()
+		return err
+	}
+
+	log.Debugf("Using an isolated Mumble profile at: %s", dir)
+
+	return nil
+}
+
+// closeProfile wipes the isolated profile of the meeting and
+// goes back to the configuration shared by all the meetings
+func (c *client) closeProfile() {
+	c.Lock()
+	p := c.profile
+	c.profile = nil
+	c.Unlock()
+
+	if p == nil {
+		return
+	}
+
+	c.binary = p.sharedBinary
+	c.configDir = p.sharedConfigDir
+	c.configFile = p.sharedConfigFile
+
+	wipeProfileDir(p.dir)
+}
+
+func wipeProfileDir(dir string) {
+	err := config.SecureRemove(dir)
+	if err != nil {
+		log.Errorf("The Mumble profile at %s can't be removed: %s", dir, err)
+	}
+}
+
+func newProfileDir(s *sandbox) (string, error) {
+	var dir string
+	var err error
+
+	if s != nil {
+		dir, err = s.tempDir(profileDirPrefix)
+	} else {
+		dir, err = ioutil.TempDir("", profileDirPrefix)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pid := strconv.Itoa(os.Getpid())
+	err = ioutil.WriteFile(filepath.Join(dir, profileOwnerFile), []byte(pid), 0600)
+	if err != nil {
+		wipeProfileDir(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// wipeLeftoverProfiles wipes the isolated profiles that a Wahay which
+// is not running anymore didn't wipe, because it crashed or was killed
+// during a meeting. The profiles of the running ones are kept
+func wipeLeftoverProfiles() {
+	dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), profileDirPrefix+"*"))
+	snapDirs, _ := filepath.Glob(filepath.Join(config.WithHome(snapMumbleData), profileDirPrefix+"*"))
+	dirs = append(dirs, snapDirs...)
+
+	for _, dir := range dirs {
+		if profileInUse(dir) {
+			continue
+		}
+
+		log.Infof("Wiping the Mumble profile left at: %s", dir)
+		wipeProfileDir(dir)
+	}
+}
+
+func profileInUse(dir string) bool {
+	content, err := ioutil.ReadFile(filepath.Clean(filepath.Join(dir, profileOwnerFile)))
+	if err != nil {
+		info, err := os.Stat(dir)
+		return err == nil && time.Since(info.ModTime()) < newProfileTime
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+
+	return pid == os.Getpid() || processRunning(pid)
+}
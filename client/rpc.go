@@ -0,0 +1,35 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+var errMumbleRemoteControl = failure.New("client.remote-control", failure.CategoryMumble, "the running Mumble client can't be controlled", "make sure you are in a meeting")
+
+// SetMuted mutes or unmutes the microphone of the running Mumble client,
+// using the remote control commands Mumble accepts from a new process
+func (c *client) SetMuted(muted bool) error {
+	if !c.IsValid() {
+		return ErrMumbleNotAvailable
+	}
+
+	action := "unmute"
+	if muted {
+		action = "mute"
+	}
+
+	// This executes the Mumble command, which is under control of the code
+	/* #nosec G204 */
+	command := exec.Command(c.pathToBinary(), "rpc", action)
+	command.Env = append(os.Environ(), c.binaryEnv()...)
+
+	err := command.Run()
+	if err != nil {
+		return errMumbleRemoteControl.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/config"
+)
+
+// Many distributions only ship Mumble as a Flatpak or a Snap. A sandboxed
+// Mumble can't be copied, and it can't read a configuration placed next to
+// its binary or in our temporary directory, so the configuration is written
+// in a directory the sandbox can read and given to Mumble with --config.
+// The LD_PRELOAD of torsocks doesn't reach inside the sandbox either, so
+// Mumble is configured to connect through the SOCKS proxy of Tor, like
+// on Windows
+
+type sandboxKind string
+
+const (
+	sandboxFlatpak sandboxKind = "flatpak"
+	sandboxSnap    sandboxKind = "snap"
+
+	flatpakMumbleApp = "info.mumble.Mumble"
+	snapMumbleBinary = "/snap/bin/mumble"
+
+	// snapMumbleData is where the Mumble snap can read files, relative
+	// to the home directory. The snaps have their own temporary directory
+	snapMumbleData = "snap/mumble/current"
+)
+
+// sandbox is a Mumble installed with Flatpak or Snap
+type sandbox struct {
+	kind sandboxKind
+}
+
+func newSandboxedBinary(path string, kind sandboxKind) *binary {
+	return &binary{
+		path:    path,
+		isValid: true,
+		env:     []string{},
+		sandbox: &sandbox{kind: kind},
+	}
+}
+
+// searchBinaryInFlatpak finds the Mumble Flatpak, installed
+// for the user or for the whole system
+func searchBinaryInFlatpak() (*binary, error) {
+	path, err := exec.LookPath("flatpak")
+	if err != nil {
+		return nil, nil
+	}
+
+	// This executes the flatpak command, which is under control of the code
+	/* #nosec G204 */
+	err = exec.Command(path, "info", flatpakMumbleApp).Run()
+	if err != nil {
+		return nil, nil
+	}
+
+	return newSandboxedBinary(path, sandboxFlatpak), nil
+}
+
+func searchBinaryInSnap() (*binary, error) {
+	if !pathExists(snapMumbleBinary) {
+		return nil, nil
+	}
+
+	return newSandboxedBinary(snapMumbleBinary, sandboxSnap), nil
+}
+
+// sandboxedBinaryAt returns the sandboxed Mumble run by the given command,
+// when it's one of them, for example when it's set in the settings
+func sandboxedBinaryAt(path string) *binary {
+	switch {
+	case strings.HasPrefix(path, filepath.Dir(snapMumbleBinary)+"/"):
+		return newSandboxedBinary(path, sandboxSnap)
+	case filepath.Base(path) == flatpakMumbleApp:
+		// The command exported by Flatpak can't receive the arguments
+		// for the sandbox, so flatpak is run instead
+		b, _ := searchBinaryInFlatpak()
+		return b
+	}
+	return nil
+}
+
+// runArgs returns the arguments for running Mumble in the sandbox,
+// with the given environment and access to the given directory
+func (s *sandbox) runArgs(args, env []string, dir string) []string {
+	if s.kind != sandboxFlatpak {
+		return args
+	}
+
+	result := []string{"run"}
+	for _, e := range env {
+		result = append(result, "--env="+e)
+	}
+	if dir != "" {
+		result = append(result, "--filesystem="+dir)
+	}
+	result = append(result, flatpakMumbleApp)
+
+	return append(result, args...)
+}
+
+// tempDir creates a directory the sandbox can read
+func (s *sandbox) tempDir(prefix string) (string, error) {
+	base := s.baseDir()
+	if base != os.TempDir() {
+		err := createDir(base)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return ioutil.TempDir(base, prefix)
+}
+
+func (s *sandbox) baseDir() string {
+	if s.kind == sandboxSnap {
+		return config.WithHome(snapMumbleData)
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/config"
+)
+
+const jsonSettingsFileName = "mumble_settings.json"
+
+// jsonSettingKeys maps the values Wahay writes in mumble.ini to the
+// keys Mumble 1.5 and newer use in mumble_settings.json. The values
+// of mumble.ini not in the list keep their default in those versions
+var jsonSettingKeys = []struct {
+	section, key, json string
+}{
+	{"net", "tcponly", "tcp_mode"},
+	{"net", "proxytype", "proxy_type"},
+	{"net", "proxyhost", "proxy_host"},
+	{"net", "proxyport", "proxy_port"},
+	{"General", "databaselocation", "database_location"},
+	{"net", "certificate", "certificate"},
+	{"overlay", "enable", "overlay_enable"},
+	{"privacy", "hideos", "hide_os_from_others"},
+	{"audio", "input", "input_system"},
+	{"audio", "output", "output_system"},
+	{"audio", "quality", "audio_quality"},
+	{"audio", "transmit", "transmit_mode"},
+	{"audio", "frames", "frames_per_packet"},
+	{"audio", "jitterbuffer", "jitter_buffer_size"},
+	{"tts", "enable", "tts_enabled"},
+	{"ui", "askonquit", "ask_on_quit"},
+	{"ui", "language", "language"},
+}
+
+// iniValues returns the values of the ini content by section and key
+func iniValues(content string) map[string]map[string]string {
+	result := map[string]map[string]string{}
+	section := ""
+
+	for _, l := range strings.Split(content, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]") {
+			section = l[1 : len(l)-1]
+			continue
+		}
+
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		if result[section] == nil {
+			result[section] = map[string]string{}
+		}
+		result[section][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return result
+}
+
+// jsonSettingValue converts a value of mumble.ini to the type used in the
+// JSON settings. Byte arrays, like the certificate, are encoded in base64
+func jsonSettingValue(v string) interface{} {
+	if len(v) > 1 && strings.HasPrefix(v, "\"") && strings.HasSuffix(v, "\"") {
+		v = v[1 : len(v)-1]
+	}
+
+	if data, ok := byteArrayParse(v); ok {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+
+	if v == "true" || v == "false" {
+		return v == "true"
+	}
+
+	if n, err := strconv.Atoi(v); err == nil {
+		return n
+	}
+
+	return v
+}
+
+func jsonSettings(ini string) ([]byte, error) {
+	values := iniValues(ini)
+	settings := map[string]interface{}{}
+
+	for _, k := range jsonSettingKeys {
+		if v, ok := values[k.section][k.key]; ok {
+			settings[k.json] = jsonSettingValue(v)
+		}
+	}
+
+	return json.MarshalIndent(settings, "", "    ")
+}
+
+// writeSettingsForVersion writes the configuration in the format the
+// located Mumble reads. mumble.ini is always kept, since it also tells
+// Mumble to use the configuration in its own directory
+func (c *client) writeSettingsForVersion() error {
+	if c.binary == nil || !c.binary.mumbleVersion().usesJSONSettings() || !pathExists(c.configFile) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(c.configFile)
+	if err != nil {
+		return err
+	}
+
+	settings, err := jsonSettings(string(content))
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(filepath.Dir(c.configFile), jsonSettingsFileName)
+	config.TrackSensitiveFile(filename, c.hasPersistentIdentity())
+
+	return config.SafeWrite(filename, settings, 0600)
+}
+
+func (c *client) removeJSONSettings() {
+	filename := filepath.Join(c.pathToConfig(), jsonSettingsFileName)
+	if pathExists(filename) {
+		_ = os.Remove(filename)
+	}
+}
+
+// byteArrayParse reverts byteArrayUnparse, returning the bytes of
+// a value in the @ByteArray format of the Mumble configuration
+func byteArrayParse(s string) ([]byte, bool) {
+	if !strings.HasPrefix(s, byteArrayPrefix) || !strings.HasSuffix(s, byteArraySuffix) {
+		return nil, false
+	}
+
+	s = s[len(byteArrayPrefix) : len(s)-len(byteArraySuffix)]
+	result := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			result = append(result, s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && byteArrayIsHex(s[j]) {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return nil, false
+			}
+			result = append(result, byte(n))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return nil, false
+			}
+			result = append(result, byte(n))
+			i = j - 1
+		case 't':
+			result = append(result, '\t')
+		case 'r':
+			result = append(result, '\r')
+		case 'a':
+			result = append(result, '\a')
+		case 'b':
+			result = append(result, '\b')
+		case 'v':
+			result = append(result, '\v')
+		case 'f':
+			result = append(result, '\f')
+		case 'n':
+			result = append(result, '\n')
+		default:
+			result = append(result, s[i])
+		}
+	}
+
+	return result, true
+}
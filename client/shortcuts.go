@@ -0,0 +1,139 @@
+package client
+
+import (
+	bin "encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// PushToTalkKeys are the names of the keys that can be used to talk,
+// in the order they are offered to the user. The first one is the
+// key Wahay uses by default
+var PushToTalkKeys = []string{
+	"right-ctrl",
+	"left-ctrl",
+	"right-alt",
+	"left-alt",
+	"right-shift",
+	"left-shift",
+	"caps-lock",
+	"scroll-lock",
+	"pause",
+	"insert",
+	"menu",
+	"f1", "f2", "f3", "f4", "f5", "f6",
+	"f7", "f8", "f9", "f10", "f11", "f12",
+}
+
+// pushToTalkEvdevCodes are the Linux input event codes of the keys,
+// the ones Wayland compositors and libinput report
+var pushToTalkEvdevCodes = map[string]uint32{
+	"left-ctrl":   29,
+	"left-shift":  42,
+	"right-shift": 54,
+	"left-alt":    56,
+	"caps-lock":   58,
+	"f1":          59,
+	"f2":          60,
+	"f3":          61,
+	"f4":          62,
+	"f5":          63,
+	"f6":          64,
+	"f7":          65,
+	"f8":          66,
+	"f9":          67,
+	"f10":         68,
+	"scroll-lock": 70,
+	"f11":         87,
+	"f12":         88,
+	"right-ctrl":  97,
+	"right-alt":   100,
+	"insert":      110,
+	"pause":       119,
+	"menu":        127,
+}
+
+const (
+	// The prefixes of the push to talk keys given by their key code,
+	// as xev reports them in X11 or libinput in Wayland
+	x11KeyCodePrefix   = "x11:"
+	evdevKeyCodePrefix = "evdev:"
+
+	// x11KeyCodeOffset is the difference between the X11 key codes
+	// and the Linux input event codes of the same keys
+	x11KeyCodeOffset = 8
+
+	// pushToTalkShortcutIndex is the index of the push to
+	// talk action in the global shortcuts of Mumble
+	pushToTalkShortcutIndex = 1
+)
+
+// x11KeyCode translates a Linux input event code to the X11 key code.
+// Mumble keeps X11 key codes in its shortcuts, also when its evdev
+// backend is used under Wayland
+func x11KeyCode(evdev uint32) uint32 {
+	return evdev + x11KeyCodeOffset
+}
+
+// pushToTalkKeyCode returns the X11 key code of the key, given by its
+// name or by a key code with the x11: or evdev: prefixes
+func pushToTalkKeyCode(key string) (uint32, bool) {
+	if code, ok := pushToTalkEvdevCodes[key]; ok {
+		return x11KeyCode(code), true
+	}
+
+	parse := func(prefix string) (uint32, bool) {
+		n, err := strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 16)
+		return uint32(n), err == nil && n > 0
+	}
+
+	switch {
+	case strings.HasPrefix(key, x11KeyCodePrefix):
+		code, ok := parse(x11KeyCodePrefix)
+		return code, ok && code >= x11KeyCodeOffset
+	case strings.HasPrefix(key, evdevKeyCodePrefix):
+		code, ok := parse(evdevKeyCodePrefix)
+		return x11KeyCode(code), ok
+	}
+
+	return 0, false
+}
+
+// IsValidPushToTalkKey returns true if the key can be used to talk
+func IsValidPushToTalkKey(key string) bool {
+	_, ok := pushToTalkKeyCode(key)
+	return ok
+}
+
+// pushToTalkShortcut returns the values of the shortcuts section of
+// the Mumble configuration that bind the key to push to talk
+func pushToTalkShortcut(key string) ([][2]string, bool) {
+	code, ok := pushToTalkKeyCode(key)
+	if !ok {
+		return nil, false
+	}
+
+	prefix := strconv.Itoa(pushToTalkShortcutIndex) + "\\"
+
+	return [][2]string{
+		{prefix + "data", "@Invalid()"},
+		{prefix + "index", strconv.Itoa(pushToTalkShortcutIndex)},
+		{prefix + "keys", shortcutKeysVariant(code)},
+		{prefix + "suppress", "false"},
+		{"size", "1"},
+	}, true
+}
+
+// shortcutKeysVariant returns the list of keys of a shortcut, as the
+// QVariant Mumble writes in its configuration
+func shortcutKeysVariant(code uint32) string {
+	const qVariantList, qVariantInt = 9, 2
+
+	data := make([]byte, 16)
+	bin.BigEndian.PutUint32(data[0:], qVariantList)
+	bin.BigEndian.PutUint32(data[4:], 1)
+	bin.BigEndian.PutUint32(data[8:], qVariantInt)
+	bin.BigEndian.PutUint32(data[12:], code)
+
+	return "@Variant(" + strings.TrimPrefix(byteArrayUnparse(data), byteArrayPrefix)
+}
@@ -0,0 +1,1292 @@
+package client
+
+import (
+	"bytes"
+	bin "encoding/binary"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// This is a small implementation of the SQLite file format, enough
+// for reading and rewriting the tables of the Mumble database without
+// linking a complete SQLite library. Only what Mumble uses is
+// supported: UTF-8 text, no auto vacuum, and tables that can be
+// written in a b-tree of at most two levels.
+//
+// Databases in WAL mode can only be used when all their changes are
+// in the main file, which is what SQLite leaves when the last
+// connection is closed. The file is then rewritten as it is, and
+// SQLite keeps using it in WAL mode. It's up to the caller to check
+// that there is no write-ahead log with changes next to it.
+//
+// The format is described in https://www.sqlite.org/fileformat2.html
+
+const (
+	sqliteHeaderSize = 100
+	sqliteMagic      = "SQLite format 3\x00"
+	sqliteMaxDepth   = 20
+
+	sqliteIndexInterior byte = 0x02
+	sqliteTableInterior byte = 0x05
+	sqliteIndexLeaf     byte = 0x0a
+	sqliteTableLeaf     byte = 0x0d
+
+	sqliteSequenceTable = "sqlite_sequence"
+)
+
+var (
+	errInvalidSQLite     = failure.New("client.invalid-mumble-database", failure.CategoryMumble, "the Mumble database is not valid", "")
+	errUnsupportedSQLite = failure.New("client.unsupported-mumble-database", failure.CategoryMumble, "the Mumble database uses features that are not supported", "")
+	errSQLiteTooLarge    = failure.New("client.mumble-database-too-large", failure.CategoryMumble, "the content doesn't fit in the Mumble database", "")
+	errSQLiteNoTable     = failure.New("client.mumble-database-no-table", failure.CategoryMumble, "the table doesn't exist in the Mumble database", "")
+	errSQLiteConstraint  = failure.New("client.mumble-database-constraint", failure.CategoryMumble, "the row already exists in the Mumble database", "")
+)
+
+type sqliteDB struct {
+	content  []byte
+	pageSize int
+	usable   int
+	schema   map[string]*sqliteObject
+}
+
+// sqliteObject is a table or an index of the schema. For indexes
+// the columns are the names of the indexed columns of the table
+type sqliteObject struct {
+	kind          string
+	name          string
+	table         string
+	root          int
+	columns       []string
+	rowidColumn   int
+	autoincrement bool
+	unique        bool
+	supported     bool
+}
+
+type sqliteRow struct {
+	rowid  int64
+	values []interface{}
+}
+
+func parseSQLite(content []byte) (*sqliteDB, error) {
+	if len(content) < sqliteHeaderSize || string(content[:len(sqliteMagic)]) != sqliteMagic {
+		return nil, errInvalidSQLite
+	}
+
+	pageSize := int(bin.BigEndian.Uint16(content[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	if pageSize < 512 || len(content)%pageSize != 0 {
+		return nil, errInvalidSQLite
+	}
+
+	// Auto vacuum and any encoding different from UTF-8 would need
+	// much more than what is implemented here. The versions are 1
+	// for rollback journals and 2 for WAL mode
+	writeVersion, readVersion := content[18], content[19]
+	autoVacuum := bin.BigEndian.Uint32(content[52:])
+	encoding := bin.BigEndian.Uint32(content[56:])
+	if writeVersion < 1 || writeVersion > 2 || readVersion != writeVersion || autoVacuum != 0 || (encoding != 1 && encoding != 0) {
+		return nil, errUnsupportedSQLite
+	}
+
+	db := &sqliteDB{
+		content:  append([]byte{}, content...),
+		pageSize: pageSize,
+		usable:   pageSize - int(content[20]),
+		schema:   make(map[string]*sqliteObject),
+	}
+
+	err := db.readSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *sqliteDB) bytes() []byte {
+	return db.content
+}
+
+func (db *sqliteDB) pageCount() int {
+	return len(db.content) / db.pageSize
+}
+
+func (db *sqliteDB) page(n int) []byte {
+	return db.content[(n-1)*db.pageSize : n*db.pageSize]
+}
+
+func pageHeaderOffset(n int) int {
+	if n == 1 {
+		return sqliteHeaderSize
+	}
+	return 0
+}
+
+func (db *sqliteDB) readSchema() error {
+	var entries [][]interface{}
+	err := db.walk(1, 0, nil, func(_ int64, payload []byte) error {
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, values)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if len(e) < 5 {
+			return errInvalidSQLite
+		}
+
+		kind, _ := e[0].(string)
+		name, _ := e[1].(string)
+		table, _ := e[2].(string)
+		root, _ := e[3].(int64)
+		sql, _ := e[4].(string)
+
+		if kind != "table" && kind != "index" {
+			continue
+		}
+
+		o := &sqliteObject{
+			kind:        kind,
+			name:        name,
+			table:       table,
+			root:        int(root),
+			rowidColumn: -1,
+		}
+
+		if kind == "table" {
+			o.columns, o.rowidColumn = parseTableColumns(sql)
+			o.autoincrement = strings.Contains(strings.ToUpper(sql), "AUTOINCREMENT")
+			o.supported = o.columns != nil
+		} else {
+			o.columns, o.supported = parseIndexColumns(sql)
+			o.unique = strings.HasPrefix(strings.ToUpper(sql), "CREATE UNIQUE")
+		}
+
+		db.schema[name] = o
+	}
+
+	return nil
+}
+
+// walk visits the entries of the b-tree in the given page, in order.
+// All the pages used by the b-tree are added to pages
+func (db *sqliteDB) walk(n, depth int, pages *[]int, visit func(rowid int64, payload []byte) error) error {
+	if n < 1 || n > db.pageCount() || depth > sqliteMaxDepth {
+		return errInvalidSQLite
+	}
+
+	if pages != nil {
+		*pages = append(*pages, n)
+	}
+
+	p := db.page(n)
+	h := pageHeaderOffset(n)
+	kind := p[h]
+	interior := kind == sqliteIndexInterior || kind == sqliteTableInterior
+
+	headerSize := 8
+	if interior {
+		headerSize = 12
+	}
+
+	cells := int(bin.BigEndian.Uint16(p[h+3:]))
+	if h+headerSize+2*cells > db.usable {
+		return errInvalidSQLite
+	}
+
+	for i := 0; i < cells; i++ {
+		offset := int(bin.BigEndian.Uint16(p[h+headerSize+2*i:]))
+		if offset >= db.usable {
+			return errInvalidSQLite
+		}
+
+		err := db.visitCell(kind, p[offset:db.usable], depth, pages, visit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if interior {
+		return db.walk(int(bin.BigEndian.Uint32(p[h+8:])), depth+1, pages, visit)
+	}
+
+	return nil
+}
+
+func (db *sqliteDB) visitCell(kind byte, cell []byte, depth int, pages *[]int, visit func(int64, []byte) error) error {
+	if kind == sqliteTableInterior || kind == sqliteIndexInterior {
+		if len(cell) < 4 {
+			return errInvalidSQLite
+		}
+
+		err := db.walk(int(bin.BigEndian.Uint32(cell)), depth+1, pages, visit)
+		if err != nil || kind == sqliteTableInterior {
+			return err
+		}
+
+		cell = cell[4:]
+	}
+
+	size, n := getVarint(cell)
+	if n == 0 {
+		return errInvalidSQLite
+	}
+	cell = cell[n:]
+
+	var rowid uint64
+	switch kind {
+	case sqliteTableLeaf:
+		rowid, n = getVarint(cell)
+		if n == 0 {
+			return errInvalidSQLite
+		}
+		cell = cell[n:]
+	case sqliteIndexLeaf, sqliteIndexInterior:
+	default:
+		return errInvalidSQLite
+	}
+
+	payload, err := db.payload(cell, size, kind != sqliteTableLeaf, pages)
+	if err != nil {
+		return err
+	}
+
+	return visit(int64(rowid), payload)
+}
+
+func (db *sqliteDB) maxLocal(index bool) int {
+	if index {
+		return (db.usable-12)*64/255 - 23
+	}
+	return db.usable - 35
+}
+
+// payload returns the content of a cell, following its
+// overflow pages when it's too big for a single page
+func (db *sqliteDB) payload(local []byte, size uint64, index bool, pages *[]int) ([]byte, error) {
+	if size > uint64(len(db.content)) {
+		return nil, errInvalidSQLite
+	}
+
+	total := int(size)
+	x := db.maxLocal(index)
+	if total <= x {
+		if len(local) < total {
+			return nil, errInvalidSQLite
+		}
+		return local[:total], nil
+	}
+
+	m := (db.usable-12)*32/255 - 23
+	l := m + (total-m)%(db.usable-4)
+	if l > x {
+		l = m
+	}
+
+	if len(local) < l+4 {
+		return nil, errInvalidSQLite
+	}
+
+	result := append([]byte{}, local[:l]...)
+	next := int(bin.BigEndian.Uint32(local[l:]))
+
+	for i := 0; len(result) < total; i++ {
+		if next < 1 || next > db.pageCount() || i > db.pageCount() {
+			return nil, errInvalidSQLite
+		}
+
+		if pages != nil {
+			*pages = append(*pages, next)
+		}
+
+		p := db.page(next)
+		next = int(bin.BigEndian.Uint32(p))
+
+		chunk := p[4:db.usable]
+		if rest := total - len(result); rest < len(chunk) {
+			chunk = chunk[:rest]
+		}
+		result = append(result, chunk...)
+	}
+
+	return result, nil
+}
+
+func (db *sqliteDB) table(name string) (*sqliteObject, error) {
+	t, ok := db.schema[name]
+	if !ok || t.kind != "table" {
+		return nil, errSQLiteNoTable
+	}
+
+	if !t.supported {
+		return nil, errUnsupportedSQLite
+	}
+
+	return t, nil
+}
+
+func (db *sqliteDB) indexesOf(table string) []*sqliteObject {
+	var result []*sqliteObject
+	for _, o := range db.schema {
+		if o.kind == "index" && o.table == table {
+			result = append(result, o)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].root < result[j].root
+	})
+
+	return result
+}
+
+func (db *sqliteDB) columnIndex(t *sqliteObject, column string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c, column) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rows returns the rows of the table, with one value for every column
+func (db *sqliteDB) rows(name string) ([]sqliteRow, error) {
+	t, err := db.table(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []sqliteRow
+	err = db.walk(t.root, 0, nil, func(rowid int64, payload []byte) error {
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+
+		// Columns added to the table after the row was written are not in
+		// the record, and a column that is an alias of the rowid is NULL
+		for len(values) < len(t.columns) {
+			values = append(values, nil)
+		}
+		if t.rowidColumn >= 0 {
+			values[t.rowidColumn] = rowid
+		}
+
+		result = append(result, sqliteRow{rowid: rowid, values: values[:len(t.columns)]})
+		return nil
+	})
+
+	return result, err
+}
+
+// setRows replaces all the rows of the table, rebuilding its indexes.
+// The pages of the free list are used first, and the ones that are not
+// needed anymore are added to it. The database is left as it was
+// when the rows can't be written
+func (db *sqliteDB) setRows(name string, rows []sqliteRow) error {
+	original := append([]byte{}, db.content...)
+	err := db.writeRows(name, rows)
+	if err != nil {
+		db.content = original
+	}
+	return err
+}
+
+func (db *sqliteDB) writeRows(name string, rows []sqliteRow) error {
+	t, err := db.table(name)
+	if err != nil {
+		return err
+	}
+
+	indexes := db.indexesOf(name)
+	for _, idx := range indexes {
+		if !idx.supported {
+			return errUnsupportedSQLite
+		}
+	}
+
+	rows = append([]sqliteRow{}, rows...)
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].rowid < rows[j].rowid
+	})
+
+	for i := 1; i < len(rows); i++ {
+		if rows[i].rowid == rows[i-1].rowid {
+			return errSQLiteConstraint
+		}
+	}
+
+	free, err := db.treePages(t.root)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range indexes {
+		pages, err := db.treePages(idx.root)
+		if err != nil {
+			return err
+		}
+		free = append(free, pages...)
+	}
+
+	unused, err := db.takeFreeList()
+	if err != nil {
+		return err
+	}
+	free = append(free, unused...)
+	sort.Ints(free)
+
+	alloc := func() int {
+		if len(free) > 0 {
+			n := free[0]
+			free = free[1:]
+			return n
+		}
+		db.content = append(db.content, make([]byte, db.pageSize)...)
+		return db.pageCount()
+	}
+
+	err = db.writeTable(t, rows, alloc)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range indexes {
+		err = db.writeIndex(t, idx, rows, alloc)
+		if err != nil {
+			return err
+		}
+	}
+
+	db.freePages(free)
+	db.updateHeader()
+
+	return nil
+}
+
+// treePages returns the pages used by the b-tree, except its root
+func (db *sqliteDB) treePages(root int) ([]int, error) {
+	var pages []int
+	err := db.walk(root, 0, &pages, func(int64, []byte) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pages[1:], nil
+}
+
+func (db *sqliteDB) writeTable(t *sqliteObject, rows []sqliteRow, alloc func() int) error {
+	cells := make([][]byte, 0, len(rows))
+	for _, r := range rows {
+		values := append([]interface{}{}, r.values...)
+		if t.rowidColumn >= 0 {
+			values[t.rowidColumn] = nil
+		}
+
+		record, err := encodeRecord(values)
+		if err != nil {
+			return err
+		}
+
+		if len(record) > db.maxLocal(false) {
+			return errSQLiteTooLarge
+		}
+
+		cell := putVarint(uint64(len(record)))
+		cell = append(cell, putVarint(uint64(r.rowid))...)
+		cells = append(cells, append(cell, record...))
+	}
+
+	leaves := db.splitCells(cells, 8)
+	if len(leaves) == 1 {
+		return db.writePage(t.root, sqliteTableLeaf, cells, 0)
+	}
+
+	var interior [][]byte
+	used := 0
+	for i, leaf := range leaves {
+		n := alloc()
+		err := db.writePage(n, sqliteTableLeaf, leaf, 0)
+		if err != nil {
+			return err
+		}
+
+		used += len(leaf)
+		if i == len(leaves)-1 {
+			return db.writePage(t.root, sqliteTableInterior, interior, n)
+		}
+
+		cell := make([]byte, 4)
+		bin.BigEndian.PutUint32(cell, uint32(n))
+		interior = append(interior, append(cell, putVarint(uint64(rows[used-1].rowid))...))
+	}
+
+	return nil
+}
+
+func (db *sqliteDB) writeIndex(t, idx *sqliteObject, rows []sqliteRow, alloc func() int) error {
+	columns := make([]int, 0, len(idx.columns))
+	for _, c := range idx.columns {
+		i := db.columnIndex(t, c)
+		if i < 0 {
+			return errUnsupportedSQLite
+		}
+		columns = append(columns, i)
+	}
+
+	keys := make([][]interface{}, 0, len(rows))
+	for _, r := range rows {
+		key := make([]interface{}, 0, len(columns)+1)
+		for _, c := range columns {
+			key = append(key, r.values[c])
+		}
+		keys = append(keys, append(key, r.rowid))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return compareSQLiteKeys(keys[i], keys[j]) < 0
+	})
+
+	cells := make([][]byte, 0, len(keys))
+	for i, k := range keys {
+		if i > 0 && idx.unique && isUniqueIndexConflict(keys[i-1], k) {
+			return errSQLiteConstraint
+		}
+
+		record, err := encodeRecord(k)
+		if err != nil {
+			return err
+		}
+
+		if len(record) > db.maxLocal(true) {
+			return errSQLiteTooLarge
+		}
+
+		cells = append(cells, append(putVarint(uint64(len(record))), record...))
+	}
+
+	leaves, separators := db.splitIndexCells(cells)
+	if len(leaves) == 1 {
+		return db.writePage(idx.root, sqliteIndexLeaf, cells, 0)
+	}
+
+	var interior [][]byte
+	for i, leaf := range leaves {
+		n := alloc()
+		err := db.writePage(n, sqliteIndexLeaf, leaf, 0)
+		if err != nil {
+			return err
+		}
+
+		if i == len(leaves)-1 {
+			return db.writePage(idx.root, sqliteIndexInterior, interior, n)
+		}
+
+		cell := make([]byte, 4)
+		bin.BigEndian.PutUint32(cell, uint32(n))
+		interior = append(interior, append(cell, separators[i]...))
+	}
+
+	return nil
+}
+
+// isUniqueIndexConflict is only used for the unique indexes of Mumble,
+// where all the columns have values. NULL values never conflict
+func isUniqueIndexConflict(a, b []interface{}) bool {
+	for i := 0; i < len(a)-1; i++ {
+		if a[i] == nil || compareSQLiteValues(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCells groups the cells in pages with the given header size
+func (db *sqliteDB) splitCells(cells [][]byte, headerSize int) [][][]byte {
+	result := [][][]byte{}
+	var current [][]byte
+	used := headerSize
+
+	for _, c := range cells {
+		if used+2+len(c) > db.usable && len(current) > 0 {
+			result = append(result, current)
+			current = nil
+			used = headerSize
+		}
+		current = append(current, c)
+		used += 2 + len(c)
+	}
+
+	return append(result, current)
+}
+
+// splitIndexCells groups the cells of an index in leaf pages. In index
+// b-trees the entries of the interior pages are not repeated in the
+// leaves, so one entry is kept between every two leaves
+func (db *sqliteDB) splitIndexCells(cells [][]byte) ([][][]byte, [][]byte) {
+	var leaves [][][]byte
+	var separators [][]byte
+	var current [][]byte
+	used := 8
+
+	for i := 0; i < len(cells); i++ {
+		c := cells[i]
+		if used+2+len(c) <= db.usable {
+			current = append(current, c)
+			used += 2 + len(c)
+			continue
+		}
+
+		// When nothing would be left for the next leaf, the last
+		// entry of this one is used as the separator instead
+		if i == len(cells)-1 {
+			separators = append(separators, current[len(current)-1])
+			leaves = append(leaves, current[:len(current)-1])
+			current = [][]byte{c}
+			used = 8 + 2 + len(c)
+			continue
+		}
+
+		leaves = append(leaves, current)
+		separators = append(separators, c)
+		current = nil
+		used = 8
+	}
+
+	return append(leaves, current), separators
+}
+
+func (db *sqliteDB) writePage(n int, kind byte, cells [][]byte, right int) error {
+	interior := kind == sqliteIndexInterior || kind == sqliteTableInterior
+	h := pageHeaderOffset(n)
+
+	headerSize := 8
+	if interior {
+		headerSize = 12
+	}
+
+	size := h + headerSize + 2*len(cells)
+	for _, c := range cells {
+		size += len(c)
+	}
+	if size > db.usable {
+		return errSQLiteTooLarge
+	}
+
+	p := db.page(n)
+	for i := h; i < db.usable; i++ {
+		p[i] = 0
+	}
+
+	p[h] = kind
+	bin.BigEndian.PutUint16(p[h+3:], uint16(len(cells)))
+
+	end := db.usable
+	for i, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		bin.BigEndian.PutUint16(p[h+headerSize+2*i:], uint16(end))
+	}
+
+	// A start of 65536 can't be written and is represented by zero
+	bin.BigEndian.PutUint16(p[h+5:], uint16(end%65536))
+
+	if interior {
+		bin.BigEndian.PutUint32(p[h+8:], uint32(right))
+	}
+
+	return nil
+}
+
+// takeFreeList returns all the pages of the free list, both trunk and
+// leaf pages, leaving the list empty
+func (db *sqliteDB) takeFreeList() ([]int, error) {
+	var pages []int
+	total := int(bin.BigEndian.Uint32(db.content[36:]))
+
+	for trunk := int(bin.BigEndian.Uint32(db.content[32:])); trunk != 0; {
+		if trunk > db.pageCount() || len(pages) >= total {
+			return nil, errInvalidSQLite
+		}
+
+		p := db.page(trunk)
+		pages = append(pages, trunk)
+
+		n := int(bin.BigEndian.Uint32(p[4:]))
+		if n > db.usable/4-2 {
+			return nil, errInvalidSQLite
+		}
+
+		for i := 0; i < n; i++ {
+			leaf := int(bin.BigEndian.Uint32(p[8+4*i:]))
+			if leaf < 2 || leaf > db.pageCount() {
+				return nil, errInvalidSQLite
+			}
+			pages = append(pages, leaf)
+		}
+
+		trunk = int(bin.BigEndian.Uint32(p))
+	}
+
+	if len(pages) != total {
+		return nil, errInvalidSQLite
+	}
+
+	bin.BigEndian.PutUint32(db.content[32:], 0)
+	bin.BigEndian.PutUint32(db.content[36:], 0)
+
+	return pages, nil
+}
+
+// freePages adds the given pages to the free list, using trunk pages
+// that are not completely filled, as recommended for compatibility
+func (db *sqliteDB) freePages(pages []int) {
+	perTrunk := db.usable/4 - 8
+
+	for len(pages) > 0 {
+		trunk := pages[0]
+		pages = pages[1:]
+
+		n := len(pages)
+		if n > perTrunk {
+			n = perTrunk
+		}
+
+		p := db.page(trunk)
+		for i := range p {
+			p[i] = 0
+		}
+
+		copy(p[0:4], db.content[32:36])
+		bin.BigEndian.PutUint32(p[4:], uint32(n))
+		for i := 0; i < n; i++ {
+			bin.BigEndian.PutUint32(p[8+4*i:], uint32(pages[i]))
+		}
+
+		bin.BigEndian.PutUint32(db.content[32:], uint32(trunk))
+		total := bin.BigEndian.Uint32(db.content[36:])
+		bin.BigEndian.PutUint32(db.content[36:], total+uint32(n)+1)
+
+		pages = pages[n:]
+	}
+}
+
+func (db *sqliteDB) updateHeader() {
+	counter := bin.BigEndian.Uint32(db.content[24:]) + 1
+	bin.BigEndian.PutUint32(db.content[24:], counter)
+	bin.BigEndian.PutUint32(db.content[92:], counter)
+	bin.BigEndian.PutUint32(db.content[28:], uint32(db.pageCount()))
+}
+
+// upsert updates the rows of the table matching the key with the given
+// values, or inserts a new row with both when there is none
+func (db *sqliteDB) upsert(name string, key, values map[string]interface{}) error {
+	t, err := db.table(name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.rows(name)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, r := range rows {
+		if db.matches(t, r, key) {
+			db.setValues(t, r, values)
+			found = true
+		}
+	}
+
+	if found {
+		return db.setRows(name, rows)
+	}
+
+	rowid, err := db.nextRowid(t, rows)
+	if err != nil {
+		return err
+	}
+
+	r := sqliteRow{rowid: rowid, values: make([]interface{}, len(t.columns))}
+	db.setValues(t, r, key)
+	db.setValues(t, r, values)
+	if t.rowidColumn >= 0 {
+		r.values[t.rowidColumn] = rowid
+	}
+
+	err = db.setRows(name, append(rows, r))
+	if err != nil || !t.autoincrement {
+		return err
+	}
+
+	return db.setSequence(name, rowid)
+}
+
+// delete removes the rows of the table matching the key
+func (db *sqliteDB) delete(name string, key map[string]interface{}) error {
+	t, err := db.table(name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.rows(name)
+	if err != nil {
+		return err
+	}
+
+	result := rows[:0]
+	for _, r := range rows {
+		if !db.matches(t, r, key) {
+			result = append(result, r)
+		}
+	}
+
+	if len(result) == len(rows) {
+		return nil
+	}
+
+	return db.setRows(name, result)
+}
+
+func (db *sqliteDB) matches(t *sqliteObject, r sqliteRow, key map[string]interface{}) bool {
+	for column, v := range key {
+		i := db.columnIndex(t, column)
+		if i < 0 || compareSQLiteValues(r.values[i], normalizeSQLiteValue(v)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *sqliteDB) setValues(t *sqliteObject, r sqliteRow, values map[string]interface{}) {
+	for column, v := range values {
+		if i := db.columnIndex(t, column); i >= 0 {
+			r.values[i] = normalizeSQLiteValue(v)
+		}
+	}
+}
+
+// nextRowid returns the rowid SQLite would use for a new row. With
+// AUTOINCREMENT the rowids of deleted rows are never used again
+func (db *sqliteDB) nextRowid(t *sqliteObject, rows []sqliteRow) (int64, error) {
+	var max int64
+	for _, r := range rows {
+		if r.rowid > max {
+			max = r.rowid
+		}
+	}
+
+	if t.autoincrement {
+		sequence, err := db.rows(sqliteSequenceTable)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, s := range sequence {
+			if name, _ := s.values[0].(string); name == t.name {
+				if seq, ok := s.values[1].(int64); ok && seq > max {
+					max = seq
+				}
+			}
+		}
+	}
+
+	if max == math.MaxInt64 {
+		return 0, errSQLiteTooLarge
+	}
+
+	return max + 1, nil
+}
+
+func (db *sqliteDB) setSequence(name string, rowid int64) error {
+	return db.upsert(sqliteSequenceTable,
+		map[string]interface{}{"name": name},
+		map[string]interface{}{"seq": rowid})
+}
+
+func normalizeSQLiteValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case int:
+		return int64(value)
+	case uint16:
+		return int64(value)
+	case float32:
+		return float64(value)
+	}
+	return v
+}
+
+// parseTableColumns returns the columns of a CREATE TABLE statement and
+// the one that is an alias of the rowid, if any. Only the statements
+// written by Mumble are expected here, not any possible SQL
+func parseTableColumns(sql string) ([]string, int) {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, -1
+	}
+
+	var columns []string
+	rowid := -1
+
+	for _, def := range splitSQLList(sql[start+1 : end]) {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+
+		upper := strings.ToUpper(def)
+		if len(fields) > 1 && strings.ToUpper(fields[1]) == "INTEGER" &&
+			strings.Contains(upper, "PRIMARY KEY") && !strings.Contains(upper, "DESC") {
+			rowid = len(columns)
+		}
+
+		columns = append(columns, unquoteSQLName(fields[0]))
+	}
+
+	return columns, rowid
+}
+
+// parseIndexColumns returns the columns of a CREATE INDEX statement.
+// Indexes using expressions, collations, descending order or a WHERE
+// clause are not supported
+func parseIndexColumns(sql string) ([]string, bool) {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start || strings.TrimSpace(sql[end+1:]) != "" {
+		return nil, false
+	}
+
+	var columns []string
+	for _, def := range splitSQLList(sql[start+1 : end]) {
+		fields := strings.Fields(def)
+		if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && strings.ToUpper(fields[1]) != "ASC") {
+			return nil, false
+		}
+		columns = append(columns, unquoteSQLName(fields[0]))
+	}
+
+	return columns, len(columns) > 0
+}
+
+func splitSQLList(s string) []string {
+	var result []string
+	depth := 0
+	var quote rune
+	last := 0
+
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '`' || c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, s[last:i])
+			last = i + 1
+		}
+	}
+
+	return append(result, s[last:])
+}
+
+func unquoteSQLName(s string) string {
+	if len(s) >= 2 {
+		switch s[0] {
+		case '`', '"', '\'':
+			if s[len(s)-1] == s[0] {
+				return s[1 : len(s)-1]
+			}
+		case '[':
+			if s[len(s)-1] == ']' {
+				return s[1 : len(s)-1]
+			}
+		}
+	}
+	return s
+}
+
+func decodeRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n := getVarint(payload)
+	if n == 0 || headerSize > uint64(len(payload)) || headerSize < uint64(n) {
+		return nil, errInvalidSQLite
+	}
+
+	var types []uint64
+	header := payload[n:headerSize]
+	for len(header) > 0 {
+		t, k := getVarint(header)
+		if k == 0 {
+			return nil, errInvalidSQLite
+		}
+		types = append(types, t)
+		header = header[k:]
+	}
+
+	body := payload[headerSize:]
+	values := make([]interface{}, 0, len(types))
+
+	for _, t := range types {
+		size := serialTypeSize(t)
+		if size < 0 || size > len(body) {
+			return nil, errInvalidSQLite
+		}
+
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			values = append(values, decodeSQLiteInt(data))
+		case t == 7:
+			values = append(values, math.Float64frombits(bin.BigEndian.Uint64(data)))
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t%2 == 0:
+			values = append(values, append([]byte{}, data...))
+		default:
+			values = append(values, string(data))
+		}
+	}
+
+	return values, nil
+}
+
+func serialTypeSize(t uint64) int {
+	switch {
+	case t <= 4:
+		return int(t)
+	case t == 5:
+		return 6
+	case t == 6 || t == 7:
+		return 8
+	case t == 8 || t == 9:
+		return 0
+	case t >= 12:
+		return int((t - 12) / 2)
+	}
+	return -1
+}
+
+func decodeSQLiteInt(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	// The integers are big-endian and signed
+	v := int64(int8(data[0]))
+	for _, b := range data[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var types, body []byte
+
+	for _, v := range values {
+		switch value := normalizeSQLiteValue(v).(type) {
+		case nil:
+			types = append(types, putVarint(0)...)
+		case int64:
+			t, size := sqliteIntType(value)
+			types = append(types, putVarint(t)...)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(value>>(8*uint(i))))
+			}
+		case float64:
+			types = append(types, putVarint(7)...)
+			b := make([]byte, 8)
+			bin.BigEndian.PutUint64(b, math.Float64bits(value))
+			body = append(body, b...)
+		case string:
+			types = append(types, putVarint(uint64(13+2*len(value)))...)
+			body = append(body, value...)
+		case []byte:
+			types = append(types, putVarint(uint64(12+2*len(value)))...)
+			body = append(body, value...)
+		default:
+			return nil, errUnsupportedSQLite
+		}
+	}
+
+	// The size of the header includes the varint of the size itself
+	headerSize := len(types) + 1
+	for len(putVarint(uint64(headerSize)))+len(types) != headerSize {
+		headerSize = len(putVarint(uint64(headerSize))) + len(types)
+	}
+
+	result := putVarint(uint64(headerSize))
+	result = append(result, types...)
+
+	return append(result, body...), nil
+}
+
+func sqliteIntType(v int64) (uint64, int) {
+	switch {
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// compareSQLiteValues orders the values like SQLite does: NULL first,
+// then numbers, text and blobs. Text uses the binary collation
+func compareSQLiteValues(a, b interface{}) int {
+	ca, cb := sqliteValueClass(a), sqliteValueClass(b)
+	if ca != cb {
+		return ca - cb
+	}
+
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return compareInt64(x, y)
+		}
+		return compareFloat64(float64(x), b.(float64))
+	case float64:
+		if y, ok := b.(int64); ok {
+			return compareFloat64(x, float64(y))
+		}
+		return compareFloat64(x, b.(float64))
+	case string:
+		return strings.Compare(x, b.(string))
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	}
+
+	return 0
+}
+
+func compareSQLiteKeys(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareSQLiteValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func sqliteValueClass(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	}
+	return 3
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat64(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// getVarint decodes the variable-length integers used by SQLite, which
+// are big-endian and different from the ones of encoding/binary. It
+// returns zero as the length when the varint is not complete
+func getVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func putVarint(v uint64) []byte {
+	if v>>56 != 0 {
+		result := make([]byte, 9)
+		result[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			result[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return result
+	}
+
+	var groups []byte
+	for {
+		groups = append(groups, byte(v&0x7f)|0x80)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	groups[0] &= 0x7f
+
+	result := make([]byte, len(groups))
+	for i, g := range groups {
+		result[len(groups)-1-i] = g
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+type SQLiteSuite struct {
+	template []byte
+}
+
+var _ = Suite(&SQLiteSuite{})
+
+func (s *SQLiteSuite) SetUpSuite(c *C) {
+	content, err := ioutil.ReadFile(filepath.Join("files", ".mumble.sqlite"))
+	c.Assert(err, IsNil)
+	s.template = content
+}
+
+func (s *SQLiteSuite) db(c *C) *sqliteDB {
+	db, err := parseSQLite(s.template)
+	c.Assert(err, IsNil)
+	return db
+}
+
+// reopen parses the content written, like the next time it's loaded
+func (s *SQLiteSuite) reopen(c *C, db *sqliteDB) *sqliteDB {
+	result, err := parseSQLite(db.bytes())
+	c.Assert(err, IsNil)
+	s.checkPages(c, result)
+	return result
+}
+
+// checkPages checks that every page of the database is used by
+// exactly one b-tree, or is in the free list
+func (s *SQLiteSuite) checkPages(c *C, db *sqliteDB) {
+	var pages []int
+	c.Assert(db.walk(1, 0, &pages, func(int64, []byte) error { return nil }), IsNil)
+
+	for _, o := range db.schema {
+		c.Assert(db.walk(o.root, 0, &pages, func(int64, []byte) error { return nil }), IsNil)
+	}
+
+	free, err := (&sqliteDB{content: append([]byte{}, db.content...), pageSize: db.pageSize, usable: db.usable}).takeFreeList()
+	c.Assert(err, IsNil)
+	pages = append(pages, free...)
+
+	used := make(map[int]bool)
+	for _, p := range pages {
+		c.Assert(used[p], Equals, false, Commentf("page %d is used twice", p))
+		used[p] = true
+	}
+	c.Assert(used, HasLen, db.pageCount())
+}
+
+func (s *SQLiteSuite) sequence(c *C, db *sqliteDB, table string) int64 {
+	rows, err := db.rows(sqliteSequenceTable)
+	c.Assert(err, IsNil)
+
+	for _, r := range rows {
+		if r.values[0] == table {
+			return r.values[1].(int64)
+		}
+	}
+	return 0
+}
+
+func (s *SQLiteSuite) certificates(c *C, db *sqliteDB) map[string]string {
+	rows, err := db.rows("cert")
+	c.Assert(err, IsNil)
+
+	result := make(map[string]string)
+	for _, r := range rows {
+		c.Assert(r.values[0], Equals, r.rowid)
+		result[fmt.Sprintf("%s:%d", r.values[1], r.values[2])] = r.values[3].(string)
+	}
+	return result
+}
+
+func storeTestCertificate(db *sqliteDB, hostname string, port int, digest string) error {
+	return db.upsert("cert",
+		map[string]interface{}{"hostname": hostname, "port": port},
+		map[string]interface{}{"digest": digest})
+}
+
+func (s *SQLiteSuite) Test_parseSQLite_readsTheTemplate(c *C) {
+	db := s.db(c)
+	s.checkPages(c, db)
+
+	c.Assert(db.schema["cert"].autoincrement, Equals, true)
+	c.Assert(db.schema["cert_host_port"].unique, Equals, true)
+	c.Assert(db.schema["cert_host_port"].columns, DeepEquals, []string{"hostname", "port"})
+	c.Assert(s.certificates(c, db), HasLen, 1)
+}
+
+func (s *SQLiteSuite) Test_parseSQLite_failsWithContentThatIsNotADatabase(c *C) {
+	_, err := parseSQLite([]byte("this is not a database"))
+	c.Assert(failure.Kind(err), Equals, errInvalidSQLite)
+
+	_, err = parseSQLite(s.template[:len(s.template)-10])
+	c.Assert(failure.Kind(err), Equals, errInvalidSQLite)
+}
+
+func (s *SQLiteSuite) Test_parseSQLite_acceptsDatabasesInWALMode(c *C) {
+	content := append([]byte{}, s.template...)
+	content[18], content[19] = 2, 2
+
+	db, err := parseSQLite(content)
+	c.Assert(err, IsNil)
+	c.Assert(storeTestCertificate(db, "example.onion", 64738, "abc"), IsNil)
+
+	db = s.reopen(c, db)
+	c.Assert(db.bytes()[18], Equals, byte(2))
+	c.Assert(s.certificates(c, db)["example.onion:64738"], Equals, "abc")
+
+	content[19] = 1
+	_, err = parseSQLite(content)
+	c.Assert(failure.Kind(err), Equals, errUnsupportedSQLite)
+}
+
+func (s *SQLiteSuite) Test_loadDBFromFile_failsWhenTheWriteAheadLogHasChanges(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-sqlite")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, ".mumble.sqlite")
+	c.Assert(ioutil.WriteFile(fileName, s.template, 0600), IsNil)
+	c.Assert(ioutil.WriteFile(fileName+"-wal", nil, 0600), IsNil)
+
+	_, err = loadDBFromFile(fileName)
+	c.Assert(err, IsNil)
+
+	c.Assert(ioutil.WriteFile(fileName+"-wal", []byte("changes"), 0600), IsNil)
+	_, err = loadDBFromFile(fileName)
+	c.Assert(failure.Kind(err), Equals, errUnsupportedSQLite)
+}
+
+func (s *SQLiteSuite) Test_upsert_insertsAndUpdatesRows(c *C) {
+	db := s.db(c)
+	before := s.sequence(c, db, "cert")
+
+	c.Assert(storeTestCertificate(db, "example.onion", 64738, "abc"), IsNil)
+	c.Assert(storeTestCertificate(db, "example.onion", 443, "def"), IsNil)
+	c.Assert(storeTestCertificate(db, "example.onion", 64738, "ghi"), IsNil)
+
+	db = s.reopen(c, db)
+	certificates := s.certificates(c, db)
+	c.Assert(certificates, HasLen, 3)
+	c.Assert(certificates["example.onion:64738"], Equals, "ghi")
+	c.Assert(certificates["example.onion:443"], Equals, "def")
+	c.Assert(s.sequence(c, db, "cert"), Equals, before+2)
+}
+
+func (s *SQLiteSuite) Test_delete_removesTheRowsWithoutUsingTheirRowidsAgain(c *C) {
+	db := s.db(c)
+	c.Assert(storeTestCertificate(db, "first.onion", 64738, "abc"), IsNil)
+	c.Assert(storeTestCertificate(db, "second.onion", 64738, "def"), IsNil)
+	sequence := s.sequence(c, db, "cert")
+
+	c.Assert(db.delete("cert", map[string]interface{}{"hostname": "second.onion"}), IsNil)
+	db = s.reopen(c, db)
+	_, found := s.certificates(c, db)["second.onion:64738"]
+	c.Assert(found, Equals, false)
+
+	c.Assert(storeTestCertificate(db, "third.onion", 64738, "ghi"), IsNil)
+	db = s.reopen(c, db)
+	c.Assert(s.sequence(c, db, "cert"), Equals, sequence+1)
+
+	rows, err := db.rows("cert")
+	c.Assert(err, IsNil)
+	c.Assert(rows[len(rows)-1].rowid, Equals, sequence+1)
+}
+
+func (s *SQLiteSuite) Test_upsert_failsWhenAUniqueIndexHasTheValuesAlready(c *C) {
+	db := s.db(c)
+	c.Assert(db.upsert("friends",
+		map[string]interface{}{"hash": "1234"},
+		map[string]interface{}{"name": "Alice"}), IsNil)
+	content := append([]byte{}, db.bytes()...)
+
+	err := db.upsert("friends",
+		map[string]interface{}{"hash": "5678"},
+		map[string]interface{}{"name": "Alice"})
+	c.Assert(failure.Kind(err), Equals, errSQLiteConstraint)
+	c.Assert(db.bytes(), DeepEquals, content)
+
+	c.Assert(db.upsert("friends",
+		map[string]interface{}{"hash": "5678"},
+		map[string]interface{}{"name": "Bob"}), IsNil)
+
+	db = s.reopen(c, db)
+	rows, err := db.rows("friends")
+	c.Assert(err, IsNil)
+	c.Assert(rows, HasLen, 2)
+}
+
+func (s *SQLiteSuite) Test_upsert_writesTablesInSeveralPages(c *C) {
+	db := s.db(c)
+	hostname := strings.Repeat("a", 56) + ".onion"
+
+	for i := 0; i < 300; i++ {
+		c.Assert(storeTestCertificate(db, hostname, i, fmt.Sprintf("%040d", i)), IsNil)
+	}
+
+	db = s.reopen(c, db)
+	pages, err := db.treePages(db.schema["cert"].root)
+	c.Assert(err, IsNil)
+	c.Assert(len(pages) > 1, Equals, true)
+
+	pages, err = db.treePages(db.schema["cert_host_port"].root)
+	c.Assert(err, IsNil)
+	c.Assert(len(pages) > 1, Equals, true)
+
+	certificates := s.certificates(c, db)
+	c.Assert(certificates, HasLen, 301)
+	for i := 0; i < 300; i++ {
+		c.Assert(certificates[fmt.Sprintf("%s:%d", hostname, i)], Equals, fmt.Sprintf("%040d", i))
+	}
+}
+
+func (s *SQLiteSuite) Test_setRows_usesThePagesOfTheFreeList(c *C) {
+	db := s.db(c)
+	for i := 0; i < 300; i++ {
+		c.Assert(storeTestCertificate(db, "example.onion", i, fmt.Sprintf("%040d", i)), IsNil)
+	}
+	size := db.pageCount()
+
+	c.Assert(db.delete("cert", map[string]interface{}{"hostname": "example.onion"}), IsNil)
+	db = s.reopen(c, db)
+	c.Assert(db.content[36:40], Not(DeepEquals), []byte{0, 0, 0, 0})
+
+	for i := 0; i < 300; i++ {
+		c.Assert(db.upsert("pingcache",
+			map[string]interface{}{"hostname": "example.onion", "port": i},
+			map[string]interface{}{"ping": i}), IsNil)
+	}
+
+	db = s.reopen(c, db)
+	c.Assert(db.pageCount(), Equals, size)
+
+	rows, err := db.rows("pingcache")
+	c.Assert(err, IsNil)
+	c.Assert(rows, HasLen, 300)
+}
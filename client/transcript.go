@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	b "encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The chat transcript is a personal record of the text messages the
+// local user sees during a meeting. It's written only in the local
+// computer, and every message is encrypted before touching the disk:
+//
+//   record = length (4 bytes) | nonce | AES-GCM(JSON message)
+//
+// The position of every record is authenticated with it, so a record
+// can't be removed or moved without the transcript being reported as
+// corrupted, except the last ones. Records are appended one by one, so
+// a transcript is still readable if Wahay is closed abruptly. Nothing
+// captures messages by default: the transcript only exists if the user
+// enables it.
+
+const (
+	// TranscriptKeyLength is the length of the keys used to encrypt transcripts
+	TranscriptKeyLength = 32
+
+	// TranscriptFileExtension is the extension of the transcript files
+	TranscriptFileExtension = ".wahaychat"
+
+	transcriptNonceLength  = 12
+	maxTranscriptRecordLen = 1024 * 1024
+)
+
+var (
+	// ErrInvalidTranscriptKey is an error to be trown when the
+	// key of the transcript doesn't have the right length
+	ErrInvalidTranscriptKey = failure.New("client.invalid-transcript-key", failure.CategoryInput, "the key of the transcript is not valid", "")
+
+	// ErrCorruptedTranscript is an error to be trown when the transcript
+	// can't be decrypted with the given key or it was modified
+	ErrCorruptedTranscript = failure.New("client.corrupted-transcript", failure.CategoryInput, "the transcript is corrupted or the key is not correct", "check the key of the transcript")
+
+	// ErrTranscriptClosed is an error to be trown when a message is
+	// recorded after the transcript has been closed
+	ErrTranscriptClosed = failure.New("client.transcript-closed", failure.CategoryMumble, "the transcript is closed", "")
+)
+
+// ChatMessage is a text message seen by the local user during a meeting
+type ChatMessage struct {
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author"`
+	Channel string    `json:"channel,omitempty"`
+	Text    string    `json:"text"`
+}
+
+// Transcript records chat messages in an encrypted file
+type Transcript interface {
+	Record(ChatMessage) error
+	Close() error
+}
+
+type transcript struct {
+	sync.Mutex
+	file *os.File
+	aead cipher.AEAD
+
+	// records is how many records the file has
+	records uint64
+}
+
+// NewTranscriptKey returns a new random key for a transcript
+func NewTranscriptKey() ([]byte, error) {
+	key := make([]byte, TranscriptKeyLength)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// OpenTranscript opens the transcript in the given file for recording
+// new messages, creating it if it doesn't exist
+func OpenTranscript(fileName string, key []byte) (Transcript, error) {
+	aead, err := transcriptCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(fileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := skipTranscriptRecords(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &transcript{
+		file:    f,
+		aead:    aead,
+		records: records,
+	}, nil
+}
+
+// skipTranscriptRecords counts the records of the transcript, and leaves
+// the file ready for appending more. A record that was not written
+// completely, when Wahay was closed abruptly, is removed
+func skipTranscriptRecords(f *os.File) (uint64, error) {
+	r := bufio.NewReader(f)
+	records := uint64(0)
+	end := int64(0)
+
+	for {
+		length := make([]byte, 4)
+		if _, err := io.ReadFull(r, length); err != nil {
+			break
+		}
+
+		l := b.BigEndian.Uint32(length)
+		if l <= transcriptNonceLength || l > maxTranscriptRecordLen {
+			return 0, ErrCorruptedTranscript
+		}
+
+		if _, err := io.CopyN(ioutil.Discard, r, int64(l)); err != nil {
+			break
+		}
+
+		records++
+		end += 4 + int64(l)
+	}
+
+	err := f.Truncate(end)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.Seek(end, io.SeekStart)
+	return records, err
+}
+
+// transcriptRecordData is the data authenticated with every
+// record, which is its position in the transcript
+func transcriptRecordData(index uint64) []byte {
+	data := make([]byte, 8)
+	b.BigEndian.PutUint64(data, index)
+	return data
+}
+
+func transcriptCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != TranscriptKeyLength {
+		return nil, ErrInvalidTranscriptKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (t *transcript) Record(m ChatMessage) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.file == nil {
+		return ErrTranscriptClosed
+	}
+
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	plain, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, transcriptNonceLength)
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return err
+	}
+
+	record := append(nonce, t.aead.Seal(nil, nonce, plain, transcriptRecordData(t.records))...)
+
+	length := make([]byte, 4)
+	b.BigEndian.PutUint32(length, uint32(len(record)))
+
+	_, err = t.file.Write(append(length, record...))
+	if err != nil {
+		return err
+	}
+
+	t.records++
+	return nil
+}
+
+func (t *transcript) Close() error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+
+	err := t.file.Close()
+	t.file = nil
+
+	return err
+}
+
+// ReadTranscript decrypts all the messages of the given transcript
+func ReadTranscript(fileName string, key []byte) ([]ChatMessage, error) {
+	aead, err := transcriptCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	messages := []ChatMessage{}
+
+	for index := uint64(0); ; index++ {
+		m, err := readTranscriptRecord(r, aead, index)
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+}
+
+func readTranscriptRecord(r io.Reader, aead cipher.AEAD, index uint64) (ChatMessage, error) {
+	m := ChatMessage{}
+
+	length := make([]byte, 4)
+	_, err := io.ReadFull(r, length)
+	if err == io.EOF {
+		return m, io.EOF
+	}
+	if err != nil {
+		return m, ErrCorruptedTranscript
+	}
+
+	l := b.BigEndian.Uint32(length)
+	if l <= transcriptNonceLength || l > maxTranscriptRecordLen {
+		return m, ErrCorruptedTranscript
+	}
+
+	record := make([]byte, l)
+	_, err = io.ReadFull(r, record)
+	if err != nil {
+		return m, ErrCorruptedTranscript
+	}
+
+	plain, err := aead.Open(nil, record[:transcriptNonceLength], record[transcriptNonceLength:], transcriptRecordData(index))
+	if err != nil {
+		return m, ErrCorruptedTranscript
+	}
+
+	err = json.Unmarshal(plain, &m)
+	if err != nil {
+		return m, ErrCorruptedTranscript
+	}
+
+	return m, nil
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"#", `\#`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+var (
+	chatLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	chatTags       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// plainChatText removes the HTML formatting that Mumble
+// uses for the text messages, keeping the line breaks
+func plainChatText(s string) string {
+	s = chatLineBreaks.ReplaceAllString(s, "\n")
+	s = chatTags.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// ExportTranscriptMarkdown returns the given messages as a Markdown
+// document, that the user can keep or share outside of Wahay
+func ExportTranscriptMarkdown(title string, messages []ChatMessage) []byte {
+	var buf bytes.Buffer
+
+	if title == "" {
+		title = "Wahay meeting"
+	}
+	fmt.Fprintf(&buf, "# %s\n", markdownEscaper.Replace(title))
+
+	day := ""
+	for _, m := range messages {
+		t := m.Time.Local()
+
+		if d := t.Format("2006-01-02"); d != day {
+			day = d
+			fmt.Fprintf(&buf, "\n## %s\n\n", day)
+		}
+
+		author := markdownEscaper.Replace(m.Author)
+		if m.Channel != "" {
+			author = fmt.Sprintf("%s (%s)", author, markdownEscaper.Replace(m.Channel))
+		}
+
+		// Every line of the message is kept inside the same list item
+		text := strings.Replace(markdownEscaper.Replace(plainChatText(m.Text)), "\n", "  \n  ", -1)
+
+		fmt.Fprintf(&buf, "- **%s** %s: %s\n", t.Format("15:04"), author, text)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	b "encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type TranscriptSuite struct {
+	dir string
+	key []byte
+}
+
+var _ = Suite(&TranscriptSuite{})
+
+func (s *TranscriptSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-transcript")
+	c.Assert(err, IsNil)
+	s.dir = dir
+
+	s.key, err = NewTranscriptKey()
+	c.Assert(err, IsNil)
+}
+
+func (s *TranscriptSuite) TearDownTest(c *C) {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *TranscriptSuite) record(c *C, fileName string, texts ...string) {
+	t, err := OpenTranscript(fileName, s.key)
+	c.Assert(err, IsNil)
+	defer t.Close()
+
+	for _, text := range texts {
+		c.Assert(t.Record(ChatMessage{Author: "Alice", Text: text}), IsNil)
+	}
+}
+
+// records returns the records of the transcript, with their length
+func (s *TranscriptSuite) records(c *C, fileName string) [][]byte {
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+
+	var result [][]byte
+	for len(content) > 0 {
+		l := 4 + int(b.BigEndian.Uint32(content))
+		result = append(result, content[:l])
+		content = content[l:]
+	}
+	return result
+}
+
+func (s *TranscriptSuite) Test_Record_isReadInTheSameOrder(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+
+	s.record(c, fileName, "first", "second")
+	s.record(c, fileName, "third")
+
+	messages, err := ReadTranscript(fileName, s.key)
+	c.Assert(err, IsNil)
+	c.Assert(messages, HasLen, 3)
+	c.Assert(messages[0].Text, Equals, "first")
+	c.Assert(messages[1].Text, Equals, "second")
+	c.Assert(messages[2].Text, Equals, "third")
+	c.Assert(messages[2].Author, Equals, "Alice")
+	c.Assert(messages[2].Time.IsZero(), Equals, false)
+}
+
+func (s *TranscriptSuite) Test_Record_isNotWrittenInPlainText(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+	s.record(c, fileName, "something secret")
+
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(content), "secret"), Equals, false)
+}
+
+func (s *TranscriptSuite) Test_Record_failsOnceClosed(c *C) {
+	t, err := OpenTranscript(filepath.Join(s.dir, "meeting"+TranscriptFileExtension), s.key)
+	c.Assert(err, IsNil)
+	c.Assert(t.Close(), IsNil)
+
+	c.Assert(failure.Kind(t.Record(ChatMessage{Text: "late"})), Equals, ErrTranscriptClosed)
+}
+
+func (s *TranscriptSuite) Test_OpenTranscript_removesARecordWrittenPartially(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+	s.record(c, fileName, "first", "second")
+
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(fileName, content[:len(content)-3], 0600), IsNil)
+
+	s.record(c, fileName, "third")
+
+	messages, err := ReadTranscript(fileName, s.key)
+	c.Assert(err, IsNil)
+	c.Assert(messages, HasLen, 2)
+	c.Assert(messages[0].Text, Equals, "first")
+	c.Assert(messages[1].Text, Equals, "third")
+}
+
+func (s *TranscriptSuite) Test_ReadTranscript_failsWithAnotherKey(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+	s.record(c, fileName, "first")
+
+	other, err := NewTranscriptKey()
+	c.Assert(err, IsNil)
+
+	_, err = ReadTranscript(fileName, other)
+	c.Assert(failure.Kind(err), Equals, ErrCorruptedTranscript)
+
+	_, err = ReadTranscript(fileName, other[:10])
+	c.Assert(failure.Kind(err), Equals, ErrInvalidTranscriptKey)
+}
+
+func (s *TranscriptSuite) Test_ReadTranscript_detectsModifiedRecords(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+	s.record(c, fileName, "first", "second")
+
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+	content[len(content)-1] ^= 1
+	c.Assert(ioutil.WriteFile(fileName, content, 0600), IsNil)
+
+	_, err = ReadTranscript(fileName, s.key)
+	c.Assert(failure.Kind(err), Equals, ErrCorruptedTranscript)
+}
+
+func (s *TranscriptSuite) Test_ReadTranscript_detectsRemovedAndMovedRecords(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+TranscriptFileExtension)
+	s.record(c, fileName, "first", "second", "third")
+	records := s.records(c, fileName)
+	c.Assert(records, HasLen, 3)
+
+	join := func(rs ...[]byte) []byte {
+		var result []byte
+		for _, r := range rs {
+			result = append(result, r...)
+		}
+		return result
+	}
+
+	c.Assert(ioutil.WriteFile(fileName, join(records[0], records[2]), 0600), IsNil)
+	_, err := ReadTranscript(fileName, s.key)
+	c.Assert(failure.Kind(err), Equals, ErrCorruptedTranscript)
+
+	c.Assert(ioutil.WriteFile(fileName, join(records[1], records[0], records[2]), 0600), IsNil)
+	_, err = ReadTranscript(fileName, s.key)
+	c.Assert(failure.Kind(err), Equals, ErrCorruptedTranscript)
+}
+
+func (s *TranscriptSuite) Test_ExportTranscriptMarkdown_escapesTheMessages(c *C) {
+	t := time.Date(2026, 10, 14, 15, 4, 0, 0, time.Local)
+	md := string(ExportTranscriptMarkdown("", []ChatMessage{
+		{Time: t, Author: "*Bob*", Channel: "Room [1]", Text: "see <b>#1</b> and `code`<br/>_bye_ &amp; \\o/"},
+	}))
+
+	c.Assert(md, Equals, "# Wahay meeting\n"+
+		"\n## 2026-10-14\n\n"+
+		"- **15:04** \\*Bob\\* (Room \\[1\\]): see \\#1 and \\`code\\`  \n  \\_bye\\_ & \\\\o/\n")
+}
+
+func (s *TranscriptSuite) Test_ExportTranscriptMarkdown_escapesTheHTMLLeftInTheText(c *C) {
+	t := time.Date(2026, 10, 14, 9, 30, 0, 0, time.Local)
+	md := string(ExportTranscriptMarkdown("Weekly # meeting", []ChatMessage{
+		{Time: t, Author: "Alice", Text: "1 &lt; 2 &gt; 0"},
+	}))
+
+	c.Assert(md, Equals, "# Weekly \\# meeting\n"+
+		"\n## 2026-10-14\n\n"+
+		"- **09:30** Alice: 1 &lt; 2 &gt; 0\n")
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	// #nosec
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/invitation"
+	log "github.com/sirupsen/logrus"
+)
+
+// CertificateDigest contains the digests of a certificate. Mumble keeps
+// identifying the certificates of the servers with SHA-1, so that one is
+// only written in its database. Wahay shows and logs the SHA-256 one
+type CertificateDigest struct {
+	SHA1   string
+	SHA256 string
+}
+
+func digestForCertificate(cert []byte) CertificateDigest {
+	// #nosec
+	sum := sha1.Sum(cert)
+
+	return CertificateDigest{
+		SHA1:   hex.EncodeToString(sum[:]),
+		SHA256: invitation.CertificateFingerprint(cert),
+	}
+}
+
+func (d CertificateDigest) String() string {
+	return d.SHA256
+}
+
+// matches compares the digest with a trusted certificate. The SHA-1
+// digest is only used for the entries that don't have the SHA-256 one
+func (d CertificateDigest) matches(t config.TrustedCertificate) bool {
+	if t.SHA256 != "" {
+		return t.SHA256 == d.SHA256
+	}
+	return t.SHA1 != "" && strings.EqualFold(t.SHA1, d.SHA1)
+}
+
+// TrustStore remembers the certificates of the meetings the user
+// has already trusted, so they don't have to be verified again.
+// Every server is identified by its host and port. CertificateChanged
+// is called when a server has a different certificate than the trusted one
+type TrustStore interface {
+	TrustedCertificate(host string, port int) (config.TrustedCertificate, bool)
+	TrustCertificate(config.TrustedCertificate)
+	CertificateChanged(host string, port int, fingerprint string)
+}
+
+func (c *client) SetTrustStore(s TrustStore) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.trustStore = s
+}
+
+func (c *client) getTrustStore() TrustStore {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.trustStore
+}
+
+// isTrusted returns true if the certificate was trusted before for the given server
+func (c *client) isTrusted(hostname string, port int, d CertificateDigest) bool {
+	s := c.getTrustStore()
+	if s == nil {
+		return false
+	}
+
+	t, ok := s.TrustedCertificate(hostname, port)
+	if !ok {
+		return false
+	}
+
+	if !d.matches(t) {
+		log.WithFields(log.Fields{
+			"hostname":    hostname,
+			"port":        port,
+			"fingerprint": d.SHA256,
+			"trusted":     t.SHA256,
+		}).Warning("The certificate of the server has changed since it was trusted")
+		s.CertificateChanged(hostname, port, d.SHA256)
+		return false
+	}
+
+	return true
+}
+
+// rememberCertificate saves the certificate in the trust store every time
+// it's used, so the certificates of recurring meetings don't expire.
+// Entries that only had the SHA-1 digest get the SHA-256 one too
+func (c *client) rememberCertificate(hostname string, port int, d CertificateDigest) {
+	s := c.getTrustStore()
+	if s == nil {
+		return
+	}
+
+	s.TrustCertificate(config.TrustedCertificate{
+		Host:   hostname,
+		Port:   port,
+		SHA1:   d.SHA1,
+		SHA256: d.SHA256,
+	})
+}
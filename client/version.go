@@ -0,0 +1,85 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// mumbleVersion is the version of the located Mumble binary. The format
+// of the configuration Mumble reads depends on it
+type mumbleVersion struct {
+	major, minor, patch int
+}
+
+// defaultMumbleVersion is assumed when the version can't be detected.
+// Newer versions of Mumble still read the configuration it uses
+var defaultMumbleVersion = mumbleVersion{1, 3, 0}
+
+var mumbleVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+func parseMumbleVersion(output string) (mumbleVersion, bool) {
+	m := mumbleVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return mumbleVersion{}, false
+	}
+
+	v := mumbleVersion{}
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.patch, _ = strconv.Atoi(m[3])
+	}
+
+	return v, true
+}
+
+func (v mumbleVersion) atLeast(major, minor int) bool {
+	return v.major > major || (v.major == major && v.minor >= minor)
+}
+
+func (v mumbleVersion) String() string {
+	return strconv.Itoa(v.major) + "." + strconv.Itoa(v.minor) + "." + strconv.Itoa(v.patch)
+}
+
+// usesJSONSettings returns true for the versions of Mumble that keep
+// their settings in mumble_settings.json instead of mumble.ini
+func (v mumbleVersion) usesJSONSettings() bool {
+	return v.atLeast(1, 5)
+}
+
+// mumbleVersion runs the binary to find out its version, only the
+// first time it's needed
+func (b *binary) mumbleVersion() mumbleVersion {
+	if b.version != nil {
+		return *b.version
+	}
+
+	v := defaultMumbleVersion
+
+	// This executes the Mumble command, which is under control of the code
+	/* #nosec G204 */
+	args := []string{"--version"}
+	if b.sandbox != nil {
+		args = b.sandbox.runArgs(args, nil, "")
+	}
+	command := exec.Command(b.path, args...)
+	if env := b.envIfBundle(); len(env) > 0 {
+		command.Env = append(os.Environ(), env...)
+	}
+
+	// Mumble writes its version to stderr in some systems
+	output, _ := command.CombinedOutput()
+	if detected, ok := parseMumbleVersion(string(output)); ok {
+		v = detected
+	} else {
+		log.Debugf("mumbleVersion(): the version of %s can't be detected, using %s", b.path, v)
+	}
+
+	b.version = &v
+
+	return v
+}
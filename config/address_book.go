@@ -0,0 +1,87 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxAddressBook keeps the configuration small
+const maxAddressBook = 50
+
+// AddressBookEntry is a meeting the user joins often, saved with a
+// label for picking it instead of writing its address. It has the
+// password of the meeting, so the address book is only saved when the
+// configuration file is encrypted with the master password
+type AddressBookEntry struct {
+	Label       string
+	Address     string
+	Password    string `json:",omitempty"`
+	Fingerprint string `json:",omitempty"`
+}
+
+// ListAddressBook returns the entries of the address book, sorted by label
+func (a *ApplicationConfig) ListAddressBook() []AddressBookEntry {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []AddressBookEntry{}
+	result = append(result, a.AddressBook...)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Label) < strings.ToLower(result[j].Label)
+	})
+
+	return result
+}
+
+// AddressBookEntryFor returns the entry of the address book with the
+// given label, and false if there is no such entry
+func (a *ApplicationConfig) AddressBookEntryFor(label string) (AddressBookEntry, bool) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	for _, e := range a.AddressBook {
+		if strings.EqualFold(e.Label, label) {
+			return e, true
+		}
+	}
+
+	return AddressBookEntry{}, false
+}
+
+// SaveToAddressBook adds the entry to the address book, replacing the one
+// with the same label. It returns false if the address book is full. The
+// configuration has to be saved afterwards
+func (a *ApplicationConfig) SaveToAddressBook(e AddressBookEntry) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	for i := range a.AddressBook {
+		if strings.EqualFold(a.AddressBook[i].Label, e.Label) {
+			a.AddressBook[i] = e
+			return true
+		}
+	}
+
+	if len(a.AddressBook) >= maxAddressBook {
+		return false
+	}
+
+	a.AddressBook = append(a.AddressBook, e)
+	return true
+}
+
+// RemoveFromAddressBook forgets the entry with the given label
+func (a *ApplicationConfig) RemoveFromAddressBook(label string) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []AddressBookEntry{}
+	for _, e := range a.AddressBook {
+		if !strings.EqualFold(e.Label, label) {
+			result = append(result, e)
+		}
+	}
+
+	a.AddressBook = result
+}
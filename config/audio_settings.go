@@ -0,0 +1,27 @@
+package config
+
+// AudioSettings are the Mumble audio settings the user can tune. They are
+// written to the Mumble configuration every time a meeting is joined,
+// and empty or zero values keep what Wahay uses by default
+type AudioSettings struct {
+	// PushToTalkKey is the name of the key used to talk, or its key code
+	// as "x11:<code>", like xev reports it, or "evdev:<code>", like
+	// libinput reports it in Wayland
+	PushToTalkKey string `json:",omitempty"`
+
+	// The devices are the names PulseAudio gives them, which PipeWire
+	// uses too. They are empty for the default devices of the system
+	InputDevice  string `json:",omitempty"`
+	OutputDevice string `json:",omitempty"`
+
+	// Quality is the bitrate of the encoded audio, in bits per second.
+	// It replaces the quality of the audio preset
+	Quality int `json:",omitempty"`
+
+	// NoiseSuppression is how much the noise is reduced, in decibels
+	NoiseSuppression int `json:",omitempty"`
+
+	// JitterBuffer is the amount of 10ms frames that Mumble waits for
+	// before playing the audio. It replaces the one of the audio preset
+	JitterBuffer int `json:",omitempty"`
+}
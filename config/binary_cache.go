@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The executables found in PATH are remembered, so we don't have to
+// look in all its directories every time, which is slow when some of
+// them are in the network. An entry is only used while PATH is the
+// same, the executable keeps its modification time and size, and the
+// directories of PATH before the one it's in keep their modification
+// times, since a new executable in any of them would be found first.
+//
+// Only the search in PATH is remembered: the other locations where
+// Wahay looks are always checked first, in their usual order. The
+// entries tell what is installed in the computer, so they are only
+// written with the configuration when it's encrypted.
+
+type binaryCacheEntry struct {
+	Path    string
+	PathEnv string
+	ModTime time.Time
+	Size    int64
+	Dirs    map[string]time.Time
+}
+
+// LookPath finds the executable in the directories of PATH with the
+// given function, usually exec.LookPath, or returns where it was found
+// before, when nothing has changed since then
+func (a *ApplicationConfig) LookPath(file string, lookPath func(string) (string, error)) (string, error) {
+	pathEnv := os.Getenv("PATH")
+
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	if e, ok := a.BinaryCache[file]; ok && e.isValid(pathEnv) {
+		return e.Path, nil
+	}
+
+	delete(a.BinaryCache, file)
+
+	path, err := lookPath(file)
+	if err != nil {
+		return "", err
+	}
+
+	e, ok := newBinaryCacheEntry(path, pathEnv)
+	if ok {
+		if a.BinaryCache == nil {
+			a.BinaryCache = make(map[string]binaryCacheEntry)
+		}
+		a.BinaryCache[file] = e
+	}
+
+	return path, nil
+}
+
+func newBinaryCacheEntry(path, pathEnv string) (binaryCacheEntry, bool) {
+	st, err := os.Stat(path)
+	if err != nil || st.IsDir() {
+		return binaryCacheEntry{}, false
+	}
+
+	e := binaryCacheEntry{
+		Path:    path,
+		PathEnv: pathEnv,
+		ModTime: st.ModTime(),
+		Size:    st.Size(),
+		Dirs:    make(map[string]time.Time),
+	}
+
+	found := filepath.Dir(path)
+	for _, dir := range filepath.SplitList(pathEnv) {
+		// What is found in relative directories depends on where
+		// Wahay is started, so it can't be remembered
+		if !filepath.IsAbs(dir) {
+			return binaryCacheEntry{}, false
+		}
+
+		dir = filepath.Clean(dir)
+		e.Dirs[dir] = dirModTime(dir)
+		if dir == found {
+			return e, true
+		}
+	}
+
+	// It wasn't found in PATH, so we don't know what changes it
+	return binaryCacheEntry{}, false
+}
+
+func (e binaryCacheEntry) isValid(pathEnv string) bool {
+	if e.PathEnv != pathEnv {
+		return false
+	}
+
+	st, err := os.Stat(e.Path)
+	if err != nil || st.IsDir() || !st.ModTime().Equal(e.ModTime) || st.Size() != e.Size {
+		return false
+	}
+
+	for dir, t := range e.Dirs {
+		if !dirModTime(dir).Equal(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dirModTime returns the modification time of the directory, which
+// is zero when it doesn't exist
+func dirModTime(dir string) time.Time {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,43 @@
+package config
+
+// The chat transcripts are encrypted with a key of their own, which is
+// kept with the rest of the secrets of the configuration. Like the
+// history of the meetings, they tell what happened in the meetings, so
+// they are only kept when the configuration is encrypted or its secrets
+// are in the keyring. The key is kept when the transcripts are disabled,
+// so the ones recorded before can still be exported
+
+// SetKeepChatTranscripts sets if the chat of the meetings hosted is recorded
+func (a *ApplicationConfig) SetKeepChatTranscripts(v bool) {
+	a.KeepChatTranscripts = v
+}
+
+// GetKeepChatTranscripts returns true if the chat of the meetings
+// hosted is recorded in an encrypted transcript
+func (a *ApplicationConfig) GetKeepChatTranscripts() bool {
+	return a.KeepChatTranscripts
+}
+
+// CanKeepChatTranscripts returns true when the user wants the
+// transcripts, and their key can be saved encrypted
+func (a *ApplicationConfig) CanKeepChatTranscripts() bool {
+	return a.GetKeepChatTranscripts() && a.CanSaveMeetingRooms()
+}
+
+// GetChatTranscriptKey returns the key of the chat transcripts,
+// which is empty until the first transcript is recorded
+func (a *ApplicationConfig) GetChatTranscriptKey() []byte {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	return a.ChatTranscriptKey
+}
+
+// SetChatTranscriptKey sets the key of the chat transcripts. The
+// configuration has to be saved afterwards
+func (a *ApplicationConfig) SetChatTranscriptKey(key []byte) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	a.ChatTranscriptKey = key
+}
@@ -24,12 +24,52 @@ var (
 	TorControlPassword = flag.String("tor-password", "", "the password for controlling Tor - can not be empty")
 	// Debug contains the command line argument given for debugging
 	Debug = flag.Bool("debug", false, "start Wahay in debugging mode")
+	// DebugProfilingPort contains the command line argument given for the port of the profiling endpoints
+	DebugProfilingPort = flag.Int("debug-profiling-port", 0, "the localhost port for the profiling endpoints in debugging mode - a random one by default")
 	// Trace contains the command line argument given for debugging
 	Trace = flag.Bool("trace", false, "start Wahay in tracing mode")
 	// DebugFunctionCalls contains the command line argument given for debugging
 	DebugFunctionCalls = flag.Bool("debug-function-calls", false, "trace function calls in logging")
+	// LogLevels contains the command line argument given for the log level of each subsystem
+	LogLevels = flag.String("log-levels", "", "the log level of each subsystem, for example: tor=debug,gui=warning")
+	// LogJSON contains the command line argument given for writing the logs as JSON
+	LogJSON = flag.Bool("log-json", false, "write the logs as JSON")
+	// LogUnredacted contains the command line argument given for keeping the personal information in the logs
+	LogUnredacted = flag.Bool("log-unredacted", false, "don't remove onion addresses, fingerprints and usernames from the logs - only for debugging")
+	// LogBufferSize contains the command line argument given for the amount of log entries kept in memory
+	LogBufferSize = flag.Int("log-buffer-size", DefaultLogBufferSize, "the amount of recent log entries kept in memory - 0 disables it")
 	// Version contains the command line argument given for version
 	Version = flag.Bool("version", false, "display version information and exit")
+	// SelfTest contains the command line argument given for checking that Wahay can work in this computer
+	SelfTest = flag.Bool("self-test", false, "check that Tor, the onion services and Mumble work in this computer, and exit")
+	// Panic contains the command line argument given for tearing down the running session
+	Panic = flag.Bool("panic", false, "immediately terminate the running Wahay session and exit, for using as a keyboard shortcut of the desktop")
+	// Host contains the command line argument given for hosting a meeting without the GUI
+	Host = flag.Bool("host", false, "host a meeting and print its invitation - needs -headless")
+	// Headless contains the command line argument given for running without the GUI
+	Headless = flag.Bool("headless", false, "run without the GUI, until Wahay is interrupted")
+	// HeadlessJSON contains the command line argument given for printing the invitation as JSON
+	HeadlessJSON = flag.Bool("json", false, "print the invitation of the hosted meeting as JSON")
+	// MeetingPassword contains the command line argument given for the password of the meeting
+	MeetingPassword = flag.String("password", "", "the password of the meeting hosted with -host or joined with -join")
+	// ModeratorPassword contains the command line argument given for the moderator password of the meeting
+	ModeratorPassword = flag.String("moderator-password", "", "the access token that gives the privileges of a moderator in the meeting hosted with -host")
+	// ParticipantsCanMute contains the command line argument given for letting the participants mute each other
+	ParticipantsCanMute = flag.Bool("participants-can-mute", false, "let every participant of the meeting hosted with -host mute and deafen the others")
+	// ParticipantsCanKick contains the command line argument given for letting the participants kick each other
+	ParticipantsCanKick = flag.Bool("participants-can-kick", false, "let every participant of the meeting hosted with -host remove the others")
+	// WaitingRoom contains the command line argument given for admitting the participants one by one
+	WaitingRoom = flag.Bool("waiting-room", false, "ask for admitting every participant of the meeting hosted with -host, who waits without hearing the meeting until then")
+	// Join contains the command line argument given for joining a meeting without the GUI
+	Join = flag.String("join", "", "join the meeting of the given invitation with Mumble, without the GUI")
+	// Username contains the command line argument given for the screen name used in the joined meeting
+	Username = flag.String("username", "", "the screen name used in the meeting joined with -join")
+	// Control contains the command line argument given for letting other applications drive Wahay
+	Control = flag.Bool("control", false, "listen for the commands of other applications on a local socket")
+	// MumbleBinary contains the command line argument given for the Mumble binary to use
+	MumbleBinary = flag.String("mumble-binary", "", "the Mumble binary to use instead of the one Wahay finds")
+	// Language contains the command line argument given for the language of Wahay
+	Language = flag.String("language", "", "the language of Wahay, like es, instead of the one of the system")
 )
 
 // ProcessCommandLineArguments will parse the command line, check that
@@ -37,3 +77,12 @@ var (
 func ProcessCommandLineArguments() {
 	flag.Parse()
 }
+
+// MeetingURL returns the meeting URL given as argument, for example
+// when Wahay is opened as the handler of a mumble:// link
+func MeetingURL() string {
+	if flag.NArg() == 0 {
+		return ""
+	}
+	return flag.Arg(0)
+}
@@ -11,6 +11,9 @@ import (
 	"sync"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/secrets"
 )
 
 // ApplicationConfig contains the configuration for the application.
@@ -23,21 +26,64 @@ type ApplicationConfig struct {
 	persistentMode   bool
 	encryptedFile    bool
 	encryptionParams *EncryptionParameters
+	secretStore      secrets.Store
 
 	// The fields to save as the JSON representation of the configuration
 	UniqueConfigurationID string
 	AsSuperUser           bool
 	AutoJoin              bool
+	ParticipantsCanMute   bool
+	ParticipantsCanKick   bool
+	WaitingRoom           bool
 	PathTor               string
 	PathTorsocks          string
 	LogsEnabled           bool
 	RawLogFile            string
 	PathMumble            string
 	PortMumble            string
+	MumbleListenerPort    string
+	OnionPortMappings     []string
+	WebhookURL            string
+	FileSharing           bool
+	FileSharingHostOnly   bool
+	EmbedCertificate      bool
+	OnionOnlyCertServer   bool
+	ClientAuthInvitees    int
+	MaxParticipants       int
+	MaxBandwidth          int
+	IdleKickTimeout       int
+	InvitationSender      InvitationSenderSettings
+	TorIdleTimeout        int
+	TorBridges            []string
+	TorControlAddress     string
+	TorControlPassword    string
+	TorSocksPort          int
+	TorControlPort        int
+	AudioPreset           string
+	AudioSettings         AudioSettings
+	CertificateKey        string
+	ClientIdentity        string
+	IsolatedProfiles      bool
+	KeepIdentityFiles     bool
+	TrustedCertificates   []TrustedCertificate
+	MeetingRooms          []MeetingRoom
+	ScheduledMeetings     []ScheduledMeeting
+	KeepMeetingHistory    bool
+	MeetingHistory        []MeetingHistoryEntry
+	KeepChatTranscripts   bool
+	ChatTranscriptKey     []byte
+	AddressBook           []AddressBookEntry
+	SecretsInKeyring      bool
+	LogLevel              string
+	Language              string
+	FontScale             int
+	Theme                 string
+	DisabledNotifications []string
+	BinaryCache           map[string]binaryCacheEntry `json:",omitempty"`
 }
 
 var (
-	errInvalidConfigFile = errors.New("failed to parse config file")
+	errInvalidConfigFile = failure.New("config.invalid-file", failure.CategoryConfiguration, "failed to parse config file", "")
 )
 
 // New creates a new instance of the application config struct
@@ -193,6 +239,10 @@ func (a *ApplicationConfig) tryLoad(k KeySupplier) error {
 		return errInvalidConfigFile
 	}
 
+	if a.usesKeyring() {
+		a.loadKeyringSecrets()
+	}
+
 	return nil
 }
 
@@ -209,6 +259,37 @@ func (a *ApplicationConfig) Save(k KeySupplier) error {
 	a.onBeforeSave()
 	defer a.onAfterSave()
 
+	// The keys of the meeting rooms are never written without encryption,
+	// so they are forgotten when the encryption is disabled. The same goes
+	// for the scheduled meetings, which have the keys of their rooms, and
+	// for the history of the meetings, which says where the user has been,
+	// and for the address book, which has the passwords of the meetings,
+	// and for the key of the chat transcripts. They are only kept when
+	// the user wants them in the keyring instead
+	if a.usesKeyring() {
+		s, err := a.saveKeyringSecrets()
+		defer a.restoreKeyringSecrets(s)
+		if err != nil {
+			return err
+		}
+	} else if !a.ShouldEncrypt() {
+		a.MeetingRooms = nil
+		a.ScheduledMeetings = nil
+		a.MeetingHistory = nil
+		a.AddressBook = nil
+		a.ChatTranscriptKey = nil
+	}
+
+	// The executables found tell what is installed in the computer,
+	// so they are only written encrypted, but they are still used
+	if !a.ShouldEncrypt() {
+		binaryCache := a.BinaryCache
+		a.BinaryCache = nil
+		defer func() {
+			a.BinaryCache = binaryCache
+		}()
+	}
+
 	// Ensure the directory where the configuration file will be saved
 	a.EnsureDestination()
 
@@ -322,6 +403,42 @@ func (a *ApplicationConfig) SetAutoJoinSuperUser(v bool) {
 	a.AsSuperUser = v
 }
 
+// GetParticipantsCanMute returns whether the participants of the
+// hosted meetings can mute and deafen each other
+func (a *ApplicationConfig) GetParticipantsCanMute() bool {
+	return a.ParticipantsCanMute
+}
+
+// SetParticipantsCanMute sets whether the participants of the
+// hosted meetings can mute and deafen each other
+func (a *ApplicationConfig) SetParticipantsCanMute(v bool) {
+	a.ParticipantsCanMute = v
+}
+
+// GetParticipantsCanKick returns whether the participants of the
+// hosted meetings can remove each other from the meeting
+func (a *ApplicationConfig) GetParticipantsCanKick() bool {
+	return a.ParticipantsCanKick
+}
+
+// SetParticipantsCanKick sets whether the participants of the
+// hosted meetings can remove each other from the meeting
+func (a *ApplicationConfig) SetParticipantsCanKick(v bool) {
+	a.ParticipantsCanKick = v
+}
+
+// GetWaitingRoom returns whether the participants of the hosted
+// meetings wait until the host admits them
+func (a *ApplicationConfig) GetWaitingRoom() bool {
+	return a.WaitingRoom
+}
+
+// SetWaitingRoom sets whether the participants of the hosted
+// meetings wait until the host admits them
+func (a *ApplicationConfig) SetWaitingRoom(v bool) {
+	a.WaitingRoom = v
+}
+
 // IsPersistentConfiguration returns the setting value to persist the configuration file in the device
 func (a *ApplicationConfig) IsPersistentConfiguration() bool {
 	return a.persistentMode
@@ -382,6 +499,84 @@ func (a *ApplicationConfig) EnableLogs(v bool) {
 	a.LogsEnabled = v
 }
 
+// SetLogLevel sets the level of the logs, as understood by logrus
+func (a *ApplicationConfig) SetLogLevel(v string) {
+	a.LogLevel = v
+}
+
+// GetLogLevel returns the level of the logs. An empty
+// value means the default one, which is informative
+func (a *ApplicationConfig) GetLogLevel() string {
+	return a.LogLevel
+}
+
+// SetLanguage sets the language of Wahay, as a tag like "es"
+func (a *ApplicationConfig) SetLanguage(v string) {
+	a.Language = v
+}
+
+// GetLanguage returns the language of Wahay. An empty
+// value means the language of the system
+func (a *ApplicationConfig) GetLanguage() string {
+	return a.Language
+}
+
+// SetFontScale sets the size of the text of the windows, as a percentage
+func (a *ApplicationConfig) SetFontScale(v int) {
+	a.FontScale = v
+}
+
+// GetFontScale returns the size of the text of the windows, as a
+// percentage. When it's not set the text has its normal size
+func (a *ApplicationConfig) GetFontScale() int {
+	if a.FontScale <= 0 {
+		return 100
+	}
+	return a.FontScale
+}
+
+// SetTheme sets the theme of the windows
+func (a *ApplicationConfig) SetTheme(v string) {
+	a.Theme = v
+}
+
+// GetTheme returns the theme of the windows. When it's empty
+// the windows follow the theme of the system
+func (a *ApplicationConfig) GetTheme() string {
+	return a.Theme
+}
+
+// IsNotificationEnabled returns true unless the user has
+// turned off the notifications of the given event
+func (a *ApplicationConfig) IsNotificationEnabled(event string) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	for _, e := range a.DisabledNotifications {
+		if e == event {
+			return false
+		}
+	}
+	return true
+}
+
+// SetNotificationEnabled turns on or off the notifications of the given event
+func (a *ApplicationConfig) SetNotificationEnabled(event string, v bool) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []string{}
+	for _, e := range a.DisabledNotifications {
+		if e != event {
+			result = append(result, e)
+		}
+	}
+	if !v {
+		result = append(result, event)
+	}
+	a.DisabledNotifications = result
+}
+
 // GetRawLogFile returns the configured value for the file to write logs
 func (a *ApplicationConfig) GetRawLogFile() string {
 	return a.RawLogFile
@@ -412,6 +607,279 @@ func (a *ApplicationConfig) GetPortMumble() string {
 	return a.PortMumble
 }
 
+// SetMumbleListenerPort sets the local port of the Mumble server
+func (a *ApplicationConfig) SetMumbleListenerPort(v string) {
+	a.MumbleListenerPort = v
+}
+
+// GetMumbleListenerPort returns the local port of the Mumble server of
+// the hosted meetings. When it's empty a random free port is used
+func (a *ApplicationConfig) GetMumbleListenerPort() string {
+	return a.MumbleListenerPort
+}
+
+// SetOnionPortMappings sets the other ports published by hosted meetings
+func (a *ApplicationConfig) SetOnionPortMappings(v []string) {
+	a.OnionPortMappings = v
+}
+
+// GetOnionPortMappings returns the other ports published by the onion
+// service of hosted meetings, like "8181:18181", with the port of the
+// meeting and the local port
+func (a *ApplicationConfig) GetOnionPortMappings() []string {
+	return a.OnionPortMappings
+}
+
+// SetWebhookURL sets the URL notified when a hosted meeting starts
+func (a *ApplicationConfig) SetWebhookURL(v string) {
+	a.WebhookURL = v
+}
+
+// GetWebhookURL returns the URL notified when a hosted meeting starts
+func (a *ApplicationConfig) GetWebhookURL() string {
+	return a.WebhookURL
+}
+
+// SetFileSharing sets the specified value to share files in hosted meetings
+func (a *ApplicationConfig) SetFileSharing(v bool) {
+	a.FileSharing = v
+}
+
+// GetFileSharing returns the setting value to share files in hosted meetings
+func (a *ApplicationConfig) GetFileSharing() bool {
+	return a.FileSharing
+}
+
+// SetFileSharingHostOnly sets if only the host shares files in hosted meetings
+func (a *ApplicationConfig) SetFileSharingHostOnly(v bool) {
+	a.FileSharingHostOnly = v
+}
+
+// GetFileSharingHostOnly returns if only the host shares files in hosted meetings
+func (a *ApplicationConfig) GetFileSharingHostOnly() bool {
+	return a.FileSharingHostOnly
+}
+
+// SetEmbedCertificate sets if the certificate of hosted meetings goes in the invitation
+func (a *ApplicationConfig) SetEmbedCertificate(v bool) {
+	a.EmbedCertificate = v
+}
+
+// GetEmbedCertificate returns if the certificate of hosted meetings goes in the invitation
+func (a *ApplicationConfig) GetEmbedCertificate() bool {
+	return a.EmbedCertificate
+}
+
+// SetOnionOnlyCertServer sets if the certificate server of hosted
+// meetings can only be reached through the onion service
+func (a *ApplicationConfig) SetOnionOnlyCertServer(v bool) {
+	a.OnionOnlyCertServer = v
+}
+
+// GetOnionOnlyCertServer returns if the certificate server of hosted
+// meetings can only be reached through the onion service
+func (a *ApplicationConfig) GetOnionOnlyCertServer() bool {
+	return a.OnionOnlyCertServer
+}
+
+// SetClientAuthInvitees sets how many participants can reach hosted meetings
+func (a *ApplicationConfig) SetClientAuthInvitees(v int) {
+	a.ClientAuthInvitees = v
+}
+
+// GetClientAuthInvitees returns how many participants can reach hosted
+// meetings, each one with the key in their invitation. Zero means that
+// anyone knowing the meeting ID can reach them
+func (a *ApplicationConfig) GetClientAuthInvitees() int {
+	return a.ClientAuthInvitees
+}
+
+// SetMaxParticipants sets how many participants can be in hosted meetings at once
+func (a *ApplicationConfig) SetMaxParticipants(v int) {
+	a.MaxParticipants = v
+}
+
+// GetMaxParticipants returns how many participants can be in hosted
+// meetings at once. Zero doesn't limit them
+func (a *ApplicationConfig) GetMaxParticipants() int {
+	return a.MaxParticipants
+}
+
+// SetMaxBandwidth sets the kilobits per second that every participant
+// of hosted meetings can use
+func (a *ApplicationConfig) SetMaxBandwidth(v int) {
+	a.MaxBandwidth = v
+}
+
+// GetMaxBandwidth returns the kilobits per second that every participant
+// of hosted meetings can use. Zero only keeps the limit of the audio preset
+func (a *ApplicationConfig) GetMaxBandwidth() int {
+	return a.MaxBandwidth
+}
+
+// SetIdleKickTimeout sets the minutes after which the idle participants
+// are removed from hosted meetings
+func (a *ApplicationConfig) SetIdleKickTimeout(v int) {
+	a.IdleKickTimeout = v
+}
+
+// GetIdleKickTimeout returns the minutes after which the idle participants
+// are removed from hosted meetings. Zero keeps them in the meeting
+func (a *ApplicationConfig) GetIdleKickTimeout() int {
+	return a.IdleKickTimeout
+}
+
+// SetInvitationSender sets the channel used to send invitations directly
+func (a *ApplicationConfig) SetInvitationSender(v InvitationSenderSettings) {
+	a.InvitationSender = v
+}
+
+// GetInvitationSender returns the channel used to send invitations directly
+func (a *ApplicationConfig) GetInvitationSender() InvitationSenderSettings {
+	return a.InvitationSender
+}
+
+// SetTorIdleTimeout sets the minutes Tor keeps running without meetings
+func (a *ApplicationConfig) SetTorIdleTimeout(v int) {
+	a.TorIdleTimeout = v
+}
+
+// GetTorIdleTimeout returns the minutes Tor keeps running without meetings.
+// Zero means that Tor keeps running until Wahay is closed
+func (a *ApplicationConfig) GetTorIdleTimeout() int {
+	return a.TorIdleTimeout
+}
+
+// SetTorBridges sets the bridge lines used for connecting to Tor
+func (a *ApplicationConfig) SetTorBridges(v []string) {
+	a.TorBridges = v
+}
+
+// GetTorBridges returns the bridge lines used for connecting to Tor
+// when the network blocks it. Without bridges Tor connects directly
+func (a *ApplicationConfig) GetTorBridges() []string {
+	return a.TorBridges
+}
+
+// SetTorControlAddress sets the control port of the running Tor to use
+func (a *ApplicationConfig) SetTorControlAddress(v string) {
+	a.TorControlAddress = v
+}
+
+// GetTorControlAddress returns the host:port of the control port of a
+// running Tor to use instead of starting our own. When it's empty the
+// system Tor and Tor Browser are looked for in their default ports
+func (a *ApplicationConfig) GetTorControlAddress() string {
+	return a.TorControlAddress
+}
+
+// SetTorControlPassword sets the password of the Tor control port
+func (a *ApplicationConfig) SetTorControlPassword(v string) {
+	a.TorControlPassword = v
+}
+
+// GetTorControlPassword returns the password of the Tor control port,
+// for when Tor is configured with HashedControlPassword
+func (a *ApplicationConfig) GetTorControlPassword() string {
+	return a.TorControlPassword
+}
+
+// SetTorPorts sets the SOCKS and control ports of the Tor started by
+// Wahay. Zero lets Wahay choose a free port
+func (a *ApplicationConfig) SetTorPorts(socksPort, controlPort int) {
+	a.TorSocksPort = socksPort
+	a.TorControlPort = controlPort
+}
+
+// GetTorSocksPort returns the SOCKS port of the Tor started by Wahay,
+// or zero when Wahay chooses it
+func (a *ApplicationConfig) GetTorSocksPort() int {
+	return a.TorSocksPort
+}
+
+// GetTorControlPort returns the control port of the Tor started by
+// Wahay, or zero when Wahay chooses it
+func (a *ApplicationConfig) GetTorControlPort() int {
+	return a.TorControlPort
+}
+
+// SetAudioPreset sets the audio preset used in the meetings
+func (a *ApplicationConfig) SetAudioPreset(v string) {
+	a.AudioPreset = v
+}
+
+// GetAudioPreset returns the audio preset used in the meetings. An
+// empty value means that it's chosen from the latency of the meeting
+func (a *ApplicationConfig) GetAudioPreset() string {
+	return a.AudioPreset
+}
+
+// SetAudioSettings sets the Mumble audio settings tuned by the user
+func (a *ApplicationConfig) SetAudioSettings(v AudioSettings) {
+	a.AudioSettings = v
+}
+
+// GetAudioSettings returns the Mumble audio settings tuned by the user
+func (a *ApplicationConfig) GetAudioSettings() AudioSettings {
+	return a.AudioSettings
+}
+
+// SetIsolatedProfiles sets if every meeting uses its own Mumble configuration
+func (a *ApplicationConfig) SetIsolatedProfiles(v bool) {
+	a.IsolatedProfiles = v
+}
+
+// GetIsolatedProfiles returns true if every meeting uses its own Mumble
+// configuration, which is wiped when the meeting is closed
+func (a *ApplicationConfig) GetIsolatedProfiles() bool {
+	return a.IsolatedProfiles
+}
+
+// SetKeepIdentityFiles sets if the files with the persistent identity
+// are kept when Wahay is closed
+func (a *ApplicationConfig) SetKeepIdentityFiles(v bool) {
+	a.KeepIdentityFiles = v
+}
+
+// GetKeepIdentityFiles returns true if the files with the persistent
+// identity are kept when Wahay is closed, instead of being wiped
+func (a *ApplicationConfig) GetKeepIdentityFiles() bool {
+	return a.KeepIdentityFiles
+}
+
+// SetKeepMeetingHistory sets if the meetings joined are remembered
+func (a *ApplicationConfig) SetKeepMeetingHistory(v bool) {
+	a.KeepMeetingHistory = v
+}
+
+// GetKeepMeetingHistory returns true if the meetings joined are
+// remembered, for joining them again
+func (a *ApplicationConfig) GetKeepMeetingHistory() bool {
+	return a.KeepMeetingHistory
+}
+
+// SetCertificateKey sets the key algorithm of the Mumble certificate
+func (a *ApplicationConfig) SetCertificateKey(v string) {
+	a.CertificateKey = v
+}
+
+// GetCertificateKey returns the key algorithm of the Mumble
+// certificate. An empty value means the default algorithm
+func (a *ApplicationConfig) GetCertificateKey() string {
+	return a.CertificateKey
+}
+
+// SetClientIdentity sets the certificate and key used by Mumble in all the meetings
+func (a *ApplicationConfig) SetClientIdentity(v string) {
+	a.ClientIdentity = v
+}
+
+// GetClientIdentity returns the certificate and key used by Mumble in all
+// the meetings. An empty value means that a new one is used every time
+func (a *ApplicationConfig) GetClientIdentity() string {
+	return a.ClientIdentity
+}
+
 // GetDefaultLogFile returns the default path for the log file
 func GetDefaultLogFile() string {
 	return filepath.Join(Dir(), GetDefaultLogFileName())
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BuildInfo describes the build of Wahay that is running
+type BuildInfo struct {
+	Commit      string
+	ShortCommit string
+	Tag         string
+	Timestamp   string
+}
+
+// CurrentBuild is the build of Wahay that is running. It's set by
+// the main package, where the build information is available
+var CurrentBuild = BuildInfo{
+	Commit:      "UNKNOWN",
+	ShortCommit: "UNKNOWN",
+	Tag:         "(no tag)",
+	Timestamp:   "UNKNOWN",
+}
+
+// diagnosticEnvironment are the environment variables that help
+// understanding the desktop where Wahay runs without identifying the user
+var diagnosticEnvironment = []string{
+	"DESKTOP_SESSION",
+	"GDK_BACKEND",
+	"LANG",
+	"LC_ALL",
+	"QT_QPA_PLATFORM",
+	"XDG_CURRENT_DESKTOP",
+	"XDG_SESSION_TYPE",
+}
+
+// diagnosticSecrets are the values of the configuration that are never
+// included in a diagnostic bundle, not even in a redacted form
+var diagnosticSecrets = map[string]bool{
+	"UniqueConfigurationID": true,
+	"WebhookURL":            true,
+	"ClientIdentity":        true,
+	"TrustedCertificates":   true,
+	"MeetingRooms":          true,
+	"ScheduledMeetings":     true,
+	"MeetingHistory":        true,
+	"AddressBook":           true,
+	"Command":               true,
+	"SMTPServer":            true,
+	"SMTPUsername":          true,
+	"SMTPPassword":          true,
+	"SMTPFrom":              true,
+	"TorBridges":            true,
+	"TorControlPassword":    true,
+}
+
+const (
+	// maxDiagnosticLogSize is the amount of the most recent log
+	// messages from every file that are included in the bundle
+	maxDiagnosticLogSize = 5 * 1024 * 1024
+
+	diagnosticReadme = `This archive was created by Wahay to be attached to a bug report.
+
+It contains the version of Wahay, some details about the system, like
+the versions of Tor and Mumble, how far Tor got connecting to the
+network and what Mumble wrote the last time it crashed, the
+configuration without passwords or other secrets and the logs written
+in this computer. Onion addresses, fingerprints, usernames
+and tokens have been replaced in the logs by random identifiers. The
+most recent logs are in logs/recent.txt, even when they are not
+written to a file.
+
+You can open the files and check their content before sharing them.
+`
+)
+
+// CreateDiagnosticBundle writes to w a zip archive with the information
+// needed to understand a problem: the build, the environment, the
+// configuration without secrets and the redacted logs. The given details
+// are added to the environment, for example the state of Tor and Mumble
+func CreateDiagnosticBundle(w io.Writer, a *ApplicationConfig, details map[string]string) error {
+	r := newRedactor()
+	z := zip.NewWriter(w)
+
+	err := addDiagnosticFile(z, "README.txt", []byte(diagnosticReadme))
+	if err == nil {
+		err = addDiagnosticFile(z, "version.txt", []byte(diagnosticVersion()))
+	}
+	if err == nil {
+		err = addDiagnosticFile(z, "environment.txt", []byte(r.redact(diagnosticEnvironmentText(a, details))))
+	}
+	if err == nil {
+		err = addDiagnosticConfiguration(z, a, r)
+	}
+	if err == nil {
+		err = addDiagnosticRecentLogs(z, r)
+	}
+	if err == nil {
+		err = addDiagnosticLogs(z, a, r)
+	}
+	if err != nil {
+		_ = z.Close()
+		return err
+	}
+
+	return z.Close()
+}
+
+func addDiagnosticFile(z *zip.Writer, name string, content []byte) error {
+	f, err := z.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(content)
+	return err
+}
+
+func diagnosticVersion() string {
+	return fmt.Sprintf("Wahay\ncommit: %s (%s)\ntag: %s\nbuilt: %s\n",
+		CurrentBuild.ShortCommit, CurrentBuild.Commit, CurrentBuild.Tag, CurrentBuild.Timestamp)
+}
+
+func diagnosticEnvironmentText(a *ApplicationConfig, details map[string]string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "os: %s\n", runtime.GOOS)
+	fmt.Fprintf(&b, "arch: %s\n", runtime.GOARCH)
+	fmt.Fprintf(&b, "go: %s\n", runtime.Version())
+	fmt.Fprintf(&b, "distribution: %s\n", osReleaseName())
+	fmt.Fprintf(&b, "persistent configuration: %v\n", a.IsPersistentConfiguration())
+	fmt.Fprintf(&b, "logs enabled: %v\n", a.IsLogsEnabled())
+
+	for _, k := range diagnosticEnvironment {
+		fmt.Fprintf(&b, "%s: %s\n", k, os.Getenv(k))
+	}
+
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, details[k])
+	}
+
+	return withoutHomeDir(b.String())
+}
+
+func osReleaseName() string {
+	content, err := ioutil.ReadFile("/etc/os-release")
+	if err != nil {
+		return "unknown"
+	}
+
+	for _, l := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(l, "PRETTY_NAME=") {
+			return strings.Trim(strings.TrimPrefix(l, "PRETTY_NAME="), `"`)
+		}
+	}
+
+	return "unknown"
+}
+
+// withoutHomeDir replaces the home directory of the user, since it
+// usually contains the name of the user
+func withoutHomeDir(s string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || len(home) < 2 {
+		return s
+	}
+
+	return strings.Replace(s, home, "~", -1)
+}
+
+func addDiagnosticConfiguration(z *zip.Writer, a *ApplicationConfig, r *redactor) error {
+	content, err := a.serialize()
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	err = json.Unmarshal(content, &values)
+	if err != nil {
+		return err
+	}
+
+	sanitizeDiagnosticValues(values, r)
+
+	content, err = json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return addDiagnosticFile(z, "configuration.json", content)
+}
+
+func sanitizeDiagnosticValues(values map[string]interface{}, r *redactor) {
+	for k, v := range values {
+		if diagnosticSecrets[k] {
+			if v != nil && v != "" {
+				values[k] = "[removed]"
+			}
+			continue
+		}
+
+		switch value := v.(type) {
+		case map[string]interface{}:
+			sanitizeDiagnosticValues(value, r)
+		case string:
+			values[k] = withoutHomeDir(r.redact(value))
+		}
+	}
+}
+
+// addDiagnosticRecentLogs adds the log entries kept in memory, which
+// are the only ones available when the logs are not written to a file
+func addDiagnosticRecentLogs(z *zip.Writer, r *redactor) error {
+	var b strings.Builder
+	for _, e := range RecentLogEntries() {
+		b.WriteString(withoutHomeDir(r.redact(e.String())))
+		b.WriteString("\n")
+	}
+
+	return addDiagnosticFile(z, "logs/recent.txt", []byte(b.String()))
+}
+
+func addDiagnosticLogs(z *zip.Writer, a *ApplicationConfig, r *redactor) error {
+	logFile := a.GetRawLogFile()
+	if logFile == "" {
+		logFile = GetDefaultLogFile()
+	}
+
+	if st, err := os.Stat(logFile); err == nil && st.IsDir() {
+		logFile = filepath.Join(logFile, GetDefaultLogFileName())
+	}
+
+	files := []string{logFile}
+	for i := 1; i <= DefaultLogFileBackups; i++ {
+		files = append(files, fmt.Sprintf("%s.%d", logFile, i))
+	}
+
+	for _, f := range files {
+		err := addDiagnosticLog(z, f, r)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDiagnosticLog adds the most recent messages of the given log
+// file. They are redacted again, because the logs could have been
+// written when the redaction was disabled
+func addDiagnosticLog(z *zip.Writer, fileName string, r *redactor) error {
+	f, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if st.Size() > maxDiagnosticLogSize {
+		_, err = f.Seek(st.Size()-maxDiagnosticLogSize, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := z.Create(filepath.Join("logs", filepath.Base(fileName)))
+	if err != nil {
+		return err
+	}
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		_, err = fmt.Fprintln(out, withoutHomeDir(r.redact(s.Text())))
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
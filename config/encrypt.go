@@ -12,6 +12,8 @@ import (
 	"sync"
 
 	"golang.org/x/crypto/scrypt"
+
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 // EncryptionResult is a representation of a result provided
@@ -98,10 +100,18 @@ func (k *keySupplierWrap) Invalidate() {
 	defer k.Unlock()
 
 	k.haveKeys = false
+	wipeBytes(k.key)
+	wipeBytes(k.mac)
 	k.key = []byte{}
 	k.mac = []byte{}
 }
 
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func (k *keySupplierWrap) LastAttemptFailed() {
 	k.lastAttemptFailed = true
 }
@@ -260,10 +270,10 @@ func encryptConfigContent(content string, p *EncryptionParameters, k KeySupplier
 }
 
 var (
-	errorEncryptionDecryptFailed = errors.New("decryption failed")
-	errorEncryptionBadFile       = errors.New("invalid or corrupted file")
-	errorEncryptionNoEncrypted   = errors.New("the configuration file data is not encrypted")
-	errorEncryptionNoPassword    = errors.New("no password supplied to decrypt the config file")
+	errorEncryptionDecryptFailed = failure.New("config.decryption-failed", failure.CategoryConfiguration, "decryption failed", "check the password of the configuration")
+	errorEncryptionBadFile       = failure.New("config.corrupted-file", failure.CategoryConfiguration, "invalid or corrupted file", "")
+	errorEncryptionNoEncrypted   = failure.New("config.not-encrypted", failure.CategoryConfiguration, "the configuration file data is not encrypted", "")
+	errorEncryptionNoPassword    = failure.New("config.no-password", failure.CategoryConfiguration, "no password supplied to decrypt the config file", "")
 )
 
 func encryptData(key, macKey, nonce []byte, plain string) []byte {
@@ -347,3 +357,22 @@ func GenerateKeysBasedOnPassword(password string, params EncryptionParameters) E
 
 	return r
 }
+
+// EncryptWithPassword encrypts the given content with a key derived from
+// the password, in the same format used for the configuration file
+func EncryptWithPassword(content []byte, password string) ([]byte, error) {
+	p := newEncryptionParameters()
+	return encryptConfigContent(string(content), &p, passwordKeySupplier(password))
+}
+
+// DecryptWithPassword decrypts content encrypted with EncryptWithPassword
+func DecryptWithPassword(content []byte, password string) ([]byte, error) {
+	res, _, err := decryptConfigContent(content, passwordKeySupplier(password))
+	return res, err
+}
+
+func passwordKeySupplier(password string) KeySupplier {
+	return CreateKeySupplier(func(p EncryptionParameters, _ bool) EncryptionResult {
+		return GenerateKeysBasedOnPassword(password, p)
+	})
+}
@@ -83,6 +83,11 @@ func TorDir() string {
 	return filepath.Join(Dir(), "tor")
 }
 
+// TranscriptsDir returns the directory where the chat transcripts are kept
+func TranscriptsDir() string {
+	return filepath.Join(Dir(), "transcripts")
+}
+
 // SystemConfigDir returns the application data directory, valid on both windows and posix systems
 func SystemConfigDir() string {
 	return XdgConfigHome()
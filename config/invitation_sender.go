@@ -0,0 +1,23 @@
+package config
+
+// InvitationSenderSettings is the configuration of the channel used to
+// send the invitations directly from Wahay. Only the fields used by
+// the chosen kind of sender are taken into account
+type InvitationSenderSettings struct {
+	Kind string
+
+	// Command is the command line of a local program that
+	// receives the invitation in its standard input
+	Command string
+
+	// The SMTP server is always reached through Tor
+	SMTPServer   string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+}
+
+// IsEnabled returns true if a sender has been configured
+func (s InvitationSenderSettings) IsEnabled() bool {
+	return s.Kind != ""
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/secrets"
+)
+
+// keyringSecret is the name of the secrets of the configuration in the keyring
+const keyringSecret = "configuration"
+
+// keyringSecrets are the parts of the configuration that are kept in the
+// keyring of the system, instead of the file, when the file is not
+// encrypted and the user wants the secrets in the keyring
+type keyringSecrets struct {
+	ClientIdentity    string                `json:",omitempty"`
+	MeetingRooms      []MeetingRoom         `json:",omitempty"`
+	ScheduledMeetings []ScheduledMeeting    `json:",omitempty"`
+	MeetingHistory    []MeetingHistoryEntry `json:",omitempty"`
+	AddressBook       []AddressBookEntry    `json:",omitempty"`
+	ChatTranscriptKey []byte                `json:",omitempty"`
+}
+
+// SetSecretStore sets the keyring of the system, where the
+// secrets can be kept instead of in the configuration file
+func (a *ApplicationConfig) SetSecretStore(s secrets.Store) {
+	a.secretStore = s
+}
+
+// HasSecretStore returns true if the keyring of the system can be used
+func (a *ApplicationConfig) HasSecretStore() bool {
+	return a.secretStore != nil
+}
+
+// SetSecretsInKeyring sets if the secrets are kept in the keyring
+// of the system, when the configuration file is not encrypted
+func (a *ApplicationConfig) SetSecretsInKeyring(v bool) {
+	a.SecretsInKeyring = v
+}
+
+// GetSecretsInKeyring returns true if the secrets are kept in the
+// keyring of the system, when the configuration file is not encrypted
+func (a *ApplicationConfig) GetSecretsInKeyring() bool {
+	return a.SecretsInKeyring
+}
+
+// usesKeyring returns true when the secrets go to the keyring. When
+// the file is encrypted they are kept in it, like the rest of the values
+func (a *ApplicationConfig) usesKeyring() bool {
+	return a.SecretsInKeyring && a.secretStore != nil && !a.ShouldEncrypt()
+}
+
+// takeKeyringSecrets removes the secrets from the configuration,
+// so they are not written in the file
+func (a *ApplicationConfig) takeKeyringSecrets() keyringSecrets {
+	s := keyringSecrets{
+		ClientIdentity:    a.ClientIdentity,
+		MeetingRooms:      a.MeetingRooms,
+		ScheduledMeetings: a.ScheduledMeetings,
+		MeetingHistory:    a.MeetingHistory,
+		AddressBook:       a.AddressBook,
+		ChatTranscriptKey: a.ChatTranscriptKey,
+	}
+
+	a.ClientIdentity = ""
+	a.MeetingRooms = nil
+	a.ScheduledMeetings = nil
+	a.MeetingHistory = nil
+	a.AddressBook = nil
+	a.ChatTranscriptKey = nil
+
+	return s
+}
+
+func (a *ApplicationConfig) restoreKeyringSecrets(s keyringSecrets) {
+	a.ClientIdentity = s.ClientIdentity
+	a.MeetingRooms = s.MeetingRooms
+	a.ScheduledMeetings = s.ScheduledMeetings
+	a.MeetingHistory = s.MeetingHistory
+	a.AddressBook = s.AddressBook
+	a.ChatTranscriptKey = s.ChatTranscriptKey
+}
+
+// saveKeyringSecrets saves the secrets in the keyring and removes
+// them from the configuration. They have to be restored later
+func (a *ApplicationConfig) saveKeyringSecrets() (keyringSecrets, error) {
+	s := a.takeKeyringSecrets()
+
+	content, err := json.Marshal(s)
+	if err != nil {
+		return s, err
+	}
+
+	return s, a.secretStore.Save(keyringSecret, string(content))
+}
+
+// loadKeyringSecrets gets back the secrets saved in the keyring. If the
+// keyring doesn't have them, the configuration is used without them
+func (a *ApplicationConfig) loadKeyringSecrets() {
+	content, ok := a.secretStore.Lookup(keyringSecret)
+	if !ok {
+		log.Warning("The secrets of the configuration are not in the keyring of the system")
+		return
+	}
+
+	s := keyringSecrets{}
+	if err := json.Unmarshal([]byte(content), &s); err != nil {
+		log.Warning("The secrets of the configuration in the keyring of the system are not valid")
+		return
+	}
+
+	a.restoreKeyringSecrets(s)
+}
+
+// ForgetKeyringSecrets removes the secrets of the configuration from the
+// keyring of the system, when the user doesn't want them there anymore
+func (a *ApplicationConfig) ForgetKeyringSecrets() {
+	if a.secretStore != nil {
+		_ = a.secretStore.Forget(keyringSecret)
+	}
+}
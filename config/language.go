@@ -0,0 +1,41 @@
+package config
+
+import (
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Languages are the languages Wahay is translated to
+var Languages = []language.Tag{
+	language.English,
+	language.Spanish,
+	language.Swedish,
+	language.Arabic,
+	language.French,
+}
+
+var languageLock sync.Mutex
+var chosenLanguage = language.Und
+
+// UseLanguage makes Wahay and Mumble use the given language, as a tag like
+// "es", instead of the one of the system. With an empty or invalid value
+// the language of the system is used again
+func UseLanguage(lang string) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		tag = language.Und
+	}
+
+	languageLock.Lock()
+	defer languageLock.Unlock()
+
+	chosenLanguage = tag
+}
+
+func usedLanguage() (language.Tag, bool) {
+	languageLock.Lock()
+	defer languageLock.Unlock()
+
+	return chosenLanguage, chosenLanguage != language.Und
+}
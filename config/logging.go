@@ -0,0 +1,365 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// All the packages of Wahay log through logrus, and every entry is tagged
+// with the subsystem it comes from. The subsystem is taken from the
+// "subsystem" field when it's given, or from the package of the caller.
+// Each subsystem can have its own level, given in the command line as:
+//
+//   --log-levels tor=debug,hosting=warning
+//
+// The messages written with the standard log package, like the ones
+// of the Mumble server, are sent to logrus as part of hosting.
+//
+// Unless the user asks for it, the entries are redacted before they
+// are written, as described in redaction.go. The most recent entries
+// are also kept in memory, as described in memory_log.go.
+
+const (
+	// SubsystemTor is the subsystem of the Tor integration
+	SubsystemTor = "tor"
+
+	// SubsystemClient is the subsystem of the Mumble client integration
+	SubsystemClient = "client"
+
+	// SubsystemHosting is the subsystem of the meeting servers
+	SubsystemHosting = "hosting"
+
+	// SubsystemGUI is the subsystem of the user interface
+	SubsystemGUI = "gui"
+
+	// SubsystemField is the name of the field with the subsystem of an entry
+	SubsystemField = "subsystem"
+
+	// DefaultMaxLogFileSize is the size that a log file can reach before it's rotated
+	DefaultMaxLogFileSize = 10 * 1024 * 1024
+
+	// DefaultLogFileBackups is the amount of rotated log files that are kept
+	DefaultLogFileBackups = 3
+
+	wahayPackagePrefix = "github.com/digitalautonomy/wahay/"
+)
+
+// ErrInvalidLogLevel is an error to be trown when the
+// log levels given in the command line can't be understood
+var ErrInvalidLogLevel = failure.New("config.invalid-log-level", failure.CategoryConfiguration,
+	"the log level is not valid", "use the format subsystem=level, for example: tor=debug,gui=warning")
+
+// LoggingOptions describes how the logs of Wahay are written
+type LoggingOptions struct {
+	Level        log.Level
+	Levels       map[string]log.Level
+	JSON         bool
+	ReportCaller bool
+
+	// Unredacted keeps the personal information in the logs, and
+	// should only be used when debugging in the computer of a developer
+	Unredacted bool
+
+	// BufferSize is the amount of entries kept in memory, zero disables it
+	BufferSize int
+}
+
+// ParseLogLevels returns the levels given for each subsystem
+// in the format "subsystem=level,subsystem=level"
+func ParseLogLevels(v string) (map[string]log.Level, error) {
+	result := map[string]log.Level{}
+
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, ErrInvalidLogLevel.Wrapf("%q", s)
+		}
+
+		l, err := log.ParseLevel(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, ErrInvalidLogLevel.Wrap(err)
+		}
+
+		result[strings.TrimSpace(parts[0])] = l
+	}
+
+	return result, nil
+}
+
+// InitLogging configures the logger used by all the packages of Wahay
+func InitLogging(o LoggingOptions) {
+	var inner log.Formatter = &log.TextFormatter{}
+	if o.JSON {
+		inner = &log.JSONFormatter{}
+	}
+
+	// The logger must let through the entries of the most
+	// verbose subsystem, the others are filtered by the formatter
+	level := o.Level
+	for _, l := range o.Levels {
+		if l > level {
+			level = l
+		}
+	}
+
+	log.SetLevel(level)
+	log.SetReportCaller(true)
+	f := &subsystemFormatter{
+		inner:        inner,
+		level:        o.Level,
+		levels:       o.Levels,
+		reportCaller: o.ReportCaller,
+	}
+
+	if !o.Unredacted {
+		f.redactor = newRedactor()
+	}
+
+	currentFormatter = f
+	log.SetFormatter(f)
+	recentLogs.resize(o.BufferSize)
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(LogWriter(SubsystemHosting))
+}
+
+// currentFormatter is the one set by InitLogging
+var currentFormatter *subsystemFormatter
+
+// SetDefaultLogLevel changes the level of the subsystems that don't have
+// one of their own, once the level chosen in the settings is known
+func SetDefaultLogLevel(l log.Level) {
+	f := currentFormatter
+	if f == nil {
+		log.SetLevel(l)
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.level = l
+
+	level := l
+	for _, sl := range f.levels {
+		if sl > level {
+			level = sl
+		}
+	}
+	log.SetLevel(level)
+}
+
+// ApplyLogLevel uses the level of the logs chosen in the settings,
+// unless Wahay was started with a level in the command line
+func (a *ApplicationConfig) ApplyLogLevel() {
+	if *Debug || *Trace {
+		return
+	}
+
+	level := log.InfoLevel
+	if l, err := log.ParseLevel(a.GetLogLevel()); err == nil {
+		level = l
+	}
+
+	SetDefaultLogLevel(level)
+}
+
+// LogWriter returns a writer that logs every line written
+// to it as an informative message of the given subsystem
+func LogWriter(subsystem string) io.Writer {
+	return log.WithField(SubsystemField, subsystem).WriterLevel(log.InfoLevel)
+}
+
+type subsystemFormatter struct {
+	lock         sync.RWMutex
+	inner        log.Formatter
+	level        log.Level
+	levels       map[string]log.Level
+	reportCaller bool
+	redactor     *redactor
+}
+
+func (f *subsystemFormatter) Format(entry *log.Entry) ([]byte, error) {
+	subsystem := entrySubsystem(entry)
+
+	f.lock.RLock()
+	level, ok := f.levels[subsystem]
+	if !ok {
+		level = f.level
+	}
+	f.lock.RUnlock()
+
+	if entry.Level > level {
+		return nil, nil
+	}
+
+	e := *entry
+	e.Data = make(log.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		// The errors of Wahay are identified by their code, so the
+		// same problem can be found in the logs of different users
+		if err, ok := v.(error); ok {
+			if code := failure.CodeOf(err); code != "" {
+				e.Data[k+"_code"] = string(code)
+			}
+		}
+
+		if f.redactor != nil {
+			v = f.redactor.redactField(k, v)
+		}
+		e.Data[k] = v
+	}
+
+	if f.redactor != nil {
+		e.Message = f.redactor.redact(e.Message)
+	}
+
+	if subsystem != "" {
+		e.Data[SubsystemField] = subsystem
+	}
+
+	// The caller is always needed for finding the subsystem,
+	// but it's only shown when the user asks for it
+	if !f.reportCaller {
+		e.Caller = nil
+	}
+
+	recentLogs.record(&e, subsystem)
+
+	return f.inner.Format(&e)
+}
+
+func entrySubsystem(entry *log.Entry) string {
+	if s, ok := entry.Data[SubsystemField].(string); ok {
+		return s
+	}
+
+	if entry.Caller == nil {
+		return ""
+	}
+
+	// The function looks like github.com/digitalautonomy/wahay/tor.(*instance).Start
+	name := entry.Caller.Function
+	if !strings.HasPrefix(name, wahayPackagePrefix) {
+		return ""
+	}
+
+	name = strings.TrimPrefix(name, wahayPackagePrefix)
+	if i := strings.IndexAny(name, "./"); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+// RotatingFile is a log file that is rotated when it reaches a maximum
+// size. The rotated files are kept with the suffixes .1, .2 and so on,
+// where .1 is the most recent one
+type RotatingFile struct {
+	sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+// OpenRotatingFile opens the given log file for appending new messages
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:    filepath.Clean(path),
+		maxSize: maxSize,
+		backups: backups,
+	}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	st, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = st.Size()
+
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(f.backupName(f.backups))
+	for i := f.backups - 1; i > 0; i-- {
+		_ = os.Rename(f.backupName(i), f.backupName(i+1))
+	}
+
+	if f.backups > 0 {
+		err = os.Rename(f.path, f.backupName(1))
+	} else {
+		err = os.Remove(f.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close closes the current log file
+func (f *RotatingFile) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// maxMeetingHistory keeps the configuration small
+const maxMeetingHistory = 50
+
+// MeetingHistoryEntry is a meeting the user has been in. It has what is
+// needed for joining the meeting again, which tells where the user has
+// been, so the history is only kept when the user wants it, and only
+// saved when the configuration file is encrypted with the master password
+type MeetingHistoryEntry struct {
+	Address     string
+	Port        int
+	Label       string
+	Fingerprint string `json:",omitempty"`
+
+	Username       string `json:",omitempty"`
+	Password       string `json:",omitempty"`
+	Token          string `json:",omitempty"`
+	CertificateURL string `json:",omitempty"`
+	Certificate    []byte `json:",omitempty"`
+	ClientAuth     string `json:",omitempty"`
+
+	// Room is the name of the meeting room, for the
+	// meetings the user has hosted, which are hosted again
+	Room string `json:",omitempty"`
+
+	LastJoined time.Time
+}
+
+func (e MeetingHistoryEntry) sameMeeting(other MeetingHistoryEntry) bool {
+	return strings.EqualFold(e.Address, other.Address) && e.Port == other.Port
+}
+
+// CanKeepMeetingHistory returns true when the user wants the history,
+// and it can be saved encrypted
+func (a *ApplicationConfig) CanKeepMeetingHistory() bool {
+	return a.GetKeepMeetingHistory() && a.CanSaveMeetingRooms()
+}
+
+// ListMeetingHistory returns the meetings in the history, starting with the last one
+func (a *ApplicationConfig) ListMeetingHistory() []MeetingHistoryEntry {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []MeetingHistoryEntry{}
+	result = append(result, a.MeetingHistory...)
+	return result
+}
+
+// AddToMeetingHistory remembers the meeting as joined now, replacing the
+// one in the same address. The label given before is kept, unless a new
+// one is given. The configuration has to be saved afterwards
+func (a *ApplicationConfig) AddToMeetingHistory(e MeetingHistoryEntry) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	e.LastJoined = time.Now()
+
+	result := []MeetingHistoryEntry{e}
+	for _, old := range a.MeetingHistory {
+		if old.sameMeeting(e) {
+			if e.Label == "" {
+				result[0].Label = old.Label
+			}
+			continue
+		}
+
+		if len(result) < maxMeetingHistory {
+			result = append(result, old)
+		}
+	}
+
+	a.MeetingHistory = result
+}
+
+// RenameInMeetingHistory changes the label of the meeting in
+// the given address. It returns false if there was no such meeting
+func (a *ApplicationConfig) RenameInMeetingHistory(address string, port int, label string) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	target := MeetingHistoryEntry{Address: address, Port: port}
+	for i := range a.MeetingHistory {
+		if a.MeetingHistory[i].sameMeeting(target) {
+			a.MeetingHistory[i].Label = label
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClearMeetingHistory forgets all the meetings in the history
+func (a *ApplicationConfig) ClearMeetingHistory() {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	a.MeetingHistory = nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// maxMeetingRooms keeps the configuration small
+const maxMeetingRooms = 20
+
+// MeetingRoom is a meeting the user hosts again and again, with the
+// same onion address and invitation. It has the private key of the onion
+// service and of the certificate of the meeting, so it's only saved when
+// the configuration file is encrypted with the master password
+type MeetingRoom struct {
+	Name           string
+	Port           int
+	OnionKey       string
+	Token          string
+	Certificate    string
+	CertificateKey string
+	Created        time.Time
+	LastUsed       time.Time `json:",omitempty"`
+
+	// SuperUserPassword is the password of the administrator
+	// of the Mumble server of the room
+	SuperUserPassword string `json:",omitempty"`
+
+	// WelcomeText is what the participants read when they join
+	// the meetings of the room, when the host has written it
+	WelcomeText string `json:",omitempty"`
+}
+
+// CanSaveMeetingRooms returns true when the configuration is saved
+// encrypted, or its secrets in the keyring of the system, which is
+// needed for keeping the keys of the rooms
+func (a *ApplicationConfig) CanSaveMeetingRooms() bool {
+	return a.IsPersistentConfiguration() && (a.ShouldEncrypt() || a.usesKeyring())
+}
+
+// ListMeetingRooms returns the saved rooms, starting with the ones used most recently
+func (a *ApplicationConfig) ListMeetingRooms() []MeetingRoom {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []MeetingRoom{}
+	result = append(result, a.MeetingRooms...)
+	return result
+}
+
+// MeetingRoom returns the saved room with the given name
+func (a *ApplicationConfig) MeetingRoom(name string) (MeetingRoom, bool) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	for _, r := range a.MeetingRooms {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+
+	return MeetingRoom{}, false
+}
+
+// SaveMeetingRoom remembers the room as used now, replacing the one with
+// the same name. The configuration has to be saved afterwards for the
+// room to be remembered in the next runs
+func (a *ApplicationConfig) SaveMeetingRoom(r MeetingRoom) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	now := time.Now()
+	if r.Created.IsZero() {
+		r.Created = now
+	}
+	r.LastUsed = now
+
+	result := []MeetingRoom{r}
+	for _, old := range a.MeetingRooms {
+		if strings.EqualFold(old.Name, r.Name) {
+			continue
+		}
+
+		if len(result) < maxMeetingRooms {
+			result = append(result, old)
+		}
+	}
+
+	a.MeetingRooms = result
+}
+
+// RemoveMeetingRoom forgets the room with the given name, so its
+// invitation stops working. It returns false if there was no such room
+func (a *ApplicationConfig) RemoveMeetingRoom(name string) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []MeetingRoom{}
+	for _, r := range a.MeetingRooms {
+		if !strings.EqualFold(r.Name, name) {
+			result = append(result, r)
+		}
+	}
+
+	found := len(result) != len(a.MeetingRooms)
+	a.MeetingRooms = result
+
+	return found
+}
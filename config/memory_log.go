@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultLogBufferSize is the amount of log entries kept in memory
+const DefaultLogBufferSize = 1000
+
+// LogEntry is a log message kept in memory. It has already been
+// filtered and redacted in the same way as the written messages
+type LogEntry struct {
+	Time      time.Time
+	Level     log.Level
+	Subsystem string
+	Message   string
+	Fields    map[string]string
+}
+
+// String returns the entry in the same format of the text logs
+func (e LogEntry) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "time=%q level=%s", e.Time.Format(time.RFC3339), e.Level)
+	if e.Subsystem != "" {
+		fmt.Fprintf(&b, " %s=%s", SubsystemField, e.Subsystem)
+	}
+	fmt.Fprintf(&b, " msg=%q", e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, e.Fields[k])
+	}
+
+	return b.String()
+}
+
+// logBuffer keeps the most recent log entries in memory, so they can be
+// looked at or exported even when nothing is written to the disk, which
+// is the default when running without persistent configuration
+type logBuffer struct {
+	sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+var recentLogs = &logBuffer{}
+
+func (b *logBuffer) resize(size int) {
+	b.Lock()
+	defer b.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+
+	b.entries = make([]LogEntry, size)
+	b.next = 0
+	b.full = false
+}
+
+func (b *logBuffer) add(e LogEntry) {
+	b.Lock()
+	defer b.Unlock()
+
+	if len(b.entries) == 0 {
+		return
+	}
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	b.full = b.full || b.next == 0
+}
+
+func (b *logBuffer) all() []LogEntry {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.full {
+		return append([]LogEntry{}, b.entries[:b.next]...)
+	}
+
+	result := make([]LogEntry, 0, len(b.entries))
+	result = append(result, b.entries[b.next:]...)
+	return append(result, b.entries[:b.next]...)
+}
+
+func (b *logBuffer) record(entry *log.Entry, subsystem string) {
+	e := LogEntry{
+		Time:      entry.Time,
+		Level:     entry.Level,
+		Subsystem: subsystem,
+		Message:   entry.Message,
+		Fields:    make(map[string]string, len(entry.Data)),
+	}
+
+	for k, v := range entry.Data {
+		if k != SubsystemField {
+			e.Fields[k] = fmt.Sprint(v)
+		}
+	}
+
+	b.add(e)
+}
+
+// RecentLogEntries returns the log entries kept in memory, from the oldest one
+func RecentLogEntries() []LogEntry {
+	return recentLogs.all()
+}
+
+// ExportRecentLogs writes the log entries kept in memory to w
+func ExportRecentLogs(w io.Writer) error {
+	for _, e := range RecentLogEntries() {
+		_, err := fmt.Fprintln(w, e.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+// +build darwin
+
+package config
+
+// On macOS the configuration and the rest of the files go to the
+// Application Support directory of the user, and the cache to Caches
+
+func defaultConfigHome() string {
+	return WithHome("Library/Application Support")
+}
+
+func defaultCacheHome() string {
+	return WithHome("Library/Caches")
+}
+
+func defaultDataHome() string {
+	return WithHome("Library/Application Support")
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package config
+
+func defaultConfigHome() string {
+	return WithHome(".config")
+}
+
+func defaultCacheHome() string {
+	return WithHome(".cache")
+}
+
+func defaultDataHome() string {
+	return WithHome(".local/share")
+}
@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// On Windows the configuration goes to the roaming application data
+// of the user, %APPDATA%, and the rest of the files, which are only
+// useful in this computer, to the local one, %LOCALAPPDATA%
+
+func defaultConfigHome() string {
+	return windowsFolder("APPDATA", "AppData/Roaming")
+}
+
+func defaultCacheHome() string {
+	return filepath.Join(defaultDataHome(), "cache")
+}
+
+func defaultDataHome() string {
+	return windowsFolder("LOCALAPPDATA", "AppData/Local")
+}
+
+func windowsFolder(env, or string) string {
+	x := os.Getenv(env)
+	if x == "" {
+		x = WithHome(or)
+	}
+	return x
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"encoding/json"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+const (
+	// ProfileFileExtension is the extension of the exported profiles
+	ProfileFileExtension = ".wahayprofile"
+
+	// profileVersion is the version of the format of the
+	// profiles, to know if a profile can be imported
+	profileVersion = 1
+)
+
+var (
+	// ErrProfilePasswordRequired is the error returned when
+	// a profile is exported without a password
+	ErrProfilePasswordRequired = failure.New("config.profile-password-required", failure.CategoryInput, "a password is required to protect the profile", "")
+
+	// ErrInvalidProfileFile is the error returned when the imported
+	// file can't be decrypted or doesn't contain a profile
+	ErrInvalidProfileFile = failure.New("config.invalid-profile-file", failure.CategoryInput, "the profile file is not valid or the password is wrong", "check the password used when the profile was exported")
+)
+
+// profile is what an exported profile contains, before encrypting it. The
+// configuration is kept as it's saved in the configuration file, so it
+// has the persistent identity, the meeting rooms and the Tor bridges
+type profile struct {
+	Version int
+	Config  json.RawMessage
+}
+
+// localSettings are the settings that only make sense in the device
+// where they were made, like the paths of the programs, so they are
+// kept when a profile made in another device is imported
+type localSettings struct {
+	uniqueConfigurationID string
+	pathTor               string
+	pathTorsocks          string
+	pathMumble            string
+	rawLogFile            string
+	torControlAddress     string
+	torControlPassword    string
+	torSocksPort          int
+	torControlPort        int
+	secretsInKeyring      bool
+}
+
+func (a *ApplicationConfig) takeLocalSettings() localSettings {
+	return localSettings{
+		uniqueConfigurationID: a.UniqueConfigurationID,
+		pathTor:               a.PathTor,
+		pathTorsocks:          a.PathTorsocks,
+		pathMumble:            a.PathMumble,
+		rawLogFile:            a.RawLogFile,
+		torControlAddress:     a.TorControlAddress,
+		torControlPassword:    a.TorControlPassword,
+		torSocksPort:          a.TorSocksPort,
+		torControlPort:        a.TorControlPort,
+		secretsInKeyring:      a.SecretsInKeyring,
+	}
+}
+
+func (a *ApplicationConfig) restoreLocalSettings(s localSettings) {
+	a.UniqueConfigurationID = s.uniqueConfigurationID
+	a.PathTor = s.pathTor
+	a.PathTorsocks = s.pathTorsocks
+	a.PathMumble = s.pathMumble
+	a.RawLogFile = s.rawLogFile
+	a.TorControlAddress = s.torControlAddress
+	a.TorControlPassword = s.torControlPassword
+	a.TorSocksPort = s.torSocksPort
+	a.TorControlPort = s.torControlPort
+	a.SecretsInKeyring = s.secretsInKeyring
+}
+
+// ExportProfile returns all the settings in a single file encrypted
+// with the password, to use them in another device or to restore them
+// after installing Wahay again. Unlike the configuration file, the
+// meeting rooms and the other secrets are always in it
+func (a *ApplicationConfig) ExportProfile(password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrProfilePasswordRequired
+	}
+
+	a.ioLock.Lock()
+	content, err := a.serialize()
+	a.ioLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(profile{
+		Version: profileVersion,
+		Config:  content,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptWithPassword(data, password)
+}
+
+// ImportProfile replaces the settings with the ones in a file created by
+// ExportProfile, except the ones that only make sense in this device.
+// Whether the configuration is persistent or encrypted doesn't change,
+// and the settings are only saved when the caller saves them
+func (a *ApplicationConfig) ImportProfile(content []byte, password string) error {
+	decrypted, err := DecryptWithPassword(content, password)
+	if err != nil {
+		return ErrInvalidProfileFile.Wrap(err)
+	}
+
+	var p profile
+	if err = json.Unmarshal(decrypted, &p); err != nil || p.Version != profileVersion || len(p.Config) == 0 {
+		return ErrInvalidProfileFile
+	}
+
+	// The profile is read first on its own, so a broken
+	// one doesn't leave the settings half replaced
+	if err = json.Unmarshal(p.Config, New()); err != nil {
+		return ErrInvalidProfileFile.Wrap(err)
+	}
+
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	local := a.takeLocalSettings()
+	defer a.restoreLocalSettings(local)
+
+	if err = json.Unmarshal(p.Config, a); err != nil {
+		return ErrInvalidProfileFile.Wrap(err)
+	}
+
+	return nil
+}
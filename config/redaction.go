@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The logs are meant to be shared when something goes wrong, so by
+// default they don't include anything that could reveal who met whom:
+// onion addresses, fingerprints, usernames, passwords and tokens are
+// replaced before an entry is written. Addresses and fingerprints are
+// replaced by a short keyed hash, so the same value can still be
+// followed through a log. The key is random for every run, which means
+// that nobody can confirm that a known address appears in the log.
+
+var (
+	redactedOnion       = regexp.MustCompile(`\b[a-z2-7]{56}(\.onion)?\b`)
+	redactedFingerprint = regexp.MustCompile(`\$?\b[0-9A-Fa-f]{40}\b`)
+	redactedURLUser     = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/@\s]+@`)
+	redactedSecretParam = regexp.MustCompile(`(?i)\b(token|password)=[^&\s"]+`)
+	redactedEmail       = regexp.MustCompile(`\b[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}\b`)
+)
+
+// redactedFields are the fields of an entry that always contain
+// personal information, so their whole value is replaced
+var redactedFields = map[string]bool{
+	"username":  true,
+	"user":      true,
+	"recipient": true,
+	"password":  true,
+	"token":     true,
+}
+
+type redactor struct {
+	key []byte
+}
+
+func newRedactor() *redactor {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	return &redactor{key: key}
+}
+
+func (r *redactor) hash(kind, v string) string {
+	m := hmac.New(sha256.New, r.key)
+	_, _ = m.Write([]byte(v))
+
+	return fmt.Sprintf("[%s:%s]", kind, hex.EncodeToString(m.Sum(nil))[:8])
+}
+
+// redact replaces the sensitive parts of the given text
+func (r *redactor) redact(s string) string {
+	s = redactedURLUser.ReplaceAllString(s, "${1}[redacted]@")
+	s = redactedSecretParam.ReplaceAllString(s, "${1}=[redacted]")
+	s = redactedEmail.ReplaceAllStringFunc(s, func(v string) string {
+		return r.hash("email", strings.ToLower(v))
+	})
+	s = redactedOnion.ReplaceAllStringFunc(s, func(v string) string {
+		return r.hash("onion", strings.TrimSuffix(v, ".onion"))
+	})
+	s = redactedFingerprint.ReplaceAllStringFunc(s, func(v string) string {
+		return r.hash("fingerprint", strings.ToUpper(strings.TrimPrefix(v, "$")))
+	})
+
+	return s
+}
+
+// redactField returns the value of the given field that can be written to the logs
+func (r *redactor) redactField(key string, v interface{}) interface{} {
+	if redactedFields[strings.ToLower(key)] {
+		return r.hash(strings.ToLower(key), fmt.Sprint(v))
+	}
+
+	switch value := v.(type) {
+	case string:
+		return r.redact(value)
+	case error:
+		return r.redact(value.Error())
+	case fmt.Stringer:
+		return r.redact(value.String())
+	}
+
+	return v
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"sort"
+	"time"
+)
+
+// maxScheduledMeetings keeps the configuration small
+const maxScheduledMeetings = 20
+
+// ScheduledMeeting is a meeting the user will host later. Its room is
+// generated when it's scheduled, so the invitation can be given from
+// then on, and Wahay starts hosting it some minutes before it begins.
+// Like the meeting rooms, it's only saved encrypted
+type ScheduledMeeting struct {
+	ID       string
+	Title    string
+	Start    time.Time
+	Duration int
+
+	// LeadMinutes is how long before the start the meeting is hosted
+	LeadMinutes int
+
+	Password   string
+	Invitation string
+
+	// KeepRoom is true when the room has to be saved as a meeting
+	// room once hosted, so it can be used again afterwards
+	KeepRoom bool
+	Room     MeetingRoom
+}
+
+// End returns when the meeting is expected to finish
+func (m ScheduledMeeting) End() time.Time {
+	return m.Start.Add(time.Duration(m.Duration) * time.Minute)
+}
+
+// HostingTime returns when the meeting has to be hosted
+func (m ScheduledMeeting) HostingTime() time.Time {
+	return m.Start.Add(-time.Duration(m.LeadMinutes) * time.Minute)
+}
+
+// ListScheduledMeetings returns the scheduled meetings, starting with the next one
+func (a *ApplicationConfig) ListScheduledMeetings() []ScheduledMeeting {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []ScheduledMeeting{}
+	result = append(result, a.ScheduledMeetings...)
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result
+}
+
+// ScheduledMeeting returns the scheduled meeting with the given ID
+func (a *ApplicationConfig) ScheduledMeeting(id string) (ScheduledMeeting, bool) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	for _, m := range a.ScheduledMeetings {
+		if m.ID == id {
+			return m, true
+		}
+	}
+
+	return ScheduledMeeting{}, false
+}
+
+// ScheduleMeeting remembers the meeting, replacing the one with the
+// same ID. It returns false when there are too many scheduled meetings
+// already. The configuration has to be saved afterwards
+func (a *ApplicationConfig) ScheduleMeeting(m ScheduledMeeting) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []ScheduledMeeting{}
+	for _, old := range a.ScheduledMeetings {
+		if old.ID != m.ID {
+			result = append(result, old)
+		}
+	}
+
+	if len(result) >= maxScheduledMeetings {
+		return false
+	}
+
+	a.ScheduledMeetings = append(result, m)
+
+	return true
+}
+
+// RemoveScheduledMeeting forgets the meeting with the given ID, so its
+// invitation stops working. It returns false if there was no such meeting
+func (a *ApplicationConfig) RemoveScheduledMeeting(id string) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []ScheduledMeeting{}
+	for _, m := range a.ScheduledMeetings {
+		if m.ID != id {
+			result = append(result, m)
+		}
+	}
+
+	found := len(result) != len(a.ScheduledMeetings)
+	a.ScheduledMeetings = result
+
+	return found
+}
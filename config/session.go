@@ -0,0 +1,137 @@
+package config
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+const (
+	sessionPidFile     = "wahay.pid"
+	controlSocketFile  = "wahay-control.sock"
+	instanceSocketFile = "wahay-instance.sock"
+)
+
+// ErrNoRunningSession is an error to be trown when there is no
+// running Wahay session to send the panic signal to
+var ErrNoRunningSession = failure.New("config.no-running-session", failure.CategoryUnknown, "no running Wahay session found", "")
+
+func sessionDir() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = wahayDataDir
+	}
+	return dir
+}
+
+func sessionPidFilePath() string {
+	return filepath.Join(sessionDir(), sessionPidFile)
+}
+
+// ControlSocketPath returns the location of the Unix socket where the
+// running Wahay session listens for the commands of other applications
+func ControlSocketPath() string {
+	return filepath.Join(sessionDir(), controlSocketFile)
+}
+
+// InstanceSocketPath returns the location of the Unix socket where the
+// running Wahay session listens for Wahay being opened again
+func InstanceSocketPath() string {
+	return filepath.Join(sessionDir(), instanceSocketFile)
+}
+
+// RegisterSession writes the PID of the current process so other
+// Wahay processes can reach the running session
+func RegisterSession() error {
+	EnsureFilesAndDir()
+	pid := strconv.Itoa(os.Getpid())
+	return SafeWrite(sessionPidFilePath(), []byte(pid), 0600)
+}
+
+// UnregisterSession removes the PID file of the current session
+func UnregisterSession() {
+	_ = os.Remove(sessionPidFilePath())
+}
+
+func runningSessionPid() (int, error) {
+	content, err := ioutil.ReadFile(filepath.Clean(sessionPidFilePath()))
+	if err != nil {
+		return 0, ErrNoRunningSession
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return 0, ErrNoRunningSession
+	}
+
+	return pid, nil
+}
+
+// isRunningSession checks that the process is the running Wahay
+// session, since a session that finished without removing its PID
+// file leaves a PID that any other process can be given later.
+// Without /proc, a Wahay answering on the instance socket is taken
+// as the process of the PID file
+func isRunningSession(pid int) bool {
+	exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+	if err == nil {
+		return isWahayExecutable(exe)
+	}
+
+	if _, err := os.Stat("/proc/self/exe"); err == nil {
+		return false
+	}
+
+	conn, err := net.DialTimeout("unix", InstanceSocketPath(), time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+
+	return true
+}
+
+func isWahayExecutable(path string) bool {
+	// The executable of a running process is shown as deleted
+	// once it has been replaced, like after an update
+	path = strings.TrimSuffix(path, " (deleted)")
+
+	if self, err := os.Executable(); err == nil && path == self {
+		return true
+	}
+
+	return strings.HasPrefix(filepath.Base(path), "wahay")
+}
+
+// SignalPanicToRunningSession asks the running Wahay session to
+// immediately tear down everything it has started
+func SignalPanicToRunningSession() error {
+	pid, err := runningSessionPid()
+	if err != nil {
+		return err
+	}
+
+	if !isRunningSession(pid) {
+		return ErrNoRunningSession
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return ErrNoRunningSession
+	}
+
+	err = p.Signal(PanicSignal)
+	if err != nil {
+		// The process is gone, so the PID file is stale
+		UnregisterSession()
+		return ErrNoRunningSession
+	}
+
+	return nil
+}
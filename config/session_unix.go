@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import "syscall"
+
+// PanicSignal is the signal sent to a running Wahay session
+// when the panic action is requested from the command line
+var PanicSignal = syscall.SIGUSR1
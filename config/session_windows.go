@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package config
+
+import "syscall"
+
+// PanicSignal is the signal sent to a running Wahay session when the
+// panic action is requested from the command line. Windows can only
+// kill other processes, so the running session is not reached by it
+var PanicSignal = syscall.SIGTERM
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StepTiming is a summary of how long a step of the
+// host or the join pipelines has taken in this session
+type StepTiming struct {
+	Step  string        `json:"step"`
+	Count int           `json:"count"`
+	Last  time.Duration `json:"last"`
+	Max   time.Duration `json:"max"`
+	Total time.Duration `json:"total"`
+}
+
+var (
+	timingsLock sync.Mutex
+	timings     = map[string]*StepTiming{}
+)
+
+// LogDuration records how long the given step has taken since start.
+// It's meant to be deferred at the beginning of the step, and it does
+// nothing unless Wahay is running in debugging mode
+func LogDuration(step string, start time.Time) {
+	if !*Debug {
+		return
+	}
+
+	d := time.Since(start)
+
+	timingsLock.Lock()
+	t, ok := timings[step]
+	if !ok {
+		t = &StepTiming{Step: step}
+		timings[step] = t
+	}
+	t.Count++
+	t.Last = d
+	t.Total += d
+	if d > t.Max {
+		t.Max = d
+	}
+	timingsLock.Unlock()
+
+	log.WithFields(log.Fields{
+		"context":  "timing",
+		"step":     step,
+		"duration": d,
+	}).Debug("Step finished")
+}
+
+// Timings returns the summary of all the steps recorded in this session
+func Timings() []StepTiming {
+	timingsLock.Lock()
+	defer timingsLock.Unlock()
+
+	result := make([]StepTiming, 0, len(timings))
+	for _, t := range timings {
+		result = append(result, *t)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Step < result[j].Step
+	})
+
+	return result
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// maxTrustedCertificates keeps the configuration small. The certificates
+	// used the longest time ago are forgotten first
+	maxTrustedCertificates = 200
+
+	// trustedCertificateLifetime is how long a certificate is remembered
+	// after it was last used. Recurring meetings keep their certificate
+	trustedCertificateLifetime = 180 * 24 * time.Hour
+)
+
+// TrustedCertificate is the certificate of a meeting the user has trusted
+// before. Mumble identifies certificates by their SHA-1 digest, but Wahay
+// uses the SHA-256 one everywhere else. Entries saved by older versions
+// of Wahay might only have the SHA-1 digest, and they get the SHA-256
+// one the next time the same certificate is received
+type TrustedCertificate struct {
+	Host     string
+	Port     int
+	SHA1     string `json:",omitempty"`
+	SHA256   string `json:",omitempty"`
+	Trusted  time.Time
+	LastUsed time.Time `json:",omitempty"`
+}
+
+// IsSameServer returns true if the certificate is for the given host and port
+func (c TrustedCertificate) IsSameServer(host string, port int) bool {
+	return strings.EqualFold(c.Host, host) && c.Port == port
+}
+
+// IsExpired returns true if the certificate hasn't been used for too long
+func (c TrustedCertificate) IsExpired(now time.Time) bool {
+	last := c.LastUsed
+	if last.IsZero() {
+		last = c.Trusted
+	}
+	return now.Sub(last) > trustedCertificateLifetime
+}
+
+// ListTrustedCertificates returns the certificates that haven't
+// expired, starting with the ones used most recently
+func (a *ApplicationConfig) ListTrustedCertificates() []TrustedCertificate {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	now := time.Now()
+	result := []TrustedCertificate{}
+	for _, c := range a.TrustedCertificates {
+		if !c.IsExpired(now) {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// TrustedCertificate returns the certificate trusted for the given server
+func (a *ApplicationConfig) TrustedCertificate(host string, port int) (TrustedCertificate, bool) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	now := time.Now()
+	for _, c := range a.TrustedCertificates {
+		if c.IsSameServer(host, port) && !c.IsExpired(now) {
+			return c, true
+		}
+	}
+
+	return TrustedCertificate{}, false
+}
+
+// TrustCertificate remembers the given certificate as used now, replacing
+// the one trusted before for the same server. The certificates of other
+// servers, even in the same host, are kept. The configuration has to be
+// saved afterwards for the certificate to be remembered in the next runs
+func (a *ApplicationConfig) TrustCertificate(c TrustedCertificate) {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	now := time.Now()
+	for _, old := range a.TrustedCertificates {
+		if old.IsSameServer(c.Host, c.Port) && old.SHA1 == c.SHA1 && c.Trusted.IsZero() {
+			c.Trusted = old.Trusted
+		}
+	}
+
+	if c.Trusted.IsZero() {
+		c.Trusted = now
+	}
+	c.LastUsed = now
+
+	result := []TrustedCertificate{c}
+	for _, old := range a.TrustedCertificates {
+		if old.IsSameServer(c.Host, c.Port) || old.IsExpired(now) {
+			continue
+		}
+
+		if len(result) < maxTrustedCertificates {
+			result = append(result, old)
+		}
+	}
+
+	a.TrustedCertificates = result
+}
+
+// ForgetTrustedCertificate removes the certificate trusted for the given
+// server, so the user is asked to verify it again. It returns false if
+// there was no certificate for the server
+func (a *ApplicationConfig) ForgetTrustedCertificate(host string, port int) bool {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	result := []TrustedCertificate{}
+	for _, c := range a.TrustedCertificates {
+		if !c.IsSameServer(host, port) {
+			result = append(result, c)
+		}
+	}
+
+	found := len(result) != len(a.TrustedCertificates)
+	a.TrustedCertificates = result
+
+	return found
+}
+
+// ExpireTrustedCertificates removes the certificates that haven't been
+// used for a long time. It returns the number of removed certificates
+func (a *ApplicationConfig) ExpireTrustedCertificates() int {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	now := time.Now()
+	result := []TrustedCertificate{}
+	for _, c := range a.TrustedCertificates {
+		if !c.IsExpired(now) {
+			result = append(result, c)
+		}
+	}
+
+	removed := len(a.TrustedCertificates) - len(result)
+	a.TrustedCertificates = result
+
+	return removed
+}
+
+// ForgetTrustedCertificates removes all the trusted certificates, so
+// the user is asked to verify them again
+func (a *ApplicationConfig) ForgetTrustedCertificates() {
+	a.ioLock.Lock()
+	defer a.ioLock.Unlock()
+
+	a.TrustedCertificates = nil
+}
@@ -38,15 +38,20 @@ func RandomString(dest []byte) error {
 	return nil
 }
 
-// WithHome returns the given relative file/dir with the $HOME prepended
+// WithHome returns the given relative file/dir with the home directory
+// of the user prepended, which is $HOME or %USERPROFILE% on Windows
 func WithHome(file string) string {
-	return filepath.Join(os.Getenv("HOME"), file)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, file)
 }
 
-func xdgOrWithHome(env, or string) string {
+func xdgOr(env string, or func() string) string {
 	x := os.Getenv(env)
 	if x == "" {
-		x = WithHome(or)
+		x = or()
 	}
 	return x
 }
@@ -63,23 +68,23 @@ func FindFileInLocations(places []string) (string, bool) {
 
 // XdgConfigHome returns the standardized XDG Configuration directory
 func XdgConfigHome() string {
-	return xdgOrWithHome("XDG_CONFIG_HOME", ".config")
+	return xdgOr("XDG_CONFIG_HOME", defaultConfigHome)
 }
 
 // XdgCacheDir returns the standardized XDG Cache directory
 func XdgCacheDir() string {
-	return xdgOrWithHome("XDG_CACHE_HOME", ".cache")
+	return xdgOr("XDG_CACHE_HOME", defaultCacheHome)
 }
 
 // XdgDataHome returns the standardized XDG Data directory
 func XdgDataHome() string {
-	return xdgOrWithHome("XDG_DATA_HOME", ".local/share")
+	return xdgOr("XDG_DATA_HOME", defaultDataHome)
 }
 
 // XdgDataDirs returns the standardized XDG Data directory
 func XdgDataDirs() []string {
 	x := os.Getenv("XDG_DATA_DIRS")
-	return strings.Split(x, ":")
+	return filepath.SplitList(x)
 }
 
 // IsPortAvailable return a boolean indicatin if a specific
@@ -116,8 +121,13 @@ func CheckPort(port int) bool {
 	return true
 }
 
-// DetectLanguage determine the language used in the host computer
+// DetectLanguage determine the language used in the host computer,
+// unless another one is set with UseLanguage
 func DetectLanguage() language.Tag {
+	if tag, ok := usedLanguage(); ok {
+		return tag
+	}
+
 	tag, _ := jibberjabber.DetectLanguageTag()
 	if tag == language.Und {
 		tag = language.English
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sensitiveFiles are the files Wahay has written with certificates, keys
+// or the configuration of the meetings. They are wiped when Wahay is
+// closed. The identity files contain the persistent identity of the
+// user, and they can be kept
+var sensitiveFiles = struct {
+	sync.Mutex
+	files map[string]bool
+}{files: map[string]bool{}}
+
+// TrackSensitiveFile remembers the file, or directory, to be wiped
+// when Wahay is closed. Identity files contain the persistent identity
+// of the user and are kept if the user asked for it
+func TrackSensitiveFile(path string, identity bool) {
+	sensitiveFiles.Lock()
+	defer sensitiveFiles.Unlock()
+
+	sensitiveFiles.files[path] = identity
+}
+
+// ForgetSensitiveFile stops tracking the file, usually because it
+// has already been wiped
+func ForgetSensitiveFile(path string) {
+	sensitiveFiles.Lock()
+	defer sensitiveFiles.Unlock()
+
+	delete(sensitiveFiles.files, path)
+}
+
+// WipeSensitiveFiles wipes all the tracked files, except the identity
+// files when keepIdentity is true. It's safe to call it more than once
+func WipeSensitiveFiles(keepIdentity bool) {
+	sensitiveFiles.Lock()
+	files := sensitiveFiles.files
+	sensitiveFiles.files = map[string]bool{}
+	sensitiveFiles.Unlock()
+
+	for path, identity := range files {
+		if identity && keepIdentity {
+			continue
+		}
+
+		err := SecureRemove(path)
+		if err != nil {
+			log.Errorf("The file %s can't be wiped: %s", path, err)
+		}
+	}
+}
+
+// SecureRemove overwrites the content of the file, or of all the files
+// in the directory, before removing it, so the data can't be recovered
+// from the disk blocks they used. Some file systems, like the ones using
+// copy on write, might still keep old copies of the data
+func SecureRemove(path string) error {
+	_ = filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			overwriteFile(name, info.Size())
+		}
+		return nil
+	})
+
+	ForgetSensitiveFile(path)
+
+	err := os.RemoveAll(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func overwriteFile(name string, size int64) {
+	f, err := os.OpenFile(filepath.Clean(name), os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	zeros := make([]byte, 32*1024)
+	for written := int64(0); written < size; {
+		n := int64(len(zeros))
+		if size-written < n {
+			n = size - written
+		}
+
+		_, err = f.Write(zeros[:n])
+		if err != nil {
+			return
+		}
+		written += n
+	}
+
+	_ = f.Sync()
+}
@@ -0,0 +1,428 @@
+// Package control exposes a local interface that other applications,
+// scripts and desktop integrations can use to drive a running Wahay.
+// It's a JSON-RPC service listening on a Unix socket that only the
+// user running Wahay can access
+package control
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// ServiceName is the name the methods are called with, like "Wahay.GetStatus"
+const ServiceName = "Wahay"
+
+var (
+	// ErrAlreadyRunning is returned when another Wahay is already listening on the socket
+	ErrAlreadyRunning = failure.New("control.already-running", failure.CategoryUnknown, "another Wahay session is already listening for commands", "close the other Wahay session")
+
+	errCantListen = failure.New("control.cant-listen", failure.CategoryUnknown, "the control interface can't be started", "")
+)
+
+// Controller is what the control interface drives, usually the GUI
+// of the running Wahay session
+type Controller interface {
+	// HostMeeting starts a new meeting with the given password
+	// and returns its invitation
+	HostMeeting(password string) (string, error)
+
+	// JoinMeeting joins the meeting of the invitation
+	JoinMeeting(invitation, username, password string) error
+
+	// EndMeeting finishes the hosted meeting, or leaves the joined one
+	EndMeeting() error
+
+	// Status returns what Wahay is doing now
+	Status() Status
+
+	// SetMuted mutes or unmutes the microphone in the current meeting
+	SetMuted(bool) error
+
+	// Participants returns who is connected to the hosted meeting
+	Participants() ([]Participant, error)
+
+	// MuteParticipant mutes or unmutes a participant of the hosted meeting
+	MuteParticipant(session uint32, muted bool) error
+
+	// DeafenParticipant deafens or undeafens a participant of the hosted meeting
+	DeafenParticipant(session uint32, deafened bool) error
+
+	// KickParticipant removes a participant from the hosted meeting
+	KickParticipant(session uint32) error
+
+	// BanParticipant removes a participant from the hosted meeting,
+	// and doesn't let them join it again for the given time
+	BanParticipant(session uint32, d time.Duration) error
+
+	// SetCoModerator gives a participant of the hosted meeting the
+	// privileges of a moderator, or takes them back
+	SetCoModerator(session uint32, coModerator bool) error
+
+	// MeetingStats returns how the connection to the current meeting works
+	MeetingStats() (MeetingStats, error)
+
+	// ChatMessages returns the last messages of the chat of the hosted meeting
+	ChatMessages() ([]ChatMessage, error)
+
+	// SendChatMessage writes a message for everybody in the hosted meeting
+	SendChatMessage(text string) error
+
+	// SuperUser returns the administrator of the hosted meeting
+	SuperUser() (SuperUser, error)
+
+	// RotateSuperUserPassword gives the administrator of the
+	// hosted meeting a new password, and returns it
+	RotateSuperUserPassword() (SuperUser, error)
+}
+
+// Status is what the running Wahay session is doing
+type Status struct {
+	TorReady   bool   `json:"torReady"`
+	Hosting    bool   `json:"hosting"`
+	InMeeting  bool   `json:"inMeeting"`
+	MeetingID  string `json:"meetingId,omitempty"`
+	Invitation string `json:"invitation,omitempty"`
+}
+
+// Participant is somebody connected to the hosted meeting
+type Participant struct {
+	Session     uint32 `json:"session"`
+	Name        string `json:"name"`
+	Muted       bool   `json:"muted"`
+	Deafened    bool   `json:"deafened"`
+	Talking     bool   `json:"talking"`
+	Waiting     bool   `json:"waiting"`
+	CoModerator bool   `json:"coModerator"`
+}
+
+// MeetingStats is how the connection to the current meeting works. The
+// bytes are the ones that went through Tor to the meeting since it was
+// joined or hosted, and the participants are only known by the host
+type MeetingStats struct {
+	BytesSent     int64              `json:"bytesSent"`
+	BytesReceived int64              `json:"bytesReceived"`
+	RoundTripMs   int64              `json:"roundTripMs,omitempty"`
+	Participants  []ParticipantStats `json:"participants,omitempty"`
+}
+
+// ParticipantStats is how the connection of a participant to the
+// hosted meeting works. Loss is the part of the voice packets that
+// didn't arrive on time, or -1 when it's not known
+type ParticipantStats struct {
+	Session     uint32  `json:"session"`
+	Name        string  `json:"name"`
+	RoundTripMs int64   `json:"roundTripMs"`
+	Loss        float64 `json:"loss"`
+}
+
+// ChatMessage is a message written in the chat of the hosted meeting.
+// The session is zero for the messages written by the host, and the
+// private ones were only sent to Wahay
+type ChatMessage struct {
+	Time    time.Time `json:"time"`
+	Session uint32    `json:"session,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Text    string    `json:"text"`
+	Private bool      `json:"private,omitempty"`
+}
+
+// SuperUser is the administrator of the Mumble server of the hosted meeting
+type SuperUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// HostMeetingArgs are the arguments of the HostMeeting method
+type HostMeetingArgs struct {
+	Password string `json:"password"`
+}
+
+// HostMeetingReply is the result of the HostMeeting method
+type HostMeetingReply struct {
+	Invitation string `json:"invitation"`
+}
+
+// JoinMeetingArgs are the arguments of the JoinMeeting method
+type JoinMeetingArgs struct {
+	Invitation string `json:"invitation"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+// MuteSelfArgs are the arguments of the MuteSelf method
+type MuteSelfArgs struct {
+	Muted bool `json:"muted"`
+}
+
+// ListParticipantsReply is the result of the ListParticipants method
+type ListParticipantsReply struct {
+	Participants []Participant `json:"participants"`
+}
+
+// ParticipantArgs are the arguments of the methods that act on a participant
+type ParticipantArgs struct {
+	Session uint32 `json:"session"`
+}
+
+// MuteParticipantArgs are the arguments of the MuteParticipant method
+type MuteParticipantArgs struct {
+	Session uint32 `json:"session"`
+	Muted   bool   `json:"muted"`
+}
+
+// DeafenParticipantArgs are the arguments of the DeafenParticipant method
+type DeafenParticipantArgs struct {
+	Session  uint32 `json:"session"`
+	Deafened bool   `json:"deafened"`
+}
+
+// BanParticipantArgs are the arguments of the BanParticipant method
+type BanParticipantArgs struct {
+	Session uint32 `json:"session"`
+	Minutes int    `json:"minutes"`
+}
+
+// SetCoModeratorArgs are the arguments of the SetCoModerator method
+type SetCoModeratorArgs struct {
+	Session     uint32 `json:"session"`
+	CoModerator bool   `json:"coModerator"`
+}
+
+// ListChatMessagesReply is the result of the ListChatMessages method
+type ListChatMessagesReply struct {
+	Messages []ChatMessage `json:"messages"`
+}
+
+// SendChatMessageArgs are the arguments of the SendChatMessage method
+type SendChatMessageArgs struct {
+	Text string `json:"text"`
+}
+
+// Empty is used by the methods that don't need arguments or a result
+type Empty struct{}
+
+// Service has the methods available through the control interface
+type Service struct {
+	c Controller
+}
+
+// HostMeeting starts a new meeting
+func (s *Service) HostMeeting(args HostMeetingArgs, reply *HostMeetingReply) error {
+	inv, err := s.c.HostMeeting(args.Password)
+	if err != nil {
+		return err
+	}
+
+	reply.Invitation = inv
+	return nil
+}
+
+// JoinMeeting joins the meeting of the given invitation
+func (s *Service) JoinMeeting(args JoinMeetingArgs, reply *Empty) error {
+	return s.c.JoinMeeting(args.Invitation, args.Username, args.Password)
+}
+
+// EndMeeting finishes or leaves the current meeting
+func (s *Service) EndMeeting(args Empty, reply *Empty) error {
+	return s.c.EndMeeting()
+}
+
+// GetStatus returns what Wahay is doing now
+func (s *Service) GetStatus(args Empty, reply *Status) error {
+	*reply = s.c.Status()
+	return nil
+}
+
+// MuteSelf mutes or unmutes the microphone in the current meeting
+func (s *Service) MuteSelf(args MuteSelfArgs, reply *Empty) error {
+	return s.c.SetMuted(args.Muted)
+}
+
+// ListParticipants returns who is connected to the hosted meeting
+func (s *Service) ListParticipants(args Empty, reply *ListParticipantsReply) error {
+	ps, err := s.c.Participants()
+	if err != nil {
+		return err
+	}
+
+	reply.Participants = ps
+	return nil
+}
+
+// MuteParticipant mutes or unmutes a participant of the hosted meeting
+func (s *Service) MuteParticipant(args MuteParticipantArgs, reply *Empty) error {
+	return s.c.MuteParticipant(args.Session, args.Muted)
+}
+
+// DeafenParticipant deafens or undeafens a participant of the hosted meeting
+func (s *Service) DeafenParticipant(args DeafenParticipantArgs, reply *Empty) error {
+	return s.c.DeafenParticipant(args.Session, args.Deafened)
+}
+
+// KickParticipant removes a participant from the hosted meeting
+func (s *Service) KickParticipant(args ParticipantArgs, reply *Empty) error {
+	return s.c.KickParticipant(args.Session)
+}
+
+// BanParticipant removes a participant from the hosted meeting for some minutes
+func (s *Service) BanParticipant(args BanParticipantArgs, reply *Empty) error {
+	return s.c.BanParticipant(args.Session, time.Duration(args.Minutes)*time.Minute)
+}
+
+// SetCoModerator makes a participant of the hosted meeting a co-moderator, or not anymore
+func (s *Service) SetCoModerator(args SetCoModeratorArgs, reply *Empty) error {
+	return s.c.SetCoModerator(args.Session, args.CoModerator)
+}
+
+// GetMeetingStats returns how the connection to the current meeting works
+func (s *Service) GetMeetingStats(args Empty, reply *MeetingStats) error {
+	stats, err := s.c.MeetingStats()
+	if err != nil {
+		return err
+	}
+
+	*reply = stats
+	return nil
+}
+
+// ListChatMessages returns the last messages of the chat of the hosted meeting
+func (s *Service) ListChatMessages(args Empty, reply *ListChatMessagesReply) error {
+	ms, err := s.c.ChatMessages()
+	if err != nil {
+		return err
+	}
+
+	reply.Messages = ms
+	return nil
+}
+
+// SendChatMessage writes a message for everybody in the hosted meeting
+func (s *Service) SendChatMessage(args SendChatMessageArgs, reply *Empty) error {
+	return s.c.SendChatMessage(args.Text)
+}
+
+// GetSuperUser returns the administrator of the hosted meeting
+func (s *Service) GetSuperUser(args Empty, reply *SuperUser) error {
+	su, err := s.c.SuperUser()
+	if err != nil {
+		return err
+	}
+
+	*reply = su
+	return nil
+}
+
+// RotateSuperUserPassword gives the administrator of the hosted meeting a new password
+func (s *Service) RotateSuperUserPassword(args Empty, reply *SuperUser) error {
+	su, err := s.c.RotateSuperUserPassword()
+	if err != nil {
+		return err
+	}
+
+	*reply = su
+	return nil
+}
+
+// Server listens for the commands of other applications
+type Server struct {
+	sync.Mutex
+	path     string
+	listener net.Listener
+	closed   bool
+}
+
+// Listen starts the control interface on the Unix socket at the given
+// path, serving every connection in its own goroutine
+func Listen(path string, c Controller) (*Server, error) {
+	return listen(path, ServiceName, &Service{c})
+}
+
+func listen(path, name string, service interface{}) (*Server, error) {
+	if isListening(path) {
+		return nil, ErrAlreadyRunning
+	}
+
+	// A socket left behind by a session that was killed
+	_ = os.Remove(path)
+
+	r := rpc.NewServer()
+	err := r.RegisterName(name, service)
+	if err != nil {
+		return nil, errCantListen.Wrap(err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errCantListen.Wrap(err)
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		_ = l.Close()
+		return nil, errCantListen.Wrap(err)
+	}
+
+	s := &Server{
+		path:     path,
+		listener: l,
+	}
+
+	go s.serve(r)
+
+	return s, nil
+}
+
+func (s *Server) serve(r *rpc.Server) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !s.isClosed() {
+				log.WithFields(log.Fields{
+					"context": "control",
+				}).Errorf("the connection can't be accepted: %s", err)
+			}
+			return
+		}
+
+		go r.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed
+}
+
+// Close stops the control interface and removes its socket
+func (s *Server) Close() {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return
+	}
+	s.closed = true
+	s.Unlock()
+
+	_ = s.listener.Close()
+	_ = os.Remove(s.path)
+}
+
+func isListening(path string) bool {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false
+	}
+
+	_ = conn.Close()
+	return true
+}
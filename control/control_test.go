@@ -0,0 +1,346 @@
+package control
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ControlSuite struct {
+	dir string
+}
+
+var _ = Suite(&ControlSuite{})
+
+func (s *ControlSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-control")
+	c.Assert(err, IsNil)
+	s.dir = dir
+}
+
+func (s *ControlSuite) TearDownTest(c *C) {
+	_ = os.RemoveAll(s.dir)
+}
+
+type fakeController struct {
+	password string
+	muted    bool
+	ended    bool
+
+	mutedParticipant    uint32
+	deafenedParticipant uint32
+	kicked              uint32
+	banned              uint32
+	banDuration         time.Duration
+	coModerator         uint32
+
+	chat []ChatMessage
+
+	superUserPassword string
+}
+
+func (f *fakeController) HostMeeting(password string) (string, error) {
+	f.password = password
+	return "wahay:invitation", nil
+}
+
+func (f *fakeController) JoinMeeting(invitation, username, password string) error {
+	return errors.New("the meeting can't be joined")
+}
+
+func (f *fakeController) EndMeeting() error {
+	f.ended = true
+	return nil
+}
+
+func (f *fakeController) Status() Status {
+	return Status{TorReady: true, Hosting: f.password != ""}
+}
+
+func (f *fakeController) SetMuted(muted bool) error {
+	f.muted = muted
+	return nil
+}
+
+func (f *fakeController) Participants() ([]Participant, error) {
+	return []Participant{{Session: 2, Name: "Alice", Talking: true}}, nil
+}
+
+func (f *fakeController) MuteParticipant(session uint32, muted bool) error {
+	if muted {
+		f.mutedParticipant = session
+	}
+	return nil
+}
+
+func (f *fakeController) DeafenParticipant(session uint32, deafened bool) error {
+	if deafened {
+		f.deafenedParticipant = session
+	}
+	return nil
+}
+
+func (f *fakeController) KickParticipant(session uint32) error {
+	f.kicked = session
+	return nil
+}
+
+func (f *fakeController) BanParticipant(session uint32, d time.Duration) error {
+	f.banned = session
+	f.banDuration = d
+	return nil
+}
+
+func (f *fakeController) SetCoModerator(session uint32, coModerator bool) error {
+	if coModerator {
+		f.coModerator = session
+	}
+	return nil
+}
+
+func (f *fakeController) MeetingStats() (MeetingStats, error) {
+	if f.password == "" {
+		return MeetingStats{}, errors.New("there is no meeting in progress")
+	}
+	return MeetingStats{
+		BytesSent:     1024,
+		BytesReceived: 4096,
+		RoundTripMs:   800,
+		Participants:  []ParticipantStats{{Session: 2, Name: "Alice", RoundTripMs: 900, Loss: -1}},
+	}, nil
+}
+
+func (f *fakeController) ChatMessages() ([]ChatMessage, error) {
+	return f.chat, nil
+}
+
+func (f *fakeController) SendChatMessage(text string) error {
+	if text == "" {
+		return errors.New("the message is empty")
+	}
+	f.chat = append(f.chat, ChatMessage{Text: text})
+	return nil
+}
+
+func (f *fakeController) SuperUser() (SuperUser, error) {
+	if f.password == "" {
+		return SuperUser{}, errors.New("there is no hosted meeting in progress")
+	}
+	return SuperUser{Username: "SuperUser", Password: f.superUserPassword}, nil
+}
+
+func (f *fakeController) RotateSuperUserPassword() (SuperUser, error) {
+	f.superUserPassword += "-rotated"
+	return f.SuperUser()
+}
+
+func (s *ControlSuite) Test_Listen_servesTheMethodsOfTheController(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+	f := &fakeController{}
+
+	server, err := Listen(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	conn, err := jsonrpc.Dial("unix", path)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	var reply HostMeetingReply
+	err = conn.Call("Wahay.HostMeeting", HostMeetingArgs{Password: "secret"}, &reply)
+	c.Assert(err, IsNil)
+	c.Assert(reply.Invitation, Equals, "wahay:invitation")
+	c.Assert(f.password, Equals, "secret")
+
+	var status Status
+	err = conn.Call("Wahay.GetStatus", Empty{}, &status)
+	c.Assert(err, IsNil)
+	c.Assert(status, DeepEquals, Status{TorReady: true, Hosting: true})
+
+	err = conn.Call("Wahay.MuteSelf", MuteSelfArgs{Muted: true}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.muted, Equals, true)
+
+	err = conn.Call("Wahay.EndMeeting", Empty{}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.ended, Equals, true)
+
+	err = conn.Call("Wahay.JoinMeeting", JoinMeetingArgs{Invitation: "x"}, &Empty{})
+	c.Assert(err, ErrorMatches, "the meeting can't be joined")
+}
+
+func (s *ControlSuite) Test_Listen_servesTheHostControls(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+	f := &fakeController{}
+
+	server, err := Listen(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	conn, err := jsonrpc.Dial("unix", path)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	var reply ListParticipantsReply
+	err = conn.Call("Wahay.ListParticipants", Empty{}, &reply)
+	c.Assert(err, IsNil)
+	c.Assert(reply.Participants, DeepEquals, []Participant{{Session: 2, Name: "Alice", Talking: true}})
+
+	err = conn.Call("Wahay.MuteParticipant", MuteParticipantArgs{Session: 2, Muted: true}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.mutedParticipant, Equals, uint32(2))
+
+	err = conn.Call("Wahay.DeafenParticipant", DeafenParticipantArgs{Session: 3, Deafened: true}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.deafenedParticipant, Equals, uint32(3))
+
+	err = conn.Call("Wahay.KickParticipant", ParticipantArgs{Session: 4}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.kicked, Equals, uint32(4))
+
+	err = conn.Call("Wahay.BanParticipant", BanParticipantArgs{Session: 5, Minutes: 30}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.banned, Equals, uint32(5))
+	c.Assert(f.banDuration, Equals, 30*time.Minute)
+
+	err = conn.Call("Wahay.SetCoModerator", SetCoModeratorArgs{Session: 6, CoModerator: true}, &Empty{})
+	c.Assert(err, IsNil)
+	c.Assert(f.coModerator, Equals, uint32(6))
+}
+
+func (s *ControlSuite) Test_Listen_servesTheStatisticsOfTheMeeting(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+	f := &fakeController{}
+
+	server, err := Listen(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	conn, err := jsonrpc.Dial("unix", path)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	var stats MeetingStats
+	err = conn.Call("Wahay.GetMeetingStats", Empty{}, &stats)
+	c.Assert(err, ErrorMatches, "there is no meeting in progress")
+
+	f.password = "secret"
+	err = conn.Call("Wahay.GetMeetingStats", Empty{}, &stats)
+	c.Assert(err, IsNil)
+	c.Assert(stats.BytesReceived, Equals, int64(4096))
+	c.Assert(stats.RoundTripMs, Equals, int64(800))
+	c.Assert(stats.Participants, DeepEquals, []ParticipantStats{{Session: 2, Name: "Alice", RoundTripMs: 900, Loss: -1}})
+}
+
+func (s *ControlSuite) Test_Listen_servesTheChatOfTheMeeting(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+	f := &fakeController{
+		chat: []ChatMessage{{Session: 2, Author: "Alice", Text: "hello"}},
+	}
+
+	server, err := Listen(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	conn, err := jsonrpc.Dial("unix", path)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	err = conn.Call("Wahay.SendChatMessage", SendChatMessageArgs{Text: "the notes are at https://example.org"}, &Empty{})
+	c.Assert(err, IsNil)
+
+	err = conn.Call("Wahay.SendChatMessage", SendChatMessageArgs{}, &Empty{})
+	c.Assert(err, ErrorMatches, "the message is empty")
+
+	var reply ListChatMessagesReply
+	err = conn.Call("Wahay.ListChatMessages", Empty{}, &reply)
+	c.Assert(err, IsNil)
+	c.Assert(reply.Messages, HasLen, 2)
+	c.Assert(reply.Messages[0].Author, Equals, "Alice")
+	c.Assert(reply.Messages[1].Text, Equals, "the notes are at https://example.org")
+}
+
+func (s *ControlSuite) Test_Listen_servesTheSuperUserOfTheMeeting(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+	f := &fakeController{superUserPassword: "first"}
+
+	server, err := Listen(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	conn, err := jsonrpc.Dial("unix", path)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	var su SuperUser
+	err = conn.Call("Wahay.GetSuperUser", Empty{}, &su)
+	c.Assert(err, ErrorMatches, "there is no hosted meeting in progress")
+
+	f.password = "secret"
+	err = conn.Call("Wahay.GetSuperUser", Empty{}, &su)
+	c.Assert(err, IsNil)
+	c.Assert(su, DeepEquals, SuperUser{Username: "SuperUser", Password: "first"})
+
+	err = conn.Call("Wahay.RotateSuperUserPassword", Empty{}, &su)
+	c.Assert(err, IsNil)
+	c.Assert(su.Password, Equals, "first-rotated")
+}
+
+func (s *ControlSuite) Test_Listen_failsWhenAnotherSessionIsListening(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+
+	server, err := Listen(path, &fakeController{})
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	_, err = Listen(path, &fakeController{})
+	c.Assert(err, Equals, ErrAlreadyRunning)
+}
+
+func (s *ControlSuite) Test_Close_removesTheSocket(c *C) {
+	path := filepath.Join(s.dir, "control.sock")
+
+	server, err := Listen(path, &fakeController{})
+	c.Assert(err, IsNil)
+
+	server.Close()
+
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+type fakeActivator struct {
+	meetingURL chan string
+}
+
+func (f *fakeActivator) Activate(meetingURL string) {
+	f.meetingURL <- meetingURL
+}
+
+func (s *ControlSuite) Test_ActivateRunningInstance_handsTheMeetingURLToTheRunningInstance(c *C) {
+	path := filepath.Join(s.dir, "instance.sock")
+	f := &fakeActivator{meetingURL: make(chan string, 1)}
+
+	server, err := ListenInstance(path, f)
+	c.Assert(err, IsNil)
+	defer server.Close()
+
+	err = ActivateRunningInstance(path, "wahay://meeting")
+	c.Assert(err, IsNil)
+	c.Assert(<-f.meetingURL, Equals, "wahay://meeting")
+}
+
+func (s *ControlSuite) Test_ActivateRunningInstance_failsWithoutRunningInstance(c *C) {
+	err := ActivateRunningInstance(filepath.Join(s.dir, "instance.sock"), "")
+	c.Assert(failure.Is(err, ErrNoRunningInstance), Equals, true)
+}
@@ -0,0 +1,79 @@
+package control
+
+import (
+	"net"
+	"net/rpc/jsonrpc"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The first Wahay started in a session listens on the instance socket, so
+// opening Wahay again hands its arguments to the running one instead of
+// starting another Tor and another Mumble server. Unlike the control
+// interface, it's always listening, and it can only bring Wahay to the
+// front and open a meeting URL, which the user is asked about anyway
+
+// InstanceServiceName is the name the methods of the instance socket are called with
+const InstanceServiceName = "WahayInstance"
+
+// instanceActivationTimeout is how long a new Wahay process waits
+// for the running one, before starting on its own
+const instanceActivationTimeout = 5 * time.Second
+
+// ErrNoRunningInstance is returned when there is no Wahay
+// listening on the instance socket
+var ErrNoRunningInstance = failure.New("control.no-running-instance", failure.CategoryUnknown, "no running Wahay found", "")
+
+// Activator is what the running Wahay does when it's opened again
+type Activator interface {
+	// Activate brings Wahay to the front, and starts joining the
+	// meeting of the URL given, if any
+	Activate(meetingURL string)
+}
+
+// ActivateArgs are the arguments of the Activate method
+type ActivateArgs struct {
+	MeetingURL string `json:"meetingUrl"`
+}
+
+// InstanceService has the methods available through the instance socket
+type InstanceService struct {
+	a Activator
+}
+
+// Activate brings the running Wahay to the front
+func (s *InstanceService) Activate(args ActivateArgs, reply *Empty) error {
+	s.a.Activate(args.MeetingURL)
+	return nil
+}
+
+// ListenInstance starts listening on the instance socket at the given
+// path, returning ErrAlreadyRunning when another Wahay is already there
+func ListenInstance(path string, a Activator) (*Server, error) {
+	return listen(path, InstanceServiceName, &InstanceService{a})
+}
+
+// ActivateRunningInstance asks the Wahay listening on the instance
+// socket at the given path to come to the front and open the meeting URL
+func ActivateRunningInstance(path, meetingURL string) error {
+	conn, err := net.DialTimeout("unix", path, instanceActivationTimeout)
+	if err != nil {
+		return ErrNoRunningInstance.Wrap(err)
+	}
+	defer conn.Close()
+
+	// A Wahay that stopped answering must not keep this one waiting
+	err = conn.SetDeadline(time.Now().Add(instanceActivationTimeout))
+	if err != nil {
+		return ErrNoRunningInstance.Wrap(err)
+	}
+
+	c := jsonrpc.NewClient(conn)
+	err = c.Call(InstanceServiceName+".Activate", ActivateArgs{MeetingURL: meetingURL}, &Empty{})
+	if err != nil {
+		return ErrNoRunningInstance.Wrap(err)
+	}
+
+	return nil
+}
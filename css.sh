@@ -11,4 +11,6 @@ if [ ! -d ./gui/styles ]; then
   mkdir -p ./gui/styles
 fi
 
-sass $WATCH_ARG ./sass/gui.scss:./gui/styles/gui.css
+sass $WATCH_ARG ./sass/gui.scss:./gui/styles/gui.css \
+  ./sass/dark.scss:./gui/styles/dark.css \
+  ./sass/high-contrast.scss:./gui/styles/high-contrast.css
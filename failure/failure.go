@@ -0,0 +1,240 @@
+// Package failure contains the errors shared by all the packages of
+// Wahay. Every error has a code, which identifies it in the logs, and
+// a category, which tells what part of the system has to be fixed:
+// Tor, Mumble, the meeting, the configuration, etc. This makes it
+// possible for the user interface, the exit codes and the logs to
+// explain the same problem in the same way.
+//
+// The errors are defined once, as variables of the packages, and can
+// be compared directly. When more information has to be given, the
+// error can wrap its cause, and Is must be used to compare it.
+package failure
+
+import (
+	"fmt"
+)
+
+// Category is the part of the system that failed
+type Category int
+
+const (
+	// CategoryUnknown is the category of the errors from outside Wahay
+	CategoryUnknown Category = iota
+
+	// CategoryTor is the category of the errors using Tor
+	CategoryTor
+
+	// CategoryMumble is the category of the errors using the Mumble client
+	CategoryMumble
+
+	// CategoryMeeting is the category of the errors reaching a meeting
+	CategoryMeeting
+
+	// CategoryHosting is the category of the errors hosting a meeting
+	CategoryHosting
+
+	// CategoryConfiguration is the category of the errors in the configuration
+	CategoryConfiguration
+
+	// CategoryInput is the category of the errors in what the user has given
+	CategoryInput
+)
+
+var categoryNames = map[Category]string{
+	CategoryUnknown:       "unknown",
+	CategoryTor:           "tor",
+	CategoryMumble:        "mumble",
+	CategoryMeeting:       "meeting",
+	CategoryHosting:       "hosting",
+	CategoryConfiguration: "configuration",
+	CategoryInput:         "input",
+}
+
+func (c Category) String() string {
+	if n, ok := categoryNames[c]; ok {
+		return n
+	}
+	return categoryNames[CategoryUnknown]
+}
+
+// The exit codes of Wahay when it can't continue because of an error
+var categoryExitCodes = map[Category]int{
+	CategoryUnknown:       1,
+	CategoryConfiguration: 2,
+	CategoryTor:           3,
+	CategoryMumble:        4,
+	CategoryMeeting:       5,
+	CategoryHosting:       6,
+	CategoryInput:         7,
+}
+
+// ExitCode returns the exit code for the category
+func (c Category) ExitCode() int {
+	if e, ok := categoryExitCodes[c]; ok {
+		return e
+	}
+	return categoryExitCodes[CategoryUnknown]
+}
+
+// Code identifies an error, for example "tor.binary-not-found"
+type Code string
+
+// Error is an error of Wahay
+type Error struct {
+	code     Code
+	category Category
+	message  string
+	hint     string
+	cause    error
+
+	// kind is the error defined by the package, which
+	// is the same for all the errors wrapping a cause
+	kind *Error
+}
+
+// New defines a new error. The hint tells the user what can be done about it
+func New(code Code, category Category, message, hint string) *Error {
+	e := &Error{
+		code:     code,
+		category: category,
+		message:  message,
+		hint:     hint,
+	}
+	e.kind = e
+
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s", e.message, e.cause)
+	}
+	return e.message
+}
+
+// Code returns the code of the error
+func (e *Error) Code() Code {
+	return e.code
+}
+
+// Category returns the category of the error
+func (e *Error) Category() Category {
+	return e.category
+}
+
+// Hint returns what the user can do about the error
+func (e *Error) Hint() string {
+	return e.hint
+}
+
+// Cause returns the error wrapped by this one, if any
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// Wrap returns an error of the same kind, caused by the given error
+func (e *Error) Wrap(cause error) error {
+	if cause == nil {
+		return e
+	}
+
+	return &Error{
+		code:     e.code,
+		category: e.category,
+		message:  e.message,
+		hint:     e.hint,
+		cause:    cause,
+		kind:     e.kind,
+	}
+}
+
+// Wrapf returns an error of the same kind, caused by the formatted message
+func (e *Error) Wrapf(format string, args ...interface{}) error {
+	return e.Wrap(fmt.Errorf(format, args...))
+}
+
+type causer interface {
+	Cause() error
+}
+
+// From returns the first error of Wahay found in the chain of causes
+func From(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok && e != nil {
+			return e, true
+		}
+
+		c, ok := err.(causer)
+		if !ok {
+			return nil, false
+		}
+		err = c.Cause()
+	}
+
+	return nil, false
+}
+
+// Kind returns the error defined by the package for the given error, so
+// it can be compared even when it wraps a cause. Other errors are
+// returned as they are
+func Kind(err error) error {
+	if e, ok := From(err); ok {
+		return e.kind
+	}
+	return err
+}
+
+// Is returns true if the given error, or any of its causes, is of the
+// same kind of the target
+func Is(err, target error) bool {
+	for err != nil {
+		if err == target {
+			return true
+		}
+
+		if e, ok := err.(*Error); ok && e != nil && e.kind == target {
+			return true
+		}
+
+		c, ok := err.(causer)
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+
+	return false
+}
+
+// CodeOf returns the code of the given error, or an
+// empty code if it's not an error of Wahay
+func CodeOf(err error) Code {
+	if e, ok := From(err); ok {
+		return e.code
+	}
+	return ""
+}
+
+// CategoryOf returns the category of the given error
+func CategoryOf(err error) Category {
+	if e, ok := From(err); ok {
+		return e.category
+	}
+	return CategoryUnknown
+}
+
+// HintOf returns what the user can do about the given error
+func HintOf(err error) string {
+	if e, ok := From(err); ok {
+		return e.hint
+	}
+	return ""
+}
+
+// ExitCode returns the exit code of Wahay for the given error
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return CategoryOf(err).ExitCode()
+}
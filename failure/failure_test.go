@@ -0,0 +1,53 @@
+package failure
+
+import (
+	"errors"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type WahayFailureSuite struct{}
+
+var _ = Suite(&WahayFailureSuite{})
+
+func Test(t *testing.T) { TestingT(t) }
+
+var errTest = New("test.failed", CategoryTor, "the test failed", "run it again")
+
+func (s *WahayFailureSuite) Test_Error_keepsItsKindWhenWrappingACause(c *C) {
+	err := errTest.Wrap(errors.New("connection refused"))
+
+	c.Assert(err.Error(), Equals, "the test failed: connection refused")
+	c.Assert(err == error(errTest), Equals, false)
+	c.Assert(Is(err, errTest), Equals, true)
+	c.Assert(Kind(err), Equals, error(errTest))
+	c.Assert(CodeOf(err), Equals, Code("test.failed"))
+	c.Assert(CategoryOf(err), Equals, CategoryTor)
+	c.Assert(HintOf(err), Equals, "run it again")
+}
+
+func (s *WahayFailureSuite) Test_Is_findsTheKindInsideOtherErrors(c *C) {
+	outer := New("test.outer", CategoryMeeting, "the meeting failed", "")
+	err := outer.Wrap(errTest.Wrap(errors.New("timeout")))
+
+	c.Assert(Is(err, errTest), Equals, true)
+	c.Assert(Is(err, outer), Equals, true)
+	c.Assert(CategoryOf(err), Equals, CategoryMeeting)
+}
+
+func (s *WahayFailureSuite) Test_otherErrors_haveNoCodeAndAreUnknown(c *C) {
+	err := errors.New("something else")
+
+	c.Assert(Is(err, errTest), Equals, false)
+	c.Assert(Kind(err), Equals, err)
+	c.Assert(CodeOf(err), Equals, Code(""))
+	c.Assert(CategoryOf(err), Equals, CategoryUnknown)
+	c.Assert(ExitCode(err), Equals, 1)
+	c.Assert(ExitCode(nil), Equals, 0)
+}
+
+func (s *WahayFailureSuite) Test_ExitCode_dependsOnTheCategory(c *C) {
+	c.Assert(ExitCode(errTest), Equals, 3)
+	c.Assert(ExitCode(New("test.mumble", CategoryMumble, "no Mumble", "")), Equals, 4)
+}
@@ -0,0 +1,158 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/invitation"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// addressBook lets the user pick, from the join window, one of the
+// meetings saved before, instead of writing its address again
+type addressBook struct {
+	u       *gtkUI
+	entries []config.AddressBookEntry
+
+	store              gtki.ListStore
+	cmbAddressBook     gtki.ComboBox
+	entAddressLabel    gtki.Entry
+	entMeetingID       gtki.Entry
+	entMeetingPassword gtki.Entry
+	chkClearnetServer  gtki.CheckButton
+}
+
+// newAddressBook shows the address book in the join window. It's
+// hidden when it can't be saved encrypted, since it has the
+// passwords of the meetings
+func (u *gtkUI) newAddressBook(builder *uiBuilder) *addressBook {
+	b := &addressBook{u: u}
+
+	var boxAddressBook, boxSaveAddress gtki.Box
+	builder.getItems(
+		"storeAddressBook", &b.store,
+		"cmbAddressBook", &b.cmbAddressBook,
+		"entAddressLabel", &b.entAddressLabel,
+		"entMeetingID", &b.entMeetingID,
+		"entMeetingPassword", &b.entMeetingPassword,
+		"chkClearnetServer", &b.chkClearnetServer,
+		"boxAddressBook", &boxAddressBook,
+		"boxSaveAddress", &boxSaveAddress,
+	)
+
+	enabled := u.config.CanSaveMeetingRooms()
+	boxAddressBook.SetVisible(enabled && len(u.config.ListAddressBook()) > 0)
+	boxSaveAddress.SetVisible(enabled)
+
+	b.refresh()
+
+	return b
+}
+
+func (b *addressBook) refresh() {
+	b.store.Clear()
+	b.entries = b.u.config.ListAddressBook()
+
+	for _, e := range b.entries {
+		iter := b.store.Append()
+		_ = b.store.SetValue(iter, 0, e.Label)
+	}
+}
+
+func (b *addressBook) chosenEntry() (config.AddressBookEntry, bool) {
+	i := b.cmbAddressBook.GetActive()
+	if i < 0 || i >= len(b.entries) {
+		return config.AddressBookEntry{}, false
+	}
+	return b.entries[i], true
+}
+
+// onChanged fills in the meeting chosen from the address book
+func (b *addressBook) onChanged() {
+	e, ok := b.chosenEntry()
+	if !ok {
+		return
+	}
+
+	b.entMeetingID.SetText(e.Address)
+	b.entMeetingPassword.SetText(e.Password)
+	b.entAddressLabel.SetText(e.Label)
+	b.chkClearnetServer.SetActive(false)
+}
+
+// save keeps the meeting written in the join window in the address book.
+// The fingerprint of the certificate is kept apart from the address, so
+// it's still checked when the user writes the address by hand
+func (b *addressBook) save() {
+	label := getTrimmedText(b.entAddressLabel)
+	if label == "" {
+		b.u.reportError(i18n.Sprintf("Write a name for saving the meeting in the address book"))
+		return
+	}
+
+	inv, err := invitation.Parse(getTrimmedText(b.entMeetingID))
+	if err != nil {
+		b.u.reportError(i18n.Sprintf("Only valid onion meeting IDs can be saved in the address book: %s", invitationErrorTranslator(err)))
+		return
+	}
+
+	password, _ := b.entMeetingPassword.GetText()
+	if password == "" {
+		password = inv.Password
+	}
+
+	fingerprint := inv.Fingerprint
+	inv.Fingerprint = ""
+
+	if !b.u.config.SaveToAddressBook(config.AddressBookEntry{
+		Label:       label,
+		Address:     inv.URL(),
+		Password:    password,
+		Fingerprint: fingerprint,
+	}) {
+		b.u.reportError(i18n.Sprintf("The address book is full. Remove some of the meetings in it first."))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"context": "address book",
+	}).Info("A meeting has been saved in the address book")
+
+	b.u.saveConfigOnly()
+	b.refresh()
+}
+
+func (b *addressBook) remove() {
+	e, ok := b.chosenEntry()
+	if !ok {
+		b.u.reportError(i18n.Sprintf("Choose the meeting you want to remove from the address book"))
+		return
+	}
+
+	b.u.showConfirmation(func(ok bool) {
+		if !ok {
+			return
+		}
+
+		b.u.config.RemoveFromAddressBook(e.Label)
+		b.u.saveConfigOnly()
+		b.entAddressLabel.SetText("")
+		b.refresh()
+	}, i18n.Sprintf("%s will be removed from the address book.", e.Label))
+}
+
+// pinFingerprint gives the invitation the fingerprint saved in the
+// address book for its meeting, when it doesn't have one of its own
+func (b *addressBook) pinFingerprint(inv *invitation.Invitation) {
+	if inv.Fingerprint != "" {
+		return
+	}
+
+	for _, e := range b.entries {
+		saved, err := invitation.Parse(e.Address)
+		if err == nil && saved.MeetingID() == inv.MeetingID() {
+			inv.Fingerprint = e.Fingerprint
+			return
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package gui
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rttSamples is the amount of connections used to measure the
+// latency to the meeting, without counting the first one
+const rttSamples = 3
+
+// audioPresetChoices are the audio presets that can be chosen in the
+// settings, in the same order they are shown to the user. The first
+// one chooses the preset from the latency to the meeting
+var audioPresetChoices = []string{
+	"",
+	client.AudioPresetHigh,
+	client.AudioPresetBalanced,
+	client.AudioPresetLow,
+	client.AudioPresetLowLatency,
+	client.AudioPresetLowBandwidth,
+}
+
+func audioPresetName(id string) string {
+	switch id {
+	case client.AudioPresetHigh:
+		return i18n.Sprintf("High")
+	case client.AudioPresetBalanced:
+		return i18n.Sprintf("Balanced")
+	case client.AudioPresetLow:
+		return i18n.Sprintf("Low")
+	case client.AudioPresetLowLatency:
+		return i18n.Sprintf("Low latency")
+	case client.AudioPresetLowBandwidth:
+		return i18n.Sprintf("Low bandwidth")
+	}
+	return i18n.Sprintf("Automatic")
+}
+
+func pushToTalkKeyName(key string) string {
+	switch key {
+	case "right-ctrl":
+		return i18n.Sprintf("Right Ctrl")
+	case "left-ctrl":
+		return i18n.Sprintf("Left Ctrl")
+	case "right-alt":
+		return i18n.Sprintf("Right Alt")
+	case "left-alt":
+		return i18n.Sprintf("Left Alt")
+	case "right-shift":
+		return i18n.Sprintf("Right Shift")
+	case "left-shift":
+		return i18n.Sprintf("Left Shift")
+	case "caps-lock":
+		return i18n.Sprintf("Caps Lock")
+	case "scroll-lock":
+		return i18n.Sprintf("Scroll Lock")
+	case "pause":
+		return i18n.Sprintf("Pause")
+	case "insert":
+		return i18n.Sprintf("Insert")
+	case "menu":
+		return i18n.Sprintf("Menu")
+	}
+
+	if n, err := strconv.Atoi(strings.TrimPrefix(key, "f")); err == nil && strings.HasPrefix(key, "f") {
+		return fmt.Sprintf("F%d", n)
+	}
+
+	// Keys given by their key code in the configuration file
+	return i18n.Sprintf("Key code %s", key)
+}
+
+// noiseSuppressionChoices are the decibels of noise suppression
+// that can be chosen in the settings. Zero keeps the Mumble default
+var noiseSuppressionChoices = []int{0, 20, 30, 40}
+
+func noiseSuppressionName(db int) string {
+	switch db {
+	case 20:
+		return i18n.Sprintf("Low")
+	case 30:
+		return i18n.Sprintf("Medium")
+	case 40:
+		return i18n.Sprintf("High")
+	}
+	return i18n.Sprintf("Default")
+}
+
+// audioDecision is the audio preset used in the current meeting
+// and the reason it was chosen, so it can be shown to the user
+type audioDecision struct {
+	preset    client.AudioPreset
+	automatic bool
+	rtt       time.Duration
+}
+
+func (d audioDecision) description() string {
+	name := audioPresetName(d.preset.ID)
+
+	if !d.automatic {
+		return i18n.Sprintf("Audio quality: %s (chosen in the settings)", name)
+	}
+
+	if d.rtt == 0 {
+		return i18n.Sprintf("Audio quality: %s (the latency couldn't be measured)", name)
+	}
+
+	return i18n.Sprintf("Audio quality: %s (%d ms of latency)", name, d.rtt/time.Millisecond)
+}
+
+// chooseAudioPreset returns the preset configured by the user or, when
+// it's automatic, the one that fits the latency to the meeting
+func (u *gtkUI) chooseAudioPreset(t tor.Instance, data hosting.MeetingData) audioDecision {
+	id := u.config.GetAudioPreset()
+	if id != "" {
+		p, err := client.AudioPresetByID(id)
+		if err == nil {
+			return audioDecision{preset: p}
+		}
+
+		log.WithFields(log.Fields{
+			"context": "audio",
+			"preset":  id,
+		}).Warning("the configured audio preset is not known, choosing it automatically")
+	}
+
+	start := time.Now()
+	rtt, err := client.MeasureRTT(t, net.JoinHostPort(data.MeetingID, strconv.Itoa(data.Port)), rttSamples)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "audio",
+		}).Errorf("the latency to the meeting can't be measured: %s", err)
+
+		// We don't know anything about the circuit, so
+		// we use the preset that needs less of it
+		p, _ := client.AudioPresetByID(client.AudioPresetLow)
+		return audioDecision{preset: p, automatic: true}
+	}
+
+	config.LogDuration("join: measure the latency", start)
+
+	log.WithFields(log.Fields{
+		"context": "audio",
+		"rtt":     rtt,
+	}).Debug("Measured the latency to the meeting")
+
+	return audioDecision{
+		preset:    client.AudioPresetForRTT(rtt),
+		automatic: true,
+		rtt:       rtt,
+	}
+}
+
+// showAudioDecision shows in the meeting window the
+// audio preset that is being used in the meeting
+func (u *gtkUI) showAudioDecision(builder *uiBuilder) {
+	l := builder.get("lblAudioPreset").(gtki.Label)
+	l.SetText(u.currentAudio.description())
+}
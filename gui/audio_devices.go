@@ -0,0 +1,58 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deviceChoices are the names of the devices shown in a combo box, in
+// the same order. The first one is empty, for the default device of the
+// system. A device that was chosen before is kept as a choice when it's
+// not connected, so choosing it again isn't needed when it is
+type deviceChoices []string
+
+// listAudioDevices returns the devices of the sound server, or
+// none when they can't be listed, like in the systems without
+// PipeWire or PulseAudio
+func listAudioDevices() client.AudioDevices {
+	devices, err := client.ListAudioDevices()
+	if err != nil {
+		log.WithFields(errorFields(err)).Warningf("the audio devices can't be listed: %s", err)
+	}
+	return devices
+}
+
+func fillDeviceChoices(cmb gtki.ComboBoxText, devices []client.AudioDevice, current string) deviceChoices {
+	choices := deviceChoices{""}
+	cmb.AppendText(i18n.Sprintf("Default device"))
+
+	active := 0
+	for _, d := range devices {
+		if d.Name == current {
+			active = len(choices)
+		}
+		choices = append(choices, d.Name)
+		cmb.AppendText(d.Label())
+	}
+
+	if current != "" && active == 0 {
+		active = len(choices)
+		choices = append(choices, current)
+		cmb.AppendText(i18n.Sprintf("%s (not connected)", current))
+	}
+
+	cmb.SetActive(active)
+
+	return choices
+}
+
+// chosen is the name of the device chosen in the combo box,
+// which is empty for the default device
+func (c deviceChoices) chosen(cmb gtki.ComboBoxText) string {
+	if i := cmb.GetActive(); i >= 0 && i < len(c) {
+		return c[i]
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package gui
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/invitation"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	calendarFileDefaultName = "meeting" + invitation.CalendarFileExtension
+	calendarDateFormat      = "2006-01-02"
+	calendarTimeFormat      = "15:04"
+)
+
+// showScheduleMeeting lets the host save a calendar entry for the
+// meeting, with the invitation included in its description
+func (h *hostData) showScheduleMeeting() {
+	builder := h.u.g.uiBuilderFor("ScheduleMeetingWindow")
+	builder.i18nProperties(
+		"title", "scheduleMeetingWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"label", "lblMeetingTitle",
+		"label", "lblDate",
+		"label", "lblTime",
+		"label", "lblDuration",
+		"label", "lblValidation",
+		"placeholder", "entTitle",
+		"placeholder", "entDate",
+		"placeholder", "entTime",
+		"button", "btnCancel",
+		"button", "btnSave")
+
+	win := builder.get("scheduleMeetingWindow").(gtki.Window)
+	entTitle := builder.get("entTitle").(gtki.Entry)
+	entDate := builder.get("entDate").(gtki.Entry)
+	entTime := builder.get("entTime").(gtki.Entry)
+	spnDuration := builder.get("spnDuration").(gtki.SpinButton)
+	lblValidation := builder.get("lblValidation").(gtki.Label)
+
+	// By default, we propose the next full hour
+	next := time.Now().Truncate(time.Hour).Add(time.Hour)
+	entDate.SetText(next.Format(calendarDateFormat))
+	entTime.SetText(next.Format(calendarTimeFormat))
+
+	if h.u.currentWindow != nil {
+		win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_cancel": win.Destroy,
+		"on_save": func() {
+			date, _ := entDate.GetText()
+			hour, _ := entTime.GetText()
+
+			start, err := parseScheduledTime(date, hour)
+			if err != nil {
+				lblValidation.SetVisible(true)
+				return
+			}
+
+			title, _ := entTitle.GetText()
+			duration := time.Duration(spnDuration.GetValueAsInt()) * time.Minute
+
+			if h.saveCalendarEntry(strings.TrimSpace(title), start, duration) {
+				win.Destroy()
+			}
+		},
+	})
+
+	win.Present()
+	win.Show()
+}
+
+func parseScheduledTime(date, hour string) (time.Time, error) {
+	return time.ParseInLocation(calendarDateFormat+" "+calendarTimeFormat,
+		strings.TrimSpace(date)+" "+strings.TrimSpace(hour), time.Local)
+}
+
+func (h *hostData) saveCalendarEntry(title string, start time.Time, duration time.Duration) bool {
+	return h.u.saveCalendarEntry(h.getInvitationData(), title, start, duration)
+}
+
+// saveCalendarEntry asks where to save the calendar entry with
+// the invitation. It returns false when it was not saved
+func (u *gtkUI) saveCalendarEntry(inv *invitation.Invitation, title string, start time.Time, duration time.Duration) bool {
+	inv.Title = title
+
+	content, err := inv.ExportICalendar(start, duration)
+	if err != nil {
+		u.reportError(i18n.Sprintf("The calendar entry can't be created: %s", invitationErrorTranslator(err)))
+		return false
+	}
+
+	fileName, ok := u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Save calendar entry"), calendarFileDefaultName)
+	if !ok {
+		return false
+	}
+
+	if !strings.HasSuffix(strings.ToLower(fileName), invitation.CalendarFileExtension) {
+		fileName = fileName + invitation.CalendarFileExtension
+	}
+
+	err = ioutil.WriteFile(fileName, content, 0600)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  fileName,
+			"error": err,
+		}).Error("The calendar entry can't be saved")
+		u.reportError(i18n.Sprintf("The calendar entry can't be saved: %s", err.Error()))
+		return false
+	}
+
+	return true
+}
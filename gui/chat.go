@@ -0,0 +1,142 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type chatWindow struct {
+	u    *gtkUI
+	chat hosting.Chat
+	stop func()
+
+	win         gtki.Window
+	buffer      gtki.TextBuffer
+	scroll      gtki.ScrolledWindow
+	entMessage  gtki.Entry
+	btnSend     gtki.Button
+	lblStatus   gtki.Label
+	hasMessages bool
+}
+
+// showHostChat opens the chat of the meeting we are hosting, or
+// brings it to the front when it's already open
+func (h *hostData) showHostChat() {
+	if h.chatWindow != nil {
+		h.chatWindow.win.Present()
+		return
+	}
+
+	c := h.service.Chat()
+	if c == nil {
+		return
+	}
+
+	builder := h.u.g.uiBuilderFor("ChatWindow")
+	builder.i18nProperties(
+		"title", "chatWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"placeholder", "entMessage",
+		"button", "btnSend",
+		"button", "btnClose")
+
+	w := &chatWindow{
+		u:    h.u,
+		chat: c,
+	}
+
+	builder.getItems(
+		"chatWindow", &w.win,
+		"chatBuffer", &w.buffer,
+		"scrollMessages", &w.scroll,
+		"entMessage", &w.entMessage,
+		"btnSend", &w.btnSend,
+		"lblStatus", &w.lblStatus,
+	)
+
+	if h.u.currentWindow != nil {
+		w.win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_send":  w.send,
+		"on_close": w.win.Destroy,
+		"on_destroy": func() {
+			w.stop()
+			h.chatWindow = nil
+		},
+	})
+
+	for _, m := range c.Messages() {
+		w.show(m)
+	}
+	w.stop = c.WhenMessage(func(m hosting.ChatMessage) {
+		w.u.doInUIThread(func() {
+			w.show(m)
+		})
+	})
+
+	h.chatWindow = w
+
+	w.win.Present()
+	w.win.Show()
+}
+
+// show adds the message at the end of the chat, which
+// must be called from the UI thread
+func (w *chatWindow) show(m hosting.ChatMessage) {
+	author := m.Author
+	switch {
+	case m.Session == 0:
+		author = i18n.Sprintf("You")
+	case author == "":
+		author = i18n.Sprintf("Unknown participant")
+	}
+	if m.Private {
+		author = i18n.Sprintf("%s (only to you)", author)
+	}
+
+	line := i18n.Sprintf("[%s] %s: %s", m.Time.Format("15:04"), author, m.Text)
+	if w.hasMessages {
+		line = "\n" + line
+	}
+	w.hasMessages = true
+
+	w.buffer.Insert(w.buffer.GetEndIter(), line)
+
+	// The size of the text is only known once it has been
+	// drawn, so the chat is scrolled to the end after that
+	w.u.doInUIThread(func() {
+		adj := w.scroll.GetVAdjustment()
+		adj.SetValue(adj.GetUpper() - adj.GetPageSize())
+	})
+}
+
+func (w *chatWindow) send() {
+	text := getTrimmedText(w.entMessage)
+	if text == "" {
+		return
+	}
+
+	w.btnSend.SetSensitive(false)
+	w.lblStatus.SetText("")
+
+	go func() {
+		err := w.chat.Send(text)
+
+		w.u.doInUIThread(func() {
+			w.btnSend.SetSensitive(true)
+
+			if err != nil {
+				log.WithFields(errorFields(err)).Errorf("the message can't be sent: %s", err)
+				w.lblStatus.SetText(i18n.Sprintf("The message can't be sent: %s", err))
+				return
+			}
+
+			w.entMessage.SetText("")
+		})
+	}()
+}
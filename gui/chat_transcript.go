@@ -0,0 +1,146 @@
+package gui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const markdownExtension = ".md"
+
+func transcriptFileDefaultName() string {
+	return "wahay-" + time.Now().Format("2006-01-02-1504") + client.TranscriptFileExtension
+}
+
+// chatTranscriptKey returns the key of the chat transcripts,
+// creating it the first time a transcript is recorded
+func (u *gtkUI) chatTranscriptKey() ([]byte, error) {
+	if key := u.config.GetChatTranscriptKey(); len(key) > 0 {
+		return key, nil
+	}
+
+	key, err := client.NewTranscriptKey()
+	if err != nil {
+		return nil, err
+	}
+
+	u.config.SetChatTranscriptKey(key)
+	u.saveConfigOnly()
+
+	return key, nil
+}
+
+// startChatTranscript records the chat of the meeting we are
+// hosting, when the user wants it. It's stopped when the meeting ends
+func (h *hostData) startChatTranscript() {
+	c := h.service.Chat()
+	if c == nil || !h.u.config.CanKeepChatTranscripts() {
+		return
+	}
+
+	key, err := h.u.chatTranscriptKey()
+	if err == nil {
+		err = os.MkdirAll(config.TranscriptsDir(), 0700)
+	}
+
+	var t client.Transcript
+	if err == nil {
+		t, err = client.OpenTranscript(filepath.Join(config.TranscriptsDir(), transcriptFileDefaultName()), key)
+	}
+
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the chat of the meeting can't be recorded: %s", err)
+		return
+	}
+
+	stop := c.WhenMessage(func(m hosting.ChatMessage) {
+		err := t.Record(h.transcriptMessage(m))
+		if err != nil && failure.Kind(err) != client.ErrTranscriptClosed {
+			log.WithFields(errorFields(err)).Errorf("the message can't be added to the transcript: %s", err)
+		}
+	})
+
+	h.stopTranscript = func() {
+		stop()
+		_ = t.Close()
+	}
+}
+
+// transcriptMessage is the message of the chat as it's kept in the transcript
+func (h *hostData) transcriptMessage(m hosting.ChatMessage) client.ChatMessage {
+	author := m.Author
+	if m.Session == 0 {
+		author = h.meetingUsername
+		if author == "" {
+			author = i18n.Sprintf("Host")
+		}
+	}
+
+	channel := ""
+	if m.Private {
+		channel = i18n.Sprintf("private message")
+	}
+
+	return client.ChatMessage{
+		Time:    m.Time,
+		Author:  author,
+		Channel: channel,
+		Text:    m.Text,
+	}
+}
+
+// exportTranscript saves a chat transcript as a Markdown file, next
+// to the encrypted one unless the user chooses another place
+func (s *settings) exportTranscript() {
+	key := s.u.config.GetChatTranscriptKey()
+	if len(key) == 0 {
+		s.u.reportError(i18n.Sprintf("No chat has been recorded yet"))
+		return
+	}
+
+	fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Export a transcript"), "")
+	if !ok {
+		return
+	}
+
+	messages, err := client.ReadTranscript(fileName, key)
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the transcript can't be read: %s", err)
+		s.u.reportError(i18n.Sprintf("The transcript can't be exported: %s", transcriptErrorTranslator(err)))
+		return
+	}
+
+	defaultName := strings.TrimSuffix(filepath.Base(fileName), client.TranscriptFileExtension) + markdownExtension
+	target, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Export a transcript"), defaultName)
+	if !ok {
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Clean(target), client.ExportTranscriptMarkdown(i18n.Sprintf("Wahay meeting"), messages), 0600)
+	if err != nil {
+		s.u.reportError(i18n.Sprintf("The transcript can't be exported: %s", err))
+		return
+	}
+
+	go s.u.messageToLabel(s.lblTranscriptMessage, i18n.Sprintf("The transcript has been exported"), 5)
+}
+
+func transcriptErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case client.ErrCorruptedTranscript:
+		return i18n.Sprintf("the transcript is not valid, or it was recorded with another configuration")
+	case client.ErrInvalidTranscriptKey:
+		return i18n.Sprintf("the key of the transcripts is not valid")
+	}
+	return err.Error()
+}
@@ -1,13 +1,23 @@
 package gui
 
-import "sync"
+import (
+	"sync"
 
-func (u *gtkUI) ensureDependencies(onFinish func()) {
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+func (u *gtkUI) ensureDependencies(onTorProgress func(tor.BootstrapProgress), onFinish func()) {
 	var wg sync.WaitGroup
 
-	u.ensureTor(&wg)
+	// Tor bootstrap, the Mumble configuration and the certificate of
+	// the servers don't depend on each other, so they run at the same time
+	u.ensureTor(&wg, onTorProgress)
 	u.ensureMumble(&wg)
 
+	// Nothing on startup needs the servers, so we don't wait for them
+	u.onExit(u.cleanupPreparedServers)
+	u.prepareServers()
+
 	wg.Wait()
 
 	onFinish()
@@ -0,0 +1,148 @@
+package gui
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// latencyCheckInterval is how often the latency to
+	// the meeting is measured while the user is in it
+	latencyCheckInterval = time.Minute
+
+	// degradedLatencyFactor and minDegradedLatency decide when the
+	// latency is bad enough, compared with the one measured when
+	// joining, for building new circuits automatically
+	degradedLatencyFactor = 3
+	minDegradedLatency    = 1500 * time.Millisecond
+
+	// minCircuitsRenewalInterval avoids renewing the circuits again
+	// and again when the latency is bad because of the meeting itself
+	minCircuitsRenewalInterval = 5 * time.Minute
+)
+
+// circuitsRenewal remembers when the circuits to the
+// current meeting were renewed for the last time
+type circuitsRenewal struct {
+	sync.Mutex
+	last    time.Time
+	running bool
+}
+
+// start returns false when the circuits are being renewed already,
+// or when they were renewed a moment ago and automatic is true
+func (r *circuitsRenewal) start(automatic bool) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.running || (automatic && time.Since(r.last) < minCircuitsRenewalInterval) {
+		return false
+	}
+
+	r.running = true
+	return true
+}
+
+func (r *circuitsRenewal) finish() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.running = false
+	r.last = time.Now()
+}
+
+func isOnionMeeting(data hosting.MeetingData) bool {
+	return strings.HasSuffix(data.MeetingID, ".onion")
+}
+
+// renewMeetingCircuits makes Tor connect to the meeting through new
+// circuits. Mumble reconnects by itself when its connection is closed
+func (u *gtkUI) renewMeetingCircuits(builder *uiBuilder, r *circuitsRenewal, data hosting.MeetingData, automatic bool) {
+	if !r.start(automatic) {
+		return
+	}
+
+	btnRenewCircuits := builder.get("btnRenewCircuits").(gtki.Button)
+	u.doInUIThread(func() {
+		btnRenewCircuits.SetSensitive(false)
+	})
+
+	go func() {
+		defer r.finish()
+
+		err := u.torInstance().RenewCircuits(data.MeetingID)
+
+		u.doInUIThread(func() {
+			btnRenewCircuits.SetSensitive(true)
+		})
+
+		if err != nil {
+			log.WithFields(errorFields(err)).Errorf("the Tor circuits can't be renewed: %s", err)
+			if !automatic {
+				u.doInUIThread(func() {
+					u.reportError(i18n.Sprintf("The connection to the meeting can't be renewed: %s", describeError(err)))
+				})
+			}
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"context":   "circuits",
+			"automatic": automatic,
+		}).Info("The Tor circuits to the meeting have been renewed")
+	}()
+}
+
+// watchMeetingLatency measures the latency to the meeting while the user
+// is in it, and renews the circuits when it becomes much worse than it was.
+// When the meeting can't be reached anymore, it reconnects to it
+func (u *gtkUI) watchMeetingLatency(builder *uiBuilder, r *circuitsRenewal, m *meetingSupervisor) {
+	address := meetingAddress(m.data)
+	baseline := u.currentAudio.rtt
+
+	ticker := time.NewTicker(latencyCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if m.IsClosed() || u.currentMumble != m {
+			return
+		}
+
+		rtt, err := client.MeasureRTT(u.torInstance(), address, 1)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "circuits",
+			}).Debugf("the latency to the meeting can't be measured: %s", err)
+			m.recoverConnection(address)
+			continue
+		}
+
+		m.stats.setRoundTrip(rtt)
+
+		if baseline == 0 {
+			baseline = rtt
+			continue
+		}
+
+		if rtt < minDegradedLatency || rtt < baseline*degradedLatencyFactor {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"context":  "circuits",
+			"rtt":      rtt,
+			"baseline": baseline,
+		}).Warning("The latency to the meeting has degraded, renewing the Tor circuits")
+
+		u.notifyEvent(notificationConnectionDegraded, i18n.Sprintf("The connection to the meeting is slow"),
+			i18n.Sprintf("Wahay is connecting to the meeting through new Tor circuits."))
+		u.renewMeetingCircuits(builder, r, m.data, true)
+	}
+}
@@ -0,0 +1,356 @@
+package gui
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/control"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
+)
+
+var (
+	errMeetingsNotReady    = errors.New("Wahay is not ready to host or join meetings")
+	errAlreadyInMeeting    = errors.New("there is already a meeting in progress")
+	errNoMeetingInProgress = errors.New("there is no meeting in progress")
+	errUsernameRequired    = errors.New("the username is required")
+	errNotHosting          = errors.New("there is no hosted meeting in progress")
+	errNoParticipants      = errors.New("the participants of the meeting can't be followed")
+	errNoChat              = errors.New("the chat of the meeting is not available")
+)
+
+// startControlInterface lets other applications drive this session
+// through a local socket, when Wahay was started with -control
+func (u *gtkUI) startControlInterface() {
+	if !*config.Control {
+		return
+	}
+
+	s, err := control.Listen(config.ControlSocketPath(), &controlAPI{u})
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the control interface can't be started: %s", err)
+		return
+	}
+
+	log.Infof("Listening for commands at: %s", config.ControlSocketPath())
+
+	u.onExit(s.Close)
+}
+
+// controlAPI does what other applications ask through the control
+// interface, the same way the user would do it in the GUI. It's called
+// outside of the UI thread
+type controlAPI struct {
+	u *gtkUI
+}
+
+func (c *controlAPI) inMeeting() bool {
+	return c.u.currentHost != nil || c.u.currentMumble != nil
+}
+
+func (c *controlAPI) HostMeeting(password string) (string, error) {
+	u := c.u
+
+	if !u.meetingsEnabled {
+		return "", errMeetingsNotReady
+	}
+
+	if c.inMeeting() {
+		return "", errAlreadyInMeeting
+	}
+
+	if u.servers == nil {
+		var err error
+		u.servers, err = u.takePreparedServers()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	h := &hostData{
+		u:               u,
+		meetingPassword: password,
+	}
+
+	echan := make(chan error)
+	go h.createNewService(echan)
+
+	err := <-echan
+	if err != nil {
+		return "", err
+	}
+
+	err = h.service.NewConferenceRoom(password, hosting.SuperUserData{})
+	if err != nil {
+		_ = h.service.Close()
+		u.servers = nil
+		u.prepareServers()
+		return "", err
+	}
+
+	u.currentHost = h
+	h.nextClientAuthKey()
+
+	h.service.WhenPublished(func(error) {
+		h.notifyWebhook()
+	})
+
+	u.hideMainWindow()
+	u.doInUIThread(h.showMeetingControls)
+
+	return h.getInvitation(), nil
+}
+
+func (c *controlAPI) JoinMeeting(invite, username, password string) error {
+	u := c.u
+
+	if !u.meetingsEnabled {
+		return errMeetingsNotReady
+	}
+
+	if c.inMeeting() {
+		return errAlreadyInMeeting
+	}
+
+	// Meetings on the Internet need the confirmation of the user,
+	// so only onion services can be joined from other applications
+	inv, err := invitation.Parse(invite)
+	if err != nil {
+		return errors.New(invitationErrorTranslator(err))
+	}
+
+	if username != "" {
+		inv.Username = username
+	}
+	if password != "" {
+		inv.Password = password
+	}
+	if inv.Username == "" {
+		return errUsernameRequired
+	}
+
+	data := meetingDataFor(inv)
+
+	u.hideMainWindow()
+	go u.joinMeetingHandler(data)
+
+	return nil
+}
+
+func (c *controlAPI) EndMeeting() error {
+	u := c.u
+
+	if h := u.currentHost; h != nil {
+		// The host is still in the meeting with Mumble
+		if h.mumble != nil && u.currentMumble != nil {
+			h.next = h.uiActionFinishMeeting
+			go h.mumble.Close()
+			return nil
+		}
+
+		u.doInUIThread(h.finishMeetingReal)
+		return nil
+	}
+
+	if m := u.currentMumble; m != nil {
+		go m.Close()
+		return nil
+	}
+
+	return errNoMeetingInProgress
+}
+
+func (c *controlAPI) Status() control.Status {
+	u := c.u
+
+	s := control.Status{
+		TorReady:  u.tor != nil,
+		InMeeting: u.currentMumble != nil,
+	}
+
+	if h := u.currentHost; h != nil && h.service != nil {
+		s.Hosting = true
+		s.MeetingID = h.service.URL()
+		s.Invitation = h.getInvitation()
+	}
+
+	return s
+}
+
+func (c *controlAPI) SetMuted(muted bool) error {
+	if c.u.currentMumble == nil {
+		return errNoMeetingInProgress
+	}
+
+	cl := c.u.client
+	if cl == nil || !cl.IsValid() {
+		return client.ErrMumbleNotAvailable
+	}
+
+	return cl.SetMuted(muted)
+}
+
+func (c *controlAPI) participants() (hosting.Participants, error) {
+	h := c.u.currentHost
+	if h == nil || h.service == nil {
+		return nil, errNotHosting
+	}
+
+	p := h.service.Participants()
+	if p == nil {
+		return nil, errNoParticipants
+	}
+
+	return p, nil
+}
+
+func (c *controlAPI) Participants() ([]control.Participant, error) {
+	p, err := c.participants()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []control.Participant{}
+	for _, pp := range p.List() {
+		result = append(result, control.Participant{
+			Session:     pp.Session,
+			Name:        pp.Name,
+			Muted:       pp.Muted,
+			Deafened:    pp.Deafened,
+			Talking:     pp.Talking,
+			Waiting:     pp.Waiting,
+			CoModerator: pp.CoModerator,
+		})
+	}
+
+	return result, nil
+}
+
+func (c *controlAPI) MuteParticipant(session uint32, muted bool) error {
+	p, err := c.participants()
+	if err != nil {
+		return err
+	}
+
+	return p.Mute(session, muted)
+}
+
+func (c *controlAPI) DeafenParticipant(session uint32, deafened bool) error {
+	p, err := c.participants()
+	if err != nil {
+		return err
+	}
+
+	return p.Deafen(session, deafened)
+}
+
+func (c *controlAPI) KickParticipant(session uint32) error {
+	p, err := c.participants()
+	if err != nil {
+		return err
+	}
+
+	return p.Kick(session)
+}
+
+func (c *controlAPI) BanParticipant(session uint32, d time.Duration) error {
+	p, err := c.participants()
+	if err != nil {
+		return err
+	}
+
+	return p.Ban(session, d)
+}
+
+func (c *controlAPI) SetCoModerator(session uint32, coModerator bool) error {
+	p, err := c.participants()
+	if err != nil {
+		return err
+	}
+
+	return p.SetCoModerator(session, coModerator)
+}
+
+func (c *controlAPI) MeetingStats() (control.MeetingStats, error) {
+	s := c.u.currentMeetingStats()
+	if s == nil {
+		return control.MeetingStats{}, errNoMeetingInProgress
+	}
+
+	return s.snapshot(), nil
+}
+
+func (c *controlAPI) chat() (hosting.Chat, error) {
+	h := c.u.currentHost
+	if h == nil || h.service == nil {
+		return nil, errNotHosting
+	}
+
+	ch := h.service.Chat()
+	if ch == nil {
+		return nil, errNoChat
+	}
+
+	return ch, nil
+}
+
+func (c *controlAPI) ChatMessages() ([]control.ChatMessage, error) {
+	ch, err := c.chat()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []control.ChatMessage{}
+	for _, m := range ch.Messages() {
+		result = append(result, control.ChatMessage{
+			Time:    m.Time,
+			Session: m.Session,
+			Author:  m.Author,
+			Text:    m.Text,
+			Private: m.Private,
+		})
+	}
+
+	return result, nil
+}
+
+func (c *controlAPI) SendChatMessage(text string) error {
+	ch, err := c.chat()
+	if err != nil {
+		return err
+	}
+
+	return ch.Send(text)
+}
+
+func (c *controlAPI) SuperUser() (control.SuperUser, error) {
+	h := c.u.currentHost
+	if h == nil || h.service == nil {
+		return control.SuperUser{}, errNotHosting
+	}
+
+	su := h.service.SuperUser()
+	return control.SuperUser{Username: su.Username, Password: su.Password}, nil
+}
+
+func (c *controlAPI) RotateSuperUserPassword() (control.SuperUser, error) {
+	h := c.u.currentHost
+	if h == nil || h.service == nil {
+		return control.SuperUser{}, errNotHosting
+	}
+
+	password, err := h.service.RotateSuperUserPassword()
+	if err != nil {
+		return control.SuperUser{}, err
+	}
+
+	c.u.doInUIThread(func() {
+		h.superUserChanged(password)
+	})
+
+	return c.SuperUser()
+}
@@ -0,0 +1,146 @@
+package gui
+
+import (
+	"context"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/failure"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deviceCheckDuration is how long the input device is recorded
+const deviceCheckDuration = 3 * time.Second
+
+type deviceCheckWindow struct {
+	u *gtkUI
+
+	win             gtki.Window
+	cmbInputDevice  gtki.ComboBoxText
+	cmbOutputDevice gtki.ComboBoxText
+	btnCheck        gtki.Button
+	lblStatus       gtki.Label
+
+	inputDevices  deviceChoices
+	outputDevices deviceChoices
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// showDeviceCheck opens the window where the user records a few
+// seconds from the microphone and hears them back, and chooses
+// the audio devices that Mumble uses in the meeting
+func (u *gtkUI) showDeviceCheck(parent gtki.Window) {
+	builder := u.g.uiBuilderFor("DeviceCheckWindow")
+	builder.i18nProperties(
+		"title", "deviceCheckWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"label", "lblInputDevice",
+		"label", "lblOutputDevice",
+		"button", "btnClose",
+		"button", "btnSaveDevices",
+		"button", "btnCheck")
+
+	w := &deviceCheckWindow{u: u}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	builder.getItems(
+		"deviceCheckWindow", &w.win,
+		"cmbInputDevice", &w.cmbInputDevice,
+		"cmbOutputDevice", &w.cmbOutputDevice,
+		"btnCheck", &w.btnCheck,
+		"lblStatus", &w.lblStatus,
+	)
+
+	audio := u.config.GetAudioSettings()
+	devices := listAudioDevices()
+	w.inputDevices = fillDeviceChoices(w.cmbInputDevice, devices.Inputs, audio.InputDevice)
+	w.outputDevices = fillDeviceChoices(w.cmbOutputDevice, devices.Outputs, audio.OutputDevice)
+
+	if parent != nil {
+		w.win.SetTransientFor(parent)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_check":   w.check,
+		"on_save":    w.save,
+		"on_close":   w.win.Destroy,
+		"on_destroy": w.cancel,
+	})
+
+	w.win.Present()
+	w.win.Show()
+}
+
+func (w *deviceCheckWindow) check() {
+	input := w.inputDevices.chosen(w.cmbInputDevice)
+	output := w.outputDevices.chosen(w.cmbOutputDevice)
+
+	w.btnCheck.SetSensitive(false)
+	w.lblStatus.SetText(i18n.Sprintf("Say something, the microphone is being recorded..."))
+
+	go func() {
+		sample, err := client.RecordAudioSample(w.ctx, input, deviceCheckDuration)
+		if err == nil && w.ctx.Err() == nil {
+			w.u.doInUIThread(func() {
+				w.lblStatus.SetText(i18n.Sprintf("Playing the recording..."))
+			})
+			err = sample.Play(w.ctx, output)
+		}
+
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		w.u.doInUIThread(func() {
+			w.btnCheck.SetSensitive(true)
+			w.showResult(sample, err)
+		})
+	}()
+}
+
+func (w *deviceCheckWindow) showResult(sample *client.AudioSample, err error) {
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the audio devices can't be checked: %s", err)
+		w.lblStatus.SetText(deviceCheckErrorTranslator(err))
+		return
+	}
+
+	if sample.IsSilent() {
+		w.lblStatus.SetText(i18n.Sprintf("Nothing was heard from the microphone. " +
+			"Check that it's not muted, or choose another input device."))
+		return
+	}
+
+	w.lblStatus.SetText(i18n.Sprintf("If you heard yourself, these devices are ready for the meeting. " +
+		"If you didn't, choose another output device."))
+}
+
+// save keeps the devices in the audio settings, which are written
+// in the configuration of Mumble every time a meeting is joined
+func (w *deviceCheckWindow) save() {
+	audio := w.u.config.GetAudioSettings()
+	audio.InputDevice = w.inputDevices.chosen(w.cmbInputDevice)
+	audio.OutputDevice = w.outputDevices.chosen(w.cmbOutputDevice)
+	w.u.config.SetAudioSettings(audio)
+	w.u.saveConfigOnly()
+
+	w.win.Destroy()
+}
+
+func deviceCheckErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case client.ErrNoAudioTools:
+		return i18n.Sprintf("The audio devices can't be checked, since neither pacat nor pw-cat are installed. " +
+			"Install the utilities of PulseAudio or PipeWire.")
+	case client.ErrAudioRecordingFailed:
+		return i18n.Sprintf("The input device can't be recorded. Check the name of the input device.")
+	case client.ErrAudioPlaybackFailed:
+		return i18n.Sprintf("The recording can't be played in the output device. Check the name of the output device.")
+	}
+	return err.Error()
+}
@@ -0,0 +1,138 @@
+package gui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// diagnosticDetails returns what only the GUI knows about the
+// current session, to be added to the diagnostic bundle
+func (u *gtkUI) diagnosticDetails() map[string]string {
+	details := map[string]string{
+		"gtk":              fmt.Sprintf("%d.%d", u.g.gtk.GetMajorVersion(), u.g.gtk.GetMinorVersion()),
+		"meetings enabled": strconv.FormatBool(u.meetingsEnabled),
+		"mumble binary":    u.config.MumbleBinaryPath(),
+	}
+
+	if txt := u.errorHandler.getStatusErrorsText(); txt != "" {
+		details["startup errors"] = txt
+	}
+
+	details["tor bootstrap"] = u.torProgress.String()
+
+	if u.lastMumbleCrash != "" {
+		details["last mumble crash"] = u.lastMumbleCrash
+	}
+
+	return details
+}
+
+// addVersionDetails adds the versions of Tor and Mumble, which are found
+// out by running them, so it's done when the bundle is written
+func (u *gtkUI) addVersionDetails(details map[string]string) {
+	details["tor version"] = unknownIfEmpty(u.torVersion())
+
+	mumbleVersion := ""
+	if u.client != nil {
+		mumbleVersion = u.client.Version()
+	}
+	details["mumble version"] = unknownIfEmpty(mumbleVersion)
+}
+
+func (u *gtkUI) torVersion() string {
+	if u.tor == nil {
+		return ""
+	}
+	return u.tor.Version()
+}
+
+func unknownIfEmpty(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func (s *settings) createDiagnosticBundle() {
+	details := s.u.diagnosticDetails()
+
+	s.saveDiagnostics(
+		i18n.Sprintf("Save the diagnostic bundle"),
+		fmt.Sprintf("wahay-diagnostics-%s.zip", time.Now().Format("20060102")),
+		s.b.get("lblDiagnosticBundleMessage").(gtki.Label),
+		i18n.Sprintf("Creating the diagnostic bundle..."),
+		i18n.Sprintf("The diagnostic bundle has been saved"),
+		func(w io.Writer) error {
+			s.u.addVersionDetails(details)
+			return config.CreateDiagnosticBundle(w, s.u.config, details)
+		})
+}
+
+func (s *settings) exportRecentLogs() {
+	s.saveDiagnostics(
+		i18n.Sprintf("Save the recent logs"),
+		fmt.Sprintf("wahay-logs-%s.txt", time.Now().Format("20060102")),
+		s.b.get("lblRecentLogsMessage").(gtki.Label),
+		i18n.Sprintf("Saving the recent logs..."),
+		i18n.Sprintf("The recent logs have been saved"),
+		config.ExportRecentLogs)
+}
+
+// saveDiagnostics asks for a file and writes to it in the background,
+// informing the user about the progress in the given label
+func (s *settings) saveDiagnostics(title, defaultName string, lblMessage gtki.Label, working, done string, write func(io.Writer) error) {
+	fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, title, defaultName)
+	if !ok {
+		return
+	}
+
+	lblMessage.SetText(working)
+
+	go func() {
+		err := writeToFile(fileName, write)
+
+		s.u.doInUIThread(func() {
+			if err != nil {
+				lblMessage.SetText("")
+				log.WithFields(log.Fields{
+					"context": "diagnostics",
+				}).Errorf("the diagnostic information can't be saved: %s", err)
+				s.u.reportError(i18n.Sprintf("The diagnostic information can't be saved: %s", err))
+				return
+			}
+
+			go s.u.messageToLabel(lblMessage, done, 5)
+		})
+	}()
+}
+
+// writeToFile saves in the given file what write produces.
+// Nothing is kept if the file can't be completely written
+func writeToFile(fileName string, write func(io.Writer) error) error {
+	out, err := os.OpenFile(filepath.Clean(fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = write(out)
+
+	cerr := out.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(fileName)
+	}
+
+	return err
+}
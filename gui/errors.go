@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 func fatal(v interface{}) {
@@ -49,12 +50,24 @@ func (u *gtkUI) reportError(message string) {
 	})
 }
 
-func (u *gtkUI) showStatusErrorsWindow(builder *uiBuilder) {
-	txt := u.errorHandler.getStatusErrorsText()
+// showStatusErrorsWindow shows the errors found during the startup. The
+// dialog is only created when the user asks for it, since most of the
+// time there are no errors to show
+func (u *gtkUI) showStatusErrorsWindow() {
+	builder := u.g.uiBuilderFor("StartupErrorsWindow")
+	builder.i18nProperties("button", "btnErrorsAccept")
 
 	dialog := builder.get("mainWindowErrors").(gtki.Dialog)
 	buffer := builder.get("helpTextBuffer").(gtki.TextBuffer)
-	buffer.SetText(txt)
+	buffer.SetText(u.errorHandler.getStatusErrorsText())
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_close_window_errors": func() {
+			u.enableMainWindow()
+			dialog.Destroy()
+			u.currentWindow = u.mainWindow
+		},
+	})
 
 	if u.mainWindow != nil {
 		dialog.SetTransientFor(u.mainWindow)
@@ -66,13 +79,6 @@ func (u *gtkUI) showStatusErrorsWindow(builder *uiBuilder) {
 	dialog.Show()
 }
 
-func (u *gtkUI) closeStatusErrorsWindow() {
-	u.enableMainWindow()
-	if u.currentWindow != nil {
-		u.currentWindow.Hide()
-	}
-}
-
 /* UI error handler utilities */
 
 type errGroupType string
@@ -126,10 +132,47 @@ func (h *errorHandler) getStatusErrorsText() string {
 	return strings.Join(txt, "\n")
 }
 
+// errorHint returns what the user can do about the given error,
+// depending on the part of the system that failed
+func errorHint(err error) string {
+	switch failure.CategoryOf(err) {
+	case failure.CategoryTor:
+		return i18n.Sprintf("Wahay can't use the Tor network. Check that Tor is installed and that your network allows connections to Tor.")
+	case failure.CategoryMumble:
+		return i18n.Sprintf("Wahay can't use the Mumble client. Check that Mumble is installed or set its location in the settings.")
+	case failure.CategoryMeeting:
+		return i18n.Sprintf("The meeting can't be reached. Check the meeting ID and that the meeting is still running.")
+	case failure.CategoryHosting:
+		return i18n.Sprintf("The meeting can't be hosted right now. Please try to host it again.")
+	case failure.CategoryConfiguration:
+		return i18n.Sprintf("Please check the settings of Wahay.")
+	}
+	return ""
+}
+
+// describeError returns the message of the error followed by what the user can do about it
+func describeError(err error) string {
+	hint := errorHint(err)
+	if hint == "" {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s\n\n%s", err.Error(), hint)
+}
+
+// errorFields returns the fields that identify the given error in the logs
+func errorFields(err error) log.Fields {
+	return log.Fields{
+		"code":     failure.CodeOf(err),
+		"category": failure.CategoryOf(err),
+	}
+}
+
 func (h *errorHandler) addNewStartupError(err error, group errGroupType) {
 	h.Lock()
 	defer h.Unlock()
 
+	log.WithFields(errorFields(err)).Errorf("Wahay can't start correctly: %s", err)
+
 	h.hasErrors = true
 
 	h.startupErrors[group].errorList = append(
@@ -0,0 +1,341 @@
+package gui
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	fileDropColumnID = iota
+	fileDropColumnName
+	fileDropColumnSize
+)
+
+type fileDropWindow struct {
+	u     *gtkUI
+	drop  hosting.FileDrop
+	files map[string]hosting.SharedFile
+
+	// host is the file drop of the meeting we are hosting, which is
+	// nil for the meetings hosted by somebody else
+	host hosting.HostFileDrop
+
+	win         gtki.Window
+	store       gtki.ListStore
+	tree        gtki.TreeView
+	lblStatus   gtki.Label
+	btnRefresh  gtki.Button
+	btnUpload   gtki.Button
+	btnDownload gtki.Button
+	btnRemove   gtki.Button
+}
+
+// showHostSharedFiles opens the files shared in the meeting we are hosting
+func (h *hostData) showHostSharedFiles() {
+	d := h.service.FileDrop()
+	if d == nil {
+		return
+	}
+
+	w := h.u.showSharedFiles(d)
+	w.host = d
+	w.btnRemove.SetVisible(true)
+}
+
+// showRemoteSharedFiles opens the files shared in a meeting hosted by
+// somebody else. The host might have not enabled file sharing, in
+// which case the first request fails and the user is informed
+func (u *gtkUI) showRemoteSharedFiles(data hosting.MeetingData) {
+	u.waitForTorInstance(func(t tor.Instance) {
+		d := hosting.NewRemoteFileDrop(t, data.MeetingID, data.Token)
+		u.doInUIThread(func() {
+			u.showSharedFiles(d)
+		})
+	})
+}
+
+func (u *gtkUI) showSharedFiles(d hosting.FileDrop) *fileDropWindow {
+	builder := u.g.uiBuilderFor("FileDropWindow")
+	builder.i18nProperties(
+		"title", "fileDropWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"title", "colName",
+		"title", "colSize",
+		"button", "btnRefresh",
+		"button", "btnUpload",
+		"button", "btnDownload",
+		"button", "btnRemove",
+		"button", "btnClose")
+
+	w := &fileDropWindow{
+		u:     u,
+		drop:  d,
+		files: make(map[string]hosting.SharedFile),
+	}
+
+	builder.getItems(
+		"fileDropWindow", &w.win,
+		"storeFiles", &w.store,
+		"treeFiles", &w.tree,
+		"lblStatus", &w.lblStatus,
+		"btnRefresh", &w.btnRefresh,
+		"btnUpload", &w.btnUpload,
+		"btnDownload", &w.btnDownload,
+		"btnRemove", &w.btnRemove,
+	)
+
+	if u.currentWindow != nil {
+		w.win.SetTransientFor(u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_refresh":  w.refresh,
+		"on_upload":   w.upload,
+		"on_download": w.download,
+		"on_remove":   w.remove,
+		"on_close":    w.win.Destroy,
+	})
+
+	w.win.Present()
+	w.win.Show()
+
+	w.refresh()
+
+	return w
+}
+
+// setBusy disables the actions of the window while a request
+// is running, showing the given message to the user
+func (w *fileDropWindow) setBusy(busy bool, message string) {
+	w.btnRefresh.SetSensitive(!busy)
+	w.btnUpload.SetSensitive(!busy)
+	w.btnDownload.SetSensitive(!busy)
+	w.btnRemove.SetSensitive(!busy)
+	w.lblStatus.SetText(message)
+}
+
+func (w *fileDropWindow) refresh() {
+	w.setBusy(true, i18n.Sprintf("Loading the shared files..."))
+
+	go func() {
+		files, err := w.drop.List()
+
+		w.u.doInUIThread(func() {
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "filedrop",
+				}).Errorf("the shared files can't be listed: %s", err)
+				w.setBusy(false, i18n.Sprintf("The shared files can't be loaded. The host of the meeting might not be sharing files."))
+				return
+			}
+
+			w.showFiles(files)
+			w.setBusy(false, "")
+		})
+	}()
+}
+
+func (w *fileDropWindow) showFiles(files []hosting.SharedFile) {
+	w.store.Clear()
+	w.files = make(map[string]hosting.SharedFile)
+
+	for _, f := range files {
+		w.files[f.ID] = f
+
+		iter := w.store.Append()
+		_ = w.store.Set2(iter,
+			[]int{fileDropColumnID, fileDropColumnName, fileDropColumnSize},
+			[]interface{}{f.ID, f.Name, formatFileSize(f.Size)})
+	}
+
+	if len(files) == 0 {
+		w.lblStatus.SetText(i18n.Sprintf("Nobody has shared a file yet"))
+	}
+}
+
+func (w *fileDropWindow) upload() {
+	fileName, ok := w.u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Share a file"), "")
+	if !ok {
+		return
+	}
+
+	st, err := os.Stat(fileName)
+	if err == nil && st.Size() > hosting.MaxSharedFileSize {
+		err = hosting.ErrSharedFileTooLarge
+	}
+
+	var content []byte
+	if err == nil {
+		content, err = ioutil.ReadFile(filepath.Clean(fileName))
+	}
+
+	if err != nil {
+		w.u.reportError(i18n.Sprintf("The file can't be shared: %s", fileDropErrorTranslator(err)))
+		return
+	}
+
+	w.setBusy(true, i18n.Sprintf("Sharing the file..."))
+
+	go func() {
+		err := w.drop.Upload(filepath.Base(fileName), content)
+
+		w.u.doInUIThread(func() {
+			w.setBusy(false, "")
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "filedrop",
+				}).Errorf("the file can't be shared: %s", err)
+				w.u.reportError(i18n.Sprintf("The file can't be shared: %s", fileDropErrorTranslator(err)))
+				return
+			}
+
+			w.refresh()
+		})
+	}()
+}
+
+func (w *fileDropWindow) selectedFile() (hosting.SharedFile, bool) {
+	sel, err := w.tree.GetSelection()
+	if err != nil {
+		return hosting.SharedFile{}, false
+	}
+
+	model, iter, ok := sel.GetSelected()
+	if !ok {
+		return hosting.SharedFile{}, false
+	}
+
+	v, err := model.GetValue(iter, fileDropColumnID)
+	if err != nil {
+		return hosting.SharedFile{}, false
+	}
+
+	id, _ := v.GetString()
+	f, ok := w.files[id]
+
+	return f, ok
+}
+
+func (w *fileDropWindow) download() {
+	f, ok := w.selectedFile()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the file you want to download"))
+		return
+	}
+
+	fileName, ok := w.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Save the shared file"), f.Name)
+	if !ok {
+		return
+	}
+
+	w.setBusy(true, i18n.Sprintf("Downloading the file..."))
+
+	go func() {
+		err := w.downloadTo(f.ID, fileName)
+
+		w.u.doInUIThread(func() {
+			w.setBusy(false, "")
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "filedrop",
+				}).Errorf("the shared file can't be downloaded: %s", err)
+				w.u.reportError(i18n.Sprintf("The file can't be downloaded: %s", fileDropErrorTranslator(err)))
+			}
+		})
+	}()
+}
+
+// remove stops sharing the selected file in the meeting we are
+// hosting, so the participants can't download it anymore
+func (w *fileDropWindow) remove() {
+	f, ok := w.selectedFile()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the file you want to stop sharing"))
+		return
+	}
+
+	err := w.host.Remove(f.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "filedrop",
+		}).Errorf("the shared file can't be removed: %s", err)
+		w.u.reportError(i18n.Sprintf("The file can't be removed: %s", fileDropErrorTranslator(err)))
+	}
+
+	w.refresh()
+}
+
+// downloadTo streams the shared file to the given file name, showing
+// the progress in the window. Nothing is kept if the download fails
+func (w *fileDropWindow) downloadTo(id, fileName string) error {
+	out, err := os.OpenFile(filepath.Clean(fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	lastPercent := -1
+	err = w.drop.Download(id, out, func(done, total int64) {
+		if total <= 0 {
+			return
+		}
+
+		percent := int(done * 100 / total)
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+
+		w.u.doInUIThread(func() {
+			w.lblStatus.SetText(i18n.Sprintf("Downloading the file... %d%%", percent))
+		})
+	})
+
+	cerr := out.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(fileName)
+	}
+
+	return err
+}
+
+func formatFileSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return i18n.Sprintf("%d bytes", size)
+}
+
+func fileDropErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case hosting.ErrSharedFileTooLarge:
+		return i18n.Sprintf("the file is too large to be shared")
+	case hosting.ErrSharedFileEmpty:
+		return i18n.Sprintf("the file is empty")
+	case hosting.ErrSharedFileInvalidName:
+		return i18n.Sprintf("the name of the file is not valid")
+	case hosting.ErrSharedFileNotFound:
+		return i18n.Sprintf("the file is not shared anymore")
+	case hosting.ErrFileDropFull:
+		return i18n.Sprintf("no more files can be shared in this meeting")
+	}
+	return err.Error()
+}
@@ -0,0 +1,118 @@
+package gui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	log "github.com/sirupsen/logrus"
+)
+
+// fontScaleChoices are the sizes of the text, as percentages, that can be chosen
+var fontScaleChoices = []int{100, 125, 150, 175, 200}
+
+// defaultXftDPI is the resolution of the fonts, in 1024ths of a dot per
+// inch, that GTK uses when the desktop doesn't set one
+const defaultXftDPI = 96 * 1024
+
+var (
+	cssRule         = regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+	cssFontSizeInPx = regexp.MustCompile(`font-size:\s*([0-9.]+)px`)
+)
+
+// fontScale keeps what is needed to change the size of the text of the windows
+type fontScale struct {
+	provider  gtki.CssProvider
+	systemDPI int
+}
+
+// scaledFontSizesCSS returns the rules of the given style sheet that
+// set the size of the text in pixels, with the size scaled. The theme
+// of the system follows the resolution of the fonts instead
+func scaledFontSizesCSS(css string, percent int) string {
+	if percent == 100 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, rule := range cssRule.FindAllStringSubmatch(css, -1) {
+		size := cssFontSizeInPx.FindStringSubmatch(rule[2])
+		if size == nil {
+			continue
+		}
+
+		px, err := strconv.ParseFloat(size[1], 64)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s { font-size: %gpx; }\n", strings.TrimSpace(rule[1]), px*float64(percent)/100)
+	}
+
+	return b.String()
+}
+
+func fontScaleName(percent int) string {
+	if percent == 100 {
+		return i18n.Sprintf("Normal")
+	}
+	return fmt.Sprintf("%d%%", percent)
+}
+
+// applyFontScale changes the size of the text of all the windows to the
+// configured one. This must be called from the UI thread
+func (u *gtkUI) applyFontScale() {
+	if u.g.gdk == nil || u.config == nil {
+		return
+	}
+
+	percent := u.config.GetFontScale()
+	if u.fontScale.provider == nil && percent == 100 {
+		return
+	}
+
+	if u.fontScale.provider == nil {
+		prov, err := u.g.gtk.CssProviderNew()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "font-scale",
+			}).Errorf("The size of the text can't be changed: %s", err)
+			return
+		}
+
+		screen, _ := u.g.gdk.ScreenGetDefault()
+		u.g.gtk.AddProviderForScreen(screen, prov, uint(gtki.STYLE_PROVIDER_PRIORITY_USER))
+		u.fontScale.provider = prov
+	}
+
+	err := u.fontScale.provider.LoadFromData(scaledFontSizesCSS(getCSSFileWithFallback("gui"), percent))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "font-scale",
+		}).Errorf("The size of the text can't be changed: %s", err)
+	}
+
+	u.setFontResolution(percent)
+}
+
+// setFontResolution scales the resolution of the fonts
+// the system uses, which GTK uses for the sizes of the theme
+func (u *gtkUI) setFontResolution(percent int) {
+	settings, err := u.g.gtk.SettingsGetDefault()
+	if err != nil {
+		return
+	}
+
+	if u.fontScale.systemDPI == 0 {
+		u.fontScale.systemDPI = defaultXftDPI
+		if v, err := settings.GetProperty("gtk-xft-dpi"); err == nil {
+			if dpi, ok := v.(int); ok && dpi > 0 {
+				u.fontScale.systemDPI = dpi
+			}
+		}
+	}
+
+	_ = settings.SetProperty("gtk-xft-dpi", u.fontScale.systemDPI*percent/100)
+}
@@ -0,0 +1,28 @@
+package gui
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type WahayGUIFontScaleSuite struct{}
+
+var _ = Suite(&WahayGUIFontScaleSuite{})
+
+func (s *WahayGUIFontScaleSuite) Test_scaledFontSizesCSS_scalesOnlyTheSizesInPixels(c *C) {
+	css := `.title {
+  font-size: 20px;
+  color: red; }
+
+.help {
+  font-size: inherit; }
+
+.list label, .entry {
+  font-size: 14.5px; }
+`
+
+	c.Assert(scaledFontSizesCSS(css, 150), Equals, ".title { font-size: 30px; }\n.list label, .entry { font-size: 21.75px; }\n")
+}
+
+func (s *WahayGUIFontScaleSuite) Test_scaledFontSizesCSS_isEmptyForTheNormalSize(c *C) {
+	c.Assert(scaledFontSizesCSS(".title { font-size: 20px; }", 100), Equals, "")
+}
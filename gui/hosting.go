@@ -2,14 +2,16 @@ package gui
 
 import (
 	"fmt"
-	"math/rand"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
 	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/digitalautonomy/wahay/tor"
 )
 
@@ -23,20 +25,80 @@ type hostData struct {
 	meetingUsername   string
 	meetingPassword   string
 	currentWindow     gtki.Window
+	distribution      hosting.InvitationDistribution
 	next              func()
+
+	// clientAuthKey is the key of the invitation given last, when
+	// only invited participants can reach the meeting
+	clientAuthKey       string
+	clientAuthKeysGiven int
+
+	// acl has the moderator password and the privileges
+	// that every participant of the meeting has
+	acl hosting.MeetingACL
+
+	// room is the meeting room being hosted, when
+	// the meeting is saved for hosting it again
+	room *config.MeetingRoom
+
+	// scheduled is the meeting being hosted, when it was
+	// scheduled in advance with a room of its own
+	scheduled *config.ScheduledMeeting
+
+	// finishing is set once the host has ended the meeting
+	finishing bool
+
+	// reachability is whether the participants can reach the meeting,
+	// which is shown in the label of the window being shown
+	reachability    hosting.Reachability
+	lblReachability gtki.Label
+
+	// btnRecord and lblRecording are the recording
+	// controls of the window being shown
+	btnRecord    gtki.Button
+	lblRecording gtki.Label
+
+	// duration is how long the meeting lasts, when the host wants it to
+	// end automatically. started and endsAt are when it started and when
+	// it ends, and endWarned is set once the participants have been told
+	duration       time.Duration
+	started        time.Time
+	endsAt         time.Time
+	endWarned      bool
+	lblMeetingTime gtki.Label
+
+	// chatWindow is the chat of the meeting, while it's open
+	chatWindow *chatWindow
+
+	// stopTranscript stops recording the chat, when it's recorded
+	stopTranscript func()
+
+	// stats is how the connection of the host works, while
+	// the host is in the meeting with Mumble
+	stats *meetingStats
 }
 
 func (u *gtkUI) hostMeetingHandler() {
-	go u.realHostMeetingHandler()
+	// The keys of the meeting rooms are only saved encrypted
+	if !u.config.CanSaveMeetingRooms() {
+		go u.realHostMeetingHandler(nil)
+		return
+	}
+
+	u.chooseMeetingRoom(func(room *config.MeetingRoom) {
+		go u.realHostMeetingHandler(room)
+	})
 }
 
-func (u *gtkUI) realHostMeetingHandler() {
+func (u *gtkUI) realHostMeetingHandler(room *config.MeetingRoom) {
+	defer config.LogDuration("host: prepare the meeting", time.Now())
+
 	u.hideMainWindow()
 	u.displayLoadingWindow()
 
 	if u.servers == nil {
 		var err error
-		u.servers, err = hosting.CreateServerCollection()
+		u.servers, err = u.takePreparedServers()
 		if err != nil {
 			// TODO: should we check if u.servers !== nil here?
 			u.reportError(i18n.Sprintf("Something went wrong: %s", err))
@@ -50,6 +112,12 @@ func (u *gtkUI) realHostMeetingHandler() {
 		asSuperUser: u.config.GetAsSuperUser(),
 		autoJoin:    u.config.GetAutoJoin(),
 		next:        nil,
+		room:        room,
+		acl: hosting.MeetingACL{
+			ParticipantsCanMute: u.config.GetParticipantsCanMute(),
+			ParticipantsCanKick: u.config.GetParticipantsCanKick(),
+			WaitingRoom:         u.config.GetWaitingRoom(),
+		},
 	}
 
 	echan := make(chan error)
@@ -67,6 +135,8 @@ func (u *gtkUI) realHostMeetingHandler() {
 		return
 	}
 
+	u.currentHost = h
+
 	u.doInUIThread(h.showMeetingConfiguration)
 }
 
@@ -93,9 +163,25 @@ func (h *hostData) showMeetingControls() {
 		"button", "btnJoinMeeting",
 		"button", "btnJoinMeeting",
 		"button", "btnInviteOthers",
+		"button", "btnSharedFiles",
+		"button", "btnParticipants",
+		"button", "btnChat",
+		"button", "btnSuperUser",
+		"label", "lblRecording",
 		"button", "btnCopyMeetingID",
 		"tooltip", "btnJoinMeeting")
 
+	btnSharedFiles := builder.get("btnSharedFiles").(gtki.Button)
+	btnSharedFiles.SetVisible(h.service.FileDrop() != nil)
+	btnParticipants := builder.get("btnParticipants").(gtki.Button)
+	btnParticipants.SetVisible(h.service.Participants() != nil)
+	btnChat := builder.get("btnChat").(gtki.Button)
+	btnChat.SetVisible(h.service.Chat() != nil)
+
+	h.showPublicationStatus(builder, "btnInviteOthers", "btnCopyMeetingID")
+	h.showRecordingControls(builder)
+	h.showMeetingTimer(builder)
+
 	builder.ConnectSignals(map[string]interface{}{
 		"on_close_window_signal": h.finishMeetingReal,
 		"on_finish_meeting":      h.finishMeeting,
@@ -112,6 +198,11 @@ func (h *hostData) showMeetingControls() {
 		"on_send_by_email": func() {
 			h.sendInvitationByEmail(builder)
 		},
+		"on_shared_files": h.showHostSharedFiles,
+		"on_participants": h.showHostParticipants,
+		"on_chat":         h.showHostChat,
+		"on_super_user":   h.showSuperUser,
+		"on_record":       h.onRecord,
 	})
 
 	lblValueHost := builder.get("lblValueHost").(gtki.Label)
@@ -152,7 +243,10 @@ func (h *hostData) joinMeetingHostHelper(validOpChannel chan bool) {
 			}
 			return h.meetingPassword
 		}(),
-		Username: h.meetingUsername,
+		Username:    h.meetingUsername,
+		Token:       h.service.Token(),
+		Fingerprint: h.service.Fingerprint(),
+		Certificate: h.service.Certificate(),
 	}
 
 	var err error
@@ -160,11 +254,15 @@ func (h *hostData) joinMeetingHostHelper(validOpChannel chan bool) {
 
 	finish := make(chan bool)
 
+	stats := h.u.newMeetingStats(data.MeetingID, h.service.Participants())
+	h.stats = stats
+
 	go func() {
 		mumble, err = h.u.launchMumbleClient(
 			data,
 			// Callback to be executed when the client is closed
 			func() {
+				stats.close()
 				if h.next == nil {
 					h.next = h.uiActionFinishMeeting
 				}
@@ -179,14 +277,59 @@ func (h *hostData) joinMeetingHostHelper(validOpChannel chan bool) {
 	h.u.hideLoadingWindow()
 
 	if err != nil {
-		log.Errorf("joinMeetingHost() error: %s", err)
+		stats.close()
+		log.WithFields(errorFields(err)).Errorf("joinMeetingHost() error: %s", err)
 		validOpChannel <- false
 	} else {
+		// The host's own client joins like any other participant
+		// unless it's the SuperUser, so the meeting has to recognize it
+		h.service.SetHostCertificate(h.u.client.CertificateHash())
+
+		stats.setRoundTrip(h.u.currentAudio.rtt)
+		go stats.measureRoundTrip(h.u.torInstance(), meetingAddress(data))
 		h.mumble = mumble
 		validOpChannel <- true
 	}
 }
 
+// showPublicationStatus keeps the given invitation controls disabled
+// until the meeting can be reached by the participants through Tor
+func (h *hostData) showPublicationStatus(builder *uiBuilder, controls ...string) {
+	lblPublication := builder.get("lblPublication").(gtki.Label)
+
+	setSensitive := func(v bool) {
+		for _, id := range controls {
+			builder.get(id).(gtki.Widget).SetSensitive(v)
+		}
+	}
+
+	h.lblReachability = builder.get("lblReachability").(gtki.Label)
+	h.showReachability()
+
+	setSensitive(false)
+	lblPublication.SetText(i18n.Sprintf("Publishing the meeting in the Tor network..."))
+	lblPublication.SetVisible(true)
+
+	h.service.WhenPublished(func(err error) {
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "publication",
+			}).Warningf("the publication of the meeting can't be confirmed: %s", err)
+		}
+
+		h.u.doInUIThread(func() {
+			setSensitive(true)
+
+			if err != nil {
+				lblPublication.SetText(i18n.Sprintf("The meeting might take a few minutes to be reachable by the participants"))
+				return
+			}
+
+			go h.u.messageToLabel(lblPublication, i18n.Sprintf("The meeting is ready for the participants"), 5)
+		})
+	})
+}
+
 func (h *hostData) switchToHostOnFinishMeeting() {
 	h.u.doInUIThread(func() {
 		if h.next != nil {
@@ -205,6 +348,14 @@ func (u *gtkUI) getCurrentHostMeetingWindow() *uiBuilder {
 		"tooltip", "btnFinishMeeting",
 		"tooltip", "btnLeaveMeeting",
 		"button", "btnInviteOthers",
+		"button", "btnSharedFiles",
+		"button", "btnParticipants",
+		"button", "btnChat",
+		"button", "btnSuperUser",
+		"label", "lblRecording",
+		"button", "btnPanic",
+		"tooltip", "btnPanic",
+		"tooltip", "lblAudioPreset",
 		"label", "lblTipPush",
 	)
 
@@ -217,6 +368,19 @@ func (h *hostData) openHostJoinMeetingWindow() {
 	builder := h.u.getCurrentHostMeetingWindow()
 	win := builder.get("hostMeetingWindow").(gtki.ApplicationWindow)
 
+	btnSharedFiles := builder.get("btnSharedFiles").(gtki.Button)
+	btnSharedFiles.SetVisible(h.service.FileDrop() != nil)
+	btnParticipants := builder.get("btnParticipants").(gtki.Button)
+	btnParticipants.SetVisible(h.service.Participants() != nil)
+	btnChat := builder.get("btnChat").(gtki.Button)
+	btnChat.SetVisible(h.service.Chat() != nil)
+
+	h.u.showAudioDecision(builder)
+	h.showPublicationStatus(builder, "btnInviteOthers")
+	h.showRecordingControls(builder)
+	h.showMeetingTimer(builder)
+	h.u.showStatsPanel(builder, win, h.stats)
+
 	onInviteOpen := func(d gtki.ApplicationWindow) {
 		h.currentWindow = d
 		// Hide the current window because we don't want
@@ -236,9 +400,15 @@ func (h *hostData) openHostJoinMeetingWindow() {
 		},
 		"on_leave_meeting":  h.leaveHostMeeting,
 		"on_finish_meeting": h.finishMeetingMumble,
+		"on_panic":          h.u.panicButton,
 		"on_invite_others": func() {
 			h.onInviteParticipants(onInviteOpen, onInviteClose)
 		},
+		"on_shared_files": h.showHostSharedFiles,
+		"on_participants": h.showHostParticipants,
+		"on_chat":         h.showHostChat,
+		"on_super_user":   h.showSuperUser,
+		"on_record":       h.onRecord,
 	})
 
 	h.u.connectShortcutCurrentHostMeetingWindow(win, h)
@@ -256,38 +426,80 @@ func (h *hostData) uiActionFinishMeeting() {
 }
 
 func (h *hostData) createNewService(err chan error) {
-	var port string
+	defer config.LogDuration("host: create the service", time.Now())
 
-	configuredPort := h.u.config.GetPortMumble()
-	if configuredPort != "" {
-		port = configuredPort
+	ports, e := hosting.NewServicePorts(h.u.config.GetPortMumble(), h.u.config.GetMumbleListenerPort(), h.u.config.GetOnionPortMappings())
+	if e != nil {
+		log.Errorf("createNewService(): %s", e)
+		err <- e
+		return
 	}
+	ports.OnionOnly = h.u.config.GetOnionOnlyCertServer()
 
 	h.u.waitForTorInstance(func(t tor.Instance) {
-		s, e := h.u.servers.NewService(port, t)
+		var s hosting.Service
+		var e error
+		room := h.room
+		if h.scheduled != nil {
+			room = &h.scheduled.Room
+		}
+
+		if room != nil {
+			s, e = h.u.servers.NewRoomService(hostingRoom(room), ports, t, h.u.config.GetFileSharing(), h.u.config.GetEmbedCertificate())
+		} else {
+			s, e = h.u.servers.NewService(ports, t, h.u.config.GetFileSharing(), h.u.config.GetEmbedCertificate(), h.u.config.GetClientAuthInvitees())
+		}
 		if e != nil {
 			log.Errorf("createNewService(): %s", e)
 			err <- e
 			return
 		}
 
-		s.SetWelcomeText(i18n.Sprintf("Welcome to this server running <b>Wahay</b>."))
+		welcome := ""
+		if room != nil {
+			welcome = room.WelcomeText
+		}
+		s.SetWelcomeText(meetingWelcomeText(welcome))
+		s.SetMaxBandwidth(client.LimitedHostingBandwidth(h.u.config.GetAudioPreset(), h.u.config.GetMaxBandwidth()))
+		s.SetMaxParticipants(h.u.config.GetMaxParticipants())
+		s.SetIdleTimeout(time.Duration(h.u.config.GetIdleKickTimeout()) * time.Minute)
+		if d := s.FileDrop(); d != nil {
+			d.SetParticipantsCanShare(!h.u.config.GetFileSharingHostOnly())
+		}
+
+		created := time.Now()
+		s.WhenPublished(func(error) {
+			config.LogDuration("host: publish the onion service", created)
+		})
+
+		s.WhenReachabilityChanges(func(r hosting.Reachability) {
+			h.u.doInUIThread(func() {
+				h.reachability = r
+				h.showReachability()
+			})
+		})
 
 		h.service = s
 
+		if h.room != nil {
+			h.saveMeetingRoom()
+			h.rememberHostedRoom()
+		}
+
 		err <- nil
 	})
 }
 
 func (h *hostData) createNewConferenceRoom(complete chan bool) {
+	defer config.LogDuration("host: create the conference room", time.Now())
+
+	// The password of the SuperUser is chosen by the service
 	var su hosting.SuperUserData
 	if h.asSuperUser {
-		su = hosting.SuperUserData{
-			Username: h.meetingUsername,
-			Password: h.superUserPassword,
-		}
+		su.Username = h.meetingUsername
 	}
 
+	h.service.SetMeetingACL(h.acl)
 	err := h.service.NewConferenceRoom(h.meetingPassword, su)
 	if err != nil {
 		h.u.hideLoadingWindow()
@@ -296,17 +508,47 @@ func (h *hostData) createNewConferenceRoom(complete chan bool) {
 		return
 	}
 
+	h.superUserPassword = h.service.SuperUser().Password
+	h.startMeetingTimer(h.duration)
+	h.startChatTranscript()
+
 	complete <- true
 }
 
+// maxWelcomeTextLength is the longest welcome message the host can write
+const maxWelcomeTextLength = 2000
+
+// meetingWelcomeText is what the participants read when they join the
+// meeting, which is the default message unless the host has written one
+func meetingWelcomeText(text string) string {
+	if welcome := hosting.WelcomeTextHTML(text); welcome != "" {
+		return welcome
+	}
+	return i18n.Sprintf("Welcome to this server running <b>Wahay</b>.")
+}
+
+// MeetingEndedMessage is what the participants are
+// told when the host ends the meeting
+func MeetingEndedMessage() string {
+	return i18n.Sprintf("The host has ended the meeting. Thank you for joining it.")
+}
+
+// finishMeetingReal ends the meeting, telling the participants first.
+// It takes a while, so the meeting is finished outside of the UI thread
 func (h *hostData) finishMeetingReal() {
-	// TODO: What happens if two errors occurrs?
-	// We need to do a better controlling for each error
-	// and if multiple errors occurrs, show all the errors in the
-	// same window using the `u.reportError` function
-	err := h.service.Close()
-	if err != nil {
-		h.u.reportError(i18n.Sprintf("The meeting can't be closed: %s", err))
+	if h.finishing {
+		return
+	}
+	h.finishing = true
+
+	h.closeInvitationLink()
+
+	if h.chatWindow != nil {
+		h.chatWindow.win.Destroy()
+	}
+
+	if h.stopTranscript != nil {
+		h.stopTranscript()
 	}
 
 	if h.currentWindow != nil {
@@ -314,9 +556,29 @@ func (h *hostData) finishMeetingReal() {
 		h.currentWindow = nil
 	}
 
-	h.u.servers = nil
+	h.u.displayLoadingWindow()
 
-	h.u.switchToMainWindow()
+	go func() {
+		// TODO: What happens if two errors occurrs?
+		// We need to do a better controlling for each error
+		// and if multiple errors occurrs, show all the errors in the
+		// same window using the `u.reportError` function
+		err := h.service.Finish(MeetingEndedMessage())
+
+		h.u.doInUIThread(func() {
+			h.u.hideLoadingWindow()
+
+			if err != nil {
+				h.u.reportError(i18n.Sprintf("The meeting can't be closed: %s", err))
+			}
+
+			h.u.servers = nil
+			h.u.currentHost = nil
+			h.u.prepareServers()
+
+			h.u.switchToMainWindow()
+		})
+	}()
 }
 
 func (h *hostData) finishMeetingMumble() {
@@ -422,9 +684,56 @@ func (h *hostData) getInvitationText() string {
 	if h.service.URL() != "" {
 		it = i18n.Sprintf("%sMeeting ID: %s", it, h.service.URL())
 	}
+	if inv := h.getInvitation(); inv != "" {
+		it = i18n.Sprintf("%s%%0D%%0A%%0D%%0AOr paste this invitation in Wahay: %s", it, inv)
+	}
 	return it
 }
 
+func (h *hostData) getInvitationData() *invitation.Invitation {
+	return &invitation.Invitation{
+		Host:        h.service.ID(),
+		Port:        h.service.ServicePort(),
+		Token:       h.service.Token(),
+		Password:    h.meetingPassword,
+		Fingerprint: h.service.Fingerprint(),
+		Certificate: h.service.Certificate(),
+		ClientAuth:  h.clientAuthKey,
+	}
+}
+
+// nextClientAuthKey makes the next invitations use the key of another
+// participant. It returns false when all the keys were given already
+func (h *hostData) nextClientAuthKey() bool {
+	keys := h.service.ClientAuthKeys()
+	if len(keys) == 0 {
+		return true
+	}
+
+	if h.clientAuthKeysGiven >= len(keys) {
+		return false
+	}
+
+	h.clientAuthKey = keys[h.clientAuthKeysGiven]
+	h.clientAuthKeysGiven++
+
+	return true
+}
+
+// getInvitation returns the invitation for the current meeting using the
+// version 2 format, that includes everything needed for joining it
+func (h *hostData) getInvitation() string {
+	encoded, err := h.getInvitationData().EncodeV2()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "invitation",
+		}).Errorf("the invitation can't be generated: %s", err)
+		return ""
+	}
+
+	return encoded
+}
+
 func (h *hostData) wouldYouConfirmFinishMeeting(k func(bool)) {
 	builder := h.u.g.uiBuilderFor("StartHostingWindow")
 	dialog := builder.get("finishMeeting").(gtki.MessageDialog)
@@ -452,12 +761,26 @@ func (u *gtkUI) getConfigureMeetingWindow() *uiBuilder {
 		"label", "labelUsername",
 		"label", "lblMessage",
 		"label", "labelMeetingPassword",
+		"label", "labelModeratorPassword",
+		"label", "lblModeratorPasswordHelp",
+		"label", "labelMeetingDuration",
+		"label", "lblMeetingDurationHelp",
+		"label", "labelWelcomeText",
+		"label", "lblWelcomeTextHelp",
 		"placeholder", "inpMeetingUsername",
 		"placeholder", "inpMeetingPassword",
+		"placeholder", "inpModeratorPassword",
+		"placeholder", "inpMeetingDuration",
 		"checkbox", "chkAutoJoin",
 		"checkbox", "chkAutoJoinSuperUser",
+		"checkbox", "chkParticipantsCanMute",
+		"checkbox", "chkParticipantsCanKick",
+		"checkbox", "chkWaitingRoom",
 		"tooltip", "chkAutoJoin",
 		"tooltip", "chkAutoJoinSuperUser",
+		"tooltip", "chkParticipantsCanMute",
+		"tooltip", "chkParticipantsCanKick",
+		"tooltip", "chkWaitingRoom",
 		"button", "btnCopyMeetingID",
 		"button", "btnInviteOthers",
 		"button", "btnCancel",
@@ -471,6 +794,9 @@ func (h *hostData) showMeetingConfiguration() {
 	win := builder.get("configureMeetingWindow").(gtki.ApplicationWindow)
 	chkAutoJoin := builder.get("chkAutoJoin").(gtki.CheckButton)
 	chkAutoJoinSuperUser := builder.get("chkAutoJoinSuperUser").(gtki.CheckButton)
+	chkParticipantsCanMute := builder.get("chkParticipantsCanMute").(gtki.CheckButton)
+	chkParticipantsCanKick := builder.get("chkParticipantsCanKick").(gtki.CheckButton)
+	chkWaitingRoom := builder.get("chkWaitingRoom").(gtki.CheckButton)
 	btnStart := builder.get("btnStartMeeting").(gtki.Button)
 
 	onInviteOpen := func(d gtki.ApplicationWindow) {
@@ -487,8 +813,15 @@ func (h *hostData) showMeetingConfiguration() {
 
 	chkAutoJoin.SetActive(h.autoJoin)
 	chkAutoJoinSuperUser.SetActive(h.asSuperUser)
+	chkParticipantsCanMute.SetActive(h.acl.ParticipantsCanMute)
+	chkParticipantsCanKick.SetActive(h.acl.ParticipantsCanKick)
+	chkWaitingRoom.SetActive(h.acl.WaitingRoom)
 	h.changeStartButtonText(btnStart)
 
+	if h.room != nil {
+		setTextViewText(builder.get("txtWelcomeText").(gtki.TextView), h.room.WelcomeText)
+	}
+
 	btnCopyMeetingID := builder.get("btnCopyMeetingID").(gtki.Button)
 	btnCopyMeetingID.SetVisible(h.u.isCopyToClipboardSupported())
 
@@ -510,6 +843,18 @@ func (h *hostData) showMeetingConfiguration() {
 		"on_chkAutoJoinSuperUser_toggled": func() {
 			h.handlerOnAutoJoinSuperUserToggled(chkAutoJoinSuperUser)
 		},
+		"on_chkParticipantsCanMute_toggled": func() {
+			h.acl.ParticipantsCanMute = chkParticipantsCanMute.GetActive()
+			h.u.config.SetParticipantsCanMute(h.acl.ParticipantsCanMute)
+		},
+		"on_chkParticipantsCanKick_toggled": func() {
+			h.acl.ParticipantsCanKick = chkParticipantsCanKick.GetActive()
+			h.u.config.SetParticipantsCanKick(h.acl.ParticipantsCanKick)
+		},
+		"on_chkWaitingRoom_toggled": func() {
+			h.acl.WaitingRoom = chkWaitingRoom.GetActive()
+			h.u.config.SetWaitingRoom(h.acl.WaitingRoom)
+		},
 	})
 
 	h.u.connectShortcutsHostingMeetingConfigurationWindow(win, builder, h)
@@ -526,6 +871,31 @@ func (h *hostData) showMeetingConfiguration() {
 func (h *hostData) handleOnStartMeeting(b *uiBuilder) {
 	username := b.get("inpMeetingUsername").(gtki.Entry)
 	password := b.get("inpMeetingPassword").(gtki.Entry)
+	moderatorPassword := b.get("inpModeratorPassword").(gtki.Entry)
+	duration := b.get("inpMeetingDuration").(gtki.Entry)
+	txtWelcomeText := b.get("txtWelcomeText").(gtki.TextView)
+
+	h.acl.ModeratorPassword = getTrimmedText(moderatorPassword)
+	if h.acl.ModeratorPassword != "" {
+		p, _ := password.GetText()
+		if h.acl.ModeratorPassword == p {
+			h.u.reportError(i18n.Sprintf("The moderator password must be different from the meeting password"))
+			return
+		}
+	}
+
+	d, ok := parseMeetingDuration(getTrimmedText(duration))
+	if !ok {
+		h.u.reportError(i18n.Sprintf("The duration must be a number of minutes between 1 and %d", maxMeetingDuration))
+		return
+	}
+	h.duration = d
+
+	welcome := strings.TrimSpace(getTextViewText(txtWelcomeText))
+	if len([]rune(welcome)) > maxWelcomeTextLength {
+		h.u.reportError(i18n.Sprintf("The welcome message can't be longer than %d characters", maxWelcomeTextLength))
+		return
+	}
 
 	if h.asSuperUser {
 		u, _ := username.GetText()
@@ -535,9 +905,21 @@ func (h *hostData) handleOnStartMeeting(b *uiBuilder) {
 		}
 	}
 
+	h.setWelcomeText(welcome)
 	h.handlerOnStartMeeting(username, password)
 }
 
+// setWelcomeText gives the meeting the welcome message written by the
+// host, and keeps it with the meeting room, if any
+func (h *hostData) setWelcomeText(text string) {
+	h.service.SetWelcomeText(meetingWelcomeText(text))
+
+	if h.room != nil && h.room.WelcomeText != text {
+		h.room.WelcomeText = text
+		h.saveMeetingRoom()
+	}
+}
+
 func (h *hostData) seti18nProperties(b *uiBuilder) {
 	b.i18nProperties(
 		"label", "labelMeetingID",
@@ -549,12 +931,12 @@ func (h *hostData) seti18nProperties(b *uiBuilder) {
 func (h *hostData) handlerOnCancel() {
 	_ = h.service.Close()
 	h.u.servers = nil
+	h.u.prepareServers()
 	h.u.switchToMainWindow()
 }
 
 func (h *hostData) handlerOnAutoJoinSuperUserToggled(ch gtki.CheckButton) {
 	h.asSuperUser = ch.GetActive()
-	h.superUserPassword = generateRandomPassword()
 	h.u.config.SetAutoJoinSuperUser(h.asSuperUser)
 }
 
@@ -604,6 +986,15 @@ func (h *hostData) startMeetingRoutine() {
 		return
 	}
 
+	// The webhook hands out the invitation, so it's
+	// only notified when the meeting can be reached
+	h.service.WhenPublished(func(err error) {
+		h.notifyWebhook()
+	})
+
+	h.watchWaitingRoom()
+	h.watchParticipantsArrival()
+
 	if h.autoJoin {
 		h.joinMeetingHost()
 	} else {
@@ -611,6 +1002,69 @@ func (h *hostData) startMeetingRoutine() {
 	}
 }
 
+// watchParticipantsArrival tells the host when somebody joins or
+// leaves the meeting. The host joining their own meeting isn't told,
+// and neither are the participants arriving to the waiting room,
+// since the host is asked to admit them instead
+func (h *hostData) watchParticipantsArrival() {
+	p := h.service.Participants()
+	if p == nil {
+		return
+	}
+
+	p.WhenChanged(func(e hosting.ParticipantEvent, who hosting.Participant) {
+		if h.meetingUsername != "" && who.Name == h.meetingUsername {
+			return
+		}
+
+		switch {
+		case e == hosting.ParticipantConnected && !who.Waiting:
+			h.u.notifyEvent(notificationParticipantJoined,
+				i18n.Sprintf("%s has joined the meeting", who.Name), "")
+		case e == hosting.ParticipantDisconnected:
+			h.u.notifyEvent(notificationParticipantLeft,
+				i18n.Sprintf("%s has left the meeting", who.Name), "")
+		}
+	})
+}
+
+// notifyWebhook lets the systems of the host know that the meeting
+// is reachable. A failure is only logged, since the meeting works anyway
+func (h *hostData) notifyWebhook() {
+	webhookURL := h.u.config.GetWebhookURL()
+	if webhookURL == "" {
+		return
+	}
+
+	if !h.nextClientAuthKey() {
+		log.WithFields(log.Fields{
+			"context": "webhook",
+		}).Error("the webhook can't be notified: all the invitations of the meeting were given")
+		return
+	}
+
+	p := &hosting.WebhookPayload{
+		Event:      hosting.WebhookEventMeetingStarted,
+		MeetingID:  h.service.URL(),
+		Invitation: h.getInvitation(),
+		StartedAt:  time.Now().UTC(),
+	}
+
+	h.u.waitForTorInstance(func(t tor.Instance) {
+		err := hosting.NotifyWebhook(t, webhookURL, p)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "webhook",
+			}).Errorf("the webhook can't be notified: %s", err)
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"context": "webhook",
+		}).Debug("The webhook has been notified about the meeting")
+	})
+}
+
 func (h *hostData) getInvitePeopleBuilder() *uiBuilder {
 	builder := h.u.g.uiBuilderFor("InvitePeopleWindow")
 
@@ -620,6 +1074,14 @@ func (h *hostData) getInvitePeopleBuilder() *uiBuilder {
 	btnCopyInvitation := builder.get("btnCopyInvitation").(gtki.Button)
 	btnCopyInvitation.SetVisible(h.u.isCopyToClipboardSupported())
 
+	btnSendInvitation := builder.get("btnSendInvitation").(gtki.Button)
+	btnSendInvitation.SetVisible(h.u.config.GetInvitationSender().IsEnabled())
+
+	// The words can't contain the certificate, and without
+	// it the participants couldn't get it from the meeting
+	btnReadInvitationWords := builder.get("btnReadInvitationWords").(gtki.Button)
+	btnReadInvitationWords.SetVisible(h.service.Certificate() == nil)
+
 	builder.i18nProperties(
 		"label", "lblDescription",
 		"label", "lblDefaultEmail",
@@ -627,7 +1089,23 @@ func (h *hostData) getInvitePeopleBuilder() *uiBuilder {
 		"label", "lblYahoo",
 		"label", "lblOutlook",
 		"button", "btnCopyMeetingID",
-		"button", "btnCopyInvitation")
+		"button", "btnCopyInvitation",
+		"button", "btnSendInvitation",
+		"tooltip", "btnSendInvitation",
+		"button", "btnSaveInvitation",
+		"button", "btnShareInvitationLink",
+		"tooltip", "btnShareInvitationLink",
+		"button", "btnReadInvitationWords",
+		"tooltip", "btnReadInvitationWords",
+		"button", "btnAddToCalendar",
+		"button", "btnShowQRCode",
+		"tooltip", "btnShowQRCode")
+
+	if keys := h.service.ClientAuthKeys(); len(keys) > 0 {
+		lblDescription := builder.get("lblDescription").(gtki.Label)
+		lblDescription.SetText(i18n.Sprintf("This invitation lets only one participant reach the meeting (%d of %d). "+
+			"Choose your email service to send it", h.clientAuthKeysGiven, len(keys)))
+	}
 
 	btnEmail := builder.get("btnEmail").(gtki.LinkButton)
 	btnGmail := builder.get("btnGmail").(gtki.LinkButton)
@@ -660,6 +1138,12 @@ func (h *hostData) getInvitePeopleBuilder() *uiBuilder {
 
 // TODO: review this function and make a more pretty solution
 func (h *hostData) onInviteParticipants(onOpen func(d gtki.ApplicationWindow), onClose func(d gtki.ApplicationWindow)) {
+	if !h.nextClientAuthKey() {
+		h.u.reportError(i18n.Sprintf("All the invitations of this meeting were given. " +
+			"Change the number of participants in the settings and start a new meeting to invite more people"))
+		return
+	}
+
 	builder := h.getInvitePeopleBuilder()
 
 	dialog := builder.get("invitePeopleWindow").(gtki.ApplicationWindow)
@@ -684,6 +1168,14 @@ func (h *hostData) onInviteParticipants(onOpen func(d gtki.ApplicationWindow), o
 		"on_copy_invitation": func() {
 			h.copyInvitationToClipboard(builder)
 		},
+		"on_send_invitation": func() {
+			h.sendInvitation(builder)
+		},
+		"on_save_invitation":       h.saveInvitationFile,
+		"on_share_invitation_link": h.shareInvitationLink,
+		"on_read_invitation_words": h.showInvitationWords,
+		"on_add_to_calendar":       h.showScheduleMeeting,
+		"on_show_qr_code":          h.showInvitationQRCode,
 	})
 
 	if onOpen == nil {
@@ -699,16 +1191,3 @@ func (h *hostData) onInviteParticipants(onOpen func(d gtki.ApplicationWindow), o
 		onOpen(dialog)
 	})
 }
-
-func generateRandomPassword() string {
-	rand.Seed(time.Now().UnixNano())
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789")
-	length := 8
-	var b strings.Builder
-	for i := 0; i < length; i++ {
-		b.WriteRune(chars[rand.Intn(len(chars))])
-	}
-	return b.String()
-}
@@ -11,18 +11,72 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
 
 var i18n *message.Printer
 
 func init() {
+	initPrinter()
+}
+
+func initPrinter() {
 	tag := config.DetectLanguage()
 
 	log.Infof("Detected language: %v\n", tag)
 	i18n = message.NewPrinter(tag, message.Catalog(message.DefaultCatalog))
 }
 
+// SetLanguage makes Wahay use the given language, as a tag like "es",
+// instead of the one of the system. It's also used for the output
+// of the command line
+func SetLanguage(lang string) {
+	config.UseLanguage(lang)
+	initPrinter()
+}
+
+// Sprintf returns the message in the language used by Wahay, for the output
+// of the command line. The messages must be in ui_strings_for_translations.go,
+// and the hints of the errors too, since they are translated with it
+func Sprintf(format string, args ...interface{}) string {
+	return i18n.Sprintf(format, args...)
+}
+
+// languageNames are the names of the languages of Wahay in themselves,
+// so the users find theirs even when Wahay is in another one
+var languageNames = map[language.Tag]string{
+	language.English: "English",
+	language.Spanish: "Español",
+	language.Swedish: "Svenska",
+	language.Arabic:  "العربية",
+	language.French:  "Français",
+}
+
+func languageName(tag language.Tag) string {
+	if n, ok := languageNames[tag]; ok {
+		return n
+	}
+	return tag.String()
+}
+
+// applyLanguage uses the language of the settings, unless
+// another one was given in the command line
+func (u *gtkUI) applyLanguage() {
+	lang := *config.Language
+	if lang == "" {
+		lang = u.config.GetLanguage()
+	}
+	SetLanguage(lang)
+}
+
+// translatedProperty is a property of an object translated from English
+type translatedProperty struct {
+	id       string
+	property string
+	source   string
+}
+
 func (b *uiBuilder) i18nProperties(objs ...string) {
 	if len(objs)%2 == 1 {
 		panic("programmer error, uneven amount of arguments")
@@ -53,7 +107,22 @@ func (b *uiBuilder) i18nProperty(id, property string) {
 		fatal(e)
 	}
 
-	e = obj.SetProperty(property, i18n.Sprintf(currentVal))
+	source, _ := currentVal.(string)
+	b.translated = append(b.translated, translatedProperty{id, property, source})
+
+	b.setTranslatedProperty(id, obj, property, source)
+}
+
+// retranslate translates again the properties translated with
+// i18nProperties, in the language used now
+func (b *uiBuilder) retranslate() {
+	for _, t := range b.translated {
+		b.setTranslatedProperty(t.id, b.get(t.id).(glibi.Object), t.property, t.source)
+	}
+}
+
+func (b *uiBuilder) setTranslatedProperty(id string, obj glibi.Object, property, source string) {
+	e := obj.SetProperty(property, i18n.Sprintf(source))
 	if e != nil {
 		log.Errorf("Error setting property '%s' from object with id %s (%v): %v", property, id, obj, e)
 		fatal(e)
@@ -0,0 +1,183 @@
+package gui
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/failure"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const identityFileDefaultName = "wahay" + client.IdentityFileExtension
+
+func (s *settings) initIdentity() {
+	s.identity = s.u.config.GetClientIdentity()
+	s.chkPersistentIdentity.SetActive(s.identity != "")
+	s.updateIdentityFields()
+}
+
+func (s *settings) processIdentity() {
+	s.u.config.SetClientIdentity(s.identity)
+}
+
+func (s *settings) updateIdentityFields() {
+	s.btnExportIdentity.SetSensitive(s.identity != "")
+
+	fingerprint, err := client.IdentityFingerprint(s.identity)
+	if err != nil {
+		s.lblIdentityFingerprint.SetVisible(false)
+		return
+	}
+
+	s.lblIdentityFingerprint.SetText(i18n.Sprintf("Fingerprint: %s", fingerprint))
+	s.lblIdentityFingerprint.SetVisible(true)
+}
+
+func (s *settings) selectedCertificateKey() string {
+	i := s.cmbCertificateKey.GetActive()
+	if i < 0 || i >= len(certificateKeyChoices) {
+		return client.DefaultCertificateKey
+	}
+	return certificateKeyChoices[i]
+}
+
+// onPersistentIdentityToggled generates a new identity when the option is
+// enabled, with the key algorithm chosen in the settings. Disabling the
+// option forgets the identity, so it's better to export it before
+func (s *settings) onPersistentIdentityToggled() {
+	if !s.chkPersistentIdentity.GetActive() {
+		s.identity = ""
+		s.updateIdentityFields()
+		return
+	}
+
+	if s.identity != "" {
+		s.updateIdentityFields()
+		return
+	}
+
+	s.chkPersistentIdentity.SetSensitive(false)
+	s.lblIdentityMessage.SetText(i18n.Sprintf("Generating the identity..."))
+
+	algorithm := s.selectedCertificateKey()
+
+	go func() {
+		identity, err := client.NewIdentity(algorithm)
+
+		s.u.doInUIThread(func() {
+			s.chkPersistentIdentity.SetSensitive(true)
+			s.lblIdentityMessage.SetText("")
+
+			if err != nil {
+				log.WithFields(errorFields(err)).Errorf("the identity can't be generated: %s", err)
+				s.chkPersistentIdentity.SetActive(false)
+				s.u.reportError(i18n.Sprintf("The identity can't be generated: %s", identityErrorTranslator(err)))
+				return
+			}
+
+			s.identity = identity
+			s.updateIdentityFields()
+		})
+	}()
+}
+
+func (s *settings) exportIdentity() {
+	identity := s.identity
+	if identity == "" {
+		return
+	}
+
+	s.u.askPassphrase(i18n.Sprintf("Export identity"),
+		i18n.Sprintf("Enter a password to protect the identity. You will need it to import the identity in another device."),
+		func(password string, ok bool) {
+			if !ok {
+				return
+			}
+
+			fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Export identity"), identityFileDefaultName)
+			if !ok {
+				return
+			}
+
+			s.lblIdentityMessage.SetText(i18n.Sprintf("Exporting the identity..."))
+
+			go func() {
+				content, err := client.ExportIdentity(identity, password)
+				if err == nil {
+					err = ioutil.WriteFile(filepath.Clean(fileName), content, 0600)
+				}
+
+				s.u.doInUIThread(func() {
+					if err != nil {
+						s.lblIdentityMessage.SetText("")
+						log.WithFields(errorFields(err)).Errorf("the identity can't be exported: %s", err)
+						s.u.reportError(i18n.Sprintf("The identity can't be exported: %s", identityErrorTranslator(err)))
+						return
+					}
+
+					go s.u.messageToLabel(s.lblIdentityMessage, i18n.Sprintf("The identity has been exported"), 5)
+				})
+			}()
+		})
+}
+
+func (s *settings) importIdentity() {
+	fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Import identity"), "")
+	if !ok {
+		return
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		s.u.reportError(i18n.Sprintf("The identity can't be imported: %s", err))
+		return
+	}
+
+	s.u.askPassphrase(i18n.Sprintf("Import identity"),
+		i18n.Sprintf("Enter the password used when the identity was exported"),
+		func(password string, ok bool) {
+			if !ok {
+				return
+			}
+
+			s.lblIdentityMessage.SetText(i18n.Sprintf("Importing the identity..."))
+
+			go func() {
+				identity, err := client.ImportIdentity(content, password)
+
+				s.u.doInUIThread(func() {
+					if err != nil {
+						s.lblIdentityMessage.SetText("")
+						log.WithFields(errorFields(err)).Errorf("the identity can't be imported: %s", err)
+						s.u.reportError(i18n.Sprintf("The identity can't be imported: %s", identityErrorTranslator(err)))
+						return
+					}
+
+					// The identity is set first, so enabling the
+					// option doesn't generate a new one
+					s.identity = identity
+					s.chkPersistentIdentity.SetActive(true)
+					s.updateIdentityFields()
+
+					go s.u.messageToLabel(s.lblIdentityMessage, i18n.Sprintf("The identity has been imported"), 5)
+				})
+			}()
+		})
+}
+
+func identityErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case client.ErrInvalidIdentity:
+		return i18n.Sprintf("the identity is not valid")
+	case client.ErrIdentityPasswordRequired:
+		return i18n.Sprintf("a password is required to protect the identity")
+	case client.ErrInvalidIdentityFile:
+		return i18n.Sprintf("the identity file is not valid or the password is wrong")
+	case client.ErrUnknownCertificateKey:
+		return i18n.Sprintf("the certificate key is not supported")
+	}
+	return err.Error()
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/kardianos/osext"
 	log "github.com/sirupsen/logrus"
 )
@@ -38,6 +40,8 @@ func (u *gtkUI) ensureInstallation() {
 
 	i.ensureApplicationIcons()
 	i.ensureApplicationDesktop()
+	i.ensureURLSchemeHandler()
+	i.ensureInvitationMimeType()
 }
 
 var iconSizes = []int{16, 32, 48, 128, 256}
@@ -85,7 +89,7 @@ func (i *installation) ensureApplicationDesktop() {
 		}).Errorf("ensureApplicationDesktop(): %s", err.Error())
 	}
 
-	fileName := filepath.Join(i.dataHome, "applications", "wahay.desktop")
+	fileName := filepath.Join(i.dataHome, "applications", desktopFileName)
 	content := i.generateDesktopFile()
 
 	err = ioutil.WriteFile(fileName, []byte(content), 0600)
@@ -119,6 +123,83 @@ func (i *installation) generateDesktopFile() string {
 	return output
 }
 
+const (
+	desktopFileName      = "wahay.desktop"
+	mumbleSchemeMimeType = "x-scheme-handler/mumble"
+	wahaySchemeMimeType  = "x-scheme-handler/wahay"
+)
+
+// ensureURLSchemeHandler registers Wahay as the handler for wahay://
+// links, and for mumble:// links unless the user already has another
+// application for them
+func (i *installation) ensureURLSchemeHandler() {
+	xdgMime, err := exec.LookPath("xdg-mime")
+	if err != nil {
+		log.Debug("ensureURLSchemeHandler(): xdg-mime is not available")
+		return
+	}
+
+	if updateDB, err := exec.LookPath("update-desktop-database"); err == nil {
+		/* #nosec G204 */
+		_ = exec.Command(updateDB, filepath.Join(i.dataHome, "applications")).Run()
+	}
+
+	/* #nosec G204 */
+	err = exec.Command(xdgMime, "default", desktopFileName, wahaySchemeMimeType).Run()
+	if err != nil {
+		log.Errorf("ensureURLSchemeHandler(): %s", err.Error())
+	}
+
+	/* #nosec G204 */
+	current, err := exec.Command(xdgMime, "query", "default", mumbleSchemeMimeType).Output()
+	if err == nil && len(strings.TrimSpace(string(current))) > 0 {
+		log.WithFields(log.Fields{
+			"handler": strings.TrimSpace(string(current)),
+		}).Debug("ensureURLSchemeHandler(): mumble:// links are already handled")
+		return
+	}
+
+	/* #nosec G204 */
+	err = exec.Command(xdgMime, "default", desktopFileName, mumbleSchemeMimeType).Run()
+	if err != nil {
+		log.Errorf("ensureURLSchemeHandler(): %s", err.Error())
+	}
+}
+
+// ensureInvitationMimeType registers the MIME type of the invitation
+// files, so the file managers open them with Wahay
+func (i *installation) ensureInvitationMimeType() {
+	dir := filepath.Join(i.dataHome, "mime", "packages")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mimeDir": dir,
+		}).Errorf("ensureInvitationMimeType(): %s", err.Error())
+		return
+	}
+
+	fileName := filepath.Join(dir, invitationMimeFileName)
+	content := i.u.getConfigFileFor("wahay-invitation", ".xml")
+
+	err = ioutil.WriteFile(fileName, []byte(content), 0600)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mimeFileName": fileName,
+		}).Errorf("ensureInvitationMimeType(): %s", err.Error())
+		return
+	}
+
+	if updateDB, err := exec.LookPath("update-mime-database"); err == nil {
+		/* #nosec G204 */
+		_ = exec.Command(updateDB, filepath.Join(i.dataHome, "mime")).Run()
+	}
+
+	if xdgMime, err := exec.LookPath("xdg-mime"); err == nil {
+		/* #nosec G204 */
+		_ = exec.Command(xdgMime, "default", desktopFileName, invitation.FileMIMEType).Run()
+	}
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
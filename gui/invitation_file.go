@@ -0,0 +1,275 @@
+package gui
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/invitation"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	invitationFileDefaultName = "meeting" + invitation.FileExtension
+	invitationMimeFileName    = "wahay-invitation.xml"
+	droppedFileURIPrefix      = "file://"
+)
+
+// saveInvitationFile exports the invitation of the current meeting
+// to a file. The user can protect the file with a passphrase that
+// must be shared with the participants using a different channel
+func (h *hostData) saveInvitationFile() {
+	inv := h.getInvitationData()
+
+	h.u.askInvitationPassphrase(
+		i18n.Sprintf("Optionally, protect the invitation file with a passphrase. "+
+			"Share the passphrase with the participants using a different channel. "+
+			"Leave it blank to save the invitation without protection."),
+		func(passphrase string, ok bool) {
+			if !ok {
+				return
+			}
+
+			fileName, ok := h.u.chooseInvitationFile(gtki.FILE_CHOOSER_ACTION_SAVE)
+			if !ok {
+				return
+			}
+
+			if !invitation.IsInvitationFileName(fileName) {
+				fileName = fileName + invitation.FileExtension
+			}
+
+			content, err := inv.ExportFile(passphrase)
+			if err == nil {
+				err = ioutil.WriteFile(fileName, content, 0600)
+			}
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"file":  fileName,
+					"error": err,
+				}).Error("The invitation file can't be saved")
+				h.u.reportError(i18n.Sprintf("The invitation file can't be saved: %s", err.Error()))
+			}
+		})
+}
+
+// openInvitationFile lets the user choose an invitation file and
+// fills the join window with its content
+func (u *gtkUI) openInvitationFile(builder *uiBuilder) {
+	fileName, ok := u.chooseInvitationFile(gtki.FILE_CHOOSER_ACTION_OPEN)
+	if !ok {
+		return
+	}
+
+	u.importInvitationFile(fileName, func(inv *invitation.Invitation) {
+		u.fillJoinWindow(builder, inv)
+	})
+}
+
+// onMeetingIDChanged detects when an invitation file has been dropped
+// in the meeting ID field. In that case GTK inserts the URI of the file,
+// which is replaced with the content of the invitation
+func (u *gtkUI) onMeetingIDChanged(builder *uiBuilder) {
+	entMeetingID := builder.get("entMeetingID").(gtki.Entry)
+
+	text, _ := entMeetingID.GetText()
+	fileName, ok := droppedInvitationFile(text)
+	if !ok {
+		return
+	}
+
+	entMeetingID.SetText("")
+	u.importInvitationFile(fileName, func(inv *invitation.Invitation) {
+		u.fillJoinWindow(builder, inv)
+	})
+}
+
+func droppedInvitationFile(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, droppedFileURIPrefix) {
+		return "", false
+	}
+
+	// When several files are dropped, only the first one is used
+	text = strings.Fields(text)[0]
+
+	u, err := url.Parse(text)
+	if err != nil || !invitation.IsInvitationFileName(u.Path) {
+		return "", false
+	}
+
+	return u.Path, true
+}
+
+func isInvitationFilePath(p string) bool {
+	if !invitation.IsInvitationFileName(p) {
+		return false
+	}
+
+	st, err := os.Stat(p)
+	return err == nil && !st.IsDir()
+}
+
+// importInvitationFile reads the given invitation file, asking for
+// the passphrase if it's protected, and calls onSuccess with the result
+func (u *gtkUI) importInvitationFile(fileName string, onSuccess func(*invitation.Invitation)) {
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  fileName,
+			"error": err,
+		}).Error("The invitation file can't be read")
+		u.reportError(i18n.Sprintf("The invitation file can't be read: %s", err.Error()))
+		return
+	}
+
+	if !invitation.IsEncryptedFile(content) {
+		u.importInvitationFileContent(content, "", onSuccess)
+		return
+	}
+
+	u.askInvitationPassphrase(
+		i18n.Sprintf("This invitation file is protected. Enter the passphrase you received from the host."),
+		func(passphrase string, ok bool) {
+			if ok {
+				u.importInvitationFileContent(content, passphrase, onSuccess)
+			}
+		})
+}
+
+func (u *gtkUI) importInvitationFileContent(content []byte, passphrase string, onSuccess func(*invitation.Invitation)) {
+	inv, err := invitation.ImportFile(content, passphrase)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Invalid invitation file provided")
+		u.reportError(i18n.Sprintf("Invalid invitation file provided: %s", invitationErrorTranslator(err)))
+		return
+	}
+
+	onSuccess(inv)
+}
+
+func (u *gtkUI) fillJoinWindow(builder *uiBuilder, inv *invitation.Invitation) {
+	entMeetingID := builder.get("entMeetingID").(gtki.Entry)
+	entMeetingPassword := builder.get("entMeetingPassword").(gtki.Entry)
+	chkClearnetServer := builder.get("chkClearnetServer").(gtki.CheckButton)
+
+	chkExternalServer := builder.get("chkExternalServer").(gtki.CheckButton)
+	entCertificateURL := builder.get("entCertificateURL").(gtki.Entry)
+
+	entMeetingID.SetText(inv.URL())
+	entMeetingPassword.SetText(inv.Password)
+	chkClearnetServer.SetActive(!inv.IsOnion())
+	chkExternalServer.SetActive(inv.CertificateURL != "")
+	entCertificateURL.SetText(inv.CertificateURL)
+}
+
+func (u *gtkUI) chooseInvitationFile(action gtki.FileChooserAction) (string, bool) {
+	if action == gtki.FILE_CHOOSER_ACTION_SAVE {
+		return u.chooseFile(action, i18n.Sprintf("Save invitation file"), invitationFileDefaultName)
+	}
+	return u.chooseFile(action, i18n.Sprintf("Open invitation file"), "")
+}
+
+// chooseFile shows a file chooser and returns the selected file. It
+// must be called from the UI thread, since it waits for the user
+func (u *gtkUI) chooseFile(action gtki.FileChooserAction, title, defaultName string) (string, bool) {
+	button := i18n.Sprintf("Open")
+	if action == gtki.FILE_CHOOSER_ACTION_SAVE {
+		button = i18n.Sprintf("Save")
+	}
+
+	dialog, err := u.g.gtk.FileChooserDialogNewWith2Buttons(
+		title,
+		u.currentWindow,
+		action,
+		i18n.Sprintf("Cancel"),
+		gtki.RESPONSE_CANCEL,
+		button,
+		gtki.RESPONSE_ACCEPT)
+
+	if err != nil {
+		log.Errorf("chooseFile(): %s", err.Error())
+		return "", false
+	}
+
+	if action == gtki.FILE_CHOOSER_ACTION_SAVE {
+		dialog.SetCurrentName(defaultName)
+		dialog.SetDoOverwriteConfirmation(true)
+	}
+
+	if u.currentWindow != nil {
+		dialog.SetTransientFor(u.currentWindow)
+	}
+
+	defer dialog.Destroy()
+
+	if gtki.ResponseType(dialog.Run()) != gtki.RESPONSE_ACCEPT {
+		return "", false
+	}
+
+	return dialog.GetFilename(), true
+}
+
+func (u *gtkUI) askInvitationPassphrase(text string, onDone func(string, bool)) {
+	u.askPassphrase(i18n.Sprintf("Invitation passphrase"), text, onDone)
+}
+
+// askPassphrase shows the passphrase dialog with the given title and text
+func (u *gtkUI) askPassphrase(title, text string, onDone func(string, bool)) {
+	u.disableCurrentWindow()
+
+	builder := u.g.uiBuilderFor("InvitationPassphrase")
+	builder.i18nProperties(
+		"title", "dialog",
+		"label", "lblTitle",
+		"label", "lblText",
+		"placeholder", "entPassphrase",
+		"button", "btnCancel",
+		"button", "btnConfirm")
+
+	dialog := builder.get("dialog").(gtki.Window)
+	entPassphrase := builder.get("entPassphrase").(gtki.Entry)
+	lblTitle := builder.get("lblTitle").(gtki.Label)
+	lblText := builder.get("lblText").(gtki.Label)
+	dialog.SetTitle(title)
+	lblTitle.SetText(title)
+	lblText.SetText(text)
+
+	if u.currentWindow != nil {
+		dialog.SetTransientFor(u.currentWindow)
+	}
+
+	// Destroying the dialog emits the signal used for closing it,
+	// so we make sure the callback is only called once
+	done := false
+	clean := func(ok bool) {
+		if done {
+			return
+		}
+		done = true
+
+		passphrase, _ := entPassphrase.GetText()
+		dialog.Destroy()
+		u.enableCurrentWindow()
+		onDone(passphrase, ok)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_cancel": func() {
+			clean(false)
+		},
+		"on_confirm": func() {
+			clean(true)
+		},
+	})
+
+	dialog.Present()
+	dialog.Show()
+}
@@ -0,0 +1,114 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// shareInvitationLink publishes the invitation file in a temporary
+// onion service, so the host only has to share a short lived link
+// and its password instead of the address of the meeting
+func (h *hostData) shareInvitationLink() {
+	if h.distribution != nil {
+		h.showInvitationLink()
+		return
+	}
+
+	content, err := h.getInvitationData().ExportFile("")
+	if err != nil {
+		h.u.reportError(i18n.Sprintf("The invitation can't be published: %s", err.Error()))
+		return
+	}
+
+	h.u.displayLoadingWindow()
+
+	h.u.waitForTorInstance(func(t tor.Instance) {
+		d, err := hosting.PublishInvitation(t, content, hosting.DefaultDistributionLifetime)
+
+		h.u.doInUIThread(func() {
+			h.u.hideLoadingWindow()
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "distribution",
+				}).Errorf("the invitation can't be published: %s", err)
+				h.u.reportError(i18n.Sprintf("The invitation can't be published: %s", err.Error()))
+				return
+			}
+
+			h.distribution = d
+			h.showInvitationLink()
+		})
+	})
+}
+
+func (h *hostData) closeInvitationLink() {
+	if h.distribution == nil {
+		return
+	}
+
+	err := h.distribution.Close()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "distribution",
+		}).Errorf("the invitation link can't be closed: %s", err)
+	}
+
+	h.distribution = nil
+}
+
+func (h *hostData) getInvitationLinkText() string {
+	return i18n.Sprintf("Download the invitation for the Wahay meeting with Tor Browser: %s\nPassword: %s",
+		h.distribution.URL(), h.distribution.Password())
+}
+
+func (h *hostData) showInvitationLink() {
+	builder := h.u.g.uiBuilderFor("InvitationLinkWindow")
+	builder.i18nProperties(
+		"title", "invitationLinkWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"label", "lblLinkTitle",
+		"label", "lblPasswordTitle",
+		"button", "btnCopyLink",
+		"button", "btnStopSharing",
+		"button", "btnClose")
+
+	win := builder.get("invitationLinkWindow").(gtki.Window)
+	lblLink := builder.get("lblLink").(gtki.Label)
+	lblPassword := builder.get("lblPassword").(gtki.Label)
+	lblExpiration := builder.get("lblExpiration").(gtki.Label)
+	btnCopyLink := builder.get("btnCopyLink").(gtki.Button)
+
+	lblLink.SetText(h.distribution.URL())
+	lblPassword.SetText(h.distribution.Password())
+	lblExpiration.SetText(i18n.Sprintf("The link will stop working at %s",
+		h.distribution.ExpiresAt().Format("15:04")))
+	btnCopyLink.SetVisible(h.u.isCopyToClipboardSupported())
+
+	if h.u.currentWindow != nil {
+		win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_copy_link": func() {
+			err := h.u.copyToClipboard(h.getInvitationLinkText())
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "distribution",
+				}).Errorf("the invitation link can't be copied: %s", err)
+			}
+		},
+		"on_stop_sharing": func() {
+			h.closeInvitationLink()
+			win.Destroy()
+		},
+		"on_close": win.Destroy,
+	})
+
+	win.Present()
+	win.Show()
+}
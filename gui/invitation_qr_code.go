@@ -0,0 +1,159 @@
+package gui
+
+import (
+	"image"
+	// The formats of the images that can be scanned
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/coyim/gotk3adapter/gdki"
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/qr"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// qrCodeModuleSize is the size in pixels of every module of the QR code
+const qrCodeModuleSize = 6
+
+// invitationQRCode returns the QR code of the invitation. The certificate
+// is left out to keep the code small enough to be scanned, the participants
+// get it from the meeting and check it with the fingerprint
+func (h *hostData) invitationQRCode() ([]byte, error) {
+	inv := h.getInvitationData()
+	inv.Certificate = nil
+
+	encoded, err := inv.EncodeV2()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := qr.Encode([]byte(encoded), qr.Medium)
+	if err != nil {
+		return nil, err
+	}
+
+	return code.PNG(qrCodeModuleSize)
+}
+
+// showInvitationQRCode shows the invitation as a QR code, so it can
+// be moved to another device without sending it through any channel
+func (h *hostData) showInvitationQRCode() {
+	content, err := h.invitationQRCode()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "invitation",
+		}).Errorf("the invitation QR code can't be generated: %s", err)
+		h.u.reportError(i18n.Sprintf("The invitation QR code can't be generated: %s", err.Error()))
+		return
+	}
+
+	pixbuf, err := h.u.pixbufFromPNG(content)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "invitation",
+		}).Errorf("the invitation QR code can't be shown: %s", err)
+		h.u.reportError(i18n.Sprintf("The invitation QR code can't be generated: %s", err.Error()))
+		return
+	}
+
+	builder := h.u.g.uiBuilderFor("InvitationQRCodeWindow")
+	builder.i18nProperties(
+		"title", "invitationQRCodeWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"button", "btnClose")
+
+	win := builder.get("invitationQRCodeWindow").(gtki.Window)
+	imgQRCode := builder.get("imgQRCode").(gtki.Image)
+
+	imgQRCode.SetFromPixbuf(pixbuf)
+
+	if h.u.currentWindow != nil {
+		win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_close": win.Destroy,
+	})
+
+	win.Present()
+	win.Show()
+}
+
+func (u *gtkUI) pixbufFromPNG(content []byte) (gdki.Pixbuf, error) {
+	pl, err := u.g.gdk.PixbufLoaderNew()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := pl.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := pl.Close(); err != nil {
+		return nil, err
+	}
+
+	return pl.GetPixbuf()
+}
+
+// scanInvitationQRCode lets the user choose an image with the QR code
+// of an invitation and fills the join window with its content
+func (u *gtkUI) scanInvitationQRCode(builder *uiBuilder) {
+	fileName, ok := u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Open QR code image"), "")
+	if !ok {
+		return
+	}
+
+	inv, err := readInvitationQRCode(fileName)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  fileName,
+			"error": err,
+		}).Error("The invitation QR code can't be read")
+		u.reportError(i18n.Sprintf("The invitation QR code can't be read: %s", qrCodeErrorTranslator(err)))
+		return
+	}
+
+	u.fillJoinWindow(builder, inv)
+}
+
+func readInvitationQRCode(fileName string) (*invitation.Invitation, error) {
+	f, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := qr.Decode(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation.ParseAllowingClearnet(string(content))
+}
+
+func qrCodeErrorTranslator(err error) string {
+	switch err {
+	case image.ErrFormat:
+		return i18n.Sprintf("the file is not an image")
+	case qr.ErrNotFound:
+		return i18n.Sprintf("no QR code was found in the image")
+	case qr.ErrDamaged:
+		return i18n.Sprintf("the QR code is damaged, try with a sharper image")
+	}
+	return invitationErrorTranslator(err)
+}
@@ -0,0 +1,118 @@
+package gui
+
+import (
+	"strings"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sendInvitation asks for the recipient and delivers the invitation
+// with the sender the user has configured in the settings
+func (h *hostData) sendInvitation(builder *uiBuilder) {
+	settings := h.u.config.GetInvitationSender()
+	if !settings.IsEnabled() {
+		return
+	}
+
+	h.u.askInvitationRecipient(func(recipient string, ok bool) {
+		if !ok {
+			return
+		}
+
+		m := &hosting.InvitationMessage{
+			Recipient:  strings.TrimSpace(recipient),
+			Subject:    h.getInvitationSubject(),
+			Body:       strings.Replace(h.getInvitationText(), "%0D%0A", "\n", -1),
+			Invitation: h.getInvitationData(),
+		}
+
+		h.u.displayLoadingWindow()
+
+		h.u.waitForTorInstance(func(t tor.Instance) {
+			s, err := hosting.NewInvitationSender(t, settings)
+			if err == nil {
+				err = s.Send(m)
+			}
+
+			h.u.doInUIThread(func() {
+				h.u.hideLoadingWindow()
+
+				if err != nil {
+					log.WithFields(log.Fields{
+						"context": "sender",
+						"kind":    settings.Kind,
+					}).Errorf("the invitation can't be sent: %s", err)
+					h.u.reportError(i18n.Sprintf("The invitation can't be sent: %s", senderErrorTranslator(err)))
+					return
+				}
+
+				lblMessage := builder.get("lblMessage").(gtki.Label)
+				go h.u.messageToLabel(lblMessage, i18n.Sprintf("The invitation has been sent"), 5)
+			})
+		})
+	})
+}
+
+func (u *gtkUI) askInvitationRecipient(onDone func(string, bool)) {
+	u.disableCurrentWindow()
+
+	builder := u.g.uiBuilderFor("InvitationRecipient")
+	builder.i18nProperties(
+		"title", "dialog",
+		"label", "lblTitle",
+		"label", "lblText",
+		"placeholder", "entRecipient",
+		"button", "btnCancel",
+		"button", "btnConfirm")
+
+	dialog := builder.get("dialog").(gtki.Window)
+	entRecipient := builder.get("entRecipient").(gtki.Entry)
+
+	if u.currentWindow != nil {
+		dialog.SetTransientFor(u.currentWindow)
+	}
+
+	done := false
+	clean := func(ok bool) {
+		if done {
+			return
+		}
+		done = true
+
+		recipient, _ := entRecipient.GetText()
+		dialog.Destroy()
+		u.enableCurrentWindow()
+		onDone(recipient, ok)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_cancel": func() {
+			clean(false)
+		},
+		"on_confirm": func() {
+			clean(true)
+		},
+	})
+
+	dialog.Present()
+	dialog.Show()
+}
+
+func senderErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case hosting.ErrUnknownInvitationSender:
+		return i18n.Sprintf("the invitation sender is not supported")
+	case hosting.ErrInvalidInvitationSender:
+		return i18n.Sprintf("the configuration of the invitation sender is not complete")
+	case hosting.ErrInvalidRecipient:
+		return i18n.Sprintf("the recipient of the invitation is not valid")
+	case hosting.ErrSMTPTLSRequired:
+		return i18n.Sprintf("the email server doesn't support encrypted connections")
+	}
+	return invitationErrorTranslator(err)
+}
@@ -0,0 +1,57 @@
+package gui
+
+import (
+	"strings"
+
+	"github.com/coyim/gotk3adapter/gtki"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// showInvitationWords shows the invitation as a list of words, so the
+// host can read it to a participant that can't receive a message
+func (h *hostData) showInvitationWords() {
+	words, err := h.getInvitationData().EncodeWords()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "invitation",
+		}).Errorf("the invitation words can't be generated: %s", err)
+		h.u.reportError(i18n.Sprintf("The invitation words can't be generated: %s", invitationErrorTranslator(err)))
+		return
+	}
+
+	builder := h.u.g.uiBuilderFor("InvitationWordsWindow")
+	builder.i18nProperties(
+		"title", "invitationWordsWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"button", "btnCopyWords",
+		"button", "btnClose")
+
+	win := builder.get("invitationWordsWindow").(gtki.Window)
+	lblWords := builder.get("lblWords").(gtki.Label)
+	btnCopyWords := builder.get("btnCopyWords").(gtki.Button)
+
+	lblWords.SetText(words)
+	btnCopyWords.SetVisible(h.u.isCopyToClipboardSupported())
+
+	if h.u.currentWindow != nil {
+		win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_copy_words": func() {
+			// The meeting ID field has only one line
+			err := h.u.copyToClipboard(strings.Join(strings.Fields(words), " "))
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "invitation",
+				}).Errorf("the invitation words can't be copied: %s", err)
+			}
+		},
+		"on_close": win.Destroy,
+	})
+
+	win.Present()
+	win.Show()
+}
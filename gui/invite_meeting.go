@@ -1,14 +1,9 @@
 package gui
 
 import (
-	"errors"
-	"net"
-	"net/url"
-	"strconv"
-	"strings"
-
 	"github.com/coyim/gotk3adapter/gtki"
 	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/digitalautonomy/wahay/tor"
 
 	log "github.com/sirupsen/logrus"
@@ -29,7 +24,24 @@ func (u *gtkUI) getInviteCodeEntities() (gtki.ApplicationWindow, *uiBuilder) {
 		"label", "lblMeetingPassword",
 		"placeholder", "entScreenName",
 		"placeholder", "entMeetingID",
+		"tooltip", "entMeetingID",
 		"placeholder", "entMeetingPassword",
+		"checkbox", "chkClearnetServer",
+		"tooltip", "chkClearnetServer",
+		"checkbox", "chkExternalServer",
+		"tooltip", "chkExternalServer",
+		"placeholder", "entCertificateURL",
+		"button", "btnOpenInvitationFile",
+		"tooltip", "btnOpenInvitationFile",
+		"button", "btnScanQRCode",
+		"tooltip", "btnScanQRCode",
+		"button", "btnCheckAudio",
+		"tooltip", "btnCheckAudio",
+		"label", "lblAddressBook",
+		"tooltip", "cmbAddressBook",
+		"button", "btnRemoveAddress",
+		"placeholder", "entAddressLabel",
+		"button", "btnSaveAddress",
 		"button", "btnCancel",
 		"button", "btnJoin")
 
@@ -47,13 +59,22 @@ func (u *gtkUI) getCurrentMeetingWindow() *uiBuilder {
 		"secondary_text", "leaveMeeting",
 		"button", "btnLeaveMeeting",
 		"tooltip", "btnLeaveMeeting",
+		"button", "btnSharedFiles",
+		"tooltip", "btnSharedFiles",
+		"button", "btnRenewCircuits",
+		"tooltip", "btnRenewCircuits",
+		"button", "btnPanic",
+		"tooltip", "btnPanic",
+		"tooltip", "lblAudioPreset",
 		"label", "lblTipPush",
 	)
 
 	return builder
 }
 
-func (u *gtkUI) openCurrentMeetingWindow(m tor.Service) {
+func (u *gtkUI) openCurrentMeetingWindow(m *meetingSupervisor) {
+	data := m.data
+
 	if m.IsClosed() {
 		u.reportError(i18n.Sprintf("The Mumble process is down"))
 	}
@@ -63,6 +84,21 @@ func (u *gtkUI) openCurrentMeetingWindow(m tor.Service) {
 	builder := u.getCurrentMeetingWindow()
 	win := builder.get("currentMeetingWindow").(gtki.ApplicationWindow)
 
+	// Only Wahay meetings can have a file drop, and
+	// the invitation token is needed for using it
+	btnSharedFiles := builder.get("btnSharedFiles").(gtki.Button)
+	btnSharedFiles.SetVisible(data.Token != "" && data.CertificateURL == "")
+
+	// Only the meetings reached through onion services have circuits to renew
+	renewal := &circuitsRenewal{}
+	btnRenewCircuits := builder.get("btnRenewCircuits").(gtki.Button)
+	btnRenewCircuits.SetVisible(isOnionMeeting(data))
+
+	m.lblStatus = builder.get("lblConnectionStatus").(gtki.Label)
+
+	u.showAudioDecision(builder)
+	u.showStatsPanel(builder, win, m.stats)
+
 	builder.ConnectSignals(map[string]interface{}{
 		"on_close_window_signal": func() {
 			u.leaveMeeting(m)
@@ -71,37 +107,39 @@ func (u *gtkUI) openCurrentMeetingWindow(m tor.Service) {
 		"on_leave_meeting": func() {
 			u.leaveMeeting(m)
 		},
+		"on_panic": u.panicButton,
+		"on_shared_files": func() {
+			u.showRemoteSharedFiles(data)
+		},
+		"on_renew_circuits": func() {
+			u.renewMeetingCircuits(builder, renewal, data, false)
+		},
 	})
 
+	if isOnionMeeting(data) {
+		go u.watchMeetingLatency(builder, renewal, m)
+	}
+
 	u.connectShortcutCurrentMeetingWindow(win, m)
 
 	u.switchToWindow(win)
 }
 
 func (u *gtkUI) joinMeetingHandler(data hosting.MeetingData) {
-	if len(data.MeetingID) == 0 {
-		u.openErrorDialog(i18n.Sprintf("The Meeting ID cannot be blank"))
-		return
-	}
-
-	if !isAValidMeetingID(data.MeetingID) {
-		u.reportError(i18n.Sprintf("The provided meeting ID is invalid: \n\n%s", data.MeetingID))
-		return
-	}
-
 	u.hideCurrentWindow()
 	u.displayLoadingWindow()
 
-	var mumble tor.Service
+	// The supervisor starts Mumble again if it stops by
+	// itself, and reconnects when the circuits fail
+	m := u.newMeetingSupervisor(data)
+
 	var err error
 
 	finish := make(chan bool)
 
 	go func() {
-		mumble, err = u.launchMumbleClient(
-			data,
-			u.switchContextWhenMumbleFinish,
-		)
+		m.stats = u.newMeetingStats(data.MeetingID, nil)
+		err = m.launch()
 
 		finish <- true
 	}()
@@ -111,22 +149,32 @@ func (u *gtkUI) joinMeetingHandler(data hosting.MeetingData) {
 	u.hideLoadingWindow()
 
 	if err != nil {
-		u.openErrorDialog(i18n.Sprintf("An error occurred\n\n%s", err.Error()))
+		m.stats.close()
+		log.WithFields(errorFields(err)).Errorf("the meeting can't be joined: %s", err)
+		u.openErrorDialog(i18n.Sprintf("An error occurred\n\n%s", describeError(err)))
 		u.showMainWindow()
 		return
 	}
 
-	u.openCurrentMeetingWindow(mumble)
+	m.stats.setRoundTrip(u.currentAudio.rtt)
+	m.OnClose(m.stats.close)
+
+	u.rememberJoinedMeeting(data)
+	u.openCurrentMeetingWindow(m)
 }
 
 // Test Onion that can be used:
 // qvdjpoqcg572ibylv673qr76iwashlazh6spm47ly37w65iwwmkbmtid.onion
-func (u *gtkUI) openJoinWindow() {
+func (u *gtkUI) openJoinWindow() *uiBuilder {
 	win, builder := u.getInviteCodeEntities()
 
 	entMeetingID, _ := builder.get("entMeetingID").(gtki.Entry)
 	entScreenName, _ := builder.get("entScreenName").(gtki.Entry)
 	entMeetingPassword, _ := builder.get("entMeetingPassword").(gtki.Entry)
+	chkClearnetServer, _ := builder.get("chkClearnetServer").(gtki.CheckButton)
+	chkExternalServer, _ := builder.get("chkExternalServer").(gtki.CheckButton)
+	entCertificateURL, _ := builder.get("entCertificateURL").(gtki.Entry)
+	book := u.newAddressBook(builder)
 
 	cleanup := func() {
 		win.Destroy()
@@ -139,127 +187,157 @@ func (u *gtkUI) openJoinWindow() {
 			username, _ := entScreenName.GetText()
 			password, _ := entMeetingPassword.GetText()
 
-			// TODO: remove this if we show a custom input field to enter
-			// the SERVICE URL and the PORT
-			meetingID, port, err := extractMeetingIDandPort(url)
+			parse := invitation.Parse
+			if chkClearnetServer.GetActive() {
+				parse = invitation.ParseAllowingClearnet
+			}
+
+			inv, err := parse(url)
 			if err != nil {
 				log.WithFields(log.Fields{
-					"url":  url,
-					"ID":   meetingID,
-					"port": port,
+					"url":   url,
+					"error": err,
 				}).Error("Invalid meeting ID provided")
-				u.reportError(i18n.Sprintf("Invalid meeting ID provided"))
+				u.reportError(i18n.Sprintf("Invalid meeting ID provided: %s", invitationErrorTranslator(err)))
 				return
 			}
 
+			book.pinFingerprint(inv)
+
+			if username == "" {
+				username = inv.Username
+			}
+
+			if password == "" {
+				password = inv.Password
+			}
+
+			if chkExternalServer.GetActive() {
+				location, _ := entCertificateURL.GetText()
+				inv.CertificateURL, err = invitation.NormalizeCertificateURL(location)
+				if err != nil {
+					u.reportError(i18n.Sprintf("Invalid meeting ID provided: %s", invitationErrorTranslator(err)))
+					return
+				}
+			}
+
 			data := hosting.MeetingData{
-				MeetingID: meetingID,
-				Port:      port,
-				Username:  username,
-				Password:  password,
+				MeetingID:      inv.Host,
+				Port:           inv.Port,
+				Username:       username,
+				Password:       password,
+				Token:          inv.Token,
+				CertificateURL: inv.CertificateURL,
+				Fingerprint:    inv.Fingerprint,
+				Certificate:    inv.Certificate,
+				ClientAuth:     inv.ClientAuth,
+			}
+
+			if !inv.IsOnion() {
+				u.confirmClearnetJoin(data)
+				return
 			}
 
 			go u.joinMeetingHandler(data)
 		},
-		"on_cancel": cleanup,
-		"on_close":  cleanup,
+		"on_open_invitation_file": func() {
+			u.openInvitationFile(builder)
+		},
+		"on_scan_qr_code": func() {
+			u.scanInvitationQRCode(builder)
+		},
+		"on_check_audio": func() {
+			u.showDeviceCheck(win)
+		},
+		"on_meeting_id_changed": func() {
+			u.onMeetingIDChanged(builder)
+		},
+		"on_external_server_toggled": func() {
+			entCertificateURL.SetVisible(chkExternalServer.GetActive())
+		},
+		"on_address_book_changed": book.onChanged,
+		"on_save_address":         book.save,
+		"on_remove_address":       book.remove,
+		"on_cancel":               cleanup,
+		"on_close":                cleanup,
 	})
 
 	win.Show()
 	u.setCurrentWindow(win)
-}
-
-var errInvalidMeetingAddr = errors.New("invalid meeting address")
-
-func extractMeetingIDandPort(meetingURL string) (meetingID string, port int, err error) {
-	if !isAValidMeetingID(meetingURL) {
-		err = errInvalidMeetingAddr
-		return
-	}
 
-	if !strings.HasPrefix(meetingURL, "mumble://") {
-		v := url.URL{
-			Scheme: "mumble",
-			Host:   meetingURL,
-		}
-		meetingURL = v.String()
-	}
-
-	h, p, e := extractURLAndPort(meetingURL)
-	if e != nil {
-		err = errInvalidMeetingAddr
-		return
-	}
-
-	meetingID = h
-	port = p
-
-	return
+	return builder
 }
 
-func extractURLAndPort(urlToParse string) (host string, port int, err error) {
-	var h string
-	var p string
-	var e error
-
-	u, e := url.Parse(urlToParse)
-	if e != nil {
-		err = e
-		return
-	}
-
-	if u.Port() != "" {
-		h, p, e = net.SplitHostPort(u.Host)
-		if e != nil {
-			err = e
-			return
-		}
-
-		host = h
-
-		port, e = strconv.Atoi(p)
-		if e != nil {
-			err = e
+// confirmClearnetJoin asks the user to accept the different privacy
+// properties of joining a Mumble server that is not an onion service
+func (u *gtkUI) confirmClearnetJoin(data hosting.MeetingData) {
+	u.showConfirmation(func(ok bool) {
+		if ok {
+			log.WithFields(log.Fields{
+				"host": data.MeetingID,
+				"port": data.Port,
+			}).Warning("Joining a Mumble server that is not an onion service")
+			go u.joinMeetingHandler(data)
 		}
-
-		return
-	}
-
-	host = u.Host
-	port = hosting.DefaultPort
-
-	return host, port, err
+	}, i18n.Sprintf("You are about to join a Mumble server that is not an onion service. "+
+		"Your connection will still go through Tor, so the server will not know your IP address, "+
+		"but the server is not protected by Tor: its operator and network location are public, "+
+		"the server can be blocked or monitored, and Wahay can't verify its certificate for you. "+
+		"Only continue if you trust this server."))
 }
 
-const onionServiceLength = 62
-
-// TODO: This function needs to be improved in order to make a real validation of
-// the Meeting ID or Onion Address.
-// At the moment, this function helps to test the error code window render.
-func isAValidMeetingID(meetingID string) bool {
-	if len(meetingID) < onionServiceLength {
-		return false
-	}
-
-	if len(meetingID) == onionServiceLength &&
-		!strings.HasSuffix(meetingID, ".onion") {
-		return false
-	}
-
-	// TODO[OB]: this one is very confusing to me. It can NEVER be true
-	// since this code is dead
-	if len(meetingID) > onionServiceLength &&
-		len(meetingID[:onionServiceLength]) < onionServiceLength {
-		return false
-	}
-
-	if len(meetingID) > onionServiceLength &&
-		strings.Index(meetingID, ":") != onionServiceLength &&
-		strings.Index(meetingID, ".onion") != onionServiceLength-4 {
-		return false
+func invitationErrorTranslator(err error) string {
+	switch err {
+	case invitation.ErrEmptyInvitation:
+		return i18n.Sprintf("the meeting ID cannot be blank")
+	case invitation.ErrInvalidScheme:
+		return i18n.Sprintf("the invitation uses an unsupported scheme")
+	case invitation.ErrMalformedInvitation:
+		return i18n.Sprintf("the invitation is malformed")
+	case invitation.ErrUnexpectedContent:
+		return i18n.Sprintf("the invitation contains unexpected content")
+	case invitation.ErrNotAnOnionAddress:
+		return i18n.Sprintf("the meeting ID is not an onion address")
+	case invitation.ErrOnionV2NotSupported:
+		return i18n.Sprintf("version 2 onion addresses are not supported")
+	case invitation.ErrInvalidOnionAddress:
+		return i18n.Sprintf("the onion address is not valid")
+	case invitation.ErrInvalidOnionChecksum:
+		return i18n.Sprintf("the onion address has a typo: the checksum doesn't match")
+	case invitation.ErrInvalidPort:
+		return i18n.Sprintf("the meeting port is not valid")
+	case invitation.ErrInvalidHostname:
+		return i18n.Sprintf("the server address is not valid")
+	case invitation.ErrLocalAddress:
+		return i18n.Sprintf("the server address belongs to a local network")
+	case invitation.ErrInvalidV2Invitation:
+		return i18n.Sprintf("the invitation is corrupted")
+	case invitation.ErrUnsupportedInvitationVersion:
+		return i18n.Sprintf("the invitation was created by a newer version of Wahay")
+	case invitation.ErrInvitationExpired:
+		return i18n.Sprintf("the invitation has expired")
+	case invitation.ErrUnknownWord:
+		return i18n.Sprintf("the invitation contains a word that is not valid")
+	case invitation.ErrInvalidWordsChecksum:
+		return i18n.Sprintf("some words of the invitation are wrong or missing")
+	case invitation.ErrInvalidClientAuth:
+		return i18n.Sprintf("the client authorization credential is not valid")
+	case invitation.ErrInvalidFingerprint:
+		return i18n.Sprintf("the certificate fingerprint is not valid")
+	case invitation.ErrInvalidCertificate:
+		return i18n.Sprintf("the certificate in the invitation is not valid")
+	case invitation.ErrInvalidCertificateLocation:
+		return i18n.Sprintf("the certificate location is not valid")
+	case invitation.ErrInvalidSchedule:
+		return i18n.Sprintf("the meeting must last at least one minute")
+	case invitation.ErrInvalidFile:
+		return i18n.Sprintf("the file is not a valid Wahay invitation")
+	case invitation.ErrPassphraseRequired:
+		return i18n.Sprintf("the invitation file is protected with a passphrase")
+	case invitation.ErrWrongPassphrase:
+		return i18n.Sprintf("the passphrase of the invitation file is not correct")
 	}
-
-	return true
+	return err.Error()
 }
 
 func (u *gtkUI) leaveMeeting(m tor.Service) {
@@ -0,0 +1,47 @@
+package gui
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// keyringMasterPasswordName is the name of the master password in the
+// keyring of the system, where it's kept so the configuration file can
+// be decrypted at startup without asking the user for it
+const keyringMasterPasswordName = "master-password"
+
+func (u *gtkUI) isKeyringAvailable() bool {
+	return u.secrets != nil
+}
+
+// keyringMasterPassword returns the master password saved in the keyring
+func (u *gtkUI) keyringMasterPassword() (string, bool) {
+	if u.secrets == nil {
+		return "", false
+	}
+	return u.secrets.Lookup(keyringMasterPasswordName)
+}
+
+// forgetMasterPasswordInKeyring removes the master password from the
+// keyring, when it's not valid anymore or the user doesn't want it there
+func (u *gtkUI) forgetMasterPasswordInKeyring() {
+	if u.secrets != nil {
+		_ = u.secrets.Forget(keyringMasterPasswordName)
+	}
+}
+
+// rememberMasterPassword saves the master password in the keyring once
+// it has opened the configuration file, when the user asked for it
+func (u *gtkUI) rememberMasterPassword() {
+	if u.keyringPassword == "" || u.secrets == nil {
+		return
+	}
+
+	err := u.secrets.Save(keyringMasterPasswordName, u.keyringPassword)
+	u.keyringPassword = ""
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "keyring",
+		}).Warningf("The master password can't be saved in the keyring: %s", err)
+	}
+}
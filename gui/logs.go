@@ -10,11 +10,34 @@ import (
 	"github.com/digitalautonomy/wahay/config"
 )
 
+// logLevelChoices are the levels of the logs that can be chosen in
+// the settings, where the empty one is the default
+var logLevelChoices = []string{
+	"",
+	log.WarnLevel.String(),
+	log.DebugLevel.String(),
+	log.TraceLevel.String(),
+}
+
+func logLevelName(id string) string {
+	switch id {
+	case log.WarnLevel.String():
+		return i18n.Sprintf("Only warnings and errors")
+	case log.DebugLevel.String():
+		return i18n.Sprintf("Debugging information")
+	case log.TraceLevel.String():
+		return i18n.Sprintf("Everything, which is a lot")
+	}
+	return i18n.Sprintf("Informative messages (default)")
+}
+
 func (u *gtkUI) initLogs() {
 	if u.config == nil {
 		return
 	}
 
+	u.config.ApplyLogLevel()
+
 	if !u.config.IsLogsEnabled() {
 		return
 	}
@@ -37,7 +60,7 @@ func ensureLogFile(conf *config.ApplicationConfig) io.Writer {
 		return nil
 	}
 
-	file, err := os.OpenFile(rawLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	file, err := config.OpenRotatingFile(rawLogFile, config.DefaultMaxLogFileSize, config.DefaultLogFileBackups)
 	if err != nil {
 		return nil
 	}
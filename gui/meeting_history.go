@@ -0,0 +1,244 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	historyColumnIndex = iota
+	historyColumnLabel
+	historyColumnKind
+	historyColumnLastJoined
+)
+
+type meetingHistoryWindow struct {
+	u       *gtkUI
+	entries []config.MeetingHistoryEntry
+
+	win       gtki.Window
+	store     gtki.ListStore
+	tree      gtki.TreeView
+	inpLabel  gtki.Entry
+	lblStatus gtki.Label
+}
+
+// showMeetingHistory lists the meetings the user has been in,
+// for joining them again with one click
+func (u *gtkUI) showMeetingHistory() {
+	builder := u.g.uiBuilderFor("MeetingHistoryWindow")
+	builder.i18nProperties(
+		"title", "meetingHistoryWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"title", "colLabel",
+		"title", "colKind",
+		"title", "colLastJoined",
+		"placeholder", "inpLabel",
+		"button", "btnRename",
+		"button", "btnClearHistory",
+		"button", "btnClose",
+		"button", "btnJoinAgain")
+
+	w := &meetingHistoryWindow{u: u}
+
+	builder.getItems(
+		"meetingHistoryWindow", &w.win,
+		"storeHistory", &w.store,
+		"treeHistory", &w.tree,
+		"inpLabel", &w.inpLabel,
+		"lblStatus", &w.lblStatus,
+	)
+
+	if u.mainWindow != nil {
+		w.win.SetTransientFor(u.mainWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_join_again":    w.joinAgain,
+		"on_rename":        w.rename,
+		"on_clear_history": w.clear,
+		"on_close":         w.win.Destroy,
+	})
+
+	w.refresh()
+
+	switch {
+	case !u.config.GetKeepMeetingHistory():
+		w.lblStatus.SetText(i18n.Sprintf("The history of the meetings is disabled. You can enable it in the settings."))
+	case !u.config.CanSaveMeetingRooms():
+		w.lblStatus.SetText(i18n.Sprintf("The history of the meetings is only kept when the configuration " +
+			"is saved encrypted with a master password."))
+	}
+
+	w.win.Present()
+	w.win.Show()
+}
+
+func (w *meetingHistoryWindow) refresh() {
+	w.store.Clear()
+	w.entries = w.u.config.ListMeetingHistory()
+
+	for i, e := range w.entries {
+		label := e.Label
+		if label == "" {
+			label = e.Address
+		}
+
+		kind := i18n.Sprintf("Joined")
+		if e.Room != "" {
+			kind = i18n.Sprintf("Hosted")
+		}
+
+		iter := w.store.Append()
+		_ = w.store.Set2(iter,
+			[]int{historyColumnIndex, historyColumnLabel, historyColumnKind, historyColumnLastJoined},
+			[]interface{}{i, label, kind, e.LastJoined.Format(calendarDateFormat + " " + calendarTimeFormat)})
+	}
+}
+
+func (w *meetingHistoryWindow) selectedEntry() (config.MeetingHistoryEntry, bool) {
+	sel, err := w.tree.GetSelection()
+	if err != nil {
+		return config.MeetingHistoryEntry{}, false
+	}
+
+	model, iter, ok := sel.GetSelected()
+	if !ok {
+		return config.MeetingHistoryEntry{}, false
+	}
+
+	v, err := model.GetValue(iter, historyColumnIndex)
+	if err != nil {
+		return config.MeetingHistoryEntry{}, false
+	}
+
+	index, _ := v.GoValue()
+	i, _ := index.(int)
+	if i < 0 || i >= len(w.entries) {
+		return config.MeetingHistoryEntry{}, false
+	}
+
+	return w.entries[i], true
+}
+
+// joinAgain joins the meeting chosen, or hosts it again
+// when it's a meeting room the user has hosted
+func (w *meetingHistoryWindow) joinAgain() {
+	e, ok := w.selectedEntry()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the meeting you want to join again"))
+		return
+	}
+
+	u := w.u
+	if !u.meetingsEnabled {
+		w.lblStatus.SetText(i18n.Sprintf("Wahay is not ready for meetings yet"))
+		return
+	}
+
+	if e.Room != "" {
+		room, exists := u.config.MeetingRoom(e.Room)
+		if !exists {
+			w.lblStatus.SetText(i18n.Sprintf("The meeting room %s doesn't exist anymore", e.Room))
+			return
+		}
+
+		w.win.Destroy()
+		go u.realHostMeetingHandler(&room)
+		return
+	}
+
+	data := hosting.MeetingData{
+		MeetingID:      e.Address,
+		Port:           e.Port,
+		Username:       e.Username,
+		Password:       e.Password,
+		Token:          e.Token,
+		CertificateURL: e.CertificateURL,
+		Fingerprint:    e.Fingerprint,
+		Certificate:    e.Certificate,
+		ClientAuth:     e.ClientAuth,
+	}
+
+	w.win.Destroy()
+
+	if !isOnionMeeting(data) {
+		u.confirmClearnetJoin(data)
+		return
+	}
+
+	go u.joinMeetingHandler(data)
+}
+
+func (w *meetingHistoryWindow) rename() {
+	e, ok := w.selectedEntry()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the meeting you want to rename"))
+		return
+	}
+
+	label := getTrimmedText(w.inpLabel)
+	if w.u.config.RenameInMeetingHistory(e.Address, e.Port, label) {
+		w.u.saveConfigOnly()
+	}
+
+	w.inpLabel.SetText("")
+	w.refresh()
+}
+
+func (w *meetingHistoryWindow) clear() {
+	w.u.showConfirmation(func(ok bool) {
+		if !ok {
+			return
+		}
+
+		w.u.config.ClearMeetingHistory()
+		w.u.saveConfigOnly()
+
+		log.WithFields(log.Fields{
+			"context": "history",
+		}).Info("The history of the meetings has been cleared")
+
+		w.refresh()
+	}, i18n.Sprintf("All the meetings will be removed from the history."))
+}
+
+// addToMeetingHistory remembers the meeting, when the user wants it
+func (u *gtkUI) addToMeetingHistory(e config.MeetingHistoryEntry) {
+	if !u.config.CanKeepMeetingHistory() {
+		return
+	}
+
+	u.config.AddToMeetingHistory(e)
+	u.saveConfigOnly()
+}
+
+func (u *gtkUI) rememberJoinedMeeting(data hosting.MeetingData) {
+	u.addToMeetingHistory(config.MeetingHistoryEntry{
+		Address:        data.MeetingID,
+		Port:           data.Port,
+		Fingerprint:    data.Fingerprint,
+		Username:       data.Username,
+		Password:       data.Password,
+		Token:          data.Token,
+		CertificateURL: data.CertificateURL,
+		Certificate:    data.Certificate,
+		ClientAuth:     data.ClientAuth,
+	})
+}
+
+// rememberHostedRoom keeps the meeting room in the history, since
+// it's the only kind of hosted meeting that can be hosted again
+func (h *hostData) rememberHostedRoom() {
+	h.u.addToMeetingHistory(config.MeetingHistoryEntry{
+		Address:     h.service.ID(),
+		Port:        h.service.ServicePort(),
+		Label:       h.room.Name,
+		Fingerprint: h.service.Fingerprint(),
+		Room:        h.room.Name,
+	})
+}
@@ -0,0 +1,178 @@
+package gui
+
+import (
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The first choices of the meeting room window are always
+// these ones, and the saved rooms come after them
+const (
+	meetingRoomChoiceNone = iota
+	meetingRoomChoiceNew
+	meetingRoomChoiceSaved
+)
+
+// chooseMeetingRoom asks the host whether to host a meeting that is
+// forgotten when it finishes, a new meeting room or a saved one. The
+// room given to onDone is nil for the meetings that are not saved, and
+// it only has a name for the new rooms
+func (u *gtkUI) chooseMeetingRoom(onDone func(*config.MeetingRoom)) {
+	rooms := u.config.ListMeetingRooms()
+
+	builder := u.g.uiBuilderFor("MeetingRoomWindow")
+	builder.i18nProperties(
+		"title", "dialog",
+		"label", "lblTitle",
+		"label", "lblText",
+		"label", "lblRoomMessage",
+		"placeholder", "entRoomName",
+		"button", "btnRemoveRoom",
+		"button", "btnCancel",
+		"button", "btnConfirm")
+
+	dialog := builder.get("dialog").(gtki.Window)
+	cmbMeetingRoom := builder.get("cmbMeetingRoom").(gtki.ComboBoxText)
+	entRoomName := builder.get("entRoomName").(gtki.Entry)
+	lblRoomMessage := builder.get("lblRoomMessage").(gtki.Label)
+	btnRemoveRoom := builder.get("btnRemoveRoom").(gtki.Button)
+
+	cmbMeetingRoom.AppendText(i18n.Sprintf("A meeting that is forgotten when it finishes"))
+	cmbMeetingRoom.AppendText(i18n.Sprintf("A new meeting room"))
+	for _, r := range rooms {
+		cmbMeetingRoom.AppendText(i18n.Sprintf("Meeting room: %s", r.Name))
+	}
+
+	selectedRoom := func() *config.MeetingRoom {
+		i := cmbMeetingRoom.GetActive() - meetingRoomChoiceSaved
+		if i < 0 || i >= len(rooms) {
+			return nil
+		}
+		return &rooms[i]
+	}
+
+	onChanged := func() {
+		entRoomName.SetVisible(cmbMeetingRoom.GetActive() == meetingRoomChoiceNew)
+		btnRemoveRoom.SetVisible(selectedRoom() != nil)
+		lblRoomMessage.SetVisible(false)
+	}
+
+	if u.mainWindow != nil {
+		dialog.SetTransientFor(u.mainWindow)
+		u.disableWindow(u.mainWindow)
+	}
+
+	clean := func() {
+		dialog.Destroy()
+		u.enableMainWindow()
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_room_changed": onChanged,
+		"on_cancel":       clean,
+		"on_remove_room": func() {
+			r := selectedRoom()
+			if r == nil {
+				return
+			}
+
+			clean()
+			u.removeMeetingRoom(r.Name, func() {
+				u.chooseMeetingRoom(onDone)
+			})
+		},
+		"on_confirm": func() {
+			var room *config.MeetingRoom
+
+			switch cmbMeetingRoom.GetActive() {
+			case meetingRoomChoiceNone:
+			case meetingRoomChoiceNew:
+				name := getTrimmedText(entRoomName)
+				if name == "" {
+					lblRoomMessage.SetText(i18n.Sprintf("Enter a name for the meeting room"))
+					lblRoomMessage.SetVisible(true)
+					return
+				}
+				if _, exists := u.config.MeetingRoom(name); exists {
+					lblRoomMessage.SetText(i18n.Sprintf("There is already a meeting room with that name"))
+					lblRoomMessage.SetVisible(true)
+					return
+				}
+				room = &config.MeetingRoom{Name: name}
+			default:
+				room = selectedRoom()
+			}
+
+			clean()
+			onDone(room)
+		},
+	})
+
+	active := meetingRoomChoiceNone
+	if len(rooms) > 0 {
+		active = meetingRoomChoiceSaved
+	}
+	cmbMeetingRoom.SetActive(active)
+	onChanged()
+
+	dialog.Present()
+	dialog.Show()
+}
+
+// removeMeetingRoom forgets the room, after the host confirms it,
+// so its address and its invitation can't be used anymore
+func (u *gtkUI) removeMeetingRoom(name string, onDone func()) {
+	u.showConfirmation(func(ok bool) {
+		if ok && u.config.RemoveMeetingRoom(name) {
+			log.WithFields(log.Fields{
+				"context": "rooms",
+			}).Info("A meeting room has been removed")
+			u.saveConfigOnly()
+		}
+		onDone()
+	}, i18n.Sprintf("The meeting room %s will be removed, and its invitation will not work anymore. "+
+		"Anybody who has it will need a new invitation.", name))
+}
+
+// hostingRoom returns the room to publish, which is
+// empty for the meeting rooms that are new
+func hostingRoom(r *config.MeetingRoom) *hosting.Room {
+	if r.OnionKey == "" {
+		return nil
+	}
+
+	return &hosting.Room{
+		OnionKey:          r.OnionKey,
+		Port:              r.Port,
+		Token:             r.Token,
+		Certificate:       []byte(r.Certificate),
+		CertificateKey:    []byte(r.CertificateKey),
+		SuperUserPassword: r.SuperUserPassword,
+	}
+}
+
+// saveMeetingRoom remembers the room of the meeting, so it can
+// be hosted again with the same address and invitation
+func (h *hostData) saveMeetingRoom() {
+	r, err := h.service.Room()
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the meeting room can't be saved: %s", err)
+		return
+	}
+
+	h.u.config.SaveMeetingRoom(config.MeetingRoom{
+		Name:              h.room.Name,
+		Port:              r.Port,
+		OnionKey:          r.OnionKey,
+		Token:             r.Token,
+		Certificate:       string(r.Certificate),
+		CertificateKey:    string(r.CertificateKey),
+		Created:           h.room.Created,
+		SuperUserPassword: r.SuperUserPassword,
+		WelcomeText:       h.room.WelcomeText,
+	})
+	h.u.saveConfigOnly()
+}
@@ -0,0 +1,191 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/control"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// statsRefreshInterval is how often the statistics panel is updated
+const statsRefreshInterval = 5 * time.Second
+
+// meetingStats gathers how the connection to a meeting works while the
+// user is in it, or hosts it. The traffic is the one of the Mumble
+// client of the user through Tor, and the participants are only known
+// by the host
+type meetingStats struct {
+	sync.Mutex
+	meter        tor.TrafficMeter
+	roundTrip    time.Duration
+	participants hosting.Participants
+	closed       bool
+}
+
+// newMeetingStats starts measuring the traffic to the meeting. It must
+// be called before Mumble connects, since only the connections made
+// after this are counted
+func (u *gtkUI) newMeetingStats(meetingID string, participants hosting.Participants) *meetingStats {
+	s := &meetingStats{
+		participants: participants,
+	}
+
+	m, err := u.torInstance().MeasureTraffic(meetingID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "stats",
+		}).Debugf("the traffic to the meeting can't be measured: %s", err)
+	} else {
+		s.meter = m
+	}
+
+	return s
+}
+
+func (s *meetingStats) setRoundTrip(d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.roundTrip = d
+}
+
+// measureRoundTrip measures the round trip to the given address until
+// the statistics are closed, for the meetings where nothing else does it
+func (s *meetingStats) measureRoundTrip(t tor.Instance, address string) {
+	for !s.isClosed() {
+		rtt, err := client.MeasureRTT(t, address, 1)
+		if err == nil {
+			s.setRoundTrip(rtt)
+		}
+
+		time.Sleep(latencyCheckInterval)
+	}
+}
+
+func (s *meetingStats) isClosed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed
+}
+
+func (s *meetingStats) close() {
+	s.Lock()
+	s.closed = true
+	s.Unlock()
+
+	if s.meter != nil {
+		s.meter.Close()
+	}
+}
+
+// snapshot returns the statistics now. It asks the server of the hosted
+// meeting for new ones, so it must be called outside of the UI thread
+func (s *meetingStats) snapshot() control.MeetingStats {
+	s.Lock()
+	result := control.MeetingStats{
+		RoundTripMs: s.roundTrip.Milliseconds(),
+	}
+	s.Unlock()
+
+	if s.meter != nil {
+		traffic := s.meter.Stats()
+		result.BytesSent = traffic.Sent
+		result.BytesReceived = traffic.Received
+	}
+
+	if s.participants != nil {
+		for _, p := range s.participants.Stats() {
+			result.Participants = append(result.Participants, control.ParticipantStats{
+				Session:     p.Session,
+				Name:        p.Name,
+				RoundTripMs: p.RoundTrip.Milliseconds(),
+				Loss:        p.Loss(),
+			})
+		}
+	}
+
+	return result
+}
+
+// currentMeetingStats returns the statistics of the hosted meeting,
+// or of the meeting the user is in. While the host is not in the
+// meeting, only the statistics of the participants are known
+func (u *gtkUI) currentMeetingStats() *meetingStats {
+	if h := u.currentHost; h != nil {
+		if h.stats != nil && !h.stats.isClosed() {
+			return h.stats
+		}
+		if h.service == nil {
+			return nil
+		}
+		return &meetingStats{participants: h.service.Participants()}
+	}
+
+	if m, ok := u.currentMumble.(*meetingSupervisor); ok {
+		return m.stats
+	}
+
+	return nil
+}
+
+// showStatsPanel keeps the statistics panel of the window updated,
+// while the window is shown, until the statistics are closed
+func (u *gtkUI) showStatsPanel(builder *uiBuilder, win gtki.Window, s *meetingStats) {
+	builder.i18nProperties("label", "lblStatsTitle")
+
+	lblStats := builder.get("lblStats").(gtki.Label)
+	lblStats.SetText(i18n.Sprintf("Measuring the connection..."))
+
+	if s == nil {
+		lblStats.SetText(i18n.Sprintf("The connection to this meeting can't be measured"))
+		return
+	}
+
+	go func() {
+		for !s.isClosed() {
+			time.Sleep(statsRefreshInterval)
+
+			shown := make(chan bool)
+			u.doInUIThread(func() {
+				shown <- u.currentWindow == win
+			})
+			if !<-shown {
+				continue
+			}
+
+			text := describeMeetingStats(s.snapshot())
+			u.doInUIThread(func() {
+				lblStats.SetText(text)
+			})
+		}
+	}()
+}
+
+func describeMeetingStats(st control.MeetingStats) string {
+	lines := []string{
+		i18n.Sprintf("Sent: %s · Received: %s", formatFileSize(int(st.BytesSent)), formatFileSize(int(st.BytesReceived))),
+	}
+
+	if st.RoundTripMs > 0 {
+		lines = append(lines, i18n.Sprintf("Round trip to the meeting: %d ms", st.RoundTripMs))
+	}
+
+	for _, p := range st.Participants {
+		loss := i18n.Sprintf("no voice statistics")
+		if p.Loss >= 0 {
+			loss = i18n.Sprintf("%s of the voice lost or late", fmt.Sprintf("%.1f%%", p.Loss*100))
+		}
+		lines = append(lines, i18n.Sprintf("%s: %d ms, %s", p.Name, p.RoundTripMs, loss))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,147 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// meetingEndWarning is how long before the meeting ends
+	// automatically the participants are told
+	meetingEndWarning = 5 * time.Minute
+
+	// maxMeetingDuration is the longest duration, in
+	// minutes, that can be given to a meeting
+	maxMeetingDuration = 24 * 60
+)
+
+// parseMeetingDuration returns the minutes the meeting lasts, which
+// is zero when the host ends it, or false when they are not valid
+func parseMeetingDuration(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, true
+	}
+
+	m, err := strconv.Atoi(v)
+	if err != nil || m < 1 || m > maxMeetingDuration {
+		return 0, false
+	}
+
+	return time.Duration(m) * time.Minute, true
+}
+
+// formatMeetingTime shows the duration as hours, minutes and seconds
+func formatMeetingTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// startMeetingTimer counts the time since the meeting started, and ends
+// it once the given duration has passed, when it's not zero
+func (h *hostData) startMeetingTimer(duration time.Duration) {
+	h.started = time.Now()
+	if duration > 0 {
+		h.endsAt = h.started.Add(duration)
+	}
+
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+
+		for range t.C {
+			done := make(chan bool)
+			h.u.doInUIThread(func() {
+				done <- h.meetingTimerTick()
+			})
+			if !<-done {
+				return
+			}
+		}
+	}()
+}
+
+// meetingTimerTick must be called from the UI thread. It returns
+// false once the timer is not needed anymore
+func (h *hostData) meetingTimerTick() bool {
+	if h.finishing {
+		return false
+	}
+
+	h.showMeetingTime()
+
+	if h.endsAt.IsZero() {
+		return true
+	}
+
+	left := time.Until(h.endsAt)
+	if left <= 0 {
+		h.endMeetingOnTime()
+		return false
+	}
+
+	if left <= meetingEndWarning && !h.endWarned {
+		h.endWarned = true
+		h.warnMeetingEnd(left)
+	}
+
+	return true
+}
+
+// showMeetingTimer shows how long the meeting has lasted in the
+// window being shown
+func (h *hostData) showMeetingTimer(builder *uiBuilder) {
+	h.lblMeetingTime = builder.get("lblMeetingTime").(gtki.Label)
+	h.showMeetingTime()
+}
+
+// showMeetingTime must be called from the UI thread
+func (h *hostData) showMeetingTime() {
+	if h.lblMeetingTime == nil || h.started.IsZero() {
+		return
+	}
+
+	text := i18n.Sprintf("Meeting time: %s", formatMeetingTime(time.Since(h.started)))
+	if !h.endsAt.IsZero() {
+		text = i18n.Sprintf("Meeting time: %s - it ends at %s", formatMeetingTime(time.Since(h.started)), h.endsAt.Format("15:04"))
+	}
+	h.lblMeetingTime.SetText(text)
+}
+
+func (h *hostData) warnMeetingEnd(left time.Duration) {
+	minutes := int(left.Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	message := i18n.Sprintf("This meeting will end in %d minutes.", minutes)
+	go func() {
+		err := h.service.Announce(message)
+		if err != nil {
+			log.WithFields(errorFields(err)).Warningf("the participants can't be told that the meeting is ending: %s", err)
+		}
+	}()
+
+	h.u.showNotificationBanner(i18n.Sprintf("The meeting is ending"),
+		i18n.Sprintf("The meeting will end in %d minutes, and the participants have been told.", minutes))
+}
+
+// endMeetingOnTime finishes the meeting like the host does, after
+// closing Mumble when the host is in the meeting with it
+func (h *hostData) endMeetingOnTime() {
+	log.WithFields(log.Fields{
+		"context": "hosting",
+	}).Info("The meeting has reached its duration, so it's being finished")
+
+	if h.mumble != nil && h.u.currentMumble != nil {
+		h.next = h.uiActionFinishMeeting
+		go h.mumble.Close()
+		return
+	}
+
+	h.finishMeetingReal()
+}
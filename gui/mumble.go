@@ -1,42 +1,221 @@
 package gui
 
 import (
-	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func (u *gtkUI) ensureMumble(wg *sync.WaitGroup) {
 	wg.Add(1)
-	u.waitForTorInstance(func(t tor.Instance) {
-		go func() {
-			defer wg.Done()
+	go func() {
+		defer wg.Done()
 
-			c := client.InitSystem(u.config, t)
+		// Looking for Mumble and preparing its configuration
+		// doesn't need Tor, so we do it while Tor is bootstrapping
+		c := client.InitSystem(u.config, nil)
 
-			if !c.IsValid() {
-				u.errorHandler.addNewStartupError(c.LastError(), errGroupMumble)
-				return
-			}
+		if !c.IsValid() && failure.Is(c.LastError(), client.ErrMumbleNotAvailable) && client.CanDownloadMumble() {
+			u.torInitialized.Wait()
+			c = u.downloadMumble(c)
+		}
 
-			u.onExit(c.Destroy)
+		if !c.IsValid() {
+			u.errorHandler.addNewStartupError(c.LastError(), errGroupMumble)
+			return
+		}
 
-			u.client = c
-		}()
-	})
+		u.onExit(c.Destroy)
+
+		u.torInitialized.Wait()
+		c.UseTor(u.tor)
+
+		u.client = c
+	}()
+}
+
+// downloadMumble gets a known-good Mumble over Tor when none is found
+// in the system. If it fails, the original client is returned so the
+// user is told to install Mumble
+func (u *gtkUI) downloadMumble(c client.Instance) client.Instance {
+	if u.tor == nil {
+		return c
+	}
+
+	log.Info("No Mumble was found, downloading it over Tor")
+
+	err := client.DownloadMumble(u.tor, nil)
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("Mumble couldn't be downloaded: %s", err)
+		return c
+	}
+
+	return client.InitSystem(u.config, nil)
 }
 
 func (u *gtkUI) launchMumbleClient(data hosting.MeetingData, onClose func()) (tor.Service, error) {
+	defer config.LogDuration("join: launch Mumble", time.Now())
+
 	c := u.client
 
 	if !c.IsValid() {
-		return nil, errors.New("error: no client to run")
+		return nil, client.ErrMumbleNotAvailable
+	}
+
+	// Tor might have been started again since the client was initialized
+	t := u.torInstance()
+	c.UseTor(t)
+
+	if data.ClientAuth != "" {
+		err := t.AuthorizeOnion(data.MeetingID, data.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	u.currentAudio = u.chooseAudioPreset(t, data)
+	c.SetAudioPreset(u.currentAudio.preset)
+	c.SetAudioSettings(u.config.GetAudioSettings())
+	c.SetIsolatedProfile(u.config.GetIsolatedProfiles())
+
+	err := c.SetCertificateKey(u.config.GetCertificateKey())
+	if err != nil {
+		log.WithFields(errorFields(err)).Warningf("the configured certificate key can't be used: %s", err)
+	}
+
+	c.SetCertificateVerifier(u.verifyCertificate)
+	c.SetTrustStore(configTrustStore{u})
+
+	err = c.SetIdentity(u.config.GetClientIdentity())
+	if err != nil {
+		log.WithFields(errorFields(err)).Warningf("the configured identity can't be used, a temporary one is used instead: %s", err)
+	}
+
+	// Mumble can stop before Launch returns, so the
+	// service is waited for when it's closed
+	launched := make(chan tor.Service, 1)
+
+	s, err := c.Launch(data.GenerateURL(), func() {
+		u.checkMumbleExit(<-launched)
+		u.currentMumble = nil
+		if onClose != nil {
+			onClose()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	launched <- s
+	u.currentMumble = s
+
+	return s, nil
+}
+
+// checkMumbleExit tells apart a crash of Mumble from the user closing
+// it, keeping what Mumble wrote before crashing for the diagnostic bundle
+func (u *gtkUI) checkMumbleExit(s tor.Service) {
+	err := s.Err()
+	if err == nil {
+		return
+	}
+
+	output := s.Output()
+
+	log.WithFields(log.Fields{
+		"context": "mumble",
+	}).Errorf("Mumble stopped unexpectedly: %s\n%s", err, output)
+
+	u.doInUIThread(func() {
+		u.lastMumbleCrash = mumbleCrashReport(err, output)
+	})
+}
+
+func mumbleCrashReport(err error, output string) string {
+	report := fmt.Sprintf("%s: %s", time.Now().Format(time.RFC3339), err)
+	if output != "" {
+		report += "\n" + output
+	}
+	return report
+}
+
+// configTrustStore keeps the trusted certificates in the configuration,
+// saving it every time a new one is trusted
+type configTrustStore struct {
+	u *gtkUI
+}
+
+func (s configTrustStore) TrustedCertificate(host string, port int) (config.TrustedCertificate, bool) {
+	return s.u.config.TrustedCertificate(host, port)
+}
+
+func (s configTrustStore) TrustCertificate(c config.TrustedCertificate) {
+	s.u.config.TrustCertificate(c)
+	s.u.saveConfigOnly()
+}
+
+func (s configTrustStore) CertificateChanged(host string, port int, fingerprint string) {
+	s.u.notifyEvent(notificationCertificateChanged, i18n.Sprintf("The certificate of the meeting has changed"),
+		i18n.Sprintf("The meeting in %s has a different certificate than the last time you joined it. "+
+			"Only trust it if the host of the meeting confirms the new fingerprint.", host))
+}
+
+// verifyCertificate asks the user to compare the fingerprint of the
+// certificate with the one the host of the meeting sees. It's called
+// while the client is being launched, outside of the UI thread
+func (u *gtkUI) verifyCertificate(hostname, fingerprint string, withoutTLS bool) bool {
+	result := make(chan bool, 1)
+
+	warning := ""
+	if withoutTLS {
+		warning = i18n.Sprintf("The meeting is hosted with an old version of Wahay, "+
+			"so the certificate was received without TLS.") + "\n\n"
 	}
 
-	return c.Launch(data.GenerateURL(), onClose)
+	u.doInUIThread(func() {
+		u.showConfirmation(func(ok bool) {
+			select {
+			case result <- ok:
+			default:
+			}
+		}, warning+i18n.Sprintf("This is the first time you join this meeting. "+
+			"Before trusting it, ask the host of the meeting to confirm, using a different channel, "+
+			"that the fingerprint of the certificate is:\n\n%s\n\n"+
+			"Only continue if the fingerprint is exactly the same.", invitation.FormatFingerprint(fingerprint)))
+	})
+
+	ok := <-result
+	if !ok {
+		log.WithFields(log.Fields{
+			"hostname": hostname,
+		}).Warning("The certificate of the meeting was not trusted")
+	}
+
+	return ok
+}
+
+// certificateKeyChoices are the key algorithms of the Mumble certificate
+// that can be chosen in the settings, in the same order they are shown
+var certificateKeyChoices = []string{
+	client.CertificateKeyRSA2048,
+	client.CertificateKeyRSA4096,
+}
+
+func certificateKeyName(id string) string {
+	switch id {
+	case client.CertificateKeyRSA4096:
+		return i18n.Sprintf("RSA 4096 bits")
+	}
+	return i18n.Sprintf("RSA 2048 bits (default)")
 }
 
 func (u *gtkUI) switchContextWhenMumbleFinish() {
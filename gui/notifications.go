@@ -0,0 +1,130 @@
+package gui
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	log "github.com/sirupsen/logrus"
+)
+
+// The events of the meetings that the user is told about. The
+// names are saved in the configuration when they are turned off
+const (
+	notificationParticipantJoined  = "participant-joined"
+	notificationParticipantLeft    = "participant-left"
+	notificationConnectionDegraded = "connection-degraded"
+	notificationCertificateChanged = "certificate-changed"
+)
+
+const (
+	// notificationBannerTime is how long a banner is shown
+	notificationBannerTime = 6 * time.Second
+
+	// notificationBannerMarginTop is the distance from
+	// the top of the window to the banner
+	notificationBannerMarginTop = 48
+)
+
+// notificationBanner is shown over the window of the
+// application, and hides itself after a while
+type notificationBanner struct {
+	win        gtki.Window
+	lblSummary gtki.Label
+	lblBody    gtki.Label
+
+	// shown tells the banners apart, so an old
+	// one doesn't hide the one shown after it
+	shown int
+}
+
+// notifyEvent tells the user about something that happened in a
+// meeting, with a banner in the application and a desktop
+// notification, unless the user has turned them off for the event
+func (u *gtkUI) notifyEvent(event, summary, body string) {
+	if u.config != nil && !u.config.IsNotificationEnabled(event) {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"context": "notifications",
+		"event":   event,
+	}).Debug("Notifying an event")
+
+	u.doInUIThread(func() {
+		u.showNotificationBanner(summary, body)
+	})
+	go notify(summary, body)
+}
+
+// showNotificationBanner must be called from the UI thread
+func (u *gtkUI) showNotificationBanner(summary, body string) {
+	if u.notificationBanner == nil {
+		u.notificationBanner = u.createNotificationBanner()
+	}
+
+	b := u.notificationBanner
+	b.shown++
+	shown := b.shown
+
+	b.lblSummary.SetText(summary)
+	b.lblBody.SetText(body)
+	b.lblBody.SetVisible(body != "")
+
+	if u.currentWindow != nil {
+		b.win.SetTransientFor(u.currentWindow)
+		x, y := u.currentWindow.GetPosition()
+		width, _ := u.currentWindow.GetSize()
+		bannerWidth, _ := b.win.GetSize()
+		b.win.Move(x+(width-bannerWidth)/2, y+notificationBannerMarginTop)
+	}
+
+	b.win.Show()
+
+	time.AfterFunc(notificationBannerTime, func() {
+		u.doInUIThread(func() {
+			if b.shown == shown {
+				b.win.Hide()
+			}
+		})
+	})
+}
+
+func (u *gtkUI) createNotificationBanner() *notificationBanner {
+	builder := u.g.uiBuilderFor("NotificationBanner")
+	builder.i18nProperties(
+		"title", "notificationBanner",
+		"button", "btnDismiss")
+
+	b := &notificationBanner{}
+	builder.getItems(
+		"notificationBanner", &b.win,
+		"lblSummary", &b.lblSummary,
+		"lblBody", &b.lblBody,
+	)
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_dismiss": b.win.Hide,
+	})
+
+	return b
+}
+
+// notify shows a desktop notification, for things that happen while
+// the user might be doing something else. Not every desktop can show
+// them, so a notification that can't be shown is only logged
+func notify(summary, body string) {
+	notifySend, err := exec.LookPath("notify-send")
+	if err != nil {
+		log.Debug("notify(): notify-send is not available")
+		return
+	}
+
+	/* #nosec G204 */
+	err = exec.Command(notifySend, "--app-name="+programName, summary, body).Run()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "notifications",
+		}).Warningf("The notification can't be shown: %s", err)
+	}
+}
@@ -0,0 +1,75 @@
+package gui
+
+import (
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/coyim/gotk3adapter/gtki"
+)
+
+// panicShortcut is available in every Wahay window, but only while
+// one of them has the focus. A global shortcut is set up in the
+// desktop, running Wahay with --panic
+const panicShortcut = "<Primary><Shift>Delete"
+
+var panicOnce sync.Once
+
+func (u *gtkUI) panicButton() {
+	panicOnce.Do(u.realPanic)
+}
+
+// realPanic tears down everything started by Wahay without asking
+// any question to the user: the Mumble client, the hosted meeting
+// and Tor. After that, the session data is removed, the keys of the
+// encrypted configuration are forgotten and the application exits.
+func (u *gtkUI) realPanic() {
+	log.Warn("Panic requested: terminating the current Wahay session")
+
+	if u.currentMumble != nil {
+		u.currentMumble.Close()
+		u.currentMumble = nil
+	}
+
+	if u.currentHost != nil {
+		u.currentHost.closeInvitationLink()
+	}
+
+	if u.currentHost != nil && u.currentHost.service != nil {
+		err := u.currentHost.service.Close()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "panic",
+			}).Errorf("the hosted meeting can't be closed: %s", err)
+		}
+		u.currentHost = nil
+	}
+
+	if u.servers != nil {
+		u.servers.Cleanup()
+		u.servers = nil
+	}
+
+	u.cleanupHandler.doCleanup(func() {
+		u.wipeSessionState()
+		os.Exit(0)
+	})
+}
+
+func (u *gtkUI) wipeSessionState() {
+	if u.isCopyToClipboardSupported() {
+		// The meeting ID or the invitation could be in the clipboard
+		_ = u.copyToClipboard("")
+	}
+
+	if u.keySupplier != nil {
+		u.keySupplier.Invalidate()
+	}
+}
+
+func (u *gtkUI) connectPanicShortcut(w gtki.Window) {
+	u.connectShortcut(panicShortcut, w, func(gtki.Window) {
+		u.panicButton()
+	})
+}
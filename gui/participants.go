@@ -0,0 +1,411 @@
+package gui
+
+import (
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	participantsColumnSession = iota
+	participantsColumnName
+	participantsColumnStatus
+)
+
+// participantBanDuration is how long a banned participant
+// can't join the meeting again
+const participantBanDuration = time.Hour
+
+type participantsWindow struct {
+	u            *gtkUI
+	participants hosting.Participants
+	list         map[uint32]hosting.Participant
+	stop         func()
+
+	// rooms is nil when the meeting can't be split,
+	// and roomIDs are the choices of cmbRooms
+	rooms     hosting.BreakoutRooms
+	roomIDs   []uint32
+	stopRooms func()
+
+	win         gtki.Window
+	store       gtki.TreeStore
+	tree        gtki.TreeView
+	lblStatus   gtki.Label
+	btnMute     gtki.Button
+	btnKick     gtki.Button
+	boxRooms    gtki.Box
+	inpRoomName gtki.Entry
+	storeRooms  gtki.ListStore
+	cmbRooms    gtki.ComboBox
+}
+
+// showHostParticipants opens the live list of the
+// participants of the meeting we are hosting
+func (h *hostData) showHostParticipants() {
+	p := h.service.Participants()
+	if p == nil {
+		return
+	}
+
+	builder := h.u.g.uiBuilderFor("ParticipantsWindow")
+	builder.i18nProperties(
+		"title", "participantsWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"title", "colName",
+		"title", "colStatus",
+		"button", "btnMute",
+		"button", "btnDeafen",
+		"button", "btnKick",
+		"button", "btnBan",
+		"button", "btnCoModerator",
+		"tooltip", "btnCoModerator",
+		"placeholder", "inpRoomName",
+		"button", "btnCreateRoom",
+		"button", "btnMoveToRoom",
+		"button", "btnRemoveRoom",
+		"button", "btnClose")
+
+	w := &participantsWindow{
+		u:            h.u,
+		participants: p,
+		list:         make(map[uint32]hosting.Participant),
+		rooms:        h.service.BreakoutRooms(),
+	}
+
+	builder.getItems(
+		"participantsWindow", &w.win,
+		"storeParticipants", &w.store,
+		"treeParticipants", &w.tree,
+		"lblStatus", &w.lblStatus,
+		"btnMute", &w.btnMute,
+		"btnKick", &w.btnKick,
+		"boxRooms", &w.boxRooms,
+		"inpRoomName", &w.inpRoomName,
+		"storeRooms", &w.storeRooms,
+		"cmbRooms", &w.cmbRooms,
+	)
+
+	w.boxRooms.SetVisible(w.rooms != nil)
+
+	if h.u.currentWindow != nil {
+		w.win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_mute":         w.toggleMute,
+		"on_deafen":       w.toggleDeafen,
+		"on_kick":         w.kick,
+		"on_ban":          w.ban,
+		"on_co_moderator": w.toggleCoModerator,
+		"on_create_room":  w.createRoom,
+		"on_move_to_room": w.moveToRoom,
+		"on_remove_room":  w.removeRoom,
+		"on_close":        w.win.Destroy,
+		"on_destroy": func() {
+			if w.stop != nil {
+				w.stop()
+			}
+			if w.stopRooms != nil {
+				w.stopRooms()
+			}
+		},
+	})
+
+	// The events only tell that something changed, and the whole
+	// list is shown again, since they can arrive in any order
+	w.stop = p.WhenChanged(func(hosting.ParticipantEvent, hosting.Participant) {
+		w.u.doInUIThread(w.refresh)
+	})
+	if w.rooms != nil {
+		w.stopRooms = w.rooms.WhenRoomsChanged(func() {
+			w.u.doInUIThread(w.refresh)
+		})
+	}
+
+	w.win.Present()
+	w.win.Show()
+
+	w.refresh()
+}
+
+// refresh shows the participants inside of the rows of the rooms they
+// are in. The rows of the rooms have no session, so they can't be
+// taken for a participant
+func (w *participantsWindow) refresh() {
+	selected, hasSelection := w.selectedParticipant()
+
+	w.store.Clear()
+	w.list = make(map[uint32]hosting.Participant)
+
+	var rooms []hosting.BreakoutRoom
+	if w.rooms != nil {
+		rooms = w.rooms.Rooms()
+	}
+
+	main := w.store.Append(nil)
+	_ = w.store.SetValue(main, participantsColumnName, i18n.Sprintf("Main meeting"))
+
+	rows := map[uint32]gtki.TreeIter{hosting.MainRoom: main}
+	for _, r := range rooms {
+		iter := w.store.Append(nil)
+		_ = w.store.SetValue(iter, participantsColumnName, r.Name)
+		rows[r.ID] = iter
+	}
+
+	participants := w.participants.List()
+	for _, p := range participants {
+		w.list[p.Session] = p
+
+		parent, ok := rows[p.Room]
+		if !ok {
+			parent = main
+		}
+
+		iter := w.store.Append(parent)
+		_ = w.store.SetValue(iter, participantsColumnSession, p.Session)
+		_ = w.store.SetValue(iter, participantsColumnName, p.Name)
+		_ = w.store.SetValue(iter, participantsColumnStatus, participantStatus(p))
+
+		if hasSelection && p.Session == selected.Session {
+			if sel, err := w.tree.GetSelection(); err == nil {
+				sel.SelectIter(iter)
+			}
+		}
+	}
+
+	w.tree.ExpandAll()
+	w.refreshRooms(rooms)
+
+	if len(participants) == 0 {
+		w.lblStatus.SetText(i18n.Sprintf("Nobody has joined the meeting yet"))
+	} else {
+		w.lblStatus.SetText("")
+	}
+}
+
+func participantStatus(p hosting.Participant) string {
+	switch {
+	case p.Waiting:
+		return i18n.Sprintf("Waiting to be admitted")
+	case p.Talking:
+		return i18n.Sprintf("Talking")
+	case p.CoModerator:
+		return i18n.Sprintf("Co-moderator")
+	case p.DeafenedByHost:
+		return i18n.Sprintf("Deafened by the host")
+	case p.MutedByHost:
+		return i18n.Sprintf("Muted by the host")
+	case p.Deafened:
+		return i18n.Sprintf("Deafened")
+	case p.Muted:
+		return i18n.Sprintf("Muted")
+	}
+	return ""
+}
+
+func (w *participantsWindow) selectedParticipant() (hosting.Participant, bool) {
+	sel, err := w.tree.GetSelection()
+	if err != nil {
+		return hosting.Participant{}, false
+	}
+
+	model, iter, ok := sel.GetSelected()
+	if !ok {
+		return hosting.Participant{}, false
+	}
+
+	v, err := model.GetValue(iter, participantsColumnSession)
+	if err != nil {
+		return hosting.Participant{}, false
+	}
+
+	session, _ := v.GoValue()
+	s, _ := session.(uint)
+	p, ok := w.list[uint32(s)]
+
+	return p, ok
+}
+
+func (w *participantsWindow) toggleMute() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to mute"))
+		return
+	}
+
+	err := w.participants.Mute(p.Session, !p.MutedByHost)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The participant can't be muted: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The participant can't be muted: %s", err))
+	}
+}
+
+func (w *participantsWindow) toggleDeafen() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to deafen"))
+		return
+	}
+
+	err := w.participants.Deafen(p.Session, !p.DeafenedByHost)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The participant can't be deafened: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The participant can't be deafened: %s", err))
+	}
+}
+
+func (w *participantsWindow) kick() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to remove"))
+		return
+	}
+
+	w.u.showConfirmation(func(ok bool) {
+		if !ok {
+			return
+		}
+
+		err := w.participants.Kick(p.Session)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "participants",
+			}).Warningf("The participant can't be removed: %s", err)
+			w.lblStatus.SetText(i18n.Sprintf("The participant can't be removed: %s", err))
+		}
+	}, i18n.Sprintf("%s will be removed from the meeting.", p.Name))
+}
+
+func (w *participantsWindow) ban() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to ban"))
+		return
+	}
+
+	w.u.showConfirmation(func(ok bool) {
+		if !ok {
+			return
+		}
+
+		err := w.participants.Ban(p.Session, participantBanDuration)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "participants",
+			}).Warningf("The participant can't be banned: %s", err)
+			w.lblStatus.SetText(i18n.Sprintf("The participant can't be banned: %s", err))
+		}
+	}, i18n.Sprintf("%s will be removed from the meeting, and won't be able to join it again for an hour.", p.Name))
+}
+
+// toggleCoModerator gives the selected participant the privileges of
+// a moderator, so the meeting is moderated while the host is away
+func (w *participantsWindow) toggleCoModerator() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to make a co-moderator"))
+		return
+	}
+
+	err := w.participants.SetCoModerator(p.Session, !p.CoModerator)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The privileges of the participant can't be changed: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The privileges of the participant can't be changed: %s", err))
+	}
+}
+
+// refreshRooms lists the rooms where the participants can be moved,
+// keeping the one that was chosen while it exists
+func (w *participantsWindow) refreshRooms(rooms []hosting.BreakoutRoom) {
+	chosen, hasChoice := w.chosenRoom()
+
+	w.storeRooms.Clear()
+	w.roomIDs = []uint32{hosting.MainRoom}
+
+	iter := w.storeRooms.Append()
+	_ = w.storeRooms.Set2(iter, []int{0, 1}, []interface{}{hosting.MainRoom, i18n.Sprintf("Main meeting")})
+
+	active := 0
+	for _, r := range rooms {
+		if hasChoice && r.ID == chosen {
+			active = len(w.roomIDs)
+		}
+
+		w.roomIDs = append(w.roomIDs, r.ID)
+		iter := w.storeRooms.Append()
+		_ = w.storeRooms.Set2(iter, []int{0, 1}, []interface{}{r.ID, r.Name})
+	}
+
+	w.cmbRooms.SetActive(active)
+}
+
+func (w *participantsWindow) chosenRoom() (uint32, bool) {
+	i := w.cmbRooms.GetActive()
+	if i < 0 || i >= len(w.roomIDs) {
+		return hosting.MainRoom, false
+	}
+	return w.roomIDs[i], true
+}
+
+func (w *participantsWindow) createRoom() {
+	name := getTrimmedText(w.inpRoomName)
+	if name == "" {
+		w.lblStatus.SetText(i18n.Sprintf("Write the name of the breakout room"))
+		return
+	}
+
+	err := w.rooms.CreateRoom(name)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The breakout room can't be created: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The breakout room can't be created: %s", err))
+		return
+	}
+
+	w.inpRoomName.SetText("")
+}
+
+func (w *participantsWindow) moveToRoom() {
+	p, ok := w.selectedParticipant()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the participant you want to move"))
+		return
+	}
+
+	room, _ := w.chosenRoom()
+	err := w.rooms.MoveToRoom(p.Session, room)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The participant can't be moved: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The participant can't be moved: %s", err))
+	}
+}
+
+func (w *participantsWindow) removeRoom() {
+	room, ok := w.chosenRoom()
+	if !ok || room == hosting.MainRoom {
+		w.lblStatus.SetText(i18n.Sprintf("Choose the breakout room you want to remove"))
+		return
+	}
+
+	err := w.rooms.RemoveRoom(room)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "participants",
+		}).Warningf("The breakout room can't be removed: %s", err)
+		w.lblStatus.SetText(i18n.Sprintf("The breakout room can't be removed: %s", err))
+	}
+}
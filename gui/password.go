@@ -61,6 +61,8 @@ func (u *gtkUI) getMasterPasswordBuilder() *uiBuilder {
 		"placeholder", "entryPassword",
 		"tooltip", "btnTogglePassword",
 		"label", "lblMasterPasswordText",
+		"checkbox", "chkRememberPassword",
+		"tooltip", "chkRememberPassword",
 		"button", "btnMasterPasswordCancel",
 		"button", "btnMasterPasswordContinue")
 
@@ -70,6 +72,15 @@ func (u *gtkUI) getMasterPasswordBuilder() *uiBuilder {
 // This function should be only called on startup, never call this function
 // or should not be called during the execution of this app
 func (u *gtkUI) getMasterPassword(p config.EncryptionParameters, lastAttemptFailed bool) config.EncryptionResult {
+	// The password in the keyring is always tried first, so when
+	// an attempt has failed it's not the right one anymore
+	if password, ok := u.keyringMasterPassword(); ok {
+		if !lastAttemptFailed {
+			return config.GenerateKeysBasedOnPassword(password, p)
+		}
+		u.forgetMasterPasswordInKeyring()
+	}
+
 	u.hideLoadingWindow()
 
 	passwordResultCh := make(chan string)
@@ -79,6 +90,8 @@ func (u *gtkUI) getMasterPassword(p config.EncryptionParameters, lastAttemptFail
 	win := builder.get("masterPasswordWindow").(gtki.Window)
 	txtPassword := builder.get("entryPassword").(gtki.Entry)
 	btnTogglePassword := builder.get("btnTogglePassword").(gtki.CheckButton)
+	chkRememberPassword := builder.get("chkRememberPassword").(gtki.CheckButton)
+	chkRememberPassword.SetVisible(u.isKeyringAvailable())
 
 	win.SetApplication(u.app)
 
@@ -109,6 +122,12 @@ func (u *gtkUI) getMasterPassword(p config.EncryptionParameters, lastAttemptFail
 					hadSubmission = false
 				} else {
 					txtPassword.SetSensitive(false)
+					// Only the password of this submission can be
+					// remembered, never the one of an earlier attempt
+					u.keyringPassword = ""
+					if chkRememberPassword.GetActive() {
+						u.keyringPassword = text
+					}
 					passwordResultCh <- text
 					close(passwordResultCh)
 				}
@@ -172,6 +191,8 @@ func (u *gtkUI) captureMasterPassword(onSuccess func(), onCancel func()) {
 				lblValidation.SetText(err.Error())
 				lblValidation.SetVisible(true)
 			} else {
+				// The password in the keyring can't open the new file
+				u.forgetMasterPasswordInKeyring()
 				u.keySupplier = &onetimeSavedPassword{
 					savedPassword:  password,
 					realKeySuplier: u.keySupplier,
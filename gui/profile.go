@@ -0,0 +1,146 @@
+package gui
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const profileFileDefaultName = "wahay" + config.ProfileFileExtension
+
+func (s *settings) exportProfile() {
+	s.u.askPassphrase(i18n.Sprintf("Export profile"),
+		i18n.Sprintf("Enter a password to protect the profile. You will need it to import the profile in another device."),
+		func(password string, ok bool) {
+			if !ok {
+				return
+			}
+
+			fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Export profile"), profileFileDefaultName)
+			if !ok {
+				return
+			}
+
+			s.lblProfileMessage.SetText(i18n.Sprintf("Exporting the profile..."))
+
+			go func() {
+				content, err := s.u.config.ExportProfile(password)
+				if err == nil {
+					err = ioutil.WriteFile(filepath.Clean(fileName), content, 0600)
+				}
+
+				s.u.doInUIThread(func() {
+					if err != nil {
+						s.lblProfileMessage.SetText("")
+						log.WithFields(errorFields(err)).Errorf("the profile can't be exported: %s", err)
+						s.u.reportError(i18n.Sprintf("The profile can't be exported: %s", profileErrorTranslator(err)))
+						return
+					}
+
+					go s.u.messageToLabel(s.lblProfileMessage, i18n.Sprintf("The profile has been exported"), 5)
+				})
+			}()
+		})
+}
+
+// importProfile closes the settings once the profile has been
+// imported, since they show the settings that have been replaced
+func (s *settings) importProfile() {
+	s.u.importProfile(func() {
+		s.lblProfileMessage.SetText(i18n.Sprintf("Importing the profile..."))
+	}, func(ok bool) {
+		s.lblProfileMessage.SetText("")
+		if ok {
+			s.close()
+		}
+	})
+}
+
+// importProfile asks for a profile and the password used to export it,
+// and uses the settings in it. The started function is called once the
+// file is being imported, and done when it has been imported or failed
+func (u *gtkUI) importProfile(started func(), done func(bool)) {
+	fileName, ok := u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Import profile"), "")
+	if !ok {
+		done(false)
+		return
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		u.reportError(i18n.Sprintf("The profile can't be imported: %s", err))
+		done(false)
+		return
+	}
+
+	u.askPassphrase(i18n.Sprintf("Import profile"),
+		i18n.Sprintf("Enter the password used when the profile was exported"),
+		func(password string, ok bool) {
+			if !ok {
+				done(false)
+				return
+			}
+
+			started()
+
+			go func() {
+				err := u.config.ImportProfile(content, password)
+
+				u.doInUIThread(func() {
+					if err != nil {
+						log.WithFields(errorFields(err)).Errorf("the profile can't be imported: %s", err)
+						u.reportError(i18n.Sprintf("The profile can't be imported: %s", profileErrorTranslator(err)))
+						done(false)
+						return
+					}
+
+					done(true)
+					u.profileImported()
+				})
+			}()
+		})
+}
+
+// profileImported saves the imported settings and uses the ones that
+// change how the windows look right away. The Tor bridges of the profile
+// are used the next time Tor is started. This must be called from the
+// UI thread
+func (u *gtkUI) profileImported() {
+	u.saveConfigOnly()
+
+	u.applyLanguage()
+	u.retranslateMainWindow()
+	u.applyFontScale()
+	u.applyTheme()
+
+	body := ""
+	if !u.config.IsPersistentConfiguration() {
+		body = i18n.Sprintf("Turn on the persistent configuration in the settings to keep it after closing Wahay.")
+	}
+	u.showNotificationBanner(i18n.Sprintf("The profile has been imported"), body)
+}
+
+// offerProfileImport lets the user start with a profile exported in
+// another device, when Wahay is used for the first time in this one
+func (u *gtkUI) offerProfileImport() {
+	u.showConfirmation(func(ok bool) {
+		if ok {
+			u.importProfile(func() {}, func(bool) {})
+		}
+	}, i18n.Sprintf("Do you want to import a profile that you have exported from Wahay in another device?"))
+}
+
+func profileErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case config.ErrProfilePasswordRequired:
+		return i18n.Sprintf("a password is required to protect the profile")
+	case config.ErrInvalidProfileFile:
+		return i18n.Sprintf("the profile file is not valid or the password is wrong")
+	}
+	return err.Error()
+}
@@ -0,0 +1,38 @@
+package gui
+
+import (
+	"github.com/digitalautonomy/wahay/hosting"
+)
+
+// The style of the label is chosen by its name, since it's
+// shown again with another style every time the reachability changes
+var reachabilityStyles = map[hosting.Reachability]string{
+	hosting.Reachable:            "reachability-good",
+	hosting.ReachabilityDegraded: "reachability-degraded",
+	hosting.Unreachable:          "reachability-bad",
+}
+
+func reachabilityText(r hosting.Reachability) string {
+	switch r {
+	case hosting.Reachable:
+		return i18n.Sprintf("● The participants can reach the meeting")
+	case hosting.ReachabilityDegraded:
+		return i18n.Sprintf("● The meeting couldn't be reached, it's being published again")
+	case hosting.Unreachable:
+		return i18n.Sprintf("● The meeting can't be reached, it's being restarted in the Tor network")
+	}
+	return ""
+}
+
+// showReachability shows whether the participants can reach the meeting
+// in the window being shown. It must be called from the UI thread
+func (h *hostData) showReachability() {
+	if h.lblReachability == nil {
+		return
+	}
+
+	text := reachabilityText(h.reachability)
+	h.lblReachability.SetText(text)
+	h.lblReachability.SetName(reachabilityStyles[h.reachability])
+	h.lblReachability.SetVisible(text != "")
+}
@@ -0,0 +1,368 @@
+package gui
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// reconnectInitialDelay and reconnectMaxDelay are the first and the
+	// longest wait between the attempts of getting back to the meeting.
+	// The wait doubles after every attempt that fails
+	reconnectInitialDelay = 5 * time.Second
+	reconnectMaxDelay     = 2 * time.Minute
+
+	// maxMumbleRelaunches is how many times Mumble can be started again
+	// after crashing, before going back to the main window
+	maxMumbleRelaunches = 5
+
+	// reconnectStableTime is how long Mumble has to run after being
+	// started again for its next stop to count as a new problem
+	reconnectStableTime = 5 * time.Minute
+
+	// reconnectedStatusTime is how long the reconnection is told,
+	// after it works
+	reconnectedStatusTime = 10 * time.Second
+)
+
+// reconnectBackoff gives the waits between the attempts of reconnecting
+type reconnectBackoff struct {
+	delay time.Duration
+}
+
+func (b *reconnectBackoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = reconnectInitialDelay
+		return b.delay
+	}
+
+	b.delay *= 2
+	if b.delay > reconnectMaxDelay {
+		b.delay = reconnectMaxDelay
+	}
+	return b.delay
+}
+
+func (b *reconnectBackoff) reset() {
+	b.delay = 0
+}
+
+// meetingSupervisor keeps the user in the joined meeting. When Mumble
+// crashes the user can start it again with the same meeting data, and
+// when the meeting can't be reached anymore new Tor circuits are built.
+// It's the Mumble service of the current meeting for the rest of the UI
+type meetingSupervisor struct {
+	sync.Mutex
+
+	u    *gtkUI
+	data hosting.MeetingData
+
+	mumble     tor.Service
+	launched   time.Time
+	relaunches int
+	relaunch   reconnectBackoff
+	closed     bool
+	stop       chan bool
+	finishOnce sync.Once
+	onClose    []func()
+
+	lblStatus gtki.Label
+
+	// stats is how the connection to the meeting works
+	stats *meetingStats
+
+	// statusShown counts the statuses shown, so the one telling that the
+	// reconnection worked is only hidden when nothing else was shown after it
+	statusShown int
+}
+
+func (u *gtkUI) newMeetingSupervisor(data hosting.MeetingData) *meetingSupervisor {
+	return &meetingSupervisor{
+		u:    u,
+		data: data,
+		stop: make(chan bool),
+	}
+}
+
+// launch starts Mumble for the meeting
+func (s *meetingSupervisor) launch() error {
+	m, err := s.u.launchMumbleClient(s.data, s.mumbleClosed)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.mumble = m
+	s.launched = time.Now()
+	closed := s.closed
+	s.Unlock()
+
+	if closed {
+		m.Close()
+		return nil
+	}
+
+	s.u.currentMumble = s
+	return nil
+}
+
+// Close leaves the meeting, without starting Mumble again
+func (s *meetingSupervisor) Close() {
+	s.Lock()
+	m := s.mumble
+	wasClosed := s.closed
+	s.closed = true
+	s.Unlock()
+
+	if wasClosed {
+		return
+	}
+
+	close(s.stop)
+
+	if m != nil && !m.IsClosed() {
+		m.Close()
+		return
+	}
+
+	// Mumble is not running while it waits for being started again
+	s.finish()
+}
+
+// IsClosed returns true when the user has left the meeting
+func (s *meetingSupervisor) IsClosed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed
+}
+
+// OnClose adds a function to call when the user leaves the meeting
+func (s *meetingSupervisor) OnClose(f func()) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.onClose = append(s.onClose, f)
+}
+
+// Err returns nil, since the crashes of Mumble are handled by the supervisor
+func (s *meetingSupervisor) Err() error {
+	return nil
+}
+
+// Output returns what the current Mumble has written to its error output
+func (s *meetingSupervisor) Output() string {
+	s.Lock()
+	m := s.mumble
+	s.Unlock()
+
+	if m == nil {
+		return ""
+	}
+	return m.Output()
+}
+
+func (s *meetingSupervisor) mumbleClosed() {
+	s.Lock()
+	m := s.mumble
+	if time.Since(s.launched) > reconnectStableTime {
+		s.relaunches = 0
+		s.relaunch.reset()
+	}
+	stopped := !s.closed && m != nil && m.Err() != nil
+	canRelaunch := s.relaunches < maxMumbleRelaunches
+	s.Unlock()
+
+	if !stopped {
+		s.finish()
+		return
+	}
+
+	if !canRelaunch {
+		s.u.doInUIThread(func() {
+			s.u.reportError(i18n.Sprintf("Mumble stopped unexpectedly too many times, so it has not been started again"))
+		})
+		s.finish()
+		return
+	}
+
+	// Launching Mumble forgets the current meeting, so it's kept
+	// while the user decides whether to start Mumble again
+	s.u.currentMumble = s
+	s.offerRestart()
+}
+
+// offerRestart asks the user whether to start Mumble again after
+// it crashed, going back to the main window otherwise
+func (s *meetingSupervisor) offerRestart() {
+	s.showStatus(i18n.Sprintf("Mumble stopped unexpectedly"))
+
+	s.u.doInUIThread(func() {
+		s.u.showConfirmation(func(restart bool) {
+			if !restart || s.IsClosed() {
+				s.finish()
+				return
+			}
+			go s.relaunchMumble()
+		}, i18n.Sprintf("Mumble stopped unexpectedly. Do you want to restart it and join the meeting again?"))
+	})
+}
+
+// relaunchMumble starts Mumble again after a wait, building new
+// circuits to the meeting first, since a broken connection
+// is one of the things that can make Mumble stop
+func (s *meetingSupervisor) relaunchMumble() {
+	for {
+		s.Lock()
+		s.relaunches++
+		attempt := s.relaunches
+		delay := s.relaunch.next()
+		s.Unlock()
+
+		s.showStatus(i18n.Sprintf("Mumble stopped unexpectedly. Connecting to the meeting again in %s...", delay))
+		if !s.wait(delay) {
+			return
+		}
+
+		s.showStatus(i18n.Sprintf("Connecting to the meeting again..."))
+
+		if isOnionMeeting(s.data) {
+			s.renewCircuits()
+		}
+
+		err := s.launch()
+		if err == nil {
+			s.showReconnected()
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"context": "reconnection",
+			"attempt": attempt,
+		}).Errorf("Mumble can't be started again: %s", err)
+
+		if attempt >= maxMumbleRelaunches {
+			s.u.doInUIThread(func() {
+				s.u.reportError(i18n.Sprintf("The meeting can't be joined again: %s", describeError(err)))
+			})
+			s.finish()
+			return
+		}
+	}
+}
+
+// recoverConnection builds new circuits to the meeting until it answers
+// again, waiting longer after every attempt. Mumble reconnects by itself
+// once the meeting can be reached. It returns when the user leaves
+func (s *meetingSupervisor) recoverConnection(address string) {
+	b := reconnectBackoff{}
+
+	log.WithFields(log.Fields{
+		"context": "reconnection",
+	}).Warning("The meeting can't be reached, building new Tor circuits to it")
+
+	for attempt := 1; !s.IsClosed(); attempt++ {
+		s.showStatus(i18n.Sprintf("The connection to the meeting was lost. Reconnecting..."))
+		s.renewCircuits()
+
+		_, err := client.MeasureRTT(s.u.torInstance(), address, 1)
+		if err == nil {
+			log.WithFields(log.Fields{
+				"context": "reconnection",
+				"attempt": attempt,
+			}).Info("The meeting can be reached again")
+			s.showReconnected()
+			return
+		}
+
+		delay := b.next()
+		s.showStatus(i18n.Sprintf("The connection to the meeting was lost. Trying again in %s (attempt %d)...", delay, attempt))
+		if !s.wait(delay) {
+			return
+		}
+	}
+}
+
+func (s *meetingSupervisor) renewCircuits() {
+	err := s.u.torInstance().RenewCircuits(s.data.MeetingID)
+	if err != nil {
+		log.WithFields(errorFields(err)).Errorf("the Tor circuits can't be renewed: %s", err)
+	}
+}
+
+// wait returns false when the user leaves the meeting before the time passes
+func (s *meetingSupervisor) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return !s.IsClosed()
+	case <-s.stop:
+		return false
+	}
+}
+
+// finish takes the user back to the main window, once per meeting
+func (s *meetingSupervisor) finish() {
+	s.finishOnce.Do(func() {
+		s.Lock()
+		s.closed = true
+		callbacks := s.onClose
+		s.onClose = nil
+		s.Unlock()
+
+		if s.u.currentMumble == s {
+			s.u.currentMumble = nil
+		}
+
+		for _, f := range callbacks {
+			f()
+		}
+
+		s.u.switchContextWhenMumbleFinish()
+	})
+}
+
+func (s *meetingSupervisor) showStatus(text string) {
+	if s.lblStatus == nil {
+		return
+	}
+
+	s.u.doInUIThread(func() {
+		s.statusShown++
+		s.lblStatus.SetText(text)
+		s.lblStatus.SetVisible(true)
+	})
+}
+
+func (s *meetingSupervisor) showReconnected() {
+	if s.lblStatus == nil {
+		return
+	}
+
+	s.u.doInUIThread(func() {
+		s.statusShown++
+		shown := s.statusShown
+		s.lblStatus.SetText(i18n.Sprintf("Connected to the meeting again"))
+		s.lblStatus.SetVisible(true)
+
+		time.AfterFunc(reconnectedStatusTime, func() {
+			s.u.doInUIThread(func() {
+				if s.statusShown == shown {
+					s.lblStatus.SetVisible(false)
+				}
+			})
+		})
+	})
+}
+
+func meetingAddress(data hosting.MeetingData) string {
+	return net.JoinHostPort(data.MeetingID, strconv.Itoa(data.Port))
+}
@@ -0,0 +1,177 @@
+package gui
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const decryptedRecordingExtension = ".opus"
+
+func recordingFileDefaultName() string {
+	return "wahay-" + time.Now().Format("2006-01-02-1504") + hosting.RecordingFileExtension
+}
+
+// showRecordingControls shows the button to record the meeting, and
+// whether it's being recorded, in the window being shown
+func (h *hostData) showRecordingControls(builder *uiBuilder) {
+	h.btnRecord = builder.get("btnRecord").(gtki.Button)
+	h.lblRecording = builder.get("lblRecording").(gtki.Label)
+
+	h.btnRecord.SetVisible(h.service.Recording() != nil)
+	h.showRecording()
+}
+
+// showRecording must be called from the UI thread
+func (h *hostData) showRecording() {
+	if h.btnRecord == nil {
+		return
+	}
+
+	r := h.service.Recording()
+	recording := r != nil && r.IsRecording()
+
+	label := i18n.Sprintf("Record the meeting")
+	if recording {
+		label = i18n.Sprintf("Stop recording")
+	}
+	_ = h.btnRecord.SetProperty("label", label)
+	h.lblRecording.SetVisible(recording)
+}
+
+func (h *hostData) onRecord() {
+	r := h.service.Recording()
+	if r == nil {
+		return
+	}
+
+	if r.IsRecording() {
+		h.stopRecording(r)
+		return
+	}
+
+	h.u.showConfirmation(func(ok bool) {
+		if ok {
+			h.startRecording(r)
+		}
+	}, i18n.Sprintf("Everybody in the meeting will be told that it's being recorded before the recording starts, "+
+		"and the participants that join later will be told when they arrive. Mumble also shows it next to Wahay. "+
+		"Only record the meeting if the participants agree to it."))
+}
+
+func (h *hostData) startRecording(r hosting.Recording) {
+	h.u.askPassphrase(i18n.Sprintf("Record the meeting"),
+		i18n.Sprintf("Enter a password to encrypt the recording. You will need it to listen to the recording."),
+		func(password string, ok bool) {
+			if !ok {
+				return
+			}
+
+			fileName, ok := h.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Record the meeting"), recordingFileDefaultName())
+			if !ok {
+				return
+			}
+
+			announcement := i18n.Sprintf("The host is recording this meeting. " +
+				"If you don't agree, tell the host or leave the meeting.")
+
+			go func() {
+				err := r.Start(fileName, password, announcement)
+
+				h.u.doInUIThread(func() {
+					if err != nil {
+						log.WithFields(errorFields(err)).Errorf("the meeting can't be recorded: %s", err)
+						h.u.reportError(i18n.Sprintf("The meeting can't be recorded: %s", recordingErrorTranslator(err)))
+					}
+					h.showRecording()
+				})
+			}()
+		})
+}
+
+func (h *hostData) stopRecording(r hosting.Recording) {
+	go func() {
+		err := r.Stop()
+
+		h.u.doInUIThread(func() {
+			h.showRecording()
+
+			if err != nil {
+				log.WithFields(errorFields(err)).Errorf("the recording can't be saved: %s", err)
+				h.u.reportError(i18n.Sprintf("The recording can't be saved: %s", recordingErrorTranslator(err)))
+				return
+			}
+
+			h.u.showNotificationBanner(i18n.Sprintf("The recording has been saved"),
+				i18n.Sprintf("Decrypt it in the settings to listen to it."))
+		})
+	}()
+}
+
+// decryptRecording saves a recording of a meeting as an Opus file,
+// next to the encrypted one unless the user chooses another place
+func (s *settings) decryptRecording() {
+	fileName, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_OPEN, i18n.Sprintf("Decrypt a recording"), "")
+	if !ok {
+		return
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		s.u.reportError(i18n.Sprintf("The recording can't be decrypted: %s", err))
+		return
+	}
+
+	s.u.askPassphrase(i18n.Sprintf("Decrypt a recording"),
+		i18n.Sprintf("Enter the password used when the meeting was recorded"),
+		func(password string, ok bool) {
+			if !ok {
+				return
+			}
+
+			defaultName := strings.TrimSuffix(filepath.Base(fileName), hosting.RecordingFileExtension) + decryptedRecordingExtension
+			target, ok := s.u.chooseFile(gtki.FILE_CHOOSER_ACTION_SAVE, i18n.Sprintf("Decrypt a recording"), defaultName)
+			if !ok {
+				return
+			}
+
+			s.lblRecordingMessage.SetText(i18n.Sprintf("Decrypting the recording..."))
+
+			go func() {
+				ogg, err := hosting.DecryptRecording(content, password)
+				if err == nil {
+					err = ioutil.WriteFile(filepath.Clean(target), ogg, 0600)
+				}
+
+				s.u.doInUIThread(func() {
+					if err != nil {
+						s.lblRecordingMessage.SetText("")
+						log.WithFields(errorFields(err)).Errorf("the recording can't be decrypted: %s", err)
+						s.u.reportError(i18n.Sprintf("The recording can't be decrypted: %s", recordingErrorTranslator(err)))
+						return
+					}
+
+					go s.u.messageToLabel(s.lblRecordingMessage, i18n.Sprintf("The recording has been decrypted"), 5)
+				})
+			}()
+		})
+}
+
+func recordingErrorTranslator(err error) string {
+	switch failure.Kind(err) {
+	case hosting.ErrRecordingPasswordRequired:
+		return i18n.Sprintf("a password is required to protect the recording")
+	case hosting.ErrAlreadyRecording:
+		return i18n.Sprintf("the meeting is already being recorded")
+	case hosting.ErrInvalidRecording:
+		return i18n.Sprintf("the recording is not valid or the password is wrong")
+	}
+	return err.Error()
+}
@@ -0,0 +1,477 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/scheduler"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A scheduled meeting has a room generated when it's scheduled, so its
+// invitation can be given in advance. Wahay hosts it in that room some
+// minutes before it begins, starting Tor if it was stopped, and then
+// forgets it - unless the host wants to keep its room for more meetings
+
+const (
+	scheduledColumnID = iota
+	scheduledColumnTitle
+	scheduledColumnStart
+)
+
+const scheduledStartFormat = calendarDateFormat + " " + calendarTimeFormat
+
+var errTooManyScheduledMeetings = errors.New("there are too many scheduled meetings")
+
+type scheduledMeetingsWindow struct {
+	u *gtkUI
+
+	win         gtki.Window
+	store       gtki.ListStore
+	tree        gtki.TreeView
+	entTitle    gtki.Entry
+	entDate     gtki.Entry
+	entTime     gtki.Entry
+	entPassword gtki.Entry
+	spnDuration gtki.SpinButton
+	spnLead     gtki.SpinButton
+	chkKeepRoom gtki.CheckButton
+	lblStatus   gtki.Label
+	btnSchedule gtki.Button
+}
+
+// showScheduledMeetings lets the host plan meetings, and
+// give their invitations before hosting them
+func (u *gtkUI) showScheduledMeetings() {
+	// The keys of the rooms of the meetings are only saved encrypted
+	if !u.config.CanSaveMeetingRooms() {
+		u.reportError(i18n.Sprintf("The meetings can only be scheduled when the configuration " +
+			"is saved encrypted with a master password, since their keys are kept in it."))
+		return
+	}
+
+	builder := u.g.uiBuilderFor("ScheduledMeetingsWindow")
+	builder.i18nProperties(
+		"title", "scheduledMeetingsWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"title", "colTitle",
+		"title", "colStart",
+		"button", "btnCopyInvitation",
+		"button", "btnSaveCalendar",
+		"button", "btnRemoveMeeting",
+		"label", "lblNewMeeting",
+		"label", "lblMeetingTitle",
+		"label", "lblDate",
+		"label", "lblTime",
+		"label", "lblDuration",
+		"label", "lblLead",
+		"label", "lblPassword",
+		"placeholder", "entTitle",
+		"placeholder", "entDate",
+		"placeholder", "entTime",
+		"placeholder", "entPassword",
+		"checkbox", "chkKeepRoom",
+		"button", "btnClose",
+		"button", "btnSchedule")
+
+	w := &scheduledMeetingsWindow{u: u}
+
+	builder.getItems(
+		"scheduledMeetingsWindow", &w.win,
+		"storeMeetings", &w.store,
+		"treeMeetings", &w.tree,
+		"entTitle", &w.entTitle,
+		"entDate", &w.entDate,
+		"entTime", &w.entTime,
+		"entPassword", &w.entPassword,
+		"spnDuration", &w.spnDuration,
+		"spnLead", &w.spnLead,
+		"chkKeepRoom", &w.chkKeepRoom,
+		"lblStatus", &w.lblStatus,
+		"btnSchedule", &w.btnSchedule,
+	)
+
+	w.proposeStart()
+
+	if u.mainWindow != nil {
+		w.win.SetTransientFor(u.mainWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_copy_invitation": w.copyInvitation,
+		"on_save_calendar":   w.saveCalendarEntry,
+		"on_remove_meeting":  w.removeMeeting,
+		"on_schedule":        w.schedule,
+		"on_close":           w.win.Destroy,
+	})
+
+	w.refresh()
+
+	w.win.Present()
+	w.win.Show()
+}
+
+// proposeStart proposes the next full hour, like for the calendar entries
+func (w *scheduledMeetingsWindow) proposeStart() {
+	next := time.Now().Truncate(time.Hour).Add(time.Hour)
+	w.entDate.SetText(next.Format(calendarDateFormat))
+	w.entTime.SetText(next.Format(calendarTimeFormat))
+}
+
+func (w *scheduledMeetingsWindow) refresh() {
+	w.store.Clear()
+
+	for _, m := range w.u.config.ListScheduledMeetings() {
+		iter := w.store.Append()
+		_ = w.store.Set2(iter,
+			[]int{scheduledColumnID, scheduledColumnTitle, scheduledColumnStart},
+			[]interface{}{m.ID, m.Title, m.Start.Format(scheduledStartFormat)})
+	}
+}
+
+func (w *scheduledMeetingsWindow) selectedMeeting() (config.ScheduledMeeting, bool) {
+	sel, err := w.tree.GetSelection()
+	if err != nil {
+		return config.ScheduledMeeting{}, false
+	}
+
+	model, iter, ok := sel.GetSelected()
+	if !ok {
+		return config.ScheduledMeeting{}, false
+	}
+
+	v, err := model.GetValue(iter, scheduledColumnID)
+	if err != nil {
+		return config.ScheduledMeeting{}, false
+	}
+
+	id, _ := v.GetString()
+
+	return w.u.config.ScheduledMeeting(id)
+}
+
+func (w *scheduledMeetingsWindow) schedule() {
+	date, _ := w.entDate.GetText()
+	hour, _ := w.entTime.GetText()
+
+	start, err := parseScheduledTime(date, hour)
+	if err != nil {
+		w.lblStatus.SetText(i18n.Sprintf("The date or the time are not valid"))
+		return
+	}
+
+	if !start.After(time.Now()) {
+		w.lblStatus.SetText(i18n.Sprintf("The meeting has to start in the future"))
+		return
+	}
+
+	title := getTrimmedText(w.entTitle)
+	if title == "" {
+		title = i18n.Sprintf("Wahay meeting")
+	}
+
+	keepRoom := w.chkKeepRoom.GetActive()
+	if _, exists := w.u.config.MeetingRoom(title); keepRoom && exists {
+		w.lblStatus.SetText(i18n.Sprintf("There is already a meeting room with that name"))
+		return
+	}
+
+	password, _ := w.entPassword.GetText()
+	m := config.ScheduledMeeting{
+		Title:       title,
+		Start:       start,
+		Duration:    w.spnDuration.GetValueAsInt(),
+		LeadMinutes: w.spnLead.GetValueAsInt(),
+		Password:    password,
+		KeepRoom:    keepRoom,
+	}
+
+	w.btnSchedule.SetSensitive(false)
+	w.lblStatus.SetText(i18n.Sprintf("Generating the address and the invitation of the meeting..."))
+
+	// Generating the certificate of the room takes a while
+	go func() {
+		err := w.u.newScheduledMeeting(&m)
+
+		w.u.doInUIThread(func() {
+			w.btnSchedule.SetSensitive(true)
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"context": "scheduler",
+				}).Warningf("The meeting can't be scheduled: %s", err)
+				w.lblStatus.SetText(i18n.Sprintf("The meeting can't be scheduled: %s", err))
+				return
+			}
+
+			w.u.scheduleMeeting(m)
+
+			w.entTitle.SetText("")
+			w.entPassword.SetText("")
+			w.chkKeepRoom.SetActive(false)
+			w.proposeStart()
+			w.refresh()
+
+			w.lblStatus.SetText(i18n.Sprintf("The meeting has been scheduled, and its invitation can be given now"))
+		})
+	}()
+}
+
+func (w *scheduledMeetingsWindow) copyInvitation() {
+	m, ok := w.selectedMeeting()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the meeting whose invitation you want to copy"))
+		return
+	}
+
+	err := w.u.copyToClipboard(m.Invitation)
+	if err != nil {
+		w.lblStatus.SetText(i18n.Sprintf("The invitation can't be copied: %s", err))
+		return
+	}
+
+	w.lblStatus.SetText(i18n.Sprintf("The invitation has been copied to the clipboard"))
+}
+
+func (w *scheduledMeetingsWindow) saveCalendarEntry() {
+	m, ok := w.selectedMeeting()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the meeting you want to add to a calendar"))
+		return
+	}
+
+	inv, err := invitation.Parse(m.Invitation)
+	if err != nil {
+		w.lblStatus.SetText(i18n.Sprintf("The calendar entry can't be created: %s", invitationErrorTranslator(err)))
+		return
+	}
+
+	if w.u.saveCalendarEntry(inv, m.Title, m.Start, time.Duration(m.Duration)*time.Minute) {
+		w.lblStatus.SetText(i18n.Sprintf("The calendar entry has been saved"))
+	}
+}
+
+func (w *scheduledMeetingsWindow) removeMeeting() {
+	m, ok := w.selectedMeeting()
+	if !ok {
+		w.lblStatus.SetText(i18n.Sprintf("Select the meeting you want to remove"))
+		return
+	}
+
+	w.u.showConfirmation(func(ok bool) {
+		if !ok {
+			return
+		}
+
+		w.u.forgetScheduledMeeting(m.ID)
+		w.refresh()
+		w.lblStatus.SetText("")
+	}, i18n.Sprintf("The meeting %s will be removed, and its invitation will not work anymore.", m.Title))
+}
+
+// newScheduledMeeting generates the room and the invitation of the
+// meeting, and saves it. It's called outside of the UI thread
+func (u *gtkUI) newScheduledMeeting(m *config.ScheduledMeeting) error {
+	ports, err := hosting.NewServicePorts(u.config.GetPortMumble(), u.config.GetMumbleListenerPort(), u.config.GetOnionPortMappings())
+	if err != nil {
+		return err
+	}
+
+	room, inv, err := hosting.NewRoom(ports, u.config.GetEmbedCertificate())
+	if err != nil {
+		return err
+	}
+
+	inv.Password = m.Password
+	m.Invitation, err = inv.EncodeV2()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	m.ID = fmt.Sprintf("%x", now.UnixNano())
+	m.Room = config.MeetingRoom{
+		Name:           m.Title,
+		Port:           room.Port,
+		OnionKey:       room.OnionKey,
+		Token:          room.Token,
+		Certificate:    string(room.Certificate),
+		CertificateKey: string(room.CertificateKey),
+		Created:        now,
+	}
+
+	if !u.config.ScheduleMeeting(*m) {
+		return errTooManyScheduledMeetings
+	}
+	u.saveConfigOnly()
+
+	log.WithFields(log.Fields{
+		"context": "scheduler",
+		"start":   m.Start,
+	}).Info("A meeting has been scheduled")
+
+	return nil
+}
+
+// startScheduler waits for the scheduled meetings. The ones that
+// are over already were missed, so they are forgotten
+func (u *gtkUI) startScheduler() {
+	u.scheduler = scheduler.New(func(id string) {
+		u.doInUIThread(func() {
+			u.hostScheduledMeeting(id)
+		})
+	})
+	u.onExit(u.scheduler.Stop)
+
+	missed := false
+	for _, m := range u.config.ListScheduledMeetings() {
+		if !u.scheduleMeeting(m) {
+			u.config.RemoveScheduledMeeting(m.ID)
+			missed = true
+
+			log.WithFields(log.Fields{
+				"context": "scheduler",
+				"start":   m.Start,
+			}).Info("A scheduled meeting was missed, so it has been removed")
+		}
+	}
+
+	if missed {
+		u.saveConfigOnly()
+	}
+}
+
+// scheduleMeeting waits for the meeting, if the scheduler has started
+// already. It returns false when the meeting is over
+func (u *gtkUI) scheduleMeeting(m config.ScheduledMeeting) bool {
+	if u.scheduler == nil {
+		return true
+	}
+
+	return u.scheduler.Schedule(scheduler.Meeting{
+		ID:  m.ID,
+		Due: m.HostingTime(),
+		End: m.End(),
+	})
+}
+
+func (u *gtkUI) forgetScheduledMeeting(id string) {
+	if u.scheduler != nil {
+		u.scheduler.Cancel(id)
+	}
+
+	if u.config.RemoveScheduledMeeting(id) {
+		u.saveConfigOnly()
+	}
+}
+
+// hostScheduledMeeting hosts the meeting when it's due, unless the
+// user is in another meeting already. Either way, the host is told
+// with a notification, since they might be doing something else
+func (u *gtkUI) hostScheduledMeeting(id string) {
+	m, ok := u.config.ScheduledMeeting(id)
+	if !ok {
+		return
+	}
+
+	if !u.meetingsEnabled || u.currentHost != nil || u.currentMumble != nil {
+		log.WithFields(log.Fields{
+			"context": "scheduler",
+		}).Warning("The scheduled meeting can't be hosted while in another meeting")
+		notify(i18n.Sprintf("%s can't be hosted", m.Title),
+			i18n.Sprintf("Wahay is in another meeting, or it's not ready for meetings."))
+		return
+	}
+
+	notify(i18n.Sprintf("Hosting %s", m.Title),
+		i18n.Sprintf("The meeting starts at %s, and Wahay is preparing it now.", m.Start.Format(calendarTimeFormat)))
+
+	go u.realHostScheduledMeeting(m)
+}
+
+// realHostScheduledMeeting hosts the meeting without asking anything, like
+// when it's done through the control interface. The meeting is forgotten
+// once hosted, except for its room when the host wanted to keep it
+func (u *gtkUI) realHostScheduledMeeting(m config.ScheduledMeeting) {
+	defer config.LogDuration("host: prepare the scheduled meeting", time.Now())
+
+	fail := func(err error) {
+		log.WithFields(errorFields(err)).Errorf("the scheduled meeting can't be hosted: %s", err)
+		notify(i18n.Sprintf("%s can't be hosted", m.Title), i18n.Sprintf("Something went wrong: %s", err))
+	}
+
+	if u.servers == nil {
+		var err error
+		u.servers, err = u.takePreparedServers()
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	h := &hostData{
+		u:               u,
+		meetingPassword: m.Password,
+		scheduled:       &m,
+		acl: hosting.MeetingACL{
+			ParticipantsCanMute: u.config.GetParticipantsCanMute(),
+			ParticipantsCanKick: u.config.GetParticipantsCanKick(),
+			WaitingRoom:         u.config.GetWaitingRoom(),
+		},
+	}
+
+	// The room is only saved with a name nobody else uses
+	if _, exists := u.config.MeetingRoom(m.Room.Name); m.KeepRoom && !exists {
+		room := m.Room
+		h.room = &room
+	}
+
+	echan := make(chan error)
+	go h.createNewService(echan)
+
+	err := <-echan
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	h.service.SetMeetingACL(h.acl)
+	err = h.service.NewConferenceRoom(m.Password, hosting.SuperUserData{})
+	if err != nil {
+		_ = h.service.Close()
+		u.servers = nil
+		u.prepareServers()
+		fail(err)
+		return
+	}
+
+	h.startMeetingTimer(0)
+	h.startChatTranscript()
+
+	u.scheduler.Cancel(m.ID)
+	if u.config.RemoveScheduledMeeting(m.ID) {
+		u.saveConfigOnly()
+	}
+
+	u.currentHost = h
+
+	h.service.WhenPublished(func(err error) {
+		if err == nil {
+			notify(i18n.Sprintf("%s can be joined now", m.Title),
+				i18n.Sprintf("The participants can join the meeting with its invitation."))
+		}
+		h.notifyWebhook()
+	})
+
+	h.watchWaitingRoom()
+
+	u.hideMainWindow()
+	u.doInUIThread(h.showMeetingControls)
+}
@@ -0,0 +1,186 @@
+package gui
+
+import (
+	"errors"
+	"time"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/selftest"
+	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errSelfTestNoTor = errors.New("tor is not running, look at the startup errors")
+
+const (
+	selfTestColumnStep = iota
+	selfTestColumnResult
+)
+
+type selfTestWindow struct {
+	u     *gtkUI
+	steps []selftest.Step
+
+	win       gtki.Window
+	store     gtki.ListStore
+	btnRun    gtki.Button
+	lblStatus gtki.Label
+
+	rows []gtki.TreeIter
+}
+
+// showSelfTest opens the window of the self-test and starts it,
+// so the user sees which of the steps Wahay needs fails
+func (u *gtkUI) showSelfTest(parent gtki.Window) {
+	builder := u.g.uiBuilderFor("SelfTestWindow")
+	builder.i18nProperties(
+		"title", "selfTestWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"title", "colStep",
+		"title", "colResult",
+		"button", "btnClose",
+		"button", "btnRunSelfTest")
+
+	w := &selfTestWindow{u: u}
+
+	builder.getItems(
+		"selfTestWindow", &w.win,
+		"storeSteps", &w.store,
+		"btnRunSelfTest", &w.btnRun,
+		"lblStatus", &w.lblStatus,
+	)
+
+	if parent != nil {
+		w.win.SetTransientFor(parent)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_run":   w.run,
+		"on_close": w.win.Destroy,
+	})
+
+	w.win.Present()
+	w.win.Show()
+
+	w.run()
+}
+
+func (s *settings) runSelfTest() {
+	s.u.showSelfTest(s.dialog)
+}
+
+// environment is what the steps use from the running Wahay: the Tor
+// instance started with it and the Mumble found when it started
+func (w *selfTestWindow) environment() selftest.Environment {
+	u := w.u
+
+	return selftest.Environment{
+		Tor: func() (tor.Instance, error) {
+			t := u.torInstance()
+			if t == nil {
+				return nil, errSelfTestNoTor
+			}
+			return t, nil
+		},
+		Mumble: func() error {
+			if u.client != nil && u.client.IsValid() {
+				return nil
+			}
+
+			c := client.InitSystem(u.config, nil)
+			defer c.Destroy()
+			if !c.IsValid() {
+				return c.LastError()
+			}
+			return nil
+		},
+		Certificate: func() error {
+			_, err := client.NewIdentity(u.config.GetCertificateKey())
+			return err
+		},
+	}
+}
+
+func (w *selfTestWindow) run() {
+	w.btnRun.SetSensitive(false)
+	w.lblStatus.SetText(i18n.Sprintf("Checking..."))
+
+	w.steps = selftest.Steps(w.environment())
+	w.store.Clear()
+	w.rows = nil
+	for _, s := range w.steps {
+		iter := w.store.Append()
+		_ = w.store.Set2(iter,
+			[]int{selfTestColumnStep, selfTestColumnResult},
+			[]interface{}{selfTestStepDescription(s), i18n.Sprintf("Waiting")})
+		w.rows = append(w.rows, iter)
+	}
+
+	go func() {
+		ok := selftest.RunSteps(w.steps, func(r selftest.StepResult) {
+			w.u.doInUIThread(func() {
+				w.showResult(r)
+			})
+		})
+
+		w.u.doInUIThread(func() {
+			w.btnRun.SetSensitive(true)
+			if ok {
+				w.lblStatus.SetText(i18n.Sprintf("Everything works in this computer."))
+				return
+			}
+			w.lblStatus.SetText(i18n.Sprintf("Some of the steps failed. The diagnostic bundle " +
+				"can be attached to a bug report to find out why."))
+		})
+	}()
+}
+
+func (w *selfTestWindow) showResult(r selftest.StepResult) {
+	for i, s := range w.steps {
+		if s.Name != r.Step.Name || i >= len(w.rows) {
+			continue
+		}
+		_ = w.store.SetValue(w.rows[i], selfTestColumnResult, selfTestResultText(r))
+	}
+
+	l := log.WithFields(log.Fields{
+		"context": "selftest",
+		"step":    r.Step.Name,
+	})
+
+	switch {
+	case r.Skipped:
+		l.Info("Self-test step skipped")
+	case r.Passed():
+		l.Infof("Self-test step passed in %s", r.Duration)
+	default:
+		l.Warningf("Self-test step failed: %s", r.Err)
+	}
+}
+
+func selfTestStepDescription(s selftest.Step) string {
+	switch s.Name {
+	case "tor":
+		return i18n.Sprintf("Tor connects to the network")
+	case "onion":
+		return i18n.Sprintf("An onion service can be created and reached through Tor")
+	case "mumble":
+		return i18n.Sprintf("Mumble is found")
+	case "certificate":
+		return i18n.Sprintf("A certificate can be generated")
+	}
+	return s.Description
+}
+
+func selfTestResultText(r selftest.StepResult) string {
+	switch {
+	case r.Skipped:
+		return i18n.Sprintf("Skipped")
+	case r.Passed():
+		return i18n.Sprintf("Passed (%s)", r.Duration.Round(time.Second))
+	}
+	return i18n.Sprintf("Failed: %s", r.Err)
+}
@@ -0,0 +1,79 @@
+package gui
+
+import (
+	"sync"
+
+	"github.com/digitalautonomy/wahay/hosting"
+)
+
+// preparedServers is a collection of servers being created in the
+// background. Generating its certificate takes a while, so we do
+// it before the user decides to host a meeting
+type preparedServers struct {
+	done    chan bool
+	servers hosting.Servers
+	err     error
+}
+
+type serversPreparation struct {
+	sync.Mutex
+	next *preparedServers
+}
+
+// prepareServers starts creating the servers collection
+// for the next meeting hosted by the user
+func (u *gtkUI) prepareServers() {
+	p := &preparedServers{
+		done: make(chan bool),
+	}
+
+	u.nextServers.Lock()
+	defer u.nextServers.Unlock()
+
+	if u.nextServers.next != nil {
+		return
+	}
+	u.nextServers.next = p
+
+	go func() {
+		p.servers, p.err = hosting.CreateServerCollection()
+		close(p.done)
+	}()
+}
+
+// takePreparedServers returns the collection created in the background,
+// waiting for it if it's not ready yet. If nothing was prepared, the
+// collection is created right away
+func (u *gtkUI) takePreparedServers() (hosting.Servers, error) {
+	u.nextServers.Lock()
+	p := u.nextServers.next
+	u.nextServers.next = nil
+	u.nextServers.Unlock()
+
+	if p == nil {
+		return hosting.CreateServerCollection()
+	}
+
+	<-p.done
+
+	return p.servers, p.err
+}
+
+// cleanupPreparedServers removes the collection that was
+// prepared but never used to host a meeting
+func (u *gtkUI) cleanupPreparedServers() {
+	u.nextServers.Lock()
+	p := u.nextServers.next
+	u.nextServers.next = nil
+	u.nextServers.Unlock()
+
+	if p == nil {
+		return
+	}
+
+	<-p.done
+
+	if p.servers != nil {
+		p.servers.Cleanup()
+	}
+}
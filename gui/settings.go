@@ -3,11 +3,16 @@ package gui
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/client"
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/secrets"
+	"github.com/digitalautonomy/wahay/tor"
 )
 
 type settings struct {
@@ -16,8 +21,16 @@ type settings struct {
 	dialog gtki.Window
 
 	chkAutojoin                gtki.CheckButton
+	chkFileSharing             gtki.CheckButton
+	chkFileSharingHostOnly     gtki.CheckButton
+	chkEmbedCertificate        gtki.CheckButton
+	chkOnionOnlyCertServer     gtki.CheckButton
+	chkIsolatedProfiles        gtki.CheckButton
+	chkMeetingHistory          gtki.CheckButton
+	chkChatTranscripts         gtki.CheckButton
 	chkPersistentConfiguration gtki.CheckButton
 	chkEncryptFile             gtki.CheckButton
+	chkSecretsInKeyring        gtki.CheckButton
 	lblMessage                 gtki.Label
 	chkEnableLogging           gtki.CheckButton
 	rawLogFile                 gtki.Entry
@@ -25,14 +38,85 @@ type settings struct {
 	mumbleBinaryLocation       gtki.Entry
 	mumblePort                 gtki.Entry
 	lblPortMumbleMessage       gtki.Label
+	webhookURL                 gtki.Entry
+	lblWebhookMessage          gtki.Label
+	torIdleTimeout             gtki.Entry
+	lblTorIdleTimeoutMessage   gtki.Label
+	clientAuthInvitees         gtki.Entry
+	lblClientAuthInviteesMsg   gtki.Label
+	maxParticipants            gtki.Entry
+	lblMaxParticipantsMsg      gtki.Label
+	maxBandwidth               gtki.Entry
+	lblMaxBandwidthMsg         gtki.Label
+	idleKickTimeout            gtki.Entry
+	lblIdleKickTimeoutMsg      gtki.Label
+	mumbleListenerPort         gtki.Entry
+	lblMumbleListenerPortMsg   gtki.Label
+	txtOnionPortMappings       gtki.TextView
+	lblOnionPortMappingsMsg    gtki.Label
+	txtTorBridges              gtki.TextView
+	btnRequestBridges          gtki.Button
+	lblTorBridgesMessage       gtki.Label
+	torControlAddress          gtki.Entry
+	entTorControlPassword      gtki.Entry
+	lblTorControlAddressMsg    gtki.Label
+	entTorSocksPort            gtki.Entry
+	entTorControlPort          gtki.Entry
+	lblTorPortsMsg             gtki.Label
+	cmbSenderKind              gtki.ComboBoxText
+	boxSenderCommand           gtki.Box
+	boxSenderSMTP              gtki.Box
+	entSenderCommand           gtki.Entry
+	entSMTPServer              gtki.Entry
+	entSMTPUsername            gtki.Entry
+	entSMTPPassword            gtki.Entry
+	entSMTPFrom                gtki.Entry
+	lblSenderMessage           gtki.Label
+	cmbAudioPreset             gtki.ComboBoxText
+	cmbPushToTalkKey           gtki.ComboBoxText
+	cmbInputDevice             gtki.ComboBoxText
+	cmbOutputDevice            gtki.ComboBoxText
+	cmbNoiseSuppression        gtki.ComboBoxText
+	cmbCertificateKey          gtki.ComboBoxText
+	cmbLogLevel                gtki.ComboBoxText
+	cmbLanguage                gtki.ComboBoxText
+	cmbFontScale               gtki.ComboBoxText
+	cmbTheme                   gtki.ComboBoxText
+	chkNotifications           map[string]gtki.CheckButton
+	chkPersistentIdentity      gtki.CheckButton
+	lblIdentityFingerprint     gtki.Label
+	btnExportIdentity          gtki.Button
+	lblIdentityMessage         gtki.Label
+	lblProfileMessage          gtki.Label
+	lblRecordingMessage        gtki.Label
+	lblTranscriptMessage       gtki.Label
 
 	autoJoinOriginalValue          bool
+	fileSharingOriginalValue       bool
+	fileSharingHostOnlyOrigValue   bool
+	embedCertificateOriginalValue  bool
+	onionOnlyCertServerOrigValue   bool
+	isolatedProfilesOriginalValue  bool
+	meetingHistoryOriginalValue    bool
+	chatTranscriptsOriginalValue   bool
 	persistConfigFileOriginalValue bool
 	encryptFileOriginalValue       bool
+	secretsInKeyringOriginalValue  bool
 	logOriginalValue               bool
 	rawLogFileOriginalValue        string
 	mumbleBinaryOriginalValue      string
 	mumblePortOriginalValue        string
+
+	// pushToTalkKeys are the keys shown to choose the push to talk one
+	pushToTalkKeys []string
+
+	// inputDevices and outputDevices are the audio devices shown to choose from
+	inputDevices  deviceChoices
+	outputDevices deviceChoices
+
+	// identity is only saved in the configuration with the
+	// other settings, but it can be exported before that
+	identity string
 }
 
 func createSettings(u *gtkUI) *settings {
@@ -47,8 +131,16 @@ func createSettings(u *gtkUI) *settings {
 
 	s.b.getItems(
 		"chkAutojoin", &s.chkAutojoin,
+		"chkFileSharing", &s.chkFileSharing,
+		"chkFileSharingHostOnly", &s.chkFileSharingHostOnly,
+		"chkEmbedCertificate", &s.chkEmbedCertificate,
+		"chkOnionOnlyCertServer", &s.chkOnionOnlyCertServer,
+		"chkIsolatedProfiles", &s.chkIsolatedProfiles,
+		"chkMeetingHistory", &s.chkMeetingHistory,
+		"chkChatTranscripts", &s.chkChatTranscripts,
 		"chkPersistentConfiguration", &s.chkPersistentConfiguration,
 		"chkEncryptFile", &s.chkEncryptFile,
+		"chkSecretsInKeyring", &s.chkSecretsInKeyring,
 		"lblMessage", &s.lblMessage,
 		"chkEnableLogging", &s.chkEnableLogging,
 		"rawLogFile", &s.rawLogFile,
@@ -56,8 +148,64 @@ func createSettings(u *gtkUI) *settings {
 		"mumbleBinaryLocation", &s.mumbleBinaryLocation,
 		"mumblePort", &s.mumblePort,
 		"lblPortMumbleMessage", &s.lblPortMumbleMessage,
+		"webhookURL", &s.webhookURL,
+		"lblWebhookMessage", &s.lblWebhookMessage,
+		"torIdleTimeout", &s.torIdleTimeout,
+		"lblTorIdleTimeoutMessage", &s.lblTorIdleTimeoutMessage,
+		"clientAuthInvitees", &s.clientAuthInvitees,
+		"lblClientAuthInviteesMessage", &s.lblClientAuthInviteesMsg,
+		"maxParticipants", &s.maxParticipants,
+		"lblMaxParticipantsMessage", &s.lblMaxParticipantsMsg,
+		"maxBandwidth", &s.maxBandwidth,
+		"lblMaxBandwidthMessage", &s.lblMaxBandwidthMsg,
+		"idleKickTimeout", &s.idleKickTimeout,
+		"lblIdleKickTimeoutMessage", &s.lblIdleKickTimeoutMsg,
+		"mumbleListenerPort", &s.mumbleListenerPort,
+		"lblMumbleListenerPortMessage", &s.lblMumbleListenerPortMsg,
+		"txtOnionPortMappings", &s.txtOnionPortMappings,
+		"lblOnionPortMappingsMessage", &s.lblOnionPortMappingsMsg,
+		"txtTorBridges", &s.txtTorBridges,
+		"btnRequestBridges", &s.btnRequestBridges,
+		"lblTorBridgesMessage", &s.lblTorBridgesMessage,
+		"torControlAddress", &s.torControlAddress,
+		"entTorControlPassword", &s.entTorControlPassword,
+		"lblTorControlAddressMessage", &s.lblTorControlAddressMsg,
+		"entTorSocksPort", &s.entTorSocksPort,
+		"entTorControlPort", &s.entTorControlPort,
+		"lblTorPortsMessage", &s.lblTorPortsMsg,
+		"cmbSenderKind", &s.cmbSenderKind,
+		"boxSenderCommand", &s.boxSenderCommand,
+		"boxSenderSMTP", &s.boxSenderSMTP,
+		"entSenderCommand", &s.entSenderCommand,
+		"entSMTPServer", &s.entSMTPServer,
+		"entSMTPUsername", &s.entSMTPUsername,
+		"entSMTPPassword", &s.entSMTPPassword,
+		"entSMTPFrom", &s.entSMTPFrom,
+		"lblSenderMessage", &s.lblSenderMessage,
+		"cmbAudioPreset", &s.cmbAudioPreset,
+		"cmbPushToTalkKey", &s.cmbPushToTalkKey,
+		"cmbInputDevice", &s.cmbInputDevice,
+		"cmbOutputDevice", &s.cmbOutputDevice,
+		"cmbNoiseSuppression", &s.cmbNoiseSuppression,
+		"cmbCertificateKey", &s.cmbCertificateKey,
+		"cmbLogLevel", &s.cmbLogLevel,
+		"cmbLanguage", &s.cmbLanguage,
+		"cmbFontScale", &s.cmbFontScale,
+		"cmbTheme", &s.cmbTheme,
+		"chkPersistentIdentity", &s.chkPersistentIdentity,
+		"lblIdentityFingerprint", &s.lblIdentityFingerprint,
+		"btnExportIdentity", &s.btnExportIdentity,
+		"lblIdentityMessage", &s.lblIdentityMessage,
+		"lblProfileMessage", &s.lblProfileMessage,
+		"lblRecordingMessage", &s.lblRecordingMessage,
+		"lblTranscriptMessage", &s.lblTranscriptMessage,
 	)
 
+	s.chkNotifications = make(map[string]gtki.CheckButton)
+	for event, id := range notificationCheckButtons {
+		s.chkNotifications[event] = s.b.get(id).(gtki.CheckButton)
+	}
+
 	s.init()
 
 	return s
@@ -69,6 +217,28 @@ func (s *settings) init() {
 	s.autoJoinOriginalValue = conf.GetAutoJoin()
 	s.chkAutojoin.SetActive(s.autoJoinOriginalValue)
 
+	s.fileSharingOriginalValue = conf.GetFileSharing()
+	s.chkFileSharing.SetActive(s.fileSharingOriginalValue)
+
+	s.fileSharingHostOnlyOrigValue = conf.GetFileSharingHostOnly()
+	s.chkFileSharingHostOnly.SetActive(s.fileSharingHostOnlyOrigValue)
+	s.chkFileSharingHostOnly.SetSensitive(s.fileSharingOriginalValue)
+
+	s.embedCertificateOriginalValue = conf.GetEmbedCertificate()
+	s.chkEmbedCertificate.SetActive(s.embedCertificateOriginalValue)
+
+	s.onionOnlyCertServerOrigValue = conf.GetOnionOnlyCertServer()
+	s.chkOnionOnlyCertServer.SetActive(s.onionOnlyCertServerOrigValue)
+
+	s.isolatedProfilesOriginalValue = conf.GetIsolatedProfiles()
+	s.chkIsolatedProfiles.SetActive(s.isolatedProfilesOriginalValue)
+
+	s.meetingHistoryOriginalValue = conf.GetKeepMeetingHistory()
+	s.chkMeetingHistory.SetActive(s.meetingHistoryOriginalValue)
+
+	s.chatTranscriptsOriginalValue = conf.GetKeepChatTranscripts()
+	s.chkChatTranscripts.SetActive(s.chatTranscriptsOriginalValue)
+
 	s.persistConfigFileOriginalValue = conf.IsPersistentConfiguration()
 	s.chkPersistentConfiguration.SetActive(s.persistConfigFileOriginalValue)
 	s.lblMessage.SetVisible(!s.persistConfigFileOriginalValue)
@@ -77,6 +247,11 @@ func (s *settings) init() {
 	s.chkEncryptFile.SetActive(s.encryptFileOriginalValue)
 	s.chkEncryptFile.SetSensitive(s.persistConfigFileOriginalValue)
 
+	s.secretsInKeyringOriginalValue = conf.GetSecretsInKeyring()
+	s.chkSecretsInKeyring.SetActive(s.secretsInKeyringOriginalValue)
+	s.chkSecretsInKeyring.SetSensitive(s.persistConfigFileOriginalValue)
+	s.chkSecretsInKeyring.SetVisible(conf.HasSecretStore())
+
 	s.logOriginalValue = conf.IsLogsEnabled()
 	s.chkEnableLogging.SetActive(s.logOriginalValue)
 	s.rawLogFileOriginalValue = conf.GetRawLogFile()
@@ -88,6 +263,338 @@ func (s *settings) init() {
 	s.mumbleBinaryLocation.SetText(s.mumbleBinaryOriginalValue)
 	s.mumblePortOriginalValue = conf.GetPortMumble()
 	s.mumblePort.SetText(s.mumblePortOriginalValue)
+
+	s.webhookURL.SetText(conf.GetWebhookURL())
+
+	if m := conf.GetTorIdleTimeout(); m > 0 {
+		s.torIdleTimeout.SetText(strconv.Itoa(m))
+	}
+
+	if n := conf.GetClientAuthInvitees(); n > 0 {
+		s.clientAuthInvitees.SetText(strconv.Itoa(n))
+	}
+
+	if n := conf.GetMaxParticipants(); n > 0 {
+		s.maxParticipants.SetText(strconv.Itoa(n))
+	}
+
+	if n := conf.GetMaxBandwidth(); n > 0 {
+		s.maxBandwidth.SetText(strconv.Itoa(n))
+	}
+
+	if m := conf.GetIdleKickTimeout(); m > 0 {
+		s.idleKickTimeout.SetText(strconv.Itoa(m))
+	}
+
+	s.mumbleListenerPort.SetText(conf.GetMumbleListenerPort())
+	setTextViewText(s.txtOnionPortMappings, strings.Join(conf.GetOnionPortMappings(), "\n"))
+
+	s.setTorBridgesText(strings.Join(conf.GetTorBridges(), "\n"))
+	s.torControlAddress.SetText(conf.GetTorControlAddress())
+	s.entTorControlPassword.SetText(conf.GetTorControlPassword())
+
+	if p := conf.GetTorSocksPort(); p > 0 {
+		s.entTorSocksPort.SetText(strconv.Itoa(p))
+	}
+
+	if p := conf.GetTorControlPort(); p > 0 {
+		s.entTorControlPort.SetText(strconv.Itoa(p))
+	}
+
+	s.initInvitationSender()
+	s.initAudioPreset()
+	s.initAudioSettings()
+	s.initCertificateKey()
+	s.initLogLevel()
+	s.initLanguage()
+	s.initFontScale()
+	s.initTheme()
+	s.initNotifications()
+	s.initIdentity()
+}
+
+// invitationSenderKinds are the senders that can be chosen in the
+// settings, in the same order they are shown to the user
+var invitationSenderKinds = []string{
+	"",
+	hosting.InvitationSenderCommand,
+	hosting.InvitationSenderSMTP,
+}
+
+func invitationSenderKindName(kind string) string {
+	switch kind {
+	case hosting.InvitationSenderCommand:
+		return i18n.Sprintf("A local command")
+	case hosting.InvitationSenderSMTP:
+		return i18n.Sprintf("Email, through Tor")
+	}
+	return i18n.Sprintf("Don't send invitations from Wahay")
+}
+
+func (s *settings) initInvitationSender() {
+	sender := s.u.config.GetInvitationSender()
+
+	active := 0
+	for i, kind := range invitationSenderKinds {
+		s.cmbSenderKind.AppendText(invitationSenderKindName(kind))
+		if kind == sender.Kind {
+			active = i
+		}
+	}
+	s.cmbSenderKind.SetActive(active)
+
+	s.entSenderCommand.SetText(sender.Command)
+	s.entSMTPServer.SetText(sender.SMTPServer)
+	s.entSMTPUsername.SetText(sender.SMTPUsername)
+	s.entSMTPPassword.SetText(sender.SMTPPassword)
+	s.entSMTPFrom.SetText(sender.SMTPFrom)
+
+	s.updateInvitationSenderFields()
+}
+
+func (s *settings) selectedInvitationSenderKind() string {
+	i := s.cmbSenderKind.GetActive()
+	if i < 0 || i >= len(invitationSenderKinds) {
+		return ""
+	}
+	return invitationSenderKinds[i]
+}
+
+func (s *settings) updateInvitationSenderFields() {
+	kind := s.selectedInvitationSenderKind()
+	s.boxSenderCommand.SetVisible(kind == hosting.InvitationSenderCommand)
+	s.boxSenderSMTP.SetVisible(kind == hosting.InvitationSenderSMTP)
+	s.lblSenderMessage.SetVisible(false)
+}
+
+func (s *settings) initAudioPreset() {
+	preset := s.u.config.GetAudioPreset()
+
+	active := 0
+	for i, id := range audioPresetChoices {
+		s.cmbAudioPreset.AppendText(audioPresetName(id))
+		if id == preset {
+			active = i
+		}
+	}
+	s.cmbAudioPreset.SetActive(active)
+}
+
+func (s *settings) processAudioPreset() {
+	i := s.cmbAudioPreset.GetActive()
+	if i < 0 || i >= len(audioPresetChoices) {
+		return
+	}
+	s.u.config.SetAudioPreset(audioPresetChoices[i])
+}
+
+func (s *settings) initAudioSettings() {
+	audio := s.u.config.GetAudioSettings()
+
+	// A key code written in the configuration file is kept as a choice
+	s.pushToTalkKeys = client.PushToTalkKeys
+	if audio.PushToTalkKey != "" && client.IsValidPushToTalkKey(audio.PushToTalkKey) {
+		found := false
+		for _, key := range s.pushToTalkKeys {
+			found = found || key == audio.PushToTalkKey
+		}
+		if !found {
+			s.pushToTalkKeys = append(append([]string{}, s.pushToTalkKeys...), audio.PushToTalkKey)
+		}
+	}
+
+	active := 0
+	for i, key := range s.pushToTalkKeys {
+		s.cmbPushToTalkKey.AppendText(pushToTalkKeyName(key))
+		if key == audio.PushToTalkKey {
+			active = i
+		}
+	}
+	s.cmbPushToTalkKey.SetActive(active)
+
+	devices := listAudioDevices()
+	s.inputDevices = fillDeviceChoices(s.cmbInputDevice, devices.Inputs, audio.InputDevice)
+	s.outputDevices = fillDeviceChoices(s.cmbOutputDevice, devices.Outputs, audio.OutputDevice)
+
+	active = 0
+	for i, db := range noiseSuppressionChoices {
+		s.cmbNoiseSuppression.AppendText(noiseSuppressionName(db))
+		if db == audio.NoiseSuppression {
+			active = i
+		}
+	}
+	s.cmbNoiseSuppression.SetActive(active)
+}
+
+// processAudioSettings stores the audio settings shown in the window.
+// The quality and the jitter buffer can only be tuned in the
+// configuration file, so they are kept as they were
+func (s *settings) processAudioSettings() {
+	audio := s.u.config.GetAudioSettings()
+
+	if i := s.cmbPushToTalkKey.GetActive(); i >= 0 && i < len(s.pushToTalkKeys) {
+		audio.PushToTalkKey = s.pushToTalkKeys[i]
+	}
+
+	audio.InputDevice = s.inputDevices.chosen(s.cmbInputDevice)
+	audio.OutputDevice = s.outputDevices.chosen(s.cmbOutputDevice)
+
+	if i := s.cmbNoiseSuppression.GetActive(); i >= 0 && i < len(noiseSuppressionChoices) {
+		audio.NoiseSuppression = noiseSuppressionChoices[i]
+	}
+
+	s.u.config.SetAudioSettings(audio)
+}
+
+func (s *settings) initCertificateKey() {
+	key := s.u.config.GetCertificateKey()
+
+	active := 0
+	for i, id := range certificateKeyChoices {
+		s.cmbCertificateKey.AppendText(certificateKeyName(id))
+		if id == key {
+			active = i
+		}
+	}
+	s.cmbCertificateKey.SetActive(active)
+}
+
+func (s *settings) processCertificateKey() {
+	i := s.cmbCertificateKey.GetActive()
+	if i < 0 || i >= len(certificateKeyChoices) {
+		return
+	}
+	s.u.config.SetCertificateKey(certificateKeyChoices[i])
+}
+
+func (s *settings) initLogLevel() {
+	level := s.u.config.GetLogLevel()
+
+	active := 0
+	for i, id := range logLevelChoices {
+		s.cmbLogLevel.AppendText(logLevelName(id))
+		if id == level {
+			active = i
+		}
+	}
+	s.cmbLogLevel.SetActive(active)
+}
+
+// processLogLevel uses the level chosen right away, so
+// the problem being debugged doesn't need a restart
+func (s *settings) processLogLevel() {
+	i := s.cmbLogLevel.GetActive()
+	if i < 0 || i >= len(logLevelChoices) {
+		return
+	}
+	s.u.config.SetLogLevel(logLevelChoices[i])
+	s.u.config.ApplyLogLevel()
+}
+
+// initLanguage lists the language of the system first,
+// followed by the languages Wahay is translated to
+func (s *settings) initLanguage() {
+	lang := s.u.config.GetLanguage()
+
+	s.cmbLanguage.AppendText(i18n.Sprintf("Language of the system"))
+	active := 0
+	for i, tag := range config.Languages {
+		s.cmbLanguage.AppendText(languageName(tag))
+		if tag.String() == lang {
+			active = i + 1
+		}
+	}
+	s.cmbLanguage.SetActive(active)
+}
+
+// processLanguage uses the language chosen right away. The windows
+// opened after saving the settings are shown in it, and the main
+// window is translated again
+func (s *settings) processLanguage() {
+	i := s.cmbLanguage.GetActive()
+	if i < 0 || i > len(config.Languages) {
+		return
+	}
+
+	lang := ""
+	if i > 0 {
+		lang = config.Languages[i-1].String()
+	}
+
+	if lang == s.u.config.GetLanguage() {
+		return
+	}
+
+	s.u.config.SetLanguage(lang)
+	s.u.applyLanguage()
+	s.u.retranslateMainWindow()
+}
+
+func (s *settings) initFontScale() {
+	scale := s.u.config.GetFontScale()
+
+	active := 0
+	for i, percent := range fontScaleChoices {
+		s.cmbFontScale.AppendText(fontScaleName(percent))
+		if percent == scale {
+			active = i
+		}
+	}
+	s.cmbFontScale.SetActive(active)
+}
+
+// processFontScale uses the size of the text chosen right away
+func (s *settings) processFontScale() {
+	i := s.cmbFontScale.GetActive()
+	if i < 0 || i >= len(fontScaleChoices) || fontScaleChoices[i] == s.u.config.GetFontScale() {
+		return
+	}
+	s.u.config.SetFontScale(fontScaleChoices[i])
+	s.u.applyFontScale()
+}
+
+func (s *settings) initTheme() {
+	current := s.u.config.GetTheme()
+
+	active := 0
+	for i, t := range themeChoices {
+		s.cmbTheme.AppendText(themeName(t))
+		if t == current {
+			active = i
+		}
+	}
+	s.cmbTheme.SetActive(active)
+}
+
+// processTheme uses the theme chosen right away
+func (s *settings) processTheme() {
+	i := s.cmbTheme.GetActive()
+	if i < 0 || i >= len(themeChoices) || themeChoices[i] == s.u.config.GetTheme() {
+		return
+	}
+	s.u.config.SetTheme(themeChoices[i])
+	s.u.applyTheme()
+}
+
+// notificationCheckButtons are the check buttons
+// that turn on or off the notifications of every event
+var notificationCheckButtons = map[string]string{
+	notificationParticipantJoined:  "chkNotifyParticipantJoined",
+	notificationParticipantLeft:    "chkNotifyParticipantLeft",
+	notificationConnectionDegraded: "chkNotifyConnectionDegraded",
+	notificationCertificateChanged: "chkNotifyCertificateChanged",
+}
+
+func (s *settings) initNotifications() {
+	for event, chk := range s.chkNotifications {
+		chk.SetActive(s.u.config.IsNotificationEnabled(event))
+	}
+}
+
+func (s *settings) processNotifications() {
+	for event, chk := range s.chkNotifications {
+		s.u.config.SetNotificationEnabled(event, chk.GetActive())
+	}
 }
 
 func (u *gtkUI) getSettingsBuilder() *uiBuilder {
@@ -95,14 +602,90 @@ func (u *gtkUI) getSettingsBuilder() *uiBuilder {
 
 	builder.i18nProperties(
 		"checkbox", "chkAutojoin",
+		"checkbox", "chkFileSharing",
+		"checkbox", "chkFileSharingHostOnly",
+		"checkbox", "chkEmbedCertificate",
+		"checkbox", "chkOnionOnlyCertServer",
+		"checkbox", "chkIsolatedProfiles",
+		"checkbox", "chkMeetingHistory",
+		"checkbox", "chkChatTranscripts",
 		"checkbox", "chkPersistentConfiguration",
 		"checkbox", "chkEncryptFile",
+		"checkbox", "chkSecretsInKeyring",
 		"checkbox", "chkEnableLogging",
 		"tooltip", "chkAutojoin",
+		"tooltip", "chkFileSharing",
+		"tooltip", "chkFileSharingHostOnly",
+		"tooltip", "chkEmbedCertificate",
+		"tooltip", "chkOnionOnlyCertServer",
 		"tooltip", "chkPersistentConfiguration",
+		"tooltip", "chkSecretsInKeyring",
 		"tooltip", "chkEnableLogging",
 		"label", "lblAutojoin",
+		"label", "lblFileSharing",
+		"label", "lblEmbedCertificate",
+		"label", "lblOnionOnlyCertServer",
 		"label", "lblHostingGroup",
+		"label", "lblLanguage",
+		"label", "lblLanguageHelp",
+		"label", "lblFontScale",
+		"label", "lblFontScaleHelp",
+		"label", "lblTheme",
+		"label", "lblThemeHelp",
+		"label", "lblNotifications",
+		"label", "lblNotificationsHelp",
+		"checkbox", "chkNotifyParticipantJoined",
+		"checkbox", "chkNotifyParticipantLeft",
+		"checkbox", "chkNotifyConnectionDegraded",
+		"checkbox", "chkNotifyCertificateChanged",
+		"label", "lblProfile",
+		"label", "lblProfileHelp",
+		"label", "lblRecordings",
+		"label", "lblRecordingsHelp",
+		"label", "lblWebhook",
+		"label", "lblWebhookMessage",
+		"label", "lblWebhookHelp",
+		"label", "lblTorIdleTimeout",
+		"label", "lblTorIdleTimeoutMessage",
+		"label", "lblTorIdleTimeoutHelp",
+		"label", "lblTorBridges",
+		"label", "lblTorBridgesMessage",
+		"label", "lblTorBridgesHelp",
+		"label", "lblTorControlAddress",
+		"label", "lblTorControlAddressMessage",
+		"label", "lblTorControlAddressHelp",
+		"label", "lblTorPorts",
+		"label", "lblTorPortsMessage",
+		"label", "lblTorPortsHelp",
+		"label", "lblClientAuthInvitees",
+		"label", "lblClientAuthInviteesMessage",
+		"label", "lblClientAuthInviteesHelp",
+		"label", "lblMaxParticipants",
+		"label", "lblMaxParticipantsMessage",
+		"label", "lblMaxParticipantsHelp",
+		"label", "lblMaxBandwidth",
+		"label", "lblMaxBandwidthMessage",
+		"label", "lblMaxBandwidthHelp",
+		"label", "lblIdleKickTimeout",
+		"label", "lblIdleKickTimeoutMessage",
+		"label", "lblIdleKickTimeoutHelp",
+		"label", "lblMumbleListenerPort",
+		"label", "lblMumbleListenerPortMessage",
+		"label", "lblMumbleListenerPortHelp",
+		"label", "lblOnionPortMappings",
+		"label", "lblOnionPortMappingsMessage",
+		"label", "lblOnionPortMappingsHelp",
+		"label", "lblSenderKind",
+		"label", "lblSenderHelp",
+		"label", "lblSenderCommand",
+		"label", "lblSenderCommandHelp",
+		"label", "lblSMTPServer",
+		"label", "lblSMTPUsername",
+		"label", "lblSMTPPassword",
+		"label", "lblSMTPFrom",
+		"label", "lblSMTPHelp",
+		"label", "lblSenderMessage",
+		"label", "tabInvitations",
 		"label", "tabGeneral",
 		"label", "tabSecurity",
 		"label", "tabDebug",
@@ -113,11 +696,34 @@ func (u *gtkUI) getSettingsBuilder() *uiBuilder {
 		"label", "lblDebugLogFileDescription",
 		"label", "lblDebugLogFileBrowse",
 		"label", "lblDebugLogFileWarning",
+		"label", "lblDiagnosticBundle",
+		"label", "lblDiagnosticBundleHelp",
+		"label", "lblSelfTest",
+		"label", "lblSelfTestHelp",
+		"label", "lblRecentLogs",
+		"label", "lblRecentLogsHelp",
+		"label", "lblLogLevel",
+		"label", "lblLogLevelHelp",
 		"label", "lblMumbleLocation",
 		"label", "lblMumbleBinaryBrowse",
 		"label", "lblMumblePort",
 		"label", "lblPortMumbleMessage",
 		"label", "lblMumblePortHelp",
+		"label", "lblAudioPreset",
+		"label", "lblAudioPresetHelp",
+		"label", "lblPushToTalkKey",
+		"label", "lblInputDevice",
+		"label", "lblOutputDevice",
+		"label", "lblNoiseSuppression",
+		"label", "lblAudioDevicesHelp",
+		"label", "lblCertificateKey",
+		"label", "lblCertificateKeyHelp",
+		"label", "lblIdentity",
+		"label", "lblIdentityHelp",
+		"label", "lblIsolatedProfiles",
+		"label", "lblIsolatedProfilesHelp",
+		"label", "lblMeetingHistoryHelp",
+		"label", "lblChatTranscriptsHelp",
 		"label", "lblMessage",
 		"label", "lblSettingsWarning",
 		"label", "lblConfigFileCorrupted",
@@ -125,10 +731,37 @@ func (u *gtkUI) getSettingsBuilder() *uiBuilder {
 		"label", "lblMumbleBinaryDescription",
 		"button", "btnCancelSettings",
 		"button", "btnSaveSettings",
+		"button", "btnDiagnosticBundle",
+		"button", "btnSelfTest",
+		"button", "btnRequestBridges",
+		"checkbox", "chkPersistentIdentity",
+		"button", "btnExportIdentity",
+		"button", "btnImportIdentity",
+		"button", "btnExportProfile",
+		"button", "btnImportProfile",
+		"button", "btnDecryptRecording",
+		"button", "btnExportTranscript",
+		"button", "btnExportRecentLogs",
 		"button", "btnConfigFileCorruptedCancel",
 		"button", "btnConfigFileCorruptedBackup",
 		"placeholder", "mumbleBinaryLocation",
-		"placeholder", "mumblePort")
+		"placeholder", "mumblePort",
+		"placeholder", "webhookURL",
+		"placeholder", "torIdleTimeout",
+		"placeholder", "clientAuthInvitees",
+		"placeholder", "maxParticipants",
+		"placeholder", "maxBandwidth",
+		"placeholder", "idleKickTimeout",
+		"placeholder", "mumbleListenerPort",
+		"placeholder", "torControlAddress",
+		"placeholder", "entTorControlPassword",
+		"placeholder", "entTorSocksPort",
+		"placeholder", "entTorControlPort",
+		"placeholder", "entSenderCommand",
+		"placeholder", "entSMTPServer",
+		"placeholder", "entSMTPUsername",
+		"placeholder", "entSMTPPassword",
+		"placeholder", "entSMTPFrom")
 
 	return builder
 }
@@ -142,6 +775,72 @@ func (s *settings) processAutojoinOption() {
 	}
 }
 
+func (s *settings) processFileSharingOption() {
+	conf := s.u.config
+
+	if s.chkFileSharing.GetActive() != s.fileSharingOriginalValue {
+		conf.SetFileSharing(!s.fileSharingOriginalValue)
+		s.fileSharingOriginalValue = !s.fileSharingOriginalValue
+	}
+
+	if s.chkFileSharingHostOnly.GetActive() != s.fileSharingHostOnlyOrigValue {
+		conf.SetFileSharingHostOnly(!s.fileSharingHostOnlyOrigValue)
+		s.fileSharingHostOnlyOrigValue = !s.fileSharingHostOnlyOrigValue
+	}
+	s.chkFileSharingHostOnly.SetSensitive(s.fileSharingOriginalValue)
+}
+
+func (s *settings) processEmbedCertificateOption() {
+	conf := s.u.config
+
+	if s.chkEmbedCertificate.GetActive() != s.embedCertificateOriginalValue {
+		conf.SetEmbedCertificate(!s.embedCertificateOriginalValue)
+		s.embedCertificateOriginalValue = !s.embedCertificateOriginalValue
+	}
+}
+
+func (s *settings) processOnionOnlyCertServerOption() {
+	conf := s.u.config
+
+	if s.chkOnionOnlyCertServer.GetActive() != s.onionOnlyCertServerOrigValue {
+		conf.SetOnionOnlyCertServer(!s.onionOnlyCertServerOrigValue)
+		s.onionOnlyCertServerOrigValue = !s.onionOnlyCertServerOrigValue
+	}
+}
+
+func (s *settings) processIsolatedProfilesOption() {
+	conf := s.u.config
+
+	if s.chkIsolatedProfiles.GetActive() != s.isolatedProfilesOriginalValue {
+		conf.SetIsolatedProfiles(!s.isolatedProfilesOriginalValue)
+		s.isolatedProfilesOriginalValue = !s.isolatedProfilesOriginalValue
+	}
+}
+
+// processMeetingHistoryOption forgets the history when it's
+// not wanted anymore, since it tells where the user has been
+func (s *settings) processMeetingHistoryOption() {
+	conf := s.u.config
+
+	if s.chkMeetingHistory.GetActive() != s.meetingHistoryOriginalValue {
+		conf.SetKeepMeetingHistory(!s.meetingHistoryOriginalValue)
+		s.meetingHistoryOriginalValue = !s.meetingHistoryOriginalValue
+
+		if !s.meetingHistoryOriginalValue {
+			conf.ClearMeetingHistory()
+		}
+	}
+}
+
+func (s *settings) processChatTranscriptsOption() {
+	conf := s.u.config
+
+	if s.chkChatTranscripts.GetActive() != s.chatTranscriptsOriginalValue {
+		conf.SetKeepChatTranscripts(!s.chatTranscriptsOriginalValue)
+		s.chatTranscriptsOriginalValue = !s.chatTranscriptsOriginalValue
+	}
+}
+
 func (s *settings) processPersistentConfigOption() {
 	conf := s.u.config
 
@@ -150,6 +849,7 @@ func (s *settings) processPersistentConfigOption() {
 		conf.SetPersistentConfiguration(!s.persistConfigFileOriginalValue)
 		s.persistConfigFileOriginalValue = !s.persistConfigFileOriginalValue
 		s.chkEncryptFile.SetSensitive(s.persistConfigFileOriginalValue)
+		s.chkSecretsInKeyring.SetSensitive(s.persistConfigFileOriginalValue)
 	}
 }
 
@@ -162,6 +862,7 @@ func (s *settings) processEncryptFileOption() {
 				if op {
 					s.encryptFileOriginalValue = false
 					conf.SetShouldEncrypt(false)
+					s.u.forgetMasterPasswordInKeyring()
 					s.chkEncryptFile.SetActive(false)
 				} else {
 					// We keep the checkbutton checked. Nothing else change.
@@ -181,6 +882,21 @@ func (s *settings) processEncryptFileOption() {
 	}
 }
 
+// processSecretsInKeyringOption removes the secrets from the keyring
+// when they are not wanted there anymore
+func (s *settings) processSecretsInKeyringOption() {
+	conf := s.u.config
+
+	if s.chkSecretsInKeyring.GetActive() != s.secretsInKeyringOriginalValue {
+		conf.SetSecretsInKeyring(!s.secretsInKeyringOriginalValue)
+		s.secretsInKeyringOriginalValue = !s.secretsInKeyringOriginalValue
+
+		if !s.secretsInKeyringOriginalValue {
+			conf.ForgetKeyringSecrets()
+		}
+	}
+}
+
 func (s *settings) processLogsOption() {
 	conf := s.u.config
 
@@ -198,30 +914,351 @@ func (s *settings) processMumblePort() {
 	conf.SetPortMumble(v)
 }
 
+// processWebhookURL stores the webhook URL, returning false
+// and showing a message when the URL is not valid
+func (s *settings) processWebhookURL() bool {
+	v, _ := s.webhookURL.GetText()
+	v = strings.TrimSpace(v)
+
+	if hosting.ValidateWebhookURL(v) != nil {
+		s.lblWebhookMessage.SetVisible(true)
+		return false
+	}
+
+	s.lblWebhookMessage.SetVisible(false)
+	s.u.config.SetWebhookURL(v)
+	return true
+}
+
+// maxTorIdleTimeout is the longest idle time, in minutes, that can be configured
+const maxTorIdleTimeout = 24 * 60
+
+// processTorIdleTimeout stores the minutes Tor keeps running without
+// meetings, returning false and showing a message when it's not valid
+func (s *settings) processTorIdleTimeout() bool {
+	v := getTrimmedText(s.torIdleTimeout)
+
+	m := 0
+	if v != "" {
+		var err error
+		m, err = strconv.Atoi(v)
+		if err != nil || m < 0 || m > maxTorIdleTimeout {
+			s.lblTorIdleTimeoutMessage.SetVisible(true)
+			return false
+		}
+	}
+
+	s.lblTorIdleTimeoutMessage.SetVisible(false)
+	s.u.config.SetTorIdleTimeout(m)
+	return true
+}
+
+// maxClientAuthInvitees is the largest number of participants that
+// can be invited to a meeting with client authorization
+const maxClientAuthInvitees = 100
+
+// processClientAuthInvitees stores how many participants can reach the
+// meetings, returning false and showing a message when it's not valid
+func (s *settings) processClientAuthInvitees() bool {
+	v := getTrimmedText(s.clientAuthInvitees)
+
+	n := 0
+	if v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxClientAuthInvitees {
+			s.lblClientAuthInviteesMsg.SetVisible(true)
+			return false
+		}
+	}
+
+	s.lblClientAuthInviteesMsg.SetVisible(false)
+	s.u.config.SetClientAuthInvitees(n)
+	return true
+}
+
+const (
+	// maxMeetingParticipants is the largest number of participants
+	// that can be in a meeting at once
+	maxMeetingParticipants = 1000
+
+	// minMeetingBandwidth and maxMeetingBandwidth are the limits, in
+	// kilobits per second, of the bandwidth of every participant. Mumble
+	// can't send the audio with less than the minimum
+	minMeetingBandwidth = 8
+	maxMeetingBandwidth = 1000
+
+	// maxIdleKickTimeout is the longest idle time, in minutes, that can be configured
+	maxIdleKickTimeout = 24 * 60
+)
+
+// processMeetingLimits stores the limits of the hosted meetings, returning
+// false and showing a message when any of them is not valid
+func (s *settings) processMeetingLimits() bool {
+	parse := func(e gtki.Entry, msg gtki.Label, min, max int) (int, bool) {
+		v := getTrimmedText(e)
+
+		n := 0
+		if v != "" {
+			var err error
+			n, err = strconv.Atoi(v)
+			if err != nil || n < min || n > max {
+				msg.SetVisible(true)
+				return 0, false
+			}
+		}
+
+		msg.SetVisible(false)
+		return n, true
+	}
+
+	participants, ok1 := parse(s.maxParticipants, s.lblMaxParticipantsMsg, 0, maxMeetingParticipants)
+	bandwidth, ok2 := parse(s.maxBandwidth, s.lblMaxBandwidthMsg, minMeetingBandwidth, maxMeetingBandwidth)
+	idle, ok3 := parse(s.idleKickTimeout, s.lblIdleKickTimeoutMsg, 0, maxIdleKickTimeout)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+
+	s.u.config.SetMaxParticipants(participants)
+	s.u.config.SetMaxBandwidth(bandwidth)
+	s.u.config.SetIdleKickTimeout(idle)
+	return true
+}
+
+// processHostingPorts stores the local port of the Mumble server and the
+// other ports of the meetings, returning false and showing a message when
+// they are not valid
+func (s *settings) processHostingPorts() bool {
+	listener := getTrimmedText(s.mumbleListenerPort)
+	_, err := hosting.NewServicePorts("", listener, nil)
+	if err != nil {
+		s.lblMumbleListenerPortMsg.SetVisible(true)
+		return false
+	}
+	s.lblMumbleListenerPortMsg.SetVisible(false)
+
+	port, _ := s.mumblePort.GetText()
+	mappings, err := hosting.ParsePortMappings(getTextViewText(s.txtOnionPortMappings))
+	if err == nil {
+		_, err = hosting.NewServicePorts(port, listener, hosting.FormatPortMappings(mappings))
+	}
+	if err != nil {
+		s.lblOnionPortMappingsMsg.SetText(i18n.Sprintf("The port mappings are not valid: %s", describeError(err)))
+		s.lblOnionPortMappingsMsg.SetVisible(true)
+		return false
+	}
+	s.lblOnionPortMappingsMsg.SetVisible(false)
+
+	s.u.config.SetMumbleListenerPort(listener)
+	s.u.config.SetOnionPortMappings(hosting.FormatPortMappings(mappings))
+	return true
+}
+
+func textViewBuffer(tv gtki.TextView) gtki.TextBuffer {
+	b, err := tv.GetBuffer()
+	if err != nil {
+		fatal(err)
+	}
+	return b
+}
+
+func getTextViewText(tv gtki.TextView) string {
+	b := textViewBuffer(tv)
+	return b.GetText(b.GetStartIter(), b.GetEndIter(), false)
+}
+
+func setTextViewText(tv gtki.TextView, text string) {
+	textViewBuffer(tv).SetText(text)
+}
+
+func (s *settings) getTorBridgesText() string {
+	return getTextViewText(s.txtTorBridges)
+}
+
+func (s *settings) setTorBridgesText(text string) {
+	setTextViewText(s.txtTorBridges, text)
+}
+
+// processTorBridges stores the bridges used for connecting to Tor,
+// returning false and showing a message when any of them is not valid
+func (s *settings) processTorBridges() bool {
+	bridges, err := tor.ParseBridges(s.getTorBridgesText())
+	if err != nil {
+		s.showTorBridgesMessage(i18n.Sprintf("The bridges are not valid: %s", err.Error()))
+		return false
+	}
+
+	s.lblTorBridgesMessage.SetVisible(false)
+	s.u.config.SetTorBridges(bridges)
+	return true
+}
+
+func (s *settings) showTorBridgesMessage(m string) {
+	s.lblTorBridgesMessage.SetText(m)
+	s.lblTorBridgesMessage.SetVisible(true)
+}
+
+// requestBuiltinBridges adds the built-in obfs4 bridges of the
+// Tor Project to the bridges the user already has
+func (s *settings) requestBuiltinBridges() {
+	s.btnRequestBridges.SetSensitive(false)
+	s.lblTorBridgesMessage.SetVisible(false)
+
+	go func() {
+		bridges, err := tor.FetchBuiltinBridges(tor.TransportObfs4)
+		s.u.doInUIThread(func() {
+			s.btnRequestBridges.SetSensitive(true)
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Error("The built-in bridges can't be requested")
+				s.showTorBridgesMessage(i18n.Sprintf("The built-in bridges can't be requested: %s", err.Error()))
+				return
+			}
+
+			text := strings.TrimSpace(s.getTorBridgesText())
+			if text != "" {
+				text += "\n"
+			}
+			s.setTorBridgesText(text + strings.Join(bridges, "\n"))
+		})
+	}()
+}
+
+// processTorControlAddress stores the control port of the running Tor to
+// use, returning false and showing a message when it's not valid
+func (s *settings) processTorControlAddress() bool {
+	v := getTrimmedText(s.torControlAddress)
+
+	if v != "" && tor.ValidateControlAddress(v) != nil {
+		s.lblTorControlAddressMsg.SetVisible(true)
+		return false
+	}
+
+	s.lblTorControlAddressMsg.SetVisible(false)
+	s.u.config.SetTorControlAddress(v)
+
+	password, _ := s.entTorControlPassword.GetText()
+	s.u.config.SetTorControlPassword(password)
+	return true
+}
+
+// processTorPorts stores the ports of the Tor started by Wahay, returning
+// false and showing a message when they are not valid. An empty port is
+// chosen by Wahay
+func (s *settings) processTorPorts() bool {
+	socksPort, okSocks := parseOptionalPort(getTrimmedText(s.entTorSocksPort))
+	controlPort, okControl := parseOptionalPort(getTrimmedText(s.entTorControlPort))
+
+	if !okSocks || !okControl || (socksPort != 0 && socksPort == controlPort) {
+		s.lblTorPortsMsg.SetVisible(true)
+		return false
+	}
+
+	s.lblTorPortsMsg.SetVisible(false)
+	s.u.config.SetTorPorts(socksPort, controlPort)
+	return true
+}
+
+func parseOptionalPort(v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+
+	p, err := strconv.Atoi(v)
+	if err != nil || !config.CheckPort(p) {
+		return 0, false
+	}
+
+	return p, true
+}
+
+// processInvitationSender stores the configuration of the invitation
+// sender, returning false and showing a message when it's not complete
+func (s *settings) processInvitationSender() bool {
+	sender := config.InvitationSenderSettings{
+		Kind: s.selectedInvitationSenderKind(),
+	}
+
+	switch sender.Kind {
+	case hosting.InvitationSenderCommand:
+		sender.Command = getTrimmedText(s.entSenderCommand)
+	case hosting.InvitationSenderSMTP:
+		sender.SMTPServer = getTrimmedText(s.entSMTPServer)
+		sender.SMTPUsername = getTrimmedText(s.entSMTPUsername)
+		sender.SMTPPassword, _ = s.entSMTPPassword.GetText()
+		sender.SMTPFrom = getTrimmedText(s.entSMTPFrom)
+	}
+
+	if sender.IsEnabled() {
+		// The senders don't connect anywhere when they
+		// are created, so no Tor instance is needed here
+		_, err := hosting.NewInvitationSender(nil, sender)
+		if err != nil {
+			s.lblSenderMessage.SetVisible(true)
+			return false
+		}
+	}
+
+	s.lblSenderMessage.SetVisible(false)
+	s.u.config.SetInvitationSender(sender)
+	return true
+}
+
+func getTrimmedText(e gtki.Entry) string {
+	v, _ := e.GetText()
+	return strings.TrimSpace(v)
+}
+
 func (u *gtkUI) onSettingsToggleOption(s *settings) {
 	s.processAutojoinOption()
+	s.processFileSharingOption()
+	s.processEmbedCertificateOption()
+	s.processOnionOnlyCertServerOption()
+	s.processIsolatedProfilesOption()
+	s.processMeetingHistoryOption()
+	s.processChatTranscriptsOption()
 	s.processPersistentConfigOption()
 	s.processEncryptFileOption()
+	s.processSecretsInKeyringOption()
 	s.processLogsOption()
 }
 
+// close closes the settings window, without saving the changes
+func (s *settings) close() {
+	if s.u.mainWindow != nil {
+		s.u.enableWindow(s.u.mainWindow)
+	}
+	s.dialog.Destroy()
+	s.u.currentWindow = nil
+}
+
 func (u *gtkUI) openSettingsWindow() {
 	s := createSettings(u)
-
-	cleanup := func() {
-		if u.mainWindow != nil {
-			u.enableWindow(u.mainWindow)
-		}
-		s.dialog.Destroy()
-		u.currentWindow = nil
-	}
+	cleanup := s.close
 
 	s.b.ConnectSignals(map[string]interface{}{
 		"on_toggle_option": func() {
 			u.onSettingsToggleOption(s)
 		},
+		"on_sender_kind_changed": s.updateInvitationSenderFields,
+		"on_request_bridges":     s.requestBuiltinBridges,
 		"on_save": func() {
+			if !s.processWebhookURL() || !s.processTorIdleTimeout() || !s.processTorBridges() ||
+				!s.processTorControlAddress() || !s.processTorPorts() || !s.processClientAuthInvitees() || !s.processMeetingLimits() || !s.processHostingPorts() || !s.processInvitationSender() {
+				return
+			}
 			s.processMumblePort()
+			s.processAudioPreset()
+			s.processAudioSettings()
+			s.processCertificateKey()
+			s.processLogLevel()
+			s.processLanguage()
+			s.processFontScale()
+			s.processTheme()
+			s.processNotifications()
+			s.processIdentity()
 			u.saveConfigOnly()
 			cleanup()
 		},
@@ -234,6 +1271,16 @@ func (u *gtkUI) openSettingsWindow() {
 		"on_mumbleBinaryLocation_clicked_event": s.setCustomPathForMumble,
 		"on_portMumble_insert_text":             s.onInsertPortMumble,
 		"on_portMumble_delete_text":             s.onDeletePortMumble,
+		"on_diagnosticBundle_clicked":           s.createDiagnosticBundle,
+		"on_exportRecentLogs_clicked":           s.exportRecentLogs,
+		"on_selfTest_clicked":                   s.runSelfTest,
+		"on_persistentIdentity_toggled":         s.onPersistentIdentityToggled,
+		"on_exportIdentity_clicked":             s.exportIdentity,
+		"on_importIdentity_clicked":             s.importIdentity,
+		"on_exportProfile_clicked":              s.exportProfile,
+		"on_importProfile_clicked":              s.importProfile,
+		"on_decryptRecording_clicked":           s.decryptRecording,
+		"on_exportTranscript_clicked":           s.exportTranscript,
 	})
 
 	if u.mainWindow != nil {
@@ -316,6 +1363,11 @@ func (s *settings) setCustomPathForMumble() {
 func (u *gtkUI) initConfig() {
 	u.config = config.New()
 	u.config.Init()
+
+	if store, err := secrets.SecretService(); err == nil {
+		u.secrets = store
+		u.config.SetSecretStore(store)
+	}
 }
 
 func (u *gtkUI) loadConfig() {
@@ -329,6 +1381,7 @@ func (u *gtkUI) loadConfig() {
 	if err != nil {
 		log.Fatal("the configuration file can't be initialized")
 	}
+	u.firstRun = configFile == ""
 
 	if !u.ensureConfig(configFile) {
 		u.config.OnAfterLoad()
@@ -350,6 +1403,8 @@ func (u *gtkUI) ensureConfig(configFile string) bool {
 		}
 
 		if repeatIfFails {
+			// The password didn't open the file, so it's not saved
+			u.keyringPassword = ""
 			u.keySupplier.Invalidate()
 			u.keySupplier.LastAttemptFailed()
 			continue
@@ -362,6 +1417,8 @@ func (u *gtkUI) ensureConfig(configFile string) bool {
 		break
 	}
 
+	u.rememberMasterPassword()
+
 	return false
 }
 
@@ -18,27 +18,43 @@ func (u *gtkUI) connectShortcut(accel string, w gtki.Window, action func(gtki.Wi
 
 func (u *gtkUI) connectShortcutsMainWindow(w gtki.Window) {
 	// <Primary> maps to Command and OS X, but Control on other platforms
+	u.connectPanicShortcut(w)
 	u.connectShortcut("<Primary>q", w, u.closeApplicationWindow)
 	u.connectShortcut("<Primary>Q", w, u.closeApplicationWindow)
 	u.connectShortcut("<Alt>F4", w, u.closeApplicationWindow)
 	u.connectShortcut("Escape", w, u.closeApplicationWindow)
 	u.connectShortcut("<Primary>F4", w, u.closeApplicationWindow)
 	u.connectShortcut("<Primary>i", w, func(w gtki.Window) {
-		u.hostMeetingHandler()
+		if u.meetingsEnabled {
+			u.hostMeetingHandler()
+		}
 	})
 	u.connectShortcut("<Primary>I", w, func(w gtki.Window) {
-		u.hostMeetingHandler()
+		if u.meetingsEnabled {
+			u.hostMeetingHandler()
+		}
 	})
 	u.connectShortcut("<Primary>j", w, func(w gtki.Window) {
-		u.joinMeeting()
+		if u.meetingsEnabled {
+			u.joinMeeting()
+		}
 	})
 	u.connectShortcut("<Primary>J", w, func(w gtki.Window) {
-		u.joinMeeting()
+		if u.meetingsEnabled {
+			u.joinMeeting()
+		}
+	})
+	u.connectShortcut("<Primary>comma", w, func(w gtki.Window) {
+		u.openSettingsWindow()
+	})
+	u.connectShortcut("F1", w, func(w gtki.Window) {
+		u.openHelpWindow()
 	})
 }
 
 func (u *gtkUI) connectShortcutsHostingMeetingConfigurationWindow(w gtki.Window, b *uiBuilder, h *hostData) {
 	// <Primary> maps to Command and OS X, but Control on other platforms
+	u.connectPanicShortcut(w)
 	u.connectShortcut("<Primary>q", w, u.closeApplicationWindow)
 	u.connectShortcut("<Primary>Q", w, u.closeApplicationWindow)
 	u.connectShortcut("<Primary>F4", w, u.closeWindow)
@@ -51,6 +67,7 @@ func (u *gtkUI) connectShortcutsHostingMeetingConfigurationWindow(w gtki.Window,
 
 func (u *gtkUI) connectShortcutCurrentHostMeetingWindow(w gtki.Window, h *hostData) {
 	// <Primary> maps to Command and OS X, but Control on other platforms
+	u.connectPanicShortcut(w)
 	u.connectShortcut("<Primary>l", w, func(w gtki.Window) {
 		h.leaveHostMeeting()
 	})
@@ -67,6 +84,7 @@ func (u *gtkUI) connectShortcutCurrentHostMeetingWindow(w gtki.Window, h *hostDa
 
 func (u *gtkUI) connectShortcutCurrentMeetingWindow(w gtki.Window, m tor.Service) {
 	// <Primary> maps to Command and OS X, but Control on other platforms
+	u.connectPanicShortcut(w)
 	u.connectShortcut("<Primary>q", w, func(w gtki.Window) {
 		u.leaveMeeting(m)
 	})
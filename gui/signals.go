@@ -6,6 +6,8 @@ import (
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/config"
 )
 
 type cleanupHandler struct {
@@ -19,6 +21,7 @@ func (u *gtkUI) initCleanupHandler() {
 	}
 
 	u.cleanupHandler.initInterruptHandler()
+	u.cleanupHandler.initPanicHandler()
 }
 
 func (h *cleanupHandler) initInterruptHandler() {
@@ -32,6 +35,26 @@ func (h *cleanupHandler) initInterruptHandler() {
 	}()
 }
 
+func (h *cleanupHandler) initPanicHandler() {
+	err := config.RegisterSession()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "panic handler",
+		}).Errorf("the current session can't be registered: %s", err)
+	}
+
+	h.add(config.UnregisterSession)
+
+	c := make(chan os.Signal, 1)
+
+	signal.Notify(c, config.PanicSignal)
+
+	go func() {
+		<-c
+		h.u.doInUIThread(h.u.panicButton)
+	}()
+}
+
 func (h *cleanupHandler) exitOnInterrupt() {
 	h.u.quit()
 	os.Exit(0)
@@ -46,6 +69,10 @@ func (h *cleanupHandler) doCleanup(cb func()) {
 		}
 	}
 
+	// The callbacks might still write some of these files,
+	// like the Mumble configuration, so they are wiped last
+	config.WipeSensitiveFiles(h.u.config != nil && h.u.config.GetKeepIdentityFiles())
+
 	cb()
 }
 
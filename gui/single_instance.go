@@ -0,0 +1,46 @@
+package gui
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/control"
+)
+
+// ActivateRunningInstance hands the meeting URL that Wahay was opened
+// with to the Wahay already running in this session, and brings it to
+// the front. It returns false when there is none, so this process has
+// to start Wahay on its own
+func ActivateRunningInstance() bool {
+	err := control.ActivateRunningInstance(config.InstanceSocketPath(), config.MeetingURL())
+	if err != nil {
+		log.WithFields(errorFields(err)).Debugf("ActivateRunningInstance(): %s", err)
+		return false
+	}
+
+	log.Info("Wahay is already running, so it has been brought to the front")
+	return true
+}
+
+// startInstanceListener lets the Wahay processes started after this
+// one hand their arguments to it, instead of starting another session
+func (u *gtkUI) startInstanceListener() {
+	s, err := control.ListenInstance(config.InstanceSocketPath(), &instanceActivator{u})
+	if err != nil {
+		log.WithFields(errorFields(err)).Warningf("opening Wahay again will start another session: %s", err)
+		return
+	}
+
+	u.onExit(s.Close)
+}
+
+// instanceActivator is called outside of the UI thread
+type instanceActivator struct {
+	u *gtkUI
+}
+
+func (a *instanceActivator) Activate(meetingURL string) {
+	a.u.doInUIThread(func() {
+		a.u.activatedAgain(meetingURL)
+	})
+}
@@ -0,0 +1,136 @@
+package gui
+
+import (
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/coyim/gotk3adapter/gtki"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// superUserClipboardTime is how long the password of the SuperUser
+// stays in the clipboard after being copied
+const superUserClipboardTime = time.Minute
+
+type superUserWindow struct {
+	h *hostData
+
+	win         gtki.Window
+	lblUsername gtki.Label
+	entPassword gtki.Entry
+	lblStatus   gtki.Label
+}
+
+// showSuperUser opens the window where the host copies the password
+// of the administrator of the meeting, or changes it
+func (h *hostData) showSuperUser() {
+	builder := h.u.g.uiBuilderFor("SuperUserWindow")
+	builder.i18nProperties(
+		"title", "superUserWindow",
+		"label", "lblTitle",
+		"label", "lblText",
+		"label", "lblUsernameTitle",
+		"label", "lblPasswordTitle",
+		"button", "btnCopy",
+		"button", "btnRotate",
+		"button", "btnClose")
+
+	w := &superUserWindow{h: h}
+
+	builder.getItems(
+		"superUserWindow", &w.win,
+		"lblUsername", &w.lblUsername,
+		"entPassword", &w.entPassword,
+		"lblStatus", &w.lblStatus,
+	)
+
+	if h.u.currentWindow != nil {
+		w.win.SetTransientFor(h.u.currentWindow)
+	}
+
+	builder.ConnectSignals(map[string]interface{}{
+		"on_copy":   w.copy,
+		"on_rotate": w.rotate,
+		"on_close":  w.win.Destroy,
+	})
+
+	w.show()
+
+	w.win.Present()
+	w.win.Show()
+}
+
+func (w *superUserWindow) show() {
+	su := w.h.service.SuperUser()
+	w.lblUsername.SetText(su.Username)
+	w.entPassword.SetText(su.Password)
+}
+
+func (w *superUserWindow) copy() {
+	password := w.h.service.SuperUser().Password
+
+	err := w.h.u.copyToClipboard(password)
+	if err != nil {
+		w.h.u.reportError(err.Error())
+		return
+	}
+
+	go w.h.u.messageToLabel(w.lblStatus, i18n.Sprintf("The password has been copied, and it will be removed from the clipboard in a minute"), 5)
+
+	// Nothing else is removed from the clipboard, in
+	// case the user has copied something since then
+	go func() {
+		time.Sleep(superUserClipboardTime)
+		if text, err := clipboard.ReadAll(); err == nil && text == password {
+			_ = w.h.u.copyToClipboard("")
+		}
+	}()
+}
+
+func (w *superUserWindow) rotate() {
+	w.h.u.showConfirmation(func(ok bool) {
+		if ok {
+			w.h.rotateSuperUserPassword(func() {
+				w.show()
+				go w.h.u.messageToLabel(w.lblStatus, i18n.Sprintf("The password has been changed"), 5)
+			})
+		}
+	}, i18n.Sprintf("Nobody will be able to join the meeting as the SuperUser with the current password. "+
+		"The participants that are already connected will not be disconnected."))
+}
+
+// rotateSuperUserPassword gives the SuperUser a new password, which
+// is kept with the meeting room, and then calls onDone in the UI thread
+func (h *hostData) rotateSuperUserPassword(onDone func()) {
+	go func() {
+		password, err := h.service.RotateSuperUserPassword()
+
+		h.u.doInUIThread(func() {
+			if err != nil {
+				log.WithFields(errorFields(err)).Errorf("the password of the SuperUser can't be changed: %s", err)
+				h.u.reportError(i18n.Sprintf("The password can't be changed: %s", err))
+				return
+			}
+
+			log.WithFields(log.Fields{
+				"context": "hosting",
+			}).Info("The password of the SuperUser has been changed")
+
+			h.superUserChanged(password)
+			onDone()
+		})
+	}()
+}
+
+// superUserChanged keeps the new password of the SuperUser, for
+// joining the meeting again and for hosting the room again
+func (h *hostData) superUserChanged(password string) {
+	if h.asSuperUser {
+		h.superUserPassword = password
+	}
+
+	if h.room != nil {
+		h.saveMeetingRoom()
+	}
+}
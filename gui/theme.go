@@ -0,0 +1,148 @@
+package gui
+
+import (
+	"strings"
+
+	"github.com/coyim/gotk3adapter/gtki"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	themeSystem       = ""
+	themeLight        = "light"
+	themeDark         = "dark"
+	themeHighContrast = "high-contrast"
+)
+
+// themeChoices are the themes that can be chosen, in the order they are shown
+var themeChoices = []string{themeSystem, themeLight, themeDark, themeHighContrast}
+
+// theme keeps what is needed to change the colors of the windows
+type theme struct {
+	provider gtki.CssProvider
+
+	// systemPrefersDark is what the desktop says about using
+	// the dark variant of the GTK theme, before we change it
+	systemPrefersDark      bool
+	systemPrefersDarkKnown bool
+}
+
+func themeName(t string) string {
+	switch t {
+	case themeLight:
+		return i18n.Sprintf("Light")
+	case themeDark:
+		return i18n.Sprintf("Dark")
+	case themeHighContrast:
+		return i18n.Sprintf("High contrast")
+	}
+	return i18n.Sprintf("Follow the system")
+}
+
+// themeFromSystem returns the theme that matches the
+// GTK theme that the desktop uses
+func themeFromSystem(gtkThemeName string, preferDark bool) string {
+	name := strings.ToLower(gtkThemeName)
+	switch {
+	case strings.Contains(name, "highcontrast") || strings.Contains(name, "high-contrast"):
+		return themeHighContrast
+	case preferDark || strings.Contains(name, "dark"):
+		return themeDark
+	}
+	return themeLight
+}
+
+// themeStyleSheet returns the name of the style sheet loaded
+// over the bundled one for the given theme
+func themeStyleSheet(t string) string {
+	switch t {
+	case themeDark, themeHighContrast:
+		return t
+	}
+	return ""
+}
+
+// applyTheme changes the colors of all the windows to the
+// configured theme. This must be called from the UI thread
+func (u *gtkUI) applyTheme() {
+	if u.g.gdk == nil || u.config == nil {
+		return
+	}
+
+	settings, _ := u.g.gtk.SettingsGetDefault()
+	u.rememberSystemPrefersDark(settings)
+
+	t := u.config.GetTheme()
+	if t == themeSystem {
+		t = u.systemTheme(settings)
+	}
+
+	css := ""
+	if name := themeStyleSheet(t); name != "" {
+		css = getCSSFileWithFallback(name)
+	}
+
+	if u.theme.provider == nil && css == "" {
+		return
+	}
+
+	if u.theme.provider == nil {
+		prov, err := u.g.gtk.CssProviderNew()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "theme",
+			}).Errorf("The theme can't be changed: %s", err)
+			return
+		}
+
+		// The theme is above the bundled style sheet, which
+		// is added with the priority of the application
+		screen, _ := u.g.gdk.ScreenGetDefault()
+		u.g.gtk.AddProviderForScreen(screen, prov, uint(gtki.STYLE_PROVIDER_PRIORITY_APPLICATION)+1)
+		u.theme.provider = prov
+	}
+
+	err := u.theme.provider.LoadFromData(css)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "theme",
+		}).Errorf("The theme can't be changed: %s", err)
+	}
+
+	// The widgets drawn by the GTK theme, like the scroll
+	// bars, use its dark variant when it has one
+	if settings != nil {
+		preferDark := t == themeDark
+		if u.config.GetTheme() == themeSystem {
+			preferDark = u.theme.systemPrefersDark
+		}
+		_ = settings.SetProperty("gtk-application-prefer-dark-theme", preferDark)
+	}
+}
+
+// rememberSystemPrefersDark keeps what the desktop says about the
+// dark variant of the GTK theme, before the application changes it
+func (u *gtkUI) rememberSystemPrefersDark(settings gtki.Settings) {
+	if settings == nil || u.theme.systemPrefersDarkKnown {
+		return
+	}
+
+	if v, err := settings.GetProperty("gtk-application-prefer-dark-theme"); err == nil {
+		u.theme.systemPrefersDark, _ = v.(bool)
+	}
+	u.theme.systemPrefersDarkKnown = true
+}
+
+// systemTheme returns the theme that matches the one of the desktop
+func (u *gtkUI) systemTheme(settings gtki.Settings) string {
+	if settings == nil {
+		return themeLight
+	}
+
+	name := ""
+	if v, err := settings.GetProperty("gtk-theme-name"); err == nil {
+		name, _ = v.(string)
+	}
+
+	return themeFromSystem(name, u.theme.systemPrefersDark)
+}
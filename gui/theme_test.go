@@ -0,0 +1,23 @@
+package gui
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type WahayGUIThemeSuite struct{}
+
+var _ = Suite(&WahayGUIThemeSuite{})
+
+func (s *WahayGUIThemeSuite) Test_themeFromSystem_followsTheGTKTheme(c *C) {
+	c.Assert(themeFromSystem("Adwaita", false), Equals, themeLight)
+	c.Assert(themeFromSystem("Adwaita", true), Equals, themeDark)
+	c.Assert(themeFromSystem("Adwaita-dark", false), Equals, themeDark)
+	c.Assert(themeFromSystem("HighContrast", false), Equals, themeHighContrast)
+	c.Assert(themeFromSystem("HighContrastInverse", true), Equals, themeHighContrast)
+}
+
+func (s *WahayGUIThemeSuite) Test_themeStyleSheet_isEmptyForTheLightTheme(c *C) {
+	c.Assert(themeStyleSheet(themeLight), Equals, "")
+	c.Assert(themeStyleSheet(themeDark), Equals, "dark")
+	c.Assert(themeStyleSheet(themeHighContrast), Equals, "high-contrast")
+}
@@ -2,20 +2,77 @@ package gui
 
 import (
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/coyim/gotk3adapter/gtki"
+	"github.com/digitalautonomy/wahay/failure"
 	"github.com/digitalautonomy/wahay/tor"
+
+	log "github.com/sirupsen/logrus"
 )
 
 var errTorNoBinary = errors.New("tor can't be used")
 
-func (u *gtkUI) ensureTor(wg *sync.WaitGroup) {
+// torBootstrapState remembers how far the connection to the Tor
+// network went, to be told in the diagnostic bundle
+type torBootstrapState struct {
+	sync.Mutex
+	last     tor.BootstrapProgress
+	started  bool
+	finished bool
+}
+
+func (s *torBootstrapState) update(p tor.BootstrapProgress) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.last = p
+	s.started = true
+}
+
+func (s *torBootstrapState) finish() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.finished = true
+}
+
+func (s *torBootstrapState) String() string {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case !s.started:
+		return "not started"
+	case s.last.Warning != "":
+		return fmt.Sprintf("%d%% (%s), last problem: %s", s.last.Percent, s.last.Phase, s.last.Warning)
+	case s.finished && s.last.Percent == 100:
+		return "connected"
+	}
+
+	return fmt.Sprintf("%d%% (%s)", s.last.Percent, s.last.Phase)
+}
+
+func (u *gtkUI) ensureTor(wg *sync.WaitGroup, onProgress func(tor.BootstrapProgress)) {
+	b := tor.NewBootstrap()
+	u.torBootstrap = b
+
+	go func() {
+		for p := range b.Progress() {
+			u.torProgress.update(p)
+			onProgress(p)
+		}
+		u.torProgress.finish()
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		defer u.torInitialized.Done()
 
-		instance, e := tor.NewInstance(u.config, u.onTorInstanceCreated)
+		instance, e := tor.NewInstanceWithBootstrap(u.config, u.onTorInstanceCreated, b)
 		if e != nil {
 			u.errorHandler.addNewStartupError(e, errGroupTor)
 			return
@@ -30,6 +87,34 @@ func (u *gtkUI) ensureTor(wg *sync.WaitGroup) {
 	}()
 }
 
+// showTorBootstrapProgress shows in the main window how far Tor
+// is in its connection to the network, and the problems it finds
+func (u *gtkUI) showTorBootstrapProgress(builder *uiBuilder, p tor.BootstrapProgress) {
+	lblAppStatus := builder.get("lblApplicationStatus").(gtki.Label)
+
+	if p.Warning != "" {
+		lblAppStatus.SetLabel(i18n.Sprintf("Connecting to Tor: %d%%. Tor found a problem: %s", p.Percent, p.Warning))
+		return
+	}
+
+	lblAppStatus.SetLabel(i18n.Sprintf("Connecting to Tor: %d%% (%s)", p.Percent, p.Summary))
+}
+
+// cancelTorBootstrap stops Tor when it doesn't manage to connect,
+// instead of waiting until it times out
+func (u *gtkUI) cancelTorBootstrap(builder *uiBuilder) {
+	if u.torBootstrap == nil {
+		return
+	}
+
+	log.Debug("Stopping Tor before it's connected to the network")
+
+	btnCancel := builder.get("btnCancelTorBootstrap").(gtki.Button)
+	btnCancel.SetSensitive(false)
+
+	u.torBootstrap.Cancel()
+}
+
 func (u *gtkUI) onTorInstanceCreated(i tor.Instance) {
 	// Tor instance has been successfully created, so we
 	// add a new cleanup callback to destroy the given Tor
@@ -39,11 +124,119 @@ func (u *gtkUI) onTorInstanceCreated(i tor.Instance) {
 
 func (u *gtkUI) waitForTorInstance(f func(tor.Instance)) {
 	go func() {
-		u.torInitialized.Wait()
-		f(u.tor)
+		f(u.torInstance())
 	}()
 }
 
+// torLifecycle keeps the Tor instance warm between meetings. When
+// the user configures an idle timeout, Tor is stopped after that
+// time without meetings and started again the next time it's needed
+type torLifecycle struct {
+	sync.Mutex
+	idleTimer *time.Timer
+	lastUse   int
+	stopped   bool
+	ready     chan bool
+}
+
+// torInstance returns the Tor instance, waiting for it to finish
+// bootstrapping and starting it again if it was stopped while idle
+func (u *gtkUI) torInstance() tor.Instance {
+	u.torInitialized.Wait()
+
+	u.torLifecycle.Lock()
+	u.torLifecycle.lastUse++
+	if u.torLifecycle.idleTimer != nil {
+		u.torLifecycle.idleTimer.Stop()
+		u.torLifecycle.idleTimer = nil
+	}
+
+	if u.torLifecycle.stopped {
+		u.torLifecycle.stopped = false
+		u.torLifecycle.ready = make(chan bool)
+		go u.restartTor(u.torLifecycle.ready)
+	}
+
+	ready := u.torLifecycle.ready
+	u.torLifecycle.Unlock()
+
+	if ready != nil {
+		<-ready
+	}
+
+	return u.tor
+}
+
+func (u *gtkUI) restartTor(ready chan bool) {
+	log.Debug("Starting Tor again after being idle")
+
+	instance, err := tor.NewInstance(u.config, u.onTorInstanceCreated)
+	if err != nil || instance == nil {
+		log.WithFields(log.Fields{
+			"context": "tor",
+		}).Errorf("Tor can't be started again: %v", err)
+	}
+
+	u.torLifecycle.Lock()
+	defer u.torLifecycle.Unlock()
+
+	u.tor = instance
+	u.torLifecycle.stopped = instance == nil
+	u.torLifecycle.ready = nil
+	close(ready)
+}
+
+// scheduleTorIdleShutdown starts counting the time Tor is not used by
+// any meeting. It's called every time the user goes back to the main window
+func (u *gtkUI) scheduleTorIdleShutdown() {
+	minutes := u.config.GetTorIdleTimeout()
+	if minutes <= 0 {
+		return
+	}
+
+	u.torLifecycle.Lock()
+	defer u.torLifecycle.Unlock()
+
+	if u.torLifecycle.stopped || u.torLifecycle.ready != nil {
+		return
+	}
+
+	if u.torLifecycle.idleTimer != nil {
+		u.torLifecycle.idleTimer.Stop()
+	}
+
+	lastUse := u.torLifecycle.lastUse
+	u.torLifecycle.idleTimer = time.AfterFunc(time.Duration(minutes)*time.Minute, func() {
+		u.stopIdleTor(lastUse)
+	})
+}
+
+func (u *gtkUI) stopIdleTor(lastUse int) {
+	// Only the main window is shown when there are no meetings
+	if u.currentWindow != u.mainWindow {
+		return
+	}
+
+	u.torLifecycle.Lock()
+	defer u.torLifecycle.Unlock()
+
+	// Tor has been used again since the timer was started
+	if u.torLifecycle.lastUse != lastUse {
+		return
+	}
+
+	if u.tor == nil || u.torLifecycle.stopped || u.torLifecycle.ready != nil {
+		return
+	}
+
+	log.Debug("Stopping Tor after being idle")
+
+	u.torLifecycle.idleTimer = nil
+	u.torLifecycle.stopped = true
+
+	u.tor.Destroy()
+}
+
 const errGroupTor errGroupType = "tor"
 
 func init() {
@@ -51,7 +244,7 @@ func init() {
 }
 
 func torErrorTranslator(err error) string {
-	switch err {
+	switch failure.Kind(err) {
 	case tor.ErrTorBinaryNotFound:
 		return "ErrTorBinaryNotFound description"
 
@@ -61,6 +254,12 @@ func torErrorTranslator(err error) string {
 	case tor.ErrTorConnectionTimeout:
 		return "ErrTorConnectionTimeout description"
 
+	case tor.ErrTorPortInUse:
+		return i18n.Sprintf("Another application is using a port given for the Tor started by Wahay. Choose other ports in the settings, or let Wahay choose them, and restart Wahay")
+
+	case tor.ErrBootstrapCanceled:
+		return i18n.Sprintf("The connection to the Tor network was stopped. Restart Wahay to try again")
+
 	case tor.ErrPartialTorNoControlPort:
 		return "ErrPartialTorNoControlPort description"
 
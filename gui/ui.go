@@ -13,6 +13,8 @@ import (
 	"github.com/digitalautonomy/wahay/client"
 	"github.com/digitalautonomy/wahay/config"
 	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/scheduler"
+	"github.com/digitalautonomy/wahay/secrets"
 	"github.com/digitalautonomy/wahay/tor"
 )
 
@@ -52,17 +54,59 @@ func argsWithApplicationName() *[]string {
 type gtkUI struct {
 	app            gtki.Application
 	mainWindow     gtki.ApplicationWindow
+	mainBuilder    *uiBuilder
 	currentWindow  gtki.ApplicationWindow
 	loadingWindow  gtki.ApplicationWindow
 	g              Graphics
 	tor            tor.Instance
 	torInitialized *sync.WaitGroup
+	torLifecycle   torLifecycle
+	torBootstrap   *tor.Bootstrap
+	torProgress    torBootstrapState
 	client         client.Instance
+	currentMumble  tor.Service
+	currentAudio   audioDecision
+	currentHost    *hostData
 	keySupplier    config.KeySupplier
+	secrets        secrets.Store
 	config         *config.ApplicationConfig
 	servers        hosting.Servers
+	nextServers    serversPreparation
 	errorHandler   *errorHandler
 	cleanupHandler *cleanupHandler
+	scheduler      *scheduler.Scheduler
+
+	// meetingsEnabled is true once Tor and Mumble are ready to be used
+	meetingsEnabled bool
+
+	// dependenciesReady is true once Tor and Mumble have
+	// been started, even if one of them failed
+	dependenciesReady bool
+
+	// activated is true once the application has been started,
+	// so opening Wahay again only brings it to the front
+	activated bool
+
+	// pendingMeetingURL is the meeting to join once Wahay is ready,
+	// given as argument to this or another Wahay process
+	pendingMeetingURL string
+
+	// firstRun is true when there was no configuration file, so the
+	// user can start with a profile exported in another device
+	firstRun bool
+
+	// keyringPassword is the master password to save in the
+	// keyring, once it's known to open the configuration file
+	keyringPassword string
+
+	// lastMumbleCrash tells when and how Mumble crashed for the last
+	// time, with what it wrote before, for the diagnostic bundle
+	lastMumbleCrash string
+
+	fontScale fontScale
+	theme     theme
+
+	notificationBanner *notificationBanner
 }
 
 // NewGTK returns a new client for a GTK ui
@@ -76,8 +120,9 @@ func NewGTK(gx Graphics) UI {
 	}
 
 	ret := &gtkUI{
-		app: app,
-		g:   gx,
+		app:               app,
+		g:                 gx,
+		pendingMeetingURL: config.MeetingURL(),
 	}
 
 	ret.initTasks()
@@ -93,7 +138,17 @@ func (u *gtkUI) Loop() {
 		fatalf("Couldn't activate application: %v", err)
 	}
 
+	// When Wahay is already running but wasn't listening on the instance
+	// socket yet, this only activates the running application through
+	// D-Bus, and returns without starting anything
 	u.app.Run([]string{})
+
+	// The D-Bus activation doesn't carry the meeting URL, so it's handed
+	// over through the instance socket, where the running Wahay listens
+	// once it has been activated. It's never written anywhere
+	if !u.activated && u.pendingMeetingURL != "" && !ActivateRunningInstance() {
+		log.Warning("The meeting URL couldn't be handed over to the running Wahay")
+	}
 }
 
 func (u *gtkUI) initTasks() {
@@ -112,6 +167,16 @@ func (u *gtkUI) initTasks() {
 }
 
 func (u *gtkUI) onActivate() {
+	if u.activated {
+		u.activatedAgain("")
+		return
+	}
+	u.activated = true
+
+	// Only the Wahay that is activated listens, since any other
+	// one only hands its meeting URL over and exits
+	u.startInstanceListener()
+
 	u.displayLoadingWindowWithCallback(u.quit)
 
 	go u.setGlobalStyles()
@@ -124,17 +189,13 @@ func (u *gtkUI) quit() {
 }
 
 func (u *gtkUI) configLoaded() {
-	u.displayLoadingWindow()
-
+	u.applyLanguage()
 	go u.initLogs()
 
-	go u.ensureDependencies(func() {
-		u.hideLoadingWindow()
-
-		u.doInUIThread(func() {
-			u.createMainWindow()
-		})
-	})
+	u.doInUIThread(u.applyFontScale)
+	u.doInUIThread(u.applyTheme)
+	u.hideLoadingWindow()
+	u.doInUIThread(u.createMainWindow)
 }
 
 func (u *gtkUI) getMainWindowBuilder() *uiBuilder {
@@ -142,7 +203,7 @@ func (u *gtkUI) getMainWindowBuilder() *uiBuilder {
 
 	builder.i18nProperties(
 		"button", "btnStatusShowErrors",
-		"button", "btnErrorsAccept",
+		"button", "btnCancelTorBootstrap",
 		"tooltip", "btnSettings",
 		"tooltip", "btnHelp",
 		"tooltip", "btnJoinMeeting",
@@ -152,7 +213,13 @@ func (u *gtkUI) getMainWindowBuilder() *uiBuilder {
 		"label", "lblHostMeeting",
 		"label", "lblJoinMeeting",
 		"label", "lblSettings",
-		"label", "lblHelp")
+		"label", "lblHelp",
+		"button", "btnSchedule",
+		"tooltip", "btnSchedule",
+		"button", "btnHistory",
+		"tooltip", "btnHistory",
+		"button", "btnPanic",
+		"tooltip", "btnPanic")
 
 	imgHostMeeting := builder.get("imgHostMeeting").(gtki.Image)
 	imgJoinMeeting := builder.get("imgJoinMeeting").(gtki.Image)
@@ -177,6 +244,7 @@ func (u *gtkUI) createMainWindow() {
 	win := builder.get("mainWindow").(gtki.ApplicationWindow)
 	u.currentWindow = win
 	u.mainWindow = win
+	u.mainBuilder = builder
 
 	win.SetApplication(u.app)
 	win.SetIcon(getApplicationIcon().getPixbuf())
@@ -188,20 +256,83 @@ func (u *gtkUI) createMainWindow() {
 		"on_join_meeting":        u.joinMeeting,
 		"on_open_settings":       u.openSettingsWindow,
 		"on_open_help":           u.openHelpWindow,
-		"on_show_errors": func() {
-			u.showStatusErrorsWindow(builder)
-		},
-		"on_close_window_errors": func() {
-			u.closeStatusErrorsWindow()
+		"on_open_schedule":       u.showScheduledMeetings,
+		"on_open_history":        u.showMeetingHistory,
+		"on_panic":               u.panicButton,
+		"on_show_errors":         u.showStatusErrorsWindow,
+		"on_cancel_tor_bootstrap": func() {
+			u.cancelTorBootstrap(builder)
 		},
 	})
 
 	u.connectShortcutsMainWindow(u.currentWindow)
 
-	u.updateMainWindowStatusBar(builder)
-	u.disableMainWindowControls(builder)
+	u.showMainWindowStatus(builder)
+	u.setMainWindowControlsSensitive(builder, false)
+
+	btnCancelTorBootstrap := builder.get("btnCancelTorBootstrap").(gtki.Button)
+	btnCancelTorBootstrap.SetVisible(true)
 
 	win.Show()
+
+	if u.firstRun {
+		u.firstRun = false
+		u.offerProfileImport()
+	}
+
+	// Only the main window is created at startup, so the user can look at
+	// the settings or the help while Tor and Mumble are still loading
+	go u.ensureDependencies(func(p tor.BootstrapProgress) {
+		u.doInUIThread(func() {
+			u.showTorBootstrapProgress(builder, p)
+		})
+	}, func() {
+		u.doInUIThread(func() {
+			u.onDependenciesReady(builder)
+		})
+	})
+}
+
+func (u *gtkUI) onDependenciesReady(builder *uiBuilder) {
+	u.dependenciesReady = true
+
+	btnCancelTorBootstrap := builder.get("btnCancelTorBootstrap").(gtki.Button)
+	btnCancelTorBootstrap.SetVisible(false)
+
+	u.meetingsEnabled = !u.errorHandler.isThereAnyStartupError()
+
+	u.showMainWindowStatus(builder)
+	u.setMainWindowControlsSensitive(builder, u.meetingsEnabled)
+
+	u.scheduleTorIdleShutdown()
+	u.startScheduler()
+	u.startControlInterface()
+	u.joinMeetingFromCommandLine()
+}
+
+// showMainWindowStatus tells in the main window if Wahay is starting,
+// ready or with errors, in the language used now
+func (u *gtkUI) showMainWindowStatus(builder *uiBuilder) {
+	lblAppStatus := builder.get("lblApplicationStatus").(gtki.Label)
+
+	switch {
+	case !u.dependenciesReady:
+		lblAppStatus.SetLabel(i18n.Sprintf("Starting Tor and Mumble..."))
+	case u.errorHandler.isThereAnyStartupError():
+		u.updateMainWindowStatusBar(builder)
+	default:
+		lblAppStatus.SetLabel(i18n.Sprintf("Wahay is ready to use"))
+	}
+}
+
+// retranslateMainWindow shows the main window in the language used now
+func (u *gtkUI) retranslateMainWindow() {
+	if u.mainBuilder == nil {
+		return
+	}
+
+	u.mainBuilder.retranslate()
+	u.showMainWindowStatus(u.mainBuilder)
 }
 
 func (u *gtkUI) updateMainWindowStatusBar(builder *uiBuilder) {
@@ -226,16 +357,14 @@ func (u *gtkUI) updateMainWindowStatusBar(builder *uiBuilder) {
 	btnStatusShow.SetVisible(true)
 }
 
-func (u *gtkUI) disableMainWindowControls(builder *uiBuilder) {
-	if !u.errorHandler.isThereAnyStartupError() {
-		return // nothing to do
-	}
-
+// setMainWindowControlsSensitive changes if the user can host or
+// join meetings, which needs Tor and Mumble working
+func (u *gtkUI) setMainWindowControlsSensitive(builder *uiBuilder, v bool) {
 	btnHostMeeting := builder.get("btnHostMeeting").(gtki.Button)
 	btnJoinMeeting := builder.get("btnJoinMeeting").(gtki.Button)
 
-	btnHostMeeting.SetSensitive(false)
-	btnJoinMeeting.SetSensitive(false)
+	btnHostMeeting.SetSensitive(v)
+	btnJoinMeeting.SetSensitive(v)
 }
 
 func (u *gtkUI) setGlobalStyles() {
@@ -35,6 +35,11 @@ var builderMutex sync.Mutex
 
 type uiBuilder struct {
 	gtki.Builder
+
+	// translated are the properties translated with i18nProperties, with
+	// their text in English, so they can be translated again when the
+	// user changes the language
+	translated []translatedProperty
 }
 
 func (g *Graphics) cssFor(name string) gtki.CssProvider {
@@ -42,7 +47,7 @@ func (g *Graphics) cssFor(name string) gtki.CssProvider {
 }
 
 func (g *Graphics) uiBuilderFor(name string) *uiBuilder {
-	return &uiBuilder{g.builderForDefinition(name)}
+	return &uiBuilder{Builder: g.builderForDefinition(name)}
 }
 
 func readFile(fileName string) string {
@@ -24,104 +24,640 @@ func noPointInEverCallingThisButYouCanIfYouReallyFeelLikeIt1() {
 	// TODO: fix a better solution! Maybe patch gotext to have a flag to change
 	// this behavior or something else.
 
+	_ = i18n.Sprintf("%s can be joined now")
+	_ = i18n.Sprintf("%s can't be hosted")
+	_ = i18n.Sprintf("%s is in the waiting room. Do you want to admit them to the meeting? [y/N] ")
+	_ = i18n.Sprintf("%s is in the waiting room. Do you want to admit them to the meeting? Otherwise, they will be removed from it.")
+	_ = i18n.Sprintf("%s will be removed from the address book.")
+	_ = i18n.Sprintf("%s will be removed from the meeting, and won't be able to join it again for an hour.")
+	_ = i18n.Sprintf("%s will be removed from the meeting.")
+	_ = i18n.Sprintf("a certificate can be generated")
+	_ = i18n.Sprintf("A certificate can be generated")
+	_ = i18n.Sprintf("A meeting room keeps its address and its invitation, so the same invitation works for every meeting in it, like a weekly one. The rooms are saved encrypted with your master password")
+	_ = i18n.Sprintf("A meeting that is forgotten when it finishes")
+	_ = i18n.Sprintf("A new meeting room")
 	_ = i18n.Sprintf("Accept")
+	_ = i18n.Sprintf("Add the meeting to a calendar")
+	_ = i18n.Sprintf("Add to Calendar")
+	_ = i18n.Sprintf("Add to calendar")
+	_ = i18n.Sprintf("Address book")
+	_ = i18n.Sprintf("All the invitations of this meeting were given. Change the number of participants in the settings and start a new meeting to invite more people")
+	_ = i18n.Sprintf("All the meetings will be removed from the history.")
+	_ = i18n.Sprintf("Allow the participants of the meetings you host to exchange files through Tor")
+	_ = i18n.Sprintf("an onion service can be created and reached through Tor")
+	_ = i18n.Sprintf("An onion service can be created and reached through Tor")
+	_ = i18n.Sprintf("Anybody who can use your session of the desktop will be able to open the configuration file")
+	_ = i18n.Sprintf("Anybody with this link and password can download the invitation file using Tor Browser. " +
+		"Share the password using a different channel than the link.")
+	_ = i18n.Sprintf("Advanced: this is not an onion service")
+	_ = i18n.Sprintf("Advanced: this is an existing Mumble server, not a Wahay meeting")
 	_ = i18n.Sprintf("Allow the host to automatically join a newly created meeting")
 	_ = i18n.Sprintf("Are you sure you want to do this action?")
 	_ = i18n.Sprintf("Are you sure you want to end this meeting?")
 	_ = i18n.Sprintf("Are you sure you want to leave this meeting?")
+	_ = i18n.Sprintf("ask the host of the meeting for a new invitation")
+	_ = i18n.Sprintf("Audio quality")
 	_ = i18n.Sprintf("Automatically join this meeting")
 	_ = i18n.Sprintf("Automatically join a meeting")
 	_ = i18n.Sprintf("Automatically join this meeting when starting it")
+	_ = i18n.Sprintf("Ban")
 	_ = i18n.Sprintf("Be very careful. This information is sensitive and could " +
 		"potentially contain very private information. Only turn on these settings if you absolutely need it for debugging.")
 	_ = i18n.Sprintf("Browse")
 	_ = i18n.Sprintf("By clicking Yes, this meeting will end.")
 	_ = i18n.Sprintf("By clicking Yes, you will leave this meeting.")
+	_ = i18n.Sprintf("By default, Wahay measures the latency of the connection to the meeting when you join and chooses the audio quality that works better with it")
 	_ = i18n.Sprintf("Cancel")
+	_ = i18n.Sprintf("Caps Lock")
+	_ = i18n.Sprintf("Certificate key")
+	_ = i18n.Sprintf("check that the keyring is unlocked")
+	_ = i18n.Sprintf("check that Tor is running with its control port enabled")
+	_ = i18n.Sprintf("Check that Wahay works in this computer")
+	_ = i18n.Sprintf("check the authentication configured for the Tor control port")
+	_ = i18n.Sprintf("check the certificate location given by the host")
+	_ = i18n.Sprintf("check the connection of Tor to the network")
+	_ = i18n.Sprintf("check the key of the transcript")
+	_ = i18n.Sprintf("check the location of Mumble in the settings")
+	_ = i18n.Sprintf("check the location of Tor in the settings")
+	_ = i18n.Sprintf("check the meeting ID, the meeting might have finished")
+	_ = i18n.Sprintf("check the password of the configuration")
+	_ = i18n.Sprintf("check the password used when the identity was exported")
+	_ = i18n.Sprintf("check the port mappings in the settings")
+	_ = i18n.Sprintf("check the port of the meeting in the settings")
+	_ = i18n.Sprintf("check the webhook in the settings")
+	_ = i18n.Sprintf("check your connection to the Internet, or install Tor in the system")
+	_ = i18n.Sprintf("check your connection to the Tor network")
+	_ = i18n.Sprintf("Checking that Wahay can work in this computer...")
+	_ = i18n.Sprintf("Checking...")
+	_ = i18n.Sprintf("Checks that Tor, the onion services and Mumble work in this computer, and tells which one fails. The same checks are done by starting Wahay with --self-test.")
+	_ = i18n.Sprintf("choose a different password for the moderators")
+	_ = i18n.Sprintf("choose another audio quality in the settings")
+	_ = i18n.Sprintf("choose another certificate key in the settings")
+	_ = i18n.Sprintf("choose another invitation sender in the settings")
+	_ = i18n.Sprintf("choose another local port in the settings")
+	_ = i18n.Sprintf("choose another name for the breakout room")
+	_ = i18n.Sprintf("choose another password for the moderators")
+	_ = i18n.Sprintf("choose how long the participant will be banned")
+	_ = i18n.Sprintf("Choose how Wahay can deliver your invitations directly to the participants")
+	_ = i18n.Sprintf("Choose the breakout room you want to remove")
+	_ = i18n.Sprintf("Choose the meeting you want to remove from the address book")
+	_ = i18n.Sprintf("Clear history")
 	_ = i18n.Sprintf("Client binary location")
+	_ = i18n.Sprintf("close the other Wahay session")
+	_ = i18n.Sprintf("Close")
+	_ = i18n.Sprintf("Command")
+	_ = i18n.Sprintf("compare the fingerprint with the host of the meeting")
+	_ = i18n.Sprintf("complete the invitation sender in the settings")
 	_ = i18n.Sprintf("Configuration settings will be lost in the next session")
 	_ = i18n.Sprintf("Configure master password")
 }
 
 func noPointInEverCallingThisButYouCanIfYouReallyFeelLikeIt2() {
+	_ = i18n.Sprintf("%s (not connected)")
+	_ = i18n.Sprintf("%s (only to you)")
+	_ = i18n.Sprintf("%s has joined the meeting")
+	_ = i18n.Sprintf("%s has left the meeting")
+	_ = i18n.Sprintf("%s of the voice lost or late")
+	_ = i18n.Sprintf("%s: %d ms, %s")
+	_ = i18n.Sprintf("[%s] %s: %s")
+	_ = i18n.Sprintf("A participant joins the meeting")
+	_ = i18n.Sprintf("A participant leaves the meeting")
+	_ = i18n.Sprintf("a password is required to protect the profile")
+	_ = i18n.Sprintf("a password is required to protect the recording")
+	_ = i18n.Sprintf("Administrator of the meeting")
+	_ = i18n.Sprintf("Administrator")
+	_ = i18n.Sprintf("Another application is using a port given for the Tor started by Wahay. Choose other ports in the settings, or let Wahay choose them, and restart Wahay")
+	_ = i18n.Sprintf("Bandwidth of every participant, in kbit/s")
+	_ = i18n.Sprintf("By default, Wahay measures the latency of the connection to the meeting when you join and chooses the audio quality that works better with it. The low bandwidth quality also limits the meetings you host, so every participant uses less of their connection")
+	_ = i18n.Sprintf("Change the password")
+	_ = i18n.Sprintf("Chat of this meeting")
+	_ = i18n.Sprintf("Chat")
+	_ = i18n.Sprintf("Check audio")
+	_ = i18n.Sprintf("Check the microphone and speakers")
+	_ = i18n.Sprintf("Co-moderator")
 	_ = i18n.Sprintf("Confirmation")
+	_ = i18n.Sprintf("Connect to the meeting through new Tor circuits, when the audio is slow or breaks")
+	_ = i18n.Sprintf("Connected to the meeting again")
+	_ = i18n.Sprintf("Connecting to the meeting again...")
+	_ = i18n.Sprintf("Connecting to Tor: %d%% (%s)")
+	_ = i18n.Sprintf("Connecting to Tor: %d%%. Tor found a problem: %s")
 	_ = i18n.Sprintf("Connecting, please wait...")
+	_ = i18n.Sprintf("Connection statistics")
 	_ = i18n.Sprintf("Continue")
+	_ = i18n.Sprintf("Control port - chosen by Wahay when empty")
+	_ = i18n.Sprintf("Control port of a running Tor")
+	_ = i18n.Sprintf("Copy invitation")
 	_ = i18n.Sprintf("Copy Invitation")
+	_ = i18n.Sprintf("Copy link and password")
 	_ = i18n.Sprintf("Copy Meeting ID")
+	_ = i18n.Sprintf("copy the complete invitation, as given by the host")
+	_ = i18n.Sprintf("copy the complete line, as given by the Tor Project")
+	_ = i18n.Sprintf("Copy the password")
 	_ = i18n.Sprintf("Copy URL")
+	_ = i18n.Sprintf("Copy words")
+	_ = i18n.Sprintf("Create diagnostic bundle")
+	_ = i18n.Sprintf("Create room")
+	_ = i18n.Sprintf("Creates a file you can attach to a bug report. It contains the version of Wahay, details about your system, the configuration without passwords and the logs. Onion addresses, fingerprints and usernames are removed from it.")
+	_ = i18n.Sprintf("Dark")
+	_ = i18n.Sprintf("Date")
 	_ = i18n.Sprintf("Check this option to automatically join every meeting you host")
 	_ = i18n.Sprintf("Choose your email service to send invitation")
+	_ = i18n.Sprintf("Deafen")
+	_ = i18n.Sprintf("Deafened by the host")
+	_ = i18n.Sprintf("Deafened")
+	_ = i18n.Sprintf("Debugging information")
 	_ = i18n.Sprintf("Debugging")
+	_ = i18n.Sprintf("Decrypt a recording")
+	_ = i18n.Sprintf("Decrypt it in the settings to listen to it.")
+	_ = i18n.Sprintf("Decrypting the recording...")
+	_ = i18n.Sprintf("Default device")
+	_ = i18n.Sprintf("Default")
 	_ = i18n.Sprintf("Default Email")
+	_ = i18n.Sprintf("Diagnostic bundle")
+	_ = i18n.Sprintf("Dismiss")
+	_ = i18n.Sprintf("Do you want to import a profile that you have exported from Wahay in another device?")
+	_ = i18n.Sprintf("Download")
+	_ = i18n.Sprintf("Duration in minutes")
+	_ = i18n.Sprintf("Duration of the meeting")
+	_ = i18n.Sprintf("Email server")
 	_ = i18n.Sprintf("Encrypt the configuration file")
+	_ = i18n.Sprintf("Enter a name for the meeting room")
+	_ = i18n.Sprintf("Enter a password to encrypt the recording. You will need it to listen to the recording.")
+	_ = i18n.Sprintf("Enter a password to protect the profile. You will need it to import the profile in another device.")
+	_ = i18n.Sprintf("Enter the passphrase shared with the invitation")
+	_ = i18n.Sprintf("Enter the password used when the meeting was recorded")
+	_ = i18n.Sprintf("Enter the password used when the profile was exported")
+	_ = i18n.Sprintf("Enter who should receive the invitation")
 	_ = i18n.Sprintf("Error")
+	_ = i18n.Sprintf("Every invitation lets only one participant reach the meeting, even if somebody else finds its address. Leave it blank to let anybody with the invitation reach it")
+	_ = i18n.Sprintf("Every participant will be able to mute and deafen the others, not only the super user and the moderators")
+	_ = i18n.Sprintf("Every participant will be able to remove the others from the meeting, not only the super user and the moderators")
+	_ = i18n.Sprintf("Everybody in the meeting can download these files. They are kept by the host only while the meeting is running.")
+	_ = i18n.Sprintf("Everybody in the meeting will be told that it's being recorded before the recording starts, and the participants that join later will be told when they arrive. Mumble also shows it next to Wahay. Only record the meeting if the participants agree to it.")
+	_ = i18n.Sprintf("Everybody who joins the meeting reads it first, so it's a good place for the agenda. Write **bold** or *italic* text, and the addresses become links. Leave it blank for the default message.")
+	_ = i18n.Sprintf("Everything works")
+	_ = i18n.Sprintf("Everything works in this computer.")
+	_ = i18n.Sprintf("Everything, which is a lot")
+	_ = i18n.Sprintf("Ex. /usr/local/bin/send-invitation")
+	_ = i18n.Sprintf("Ex. 127.0.0.1:9051")
+	_ = i18n.Sprintf("Ex. 20")
+	_ = i18n.Sprintf("Ex. 30")
+	_ = i18n.Sprintf("Ex. 40")
+	_ = i18n.Sprintf("Ex. 5")
+	_ = i18n.Sprintf("Ex. 64738")
+	_ = i18n.Sprintf("Ex. https://example.org/hooks/wahay")
+	_ = i18n.Sprintf("Ex. me@example.org")
+	_ = i18n.Sprintf("Ex. smtp.example.org:587")
+	_ = i18n.Sprintf("Exchange files with the participants of this meeting")
+	_ = i18n.Sprintf("Export a transcript to Markdown")
+	_ = i18n.Sprintf("Export identity")
+	_ = i18n.Sprintf("Export profile")
+	_ = i18n.Sprintf("Exporting the profile...")
+	_ = i18n.Sprintf("Failed: %s")
+	_ = i18n.Sprintf("Files shared in this meeting")
+	_ = i18n.Sprintf("Fill in a meeting you have saved")
+	_ = i18n.Sprintf("Fingerprint: %s")
 	_ = i18n.Sprintf("Finish")
 	_ = i18n.Sprintf("End this meeting")
 	_ = i18n.Sprintf("End this meeting for all")
+	_ = i18n.Sprintf("Follow the system")
 	_ = i18n.Sprintf("General")
+	_ = i18n.Sprintf("generate a new identity in the settings")
+	_ = i18n.Sprintf("Generating the address and the invitation of the meeting...")
+	_ = i18n.Sprintf("get bridges from https://bridges.torproject.org using a different network and paste them")
+	_ = i18n.Sprintf("Get built-in bridges")
 	_ = i18n.Sprintf("Gmail")
+	_ = i18n.Sprintf("High contrast")
+	_ = i18n.Sprintf("History")
+	_ = i18n.Sprintf("Host")
 	_ = i18n.Sprintf("Host a new meeting")
+	_ = i18n.Sprintf("Hosted")
+	_ = i18n.Sprintf("Hosting %s")
 	_ = i18n.Sprintf("Hosting")
 	_ = i18n.Sprintf("Host meeting")
+	_ = i18n.Sprintf("How much Wahay tells about what it does. The onion addresses, fingerprints and usernames are always removed from the logs, unless Wahay is started with --log-unredacted. The level given with --debug or --trace in the command line is used instead of this one.")
+	_ = i18n.Sprintf("Identity")
+	_ = i18n.Sprintf("If you heard yourself, these devices are ready for the meeting. If you didn't, choose another output device.")
+	_ = i18n.Sprintf("Immediately close everything and exit (Ctrl+Shift+Delete)")
 	_ = i18n.Sprintf("If you backup the configuration file, we will reset " +
 		"the settings and continue normally. If the configuration file is encrypted, then we will ask you " +
 		"for a password to encrypt the new settings file.")
 	_ = i18n.Sprintf("If you set this option to a file name, low level information will be logged there.")
+	_ = i18n.Sprintf("Import identity")
+	_ = i18n.Sprintf("Import profile")
+	_ = i18n.Sprintf("Importing the profile...")
+	_ = i18n.Sprintf("Include the certificate in my invitations")
+	_ = i18n.Sprintf("Informative messages (default)")
+	_ = i18n.Sprintf("Input device")
+	_ = i18n.Sprintf("Insert")
+	_ = i18n.Sprintf("install a newer version of Tor")
+	_ = i18n.Sprintf("install Mumble or set its location in the settings")
+	_ = i18n.Sprintf("install obfs4proxy or lyrebird for obfs4 and meek bridges, and snowflake-client for snowflake bridges")
+	_ = i18n.Sprintf("install secret-tool and a keyring like GNOME Keyring or KWallet")
+	_ = i18n.Sprintf("install Tor 0.4.6 or newer")
+	_ = i18n.Sprintf("install Tor in the system")
+	_ = i18n.Sprintf("install torsocks in the system")
 	_ = i18n.Sprintf("Invalid configuration file")
 	_ = i18n.Sprintf("Invalid password. Please, try again.")
+	_ = i18n.Sprintf("Invitation passphrase")
+	_ = i18n.Sprintf("Invitation QR code")
+	_ = i18n.Sprintf("Invitation: %s")
+	_ = i18n.Sprintf("Invitations")
+	_ = i18n.Sprintf("Invitations, one for every participant:")
 	_ = i18n.Sprintf("Invite others")
+	_ = i18n.Sprintf("Join again the meetings you have been in")
+	_ = i18n.Sprintf("Join again")
 	_ = i18n.Sprintf("Join")
 	_ = i18n.Sprintf("Join a meeting")
+	_ = i18n.Sprintf("Join a Mumble server that your group runs behind its own onion service. " +
+		"Wahay will take care of Tor and of the certificate of the server")
 	_ = i18n.Sprintf("Join meeting")
+	_ = i18n.Sprintf("Join an existing Mumble server on the Internet. The connection still goes through Tor, " +
+		"but the server is not protected as an onion service")
 	_ = i18n.Sprintf("Join the meeting")
 	_ = i18n.Sprintf("Join this meeting")
+	_ = i18n.Sprintf("Joined")
+	_ = i18n.Sprintf("Keep a history of the meetings")
+	_ = i18n.Sprintf("Keep an encrypted transcript of the chat of the meetings I host")
 	_ = i18n.Sprintf("Keep configuration file when Wahay closes")
+	_ = i18n.Sprintf("Keep the address for hosting it again as a meeting room")
+	_ = i18n.Sprintf("Keep the secrets in the keyring of the system")
+	_ = i18n.Sprintf("Key code %s")
+	_ = i18n.Sprintf("Kind")
+	_ = i18n.Sprintf("Language of the system")
+	_ = i18n.Sprintf("Language")
+	_ = i18n.Sprintf("Last time")
 	_ = i18n.Sprintf("Leave")
 	_ = i18n.Sprintf("Leave this meeting")
+	_ = i18n.Sprintf("Left Alt")
+	_ = i18n.Sprintf("Left Ctrl")
+	_ = i18n.Sprintf("Left Shift")
+	_ = i18n.Sprintf("Let the participant mute, move and remove the others, or take it back")
+	_ = i18n.Sprintf("Light")
+	_ = i18n.Sprintf("Link")
+	_ = i18n.Sprintf("Local port of the Mumble server")
+	_ = i18n.Sprintf("Location of the server certificate, for example /cert.pem")
 	_ = i18n.Sprintf("Log debug info")
 	_ = i18n.Sprintf("Log debug output to the selected log file. If no file is " +
 		"selected then the log output will be written to the default log file.")
+	_ = i18n.Sprintf("Log level")
+	_ = i18n.Sprintf("Low bandwidth")
+	_ = i18n.Sprintf("Low latency")
+	_ = i18n.Sprintf("Main meeting")
+	_ = i18n.Sprintf("make sure you are in a meeting")
 	_ = i18n.Sprintf("Master password")
+	_ = i18n.Sprintf("Measuring the connection...")
+	_ = i18n.Sprintf("Medium")
+	_ = i18n.Sprintf("Meeting")
+	_ = i18n.Sprintf("Meeting history")
 	_ = i18n.Sprintf("Meeting ID")
+	_ = i18n.Sprintf("Meeting room: %s")
+	_ = i18n.Sprintf("Meeting traces")
+	_ = i18n.Sprintf("Menu")
+	_ = i18n.Sprintf("Minutes before removing idle participants")
+	_ = i18n.Sprintf("Minutes before the start to host it")
+	_ = i18n.Sprintf("Moderator password")
+	_ = i18n.Sprintf("Move there")
+	_ = i18n.Sprintf("Mumble is found")
+	_ = i18n.Sprintf("Mumble lowers the quality of the audio so that every participant uses less than this, which helps when the meetings go through slow Tor circuits. Leave it blank to only use the limit of the audio quality")
+	_ = i18n.Sprintf("Mumble stopped unexpectedly")
+	_ = i18n.Sprintf("Mumble stopped unexpectedly too many times, so it has not been started again")
+	_ = i18n.Sprintf("Mumble stopped unexpectedly. Connecting to the meeting again in %s...")
+	_ = i18n.Sprintf("Mumble stopped unexpectedly. Do you want to restart it and join the meeting again?")
+	_ = i18n.Sprintf("Mute")
+	_ = i18n.Sprintf("Muted")
+	_ = i18n.Sprintf("Muted by the host")
+	_ = i18n.Sprintf("Name for saving the meeting in the address book")
+	_ = i18n.Sprintf("Name of the breakout room")
+	_ = i18n.Sprintf("Name of the new meeting room")
+	_ = i18n.Sprintf("Name")
+	_ = i18n.Sprintf("New name of the meeting")
+	_ = i18n.Sprintf("no QR code was found in the image")
+	_ = i18n.Sprintf("no voice statistics")
+	_ = i18n.Sprintf("Nobody has joined the meeting yet")
+	_ = i18n.Sprintf("Nobody will be able to join the meeting as the SuperUser with the current password. The participants that are already connected will not be disconnected.")
+	_ = i18n.Sprintf("Noise suppression")
+	_ = i18n.Sprintf("Normal")
+	_ = i18n.Sprintf("Nothing was heard from the microphone. Check that it's not muted, or choose another input device.")
+	_ = i18n.Sprintf("Notification")
+	_ = i18n.Sprintf("Notifications")
+	_ = i18n.Sprintf("Only I share files in my meetings")
+	_ = i18n.Sprintf("Only let Tor reach the certificate server")
+	_ = i18n.Sprintf("Only valid onion meeting IDs can be saved in the address book: %s")
+	_ = i18n.Sprintf("Only warnings and errors")
+	_ = i18n.Sprintf("Open an image with the QR code of an invitation, like a screenshot")
+	_ = i18n.Sprintf("Open QR code image")
+	_ = i18n.Sprintf("Optional")
+	_ = i18n.Sprintf("Optional, for the participants who moderate the meeting")
+	_ = i18n.Sprintf("Optional, in minutes, to end the meeting automatically")
+	_ = i18n.Sprintf("Other ports of the meetings")
+	_ = i18n.Sprintf("Output device")
+	_ = i18n.Sprintf("Participants of this meeting")
+	_ = i18n.Sprintf("Participants who can be in a meeting at once")
+	_ = i18n.Sprintf("Participants")
+	_ = i18n.Sprintf("Participants can mute others")
+	_ = i18n.Sprintf("Participants can remove others")
+	_ = i18n.Sprintf("Participants wait until they are admitted")
+	_ = i18n.Sprintf("Participants who can reach the meetings")
+	_ = i18n.Sprintf("Passed (%s)")
+	_ = i18n.Sprintf("Password: %s")
+	_ = i18n.Sprintf("Pause")
+	_ = i18n.Sprintf("Plan meetings, and give their invitations in advance")
+	_ = i18n.Sprintf("Playing the recording...")
+	_ = i18n.Sprintf("Ports of the Tor started by Wahay")
+	_ = i18n.Sprintf("Profile")
+	_ = i18n.Sprintf("Push to talk key")
+	_ = i18n.Sprintf("QR Code")
+	_ = i18n.Sprintf("Read Aloud")
+	_ = i18n.Sprintf("Read the invitation aloud")
+	_ = i18n.Sprintf("Read the invitation over the phone")
+	_ = i18n.Sprintf("Read these words to the participant. They can type them in the Meeting ID field of the join screen, and Wahay will check that no word was misheard.")
+	_ = i18n.Sprintf("Recent logs")
+	_ = i18n.Sprintf("Recipient")
+	_ = i18n.Sprintf("Record a few seconds from the microphone and play them in the speakers, before joining the meeting")
+	_ = i18n.Sprintf("Record and play")
+	_ = i18n.Sprintf("Record the meeting")
+	_ = i18n.Sprintf("Recordings")
+	_ = i18n.Sprintf("Refresh")
+	_ = i18n.Sprintf("Remember the password in the keyring of the system")
+	_ = i18n.Sprintf("remove the meeting room and create it again")
+	_ = i18n.Sprintf("remove the participant instead")
+	_ = i18n.Sprintf("Remove")
+	_ = i18n.Sprintf("Remove room")
+	_ = i18n.Sprintf("Rename")
+	_ = i18n.Sprintf("Renew connection")
+	_ = i18n.Sprintf("Result")
+	_ = i18n.Sprintf("Right Alt")
+	_ = i18n.Sprintf("Right Ctrl")
+	_ = i18n.Sprintf("Right Shift")
+	_ = i18n.Sprintf("Round trip to the meeting: %d ms")
+	_ = i18n.Sprintf("Run again")
+	_ = i18n.Sprintf("Run the self-test")
+	_ = i18n.Sprintf("Save")
+	_ = i18n.Sprintf("Save recent logs")
+	_ = i18n.Sprintf("Say something, the microphone is being recorded...")
+	_ = i18n.Sprintf("Scan QR code")
+	_ = i18n.Sprintf("Scan the invitation with another device")
+	_ = i18n.Sprintf("Schedule")
+	_ = i18n.Sprintf("Schedule a new meeting")
+	_ = i18n.Sprintf("Scheduled meetings")
+	_ = i18n.Sprintf("Scroll Lock")
+	_ = i18n.Sprintf("Select the file you want to stop sharing")
+	_ = i18n.Sprintf("Select the meeting whose invitation you want to copy")
+	_ = i18n.Sprintf("Select the meeting you want to add to a calendar")
+	_ = i18n.Sprintf("Select the meeting you want to join again")
+	_ = i18n.Sprintf("Select the meeting you want to remove")
+	_ = i18n.Sprintf("Select the meeting you want to rename")
+	_ = i18n.Sprintf("Select the participant you want to ban")
+	_ = i18n.Sprintf("Select the participant you want to deafen")
+	_ = i18n.Sprintf("Select the participant you want to make a co-moderator")
+	_ = i18n.Sprintf("Select the participant you want to move")
+	_ = i18n.Sprintf("Select the participant you want to mute")
+	_ = i18n.Sprintf("Select the participant you want to remove")
+	_ = i18n.Sprintf("Self-test")
+	_ = i18n.Sprintf("Send invitation")
+	_ = i18n.Sprintf("Send invitations with")
+	_ = i18n.Sprintf("Send the invitation")
+	_ = i18n.Sprintf("Send the invitation with the channel configured in the settings")
+	_ = i18n.Sprintf("Send")
+	_ = i18n.Sprintf("Send Invitation")
+	_ = i18n.Sprintf("Sender address")
+	_ = i18n.Sprintf("Sent: %s · Received: %s")
+	_ = i18n.Sprintf("Share a file")
+	_ = i18n.Sprintf("Share files in my meetings")
+	_ = i18n.Sprintf("Shared files")
+	_ = i18n.Sprintf("Show the invitation as a list of words that can be read over the phone")
+	_ = i18n.Sprintf("Show the invitation as a QR code that can be scanned by another device")
+	_ = i18n.Sprintf("Show this code to the camera of the other device, or take a screenshot of it. In Wahay, open the image with the Scan QR code button of the join screen.")
+	_ = i18n.Sprintf("Size")
+	_ = i18n.Sprintf("Skipped")
+	_ = i18n.Sprintf("SOCKS port - chosen by Wahay when empty")
+	_ = i18n.Sprintf("Some of the steps failed. The diagnostic bundle can be attached to a bug report to find out why.")
+	_ = i18n.Sprintf("Something went wrong: %s")
+	_ = i18n.Sprintf("Start")
+	_ = i18n.Sprintf("Status")
+	_ = i18n.Sprintf("Step")
+	_ = i18n.Sprintf("Stop connecting to Tor")
+	_ = i18n.Sprintf("Stop recording")
+	_ = i18n.Sprintf("Stop Tor after being idle for (minutes)")
+	_ = i18n.Sprintf("Talking")
+	_ = i18n.Sprintf("Text size")
+	_ = i18n.Sprintf("The address book is full. Remove some of the meetings in it first.")
+	_ = i18n.Sprintf("The audio devices can't be checked, since neither pacat nor pw-cat are installed. Install the utilities of PulseAudio or PipeWire.")
+	_ = i18n.Sprintf("The bandwidth must be a number between 8 and 1000, or blank")
+	_ = i18n.Sprintf("The breakout room can't be created: %s")
+	_ = i18n.Sprintf("The breakout room can't be removed: %s")
+	_ = i18n.Sprintf("The bridges are not valid")
+	_ = i18n.Sprintf("The bridges are not valid: %s")
+	_ = i18n.Sprintf("The built-in bridges can't be requested: %s")
+	_ = i18n.Sprintf("The calendar entry can't be created: %s")
+	_ = i18n.Sprintf("The calendar entry has been saved")
+	_ = i18n.Sprintf("The certificate of a meeting has changed")
+	_ = i18n.Sprintf("The certificate of the meeting has changed")
+	_ = i18n.Sprintf("The certificate server doesn't listen on a local port that other programs of this computer could use")
+	_ = i18n.Sprintf("The certificate server listens on a file that only Tor can open. This only works when Wahay runs its own Tor, since the Tor of the system can't open the files of Wahay")
+	_ = i18n.Sprintf("The colors of the windows of Wahay. When following the system, the dark and high contrast themes are used if the desktop uses them.")
+	_ = i18n.Sprintf("The command receives the recipient as its last argument and the invitation in its standard input. Make sure the command uses Tor for its connections")
+	_ = i18n.Sprintf("The configuration of the invitation sender is not complete")
+	_ = i18n.Sprintf("The configuration, the certificates and the data Mumble keeps about the meeting are created in a temporary directory, which is overwritten and removed when the meeting is closed")
+	_ = i18n.Sprintf("The connection through Tor becomes slow")
+	_ = i18n.Sprintf("The connection to the meeting can't be renewed: %s")
+	_ = i18n.Sprintf("The connection to the meeting is slow")
+	_ = i18n.Sprintf("The connection to the meeting was lost. Reconnecting...")
+	_ = i18n.Sprintf("The connection to the meeting was lost. Trying again in %s (attempt %d)...")
+	_ = i18n.Sprintf("The connection to the Tor network was stopped. Restart Wahay to try again")
+	_ = i18n.Sprintf("The connection to this meeting can't be measured")
+	_ = i18n.Sprintf("The control port must be a host and a port, like 127.0.0.1:9051")
+	_ = i18n.Sprintf("The devices are the ones PipeWire or PulseAudio have. A device that is not connected is kept until another one is chosen, and the default device is the one your system uses")
+	_ = i18n.Sprintf("The file can't be removed: %s")
+	_ = i18n.Sprintf("the file is not an image")
+	_ = i18n.Sprintf("The files are kept in memory by your computer and are deleted when the meeting finishes")
+	_ = i18n.Sprintf("The history of the meetings is disabled. You can enable it in the settings.")
+	_ = i18n.Sprintf("The history of the meetings is only kept when the configuration is saved encrypted with a master password.")
+	_ = i18n.Sprintf("The host has ended the meeting. Thank you for joining it.")
+	_ = i18n.Sprintf("The host is recording this meeting. If you don't agree, tell the host or leave the meeting.")
+	_ = i18n.Sprintf("The idle time must be a number of minutes between 0 and 1440")
+	_ = i18n.Sprintf("The input device can't be recorded. Check the name of the input device.")
+	_ = i18n.Sprintf("The invitation can't be copied: %s")
+	_ = i18n.Sprintf("The invitation has been copied to the clipboard")
+	_ = i18n.Sprintf("The invitation QR code can't be generated: %s")
+	_ = i18n.Sprintf("The invitation QR code can't be read: %s")
+	_ = i18n.Sprintf("The language of Wahay and of Mumble. The language given with --language in the command line is used instead of this one.")
+	_ = i18n.Sprintf("The list is updated while the participants arrive, talk and leave. Select a participant to mute, deafen, remove or ban them, or to move them to a breakout room.")
+	_ = i18n.Sprintf("The meeting %s will be removed, and its invitation will not work anymore.")
+	_ = i18n.Sprintf("The meeting can't be joined again: %s")
+	_ = i18n.Sprintf("The meeting can't be recorded: %s")
+	_ = i18n.Sprintf("The meeting can't be scheduled: %s")
+	_ = i18n.Sprintf("The meeting doesn't need to publish a certificate server, but the invitations are longer and can't be given as a list of words")
+	_ = i18n.Sprintf("The meeting has been scheduled, and its invitation can be given now")
+	_ = i18n.Sprintf("The meeting has to start in the future")
+	_ = i18n.Sprintf("The meeting in %s has a different certificate than the last time you joined it. Only trust it if the host of the meeting confirms the new fingerprint.")
+	_ = i18n.Sprintf("the meeting is already being recorded")
+	_ = i18n.Sprintf("The meeting room %s doesn't exist anymore")
+	_ = i18n.Sprintf("The meeting room %s will be removed, and its invitation will not work anymore. Anybody who has it will need a new invitation.")
+	_ = i18n.Sprintf("The meeting starts at %s, and Wahay is preparing it now.")
+	_ = i18n.Sprintf("The meetings are published through Tor, so this port is only used in this computer. Leave it blank to use any free port")
+	_ = i18n.Sprintf("The meetings can only be scheduled when the configuration is saved encrypted with a master password, since their keys are kept in it.")
+	_ = i18n.Sprintf("The meetings you have been in. Join one again by choosing it, or host again the meeting rooms you have hosted.")
+	_ = i18n.Sprintf("The meetings you join are remembered, so you can join them again with one click. The history tells where you have been, so it's only kept when the configuration is encrypted with a master password, and it's removed when this option is disabled")
+	_ = i18n.Sprintf("The message can't be sent: %s")
+	_ = i18n.Sprintf("The messages are the text messages of Mumble, so everybody in the meeting reads what you write here, and you read what they write in the channel of the meeting. Nothing is kept after the meeting finishes.")
+	_ = i18n.Sprintf("The messages written in the chat of the meetings you host are kept in your computer, encrypted, and you can export them to Markdown. The transcripts tell what was said in the meetings, so they are only recorded when the configuration is encrypted with a master password, or its secrets are in the keyring")
+	_ = i18n.Sprintf("The minutes must be a number between 0 and 1440")
+	_ = i18n.Sprintf("The moderator password must be different from the meeting password")
+	_ = i18n.Sprintf("The moderators join with the meeting password, and add the moderator password in the Access Tokens of the server in Mumble. Then they can mute, move and remove the other participants.")
+	_ = i18n.Sprintf("The most recent messages are kept in memory, even when the logs are not written to a file. Nothing is written to the disk unless you save them.")
+	_ = i18n.Sprintf("The participant can't be banned: %s")
+	_ = i18n.Sprintf("The participant can't be deafened: %s")
+	_ = i18n.Sprintf("The participant can't be moved: %s")
+	_ = i18n.Sprintf("The participant can't be muted: %s")
+	_ = i18n.Sprintf("The participant can't be removed: %s")
+	_ = i18n.Sprintf("The participants are told five minutes before the meeting ends. Leave it blank to end the meeting yourself.")
+	_ = i18n.Sprintf("The participants arrive to a waiting room, where they can't hear or talk in the meeting, until you admit them")
+	_ = i18n.Sprintf("The participants can download the files you share, but they can't share their own files")
+	_ = i18n.Sprintf("The participants can join the meeting with its invitation.")
+	_ = i18n.Sprintf("The participants get the certificate of the meeting from the invitation instead of requesting it")
+	_ = i18n.Sprintf("The participants must be a number between 0 and 100")
+	_ = i18n.Sprintf("The participants must be a number between 0 and 1000")
+	_ = i18n.Sprintf("The participants who don't talk, write or change anything in this time are removed from the meeting. The co-moderators, the SuperUser and the participants in the waiting room are never removed. Leave it blank to keep everybody")
+	_ = i18n.Sprintf("The password can't be changed: %s")
+	_ = i18n.Sprintf("The password has been changed")
+	_ = i18n.Sprintf("The password has been copied, and it will be removed from the clipboard in a minute")
+	_ = i18n.Sprintf("The password of the control port, if it has one")
+	_ = i18n.Sprintf("The port mappings are not valid")
+	_ = i18n.Sprintf("The port mappings are not valid: %s")
+	_ = i18n.Sprintf("The port must be a number between 1 and 65535")
+	_ = i18n.Sprintf("The ports must be different numbers between 1 and 65535")
+	_ = i18n.Sprintf("The privileges of the participant can't be changed: %s")
+	_ = i18n.Sprintf("The profile can't be exported: %s")
+	_ = i18n.Sprintf("The profile can't be imported: %s")
+	_ = i18n.Sprintf("the profile file is not valid or the password is wrong")
+	_ = i18n.Sprintf("The profile has been exported")
+	_ = i18n.Sprintf("The profile has been imported")
+	_ = i18n.Sprintf("The profile is a single file, protected with a password, with all the settings, the identity, the saved meeting rooms and the Tor bridges. Import it to use them in another device or after installing Wahay again")
+	_ = i18n.Sprintf("the QR code is damaged, try with a sharper image")
+	_ = i18n.Sprintf("The recording can't be decrypted: %s")
+	_ = i18n.Sprintf("The recording can't be played in the output device. Check the name of the output device.")
+	_ = i18n.Sprintf("The recording can't be saved: %s")
+	_ = i18n.Sprintf("The recording has been decrypted")
+	_ = i18n.Sprintf("The recording has been saved")
+	_ = i18n.Sprintf("the recording is not valid or the password is wrong")
+	_ = i18n.Sprintf("The recordings of the meetings you host are encrypted with the password chosen when the recording started. Decrypting one saves it as an Opus file, which most players can play.")
+	_ = i18n.Sprintf("The size of the text of the windows of Wahay.")
+	_ = i18n.Sprintf("The SuperUser administers the Mumble server of this meeting, and it can do anything in the meeting. Only give its password to people you trust, and change it when they don't need it anymore. Changing it doesn't disconnect anybody.")
+	_ = i18n.Sprintf("the Tor network might be blocked in this network")
+	_ = i18n.Sprintf("the Tor network might be slow, try to host the meeting again")
+	_ = i18n.Sprintf("Theme")
+	_ = i18n.Sprintf("There is already a meeting in progress. Leave it before joining another one.")
+	_ = i18n.Sprintf("There is already a meeting room with that name")
+	_ = i18n.Sprintf("This invitation lets only one participant reach the meeting (%d of %d). Choose your email service to send it")
+	_ = i18n.Sprintf("This is the first time you join this meeting. Before trusting it, ask the host of the meeting to confirm, using a different channel, that the fingerprint of the certificate is:\n\n%s\n\nIs the fingerprint exactly the same? [y/N] ")
 	_ = i18n.Sprintf("Tip: Push right control to talk")
 	_ = i18n.Sprintf("Invite others")
+	_ = i18n.Sprintf("Turn on the persistent configuration in the settings to keep it after closing Wahay.")
+	_ = i18n.Sprintf("Unknown participant")
+	_ = i18n.Sprintf("Use these devices")
+	_ = i18n.Sprintf("Wahay chooses free ports for its own Tor, and chooses other ones if they are taken while Tor starts, so it works next to Tor Browser and the Tor of the system. Give the ports only when a firewall or another application needs them. They are used the next time Wahay starts its Tor")
+	_ = i18n.Sprintf("Wahay is connecting to the meeting through new Tor circuits.")
+	_ = i18n.Sprintf("Wahay records a few seconds from the input device and plays them in the output device, so you know that the others will hear you before the meeting starts. The recording is not saved.")
+	_ = i18n.Sprintf("Wahay tells you about these events with a banner and a notification of the desktop.")
+	_ = i18n.Sprintf("Welcome message")
+	_ = i18n.Sprintf("Whoever arrives when the meeting is full is told so and disconnected, except when joining as the SuperUser. Leave it blank to let everybody join")
+	_ = i18n.Sprintf("Write a message for everybody")
+	_ = i18n.Sprintf("You")
+	_ = i18n.Sprintf("● The meeting can't be reached, it's being restarted in the Tor network")
+	_ = i18n.Sprintf("● The meeting couldn't be reached, it's being published again")
+	_ = i18n.Sprintf("● The participants can reach the meeting")
+	_ = i18n.Sprintf("● This meeting is being recorded")
 }
 
 func noPointInEverCallingThisButYouCanIfYouReallyFeelLikeIt3() {
 	_ = i18n.Sprintf("Meeting ID:")
 	_ = i18n.Sprintf("Meeting password")
+	_ = i18n.Sprintf("HH:MM")
 	_ = i18n.Sprintf("Mumble")
 	_ = i18n.Sprintf("No, cancel")
 	_ = i18n.Sprintf("Now you are hosting a meeting.")
+	_ = i18n.Sprintf("Open a .wahay invitation file. You can also drop the file in the Meeting ID field")
+	_ = i18n.Sprintf("Open invitation file")
 	_ = i18n.Sprintf("Outlook")
+	_ = i18n.Sprintf("Panic")
+	_ = i18n.Sprintf("Passphrase")
 	_ = i18n.Sprintf("Password")
 	_ = i18n.Sprintf("Please enter the master password for the configuration file.")
 	_ = i18n.Sprintf("Port")
 	_ = i18n.Sprintf("Port out of range")
+	_ = i18n.Sprintf("Publish the invitation in a temporary onion service protected with a password")
 	_ = i18n.Sprintf("Raw log file")
 	_ = i18n.Sprintf("Repeat the password")
 	_ = i18n.Sprintf("Save changes")
+	_ = i18n.Sprintf("Save a calendar entry with the invitation, so the participants can import it in their calendars. " +
+		"The meeting will only be reachable while you are hosting it.")
+	_ = i18n.Sprintf("Save calendar entry")
+	_ = i18n.Sprintf("Save Invitation File")
 	_ = i18n.Sprintf("Security")
 	_ = i18n.Sprintf("Settings")
+	_ = i18n.Sprintf("Share Temporary Link")
+	_ = i18n.Sprintf("Share invitation link")
+	_ = i18n.Sprintf("Share the invitation with a temporary link")
 	_ = i18n.Sprintf("Show")
 	_ = i18n.Sprintf("Specify a password for the meeting")
 	_ = i18n.Sprintf("Start meeting")
+	_ = i18n.Sprintf("Start time")
+	_ = i18n.Sprintf("Stop sharing")
 	_ = i18n.Sprintf("The error message")
+	_ = i18n.Sprintf("The date or the time are not valid")
 	_ = i18n.Sprintf("The meeting ID has been copied to the clipboard")
+	_ = i18n.Sprintf("The webhook must be an http or https URL")
 	_ = i18n.Sprintf("A valid port is between 1 and 65535")
 	_ = i18n.Sprintf("This action cannot be undone")
+	_ = i18n.Sprintf("Title")
 	_ = i18n.Sprintf("Toggle password visibility")
+	_ = i18n.Sprintf("Tor bridges")
+	_ = i18n.Sprintf("Tor connects to the network")
+	_ = i18n.Sprintf("Tor keeps running between meetings, so they start faster. Leave it blank to keep Tor running until Wahay is closed")
 	_ = i18n.Sprintf("Type the Meeting ID (normally a .onion address)")
 	_ = i18n.Sprintf("Type the password")
 	_ = i18n.Sprintf("Type the password to join the meeting")
 	_ = i18n.Sprintf("Type your preferred screen name")
 	_ = i18n.Sprintf("Type your screen name")
+	_ = i18n.Sprintf("URL: %s")
+	_ = i18n.Sprintf("Use a new Mumble profile in every meeting")
+	_ = i18n.Sprintf("use an email server that supports encrypted connections")
+	_ = i18n.Sprintf("Use bridges when Tor is blocked in your network. Paste one bridge line per line, as given by https://bridges.torproject.org. The built-in bridges are requested directly, so your network can see the request. The bridges are used the next time Tor is started")
+	_ = i18n.Sprintf("use the format subsystem=level, for example: tor=debug,gui=warning")
+	_ = i18n.Sprintf("use the host and the port of the ControlPort of Tor, like 127.0.0.1:9051")
+	_ = i18n.Sprintf("Use the same identity in all the meetings")
 	_ = i18n.Sprintf("Username")
+	_ = i18n.Sprintf("Wahay checks, one step after the other, that Tor connects to the network, that an onion service can be created and reached through Tor, and that Mumble can be used. Connecting to a new onion service can take a few minutes.")
+	_ = i18n.Sprintf("Wahay connects to the email server through Tor and only if the connection can be encrypted")
+	_ = i18n.Sprintf("Wahay creates a new certificate for Mumble every time you join a meeting. Bigger keys are safer, but they take longer to create")
+	_ = i18n.Sprintf("Wahay hosts these meetings some minutes before they begin, as long as it's running. Their invitations can be given from now on.")
+	_ = i18n.Sprintf("Wahay is in another meeting, or it's not ready for meetings.")
+	_ = i18n.Sprintf("Wahay is not ready for meetings yet")
+	_ = i18n.Sprintf("Wahay meeting")
+	_ = i18n.Sprintf("Wahay uses the Tor of the system or of Tor Browser when it's running, and starts its own Tor otherwise. Give the control port of another Tor to use only that one. It's used the next time Wahay connects to Tor")
+	_ = i18n.Sprintf("Waiting")
+	_ = i18n.Sprintf("Waiting to be admitted")
+	_ = i18n.Sprintf("When the configuration file is not encrypted, the meeting rooms, the address book, the history of the meetings and your identity are kept in GNOME Keyring or KWallet, instead of being forgotten or written in the file")
+	_ = i18n.Sprintf("Where do you want to host the meeting?")
+	_ = i18n.Sprintf("With the same identity the other participants can recognize you in every meeting. The identity is kept in the Wahay configuration, and it can be exported with a password to use it in other devices")
+	_ = i18n.Sprintf("Write a name for saving the meeting in the address book")
+	_ = i18n.Sprintf("write every port mapping as the port of the meeting and the local port, like 8181:18181")
+	_ = i18n.Sprintf("Write one port of the meeting and the local port per line, like 8181:18181. The certificate server uses port 8181 and the shared files use port 8282, so they listen on the given local port. Other ports are published for the services of this computer listening on them")
+	_ = i18n.Sprintf("Write the name of the breakout room")
+	_ = i18n.Sprintf("You can also type the invitation words that the host read to you")
+	_ = i18n.Sprintf("You can choose the audio quality in the settings")
+	_ = i18n.Sprintf("Your password in the email server")
+	_ = i18n.Sprintf("Your username in the email server")
+	_ = i18n.Sprintf("YYYY-MM-DD")
 	_ = i18n.Sprintf("Wahay is ready to use")
 	_ = i18n.Sprintf("We have detected that the configuration file is invalid or corrupted. " +
 		"Do you want to make a copy (backup) of it and continue?")
+	_ = i18n.Sprintf("Webhook URL")
 	_ = i18n.Sprintf("Welcome")
+	_ = i18n.Sprintf("When a meeting you host starts, Wahay sends the invitation to this URL through Tor. " +
+		"Leave it blank to disable it")
 	_ = i18n.Sprintf("When this option is checked, the configuration settings will be stored in the device.")
 	_ = i18n.Sprintf("Yahoo Mail")
 	_ = i18n.Sprintf("Yes, back it up &amp; continue")
@@ -0,0 +1,123 @@
+package gui
+
+import (
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// joinMeetingFromCommandLine starts the join flow when Wahay was
+// opened with a meeting URL, for example when clicking a wahay:// or mumble:// link
+func (u *gtkUI) joinMeetingFromCommandLine() {
+	meetingURL := u.pendingMeetingURL
+	u.pendingMeetingURL = ""
+	if meetingURL == "" {
+		return
+	}
+
+	if u.errorHandler.isThereAnyStartupError() {
+		log.WithFields(log.Fields{
+			"url": meetingURL,
+		}).Warning("The meeting URL is ignored because Wahay can't join meetings")
+		return
+	}
+
+	u.joinMeetingFromURL(meetingURL)
+}
+
+// activatedAgain brings the running session to the front when Wahay is
+// opened again, and joins the meeting of the link it was opened with.
+// Until Wahay is ready for meetings, the link is left for
+// joinMeetingFromCommandLine
+func (u *gtkUI) activatedAgain(meetingURL string) {
+	if u.currentWindow != nil {
+		u.currentWindow.Present()
+	}
+
+	if meetingURL == "" {
+		return
+	}
+
+	if !u.dependenciesReady {
+		u.pendingMeetingURL = meetingURL
+		return
+	}
+
+	if u.currentHost != nil || u.currentMumble != nil {
+		log.WithFields(log.Fields{
+			"url": meetingURL,
+		}).Warning("The meeting URL is ignored because there is already a meeting in progress")
+		u.reportError(i18n.Sprintf("There is already a meeting in progress. Leave it before joining another one."))
+		return
+	}
+
+	if !u.meetingsEnabled {
+		log.WithFields(log.Fields{
+			"url": meetingURL,
+		}).Warning("The meeting URL is ignored because Wahay can't join meetings")
+		return
+	}
+
+	u.joinMeetingFromURL(meetingURL)
+}
+
+func (u *gtkUI) joinMeetingFromURL(meetingURL string) {
+	// Wahay is also the application for opening invitation files
+	if isInvitationFilePath(meetingURL) {
+		u.importInvitationFile(meetingURL, func(inv *invitation.Invitation) {
+			u.hideMainWindow()
+			u.fillJoinWindow(u.openJoinWindow(), inv)
+		})
+		return
+	}
+
+	inv, err := invitation.Parse(meetingURL)
+	if err == invitation.ErrNotAnOnionAddress {
+		// This could be a link to a Mumble server on the Internet,
+		// the user will be warned before joining it
+		inv, err = invitation.ParseAllowingClearnet(meetingURL)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   meetingURL,
+			"error": err,
+		}).Error("Invalid meeting URL provided")
+		u.reportError(i18n.Sprintf("Invalid meeting ID provided: %s", invitationErrorTranslator(err)))
+		return
+	}
+
+	// Without a screen name we can't join, so we let the
+	// user complete the information in the join window
+	if inv.Username == "" {
+		u.hideMainWindow()
+		u.fillJoinWindow(u.openJoinWindow(), inv)
+		return
+	}
+
+	data := meetingDataFor(inv)
+
+	if !inv.IsOnion() {
+		u.confirmClearnetJoin(data)
+		return
+	}
+
+	u.hideMainWindow()
+	go u.joinMeetingHandler(data)
+}
+
+// meetingDataFor returns what is needed for joining the meeting of the invitation
+func meetingDataFor(inv *invitation.Invitation) hosting.MeetingData {
+	return hosting.MeetingData{
+		MeetingID:      inv.Host,
+		Port:           inv.Port,
+		Username:       inv.Username,
+		Password:       inv.Password,
+		Token:          inv.Token,
+		CertificateURL: inv.CertificateURL,
+		Fingerprint:    inv.Fingerprint,
+		Certificate:    inv.Certificate,
+		ClientAuth:     inv.ClientAuth,
+	}
+}
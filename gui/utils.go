@@ -22,6 +22,8 @@ func (u *gtkUI) switchToMainWindow() {
 	if u.mainWindow != nil {
 		u.switchToWindow(u.mainWindow)
 	}
+
+	u.scheduleTorIdleShutdown()
 }
 
 func (u *gtkUI) switchToWindow(win gtki.ApplicationWindow) {
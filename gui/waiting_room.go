@@ -0,0 +1,41 @@
+package gui
+
+import (
+	"github.com/digitalautonomy/wahay/hosting"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchWaitingRoom asks the host to admit or reject everybody
+// that arrives to the waiting room of the meeting
+func (h *hostData) watchWaitingRoom() {
+	w := h.service.WaitingRoom()
+	if w == nil {
+		return
+	}
+
+	w.WhenWaiting(func(p hosting.WaitingParticipant) {
+		h.u.doInUIThread(func() {
+			h.askForAdmission(w, p)
+		})
+	})
+}
+
+func (h *hostData) askForAdmission(w hosting.WaitingRoom, p hosting.WaitingParticipant) {
+	h.u.showConfirmation(func(admit bool) {
+		var err error
+		if admit {
+			err = w.Admit(p.Session)
+		} else {
+			err = w.Reject(p.Session)
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "waiting-room",
+				"admit":   admit,
+			}).Warningf("The participant can't be admitted or rejected: %s", err)
+		}
+	}, i18n.Sprintf("%s is in the waiting room. Do you want to admit them to the meeting? "+
+		"Otherwise, they will be removed from it.", p.Name))
+}
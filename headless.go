@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/gui"
+	"github.com/digitalautonomy/wahay/hosting"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+var (
+	errHostNeedsHeadless = errors.New("hosting a meeting from the command line needs the -headless flag")
+	errJoinNeedsUsername = errors.New("joining a meeting from the command line needs the -username flag")
+	errJoinNeedsOnion    = errors.New("only meetings on onion services can be joined from the command line")
+)
+
+// headlessMeeting is what is printed once the meeting is ready,
+// so scripts can send the invitation to the participants
+type headlessMeeting struct {
+	Invitation  string `json:"invitation,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+
+	// Invitations has one invitation for every participant, instead
+	// of the shared one, when only the invitees can reach the meeting
+	Invitations []string `json:"invitations,omitempty"`
+}
+
+// headlessConfig loads the configuration of Wahay when it's not
+// encrypted. An encrypted one needs the master password, which
+// can't be asked without the GUI, so the defaults are used instead
+func headlessConfig() *config.ApplicationConfig {
+	conf := config.New()
+	conf.Init()
+
+	filename, _ := conf.DetectPersistence()
+	if filename != "" && !conf.ShouldEncrypt() {
+		_, _, err := conf.LoadFromFile(filename, nil)
+		if err == nil {
+			conf.ApplyLogLevel()
+			return conf
+		}
+		log.Warningf("The configuration can't be loaded, using the defaults: %s", err)
+	} else if filename != "" {
+		log.Warning("The configuration is encrypted and can't be used in headless mode, using the defaults")
+	}
+
+	conf = config.New()
+	conf.Init()
+	conf.InitDefault()
+
+	return conf
+}
+
+// startHeadlessTor starts Tor, logging how its connection to the
+// network advances. Interrupting Wahay stops it while it's connecting
+func startHeadlessTor(conf *config.ApplicationConfig) tor.Instance {
+	t, err := newHeadlessTor(conf)
+	if err != nil {
+		exitWithError("Wahay: Tor can't be started", err)
+	}
+
+	return t
+}
+
+func newHeadlessTor(conf *config.ApplicationConfig) (tor.Instance, error) {
+	b := tor.NewBootstrap()
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	go func() {
+		for {
+			select {
+			case p, ok := <-b.Progress():
+				if !ok {
+					return
+				}
+				logHeadlessBootstrap(p)
+			case <-interrupted:
+				b.Cancel()
+				return
+			}
+		}
+	}()
+
+	t, err := tor.NewInstanceWithBootstrap(conf, nil, b)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, tor.ErrTorBinaryNotFound
+	}
+
+	return t, nil
+}
+
+func logHeadlessBootstrap(p tor.BootstrapProgress) {
+	l := log.WithFields(log.Fields{
+		"context":  "tor",
+		"progress": p.Percent,
+		"phase":    p.Phase,
+	})
+
+	if p.Warning != "" {
+		l.Warningf("Connecting to Tor: %s", p.Warning)
+		return
+	}
+
+	l.Infof("Connecting to Tor: %d%% (%s)", p.Percent, p.Summary)
+}
+
+// runHeadlessHost hosts a meeting without the GUI. It starts Tor, the
+// Mumble server and the onion service, prints the invitation and keeps
+// the meeting open until Wahay is interrupted
+func runHeadlessHost() {
+	if !*config.Headless {
+		exitWithError("Wahay", errHostNeedsHeadless)
+	}
+
+	conf := headlessConfig()
+
+	ports, err := hosting.NewServicePorts(conf.GetPortMumble(), conf.GetMumbleListenerPort(), conf.GetOnionPortMappings())
+	if err != nil {
+		exitWithError("Wahay: the meeting can't be created", err)
+	}
+	ports.OnionOnly = conf.GetOnionOnlyCertServer()
+
+	t := startHeadlessTor(conf)
+	defer t.Destroy()
+
+	servers, err := hosting.CreateServerCollection()
+	if err != nil {
+		exitWithError("Wahay: the meeting can't be created", err)
+	}
+	defer servers.Cleanup()
+
+	s, err := servers.NewService(ports, t, false, conf.GetEmbedCertificate(), conf.GetClientAuthInvitees())
+	if err != nil {
+		exitWithError("Wahay: the meeting can't be created", err)
+	}
+	defer func() {
+		_ = s.Finish(gui.MeetingEndedMessage())
+	}()
+
+	s.SetWelcomeText("Welcome to this server running <b>Wahay</b>.")
+	s.SetMaxBandwidth(client.LimitedHostingBandwidth(conf.GetAudioPreset(), conf.GetMaxBandwidth()))
+	s.SetMaxParticipants(conf.GetMaxParticipants())
+	s.SetIdleTimeout(time.Duration(conf.GetIdleKickTimeout()) * time.Minute)
+	s.SetMeetingACL(hosting.MeetingACL{
+		ModeratorPassword:   *config.ModeratorPassword,
+		ParticipantsCanMute: *config.ParticipantsCanMute || conf.GetParticipantsCanMute(),
+		ParticipantsCanKick: *config.ParticipantsCanKick || conf.GetParticipantsCanKick(),
+		WaitingRoom:         *config.WaitingRoom || conf.GetWaitingRoom(),
+	})
+
+	err = s.NewConferenceRoom(*config.MeetingPassword, hosting.SuperUserData{})
+	if err != nil {
+		exitWithError("Wahay: the meeting can't be created", err)
+	}
+
+	if w := s.WaitingRoom(); w != nil {
+		go admitInTerminal(w)
+	}
+
+	published := make(chan error, 1)
+	s.WhenPublished(func(err error) {
+		published <- err
+	})
+
+	err = <-published
+	if err != nil {
+		log.Warningf("The meeting might not be reachable through Tor yet: %s", err)
+	}
+
+	err = printHeadlessMeeting(s)
+	if err != nil {
+		exitWithError("Wahay: the invitation can't be generated", err)
+	}
+
+	s.WhenReachabilityChanges(func(r hosting.Reachability) {
+		switch r {
+		case hosting.ReachabilityDegraded:
+			log.Warning("The meeting can't be reached through Tor, it's being published again")
+		case hosting.Unreachable:
+			log.Error("The meeting keeps being unreachable through Tor, it's being restarted")
+		case hosting.Reachable:
+			log.Info("The meeting can be reached through Tor")
+		}
+	})
+
+	<-interruptSignal()
+
+	log.Info("Closing the meeting...")
+
+	config.WipeSensitiveFiles(conf.GetKeepIdentityFiles())
+}
+
+func printHeadlessMeeting(s hosting.Service) error {
+	inv := &invitation.Invitation{
+		Host:        s.ID(),
+		Port:        s.ServicePort(),
+		Token:       s.Token(),
+		Password:    *config.MeetingPassword,
+		Fingerprint: s.Fingerprint(),
+		Certificate: s.Certificate(),
+	}
+
+	m := headlessMeeting{
+		Password:    inv.Password,
+		Fingerprint: inv.Fingerprint,
+	}
+
+	for _, k := range s.ClientAuthKeys() {
+		inv.ClientAuth = k
+		encoded, err := inv.EncodeV2()
+		if err != nil {
+			return err
+		}
+		m.Invitations = append(m.Invitations, encoded)
+	}
+
+	if len(m.Invitations) == 0 {
+		encoded, err := inv.EncodeV2()
+		if err != nil {
+			return err
+		}
+		m.Invitation = encoded
+		m.URL = inv.WahayURL()
+	}
+
+	if *config.HeadlessJSON {
+		return json.NewEncoder(os.Stdout).Encode(m)
+	}
+
+	if len(m.Invitations) > 0 {
+		fmt.Println(gui.Sprintf("Invitations, one for every participant:"))
+		for i, encoded := range m.Invitations {
+			fmt.Printf("%d: %s\n", i+1, encoded)
+		}
+	} else {
+		fmt.Println(gui.Sprintf("Invitation: %s", m.Invitation))
+		fmt.Println(gui.Sprintf("URL: %s", m.URL))
+	}
+	if m.Password != "" {
+		fmt.Println(gui.Sprintf("Password: %s", m.Password))
+	}
+	fmt.Println(gui.Sprintf("Fingerprint: %s", invitation.FormatFingerprint(m.Fingerprint)))
+
+	return nil
+}
+
+func interruptSignal() <-chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	return c
+}
+
+// runHeadlessJoin joins the meeting of the invitation given in the
+// command line, launching Mumble without the GUI. It returns when
+// Mumble is closed or Wahay is interrupted
+func runHeadlessJoin() {
+	inv, err := invitation.Parse(*config.Join)
+	if err == invitation.ErrNotAnOnionAddress {
+		exitWithError("Wahay", errJoinNeedsOnion)
+	}
+	if err != nil {
+		exitWithError("Wahay: invalid invitation", err)
+	}
+
+	if *config.Username != "" {
+		inv.Username = *config.Username
+	}
+	if *config.MeetingPassword != "" {
+		inv.Password = *config.MeetingPassword
+	}
+	if inv.Username == "" {
+		exitWithError("Wahay", errJoinNeedsUsername)
+	}
+
+	conf := headlessConfig()
+	if *config.MumbleBinary != "" {
+		conf.SetMumbleBinaryPath(*config.MumbleBinary)
+	}
+
+	c := client.InitSystem(conf, nil)
+	if !c.IsValid() {
+		exitWithError("Wahay: Mumble can't be used", c.LastError())
+	}
+	defer c.Destroy()
+
+	t := startHeadlessTor(conf)
+	defer t.Destroy()
+
+	c.UseTor(t)
+	c.SetAudioSettings(conf.GetAudioSettings())
+	c.SetIsolatedProfile(conf.GetIsolatedProfiles())
+	c.SetCertificateVerifier(verifyCertificateInTerminal)
+
+	err = c.SetCertificateKey(conf.GetCertificateKey())
+	if err != nil {
+		log.Warningf("The configured certificate key can't be used: %s", err)
+	}
+
+	err = c.SetIdentity(conf.GetClientIdentity())
+	if err != nil {
+		log.Warningf("The configured identity can't be used, a temporary one is used instead: %s", err)
+	}
+
+	data := hosting.MeetingData{
+		MeetingID:   inv.Host,
+		Port:        inv.Port,
+		Username:    inv.Username,
+		Password:    inv.Password,
+		Token:       inv.Token,
+		Fingerprint: inv.Fingerprint,
+		Certificate: inv.Certificate,
+		ClientAuth:  inv.ClientAuth,
+	}
+
+	if data.ClientAuth != "" {
+		err = t.AuthorizeOnion(data.MeetingID, data.ClientAuth)
+		if err != nil {
+			exitWithError("Wahay: the meeting can't be joined", err)
+		}
+	}
+
+	closed := make(chan bool, 1)
+	s, err := c.Launch(data.GenerateURL(), func() {
+		closed <- true
+	})
+	if err != nil {
+		exitWithError("Wahay: the meeting can't be joined", err)
+	}
+
+	select {
+	case <-closed:
+	case <-interruptSignal():
+		log.Info("Leaving the meeting...")
+		s.Close()
+	}
+
+	config.WipeSensitiveFiles(conf.GetKeepIdentityFiles())
+}
+
+// admitInTerminal asks, one by one, whether to admit the participants
+// that arrive to the waiting room. Without an answer they keep waiting
+func admitInTerminal(w hosting.WaitingRoom) {
+	arrivals := make(chan hosting.WaitingParticipant)
+	w.WhenWaiting(func(p hosting.WaitingParticipant) {
+		arrivals <- p
+	})
+
+	reader := bufio.NewReader(os.Stdin)
+	for p := range arrivals {
+		fmt.Fprint(os.Stderr, gui.Sprintf("%s is in the waiting room. Do you want to admit them to the meeting? [y/N] ", p.Name))
+
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			log.Warningf("The participants of the waiting room can't be admitted without a terminal: %s", err)
+			return
+		}
+
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "y" || answer == "yes" {
+			err = w.Admit(p.Session)
+		} else {
+			err = w.Reject(p.Session)
+		}
+
+		if err != nil {
+			log.Warningf("The participant can't be admitted or rejected: %s", err)
+		}
+	}
+}
+
+// verifyCertificateInTerminal asks the user to confirm the fingerprint
+// of the certificate of a meeting joined for the first time. Without
+// an answer the certificate is not trusted
+func verifyCertificateInTerminal(hostname, fingerprint string, withoutTLS bool) bool {
+	if withoutTLS {
+		fmt.Fprint(os.Stderr, gui.Sprintf("The meeting is hosted with an old version of Wahay, "+
+			"so the certificate was received without TLS.")+"\n\n")
+	}
+
+	fmt.Fprint(os.Stderr, gui.Sprintf("This is the first time you join this meeting. "+
+		"Before trusting it, ask the host of the meeting to confirm, using a different channel, "+
+		"that the fingerprint of the certificate is:\n\n%s\n\n"+
+		"Is the fingerprint exactly the same? [y/N] ", invitation.FormatFingerprint(fingerprint)))
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,100 @@
+package hosting
+
+import (
+	"strings"
+
+	"github.com/digitalautonomy/grumble/pkg/acl"
+	grumbleServer "github.com/digitalautonomy/grumble/server"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The moderators of a meeting are the participants who know the
+// moderator password. Mumble sends it as an access token when it's
+// added in the "Access Tokens" dialog of the server, so the moderators
+// join with the password of the meeting like everybody else, and the
+// ACLs of the root channel give them more privileges
+
+var (
+	errSameModeratorPassword    = failure.New("hosting.same-moderator-password", failure.CategoryHosting, "the moderator password is the same as the meeting password", "choose a different password for the moderators")
+	errInvalidModeratorPassword = failure.New("hosting.invalid-moderator-password", failure.CategoryHosting, "the moderator password can't have spaces or commas", "choose another password for the moderators")
+)
+
+// MeetingACL has the privileges of the participants of a meeting,
+// besides talking and writing, which everybody can do
+type MeetingACL struct {
+	// ModeratorPassword gives the privileges of a moderator to whoever
+	// adds it as an access token. It's empty when nobody moderates
+	ModeratorPassword string
+
+	// ParticipantsCanMute lets every participant mute and deafen the others
+	ParticipantsCanMute bool
+
+	// ParticipantsCanKick lets every participant remove others from the meeting
+	ParticipantsCanKick bool
+
+	// WaitingRoom keeps the participants in a channel where they can't
+	// talk, until the host or a moderator admits them to the meeting
+	WaitingRoom bool
+}
+
+// moderatorPermissions are the privileges of the moderators,
+// which can do anything the super user does, except banning
+const moderatorPermissions = acl.MuteDeafenPermission | acl.MovePermission | acl.KickPermission
+
+func (a MeetingACL) validate(meetingPassword string) error {
+	if a.ModeratorPassword == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(a.ModeratorPassword, " \t\n,") {
+		return errInvalidModeratorPassword
+	}
+
+	if strings.EqualFold(a.ModeratorPassword, meetingPassword) {
+		return errSameModeratorPassword
+	}
+
+	return nil
+}
+
+func (a MeetingACL) participantPermissions() acl.Permission {
+	p := acl.Permission(acl.NonePermission)
+	if a.ParticipantsCanMute {
+		p |= acl.MuteDeafenPermission
+	}
+	if a.ParticipantsCanKick {
+		p |= acl.KickPermission
+	}
+	return p
+}
+
+func groupACL(group string, allow acl.Permission) acl.ACL {
+	return acl.ACL{
+		UserId:    -1,
+		Group:     group,
+		ApplyHere: true,
+		ApplySubs: true,
+		Allow:     allow,
+	}
+}
+
+// setACL replaces the ACLs of the root channel, which every
+// other channel of the meeting inherits
+func setACL(a MeetingACL) func(*grumbleServer.Server) {
+	return func(serv *grumbleServer.Server) {
+		var acls []acl.ACL
+
+		if p := a.participantPermissions(); p != acl.NonePermission {
+			acls = append(acls, groupACL("all", p))
+		}
+
+		if a.ModeratorPassword != "" {
+			acls = append(acls, groupACL("#"+a.ModeratorPassword, moderatorPermissions))
+		}
+
+		root := serv.RootChannel()
+		root.ACL.ACLs = acls
+		serv.ClearCaches()
+	}
+}
@@ -0,0 +1,623 @@
+package hosting
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/acl"
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/digitalautonomy/grumble/pkg/packetdata"
+	grumbleServer "github.com/digitalautonomy/grumble/server"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+)
+
+// The grumble server doesn't tell who is connected to it, so Wahay
+// joins every meeting it hosts as one more participant, the agent. It
+// speaks the Mumble protocol, and its access token lets it move, mute
+// and remove everybody, like the super user could. What the agent sees
+// is what the host is told about the participants of the meeting.
+// Since everybody arrives through Tor, from the same address, the
+// participants are banned by the certificate of their Mumble client.
+// The agent has a certificate of its own, since grumble only lets the
+// clients with one create channels
+
+const (
+	agentName = "Wahay"
+
+	agentConnectTimeout = 10 * time.Second
+	agentPingInterval   = 15 * time.Second
+
+	// A participant is talking while the voice packets
+	// arrive more often than this
+	agentTalkingTimeout = 400 * time.Millisecond
+)
+
+var (
+	errAgentRejected = failure.New("hosting.agent-rejected", failure.CategoryHosting, "the Mumble server didn't let Wahay join the meeting", "")
+	errAgentClosed   = failure.New("hosting.agent-closed", failure.CategoryHosting, "Wahay is not connected to the meeting anymore", "")
+)
+
+// setAgentACL lets the agent act on all the participants, create
+// the breakout rooms, write to everybody and change the ACLs for the
+// co-moderators, and get the statistics of everybody. It must be
+// applied after the rest of the ACLs, since it only adds entries to them
+func setAgentACL(token string) func(*grumbleServer.Server) {
+	return func(serv *grumbleServer.Server) {
+		root := serv.RootChannel()
+		root.ACL.ACLs = append(root.ACL.ACLs,
+			groupACL("#"+token, acl.MovePermission|acl.MuteDeafenPermission|acl.KickPermission|acl.BanPermission|acl.MakeChannelPermission|acl.TextMessagePermission|acl.WritePermission|acl.RegisterPermission))
+		serv.ClearCaches()
+	}
+}
+
+type agentUser struct {
+	name        string
+	hash        string
+	channel     uint32
+	registered  bool
+	mutedByHost bool
+	selfMuted   bool
+	suppressed  bool
+	deafByHost  bool
+	selfDeaf    bool
+	lastVoice   time.Time
+	talking     bool
+	stats       ParticipantStats
+
+	// lastActivity is when the participant joined, or last talked,
+	// wrote or changed something, and removed is set once the agent
+	// has disconnected them for the limits of the meeting
+	lastActivity time.Time
+	removed      bool
+
+	// admitted is set once the agent has moved the Mumble
+	// client of the host out of the waiting room
+	admitted bool
+}
+
+// setHostCertificate recognizes the Mumble client of the host by the
+// hash of its certificate, since the host can join without being the
+// SuperUser, like any other participant
+func (a *agent) setHostCertificate(hash string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.hostHash = hash
+
+	for session, u := range a.users {
+		a.admitHost(session, u)
+	}
+}
+
+// isHost must be called with the lock held
+func (a *agent) isHost(u *agentUser) bool {
+	return a.hostHash != "" && strings.EqualFold(u.hash, a.hostHash)
+}
+
+func (u *agentUser) muted() bool {
+	return u.mutedByHost || u.selfMuted || u.suppressed
+}
+
+func (u *agentUser) deafened() bool {
+	return u.deafByHost || u.selfDeaf
+}
+
+type agent struct {
+	conn net.Conn
+
+	writeLock sync.Mutex
+
+	lock      sync.Mutex
+	self      uint32
+	meeting   uint32
+	synced    bool
+	closed    bool
+	users     map[uint32]*agentUser
+	channels  map[uint32]*agentChannel
+	creating  map[string]bool
+	bans      []*mumbleproto.BanList_BanEntry
+	onWaiting []func(WaitingParticipant)
+	onChanged map[int]func(ParticipantEvent, Participant)
+	onRooms   map[int]func()
+	nextID    int
+
+	// coModerators has the certificate hashes of the participants made
+	// co-moderators, and of the ones that were and aren't anymore
+	coModerators map[string]bool
+
+	// waitingRoom is set when the participants are admitted
+	// by the host, so the agent waits in the meeting channel
+	waitingRoom bool
+
+	// recorder is set while the host records the meeting
+	recorder *meetingRecorder
+
+	// chat has the last messages written in the meeting
+	chat   []ChatMessage
+	onChat map[int]func(ChatMessage)
+
+	maxParticipants int
+	idleTimeout     time.Duration
+
+	// hostHash is the certificate hash of the Mumble
+	// client of the host, when the host has joined
+	hostHash string
+}
+
+// openAgent joins the meeting in the given local port, and returns
+// once the server has sent everybody who is connected
+func openAgent(port int, password, token, fingerprint string, waitingRoom bool) (*agent, error) {
+	cert, err := newAgentCertificate()
+	if err != nil {
+		return nil, errAgentRejected.Wrap(err)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: agentConnectTimeout}, "tcp",
+		net.JoinHostPort(defaultHost, strconv.Itoa(port)), &tls.Config{
+			// The certificate of the server is self-signed, so
+			// we check that it's the one of this meeting instead
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{cert},
+			VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+				if fingerprint != "" && (len(raw) == 0 || invitation.CertificateFingerprint(raw[0]) != fingerprint) {
+					return errAgentRejected
+				}
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, errAgentRejected.Wrap(err)
+	}
+
+	a := &agent{
+		conn:         conn,
+		users:        make(map[uint32]*agentUser),
+		channels:     make(map[uint32]*agentChannel),
+		creating:     make(map[string]bool),
+		coModerators: make(map[string]bool),
+		onChanged:    make(map[int]func(ParticipantEvent, Participant)),
+		onRooms:      make(map[int]func()),
+		onChat:       make(map[int]func(ChatMessage)),
+		waitingRoom:  waitingRoom,
+	}
+
+	err = a.authenticate(password, token)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	go a.receive()
+	go a.ping()
+	go a.watchTalking()
+
+	return a, nil
+}
+
+func (a *agent) authenticate(password, token string) error {
+	err := a.send(mumbleproto.MessageVersion, &mumbleproto.Version{
+		Version: proto.Uint32(0x10205),
+		Release: proto.String("Wahay"),
+	})
+	if err != nil {
+		return errAgentRejected.Wrap(err)
+	}
+
+	err = a.send(mumbleproto.MessageAuthenticate, &mumbleproto.Authenticate{
+		Username: proto.String(agentName),
+		Password: proto.String(password),
+		Tokens:   []string{token},
+		Opus:     proto.Bool(true),
+	})
+	if err != nil {
+		return errAgentRejected.Wrap(err)
+	}
+
+	_ = a.conn.SetReadDeadline(time.Now().Add(agentConnectTimeout))
+	defer func() {
+		_ = a.conn.SetReadDeadline(time.Time{})
+	}()
+
+	for !a.isSynced() {
+		kind, buf, err := readMumbleMessage(a.conn)
+		if err != nil {
+			return errAgentRejected.Wrap(err)
+		}
+
+		if kind == mumbleproto.MessageReject {
+			r := &mumbleproto.Reject{}
+			_ = proto.Unmarshal(buf, r)
+			return errAgentRejected.Wrapf("%s", r.GetReason())
+		}
+
+		a.handle(kind, buf)
+	}
+
+	if a.waitingRoom {
+		return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+			Session:   proto.Uint32(a.self),
+			ChannelId: proto.Uint32(a.meeting),
+		})
+	}
+
+	return nil
+}
+
+func (a *agent) receive() {
+	for {
+		kind, buf, err := readMumbleMessage(a.conn)
+		if err != nil {
+			a.lock.Lock()
+			closed := a.closed
+			a.closed = true
+			a.lock.Unlock()
+
+			if !closed {
+				log.WithFields(log.Fields{
+					"context": "agent",
+				}).Warningf("The server has disconnected Wahay from the meeting: %s", err)
+			}
+			return
+		}
+
+		a.handle(kind, buf)
+	}
+}
+
+func (a *agent) ping() {
+	t := time.NewTicker(agentPingInterval)
+	defer t.Stop()
+
+	for range t.C {
+		if a.isClosed() {
+			return
+		}
+
+		_ = a.send(mumbleproto.MessagePing, &mumbleproto.Ping{
+			Timestamp: proto.Uint64(uint64(time.Now().Unix())),
+		})
+	}
+}
+
+func (a *agent) handle(kind uint16, buf []byte) {
+	switch kind {
+	case mumbleproto.MessageUDPTunnel:
+		a.voiceReceived(buf)
+	case mumbleproto.MessageChannelState:
+		m := &mumbleproto.ChannelState{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.updateChannel(m)
+		}
+	case mumbleproto.MessageChannelRemove:
+		m := &mumbleproto.ChannelRemove{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.removeChannel(m.GetChannelId())
+		}
+	case mumbleproto.MessageUserState:
+		m := &mumbleproto.UserState{}
+		if proto.Unmarshal(buf, m) == nil && m.Session != nil {
+			a.updateUser(m)
+		}
+	case mumbleproto.MessageUserRemove:
+		m := &mumbleproto.UserRemove{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.removeUser(m.GetSession())
+		}
+	case mumbleproto.MessageACL:
+		m := &mumbleproto.ACL{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.updateRootACL(m)
+		}
+	case mumbleproto.MessageUserStats:
+		m := &mumbleproto.UserStats{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.updateStats(m)
+		}
+	case mumbleproto.MessageTextMessage:
+		m := &mumbleproto.TextMessage{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.chatReceived(m)
+		}
+	case mumbleproto.MessageServerSync:
+		m := &mumbleproto.ServerSync{}
+		if proto.Unmarshal(buf, m) == nil {
+			a.synchronized(m.GetSession())
+		}
+	}
+}
+
+func (a *agent) updateUser(m *mumbleproto.UserState) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, known := a.users[m.GetSession()]
+	if !known {
+		u = &agentUser{lastActivity: time.Now()}
+		a.users[m.GetSession()] = u
+	}
+	if m.GetActor() == m.GetSession() {
+		u.lastActivity = time.Now()
+	}
+
+	wasWaiting := known && a.isWaiting(m.GetSession(), u)
+	if m.Name != nil {
+		u.name = m.GetName()
+	}
+	if m.Hash != nil {
+		u.hash = m.GetHash()
+	}
+	if m.UserId != nil {
+		u.registered = true
+	}
+	if m.ChannelId != nil {
+		u.channel = m.GetChannelId()
+	}
+	if m.Mute != nil {
+		u.mutedByHost = m.GetMute()
+	}
+	if m.SelfMute != nil {
+		u.selfMuted = m.GetSelfMute()
+	}
+	if m.Suppress != nil {
+		u.suppressed = m.GetSuppress()
+	}
+	if m.Deaf != nil {
+		u.deafByHost = m.GetDeaf()
+	}
+	if m.SelfDeaf != nil {
+		u.selfDeaf = m.GetSelfDeaf()
+	}
+
+	if !a.synced || m.GetSession() == a.self {
+		return
+	}
+
+	if !known {
+		a.checkFull(m.GetSession(), u)
+		a.notifyChanged(ParticipantConnected, m.GetSession(), u)
+		a.tellRecording(m.GetSession())
+	} else {
+		a.notifyChanged(ParticipantChanged, m.GetSession(), u)
+	}
+
+	if !wasWaiting && a.isWaiting(m.GetSession(), u) {
+		a.notifyWaiting(m.GetSession(), u)
+	}
+
+	a.admitHost(m.GetSession(), u)
+
+	// Waiting to be admitted is not being idle
+	if wasWaiting && !a.isWaiting(m.GetSession(), u) {
+		u.lastActivity = time.Now()
+	}
+}
+
+func (a *agent) removeUser(session uint32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, ok := a.users[session]
+	if !ok {
+		return
+	}
+
+	delete(a.users, session)
+	if a.synced && session != a.self {
+		a.notifyChanged(ParticipantDisconnected, session, u)
+	}
+}
+
+func (a *agent) synchronized(self uint32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.self = self
+	a.synced = true
+
+	for session, u := range a.users {
+		if a.isWaiting(session, u) {
+			a.notifyWaiting(session, u)
+		}
+		a.admitHost(session, u)
+	}
+}
+
+// voiceReceived marks who is talking. The voice packets start with
+// a byte for the type and the target, and the session of the speaker
+func (a *agent) voiceReceived(buf []byte) {
+	if len(buf) < 2 {
+		return
+	}
+
+	pds := packetdata.New(buf[1:])
+	session := pds.GetUint32()
+	if !pds.IsValid() {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, ok := a.users[session]
+	if !ok {
+		return
+	}
+
+	a.recordVoice(buf)
+
+	u.lastVoice = time.Now()
+	u.lastActivity = u.lastVoice
+	if !u.talking {
+		u.talking = true
+		a.notifyChanged(ParticipantTalking, session, u)
+	}
+}
+
+func (a *agent) watchTalking() {
+	t := time.NewTicker(agentTalkingTimeout / 2)
+	defer t.Stop()
+
+	for range t.C {
+		if a.isClosed() {
+			return
+		}
+
+		a.lock.Lock()
+		for session, u := range a.users {
+			if u.talking && time.Since(u.lastVoice) > agentTalkingTimeout {
+				u.talking = false
+				a.notifyChanged(ParticipantTalking, session, u)
+			}
+		}
+		a.lock.Unlock()
+	}
+}
+
+// newAgentCertificate returns a self signed certificate that
+// is only used while the agent is connected
+func newAgentCertificate() (tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject: pkix.Name{
+			CommonName: agentName,
+		},
+		NotBefore: now.Add(-300 * time.Second),
+		NotAfter:  now.Add(24 * time.Hour * 365),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, nil
+}
+
+func (a *agent) isSynced() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.synced
+}
+
+func (a *agent) isClosed() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.closed
+}
+
+// checkUser returns the user with the given session, which can't be
+// the agent itself. It must be called with the lock held
+func (a *agent) checkUser(session uint32) (*agentUser, error) {
+	if a.closed {
+		return nil, errAgentClosed
+	}
+
+	u, ok := a.users[session]
+	if !ok || session == a.self {
+		return nil, errParticipantNotFound
+	}
+
+	return u, nil
+}
+
+// announce sends a text message to everybody in the meeting, in any of
+// the rooms or in the waiting room. It returns how many got it
+func (a *agent) announce(text string) (int, error) {
+	a.lock.Lock()
+	if a.closed {
+		a.lock.Unlock()
+		return 0, errAgentClosed
+	}
+
+	var sessions []uint32
+	for session := range a.users {
+		if session != a.self {
+			sessions = append(sessions, session)
+		}
+	}
+	a.lock.Unlock()
+
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	err := a.send(mumbleproto.MessageTextMessage, &mumbleproto.TextMessage{
+		Session: sessions,
+		Message: proto.String(text),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(sessions), nil
+}
+
+func (a *agent) close() {
+	_ = a.Stop()
+
+	a.lock.Lock()
+	a.closed = true
+	a.lock.Unlock()
+
+	_ = a.conn.Close()
+}
+
+// send writes a message of the Mumble protocol, which is the type and
+// the length of the message, in big endian, followed by the message
+func (a *agent) send(kind uint16, m proto.Message) error {
+	buf, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint16(header, kind)
+	binary.BigEndian.PutUint32(header[2:], uint32(len(buf)))
+
+	a.writeLock.Lock()
+	defer a.writeLock.Unlock()
+
+	_, err = a.conn.Write(append(header, buf...))
+	return err
+}
+
+func readMumbleMessage(r io.Reader) (uint16, []byte, error) {
+	header := make([]byte, 6)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint32(header[2:]))
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return binary.BigEndian.Uint16(header), buf, nil
+}
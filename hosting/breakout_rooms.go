@@ -0,0 +1,276 @@
+package hosting
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The breakout rooms are channels of the Mumble server, inside of the
+// channel where the meeting happens. The agent creates them, and then
+// makes them inherit the ACLs of the meeting, so the moderators and
+// the waiting room work the same way in all of them
+
+// MainRoom is the room of the participants that are not in a breakout room
+const MainRoom uint32 = 0
+
+var (
+	errInvalidRoomName = failure.New("hosting.invalid-room-name", failure.CategoryHosting, "the breakout room needs a name", "")
+	errRoomExists      = failure.New("hosting.room-exists", failure.CategoryHosting, "there is already a breakout room with that name", "choose another name for the breakout room")
+	errRoomNotFound    = failure.New("hosting.room-not-found", failure.CategoryHosting, "the breakout room doesn't exist anymore", "")
+)
+
+// BreakoutRoom is a room where some of the participants
+// talk apart from the rest of the meeting
+type BreakoutRoom struct {
+	ID   uint32
+	Name string
+}
+
+// BreakoutRooms lets the host split the meeting in smaller groups
+type BreakoutRooms interface {
+	// WhenRoomsChanged calls the given function, in a goroutine of its
+	// own, every time a room is created or removed. The function
+	// returned stops the calls
+	WhenRoomsChanged(func()) func()
+
+	// Rooms returns the breakout rooms of the meeting, by creation
+	Rooms() []BreakoutRoom
+
+	// CreateRoom creates a new breakout room with the given name
+	CreateRoom(name string) error
+
+	// MoveToRoom moves the participant into the breakout room,
+	// or back to the meeting when the room is MainRoom
+	MoveToRoom(session, room uint32) error
+
+	// RemoveRoom removes the breakout room, and the participants
+	// in it go back to the meeting
+	RemoveRoom(room uint32) error
+}
+
+type agentChannel struct {
+	name   string
+	parent uint32
+}
+
+// mainChannel returns the channel where the meeting happens. It
+// must be called with the lock held
+func (a *agent) mainChannel() uint32 {
+	if a.waitingRoom {
+		return a.meeting
+	}
+	return 0
+}
+
+// isRoom returns whether the channel is a breakout room. It
+// must be called with the lock held
+func (a *agent) isRoom(id uint32) bool {
+	c, ok := a.channels[id]
+	return ok && id != 0 && id != a.mainChannel() && c.parent == a.mainChannel()
+}
+
+// roomOf returns the breakout room the user is in. It
+// must be called with the lock held
+func (a *agent) roomOf(u *agentUser) uint32 {
+	if a.isRoom(u.channel) {
+		return u.channel
+	}
+	return MainRoom
+}
+
+func (a *agent) updateChannel(m *mumbleproto.ChannelState) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := m.GetChannelId()
+	c, known := a.channels[id]
+	if !known {
+		c = &agentChannel{}
+		a.channels[id] = c
+	}
+
+	if m.Name != nil {
+		c.name = m.GetName()
+	}
+	if m.Parent != nil {
+		c.parent = m.GetParent()
+	}
+
+	if id != 0 && c.name == meetingChannelName && c.parent == 0 {
+		a.meeting = id
+	}
+
+	if known {
+		return
+	}
+
+	// A room created by the agent, which doesn't inherit the ACLs yet
+	if c.parent == a.mainChannel() && a.creating[c.name] {
+		delete(a.creating, c.name)
+		go a.inheritACL(id)
+	}
+
+	if a.synced && a.isRoom(id) {
+		a.notifyRoomsChanged()
+	}
+}
+
+func (a *agent) removeChannel(id uint32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	room := a.isRoom(id)
+	delete(a.channels, id)
+
+	if a.synced && room {
+		a.notifyRoomsChanged()
+	}
+}
+
+// inheritACL replaces the ACLs that grumble gives to the channels it
+// creates. Grumble adds back the one that lets the agent remove it
+func (a *agent) inheritACL(id uint32) {
+	_ = a.send(mumbleproto.MessageACL, &mumbleproto.ACL{
+		ChannelId:   proto.Uint32(id),
+		InheritAcls: proto.Bool(true),
+		Query:       proto.Bool(false),
+	})
+}
+
+func (a *agent) notifyRoomsChanged() {
+	for _, f := range a.onRooms {
+		go f()
+	}
+}
+
+func (a *agent) WhenRoomsChanged(f func()) func() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.nextID
+	a.nextID++
+	a.onRooms[id] = f
+
+	return func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		delete(a.onRooms, id)
+	}
+}
+
+func (a *agent) Rooms() []BreakoutRoom {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var result []BreakoutRoom
+	for id, c := range a.channels {
+		if a.isRoom(id) {
+			result = append(result, BreakoutRoom{ID: id, Name: c.name})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+func (a *agent) CreateRoom(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errInvalidRoomName
+	}
+
+	a.lock.Lock()
+	if a.closed {
+		a.lock.Unlock()
+		return errAgentClosed
+	}
+
+	for id, c := range a.channels {
+		if a.isRoom(id) && c.name == name {
+			a.lock.Unlock()
+			return errRoomExists
+		}
+	}
+
+	a.creating[name] = true
+	parent := a.mainChannel()
+	a.lock.Unlock()
+
+	return a.send(mumbleproto.MessageChannelState, &mumbleproto.ChannelState{
+		Parent:    proto.Uint32(parent),
+		Name:      proto.String(name),
+		Temporary: proto.Bool(false),
+		Position:  proto.Int32(0),
+	})
+}
+
+func (a *agent) MoveToRoom(session, room uint32) error {
+	a.lock.Lock()
+	_, err := a.checkUser(session)
+	if err == nil && room != MainRoom && !a.isRoom(room) {
+		err = errRoomNotFound
+	}
+	main := a.mainChannel()
+	a.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	channel := room
+	if room == MainRoom {
+		channel = main
+	}
+
+	return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+		Session:   proto.Uint32(session),
+		ChannelId: proto.Uint32(channel),
+	})
+}
+
+func (a *agent) RemoveRoom(room uint32) error {
+	a.lock.Lock()
+	closed := a.closed
+	exists := a.isRoom(room)
+	main := a.mainChannel()
+	var inRoom []uint32
+	for session, u := range a.users {
+		if u.channel == room {
+			inRoom = append(inRoom, session)
+		}
+	}
+	a.lock.Unlock()
+
+	if closed {
+		return errAgentClosed
+	}
+
+	if !exists {
+		return errRoomNotFound
+	}
+
+	// Grumble would move them to a channel they can enter by
+	// themselves, which is the waiting room when there is one
+	for _, session := range inRoom {
+		err := a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+			Session:   proto.Uint32(session),
+			ChannelId: proto.Uint32(main),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.send(mumbleproto.MessageChannelRemove, &mumbleproto.ChannelRemove{
+		ChannelId: proto.Uint32(room),
+	})
+}
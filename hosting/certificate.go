@@ -2,7 +2,9 @@ package hosting
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -15,24 +17,71 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
 )
 
 type webserver struct {
 	sync.WaitGroup
-	port    int
-	address string
-	cert    []byte
-	running bool
-	server  *http.Server
+	port      int
+	address   string
+	socket    string
+	cert      []byte
+	der       []byte
+	digest    string
+	token     string
+	onionHost string
+	running   bool
+	server    *http.Server
+	tls       *tls.Config
+	requests  *requestLimiter
+	failures  *requestLimiter
 }
 
 const certServerPort = 8181
 
-func newCertificateServer(dir string) (*webserver, error) {
+// InvitationTokenParam is the name of the URL parameter used to send
+// the invitation token to the certificate server
+const InvitationTokenParam = "token"
+
+// CertificateURLParam is the name of the URL parameter used to tell the
+// client where the certificate of an external Mumble server is
+const CertificateURLParam = "certificate"
+
+// FingerprintParam is the name of the URL parameter used to give the
+// client the fingerprint of the certificate it should receive
+const FingerprintParam = "fingerprint"
+
+// EmbeddedCertificateParam is the name of the URL parameter used to give
+// the client the certificate itself, so it doesn't have to request it
+const EmbeddedCertificateParam = "cert"
+
+const (
+	maxCertificateRequestsPerMinute = 30
+	maxInvalidTokensPerMinute       = 5
+)
+
+// certificatePath is the only path the certificate server answers
+const certificatePath = "/"
+
+// maxCertificateRequestHeaderSize is much more than what a
+// request for the certificate needs, which is only the token
+const maxCertificateRequestHeaderSize = 4 << 10
+
+var (
+	errCertificateFileMissing = failure.New("hosting.certificate-file-missing", failure.CategoryHosting, "the certificate file do not exists", "")
+	errCertServerCantListen   = failure.New("hosting.certificate-server-cant-listen", failure.CategoryHosting, "the certificate server of the meeting can't be started", "")
+)
+
+// newCertificateServer creates the server that gives the certificate of
+// the meeting to the participants. When onionOnly is true, it listens on
+// a Unix socket in the directory, instead of the local port, so only Tor
+// can connect to it
+func newCertificateServer(dir, token string, port int, onionOnly bool) (*webserver, error) {
 	certFile := filepath.Join(dir, "cert.pem")
 	if !fileExists(certFile) {
-		return nil, errors.New("the certificate file do not exists")
+		return nil, errCertificateFileMissing
 	}
 
 	cert, err := ioutil.ReadFile(filepath.Clean(certFile))
@@ -40,28 +89,47 @@ func newCertificateServer(dir string) (*webserver, error) {
 		return nil, err
 	}
 
-	port := config.GetRandomPort()
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return nil, errCertificateFileMissing
+	}
+
 	address := net.JoinHostPort(defaultHost, strconv.Itoa(port))
 
+	tlsConfig, err := certificateTLSConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &webserver{
-		port:    port,
-		address: address,
-		cert:    cert,
+		port:     port,
+		address:  address,
+		cert:     cert,
+		der:      block.Bytes,
+		digest:   invitation.CertificateFingerprint(block.Bytes),
+		token:    token,
+		tls:      tlsConfig,
+		requests: newRequestLimiter(maxCertificateRequestsPerMinute, time.Minute),
+		failures: newRequestLimiter(maxInvalidTokensPerMinute, time.Minute),
 	}
 
-	h := http.NewServeMux()
-	h.HandleFunc("/", s.handleCertificateRequest)
+	if onionOnly {
+		s.socket = filepath.Join(dir, fmt.Sprintf("cert-%d.sock", port))
+	}
 
 	s.server = &http.Server{
 		Addr:    address,
-		Handler: h,
+		Handler: http.HandlerFunc(s.handleCertificateRequest),
 
 		// Set sensible timeouts, in case no reverse proxy is in front of Grumble.
 		// Non-conforming (or malicious) clients may otherwise block indefinitely and cause
-		// file descriptors (or handles, depending on your OS) to leak and/or be exhausted
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Minute,
+		// file descriptors (or handles, depending on your OS) to leak and/or be exhausted.
+		// A participant only makes one small request, so the connections don't stay open
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		MaxHeaderBytes:    maxCertificateRequestHeaderSize,
 	}
 
 	log.WithFields(log.Fields{
@@ -72,28 +140,75 @@ func newCertificateServer(dir string) (*webserver, error) {
 	return s, nil
 }
 
-func (h *webserver) start(onFails func(error)) {
+// start listens for the requests of the certificate, and serves them
+// in the background until the server is stopped
+func (h *webserver) start() error {
 	if h.running {
 		log.Error("Certificate HTTP server is already running")
-		return
+		return nil
 	}
 
-	go func() {
-		log.WithFields(log.Fields{
-			"address": h.address,
-		}).Debug("Starting Mumble certificate HTTP server")
+	log.WithFields(log.Fields{
+		"address": h.address,
+	}).Debug("Starting Mumble certificate HTTP server")
+
+	l, err := h.listen()
+	if err != nil {
+		return errCertServerCantListen.Wrap(err)
+	}
 
-		h.running = true
+	h.running = true
 
-		err := h.server.ListenAndServe()
+	go func() {
+		err := h.server.Serve(&optionalTLSListener{Listener: l, config: h.tls})
 		if err != http.ErrServerClosed {
-			if onFails != nil {
-				onFails(err)
-			}
+			log.WithFields(log.Fields{
+				"address": h.address,
+			}).Errorf("Mumble certificate HTTP server: %v", err)
 		}
-
-		h.running = false
 	}()
+
+	return nil
+}
+
+func (h *webserver) listen() (net.Listener, error) {
+	if h.socket == "" {
+		return net.Listen("tcp", h.address)
+	}
+
+	// A socket left behind by a Wahay that crashed
+	_ = os.Remove(h.socket)
+
+	// Tor is run by the same user, so nobody else needs the socket
+	l, err := net.Listen("unix", h.socket)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(h.socket, 0600)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// onionPort returns where the onion service sends the
+// requests for the certificate of the meeting
+func (h *webserver) onionPort() tor.OnionPort {
+	if h.socket != "" {
+		return tor.OnionPort{
+			DestinationSocket: h.socket,
+			ServicePort:       certServerPort,
+		}
+	}
+
+	return tor.OnionPort{
+		DestinationHost: defaultHost,
+		DestinationPort: h.port,
+		ServicePort:     certServerPort,
+	}
 }
 
 func (h *webserver) stop() error {
@@ -125,11 +240,125 @@ func (h *webserver) stop() error {
 	return nil
 }
 
+// setOnionHost restricts the requests to the ones arriving
+// through the given onion service address
+func (h *webserver) setOnionHost(host string) {
+	h.onionHost = host
+}
+
 func (h *webserver) handleCertificateRequest(w http.ResponseWriter, r *http.Request) {
+	// The token is checked first, so the requests without it can't
+	// use up the limits of the participants that have it
+	if !h.isValidToken(r.URL.Query().Get(InvitationTokenParam)) {
+		if !h.failures.allow() {
+			log.Warning("handleCertificateRequest(): too many invalid invitation tokens")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		log.Warning("handleCertificateRequest(): invalid invitation token")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if !h.requests.allow() {
+		log.Warning("handleCertificateRequest(): too many requests")
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	if !h.isRequestFromOnion(r) {
+		log.WithFields(log.Fields{
+			"host": r.Host,
+		}).Warning("handleCertificateRequest(): request not coming from the onion service")
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Path != certificatePath {
+		log.WithFields(log.Fields{
+			"path": r.URL.Path,
+		}).Warning("handleCertificateRequest(): request for an unknown path")
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		log.WithFields(log.Fields{
+			"method": r.Method,
+		}).Warning("handleCertificateRequest(): request with a method not allowed")
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
 	log.Debug("handleCertificateRequest(): serving certificate content")
 	fmt.Fprint(w, string(h.cert))
 }
 
+func (h *webserver) isRequestFromOnion(r *http.Request) bool {
+	if h.onionHost == "" {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	return host == h.onionHost
+}
+
+func (h *webserver) isValidToken(token string) bool {
+	if h.token == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(h.token), []byte(token)) == 1
+}
+
+// requestLimiter allows a maximum amount of events in a sliding window
+// of time. Since every request arrives through Tor, we can't
+// distinguish the clients, so the limit applies to all of them. That's
+// why the requests without a valid token are counted apart, and they
+// never block the ones that have it
+type requestLimiter struct {
+	sync.Mutex
+	max    int
+	window time.Duration
+	events []time.Time
+}
+
+func newRequestLimiter(max int, window time.Duration) *requestLimiter {
+	return &requestLimiter{
+		max:    max,
+		window: window,
+	}
+}
+
+func (l *requestLimiter) clean(now time.Time) {
+	i := 0
+	for i < len(l.events) && now.Sub(l.events[i]) >= l.window {
+		i++
+	}
+	l.events = l.events[i:]
+}
+
+func (l *requestLimiter) allow() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.clean(now)
+
+	if len(l.events) >= l.max {
+		return false
+	}
+
+	l.events = append(l.events, now)
+
+	return true
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
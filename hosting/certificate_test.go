@@ -0,0 +1,169 @@
+package hosting
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+type CertificateServerSuite struct {
+	dir string
+}
+
+var _ = Suite(&CertificateServerSuite{})
+
+// writeTestCertificate writes a self-signed certificate and its key
+// where the Mumble server of the meeting leaves them
+func writeTestCertificate(c *C, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Wahay"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	c.Assert(err, IsNil)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	c.Assert(err, IsNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600), IsNil)
+}
+
+func (s *CertificateServerSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-certificate")
+	c.Assert(err, IsNil)
+	s.dir = dir
+
+	writeTestCertificate(c, dir)
+}
+
+func (s *CertificateServerSuite) TearDownTest(c *C) {
+	_ = os.RemoveAll(s.dir)
+}
+
+// overSocket makes the requests through the Unix socket of the
+// server, like Tor does for the onion service
+func overSocket(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func (s *CertificateServerSuite) Test_start_listensOnTheSocketLeftBehindByACrash(c *C) {
+	h, err := newCertificateServer(s.dir, "the token", 45678, true)
+	c.Assert(err, IsNil)
+	h.setOnionHost("example.onion")
+
+	// A socket nobody listens on anymore
+	l, err := net.Listen("unix", h.socket)
+	c.Assert(err, IsNil)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	c.Assert(l.Close(), IsNil)
+	_, err = os.Stat(h.socket)
+	c.Assert(err, IsNil)
+
+	c.Assert(h.start(), IsNil)
+	defer h.stop()
+
+	resp, err := overSocket(h.socket).Get("http://example.onion:8181/?token=the+token")
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+
+	st, err := os.Stat(h.socket)
+	c.Assert(err, IsNil)
+	c.Assert(st.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *CertificateServerSuite) Test_start_failsWhenItCantListen(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	h, err := newCertificateServer(s.dir, "the token", l.Addr().(*net.TCPAddr).Port, false)
+	c.Assert(err, IsNil)
+
+	c.Assert(failure.Kind(h.start()), Equals, errCertServerCantListen)
+	c.Assert(h.running, Equals, false)
+}
+
+// serveTestRequest makes the request to the handler and returns the status
+func serveTestRequest(handler http.HandlerFunc, r *http.Request) int {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func (s *CertificateServerSuite) Test_handleCertificateRequest_rejectsTheInvalidTokensBeforeTheLimits(c *C) {
+	h, err := newCertificateServer(s.dir, "the token", 45678, true)
+	c.Assert(err, IsNil)
+	h.setOnionHost("example.onion")
+
+	for _, target := range []string{
+		"http://example.onion:8181/",
+		"http://example.onion:8181/?token=",
+		"http://example.onion:8181/?token=another+token",
+	} {
+		c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, target, nil)), Equals, http.StatusForbidden, Commentf("URL: %s", target))
+	}
+	c.Assert(h.requests.events, HasLen, 0)
+
+	for i := 3; i < maxInvalidTokensPerMinute; i++ {
+		c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=another+token", nil)), Equals, http.StatusForbidden)
+	}
+	c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=another+token", nil)), Equals, http.StatusTooManyRequests)
+	c.Assert(h.requests.events, HasLen, 0)
+
+	w := httptest.NewRecorder()
+	h.handleCertificateRequest(w, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=the+token", nil))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, string(h.cert))
+	c.Assert(h.requests.events, HasLen, 1)
+}
+
+func (s *CertificateServerSuite) Test_handleCertificateRequest_limitsTheRequestsWithAValidToken(c *C) {
+	h, err := newCertificateServer(s.dir, "the token", 45678, true)
+	c.Assert(err, IsNil)
+	h.setOnionHost("example.onion")
+
+	for i := 0; i < maxCertificateRequestsPerMinute; i++ {
+		c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=the+token", nil)), Equals, http.StatusOK)
+	}
+	c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=the+token", nil)), Equals, http.StatusTooManyRequests)
+}
+
+func (s *CertificateServerSuite) Test_handleCertificateRequest_neverAcceptsAnEmptyToken(c *C) {
+	h, err := newCertificateServer(s.dir, "", 45678, true)
+	c.Assert(err, IsNil)
+	h.setOnionHost("example.onion")
+
+	c.Assert(serveTestRequest(h.handleCertificateRequest, httptest.NewRequest(http.MethodGet, "http://example.onion:8181/?token=", nil)), Equals, http.StatusForbidden)
+	c.Assert(h.requests.events, HasLen, 0)
+}
@@ -0,0 +1,90 @@
+package hosting
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+)
+
+// The certificate server uses the certificate it gives for TLS too, so
+// the participants can check that the certificate they receive is the
+// one of the server they are talking to. Older versions of Wahay request
+// the certificate without TLS, so both are accepted in the same port,
+// looking at the first byte that the client sends
+
+// tlsHandshakeRecord is the first byte of every TLS connection
+const tlsHandshakeRecord = 0x16
+
+func certificateTLSConfig(dir string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// optionalTLSListener accepts connections with and without TLS
+type optionalTLSListener struct {
+	net.Listener
+	config *tls.Config
+}
+
+func (l *optionalTLSListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &optionalTLSConn{Conn: c, config: l.config}, nil
+}
+
+// optionalTLSConn waits until the first read to know if the client uses
+// TLS, so a client that sends nothing doesn't stop the other connections
+// from being accepted. The timeouts of the server still apply to it.
+// Closing it closes the connection without the TLS close notification,
+// which the clients don't need, since the responses have their length
+type optionalTLSConn struct {
+	net.Conn
+	config *tls.Config
+
+	once sync.Once
+	rw   io.ReadWriter
+}
+
+func (c *optionalTLSConn) detect() {
+	r := bufio.NewReader(c.Conn)
+	plain := &peekedConn{Conn: c.Conn, r: r}
+	c.rw = plain
+
+	first, err := r.Peek(1)
+	if err == nil && first[0] == tlsHandshakeRecord {
+		c.rw = tls.Server(plain, c.config)
+	}
+}
+
+func (c *optionalTLSConn) Read(b []byte) (int, error) {
+	c.once.Do(c.detect)
+	return c.rw.Read(b)
+}
+
+func (c *optionalTLSConn) Write(b []byte) (int, error) {
+	c.once.Do(c.detect)
+	return c.rw.Write(b)
+}
+
+// peekedConn reads what was already peeked from the connection first
+type peekedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
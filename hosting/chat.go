@@ -0,0 +1,163 @@
+package hosting
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The chat of the meeting is made of the text messages of Mumble. The
+// agent is in the channel of the meeting, so it gets what is written
+// there, like the Mumble clients of the participants do, and what the
+// host writes is sent there by the agent. The messages of Mumble are
+// HTML, so they are kept as plain text here, and the ones sent are
+// escaped. Only the last messages are kept, while the agent is connected
+
+// maxChatMessages is how many messages of the chat are kept
+const maxChatMessages = 500
+
+var errEmptyChatMessage = failure.New("hosting.empty-chat-message", failure.CategoryHosting, "the message is empty", "write something before sending the message")
+
+// ChatMessage is a text message written in the meeting
+type ChatMessage struct {
+	Time time.Time
+
+	// Session is the participant who wrote the message, and
+	// it's zero for the messages written by the host
+	Session uint32
+	Author  string
+	Text    string
+
+	// Private is true when the message was only sent to
+	// Wahay, instead of everybody in the meeting
+	Private bool
+}
+
+// Chat lets the host write to everybody in the meeting, and
+// read what the participants write
+type Chat interface {
+	// WhenMessage calls the given function, in a goroutine of its own,
+	// every time a message is written. The function returned stops the calls
+	WhenMessage(func(ChatMessage)) func()
+
+	// Messages returns the last messages of the chat, oldest first
+	Messages() []ChatMessage
+
+	// Send writes the message for everybody in the meeting
+	Send(text string) error
+}
+
+var (
+	chatLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	chatTags       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// plainChatText removes the HTML formatting that Mumble
+// uses for the text messages, keeping the line breaks
+func plainChatText(s string) string {
+	s = chatLineBreaks.ReplaceAllString(s, "\n")
+	s = chatTags.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// htmlChatText is the text written by the host, as Mumble shows it
+func htmlChatText(s string) string {
+	return strings.Replace(html.EscapeString(s), "\n", "<br/>", -1)
+}
+
+func (a *agent) chatReceived(m *mumbleproto.TextMessage) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.synced || m.GetActor() == a.self {
+		return
+	}
+
+	name := ""
+	if u, ok := a.users[m.GetActor()]; ok {
+		name = u.name
+		u.lastActivity = time.Now()
+	}
+
+	a.addChatMessage(ChatMessage{
+		Time:    time.Now(),
+		Session: m.GetActor(),
+		Author:  name,
+		Text:    plainChatText(m.GetMessage()),
+		Private: len(m.GetChannelId()) == 0 && len(m.GetTreeId()) == 0,
+	})
+}
+
+// addChatMessage must be called with the lock held
+func (a *agent) addChatMessage(msg ChatMessage) {
+	a.chat = append(a.chat, msg)
+	if len(a.chat) > maxChatMessages {
+		a.chat = a.chat[len(a.chat)-maxChatMessages:]
+	}
+
+	for _, f := range a.onChat {
+		go f(msg)
+	}
+}
+
+func (a *agent) WhenMessage(f func(ChatMessage)) func() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.nextID
+	a.nextID++
+	a.onChat[id] = f
+
+	return func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		delete(a.onChat, id)
+	}
+}
+
+func (a *agent) Messages() []ChatMessage {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return append([]ChatMessage{}, a.chat...)
+}
+
+func (a *agent) Send(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errEmptyChatMessage
+	}
+
+	a.lock.Lock()
+	if a.closed {
+		a.lock.Unlock()
+		return errAgentClosed
+	}
+	channel := a.mainChannel()
+	a.lock.Unlock()
+
+	err := a.send(mumbleproto.MessageTextMessage, &mumbleproto.TextMessage{
+		ChannelId: []uint32{channel},
+		Message:   proto.String(htmlChatText(text)),
+	})
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.addChatMessage(ChatMessage{
+		Time: time.Now(),
+		Text: text,
+	})
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package hosting
+
+import (
+	"strings"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The host can make some participants co-moderators, so the meeting
+// keeps being moderated while the host is away. Like the bans, they are
+// told apart by the certificate of their Mumble client, which the ACLs
+// match with the "$hash" groups. The ACLs of the root channel can only be
+// replaced as a whole, so the agent asks the server for them, and sends
+// them back with the entries of the co-moderators changed
+
+// rootChannel is the channel that every other channel of the meeting inherits from
+const rootChannel = 0
+
+var errCoModeratorNoCertificate = failure.New("hosting.co-moderator-no-certificate", failure.CategoryHosting, "the participant can't be a co-moderator, since their Mumble client has no certificate", "give them the moderator password instead")
+
+func coModeratorGroup(hash string) string {
+	return "$" + hash
+}
+
+// SetCoModerator gives the participant the privileges of a moderator,
+// or takes them back. The participant keeps them when joining again
+// with the same certificate, until the meeting ends
+func (a *agent) SetCoModerator(session uint32, v bool) error {
+	a.lock.Lock()
+	u, err := a.checkUser(session)
+	if err != nil {
+		a.lock.Unlock()
+		return err
+	}
+
+	if u.hash == "" {
+		a.lock.Unlock()
+		return errCoModeratorNoCertificate
+	}
+
+	a.coModerators[strings.ToLower(u.hash)] = v
+	a.notifyChanged(ParticipantChanged, session, u)
+	a.lock.Unlock()
+
+	return a.send(mumbleproto.MessageACL, &mumbleproto.ACL{
+		ChannelId: proto.Uint32(rootChannel),
+		Query:     proto.Bool(true),
+	})
+}
+
+// isCoModerator must be called with the lock held
+func (a *agent) isCoModerator(u *agentUser) bool {
+	return u.hash != "" && a.coModerators[strings.ToLower(u.hash)]
+}
+
+// updateRootACL sends back the ACLs of the root channel that the server
+// has sent, replacing the entries of the participants that have been
+// co-moderators with the ones of the current co-moderators
+func (a *agent) updateRootACL(m *mumbleproto.ACL) {
+	if m.GetChannelId() != rootChannel {
+		return
+	}
+
+	a.lock.Lock()
+	var acls []*mumbleproto.ACL_ChanACL
+	for _, e := range m.Acls {
+		if e.GetInherited() || a.isCoModeratorEntry(e) || a.isAgentEntry(e) {
+			continue
+		}
+		e.Inherited = nil
+		acls = append(acls, e)
+	}
+
+	for hash, v := range a.coModerators {
+		if !v {
+			continue
+		}
+		acls = append(acls, &mumbleproto.ACL_ChanACL{
+			ApplyHere: proto.Bool(true),
+			ApplySubs: proto.Bool(true),
+			Group:     proto.String(coModeratorGroup(hash)),
+			Grant:     proto.Uint32(uint32(moderatorPermissions)),
+			Deny:      proto.Uint32(0),
+		})
+	}
+	a.lock.Unlock()
+
+	for _, g := range m.Groups {
+		g.Inherited = nil
+		g.InheritedMembers = nil
+	}
+
+	_ = a.send(mumbleproto.MessageACL, &mumbleproto.ACL{
+		ChannelId:   proto.Uint32(rootChannel),
+		InheritAcls: proto.Bool(m.GetInheritAcls()),
+		Groups:      m.Groups,
+		Acls:        acls,
+		Query:       proto.Bool(false),
+	})
+}
+
+// isCoModeratorEntry is true for the entries that give the privileges
+// to somebody that is or has been a co-moderator. It must be called
+// with the lock held
+func (a *agent) isCoModeratorEntry(e *mumbleproto.ACL_ChanACL) bool {
+	g := strings.ToLower(e.GetGroup())
+	if !strings.HasPrefix(g, "$") {
+		return false
+	}
+
+	_, ok := a.coModerators[g[1:]]
+	return ok
+}
+
+// isAgentEntry is true for the entry that lets the agent change the
+// ACLs, which the server adds again every time they are changed. It
+// must be called with the lock held
+func (a *agent) isAgentEntry(e *mumbleproto.ACL_ChanACL) bool {
+	self, ok := a.users[a.self]
+	return ok && self.hash != "" && strings.EqualFold(e.GetGroup(), coModeratorGroup(self.hash))
+}
@@ -0,0 +1,243 @@
+package hosting
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// InvitationDistribution is a temporary onion service that serves an
+// invitation file to anybody knowing its link and its password. This
+// way only a short lived link has to be shared in chat platforms,
+// instead of the real address of the meeting
+type InvitationDistribution interface {
+	URL() string
+	Password() string
+	ExpiresAt() time.Time
+	Close() error
+}
+
+// DefaultDistributionLifetime is the time an invitation is available
+// in its temporary onion service, when no other time is given
+const DefaultDistributionLifetime = time.Hour
+
+const (
+	distributionServicePort          = 80
+	distributionPathLength           = 16
+	distributionPasswordLength       = 12
+	distributionFileName             = "meeting" + invitation.FileExtension
+	maxDistributionRequestsPerMinute = 30
+	maxDistributionFailuresPerMinute = 5
+)
+
+var (
+	// ErrDistributionEmptyContent is an error to be trown when
+	// there is no invitation to publish
+	ErrDistributionEmptyContent = failure.New("hosting.distribution-empty", failure.CategoryInput, "there is no invitation to publish", "")
+)
+
+type distribution struct {
+	sync.Mutex
+	path      string
+	password  string
+	content   []byte
+	onion     tor.Onion
+	server    *http.Server
+	expiresAt time.Time
+	timer     *time.Timer
+	closed    bool
+	requests  *requestLimiter
+	failures  *requestLimiter
+	handlers  sync.WaitGroup
+}
+
+// PublishInvitation creates a temporary onion service serving the given
+// invitation file. The service is closed automatically after the
+// given lifetime, or when Close is called. The content is copied, and
+// the copy is wiped once the service is closed
+func PublishInvitation(t tor.Instance, content []byte, lifetime time.Duration) (InvitationDistribution, error) {
+	if len(content) == 0 {
+		return nil, ErrDistributionEmptyContent
+	}
+
+	if lifetime <= 0 {
+		lifetime = DefaultDistributionLifetime
+	}
+
+	path, err := randomHexString(distributionPathLength)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := randomHexString(distributionPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &distribution{
+		path:     "/" + path,
+		password: password,
+		content:  append([]byte{}, content...),
+		requests: newRequestLimiter(maxDistributionRequestsPerMinute, time.Minute),
+		failures: newRequestLimiter(maxDistributionFailuresPerMinute, time.Minute),
+	}
+
+	port := config.GetRandomPort()
+	address := net.JoinHostPort(defaultHost, strconv.Itoa(port))
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	h := http.NewServeMux()
+	h.HandleFunc("/", d.handleRequest)
+
+	d.server = &http.Server{
+		Handler:      h,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  time.Minute,
+	}
+
+	d.onion, err = t.NewOnionServiceWithMultiplePorts([]tor.OnionPort{{
+		DestinationHost: defaultHost,
+		DestinationPort: port,
+		ServicePort:     distributionServicePort,
+	}})
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	go func() {
+		err := d.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.WithFields(log.Fields{
+				"context": "distribution",
+			}).Errorf("the invitation distribution server failed: %s", err)
+		}
+	}()
+
+	d.expiresAt = time.Now().Add(lifetime)
+	d.timer = time.AfterFunc(lifetime, func() {
+		log.Info("The invitation distribution link has expired")
+		_ = d.Close()
+	})
+
+	log.WithFields(log.Fields{
+		"address":   address,
+		"expiresAt": d.expiresAt,
+	}).Debug("Publishing the invitation in a temporary onion service")
+
+	return d, nil
+}
+
+func randomHexString(length int) (string, error) {
+	s := make([]byte, length)
+	err := config.RandomString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+func (d *distribution) URL() string {
+	return "http://" + d.onion.ID() + d.path
+}
+
+func (d *distribution) Password() string {
+	return d.password
+}
+
+func (d *distribution) ExpiresAt() time.Time {
+	return d.expiresAt
+}
+
+func (d *distribution) Close() error {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	d.timer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := d.server.Shutdown(ctx)
+	cancel()
+
+	if err == context.DeadlineExceeded {
+		err = d.server.Close()
+	}
+
+	if err != nil {
+		log.Errorf("the invitation distribution server can't be stopped: %s", err)
+	}
+
+	err = d.onion.Delete()
+	if err != nil {
+		return ErrServerOnionDelete
+	}
+
+	// The invitation contains the meeting password, so we don't keep
+	// it in memory longer than needed, once no request is using it
+	d.handlers.Wait()
+	for i := range d.content {
+		d.content[i] = 0
+	}
+
+	log.Info("Invitation distribution link closed")
+
+	return nil
+}
+
+func (d *distribution) handleRequest(w http.ResponseWriter, r *http.Request) {
+	d.handlers.Add(1)
+	defer d.handlers.Done()
+
+	if r.Method != http.MethodGet || r.URL.Path != d.path {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The password is checked before the limits, so the requests
+	// without it can't use up the ones of the participants that have it
+	_, password, _ := r.BasicAuth()
+	if subtle.ConstantTimeCompare([]byte(password), []byte(d.password)) != 1 {
+		if password != "" && !d.failures.allow() {
+			log.Warning("distribution: too many invalid passwords")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="Wahay invitation"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if !d.requests.allow() {
+		log.Warning("distribution: too many requests")
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	log.Debug("distribution: serving the invitation file")
+
+	w.Header().Set("Content-Type", invitation.FileMIMEType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+distributionFileName+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(d.content)
+}
@@ -0,0 +1,73 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/digitalautonomy/wahay/invitation"
+
+	. "gopkg.in/check.v1"
+)
+
+type DistributionSuite struct{}
+
+var _ = Suite(&DistributionSuite{})
+
+func newTestDistribution() *distribution {
+	return &distribution{
+		path:     "/0123456789abcdef",
+		password: "the password",
+		content:  []byte("the invitation"),
+		requests: newRequestLimiter(maxDistributionRequestsPerMinute, time.Minute),
+		failures: newRequestLimiter(maxDistributionFailuresPerMinute, time.Minute),
+	}
+}
+
+func distributionRequest(password string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://example.onion/0123456789abcdef", nil)
+	if password != "" {
+		r.SetBasicAuth("", password)
+	}
+	return r
+}
+
+func (s *DistributionSuite) Test_handleRequest_rejectsTheInvalidPasswordsBeforeTheLimits(c *C) {
+	d := newTestDistribution()
+
+	for i := 0; i < maxDistributionFailuresPerMinute+1; i++ {
+		c.Assert(serveTestRequest(d.handleRequest, distributionRequest("")), Equals, http.StatusUnauthorized)
+	}
+	c.Assert(d.failures.events, HasLen, 0)
+
+	for i := 0; i < maxDistributionFailuresPerMinute; i++ {
+		c.Assert(serveTestRequest(d.handleRequest, distributionRequest("another password")), Equals, http.StatusUnauthorized)
+	}
+	c.Assert(serveTestRequest(d.handleRequest, distributionRequest("another password")), Equals, http.StatusTooManyRequests)
+	c.Assert(d.requests.events, HasLen, 0)
+
+	w := httptest.NewRecorder()
+	d.handleRequest(w, distributionRequest("the password"))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "the invitation")
+	c.Assert(w.Header().Get("Content-Type"), Equals, invitation.FileMIMEType)
+	c.Assert(d.requests.events, HasLen, 1)
+}
+
+func (s *DistributionSuite) Test_handleRequest_limitsTheRequestsWithAValidPassword(c *C) {
+	d := newTestDistribution()
+
+	for i := 0; i < maxDistributionRequestsPerMinute; i++ {
+		c.Assert(serveTestRequest(d.handleRequest, distributionRequest("the password")), Equals, http.StatusOK)
+	}
+	c.Assert(serveTestRequest(d.handleRequest, distributionRequest("the password")), Equals, http.StatusTooManyRequests)
+}
+
+func (s *DistributionSuite) Test_handleRequest_onlyServesThePathOfTheInvitation(c *C) {
+	d := newTestDistribution()
+
+	r := httptest.NewRequest(http.MethodGet, "http://example.onion/another", nil)
+	r.SetBasicAuth("", "the password")
+	c.Assert(serveTestRequest(d.handleRequest, r), Equals, http.StatusNotFound)
+	c.Assert(d.requests.events, HasLen, 0)
+}
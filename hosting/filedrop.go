@@ -0,0 +1,485 @@
+package hosting
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// SharedFile is the description of a file shared during a meeting
+type SharedFile struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Size     int       `json:"size"`
+	SharedAt time.Time `json:"sharedAt"`
+}
+
+// FileDrop gives access to the files shared by the participants
+// of a meeting. The files only exist while the meeting is running
+type FileDrop interface {
+	List() ([]SharedFile, error)
+	Download(id string, w io.Writer, progress tor.DownloadProgress) error
+	Upload(name string, content []byte) error
+}
+
+// HostFileDrop is the file drop of a meeting we are hosting, where
+// the host decides what is shared with the participants
+type HostFileDrop interface {
+	FileDrop
+
+	// Remove stops sharing the file, which is forgotten
+	Remove(id string) error
+
+	// SetParticipantsCanShare lets the participants share their own
+	// files, or only download the ones shared by the host
+	SetParticipantsCanShare(bool)
+}
+
+const (
+	// MaxSharedFileSize is the maximum size of a shared file
+	MaxSharedFileSize = 10 * 1024 * 1024
+
+	fileDropServicePort     = 8282
+	fileDropPath            = "/files"
+	fileDropNameParam       = "name"
+	fileDropIDLength        = 16
+	maxSharedFiles          = 100
+	maxSharedFilesSize      = 100 * 1024 * 1024
+	maxSharedFileNameLength = 255
+
+	maxFileDropRequestsPerMinute = 60
+)
+
+var (
+	// ErrSharedFileTooLarge is an error to be trown when the
+	// file is bigger than the maximum size of a shared file
+	ErrSharedFileTooLarge = failure.New("hosting.shared-file-too-large", failure.CategoryInput, "the file is too large to be shared", "")
+
+	// ErrSharedFileEmpty is an error to be trown when the file has no content
+	ErrSharedFileEmpty = failure.New("hosting.shared-file-empty", failure.CategoryInput, "the file is empty", "")
+
+	// ErrSharedFileInvalidName is an error to be trown when
+	// the name of the shared file can't be used
+	ErrSharedFileInvalidName = failure.New("hosting.shared-file-invalid-name", failure.CategoryInput, "the name of the file is not valid", "")
+
+	// ErrSharedFileNotFound is an error to be trown when the
+	// requested file is not shared anymore
+	ErrSharedFileNotFound = failure.New("hosting.shared-file-not-found", failure.CategoryMeeting, "the file is not shared anymore", "")
+
+	// ErrFileDropFull is an error to be trown when no more
+	// files can be shared during the meeting
+	ErrFileDropFull = failure.New("hosting.file-drop-full", failure.CategoryMeeting, "no more files can be shared in this meeting", "")
+
+	// ErrFileDropReadOnly is an error to be trown when a participant
+	// shares a file in a meeting where only the host can do it
+	ErrFileDropReadOnly = failure.New("hosting.file-drop-read-only", failure.CategoryMeeting, "only the host can share files in this meeting", "ask the host to share the file")
+)
+
+type sharedFileContent struct {
+	SharedFile
+	content []byte
+}
+
+// fileDropServer keeps the shared files in memory, so nothing is
+// written to the disk and everything disappears with the meeting
+type fileDropServer struct {
+	sync.Mutex
+	port     int
+	address  string
+	token    string
+	files    map[string]*sharedFileContent
+	total    int
+	running  bool
+	server   *http.Server
+	requests *requestLimiter
+	failures *requestLimiter
+
+	// participantsCanShare is false when only the host shares files,
+	// and the participants can only download them
+	participantsCanShare bool
+}
+
+func newFileDropServer(token string, port int) *fileDropServer {
+	d := &fileDropServer{
+		port:     port,
+		address:  net.JoinHostPort(defaultHost, strconv.Itoa(port)),
+		token:    token,
+		files:    make(map[string]*sharedFileContent),
+		requests: newRequestLimiter(maxFileDropRequestsPerMinute, time.Minute),
+		failures: newRequestLimiter(maxInvalidTokensPerMinute, time.Minute),
+
+		participantsCanShare: true,
+	}
+
+	h := http.NewServeMux()
+	h.HandleFunc("/", d.handleRequest)
+
+	d.server = &http.Server{
+		Addr:    d.address,
+		Handler: h,
+
+		// Uploads over Tor can be slow, so the timeouts are
+		// longer than the ones of the certificate server
+		ReadTimeout:  5 * time.Minute,
+		WriteTimeout: 5 * time.Minute,
+		IdleTimeout:  10 * time.Minute,
+	}
+
+	return d
+}
+
+func (d *fileDropServer) start() {
+	if d.running {
+		log.Error("File drop server is already running")
+		return
+	}
+	d.running = true
+
+	go func() {
+		log.WithFields(log.Fields{
+			"address": d.address,
+		}).Debug("Starting the file drop server")
+
+		err := d.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.WithFields(log.Fields{
+				"context": "filedrop",
+			}).Errorf("the file drop server failed: %s", err)
+		}
+	}()
+}
+
+func (d *fileDropServer) stop() error {
+	d.Lock()
+	for id, f := range d.files {
+		for i := range f.content {
+			f.content[i] = 0
+		}
+		delete(d.files, id)
+	}
+	d.total = 0
+	d.Unlock()
+
+	if !d.running {
+		return nil
+	}
+	d.running = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := d.server.Shutdown(ctx)
+	if err != nil && err != context.DeadlineExceeded {
+		return err
+	}
+
+	return nil
+}
+
+func (d *fileDropServer) List() ([]SharedFile, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	result := make([]SharedFile, 0, len(d.files))
+	for _, f := range d.files {
+		result = append(result, f.SharedFile)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SharedAt.Before(result[j].SharedAt)
+	})
+
+	return result, nil
+}
+
+func (d *fileDropServer) Download(id string, w io.Writer, progress tor.DownloadProgress) error {
+	content, err := d.content(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	if err != nil {
+		return err
+	}
+
+	if progress != nil {
+		progress(int64(len(content)), int64(len(content)))
+	}
+
+	return nil
+}
+
+// content returns a copy of the given file, so it can be
+// sent while other files are being shared or the drop stops
+func (d *fileDropServer) content(id string) ([]byte, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	f, ok := d.files[id]
+	if !ok {
+		return nil, ErrSharedFileNotFound
+	}
+
+	content := make([]byte, len(f.content))
+	copy(content, f.content)
+
+	return content, nil
+}
+
+func (d *fileDropServer) Upload(name string, content []byte) error {
+	name, err := validateSharedFile(name, content)
+	if err != nil {
+		return err
+	}
+
+	id, err := randomHexString(fileDropIDLength)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.files) >= maxSharedFiles || d.total+len(content) > maxSharedFilesSize {
+		return ErrFileDropFull
+	}
+
+	d.files[id] = &sharedFileContent{
+		SharedFile: SharedFile{
+			ID:       id,
+			Name:     name,
+			Size:     len(content),
+			SharedAt: time.Now(),
+		},
+		content: content,
+	}
+	d.total += len(content)
+
+	log.WithFields(log.Fields{
+		"size": len(content),
+	}).Debug("A new file has been shared in the meeting")
+
+	return nil
+}
+
+// Remove clears the content of the file before forgetting
+// it, like it's done with all of them when the meeting finishes
+func (d *fileDropServer) Remove(id string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	f, ok := d.files[id]
+	if !ok {
+		return ErrSharedFileNotFound
+	}
+
+	for i := range f.content {
+		f.content[i] = 0
+	}
+	delete(d.files, id)
+	d.total -= f.Size
+
+	log.WithFields(log.Fields{
+		"size": f.Size,
+	}).Debug("A shared file has been removed from the meeting")
+
+	return nil
+}
+
+func (d *fileDropServer) SetParticipantsCanShare(v bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.participantsCanShare = v
+}
+
+func (d *fileDropServer) canParticipantsShare() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.participantsCanShare
+}
+
+// validateSharedFile checks the given file and returns the name
+// that will be shown to the participants of the meeting
+func validateSharedFile(name string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", ErrSharedFileEmpty
+	}
+
+	if len(content) > MaxSharedFileSize {
+		return "", ErrSharedFileTooLarge
+	}
+
+	// Participants must never be able to choose where
+	// the file is saved, so we only keep its base name
+	name = strings.TrimSpace(filepath.Base(strings.Replace(name, `\`, "/", -1)))
+	if name == "" || name == "." || name == "/" || !utf8.ValidString(name) || len(name) > maxSharedFileNameLength {
+		return "", ErrSharedFileInvalidName
+	}
+
+	for _, r := range name {
+		if r < ' ' || r == 0x7f {
+			return "", ErrSharedFileInvalidName
+		}
+	}
+
+	return name, nil
+}
+
+func (d *fileDropServer) isValidToken(token string) bool {
+	if d.token == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+}
+
+func (d *fileDropServer) handleRequest(w http.ResponseWriter, r *http.Request) {
+	// The token is checked first, so the requests without it can't
+	// use up the limits of the participants that have it
+	if !d.isValidToken(r.URL.Query().Get(InvitationTokenParam)) {
+		if !d.failures.allow() {
+			log.Warning("filedrop: too many invalid tokens")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if !d.requests.allow() {
+		log.Warning("filedrop: too many requests")
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch {
+	case r.URL.Path == fileDropPath && r.Method == http.MethodGet:
+		d.handleList(w)
+	case r.URL.Path == fileDropPath && r.Method == http.MethodPost:
+		d.handleUpload(w, r)
+	case strings.HasPrefix(r.URL.Path, fileDropPath+"/") && r.Method == http.MethodGet:
+		d.handleDownload(w, strings.TrimPrefix(r.URL.Path, fileDropPath+"/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *fileDropServer) handleList(w http.ResponseWriter) {
+	files, _ := d.List()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(files)
+}
+
+func (d *fileDropServer) handleDownload(w http.ResponseWriter, id string) {
+	content, err := d.content(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	_, _ = w.Write(content)
+}
+
+func (d *fileDropServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !d.canParticipantsShare() {
+		http.Error(w, ErrFileDropReadOnly.Error(), http.StatusForbidden)
+		return
+	}
+
+	content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxSharedFileSize+1))
+	if err != nil {
+		http.Error(w, ErrSharedFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = d.Upload(r.URL.Query().Get(fileDropNameParam), content)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+	case ErrSharedFileTooLarge, ErrFileDropFull:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case ErrSharedFileEmpty, ErrSharedFileInvalidName:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+type remoteFileDrop struct {
+	t     tor.Instance
+	base  string
+	token string
+}
+
+// NewRemoteFileDrop returns the file drop of a meeting hosted by
+// somebody else. All the requests go through the given Tor instance
+func NewRemoteFileDrop(t tor.Instance, host, token string) FileDrop {
+	return &remoteFileDrop{
+		t:     t,
+		base:  "http://" + net.JoinHostPort(host, strconv.Itoa(fileDropServicePort)) + fileDropPath,
+		token: token,
+	}
+}
+
+func (d *remoteFileDrop) url(path string, params url.Values) string {
+	params.Set(InvitationTokenParam, d.token)
+	return d.base + path + "?" + params.Encode()
+}
+
+func (d *remoteFileDrop) List() ([]SharedFile, error) {
+	content, err := d.t.HTTPrequest(d.url("", url.Values{}))
+	if err != nil {
+		return nil, err
+	}
+
+	files := []SharedFile{}
+	err = json.Unmarshal([]byte(content), &files)
+	if err != nil {
+		return nil, fmt.Errorf("the list of shared files is not valid: %s", err)
+	}
+
+	return files, nil
+}
+
+func (d *remoteFileDrop) Download(id string, w io.Writer, progress tor.DownloadProgress) error {
+	_, err := d.t.HTTPDownload(d.url("/"+url.PathEscape(id), url.Values{}), w, MaxSharedFileSize, progress)
+	if err == tor.ErrResponseTooLarge {
+		return ErrSharedFileTooLarge
+	}
+
+	return err
+}
+
+func (d *remoteFileDrop) Upload(name string, content []byte) error {
+	name, err := validateSharedFile(name, content)
+	if err != nil {
+		return err
+	}
+
+	return d.t.HTTPPost(d.url("", url.Values{fileDropNameParam: {name}}), "application/octet-stream", content)
+}
@@ -0,0 +1,71 @@
+package hosting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type FileDropSuite struct{}
+
+var _ = Suite(&FileDropSuite{})
+
+func fileDropRequest(method, target, body string) *http.Request {
+	return httptest.NewRequest(method, "http://example.onion:8282"+target, strings.NewReader(body))
+}
+
+func (s *FileDropSuite) Test_handleRequest_rejectsTheInvalidTokensBeforeTheLimits(c *C) {
+	d := newFileDropServer("the token", 45679)
+
+	for _, target := range []string{"/files", "/files?token=", "/files?token=another+token"} {
+		c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, target, "")), Equals, http.StatusForbidden, Commentf("URL: %s", target))
+	}
+	c.Assert(d.requests.events, HasLen, 0)
+
+	for i := 3; i < maxInvalidTokensPerMinute; i++ {
+		c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodPost, "/files?token=another+token&name=a.txt", "content")), Equals, http.StatusForbidden)
+	}
+	c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, "/files?token=another+token", "")), Equals, http.StatusTooManyRequests)
+	c.Assert(d.requests.events, HasLen, 0)
+
+	files, err := d.List()
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodPost, "/files?token=the+token&name=a.txt", "content")), Equals, http.StatusCreated)
+
+	w := httptest.NewRecorder()
+	d.handleRequest(w, fileDropRequest(http.MethodGet, "/files?token=the+token", ""))
+	c.Assert(w.Code, Equals, http.StatusOK)
+
+	files = nil
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &files), IsNil)
+	c.Assert(files, HasLen, 1)
+	c.Assert(files[0].Name, Equals, "a.txt")
+
+	w = httptest.NewRecorder()
+	d.handleRequest(w, fileDropRequest(http.MethodGet, "/files/"+files[0].ID+"?token=the+token", ""))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(w.Body.String(), Equals, "content")
+	c.Assert(d.requests.events, HasLen, 3)
+}
+
+func (s *FileDropSuite) Test_handleRequest_limitsTheRequestsWithAValidToken(c *C) {
+	d := newFileDropServer("the token", 45679)
+
+	for i := 0; i < maxFileDropRequestsPerMinute; i++ {
+		c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, "/files?token=the+token", "")), Equals, http.StatusOK)
+	}
+	c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, "/files?token=the+token", "")), Equals, http.StatusTooManyRequests)
+}
+
+func (s *FileDropSuite) Test_handleRequest_neverAcceptsAnEmptyToken(c *C) {
+	d := newFileDropServer("", 45679)
+
+	c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, "/files?token=", "")), Equals, http.StatusForbidden)
+	c.Assert(serveTestRequest(d.handleRequest, fileDropRequest(http.MethodGet, "/files", "")), Equals, http.StatusForbidden)
+	c.Assert(d.requests.events, HasLen, 0)
+}
@@ -0,0 +1,102 @@
+package hosting
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	grumbleServer "github.com/digitalautonomy/grumble/server"
+	"github.com/golang/protobuf/proto"
+)
+
+// The host can limit how many participants are in a meeting at once,
+// and remove the ones that stay idle, so a meeting hosted on a small
+// machine or through a slow circuit keeps working for the others.
+// Grumble only tells the Mumble clients the maximum, without enforcing
+// it, and it doesn't look at the idle time, so the agent does both. The
+// host is never removed, whether joining as the SuperUser, which is the
+// only registered participant, or with the certificate Wahay generated
+// for their Mumble client
+
+// idleCheckInterval is how often the agent looks for idle participants
+const idleCheckInterval = 30 * time.Second
+
+// setMaxUsers tells the Mumble clients how many participants can be in
+// the meeting. The agent is one more for grumble
+func setMaxUsers(n int) func(*grumbleServer.Server) {
+	return func(serv *grumbleServer.Server) {
+		if n > 0 {
+			serv.Set("MaxUsers", strconv.Itoa(n+1))
+		}
+	}
+}
+
+// enforceLimits makes the agent remove the participants that arrive when
+// there are already maxParticipants in the meeting, and the ones that do
+// nothing for idleTimeout. Zero doesn't limit them
+func (a *agent) enforceLimits(maxParticipants int, idleTimeout time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.maxParticipants = maxParticipants
+	a.idleTimeout = idleTimeout
+
+	if idleTimeout > 0 {
+		go a.watchIdle()
+	}
+}
+
+// removeForLimit disconnects the participant with the given reason,
+// only once. It must be called with the lock held
+func (a *agent) removeForLimit(session uint32, u *agentUser, reason string) {
+	if u.removed {
+		return
+	}
+	u.removed = true
+
+	m := &mumbleproto.UserRemove{
+		Session: proto.Uint32(session),
+		Reason:  proto.String(reason),
+	}
+	go func() {
+		_ = a.send(mumbleproto.MessageUserRemove, m)
+	}()
+}
+
+// checkFull removes the participant that has just joined when the
+// meeting was already full. It must be called with the lock held
+func (a *agent) checkFull(session uint32, u *agentUser) {
+	if a.maxParticipants <= 0 || u.registered || a.isHost(u) {
+		return
+	}
+
+	// The agent is one of the users
+	if len(a.users)-1 > a.maxParticipants {
+		a.removeForLimit(session, u, "The meeting is full")
+	}
+}
+
+// isExemptFromIdle returns whether the participant stays in the meeting,
+// even when idle. It must be called with the lock held
+func (a *agent) isExemptFromIdle(session uint32, u *agentUser) bool {
+	return session == a.self || u.registered || a.isHost(u) || a.isCoModerator(u) || a.isWaiting(session, u)
+}
+
+func (a *agent) watchIdle() {
+	t := time.NewTicker(idleCheckInterval)
+	defer t.Stop()
+
+	for range t.C {
+		if a.isClosed() {
+			return
+		}
+
+		a.lock.Lock()
+		for session, u := range a.users {
+			if !a.isExemptFromIdle(session, u) && time.Since(u.lastActivity) > a.idleTimeout {
+				a.removeForLimit(session, u, "You have been removed from the meeting for being idle")
+			}
+		}
+		a.lock.Unlock()
+	}
+}
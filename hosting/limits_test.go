@@ -0,0 +1,110 @@
+package hosting
+
+import (
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	. "gopkg.in/check.v1"
+)
+
+type LimitsSuite struct{}
+
+var _ = Suite(&LimitsSuite{})
+
+// join makes the participant arrive, the way the server tells the agent
+func join(a *agent, session uint32, name, hash string) *agentUser {
+	a.updateUser(&mumbleproto.UserState{
+		Session:   proto.Uint32(session),
+		Name:      proto.String(name),
+		Hash:      proto.String(hash),
+		ChannelId: proto.Uint32(rootChannel),
+	})
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.users[session]
+}
+
+func newSyncedTestAgent() (*agent, <-chan agentMessage) {
+	a, messages := newTestAgent()
+	join(a, a.self, agentName, "")
+	a.synchronized(a.self)
+
+	return a, messages
+}
+
+func nextMessage(c *C, messages <-chan agentMessage, kind uint16, m proto.Message) {
+	select {
+	case msg := <-messages:
+		c.Assert(msg.kind, Equals, kind)
+		c.Assert(proto.Unmarshal(msg.content, m), IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("the agent didn't send the message")
+	}
+}
+
+func noMessage(c *C, messages <-chan agentMessage) {
+	select {
+	case msg := <-messages:
+		c.Fatalf("the agent sent the message %d", msg.kind)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *LimitsSuite) Test_checkFull_removesWhoArrivesToAFullMeetingExceptTheHost(c *C) {
+	a, messages := newSyncedTestAgent()
+	a.maxParticipants = 1
+	a.setHostCertificate("0123abcd")
+
+	alice := join(a, 2, "Alice", "aaaa")
+	host := join(a, 3, "Host", "0123ABCD")
+	noMessage(c, messages)
+	c.Assert(alice.removed, Equals, false)
+	c.Assert(host.removed, Equals, false)
+
+	bob := join(a, 4, "Bob", "bbbb")
+	var m mumbleproto.UserRemove
+	nextMessage(c, messages, mumbleproto.MessageUserRemove, &m)
+	c.Assert(m.GetSession(), Equals, uint32(4))
+	c.Assert(m.GetReason(), Equals, "The meeting is full")
+	c.Assert(bob.removed, Equals, true)
+}
+
+func (s *LimitsSuite) Test_isExemptFromIdle_keepsTheHostAndTheModerators(c *C) {
+	a, _ := newSyncedTestAgent()
+
+	alice := join(a, 2, "Alice", "aaaa")
+	host := join(a, 3, "Host", "0123abcd")
+	admin := join(a, 4, "SuperUser", "")
+	admin.registered = true
+	moderator := join(a, 5, "Bob", "bbbb")
+	a.coModerators["bbbb"] = true
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	c.Assert(a.isExemptFromIdle(a.self, a.users[a.self]), Equals, true)
+	c.Assert(a.isExemptFromIdle(2, alice), Equals, false)
+	c.Assert(a.isExemptFromIdle(3, host), Equals, false)
+	c.Assert(a.isExemptFromIdle(4, admin), Equals, true)
+	c.Assert(a.isExemptFromIdle(5, moderator), Equals, true)
+
+	a.hostHash = "0123ABCD"
+	c.Assert(a.isExemptFromIdle(2, alice), Equals, false)
+	c.Assert(a.isExemptFromIdle(3, host), Equals, true)
+}
+
+func (s *LimitsSuite) Test_isHost_needsTheCertificateOfTheHost(c *C) {
+	a, _ := newSyncedTestAgent()
+	withoutCertificate := join(a, 2, "Alice", "")
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	c.Assert(a.isHost(withoutCertificate), Equals, false)
+	a.hostHash = "0123abcd"
+	c.Assert(a.isHost(withoutCertificate), Equals, false)
+}
@@ -0,0 +1,92 @@
+package hosting
+
+import (
+	"sort"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+)
+
+// The server knows how the connection of every participant works: the
+// pings of their Mumble client, and the voice packets that arrived
+// late or never arrived. The agent asks for them from time to time,
+// since the server doesn't send them by itself
+
+// ParticipantStats is how the connection of a participant to the
+// meeting works, as the server sees it
+type ParticipantStats struct {
+	Session uint32
+	Name    string
+
+	// RoundTrip is the average time the pings of the participant take
+	RoundTrip time.Duration
+
+	// Good, Late and Lost are the voice packets received from the
+	// participant that arrived on time, late, or that never arrived
+	Good uint32
+	Late uint32
+	Lost uint32
+}
+
+// Loss returns the part of the voice packets of the participant that
+// didn't arrive on time, from 0 to 1, or -1 when nothing is known yet.
+// Through Tor the voice goes in the same connection as everything else,
+// so only what the Mumble client reports is known
+func (s ParticipantStats) Loss() float64 {
+	total := s.Good + s.Late + s.Lost
+	if total == 0 {
+		return -1
+	}
+	return float64(s.Late+s.Lost) / float64(total)
+}
+
+// Stats returns the last statistics the server gave about every
+// participant, and asks it for new ones
+func (a *agent) Stats() []ParticipantStats {
+	a.lock.Lock()
+	var result []ParticipantStats
+	var sessions []uint32
+	for session, u := range a.users {
+		if session == a.self {
+			continue
+		}
+		sessions = append(sessions, session)
+
+		s := u.stats
+		s.Session = session
+		s.Name = u.name
+		result = append(result, s)
+	}
+	a.lock.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Session < result[j].Session
+	})
+
+	for _, session := range sessions {
+		_ = a.send(mumbleproto.MessageUserStats, &mumbleproto.UserStats{
+			Session:   proto.Uint32(session),
+			StatsOnly: proto.Bool(true),
+		})
+	}
+
+	return result
+}
+
+func (a *agent) updateStats(m *mumbleproto.UserStats) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, ok := a.users[m.GetSession()]
+	if !ok {
+		return
+	}
+
+	u.stats.RoundTrip = time.Duration(m.GetTcpPingAvg() * float32(time.Millisecond))
+	if from := m.GetFromClient(); from != nil {
+		u.stats.Good = from.GetGood()
+		u.stats.Late = from.GetLate()
+		u.stats.Lost = from.GetLost()
+	}
+}
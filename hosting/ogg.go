@@ -0,0 +1,215 @@
+package hosting
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// The recordings are Ogg files with a single Opus stream, like RFC 7845
+// describes them, so they can be played by most players once they are
+// decrypted. The pages are written one by one, and the whole page is
+// given to the function that writes it, so it can encrypt it on its own
+
+const (
+	oggSampleRate = 48000
+
+	oggFlagBeginning = 0x02
+	oggFlagEnd       = 0x04
+
+	oggMaxSegments = 255
+
+	// oggPagePackets is how many packets are kept before writing
+	// a page, which is about a second of audio with 20ms packets
+	oggPagePackets = 50
+)
+
+// opusSilence is a packet of 20ms of silence, in the CELT mode
+var opusSilence = []byte{0xf8, 0xff, 0xfe}
+
+var errInvalidOpusPacket = errors.New("the Opus packet is not valid")
+
+// opusFrameSamples are the samples in a frame of each configuration
+// of the Opus TOC byte, at 48kHz. There are three modes, SILK, hybrid
+// and CELT, and each one has its own durations for the frames
+var opusFrameSamples = [32]int{
+	480, 960, 1920, 2880, 480, 960, 1920, 2880, 480, 960, 1920, 2880,
+	480, 960, 480, 960,
+	120, 240, 480, 960, 120, 240, 480, 960, 120, 240, 480, 960, 120, 240, 480, 960,
+}
+
+// opusPacketSamples returns how many samples at 48kHz are in the
+// packet, reading the TOC byte and the count of frames that follow it
+func opusPacketSamples(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, errInvalidOpusPacket
+	}
+
+	frames := 1
+	switch p[0] & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(p) < 2 {
+			return 0, errInvalidOpusPacket
+		}
+		frames = int(p[1] & 0x3f)
+	}
+
+	samples := frames * opusFrameSamples[p[0]>>3]
+	// A packet can't have more than 120ms of audio
+	if frames == 0 || samples > oggSampleRate*120/1000 {
+		return 0, errInvalidOpusPacket
+	}
+
+	return samples, nil
+}
+
+type oggOpusWriter struct {
+	writePage func([]byte) error
+
+	serial   uint32
+	sequence uint32
+	granule  int64
+
+	segments []byte
+	data     []byte
+	packets  int
+}
+
+// newOggOpusWriter writes the headers of a mono Opus stream, and
+// returns the writer for its packets
+func newOggOpusWriter(writePage func([]byte) error) (*oggOpusWriter, error) {
+	serial := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, serial); err != nil {
+		return nil, err
+	}
+
+	w := &oggOpusWriter{
+		writePage: writePage,
+		serial:    binary.LittleEndian.Uint32(serial),
+	}
+
+	head := []byte("OpusHead")
+	head = append(head, 1, 1) // version and channels
+	head = append(head, 0, 0) // pre-skip
+	head = appendUint32(head, oggSampleRate)
+	head = append(head, 0, 0, 0) // output gain and mapping family
+
+	vendor := "Wahay"
+	tags := []byte("OpusTags")
+	tags = appendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = appendUint32(tags, 0)
+
+	// Each header is in a page of its own
+	w.addPacket(head)
+	if err := w.flush(oggFlagBeginning); err != nil {
+		return nil, err
+	}
+	w.addPacket(tags)
+	if err := w.flush(0); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writePacket adds the packet to the stream, with the given
+// amount of samples, and writes a page once it's full
+func (w *oggOpusWriter) writePacket(p []byte, samples int) error {
+	if len(w.segments)+len(p)/255+1 > oggMaxSegments {
+		if err := w.flush(0); err != nil {
+			return err
+		}
+	}
+
+	w.addPacket(p)
+	w.granule += int64(samples)
+
+	if w.packets >= oggPagePackets {
+		return w.flush(0)
+	}
+	return nil
+}
+
+// close writes the last page, which must have a packet
+func (w *oggOpusWriter) close() error {
+	if w.packets == 0 {
+		w.addPacket(opusSilence)
+		w.granule += oggSampleRate * 20 / 1000
+	}
+	return w.flush(oggFlagEnd)
+}
+
+// addPacket adds the lacing values of the packet, which are as many
+// 255 as fit in its length followed by the rest, even when it's zero
+func (w *oggOpusWriter) addPacket(p []byte) {
+	n := len(p)
+	for ; n >= 255; n -= 255 {
+		w.segments = append(w.segments, 255)
+	}
+	w.segments = append(w.segments, byte(n))
+	w.data = append(w.data, p...)
+	w.packets++
+}
+
+func (w *oggOpusWriter) flush(flags byte) error {
+	page := []byte("OggS")
+	page = append(page, 0, flags)
+	page = appendUint64(page, uint64(w.granule))
+	page = appendUint32(page, w.serial)
+	page = appendUint32(page, w.sequence)
+	page = appendUint32(page, 0) // checksum
+	page = append(page, byte(len(w.segments)))
+	page = append(page, w.segments...)
+	page = append(page, w.data...)
+
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+
+	w.sequence++
+	w.segments = nil
+	w.data = nil
+	w.packets = 0
+
+	return w.writePage(page)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, v)
+	return append(b, buf...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return append(b, buf...)
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// oggChecksum is the CRC-32 of Ogg, which is not the one of the
+// standard library, since it doesn't reflect the bits
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
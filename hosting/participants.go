@@ -0,0 +1,234 @@
+package hosting
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/ban"
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+var (
+	errParticipantNotFound = failure.New("hosting.participant-not-found", failure.CategoryHosting, "the participant is not in the meeting anymore", "")
+	errInvalidBanDuration  = failure.New("hosting.invalid-ban-duration", failure.CategoryHosting, "the participant can only be banned for some time", "choose how long the participant will be banned")
+	errNoCertificate       = failure.New("hosting.no-certificate", failure.CategoryHosting, "the participant can't be banned, since their Mumble client has no certificate", "remove the participant instead")
+)
+
+// ParticipantEvent is what happened to a participant of the meeting
+type ParticipantEvent int
+
+const (
+	// ParticipantConnected means that the participant has just arrived
+	ParticipantConnected ParticipantEvent = iota
+	// ParticipantDisconnected means that the participant has left or has been removed
+	ParticipantDisconnected
+	// ParticipantChanged means that the participant has been muted,
+	// deafened, moved or renamed
+	ParticipantChanged
+	// ParticipantTalking means that the participant has started or stopped talking
+	ParticipantTalking
+)
+
+// Participant is somebody connected to the meeting
+type Participant struct {
+	// Session identifies the participant while connected
+	Session uint32
+	Name    string
+
+	// Muted is true when the participant can't be heard, because
+	// of themselves or the host. MutedByHost is only the latter,
+	// and the same goes for Deafened and DeafenedByHost
+	Muted          bool
+	MutedByHost    bool
+	Deafened       bool
+	DeafenedByHost bool
+	Talking        bool
+
+	// Waiting is true while the participant waits to be admitted
+	Waiting bool
+
+	// Room is the breakout room the participant is in, or MainRoom
+	Room uint32
+
+	// CoModerator is true when the host has given the
+	// participant the privileges of a moderator
+	CoModerator bool
+}
+
+// Participants tells who is connected to a meeting, and lets the
+// host mute, deafen, remove or ban them
+type Participants interface {
+	// WhenChanged calls the given function, in a goroutine of its own,
+	// every time something happens to a participant. The function
+	// returned stops the calls
+	WhenChanged(func(ParticipantEvent, Participant)) func()
+
+	// List returns the participants connected now, by arrival
+	List() []Participant
+
+	// Mute mutes or unmutes the participant for everybody
+	Mute(session uint32, mute bool) error
+
+	// Deafen stops or restores the sound of the meeting for the
+	// participant, which is also muted while deafened
+	Deafen(session uint32, deaf bool) error
+
+	// Kick removes the participant from the meeting
+	Kick(session uint32) error
+
+	// Ban removes the participant from the meeting, and doesn't let
+	// them join it again until the given time has passed
+	Ban(session uint32, d time.Duration) error
+
+	// SetCoModerator lets the participant mute, move and remove the
+	// others, like the moderators do, or takes it back
+	SetCoModerator(session uint32, v bool) error
+
+	// Stats returns how the connection of every participant works,
+	// as the server told last time, and asks it for new statistics
+	Stats() []ParticipantStats
+}
+
+// participant returns what is known about the user. It
+// must be called with the lock held
+func (a *agent) participant(session uint32, u *agentUser) Participant {
+	return Participant{
+		Session:        session,
+		Name:           u.name,
+		Muted:          u.muted(),
+		MutedByHost:    u.mutedByHost,
+		Deafened:       u.deafened(),
+		DeafenedByHost: u.deafByHost,
+		Talking:        u.talking && time.Since(u.lastVoice) <= agentTalkingTimeout,
+		Waiting:        a.isWaiting(session, u),
+		Room:           a.roomOf(u),
+		CoModerator:    a.isCoModerator(u),
+	}
+}
+
+func (a *agent) notifyChanged(e ParticipantEvent, session uint32, u *agentUser) {
+	p := a.participant(session, u)
+	for _, f := range a.onChanged {
+		go f(e, p)
+	}
+}
+
+func (a *agent) WhenChanged(f func(ParticipantEvent, Participant)) func() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.nextID
+	a.nextID++
+	a.onChanged[id] = f
+
+	return func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		delete(a.onChanged, id)
+	}
+}
+
+func (a *agent) List() []Participant {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var result []Participant
+	for session, u := range a.users {
+		if session != a.self {
+			result = append(result, a.participant(session, u))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Session < result[j].Session
+	})
+
+	return result
+}
+
+func (a *agent) Mute(session uint32, mute bool) error {
+	a.lock.Lock()
+	_, err := a.checkUser(session)
+	a.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+		Session: proto.Uint32(session),
+		Mute:    proto.Bool(mute),
+	})
+}
+
+func (a *agent) Deafen(session uint32, deaf bool) error {
+	a.lock.Lock()
+	_, err := a.checkUser(session)
+	a.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+		Session: proto.Uint32(session),
+		Deaf:    proto.Bool(deaf),
+	})
+}
+
+func (a *agent) Kick(session uint32) error {
+	a.lock.Lock()
+	_, err := a.checkUser(session)
+	a.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return a.send(mumbleproto.MessageUserRemove, &mumbleproto.UserRemove{
+		Session: proto.Uint32(session),
+		Reason:  proto.String("The host has removed you from the meeting"),
+	})
+}
+
+// Ban sends the whole list of the bans made by the host, which
+// replaces the one of the server. The address of the bans is one
+// that nobody connects from, so they only match the certificate
+func (a *agent) Ban(session uint32, d time.Duration) error {
+	if d < time.Second {
+		return errInvalidBanDuration
+	}
+
+	a.lock.Lock()
+	u, err := a.checkUser(session)
+	if err != nil {
+		a.lock.Unlock()
+		return err
+	}
+
+	if u.hash == "" {
+		a.lock.Unlock()
+		return errNoCertificate
+	}
+
+	a.bans = append(a.bans, &mumbleproto.BanList_BanEntry{
+		Address:  net.IPv6unspecified,
+		Mask:     proto.Uint32(128),
+		Name:     proto.String(u.name),
+		Hash:     proto.String(u.hash),
+		Reason:   proto.String("The host has banned you from the meeting"),
+		Start:    proto.String(time.Now().UTC().Format(ban.ISODate)),
+		Duration: proto.Uint32(uint32(d / time.Second)),
+	})
+	bans := &mumbleproto.BanList{Bans: a.bans}
+	a.lock.Unlock()
+
+	err = a.send(mumbleproto.MessageBanList, bans)
+	if err != nil {
+		return err
+	}
+
+	return a.Kick(session)
+}
@@ -0,0 +1,194 @@
+package hosting
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+var (
+	errInvalidPortMapping = failure.New("hosting.invalid-port-mapping", failure.CategoryConfiguration, "invalid port mapping supplied", "write every port mapping as the port of the meeting and the local port, like 8181:18181")
+	errDuplicatedPort     = failure.New("hosting.duplicated-port", failure.CategoryConfiguration, "the same port of the meeting is published twice", "check the port mappings in the settings")
+	errPortInUse          = failure.New("hosting.port-in-use", failure.CategoryConfiguration, "the local port is already in use", "choose another local port in the settings")
+)
+
+// PortMapping publishes a local port through the onion service of
+// the meeting. When the service port is the one of the certificate
+// server or the file drop, they listen on the local port instead
+// of a random one. Any other port is forwarded as it is, to a local
+// service of the host
+type PortMapping struct {
+	ServicePort int
+	LocalPort   int
+}
+
+// ServicePorts has the ports used by the onion service of a meeting.
+// The zero value publishes Mumble in the default port, listening
+// on a random local port, without anything else
+type ServicePorts struct {
+	// Mumble is the port of the meeting in the onion service
+	Mumble int
+
+	// MumbleListener is the local port of the Mumble server
+	MumbleListener int
+
+	Mappings []PortMapping
+
+	// OnionOnly makes the certificate server listen on a Unix socket
+	// instead of a local port, so only Tor can connect to it. The Tor
+	// of the system might not be allowed to open the socket, so it's
+	// only useful with the Tor run by Wahay
+	OnionOnly bool
+}
+
+// NewServicePorts creates the ports of a meeting from the configured
+// values, where the empty ports mean the default ones, and the
+// mappings are written like "8181:18181"
+func NewServicePorts(mumble, listener string, mappings []string) (ServicePorts, error) {
+	var ports ServicePorts
+	var err error
+
+	ports.Mumble, err = parseOptionalPort(mumble)
+	if err != nil {
+		return ports, err
+	}
+
+	ports.MumbleListener, err = parseOptionalPort(listener)
+	if err != nil {
+		return ports, err
+	}
+
+	ports.Mappings, err = ParsePortMappings(strings.Join(mappings, "\n"))
+	if err != nil {
+		return ports, err
+	}
+
+	return ports, ports.validate()
+}
+
+func parseOptionalPort(v string) (int, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+
+	p, err := strconv.Atoi(v)
+	if err != nil || !config.CheckPort(p) {
+		return 0, errInvalidPort
+	}
+
+	return p, nil
+}
+
+// ParsePortMappings reads one port mapping per line, ignoring the
+// empty lines and the ones starting with #
+func ParsePortMappings(text string) ([]PortMapping, error) {
+	var result []PortMapping
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		parts := strings.Split(l, ":")
+		if len(parts) != 2 {
+			return nil, errInvalidPortMapping.Wrapf("%s", l)
+		}
+
+		service, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		local, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err1 != nil || err2 != nil || !config.CheckPort(service) || !config.CheckPort(local) {
+			return nil, errInvalidPortMapping.Wrapf("%s", l)
+		}
+
+		result = append(result, PortMapping{ServicePort: service, LocalPort: local})
+	}
+
+	return result, nil
+}
+
+// FormatPortMappings writes the mappings as ParsePortMappings reads them
+func FormatPortMappings(mappings []PortMapping) []string {
+	var result []string
+	for _, m := range mappings {
+		result = append(result, strconv.Itoa(m.ServicePort)+":"+strconv.Itoa(m.LocalPort))
+	}
+	return result
+}
+
+func (p ServicePorts) mumblePort() int {
+	if p.Mumble == 0 {
+		return DefaultPort
+	}
+	return p.Mumble
+}
+
+// validate makes sure that no port of the meeting is published twice
+// and that no local port is used by two of them
+func (p ServicePorts) validate() error {
+	services := map[int]bool{p.mumblePort(): true}
+	locals := map[int]bool{}
+	if p.MumbleListener != 0 {
+		locals[p.MumbleListener] = true
+	}
+
+	for _, m := range p.Mappings {
+		if services[m.ServicePort] {
+			return errDuplicatedPort.Wrapf("%d", m.ServicePort)
+		}
+		services[m.ServicePort] = true
+
+		if locals[m.LocalPort] {
+			return errPortInUse.Wrapf("%d", m.LocalPort)
+		}
+		locals[m.LocalPort] = true
+	}
+
+	return nil
+}
+
+// localPortFor returns the local port mapped to the given port of the
+// meeting, or a random one that is free when it's not configured
+func (p ServicePorts) localPortFor(servicePort int) (int, error) {
+	for _, m := range p.Mappings {
+		if m.ServicePort == servicePort {
+			return availablePort(m.LocalPort)
+		}
+	}
+	return config.GetRandomPort(), nil
+}
+
+func (p ServicePorts) mumbleListenerPort() (int, error) {
+	if p.MumbleListener == 0 {
+		return config.GetRandomPort(), nil
+	}
+	return availablePort(p.MumbleListener)
+}
+
+// forwardedPorts returns the mappings to local services of the host,
+// which are not any of the servers of the meeting
+func (p ServicePorts) forwardedPorts() []tor.OnionPort {
+	var result []tor.OnionPort
+	for _, m := range p.Mappings {
+		if m.ServicePort == certServerPort || m.ServicePort == fileDropServicePort {
+			continue
+		}
+
+		result = append(result, tor.OnionPort{
+			DestinationHost: defaultHost,
+			DestinationPort: m.LocalPort,
+			ServicePort:     m.ServicePort,
+		})
+	}
+	return result
+}
+
+func availablePort(port int) (int, error) {
+	if !config.IsPortAvailable(port) {
+		return 0, errPortInUse.Wrapf("%d", port)
+	}
+	return port, nil
+}
@@ -0,0 +1,182 @@
+package hosting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// While a meeting is hosted, Wahay connects to its onion service from
+// time to time, through circuits that nobody else uses, like a
+// participant would. When that fails Tor is asked to publish the service
+// again through other introduction points, and when it keeps failing the
+// service is created again in Tor, with the same address
+
+// Reachability is whether the participants can reach the meeting
+type Reachability int
+
+const (
+	// ReachabilityUnknown is the reachability before the first check
+	ReachabilityUnknown Reachability = iota
+	// Reachable means that the last check reached the meeting
+	Reachable
+	// ReachabilityDegraded means that the last check failed, and
+	// the meeting is being published again
+	ReachabilityDegraded
+	// Unreachable means that the meeting has failed to be reached
+	// several times in a row, even after publishing it again
+	Unreachable
+)
+
+const (
+	reachabilityCheckInterval = 5 * time.Minute
+	// reachabilityRetryInterval is shorter, so a meeting that starts
+	// working again after being published again is known soon
+	reachabilityRetryInterval = time.Minute
+	reachabilityCheckTimeout  = 2 * time.Minute
+
+	// reachabilityFailuresToRestart is how many checks in a row must
+	// fail before the onion service is created again. While it keeps
+	// failing, it's published again and created again in turns
+	reachabilityFailuresToRestart = 2
+)
+
+type reachabilityMonitor struct {
+	sync.Mutex
+	state     Reachability
+	observers []func(Reachability)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newReachabilityMonitor() *reachabilityMonitor {
+	return &reachabilityMonitor{}
+}
+
+// whenChanges calls the given function with the current reachability,
+// and every time it changes after that
+func (m *reachabilityMonitor) whenChanges(f func(Reachability)) {
+	m.Lock()
+	m.observers = append(m.observers, f)
+	state := m.state
+	m.Unlock()
+
+	f(state)
+}
+
+func (m *reachabilityMonitor) set(r Reachability) {
+	m.Lock()
+	if m.state == r {
+		m.Unlock()
+		return
+	}
+	m.state = r
+	observers := append([]func(Reachability){}, m.observers...)
+	m.Unlock()
+
+	for _, f := range observers {
+		f(r)
+	}
+}
+
+// start checks the given port of the onion service once it has been
+// published, until stop is called
+func (m *reachabilityMonitor) start(onion tor.Onion, port int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	published := make(chan struct{})
+	onion.WhenPublished(func(error) {
+		close(published)
+	})
+
+	go m.run(ctx, published, onion, port)
+}
+
+func (m *reachabilityMonitor) run(ctx context.Context, published <-chan struct{}, onion tor.Onion, port int) {
+	defer close(m.done)
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-published:
+	}
+
+	failures := 0
+	for {
+		failures = m.check(ctx, onion, port, failures)
+
+		next := reachabilityCheckInterval
+		if failures > 0 {
+			next = reachabilityRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+	}
+}
+
+// check returns how many checks in a row have failed after this one
+func (m *reachabilityMonitor) check(ctx context.Context, onion tor.Onion, port int, failures int) int {
+	cctx, cancel := context.WithTimeout(ctx, reachabilityCheckTimeout)
+	err := onion.CheckReachable(cctx, port)
+	cancel()
+
+	if ctx.Err() != nil {
+		return failures
+	}
+
+	if err == nil {
+		m.set(Reachable)
+		return 0
+	}
+
+	failures++
+	log.WithFields(log.Fields{
+		"context":  "reachability",
+		"failures": failures,
+	}).Warningf("The meeting can't be reached through Tor: %s", err)
+
+	if failures < reachabilityFailuresToRestart {
+		m.set(ReachabilityDegraded)
+	} else {
+		m.set(Unreachable)
+	}
+
+	if failures%reachabilityFailuresToRestart != 0 {
+		err = onion.Republish()
+	} else {
+		err = onion.Restart()
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "reachability",
+		}).Errorf("The meeting can't be published again: %s", err)
+	}
+
+	return failures
+}
+
+// stop waits for the check in progress to finish, so the onion
+// service is not created again once it has been removed
+func (m *reachabilityMonitor) stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+
+	select {
+	case <-m.done:
+	case <-time.After(reachabilityCheckTimeout):
+	}
+}
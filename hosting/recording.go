@@ -0,0 +1,425 @@
+package hosting
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/digitalautonomy/grumble/pkg/packetdata"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The host can record the meeting, with the voice packets that the
+// agent receives. Nobody is recorded without being told first: the
+// announcement is sent to everybody before anything is written, and to
+// every participant that joins while the meeting is being recorded.
+// The agent also tells the server that it's recording, so the Mumble
+// clients show it next to its name.
+//
+// Opus can't be mixed without decoding it, and Wahay has no Opus
+// decoder, so the recording has a single speaker at a time: when several
+// participants talk at the same time, the one that started first is the
+// one recorded. The silences are filled with silent packets, so the
+// recording lasts as long as the meeting. The file is encrypted like the
+// chat transcripts, a page of the Ogg file after the other:
+//
+//   file   = magic | salt | record...
+//   record = length (4 bytes) | nonce | AES-GCM(Ogg page)
+//
+// The position of every record is authenticated with it, so the pages
+// can't be removed or moved without the recording failing to decrypt
+
+const (
+	// RecordingFileExtension is the extension of the encrypted recordings
+	RecordingFileExtension = ".wahayrec"
+
+	recordingMagic       = "WAHAYREC"
+	recordingSaltLength  = 16
+	recordingKeyLength   = 32
+	recordingNonceLength = 12
+
+	// maxRecordingRecordLen is more than the largest Ogg page
+	maxRecordingRecordLen = 256 * 1024
+
+	// opusSilenceDuration is the duration of opusSilence
+	opusSilenceDuration = 20 * time.Millisecond
+)
+
+var (
+	// ErrRecordingPasswordRequired is an error to be trown when
+	// the recording is started without a password to encrypt it
+	ErrRecordingPasswordRequired = failure.New("hosting.recording-password-required", failure.CategoryInput, "a password is required to protect the recording", "")
+
+	// ErrAlreadyRecording is an error to be trown when the recording
+	// is started while the meeting is already being recorded
+	ErrAlreadyRecording = failure.New("hosting.already-recording", failure.CategoryHosting, "the meeting is already being recorded", "")
+
+	// ErrInvalidRecording is an error to be trown when the recording
+	// can't be decrypted with the given password or it was modified
+	ErrInvalidRecording = failure.New("hosting.invalid-recording", failure.CategoryInput, "the recording is not valid or the password is wrong", "check the password used when the meeting was recorded")
+)
+
+// Recording lets the host record the meeting
+type Recording interface {
+	// Start tells everybody in the meeting that it's being recorded,
+	// with the given announcement, and then records it in the file,
+	// encrypted with the password. The participants that join later
+	// get the announcement when they arrive
+	Start(fileName, password, announcement string) error
+
+	// Stop finishes the recording, which can be played once
+	// it has been decrypted with DecryptRecording
+	Stop() error
+
+	IsRecording() bool
+}
+
+// recordingKey derives the key of the recording with the same scrypt
+// parameters used for the configuration file
+func recordingKey(password string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(password), salt, 262144, 8, 1, recordingKeyLength)
+}
+
+func recordingCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := recordingKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+type meetingRecorder struct {
+	sync.Mutex
+
+	file         *os.File
+	aead         cipher.AEAD
+	ogg          *oggOpusWriter
+	records      uint64
+	announcement string
+
+	// speaker is who is being recorded, until they stop talking
+	speaker   uint32
+	lastVoice time.Time
+
+	// end is when the audio recorded so far would finish playing
+	end time.Time
+}
+
+func newMeetingRecorder(fileName, password, announcement string) (*meetingRecorder, error) {
+	if password == "" {
+		return nil, ErrRecordingPasswordRequired
+	}
+
+	salt := make([]byte, recordingSaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := recordingCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(fileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &meetingRecorder{
+		file:         f,
+		aead:         aead,
+		announcement: announcement,
+	}
+
+	if _, err = f.Write(append([]byte(recordingMagic), salt...)); err != nil {
+		r.discard()
+		return nil, err
+	}
+
+	r.ogg, err = newOggOpusWriter(r.writePage)
+	if err != nil {
+		r.discard()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// writePage must be called with the lock held, or before
+// the recorder is used by anybody else
+func (r *meetingRecorder) writePage(page []byte) error {
+	nonce := make([]byte, recordingNonceLength)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	record := append(nonce, r.aead.Seal(nil, nonce, page, recordingRecordData(r.records))...)
+	r.records++
+
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(record)))
+
+	_, err := r.file.Write(append(length, record...))
+	return err
+}
+
+// recordingRecordData is the data authenticated with every
+// record, which is its position in the recording
+func recordingRecordData(index uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, index)
+	return data
+}
+
+// voice records the Opus packet of the participant, unless
+// somebody else is talking already
+func (r *meetingRecorder) voice(session uint32, packet []byte, now time.Time) {
+	samples, err := opusPacketSamples(packet)
+	if err != nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return
+	}
+
+	if session != r.speaker && now.Sub(r.lastVoice) < agentTalkingTimeout {
+		return
+	}
+	r.speaker = session
+	r.lastVoice = now
+
+	if r.end.IsZero() {
+		r.end = now
+	}
+
+	for ; now.Sub(r.end) >= opusSilenceDuration; r.end = r.end.Add(opusSilenceDuration) {
+		if err = r.ogg.writePacket(opusSilence, oggSampleRate*int(opusSilenceDuration/time.Millisecond)/1000); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = r.ogg.writePacket(packet, samples)
+		r.end = r.end.Add(time.Duration(samples) * time.Second / oggSampleRate)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "recording",
+		}).Errorf("the meeting can't be recorded: %s", err)
+	}
+}
+
+func (r *meetingRecorder) close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.ogg.close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+
+	return err
+}
+
+// discard removes the file of a recording that couldn't be started
+func (r *meetingRecorder) discard() {
+	name := r.file.Name()
+	_ = r.file.Close()
+	_ = os.Remove(name)
+	r.file = nil
+}
+
+// opusPayload returns the Opus packet in a voice packet of the Mumble
+// protocol, after the session of the speaker, the sequence number and
+// the length, which also has the bit that ends the transmission
+func opusPayload(buf []byte) (uint32, []byte, bool) {
+	if len(buf) < 2 || int((buf[0]>>5)&0x07) != mumbleproto.UDPMessageVoiceOpus {
+		return 0, nil, false
+	}
+
+	pds := packetdata.New(buf[1:])
+	session := pds.GetUint32()
+	_ = pds.GetUint64()
+	size := int(pds.GetUint16() & 0x1fff)
+	if !pds.IsValid() || size == 0 || size > pds.Left() {
+		return 0, nil, false
+	}
+
+	packet := make([]byte, size)
+	pds.CopyBytes(packet)
+
+	return session, packet, pds.IsValid()
+}
+
+func (a *agent) Start(fileName, password, announcement string) error {
+	a.lock.Lock()
+	recording := a.recorder != nil
+	a.lock.Unlock()
+	if recording {
+		return ErrAlreadyRecording
+	}
+
+	r, err := newMeetingRecorder(fileName, password, announcement)
+	if err != nil {
+		return err
+	}
+
+	// The participants are told before anything is recorded
+	if _, err = a.announce(announcement); err != nil {
+		r.discard()
+		return err
+	}
+
+	a.lock.Lock()
+	if a.recorder != nil {
+		a.lock.Unlock()
+		r.discard()
+		return ErrAlreadyRecording
+	}
+	a.recorder = r
+	self := a.self
+	a.lock.Unlock()
+
+	log.WithFields(log.Fields{
+		"context": "recording",
+	}).Info("The meeting is being recorded")
+
+	return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+		Session:   proto.Uint32(self),
+		Recording: proto.Bool(true),
+	})
+}
+
+func (a *agent) Stop() error {
+	a.lock.Lock()
+	r := a.recorder
+	a.recorder = nil
+	closed := a.closed
+	self := a.self
+	a.lock.Unlock()
+
+	if r == nil {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"context": "recording",
+	}).Info("The meeting is not being recorded anymore")
+
+	err := r.close()
+
+	if !closed {
+		_ = a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+			Session:   proto.Uint32(self),
+			Recording: proto.Bool(false),
+		})
+	}
+
+	return err
+}
+
+func (a *agent) IsRecording() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.recorder != nil
+}
+
+// recordVoice gives the voice packet to the recorder, if the
+// meeting is being recorded. It must be called with the lock held
+func (a *agent) recordVoice(buf []byte) {
+	if a.recorder == nil {
+		return
+	}
+
+	if session, packet, ok := opusPayload(buf); ok {
+		a.recorder.voice(session, packet, time.Now())
+	}
+}
+
+// tellRecording sends the announcement of the recording to a participant
+// that has just joined. It must be called with the lock held
+func (a *agent) tellRecording(session uint32) {
+	if a.recorder == nil {
+		return
+	}
+
+	m := &mumbleproto.TextMessage{
+		Session: []uint32{session},
+		Message: proto.String(a.recorder.announcement),
+	}
+	go func() {
+		_ = a.send(mumbleproto.MessageTextMessage, m)
+	}()
+}
+
+// DecryptRecording returns the Ogg file of a recording made by Wahay
+func DecryptRecording(content []byte, password string) ([]byte, error) {
+	if len(content) < len(recordingMagic)+recordingSaltLength || string(content[:len(recordingMagic)]) != recordingMagic {
+		return nil, ErrInvalidRecording
+	}
+
+	salt := content[len(recordingMagic) : len(recordingMagic)+recordingSaltLength]
+	aead, err := recordingCipher(password, salt)
+	if err != nil {
+		return nil, ErrInvalidRecording.Wrap(err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(content[len(recordingMagic)+recordingSaltLength:]))
+	var result bytes.Buffer
+	for index := uint64(0); ; index++ {
+		length := make([]byte, 4)
+		_, err = io.ReadFull(r, length)
+		if err == io.EOF {
+			return result.Bytes(), nil
+		}
+		if err != nil {
+			return nil, ErrInvalidRecording
+		}
+
+		l := binary.BigEndian.Uint32(length)
+		if l <= recordingNonceLength || l > maxRecordingRecordLen {
+			return nil, ErrInvalidRecording
+		}
+
+		record := make([]byte, l)
+		if _, err = io.ReadFull(r, record); err != nil {
+			return nil, ErrInvalidRecording
+		}
+
+		page, err := aead.Open(nil, record[:recordingNonceLength], record[recordingNonceLength:], recordingRecordData(index))
+		if err != nil {
+			return nil, ErrInvalidRecording
+		}
+		result.Write(page)
+	}
+}
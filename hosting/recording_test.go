@@ -0,0 +1,274 @@
+package hosting
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/digitalautonomy/grumble/pkg/packetdata"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RecordingSuite struct {
+	dir string
+}
+
+var _ = Suite(&RecordingSuite{})
+
+func (s *RecordingSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-recording")
+	c.Assert(err, IsNil)
+	s.dir = dir
+}
+
+func (s *RecordingSuite) TearDownTest(c *C) {
+	_ = os.RemoveAll(s.dir)
+}
+
+// oggPage is a page of the Ogg file, with its packets
+type oggPage struct {
+	flags    byte
+	granule  int64
+	serial   uint32
+	sequence uint32
+	packets  [][]byte
+}
+
+func readOggPages(c *C, content []byte) []oggPage {
+	var pages []oggPage
+	for len(content) > 0 {
+		c.Assert(len(content) >= 27, Equals, true)
+		c.Assert(string(content[:4]), Equals, "OggS")
+		c.Assert(content[4], Equals, byte(0))
+
+		segments := int(content[26])
+		lacing := content[27 : 27+segments]
+		size := 27 + segments
+		for _, l := range lacing {
+			size += int(l)
+		}
+		raw := content[:size]
+
+		// The checksum is calculated with its own field set to zero
+		checksum := binary.LittleEndian.Uint32(raw[22:])
+		withoutChecksum := append([]byte{}, raw...)
+		binary.LittleEndian.PutUint32(withoutChecksum[22:], 0)
+		c.Assert(oggChecksum(withoutChecksum), Equals, checksum)
+
+		p := oggPage{
+			flags:    raw[5],
+			granule:  int64(binary.LittleEndian.Uint64(raw[6:])),
+			serial:   binary.LittleEndian.Uint32(raw[14:]),
+			sequence: binary.LittleEndian.Uint32(raw[18:]),
+		}
+
+		data := raw[27+segments:]
+		var packet []byte
+		for _, l := range lacing {
+			packet = append(packet, data[:l]...)
+			data = data[l:]
+			if l < 255 {
+				p.packets = append(p.packets, packet)
+				packet = nil
+			}
+		}
+		c.Assert(packet, IsNil)
+
+		pages = append(pages, p)
+		content = content[size:]
+	}
+	return pages
+}
+
+// voicePacket is an Opus voice packet of the Mumble protocol
+func voicePacket(session uint32, sequence uint64, opus []byte) []byte {
+	buf := make([]byte, 1024)
+	pds := packetdata.New(buf)
+	pds.PutUint32(session)
+	pds.PutUint64(sequence)
+	pds.PutUint16(uint16(len(opus)))
+	pds.PutBytes(opus)
+
+	return append([]byte{byte(mumbleproto.UDPMessageVoiceOpus << 5)}, buf[:pds.Size()]...)
+}
+
+// agentMessage is a message sent by the agent to the server
+type agentMessage struct {
+	kind    uint16
+	content []byte
+}
+
+// newTestAgent returns an agent connected to nothing, and the
+// messages it sends, which are dropped when nobody reads them
+func newTestAgent() (*agent, <-chan agentMessage) {
+	conn, server := net.Pipe()
+	messages := make(chan agentMessage, 16)
+	go func() {
+		for {
+			kind, content, err := readMumbleMessage(server)
+			if err != nil {
+				_, _ = io.Copy(ioutil.Discard, server)
+				return
+			}
+
+			select {
+			case messages <- agentMessage{kind, content}:
+			default:
+			}
+		}
+	}()
+
+	return &agent{
+		conn:         conn,
+		self:         1,
+		users:        map[uint32]*agentUser{},
+		channels:     map[uint32]*agentChannel{},
+		creating:     map[string]bool{},
+		coModerators: map[string]bool{},
+		onChanged:    map[int]func(ParticipantEvent, Participant){},
+		onRooms:      map[int]func(){},
+	}, messages
+}
+
+func (s *RecordingSuite) Test_oggChecksum_isTheCRCOfOgg(c *C) {
+	c.Assert(oggChecksum([]byte("123456789")), Equals, uint32(0x89a1897f))
+	c.Assert(oggChecksum(nil), Equals, uint32(0))
+}
+
+func (s *RecordingSuite) Test_opusPacketSamples_readsTheTOCByte(c *C) {
+	samples, err := opusPacketSamples([]byte{0x98, 1, 2})
+	c.Assert(err, IsNil)
+	c.Assert(samples, Equals, 960)
+
+	samples, err = opusPacketSamples([]byte{0x99, 1, 2})
+	c.Assert(err, IsNil)
+	c.Assert(samples, Equals, 1920)
+
+	samples, err = opusPacketSamples([]byte{0x0b, 0x03})
+	c.Assert(err, IsNil)
+	c.Assert(samples, Equals, 3*960)
+
+	_, err = opusPacketSamples(nil)
+	c.Assert(err, NotNil)
+
+	_, err = opusPacketSamples([]byte{0x1b, 0x03})
+	c.Assert(err, NotNil)
+}
+
+func (s *RecordingSuite) Test_Start_andStop_recordAnOggFileThatIsDecrypted(c *C) {
+	a, _ := newTestAgent()
+	fileName := filepath.Join(s.dir, "meeting"+RecordingFileExtension)
+
+	c.Assert(a.Start(fileName, "the password", "This meeting is recorded"), IsNil)
+	c.Assert(a.IsRecording(), Equals, true)
+	c.Assert(failure.Kind(a.Start(fileName, "the password", "")), Equals, ErrAlreadyRecording)
+
+	var packets [][]byte
+	var samples []int64
+	for i := 0; i < 60; i++ {
+		opus := append([]byte{0x98}, bytes.Repeat([]byte{byte(i)}, 10)...)
+		n := int64(960)
+		if i%10 == 9 {
+			// Two frames of 20ms, and a payload that needs several lacing values
+			opus = append([]byte{0x99}, bytes.Repeat([]byte{byte(i)}, 600)...)
+			n = 1920
+		}
+
+		a.lock.Lock()
+		a.recordVoice(voicePacket(2, uint64(i), opus))
+		// Nobody else is recorded while somebody is talking
+		a.recordVoice(voicePacket(3, uint64(i), []byte{0x98, 0xff}))
+		a.lock.Unlock()
+
+		packets = append(packets, opus)
+		samples = append(samples, n)
+	}
+
+	c.Assert(a.Stop(), IsNil)
+	c.Assert(a.IsRecording(), Equals, false)
+
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Contains(content, []byte("OpusHead")), Equals, false)
+
+	ogg, err := DecryptRecording(content, "the password")
+	c.Assert(err, IsNil)
+
+	pages := readOggPages(c, ogg)
+	c.Assert(len(pages) > 3, Equals, true)
+
+	c.Assert(pages[0].flags, Equals, byte(oggFlagBeginning))
+	c.Assert(pages[0].packets, HasLen, 1)
+	c.Assert(string(pages[0].packets[0][:8]), Equals, "OpusHead")
+	c.Assert(pages[1].packets, HasLen, 1)
+	c.Assert(string(pages[1].packets[0][:8]), Equals, "OpusTags")
+	c.Assert(pages[len(pages)-1].flags, Equals, byte(oggFlagEnd))
+
+	// The granule position of every page is where its last packet ends
+	var recorded [][]byte
+	var granule int64
+	for i, p := range pages {
+		c.Assert(p.serial, Equals, pages[0].serial)
+		c.Assert(p.sequence, Equals, uint32(i))
+		if i < 2 {
+			c.Assert(p.granule, Equals, int64(0))
+			continue
+		}
+
+		for _, packet := range p.packets {
+			granule += samples[len(recorded)]
+			recorded = append(recorded, packet)
+		}
+		c.Assert(p.granule, Equals, granule)
+	}
+
+	c.Assert(recorded, DeepEquals, packets)
+}
+
+func (s *RecordingSuite) Test_DecryptRecording_failsWithAnotherPasswordOrModifiedRecords(c *C) {
+	fileName := filepath.Join(s.dir, "meeting"+RecordingFileExtension)
+	r, err := newMeetingRecorder(fileName, "the password", "")
+	c.Assert(err, IsNil)
+	c.Assert(r.close(), IsNil)
+
+	content, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+
+	_, err = DecryptRecording(content, "another password")
+	c.Assert(failure.Kind(err), Equals, ErrInvalidRecording)
+
+	// The headers and the last page are in three records
+	header := len(recordingMagic) + recordingSaltLength
+	var records [][]byte
+	for rest := content[header:]; len(rest) > 0; {
+		l := 4 + int(binary.BigEndian.Uint32(rest))
+		records = append(records, rest[:l])
+		rest = rest[l:]
+	}
+	c.Assert(records, HasLen, 3)
+
+	join := func(rs ...[]byte) []byte {
+		result := append([]byte{}, content[:header]...)
+		for _, r := range rs {
+			result = append(result, r...)
+		}
+		return result
+	}
+
+	_, err = DecryptRecording(join(records[0], records[2]), "the password")
+	c.Assert(failure.Kind(err), Equals, ErrInvalidRecording)
+
+	_, err = DecryptRecording(join(records[1], records[0], records[2]), "the password")
+	c.Assert(failure.Kind(err), Equals, ErrInvalidRecording)
+}
@@ -0,0 +1,206 @@
+package hosting
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// A room is a meeting that is hosted again and again, like a weekly one.
+// It keeps the key of the onion service, the invitation token and the
+// certificate of the Mumble server, so the invitations given once keep
+// working, and the participants don't have to verify the certificate
+// every time. Whoever has a room can publish it, so it must only be
+// saved encrypted
+
+var (
+	errInvalidRoom = failure.New("hosting.invalid-room", failure.CategoryHosting, "the saved meeting room is not valid", "remove the meeting room and create it again")
+	errNotARoom    = failure.New("hosting.not-a-room", failure.CategoryHosting, "the meeting is not a meeting room", "")
+)
+
+// Room has what is needed for hosting a meeting in the same onion
+// address, with the same invitation, every time
+type Room struct {
+	// OnionKey is the private key of the onion service, like Tor gives it
+	OnionKey string
+
+	// Port is the port of the meeting in the onion service
+	Port int
+
+	Token string
+
+	// Certificate and CertificateKey are the PEM files used
+	// by the Mumble server and the certificate server
+	Certificate    []byte
+	CertificateKey []byte
+
+	// SuperUserPassword is the password of the administrator
+	// of the Mumble server, which is kept with the room
+	SuperUserPassword string
+}
+
+// NewRoom generates a room that has never been published, so its
+// invitation can be given before the meeting is hosted. The password
+// of the invitation is left to the host
+func NewRoom(ports ServicePorts, embedCertificate bool) (*Room, *invitation.Invitation, error) {
+	key, public, err := tor.NewOnionKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, certKey, err := newRoomCertificate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return nil, nil, errCertificateFileMissing
+	}
+
+	room := &Room{
+		OnionKey:       key,
+		Port:           ports.mumblePort(),
+		Token:          token,
+		Certificate:    cert,
+		CertificateKey: certKey,
+	}
+
+	inv := &invitation.Invitation{
+		Host:        invitation.OnionAddress(public),
+		Port:        room.Port,
+		Token:       token,
+		Fingerprint: invitation.CertificateFingerprint(block.Bytes),
+	}
+	if embedCertificate {
+		inv.Certificate = block.Bytes
+	}
+
+	return room, inv, nil
+}
+
+// newRoomCertificate generates a certificate like the one that
+// the servers generate, which is always written in their directory
+func newRoomCertificate() ([]byte, []byte, error) {
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(0),
+		Subject: pkix.Name{
+			CommonName: "Grumble Autogenerated Certificate",
+		},
+		NotBefore:    now.Add(-300 * time.Second),
+		NotAfter:     now.Add(24 * time.Hour * 365),
+		SubjectKeyId: []byte{1, 2, 3, 4},
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 4096)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+
+	return cert, key, nil
+}
+
+func (r *Room) isNew() bool {
+	return r.OnionKey == "" && r.Token == "" && len(r.Certificate) == 0
+}
+
+func (r *Room) validate() error {
+	if r.isNew() {
+		return nil
+	}
+
+	if r.OnionKey == "" || len(r.Token) != invitationTokenLength || !config.CheckPort(r.Port) {
+		return errInvalidRoom
+	}
+
+	_, err := tls.X509KeyPair(r.Certificate, r.CertificateKey)
+	if err != nil {
+		return errInvalidRoom.Wrap(err)
+	}
+
+	return nil
+}
+
+func (s *servers) NewRoomService(room *Room, ports ServicePorts, t tor.Instance, fileSharing, embedCertificate bool) (Service, error) {
+	if room == nil {
+		room = &Room{}
+	}
+
+	err := room.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newService(ports, t, fileSharing, embedCertificate, 0, room)
+}
+
+// roomToken returns the invitation token of the room,
+// or a new one for the meetings that are not saved
+func roomToken(room *Room) (string, error) {
+	if room != nil && room.Token != "" {
+		return room.Token, nil
+	}
+	return newInvitationToken()
+}
+
+// useRoomCertificate replaces the certificate generated for this
+// collection with the one of the room, before the servers read it
+func (s *servers) useRoomCertificate(room *Room) error {
+	if room == nil || len(room.Certificate) == 0 {
+		return nil
+	}
+
+	err := ioutil.WriteFile(filepath.Join(s.dataDir, "cert.pem"), room.Certificate, 0600)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.dataDir, "key.pem"), room.CertificateKey, 0600)
+}
+
+func (s *service) Room() (*Room, error) {
+	if !s.persistent || s.onion.PrivateKey() == "" {
+		return nil, errNotARoom
+	}
+
+	key, err := ioutil.ReadFile(filepath.Join(s.collection.DataDir(), "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Room{
+		OnionKey:          s.onion.PrivateKey(),
+		Port:              s.mumblePort,
+		Token:             s.token,
+		Certificate:       s.httpServer.cert,
+		CertificateKey:    key,
+		SuperUserPassword: s.SuperUser().Password,
+	}, nil
+}
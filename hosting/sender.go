@@ -0,0 +1,285 @@
+package hosting
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// InvitationMessage is an invitation ready to be sent to a participant
+type InvitationMessage struct {
+	Recipient  string
+	Subject    string
+	Body       string
+	Invitation *invitation.Invitation
+}
+
+// InvitationSender delivers invitations directly through a channel
+// chosen by the user, for example email or a chat application
+type InvitationSender interface {
+	Send(*InvitationMessage) error
+}
+
+// InvitationSenderFactory creates an invitation sender from its configuration
+type InvitationSenderFactory func(tor.Instance, config.InvitationSenderSettings) (InvitationSender, error)
+
+const (
+	// InvitationSenderCommand is the kind of the sender using a local command
+	InvitationSenderCommand = "command"
+
+	// InvitationSenderSMTP is the kind of the sender using email over Tor
+	InvitationSenderSMTP = "smtp"
+
+	senderCommandTimeout = 2 * time.Minute
+	defaultSMTPPort      = "587"
+)
+
+var (
+	// ErrUnknownInvitationSender is an error to be trown when the
+	// configured kind of sender is not registered
+	ErrUnknownInvitationSender = failure.New("hosting.unknown-invitation-sender", failure.CategoryConfiguration, "the invitation sender is not supported", "choose another invitation sender in the settings")
+
+	// ErrInvalidInvitationSender is an error to be trown when
+	// the configuration of the sender is not complete
+	ErrInvalidInvitationSender = failure.New("hosting.invalid-invitation-sender", failure.CategoryConfiguration, "the configuration of the invitation sender is not complete", "complete the invitation sender in the settings")
+
+	// ErrInvalidRecipient is an error to be trown when the
+	// recipient can't be used by the invitation sender
+	ErrInvalidRecipient = failure.New("hosting.invalid-recipient", failure.CategoryInput, "the recipient of the invitation is not valid", "")
+
+	// ErrSMTPTLSRequired is an error to be trown when the SMTP
+	// server doesn't support encrypted connections
+	ErrSMTPTLSRequired = failure.New("hosting.smtp-tls-required", failure.CategoryConfiguration, "the email server doesn't support encrypted connections", "use an email server that supports encrypted connections")
+)
+
+var (
+	invitationSendersLock sync.RWMutex
+	invitationSenders     = map[string]InvitationSenderFactory{}
+)
+
+func init() {
+	RegisterInvitationSender(InvitationSenderCommand, newCommandSender)
+	RegisterInvitationSender(InvitationSenderSMTP, newSMTPSender)
+}
+
+// RegisterInvitationSender makes a new kind of sender available. New
+// channels, like XMPP, only have to register their factory here
+func RegisterInvitationSender(kind string, f InvitationSenderFactory) {
+	invitationSendersLock.Lock()
+	defer invitationSendersLock.Unlock()
+
+	invitationSenders[kind] = f
+}
+
+// InvitationSenderKinds returns the kinds of sender that can be configured
+func InvitationSenderKinds() []string {
+	invitationSendersLock.RLock()
+	defer invitationSendersLock.RUnlock()
+
+	kinds := make([]string, 0, len(invitationSenders))
+	for k := range invitationSenders {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// NewInvitationSender creates the sender described by the given configuration
+func NewInvitationSender(t tor.Instance, c config.InvitationSenderSettings) (InvitationSender, error) {
+	invitationSendersLock.RLock()
+	f, ok := invitationSenders[c.Kind]
+	invitationSendersLock.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownInvitationSender
+	}
+
+	return f(t, c)
+}
+
+type commandSender struct {
+	args []string
+}
+
+// newCommandSender creates a sender that runs a local program. The
+// recipient is given as the last argument, the message goes to the
+// standard input and the invitation is also available in the
+// environment. The program is responsible for its own connections
+func newCommandSender(_ tor.Instance, c config.InvitationSenderSettings) (InvitationSender, error) {
+	args := strings.Fields(c.Command)
+	if len(args) == 0 {
+		return nil, ErrInvalidInvitationSender
+	}
+
+	return &commandSender{args: args}, nil
+}
+
+func (s *commandSender) Send(m *InvitationMessage) error {
+	recipient := strings.TrimSpace(m.Recipient)
+	if recipient == "" || strings.HasPrefix(recipient, "-") {
+		return ErrInvalidRecipient
+	}
+
+	encoded, err := m.Invitation.EncodeV2()
+	if err != nil {
+		return err
+	}
+
+	/* #nosec G204 */
+	cmd := exec.Command(s.args[0], append(s.args[1:], recipient)...)
+	cmd.Stdin = strings.NewReader(m.Body)
+	cmd.Env = append(os.Environ(),
+		"WAHAY_INVITATION="+encoded,
+		"WAHAY_SUBJECT="+m.Subject)
+
+	var output bytes.Buffer
+	cmd.Stderr = &output
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	timer := time.AfterFunc(senderCommandTimeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	defer timer.Stop()
+
+	err = cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+type smtpSender struct {
+	t        tor.Instance
+	server   string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+}
+
+// newSMTPSender creates a sender that delivers the invitations by email,
+// using a server configured by the user. The connection to the server
+// always goes through Tor and must be encrypted
+func newSMTPSender(t tor.Instance, c config.InvitationSenderSettings) (InvitationSender, error) {
+	from, err := mail.ParseAddress(c.SMTPFrom)
+	if err != nil || c.SMTPServer == "" {
+		return nil, ErrInvalidInvitationSender
+	}
+
+	server := c.SMTPServer
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+		server = net.JoinHostPort(server, defaultSMTPPort)
+	}
+
+	return &smtpSender{
+		t:        t,
+		server:   server,
+		host:     host,
+		username: c.SMTPUsername,
+		password: c.SMTPPassword,
+		from:     from,
+	}, nil
+}
+
+func (s *smtpSender) Send(m *InvitationMessage) error {
+	to, err := mail.ParseAddress(m.Recipient)
+	if err != nil {
+		return ErrInvalidRecipient
+	}
+
+	conn, err := s.t.Dial("tcp", s.server)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		return ErrSMTPTLSRequired
+	}
+
+	err = c.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return err
+	}
+
+	if s.username != "" {
+		err = c.Auth(smtp.PlainAuth("", s.username, s.password, s.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(s.from.Address)
+	if err == nil {
+		err = c.Rcpt(to.Address)
+	}
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(s.message(to, m))
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (s *smtpSender) message(to *mail.Address, m *InvitationMessage) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.Replace(m.Body, "\r\n", "\n", -1)
+	buf.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
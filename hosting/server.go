@@ -6,6 +6,10 @@ import grumbleServer "github.com/digitalautonomy/grumble/server"
 type Server interface {
 	Start() error
 	Stop() error
+
+	// SetSuperUserPassword changes the password of the SuperUser,
+	// while the server is running
+	SetSuperUserPassword(string)
 }
 
 type server struct {
@@ -27,3 +31,7 @@ func (s *server) Start() error {
 func (s *server) Stop() error {
 	return s.gs.Stop()
 }
+
+func (s *server) SetSuperUserPassword(password string) {
+	s.gs.SetSuperUserPassword(password)
+}
@@ -3,15 +3,17 @@ package hosting
 import (
 	"fmt"
 	"io/ioutil"
+	stdlog "log"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/digitalautonomy/grumble/pkg/logtarget"
 	grumbleServer "github.com/digitalautonomy/grumble/server"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/digitalautonomy/wahay/tor"
 )
 
@@ -21,7 +23,12 @@ type Servers interface {
 	DestroyServer(Server) error
 	DataDir() string
 	Cleanup()
-	NewService(port string, t tor.Instance) (Service, error)
+	NewService(ports ServicePorts, t tor.Instance, fileSharing, embedCertificate bool, invitees int) (Service, error)
+
+	// NewRoomService creates a hosting service for the room, with its
+	// onion address, invitation token and certificate. A new room is
+	// created when it's nil, and the service returns it with Room
+	NewRoomService(room *Room, ports ServicePorts, t tor.Instance, fileSharing, embedCertificate bool) (Service, error)
 }
 
 // MeetingData is a representation of the data used to create a Mumble url
@@ -31,6 +38,23 @@ type MeetingData struct {
 	Port      int
 	Password  string
 	Username  string
+	Token     string
+
+	// CertificateURL is the location of the certificate of an
+	// external Mumble server. It's empty for Wahay meetings
+	CertificateURL string
+
+	// Fingerprint is the SHA-256 fingerprint of the certificate
+	// of the server, when the invitation contains it
+	Fingerprint string
+
+	// Certificate is the certificate of the server, in DER format,
+	// when the invitation contains it instead of its location
+	Certificate []byte
+
+	// ClientAuth is the private key that authorizes us to reach
+	// the onion service of the meeting, when it's needed
+	ClientAuth string
 }
 
 func create() (Servers, error) {
@@ -56,6 +80,24 @@ func (d *MeetingData) GenerateURL() string {
 		Host:   fmt.Sprintf("%s:%d", d.MeetingID, d.Port),
 	}
 
+	// The invitation token, the certificate, its location and
+	// its fingerprint are not used by Mumble, the client will
+	// use them to request and verify the server certificate
+	q := url.Values{}
+	if d.Token != "" {
+		q.Set(InvitationTokenParam, d.Token)
+	}
+	if d.CertificateURL != "" {
+		q.Set(CertificateURLParam, d.CertificateURL)
+	}
+	if d.Fingerprint != "" {
+		q.Set(FingerprintParam, d.Fingerprint)
+	}
+	if d.Certificate != nil {
+		q.Set(EmbeddedCertificateParam, invitation.EncodeCertificate(d.Certificate))
+	}
+	u.RawQuery = q.Encode()
+
 	return u.String()
 }
 
@@ -83,18 +125,9 @@ func (s *servers) initializeDataDirectory() error {
 	return nil
 }
 
+// initializeLogging makes the Mumble servers use the logger of
+// Wahay, instead of writing a separate file in the data directory
 func (s *servers) initializeLogging() error {
-	logDir := path.Join(s.dataDir, "grumble.log")
-	grumbleServer.Args.LogPath = logDir
-
-	err := logtarget.Target.OpenFile(logDir)
-	if err != nil {
-		return err
-	}
-
-	l := log.New()
-	l.SetOutput(&logtarget.Target)
-	s.log = l
 	s.log.Info("Grumble")
 	s.log.Infof("Using data directory: %s", s.dataDir)
 
@@ -111,6 +144,9 @@ func (s *servers) initializeCertificates() error {
 		return err
 	}
 
+	config.TrackSensitiveFile(certFn, false)
+	config.TrackSensitiveFile(keyFn, false)
+
 	s.log.Debugf("Certificate output to %v", certFn)
 	s.log.Debugf("Private key output to %v", keyFn)
 	return nil
@@ -130,6 +166,7 @@ func callAll(fs ...func() error) error {
 // state it is NOT advisable to call this function
 // more than once in a program
 func (s *servers) create() error {
+	s.log = log.StandardLogger()
 	s.initializeSharedObjects()
 
 	return callAll(
@@ -161,6 +198,16 @@ func setWelcomeText(t string) func(serv *grumbleServer.Server) {
 	}
 }
 
+// setMaxBandwidth limits the bandwidth of every participant, in bits
+// per second. The Mumble clients lower their quality to fit in it
+func setMaxBandwidth(bps int) func(*grumbleServer.Server) {
+	return func(serv *grumbleServer.Server) {
+		if bps > 0 {
+			serv.Set("MaxBandwidth", strconv.Itoa(bps))
+		}
+	}
+}
+
 func setPort(port string) func(*grumbleServer.Server) {
 	return func(serv *grumbleServer.Server) {
 		serv.Set("Port", port)
@@ -192,6 +239,9 @@ func (s *servers) CreateServer(modifiers []serverModifier) (Server, error) {
 		return nil, err
 	}
 
+	// The server logs with the standard log package
+	serv.Logger = stdlog.New(config.LogWriter(config.SubsystemHosting), fmt.Sprintf("[%v] ", serv.Id), 0)
+
 	s.servers[serv.Id] = serv
 
 	err = os.Mkdir(filepath.Join(s.dataDir, "servers", fmt.Sprintf("%v", serv.Id)), 0750)
@@ -217,8 +267,8 @@ func (s *servers) DataDir() string {
 }
 
 func (s *servers) Cleanup() {
-	err := os.RemoveAll(s.dataDir)
+	err := config.SecureRemove(s.dataDir)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Error cleaning up temporaries: "+err.Error())
+		fmt.Fprintf(os.Stderr, "WARNING: Error cleaning up temporaries: %s\n", err)
 	}
 }
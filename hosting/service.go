@@ -1,13 +1,17 @@
 package hosting
 
 import (
-	"errors"
 	"net"
+	"net/url"
 	"strconv"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/invitation"
 	"github.com/digitalautonomy/wahay/tor"
 )
 
@@ -23,7 +27,7 @@ const (
 	defaultHost = "127.0.0.1"
 )
 
-var errInvalidPort = errors.New("invalid port supplied")
+var errInvalidPort = failure.New("hosting.invalid-port", failure.CategoryConfiguration, "invalid port supplied", "check the port of the meeting in the settings")
 
 // SuperUserData is an struct that represents the superuser data
 // of a Grumble server
@@ -36,10 +40,109 @@ type SuperUserData struct {
 type Service interface {
 	ID() string
 	URL() string
+	Token() string
+
+	// Fingerprint returns the SHA-256 fingerprint of the certificate of
+	// the meeting, so it can be verified by the participants
+	Fingerprint() string
+
+	// Certificate returns the certificate of the meeting, in DER format,
+	// when it's given in the invitation instead of being served
+	Certificate() []byte
+
+	// ClientAuthKeys returns the private keys that authorize the
+	// invitees to reach the meeting, one for every invitation. It's
+	// empty when anyone knowing the onion address can reach it
+	ClientAuthKeys() []string
+
 	Port() int
 	ServicePort() int
 	SetWelcomeText(string)
+
+	// SetMaxBandwidth limits the bandwidth that every participant can
+	// use, in bits per second, before the conference room is created.
+	// Zero keeps the limit of the server
+	SetMaxBandwidth(int)
+
+	// SetMaxParticipants limits how many participants can be in the
+	// meeting at once, before the conference room is created. Zero
+	// doesn't limit them
+	SetMaxParticipants(int)
+
+	// SetIdleTimeout makes the participants that do nothing for the given
+	// time be removed from the meeting, before the conference room is
+	// created. Zero keeps them in the meeting
+	SetIdleTimeout(time.Duration)
+
+	// SetMeetingACL sets the privileges of the participants and the
+	// moderators, before the conference room is created
+	SetMeetingACL(MeetingACL)
+
+	// SetHostCertificate tells the SHA-1 hash of the certificate of
+	// the Mumble client the host joins with, so the host is never
+	// removed from their own meeting by its limits
+	SetHostCertificate(hash string)
+
+	// WaitingRoom returns who waits to be admitted to the meeting,
+	// or nil when the participants don't wait for the host
+	WaitingRoom() WaitingRoom
+
+	// Participants returns who is connected to the meeting, or
+	// nil when Wahay couldn't join the meeting to find it out
+	Participants() Participants
+
+	// BreakoutRooms lets the host split the meeting, or is
+	// nil when Wahay couldn't join the meeting
+	BreakoutRooms() BreakoutRooms
+
+	// Recording lets the host record the meeting, or is
+	// nil when Wahay couldn't join the meeting
+	Recording() Recording
+
+	// Chat lets the host write to the participants, or is
+	// nil when Wahay couldn't join the meeting
+	Chat() Chat
+
+	// FileDrop returns the files shared during the meeting, or
+	// nil when file sharing was not enabled for this meeting
+	FileDrop() HostFileDrop
+
+	// NewConferenceRoom starts the Mumble server of the meeting. The
+	// SuperUser gets the default name and a random password, unless
+	// they are given
 	NewConferenceRoom(password string, u SuperUserData) error
+
+	// SuperUser returns the administrator of the Mumble server,
+	// with its current password
+	SuperUser() SuperUserData
+
+	// RotateSuperUserPassword gives the SuperUser a new random
+	// password during the meeting, and returns it
+	RotateSuperUserPassword() (string, error)
+
+	// WhenPublished calls the given function once the meeting can
+	// be reached through Tor, or when we stop waiting for it
+	WhenPublished(func(error))
+
+	// Room returns what is needed for publishing the meeting again in
+	// the same address, with the same invitation. It fails when the
+	// service was not created with NewRoomService
+	Room() (*Room, error)
+
+	// WhenReachabilityChanges calls the given function with whether the
+	// participants can reach the meeting, and every time that changes.
+	// It can be called from any goroutine
+	WhenReachabilityChanges(func(Reachability))
+
+	// Announce tells the given message to everybody in the meeting,
+	// in the breakout rooms and in the waiting room too
+	Announce(message string) error
+
+	// Finish ends the meeting. The participants are told first with
+	// the given message, and they have some time to read it before
+	// the meeting stops being reachable and they are disconnected
+	Finish(message string) error
+
 	Close() error
 }
 
@@ -47,10 +150,25 @@ type service struct {
 	port        int
 	mumblePort  int
 	welcomeText string
+	bandwidth   int
+	maxUsers    int
+	idleTimeout time.Duration
+	acl         MeetingACL
+	token       string
 	onion       tor.Onion
 	room        *conferenceRoom
+	agent       *agent
 	httpServer  *webserver
+	embedded    bool
+	fileDrop    *fileDropServer
 	collection  Servers
+	clientAuth  []string
+	persistent  bool
+
+	reachability *reachabilityMonitor
+
+	superUserLock sync.Mutex
+	superUser     SuperUserData
 }
 
 func (s *service) ID() string {
@@ -58,10 +176,40 @@ func (s *service) ID() string {
 }
 
 func (s *service) URL() string {
+	u := s.ID()
 	if s.ServicePort() != DefaultPort {
-		return net.JoinHostPort(s.ID(), strconv.Itoa(s.ServicePort()))
+		u = net.JoinHostPort(s.ID(), strconv.Itoa(s.ServicePort()))
+	}
+	q := url.Values{InvitationTokenParam: {s.token}}
+	if f := s.Fingerprint(); f != "" {
+		q.Set(FingerprintParam, f)
+	}
+	if cert := s.Certificate(); cert != nil {
+		q.Set(EmbeddedCertificateParam, invitation.EncodeCertificate(cert))
+	}
+	return u + "?" + q.Encode()
+}
+
+func (s *service) Token() string {
+	return s.token
+}
+
+func (s *service) ClientAuthKeys() []string {
+	return s.clientAuth
+}
+
+func (s *service) Fingerprint() string {
+	if s.httpServer == nil {
+		return ""
+	}
+	return s.httpServer.digest
+}
+
+func (s *service) Certificate() []byte {
+	if s.httpServer == nil || !s.embedded {
+		return nil
 	}
-	return s.ID()
+	return s.httpServer.der
 }
 
 func (s *service) Port() int {
@@ -76,18 +224,123 @@ func (s *service) SetWelcomeText(t string) {
 	s.welcomeText = t
 }
 
+func (s *service) SetMaxBandwidth(bps int) {
+	s.bandwidth = bps
+}
+
+func (s *service) SetMaxParticipants(n int) {
+	s.maxUsers = n
+}
+
+func (s *service) SetIdleTimeout(d time.Duration) {
+	s.idleTimeout = d
+}
+
+func (s *service) SetMeetingACL(a MeetingACL) {
+	s.acl = a
+}
+
+func (s *service) SetHostCertificate(hash string) {
+	if s.agent != nil {
+		s.agent.setHostCertificate(hash)
+	}
+}
+
+func (s *service) WaitingRoom() WaitingRoom {
+	if s.agent == nil || !s.acl.WaitingRoom {
+		return nil
+	}
+	return s.agent
+}
+
+func (s *service) Participants() Participants {
+	if s.agent == nil {
+		return nil
+	}
+	return s.agent
+}
+
+func (s *service) BreakoutRooms() BreakoutRooms {
+	if s.agent == nil {
+		return nil
+	}
+	return s.agent
+}
+
+func (s *service) Recording() Recording {
+	if s.agent == nil {
+		return nil
+	}
+	return s.agent
+}
+
+func (s *service) Chat() Chat {
+	if s.agent == nil {
+		return nil
+	}
+	return s.agent
+}
+
+func (s *service) WhenPublished(f func(error)) {
+	s.onion.WhenPublished(f)
+}
+
+func (s *service) WhenReachabilityChanges(f func(Reachability)) {
+	s.reachability.whenChanges(f)
+}
+
+func (s *service) FileDrop() HostFileDrop {
+	if s.fileDrop == nil {
+		return nil
+	}
+	return s.fileDrop
+}
+
 type conferenceRoom struct {
 	server Server
 }
 
 func (s *service) NewConferenceRoom(password string, u SuperUserData) error {
-	serv, err := s.collection.CreateServer([]serverModifier{
+	err := s.acl.validate(password)
+	if err != nil {
+		return err
+	}
+
+	s.chooseSuperUser(u)
+	su := s.SuperUser()
+
+	modifiers := []serverModifier{
 		setDefaultOptions,
 		setWelcomeText(s.welcomeText),
+		setMaxBandwidth(s.bandwidth),
+		setMaxUsers(s.maxUsers),
 		setPort(strconv.Itoa(s.port)),
 		setPassword(password),
-		setSuperUser(u.Username, u.Password),
-	})
+		setSuperUser(su.Username, su.Password),
+		setACL(s.acl),
+	}
+
+	agentToken, err := newInvitationToken()
+	if err != nil {
+		return err
+	}
+	modifiers = append(modifiers, setAgentACL(agentToken))
+
+	if s.acl.WaitingRoom {
+		modifiers = append(modifiers, setWaitingRoom)
+	}
+
+	// Start our certification http server, unless the
+	// certificate is already given in the invitation. It's
+	// started first, so the meeting doesn't start without it
+	if !s.embedded {
+		err = s.httpServer.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	serv, err := s.collection.CreateServer(modifiers)
 	if err != nil {
 		return err
 	}
@@ -101,11 +354,25 @@ func (s *service) NewConferenceRoom(password string, u SuperUserData) error {
 		server: serv,
 	}
 
-	// Start our certification http server
-	s.httpServer.start(func(err error) {
-		// TODO: We must inform the user about this error in a proper way
-		log.Fatalf("Mumble certificate HTTP server: %v", err)
-	})
+	// Without the agent the meeting works, but nobody can be
+	// admitted from the waiting room
+	s.agent, err = openAgent(s.port, password, agentToken, s.Fingerprint(), s.acl.WaitingRoom)
+	if err != nil {
+		if s.acl.WaitingRoom {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"context": "agent",
+		}).Warningf("The participants of the meeting can't be followed: %s", err)
+	} else {
+		s.agent.enforceLimits(s.maxUsers, s.idleTimeout)
+	}
+
+	if s.fileDrop != nil {
+		s.fileDrop.start()
+	}
+
+	s.reachability.start(s.onion, s.mumblePort)
 
 	return nil
 }
@@ -114,87 +381,244 @@ func (r *conferenceRoom) close() error {
 	return r.server.Stop()
 }
 
-// NewService creates a new hosting service
-func (s *servers) NewService(port string, t tor.Instance) (Service, error) {
+// NewService creates a new hosting service, published in the given ports
+// of the onion service. When fileSharing is true, the onion service also
+// publishes the file drop of the meeting. When embedCertificate is true,
+// the certificate goes in the invitation and the onion service doesn't
+// publish the certificate server. When invitees is not zero, only that
+// many participants can reach the meeting
+func (s *servers) NewService(ports ServicePorts, t tor.Instance, fileSharing, embedCertificate bool, invitees int) (Service, error) {
+	return s.newService(ports, t, fileSharing, embedCertificate, invitees, nil)
+}
+
+func (s *servers) newService(ports ServicePorts, t tor.Instance, fileSharing, embedCertificate bool, invitees int, room *Room) (Service, error) {
 	var onionPorts []tor.OnionPort
 
-	httpServer, err := newCertificateServer(s.DataDir())
+	if room != nil && room.Port != 0 {
+		ports.Mumble = room.Port
+	}
+
+	err := ports.validate()
 	if err != nil {
 		return nil, err
 	}
 
-	onionPorts = append(onionPorts, tor.OnionPort{
-		DestinationHost: defaultHost,
-		DestinationPort: httpServer.port,
-		ServicePort:     certServerPort,
-	})
+	token, err := roomToken(room)
+	if err != nil {
+		return nil, err
+	}
+
+	superUserPassword, err := roomSuperUserPassword(room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.useRoomCertificate(room)
+	if err != nil {
+		return nil, err
+	}
+
+	certPort, err := ports.localPortFor(certServerPort)
+	if err != nil {
+		return nil, err
+	}
+
+	httpServer, err := newCertificateServer(s.DataDir(), token, certPort, ports.OnionOnly)
+	if err != nil {
+		return nil, err
+	}
 
-	p := DefaultPort
-	if port != "" {
-		p, err = strconv.Atoi(port)
+	if !embedCertificate {
+		onionPorts = append(onionPorts, httpServer.onionPort())
+	}
+
+	var fileDrop *fileDropServer
+	if fileSharing {
+		fileDropPort, err := ports.localPortFor(fileDropServicePort)
 		if err != nil {
-			return nil, errInvalidPort
+			return nil, err
 		}
+
+		fileDrop = newFileDropServer(token, fileDropPort)
+		onionPorts = append(onionPorts, tor.OnionPort{
+			DestinationHost: defaultHost,
+			DestinationPort: fileDrop.port,
+			ServicePort:     fileDropServicePort,
+		})
 	}
 
-	serverPort := config.GetRandomPort()
+	p := ports.mumblePort()
+	serverPort, err := ports.mumbleListenerPort()
+	if err != nil {
+		return nil, err
+	}
 
 	onionPorts = append(onionPorts, tor.OnionPort{
 		DestinationHost: defaultHost,
 		DestinationPort: serverPort,
 		ServicePort:     p,
 	})
-
-	onion, err := t.NewOnionServiceWithMultiplePorts(onionPorts)
+	onionPorts = append(onionPorts, ports.forwardedPorts()...)
+
+	var onion tor.Onion
+	var clientAuth []string
+	if room != nil {
+		// The invitations of a room are given again and again, so
+		// they can't have a key for only one participant
+		onion, err = t.NewOnionServiceWithKey(onionPorts, room.OnionKey)
+	} else if invitees > 0 {
+		onion, clientAuth, err = newAuthorizedOnion(t, onionPorts, invitees)
+	} else {
+		onion, err = t.NewOnionServiceWithMultiplePorts(onionPorts)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	httpServer.setOnionHost(onion.ID())
+
 	ss := &service{
 		port:       serverPort,
 		mumblePort: p,
+		token:      token,
 		onion:      onion,
 		httpServer: httpServer,
+		embedded:   embedCertificate,
+		fileDrop:   fileDrop,
 		collection: s,
+		clientAuth: clientAuth,
+		persistent: room != nil,
+
+		reachability: newReachabilityMonitor(),
+
+		superUser: SuperUserData{Password: superUserPassword},
 	}
 
 	return ss, nil
 }
 
+// newAuthorizedOnion creates the onion service of the meeting with a
+// key for every invitee, and one more for the host. Our own Tor gets that
+// one, so the host can join the meeting and share files through it
+func newAuthorizedOnion(t tor.Instance, ports []tor.OnionPort, invitees int) (tor.Onion, []string, error) {
+	var private, public []string
+	for i := 0; i <= invitees; i++ {
+		k, err := tor.NewClientAuthKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		private = append(private, k.PrivateKey())
+		public = append(public, k.PublicKey())
+	}
+
+	onion, err := t.NewOnionServiceWithClientAuth(ports, public)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = t.AuthorizeOnion(onion.ID(), private[0])
+	if err != nil {
+		_ = onion.Delete()
+		return nil, nil, err
+	}
+
+	return onion, private[1:], nil
+}
+
+const invitationTokenLength = 32
+
+func newInvitationToken() (string, error) {
+	t := make([]byte, invitationTokenLength)
+	err := config.RandomString(t)
+	if err != nil {
+		return "", err
+	}
+	return string(t), nil
+}
+
 var (
 	// ErrServerNoClosed is an error to return when the server can't be stopped
-	ErrServerNoClosed = errors.New("the current server can't be stopped")
+	ErrServerNoClosed = failure.New("hosting.server-not-closed", failure.CategoryHosting, "the current server can't be stopped", "")
 	// ErrServerOnionDelete is an error to return when the hidden service can't be deleted
-	ErrServerOnionDelete = errors.New("the hidden service can't be deleted")
+	ErrServerOnionDelete = failure.New("hosting.onion-not-deleted", failure.CategoryHosting, "the hidden service can't be deleted", "")
 )
 
+// meetingEndGracePeriod is how long the participants have to read
+// that the meeting has ended, before they are disconnected
+const meetingEndGracePeriod = 5 * time.Second
+
+func (s *service) Announce(message string) error {
+	if s.agent == nil {
+		return errAgentClosed
+	}
+
+	_, err := s.agent.announce(message)
+	return err
+}
+
+func (s *service) Finish(message string) error {
+	if s.agent != nil && message != "" {
+		told, err := s.agent.announce(message)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "hosting",
+			}).Warningf("The participants can't be told that the meeting has ended: %s", err)
+		}
+
+		if told > 0 {
+			time.Sleep(meetingEndGracePeriod)
+		}
+	}
+
+	return s.Close()
+}
+
+// Close stops the meeting. The onion service is removed first, so
+// nobody can arrive while the servers are stopped, and the data of
+// the meeting is wiped at the end, even when something failed before
 func (s *service) Close() error {
-	var err error
+	var result error
+
+	// The monitor could create the onion service again otherwise
+	s.reachability.stop()
+
+	if s.onion != nil {
+		err := s.onion.Delete()
+		if err != nil {
+			log.Errorf("hosting delete hidden service: Close(): %s", err)
+			result = ErrServerOnionDelete
+		}
+	}
 
 	if s.httpServer != nil {
-		err = s.httpServer.stop()
+		err := s.httpServer.stop()
 		if err != nil {
 			log.Errorf("hosting stop http server: Close(): %s", err)
 		}
 	}
 
-	if s.room != nil {
-		err = s.room.close()
+	if s.fileDrop != nil {
+		err := s.fileDrop.stop()
 		if err != nil {
-			log.Errorf("hosting stop server: Close(): %s", err)
-			return ErrServerNoClosed
+			log.Errorf("hosting stop file drop server: Close(): %s", err)
 		}
 	}
 
-	if s.onion != nil {
-		err = s.onion.Delete()
+	if s.agent != nil {
+		s.agent.close()
+	}
+
+	if s.room != nil {
+		err := s.room.close()
 		if err != nil {
-			log.Errorf("hosting delete hidden service: Close(): %s", err)
-			return ErrServerOnionDelete
+			log.Errorf("hosting stop server: Close(): %s", err)
+			if result == nil {
+				result = ErrServerNoClosed
+			}
 		}
 	}
 
 	s.collection.Cleanup()
 
-	return nil
+	return result
 }
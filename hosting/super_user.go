@@ -0,0 +1,77 @@
+package hosting
+
+import (
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// Every meeting has a SuperUser, the administrator of the Mumble server,
+// and it always has a random password, which is the one of the room for
+// the meeting rooms. Grumble only keeps a hash of it. The host can copy
+// it to administer the server with Mumble, and change it during the
+// meeting, so whoever got it before can't use it anymore
+
+const (
+	// DefaultSuperUserName is how the administrator of the
+	// Mumble server is called, unless the host chooses a name
+	DefaultSuperUserName = "SuperUser"
+
+	superUserPasswordLength = 24
+)
+
+var errNoConferenceRoom = failure.New("hosting.no-conference-room", failure.CategoryHosting, "the meeting has not started yet", "")
+
+func newSuperUserPassword() (string, error) {
+	return randomHexString(superUserPasswordLength)
+}
+
+// roomSuperUserPassword returns the password of the SuperUser of
+// the room, or a new one for the rooms that don't have it yet
+func roomSuperUserPassword(room *Room) (string, error) {
+	if room != nil && room.SuperUserPassword != "" {
+		return room.SuperUserPassword, nil
+	}
+	return newSuperUserPassword()
+}
+
+// chooseSuperUser completes the SuperUser given for the meeting with
+// the default name, and with the password chosen for the meeting
+func (s *service) chooseSuperUser(u SuperUserData) {
+	s.superUserLock.Lock()
+	defer s.superUserLock.Unlock()
+
+	if u.Username == "" {
+		u.Username = DefaultSuperUserName
+	}
+
+	if u.Password == "" {
+		u.Password = s.superUser.Password
+	}
+
+	s.superUser = u
+}
+
+func (s *service) SuperUser() SuperUserData {
+	s.superUserLock.Lock()
+	defer s.superUserLock.Unlock()
+
+	return s.superUser
+}
+
+func (s *service) RotateSuperUserPassword() (string, error) {
+	if s.room == nil {
+		return "", errNoConferenceRoom
+	}
+
+	p, err := newSuperUserPassword()
+	if err != nil {
+		return "", err
+	}
+
+	s.superUserLock.Lock()
+	defer s.superUserLock.Unlock()
+
+	s.room.server.SetSuperUserPassword(p)
+	s.superUser.Password = p
+
+	return p, nil
+}
@@ -0,0 +1,183 @@
+package hosting
+
+import (
+	"sort"
+
+	"github.com/digitalautonomy/grumble/pkg/acl"
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	grumbleServer "github.com/digitalautonomy/grumble/server"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// When a meeting has a waiting room, the root channel of the Mumble
+// server is where the participants arrive, and they can't talk there.
+// The meeting happens in a channel that only the moderators can enter
+// by themselves, and the agent moves the participants into it when
+// the host admits them - like the host could do in Mumble. The same
+// goes for its breakout rooms, which are channels inside of it
+
+const (
+	waitingRoomChannelName = "Waiting room"
+	meetingChannelName     = "Meeting"
+)
+
+var (
+	errNotWaiting = failure.New("hosting.not-waiting", failure.CategoryHosting, "the participant is not in the waiting room anymore", "")
+)
+
+// WaitingParticipant is somebody that waits to be admitted to the meeting
+type WaitingParticipant struct {
+	// Session identifies the participant while connected
+	Session uint32
+	Name    string
+}
+
+// WaitingRoom tells who arrives to a meeting, which they can't
+// hear or talk in until the host admits them
+type WaitingRoom interface {
+	// WhenWaiting calls the given function, in a goroutine
+	// of its own, every time somebody arrives
+	WhenWaiting(func(WaitingParticipant))
+
+	// Waiting returns the participants that are waiting now
+	Waiting() []WaitingParticipant
+
+	// Admit moves the participant into the meeting
+	Admit(session uint32) error
+
+	// Reject removes the participant from the server
+	Reject(session uint32) error
+}
+
+// setWaitingRoom creates the channel of the meeting below the root
+// channel, which becomes the waiting room. It must be applied after
+// the rest of the ACLs, since it only adds entries to them
+func setWaitingRoom(serv *grumbleServer.Server) {
+	root := serv.RootChannel()
+	root.Name = waitingRoomChannelName
+
+	meeting := serv.AddChannel(meetingChannelName)
+	root.AddChild(meeting)
+	meeting.ACL.InheritACL = true
+
+	root.ACL.ACLs = append(root.ACL.ACLs, acl.ACL{
+		UserId:    -1,
+		Group:     "all",
+		ApplyHere: true,
+		Deny:      acl.SpeakPermission | acl.WhisperPermission | acl.TextMessagePermission,
+	})
+
+	meeting.ACL.ACLs = []acl.ACL{{
+		UserId:    -1,
+		Group:     "all",
+		ApplyHere: true,
+		ApplySubs: true,
+		Deny:      acl.EnterPermission,
+	}}
+
+	serv.ClearCaches()
+}
+
+// isWaiting returns whether the user needs to be admitted. The super
+// user, which is the only registered one, and the Mumble client of the
+// host never wait
+func (a *agent) isWaiting(session uint32, u *agentUser) bool {
+	return a.waitingRoom && session != a.self && !u.registered && !a.isHost(u) && u.channel == 0
+}
+
+// admitHost moves the Mumble client of the host into the meeting, since
+// nobody else might be there to admit them. The host can be recognized
+// after arriving, so it's checked for every change. It must be called
+// with the lock held
+func (a *agent) admitHost(session uint32, u *agentUser) {
+	if !a.waitingRoom || !a.synced || session == a.self || u.admitted || !a.isHost(u) || u.channel != 0 || a.meeting == 0 {
+		return
+	}
+	u.admitted = true
+
+	m := &mumbleproto.UserState{
+		Session:   proto.Uint32(session),
+		ChannelId: proto.Uint32(a.meeting),
+	}
+	go func() {
+		_ = a.send(mumbleproto.MessageUserState, m)
+	}()
+}
+
+func (a *agent) notifyWaiting(session uint32, u *agentUser) {
+	p := WaitingParticipant{Session: session, Name: u.name}
+	for _, f := range a.onWaiting {
+		go f(p)
+	}
+}
+
+func (a *agent) WhenWaiting(f func(WaitingParticipant)) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.onWaiting = append(a.onWaiting, f)
+}
+
+func (a *agent) Waiting() []WaitingParticipant {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var result []WaitingParticipant
+	for session, u := range a.users {
+		if a.isWaiting(session, u) {
+			result = append(result, WaitingParticipant{Session: session, Name: u.name})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Session < result[j].Session
+	})
+
+	return result
+}
+
+func (a *agent) checkWaiting(session uint32) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, err := a.checkUser(session)
+	if err != nil {
+		return err
+	}
+
+	if !a.isWaiting(session, u) {
+		return errNotWaiting
+	}
+
+	return nil
+}
+
+func (a *agent) Admit(session uint32) error {
+	err := a.checkWaiting(session)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	meeting := a.meeting
+	a.lock.Unlock()
+
+	return a.send(mumbleproto.MessageUserState, &mumbleproto.UserState{
+		Session:   proto.Uint32(session),
+		ChannelId: proto.Uint32(meeting),
+	})
+}
+
+func (a *agent) Reject(session uint32) error {
+	err := a.checkWaiting(session)
+	if err != nil {
+		return err
+	}
+
+	return a.send(mumbleproto.MessageUserRemove, &mumbleproto.UserRemove{
+		Session: proto.Uint32(session),
+		Reason:  proto.String("The host didn't admit you to the meeting"),
+	})
+}
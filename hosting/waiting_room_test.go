@@ -0,0 +1,151 @@
+package hosting
+
+import (
+	"time"
+
+	"github.com/digitalautonomy/grumble/pkg/mumbleproto"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/digitalautonomy/wahay/failure"
+
+	. "gopkg.in/check.v1"
+)
+
+type WaitingRoomSuite struct{}
+
+var _ = Suite(&WaitingRoomSuite{})
+
+const waitingRoomTestMeeting = 7
+
+// newWaitingRoomTestAgent returns an agent in a meeting with a waiting
+// room, which tells the sessions of who waits in the returned channel
+func newWaitingRoomTestAgent() (*agent, <-chan agentMessage, <-chan uint32) {
+	a, messages := newTestAgent()
+	a.waitingRoom = true
+	a.updateChannel(&mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(rootChannel),
+		Name:      proto.String(waitingRoomChannelName),
+	})
+	a.updateChannel(&mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(waitingRoomTestMeeting),
+		Parent:    proto.Uint32(rootChannel),
+		Name:      proto.String(meetingChannelName),
+	})
+
+	waiting := make(chan uint32, 16)
+	a.WhenWaiting(func(p WaitingParticipant) {
+		waiting <- p.Session
+	})
+
+	join(a, a.self, agentName, "")
+	a.synchronized(a.self)
+
+	return a, messages, waiting
+}
+
+func nextWaiting(c *C, waiting <-chan uint32) uint32 {
+	select {
+	case session := <-waiting:
+		return session
+	case <-time.After(5 * time.Second):
+		c.Fatal("nobody is waiting")
+	}
+	return 0
+}
+
+func (s *WaitingRoomSuite) Test_isWaiting_isOnlyForTheParticipantsThatArrive(c *C) {
+	a, messages, waiting := newWaitingRoomTestAgent()
+	a.setHostCertificate("0123abcd")
+
+	join(a, 2, "Alice", "aaaa")
+	c.Assert(nextWaiting(c, waiting), Equals, uint32(2))
+
+	a.updateUser(&mumbleproto.UserState{
+		Session: proto.Uint32(3),
+		Name:    proto.String("SuperUser"),
+		UserId:  proto.Uint32(0),
+	})
+
+	join(a, 4, "Host", "0123abcd")
+	var m mumbleproto.UserState
+	nextMessage(c, messages, mumbleproto.MessageUserState, &m)
+	c.Assert(m.GetSession(), Equals, uint32(4))
+
+	c.Assert(a.Waiting(), DeepEquals, []WaitingParticipant{{Session: 2, Name: "Alice"}})
+	c.Assert(waiting, HasLen, 0)
+
+	c.Assert(failure.Kind(a.Admit(3)), Equals, errNotWaiting)
+	c.Assert(failure.Kind(a.Admit(4)), Equals, errNotWaiting)
+	c.Assert(a.Reject(a.self), NotNil)
+}
+
+func (s *WaitingRoomSuite) Test_updateUser_admitsTheHostIntoTheMeetingOnce(c *C) {
+	a, messages, waiting := newWaitingRoomTestAgent()
+	a.setHostCertificate("0123ABCD")
+
+	join(a, 2, "Host", "0123abcd")
+
+	var m mumbleproto.UserState
+	nextMessage(c, messages, mumbleproto.MessageUserState, &m)
+	c.Assert(m.GetSession(), Equals, uint32(2))
+	c.Assert(m.GetChannelId(), Equals, uint32(waitingRoomTestMeeting))
+
+	a.updateUser(&mumbleproto.UserState{
+		Session:  proto.Uint32(2),
+		SelfMute: proto.Bool(true),
+	})
+	noMessage(c, messages)
+	c.Assert(waiting, HasLen, 0)
+}
+
+func (s *WaitingRoomSuite) Test_setHostCertificate_admitsTheHostThatIsWaitingAlready(c *C) {
+	a, messages, waiting := newWaitingRoomTestAgent()
+
+	join(a, 2, "Alice", "aaaa")
+	c.Assert(nextWaiting(c, waiting), Equals, uint32(2))
+	join(a, 3, "Host", "0123abcd")
+	c.Assert(nextWaiting(c, waiting), Equals, uint32(3))
+	noMessage(c, messages)
+
+	a.setHostCertificate("0123abcd")
+
+	var m mumbleproto.UserState
+	nextMessage(c, messages, mumbleproto.MessageUserState, &m)
+	c.Assert(m.GetSession(), Equals, uint32(3))
+	c.Assert(m.GetChannelId(), Equals, uint32(waitingRoomTestMeeting))
+	noMessage(c, messages)
+
+	c.Assert(a.Waiting(), DeepEquals, []WaitingParticipant{{Session: 2, Name: "Alice"}})
+}
+
+func (s *WaitingRoomSuite) Test_Admit_movesTheParticipantIntoTheMeeting(c *C) {
+	a, messages, waiting := newWaitingRoomTestAgent()
+
+	join(a, 2, "Alice", "aaaa")
+	c.Assert(nextWaiting(c, waiting), Equals, uint32(2))
+
+	c.Assert(a.Admit(2), IsNil)
+
+	var m mumbleproto.UserState
+	nextMessage(c, messages, mumbleproto.MessageUserState, &m)
+	c.Assert(m.GetSession(), Equals, uint32(2))
+	c.Assert(m.GetChannelId(), Equals, uint32(waitingRoomTestMeeting))
+
+	a.updateUser(&mumbleproto.UserState{
+		Session:   proto.Uint32(2),
+		ChannelId: proto.Uint32(waitingRoomTestMeeting),
+	})
+	c.Assert(a.Waiting(), HasLen, 0)
+	c.Assert(failure.Kind(a.Reject(2)), Equals, errNotWaiting)
+}
+
+func (s *WaitingRoomSuite) Test_isWaiting_withoutAWaitingRoom(c *C) {
+	a, messages := newSyncedTestAgent()
+	a.setHostCertificate("0123abcd")
+
+	join(a, 2, "Alice", "aaaa")
+	join(a, 3, "Host", "0123abcd")
+
+	c.Assert(a.Waiting(), HasLen, 0)
+	noMessage(c, messages)
+}
@@ -0,0 +1,69 @@
+package hosting
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+const (
+	// WebhookEventMeetingStarted is the event sent to the webhook
+	// when a hosted meeting becomes reachable
+	WebhookEventMeetingStarted = "meeting_started"
+
+	webhookContentType = "application/json"
+)
+
+var (
+	// ErrInvalidWebhookURL is an error to be trown when the configured
+	// webhook is not an absolute http or https URL
+	ErrInvalidWebhookURL = failure.New("hosting.invalid-webhook-url", failure.CategoryConfiguration, "the webhook URL is not valid", "check the webhook in the settings")
+)
+
+// WebhookPayload is the content sent to the webhook configured by the host
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	MeetingID  string    `json:"meeting_id"`
+	Invitation string    `json:"invitation,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// ValidateWebhookURL checks that the given URL can be used as a webhook.
+// An empty URL is valid and means the webhook is disabled
+func ValidateWebhookURL(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return nil
+	}
+
+	return ErrInvalidWebhookURL
+}
+
+// NotifyWebhook sends the given payload to the webhook URL. The request
+// is always made through Tor, so the host's IP address is not revealed
+func NotifyWebhook(t tor.Instance, webhookURL string, p *WebhookPayload) error {
+	err := ValidateWebhookURL(webhookURL)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return t.HTTPPost(webhookURL, webhookContentType, body)
+}
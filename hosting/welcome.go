@@ -0,0 +1,33 @@
+package hosting
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// The welcome message is what the Mumble clients show every participant
+// when they join the meeting. Mumble shows it as HTML, so the host writes
+// plain text with some of the formatting of Markdown, which is turned
+// into HTML here. Anything else the host writes is escaped
+
+var (
+	welcomeBold   = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	welcomeItalic = regexp.MustCompile(`\*([^*\n]+)\*`)
+	welcomeLinks  = regexp.MustCompile(`https?://[^\s<*]+`)
+)
+
+// WelcomeTextHTML returns the welcome message written by the host as
+// Mumble shows it. Text between ** is bold, text between * is italic,
+// and the web addresses are links
+func WelcomeTextHTML(text string) string {
+	s := html.EscapeString(strings.TrimSpace(text))
+
+	s = welcomeLinks.ReplaceAllStringFunc(s, func(url string) string {
+		return `<a href="` + url + `">` + url + `</a>`
+	})
+	s = welcomeBold.ReplaceAllString(s, "<b>$1</b>")
+	s = welcomeItalic.ReplaceAllString(s, "<i>$1</i>")
+
+	return strings.Replace(s, "\n", "<br/>", -1)
+}
@@ -0,0 +1,210 @@
+package invitation
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// FileExtension is the extension used by the invitation files
+	FileExtension = ".wahay"
+
+	// FileMIMEType is the MIME type registered for the invitation files
+	FileMIMEType = "application/x-wahay-invitation"
+
+	fileFormatVersion = 1
+	maxFileSize       = 64 * 1024
+)
+
+// The scrypt parameters are lower than the ones used for the
+// configuration file, since the invitation is short lived and
+// should be opened quickly in any computer
+const (
+	fileScryptN     = 1 << 15
+	fileScryptR     = 8
+	fileScryptP     = 1
+	fileKeyLength   = 32
+	fileSaltLength  = 16
+	fileNonceLength = 12
+)
+
+var (
+	// ErrInvalidFile is an error to be trown when the content of
+	// the given file is not a Wahay invitation
+	ErrInvalidFile = errors.New("the file is not a valid Wahay invitation")
+
+	// ErrPassphraseRequired is an error to be trown when the
+	// invitation file is encrypted and no passphrase was given
+	ErrPassphraseRequired = errors.New("the invitation file is protected with a passphrase")
+
+	// ErrWrongPassphrase is an error to be trown when the
+	// invitation file can't be decrypted with the given passphrase
+	ErrWrongPassphrase = errors.New("the passphrase of the invitation file is not correct")
+)
+
+type invitationFile struct {
+	Version    int    `json:"version"`
+	Invitation string `json:"invitation,omitempty"`
+	Salt       string `json:"salt,omitempty"`
+	Nonce      string `json:"nonce,omitempty"`
+	Encrypted  string `json:"encrypted,omitempty"`
+}
+
+// ExportFile serializes the invitation into the content of an invitation
+// file. When a passphrase is given, the invitation is encrypted with it
+func (i *Invitation) ExportFile(passphrase string) ([]byte, error) {
+	encoded, err := i.EncodeV2()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &invitationFile{
+		Version: fileFormatVersion,
+	}
+
+	if passphrase == "" {
+		f.Invitation = encoded
+	} else {
+		err = f.encrypt(encoded, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(f, "", "\t")
+}
+
+// ImportFile reads the content of an invitation file. The passphrase
+// is only used if the file is encrypted
+func ImportFile(content []byte, passphrase string) (*Invitation, error) {
+	f, err := readInvitationFile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := f.Invitation
+	if f.isEncrypted() {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+
+		encoded, err = f.decrypt(passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !isV2(encoded) {
+		return nil, ErrInvalidFile
+	}
+
+	return Parse(encoded)
+}
+
+// IsEncryptedFile returns true if the given invitation file requires a passphrase
+func IsEncryptedFile(content []byte) bool {
+	f, err := readInvitationFile(content)
+	return err == nil && f.isEncrypted()
+}
+
+// IsInvitationFileName returns true if the given file name has the invitation file extension
+func IsInvitationFileName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), FileExtension)
+}
+
+func readInvitationFile(content []byte) (*invitationFile, error) {
+	if len(content) == 0 || len(content) > maxFileSize {
+		return nil, ErrInvalidFile
+	}
+
+	f := &invitationFile{}
+	err := json.Unmarshal(content, f)
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+
+	if f.Version != fileFormatVersion {
+		return nil, ErrUnsupportedInvitationVersion
+	}
+
+	if f.Invitation == "" && !f.isEncrypted() {
+		return nil, ErrInvalidFile
+	}
+
+	return f, nil
+}
+
+func (f *invitationFile) isEncrypted() bool {
+	return f.Encrypted != ""
+}
+
+func fileKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, fileScryptN, fileScryptR, fileScryptP, fileKeyLength)
+}
+
+func (f *invitationFile) encrypt(plain, passphrase string) error {
+	salt := make([]byte, fileSaltLength)
+	nonce := make([]byte, fileNonceLength)
+
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	aead, err := fileCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	f.Salt = base64.StdEncoding.EncodeToString(salt)
+	f.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	f.Encrypted = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, []byte(plain), nil))
+
+	return nil
+}
+
+func (f *invitationFile) decrypt(passphrase string) (string, error) {
+	salt, err1 := base64.StdEncoding.DecodeString(f.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(f.Nonce)
+	data, err3 := base64.StdEncoding.DecodeString(f.Encrypted)
+	if err1 != nil || err2 != nil || err3 != nil || len(nonce) != fileNonceLength {
+		return "", ErrInvalidFile
+	}
+
+	aead, err := fileCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+
+	return string(plain), nil
+}
+
+func fileCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := fileKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,173 @@
+package invitation
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	fingerprintLength = sha256.Size
+
+	// fingerprintPrefix names the digest, as SSH and other tools do
+	fingerprintPrefix = "sha256:"
+
+	fingerprintFragmentParam = "fp"
+	clientAuthFragmentParam  = "auth"
+)
+
+var (
+	// ErrInvalidFingerprint is an error to be trown when the fingerprint of
+	// the certificate is not the hexadecimal form of a SHA-256 digest
+	ErrInvalidFingerprint = errors.New("the certificate fingerprint is not valid")
+
+	// ErrInvalidCertificate is an error to be trown when the certificate
+	// included in the invitation can't be parsed, or it doesn't match
+	// the fingerprint given with it
+	ErrInvalidCertificate = errors.New("the certificate in the invitation is not valid")
+)
+
+// CertificateFingerprint returns the SHA-256 fingerprint of the given
+// certificate, in DER format, as it's written in the invitations
+func CertificateFingerprint(cert []byte) string {
+	sum := sha256.Sum256(cert)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint validates the given fingerprint and returns it in
+// lowercase. The separators people usually write, colons and spaces, are
+// ignored, and so is the "SHA256:" prefix naming the digest
+func NormalizeFingerprint(s string) (string, error) {
+	f := strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToLower(f), fingerprintPrefix) {
+		f = f[len(fingerprintPrefix):]
+	}
+
+	f = strings.NewReplacer(":", "", " ", "").Replace(f)
+	if f == "" {
+		return "", nil
+	}
+
+	decoded, err := hex.DecodeString(f)
+	if err != nil || len(decoded) != fingerprintLength {
+		return "", ErrInvalidFingerprint
+	}
+
+	return hex.EncodeToString(decoded), nil
+}
+
+// FormatFingerprint returns the fingerprint in groups of four
+// characters, so it's easier to compare it when reading it aloud
+func FormatFingerprint(f string) string {
+	groups := make([]string, 0, len(f)/4+1)
+	for len(f) > 4 {
+		groups = append(groups, f[:4])
+		f = f[4:]
+	}
+	groups = append(groups, f)
+
+	return strings.ToUpper(strings.Join(groups, " "))
+}
+
+// parseFragment returns the fingerprint and the client authorization
+// key given in the fragment of an invitation URL, like
+// "fp=SHA256:<fingerprint>&auth=<key>". Nothing else is expected
+// in the fragment of a meeting invitation
+func parseFragment(fragment string) (fingerprint, clientAuth string, err error) {
+	if fragment == "" {
+		return "", "", nil
+	}
+
+	values, err := url.ParseQuery(fragment)
+	if err != nil || len(values) == 0 {
+		return "", "", ErrUnexpectedContent
+	}
+
+	for k, v := range values {
+		if len(v) != 1 || (k != fingerprintFragmentParam && k != clientAuthFragmentParam) {
+			return "", "", ErrUnexpectedContent
+		}
+	}
+
+	if values.Get(fingerprintFragmentParam) != "" {
+		fingerprint, err = NormalizeFingerprint(values.Get(fingerprintFragmentParam))
+		if err != nil || fingerprint == "" {
+			return "", "", ErrInvalidFingerprint
+		}
+	}
+
+	if _, ok := values[clientAuthFragmentParam]; ok {
+		k, err := decodeClientAuth(values.Get(clientAuthFragmentParam))
+		if err != nil {
+			return "", "", err
+		}
+		clientAuth = b32.EncodeToString(k)
+	}
+
+	return fingerprint, clientAuth, nil
+}
+
+// fragment returns the fingerprint and the client authorization key
+// of the invitation, which are never sent to any server
+func (i *Invitation) fragment() string {
+	var parts []string
+	if i.Fingerprint != "" {
+		parts = append(parts, fingerprintFragmentParam+"="+strings.ToUpper(fingerprintPrefix)+i.Fingerprint)
+	}
+	if i.ClientAuth != "" {
+		parts = append(parts, clientAuthFragmentParam+"="+i.ClientAuth)
+	}
+	return strings.Join(parts, "&")
+}
+
+// EncodeCertificate returns the given certificate, in DER format,
+// as it's included in the invitations
+func EncodeCertificate(cert []byte) string {
+	return base64.RawURLEncoding.EncodeToString(cert)
+}
+
+// DecodeCertificate returns the certificate included in an
+// invitation, in DER format, once it has been validated
+func DecodeCertificate(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	cert, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, ErrInvalidCertificate
+	}
+
+	return cert, checkCertificate(cert)
+}
+
+func checkCertificate(cert []byte) error {
+	_, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return ErrInvalidCertificate
+	}
+	return nil
+}
+
+// setCertificate adds the certificate to the invitation, which also
+// gives its fingerprint when the invitation didn't have it
+func (i *Invitation) setCertificate(cert []byte) error {
+	if cert == nil {
+		return nil
+	}
+
+	f := CertificateFingerprint(cert)
+	if i.Fingerprint != "" && i.Fingerprint != f {
+		return ErrInvalidCertificate
+	}
+
+	i.Certificate = cert
+	i.Fingerprint = f
+
+	return nil
+}
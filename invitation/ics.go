@@ -0,0 +1,128 @@
+package invitation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// CalendarFileExtension is the extension used by the calendar entries
+	CalendarFileExtension = ".ics"
+
+	// CalendarMIMEType is the MIME type of the calendar entries
+	CalendarMIMEType = "text/calendar"
+
+	icsTimeFormat    = "20060102T150405Z"
+	icsMaxLineLength = 75
+	icsProductID     = "-//Wahay//Wahay Meeting//EN"
+)
+
+var (
+	// ErrInvalidSchedule is an error to be trown when the duration
+	// of a scheduled meeting is not positive
+	ErrInvalidSchedule = errors.New("the meeting must last at least one minute")
+)
+
+// ExportICalendar returns an iCalendar (RFC 5545) file with an event
+// for the meeting at the given time. The invitation is included in
+// the description of the event, so participants have everything
+// they need for joining it in their calendars
+func (i *Invitation) ExportICalendar(start time.Time, duration time.Duration) ([]byte, error) {
+	if duration < time.Minute {
+		return nil, ErrInvalidSchedule
+	}
+
+	encoded, err := i.EncodeV2()
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := newEventUID()
+	if err != nil {
+		return nil, err
+	}
+
+	title := i.Title
+	if title == "" {
+		title = "Wahay meeting"
+	}
+
+	description := fmt.Sprintf("Join the meeting with Wahay.\n\nMeeting ID: %s\n\nOr paste this invitation in Wahay: %s",
+		i.URL(), encoded)
+
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:"+icsProductID)
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "METHOD:PUBLISH")
+	writeICSLine(&buf, "BEGIN:VEVENT")
+	writeICSLine(&buf, "UID:"+uid)
+	writeICSLine(&buf, "DTSTAMP:"+icsTime(time.Now()))
+	writeICSLine(&buf, "DTSTART:"+icsTime(start))
+	writeICSLine(&buf, "DTEND:"+icsTime(start.Add(duration)))
+	writeICSLine(&buf, "SUMMARY:"+escapeICSText(title))
+	writeICSLine(&buf, "DESCRIPTION:"+escapeICSText(description))
+	writeICSLine(&buf, "LOCATION:"+escapeICSText(i.MeetingID()))
+	writeICSLine(&buf, "END:VEVENT")
+	writeICSLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes(), nil
+}
+
+func newEventUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + "@wahay", nil
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format(icsTimeFormat)
+}
+
+var icsTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// writeICSLine writes the given content line, folding it
+// in lines of at most 75 octets as required by the RFC 5545
+func writeICSLine(buf *bytes.Buffer, line string) {
+	max := icsMaxLineLength
+	for len(line) > max {
+		cut := max
+		// We never split a multi-byte character
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+
+		// The space starting the folded lines is part of them
+		max = icsMaxLineLength - 1
+	}
+
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isUTF8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,506 @@
+// Package invitation validates and normalizes the invitations used to join meetings
+package invitation
+
+import (
+	"encoding/base32"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// DefaultPort is the port used by a meeting when the invitation doesn't specify one
+const DefaultPort = 64738
+
+const (
+	schemeMumble      = "mumble"
+	schemeWahay       = "wahay"
+	tokenParam        = "token"
+	certificateParam  = "certificate"
+	fingerprintParam  = "fingerprint"
+	embeddedCertParam = "cert"
+
+	onionSuffix         = ".onion"
+	onionV3Length       = 56
+	onionV2Length       = 16
+	onionV3Version      = 3
+	onionChecksumPrefix = ".onion checksum"
+)
+
+var (
+	// ErrEmptyInvitation is an error to be trown when the invitation is blank
+	ErrEmptyInvitation = errors.New("the meeting ID cannot be blank")
+
+	// ErrInvalidScheme is an error to be trown when the invitation
+	// uses a scheme different from the ones Wahay understands
+	ErrInvalidScheme = errors.New("the invitation uses an unsupported scheme")
+
+	// ErrMalformedInvitation is an error to be trown when the
+	// invitation can't be parsed at all
+	ErrMalformedInvitation = errors.New("the invitation is malformed")
+
+	// ErrUnexpectedContent is an error to be trown when the invitation
+	// contains a path or a fragment, which a meeting invitation never has
+	ErrUnexpectedContent = errors.New("the invitation contains unexpected content")
+
+	// ErrNotAnOnionAddress is an error to be trown when the host of
+	// the invitation is not an onion service address
+	ErrNotAnOnionAddress = errors.New("the meeting ID is not an onion address")
+
+	// ErrOnionV2NotSupported is an error to be trown when the invitation
+	// points to a version 2 onion service, which is not supported anymore
+	ErrOnionV2NotSupported = errors.New("version 2 onion addresses are not supported")
+
+	// ErrInvalidOnionAddress is an error to be trown when the onion
+	// address doesn't have the format of a version 3 onion address
+	ErrInvalidOnionAddress = errors.New("the onion address is not valid")
+
+	// ErrInvalidOnionChecksum is an error to be trown when the onion
+	// address is well formed but its checksum doesn't match, which
+	// usually means there is a typo in it
+	ErrInvalidOnionChecksum = errors.New("the onion address has a typo: the checksum doesn't match")
+
+	// ErrInvalidPort is an error to be trown when the port of the
+	// invitation is not a number between 1 and 65535
+	ErrInvalidPort = errors.New("the meeting port is not valid")
+
+	// ErrInvalidHostname is an error to be trown when the address
+	// of a server that is not an onion service is not a valid hostname
+	ErrInvalidHostname = errors.New("the server address is not valid")
+
+	// ErrLocalAddress is an error to be trown when the address of a server
+	// that is not an onion service points to the local network
+	ErrLocalAddress = errors.New("the server address belongs to a local network")
+
+	// ErrInvalidCertificateLocation is an error to be trown when the
+	// location of the certificate of an external server is not a path
+	// or an http URL of an onion service
+	ErrInvalidCertificateLocation = errors.New("the certificate location is not valid")
+)
+
+// Invitation contains the normalized information needed for joining a meeting
+type Invitation struct {
+	Host       string
+	Port       int
+	Token      string
+	Username   string
+	Password   string
+	ClientAuth string
+	Expiry     time.Time
+	Title      string
+
+	// CertificateURL is the location of the certificate of an external
+	// Mumble server. It can be a path in the onion service of the server
+	CertificateURL string
+
+	// Fingerprint is the SHA-256 fingerprint of the server certificate.
+	// When it's given the certificate is trusted without asking the user
+	Fingerprint string
+
+	// Certificate is the certificate of the server, in DER format, when
+	// the host includes it in the invitation instead of serving it
+	Certificate []byte
+}
+
+// Parse validates the given invitation and returns its normalized
+// content. Invitations in the version 2 format, plain meeting IDs like
+// "<onion>.onion:port", Mumble URLs and wahay:// URLs are accepted, any
+// other scheme is rejected. The URLs can give the fingerprint of the
+// certificate in their fragment, like "#fp=SHA256:<fingerprint>"
+func Parse(s string) (*Invitation, error) {
+	return parse(s, false)
+}
+
+// ParseAllowingClearnet works like Parse, but it also accepts the
+// address of Mumble servers that are not onion services
+func ParseAllowingClearnet(s string) (*Invitation, error) {
+	return parse(s, true)
+}
+
+func parse(s string, allowClearnet bool) (*Invitation, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrEmptyInvitation
+	}
+
+	if isV2(s) {
+		return parseV2(s)
+	}
+
+	if isWords(s) {
+		return parseWords(s)
+	}
+
+	u, err := parseURL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Opaque != "" || (u.Path != "" && u.Path != "/") {
+		return nil, ErrUnexpectedContent
+	}
+
+	fragmentFingerprint, clientAuth, err := parseFragment(u.Fragment)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := splitHostPort(u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowClearnet && !isOnionHost(host) {
+		host, err = normalizeClearnetHost(host)
+	} else {
+		host, err = NormalizeOnionAddress(host)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificateURL, err := NormalizeCertificateURL(u.Query().Get(certificateParam))
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := NormalizeFingerprint(u.Query().Get(fingerprintParam))
+	if err != nil {
+		return nil, err
+	}
+
+	if fragmentFingerprint != "" {
+		if fingerprint != "" && fingerprint != fragmentFingerprint {
+			return nil, ErrInvalidFingerprint
+		}
+		fingerprint = fragmentFingerprint
+	}
+
+	cert, err := DecodeCertificate(u.Query().Get(embeddedCertParam))
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Invitation{
+		Host:           host,
+		Port:           port,
+		Token:          u.Query().Get(tokenParam),
+		ClientAuth:     clientAuth,
+		CertificateURL: certificateURL,
+		Fingerprint:    fingerprint,
+	}
+
+	err = inv.setCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.User != nil {
+		inv.Username = u.User.Username()
+		inv.Password, _ = u.User.Password()
+	}
+
+	return inv, nil
+}
+
+func parseURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		if strings.Contains(s, ":") && !hasPortSeparator(s) {
+			// Things like "javascript:" or "file:" without slashes
+			return nil, ErrInvalidScheme
+		}
+		s = schemeMumble + "://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid port") {
+			return nil, ErrInvalidPort
+		}
+		return nil, ErrMalformedInvitation
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != schemeMumble && scheme != schemeWahay {
+		return nil, ErrInvalidScheme
+	}
+
+	return u, nil
+}
+
+// hasPortSeparator returns true when the first colon in the given
+// string separates a host from its port, instead of being a scheme
+func hasPortSeparator(s string) bool {
+	i := strings.Index(s, ":")
+	if isOnionHost(s[:i]) {
+		return true
+	}
+	rest := s[i+1:]
+	return rest != "" && rest[0] >= '0' && rest[0] <= '9'
+}
+
+// NormalizeCertificateURL validates the location of the certificate of an
+// external Mumble server, which must be a path or an http URL of an onion service
+func NormalizeCertificateURL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Fragment != "" {
+		return "", ErrInvalidCertificateLocation
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(u.Path, "/") {
+			return "", ErrInvalidCertificateLocation
+		}
+		return u.String(), nil
+	}
+
+	if strings.ToLower(u.Scheme) != "http" {
+		return "", ErrInvalidCertificateLocation
+	}
+
+	if _, err := NormalizeOnionAddress(u.Hostname()); err != nil {
+		return "", ErrInvalidCertificateLocation
+	}
+
+	return u.String(), nil
+}
+
+func splitHostPort(hostport string) (string, int, error) {
+	if !strings.Contains(hostport, ":") {
+		return hostport, DefaultPort, nil
+	}
+
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", 0, ErrInvalidPort
+	}
+
+	port, err := parsePort(p)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return host, port, nil
+}
+
+func parsePort(p string) (int, error) {
+	if p == "" || len(p) > 5 || strings.TrimLeft(p, "0123456789") != "" {
+		return 0, ErrInvalidPort
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, ErrInvalidPort
+	}
+
+	return port, nil
+}
+
+// NormalizeOnionAddress validates that the given host is a version 3
+// onion address and returns it in lowercase with the .onion suffix
+func NormalizeOnionAddress(host string) (string, error) {
+	h := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+
+	if !strings.HasSuffix(h, onionSuffix) {
+		return "", ErrNotAnOnionAddress
+	}
+
+	label := strings.TrimSuffix(h, onionSuffix)
+
+	// Subdomains of an onion service are allowed by Tor,
+	// but they don't make any sense for a meeting
+	if strings.Contains(label, ".") {
+		return "", ErrInvalidOnionAddress
+	}
+
+	if len(label) == onionV2Length {
+		return "", ErrOnionV2NotSupported
+	}
+
+	if len(label) != onionV3Length {
+		return "", ErrInvalidOnionAddress
+	}
+
+	err := checkOnionV3(label)
+	if err != nil {
+		return "", err
+	}
+
+	return label + onionSuffix, nil
+}
+
+func isOnionHost(host string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(host, ".")), onionSuffix)
+}
+
+func normalizeClearnetHost(host string) (string, error) {
+	h := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+
+	if ip := net.ParseIP(h); ip != nil {
+		if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || isPrivateIP(ip) {
+			return "", ErrLocalAddress
+		}
+		return h, nil
+	}
+
+	if h == "localhost" || strings.HasSuffix(h, ".localhost") || strings.HasSuffix(h, ".local") {
+		return "", ErrLocalAddress
+	}
+
+	if len(h) > 253 || !strings.Contains(h, ".") {
+		return "", ErrInvalidHostname
+	}
+
+	for _, label := range strings.Split(h, ".") {
+		if !isValidHostnameLabel(label) {
+			return "", ErrInvalidHostname
+		}
+	}
+
+	return h, nil
+}
+
+func isValidHostnameLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+var privateNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, n := range privateNetworks {
+		_, network, _ := net.ParseCIDR(n)
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOnionV3 validates the version and checksum of an onion address,
+// as defined in the section 6 of the Tor rend-spec-v3
+func checkOnionV3(label string) error {
+	decoded, err := base32.StdEncoding.DecodeString(strings.ToUpper(label))
+	if err != nil || len(decoded) != 35 {
+		return ErrInvalidOnionAddress
+	}
+
+	pubkey := decoded[:32]
+	checksum := decoded[32:34]
+	version := decoded[34]
+
+	if version != onionV3Version {
+		return ErrInvalidOnionAddress
+	}
+
+	h := sha3.New256()
+	_, _ = h.Write([]byte(onionChecksumPrefix))
+	_, _ = h.Write(pubkey)
+	_, _ = h.Write([]byte{version})
+	expected := h.Sum(nil)[:2]
+
+	if expected[0] != checksum[0] || expected[1] != checksum[1] {
+		return ErrInvalidOnionChecksum
+	}
+
+	return nil
+}
+
+// IsOnion returns true if the meeting is hosted in an onion service
+func (i *Invitation) IsOnion() bool {
+	return isOnionHost(i.Host)
+}
+
+// MeetingID returns the normalized meeting ID for this invitation,
+// without credentials and without the default port
+func (i *Invitation) MeetingID() string {
+	if i.Port == DefaultPort {
+		return i.Host
+	}
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// URL returns the meeting ID together with the invitation token, the
+// certificate location, the certificate itself and its fingerprint, in
+// the same format used by the host when sharing the meeting. The client
+// authorization key goes in the fragment
+func (i *Invitation) URL() string {
+	q := url.Values{}
+	if i.Token != "" {
+		q.Set(tokenParam, i.Token)
+	}
+	if i.CertificateURL != "" {
+		q.Set(certificateParam, i.CertificateURL)
+	}
+	if i.Fingerprint != "" {
+		q.Set(fingerprintParam, i.Fingerprint)
+	}
+	if i.Certificate != nil {
+		q.Set(embeddedCertParam, EncodeCertificate(i.Certificate))
+	}
+
+	u := i.MeetingID()
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	if i.ClientAuth != "" {
+		u += "#" + clientAuthFragmentParam + "=" + i.ClientAuth
+	}
+
+	return u
+}
+
+// WahayURL returns the invitation as a wahay:// URL. The fingerprint of the
+// certificate and the client authorization key go in the fragment, which
+// is never sent to any server
+func (i *Invitation) WahayURL() string {
+	q := url.Values{}
+	if i.Token != "" {
+		q.Set(tokenParam, i.Token)
+	}
+	if i.CertificateURL != "" {
+		q.Set(certificateParam, i.CertificateURL)
+	}
+	if i.Certificate != nil {
+		q.Set(embeddedCertParam, EncodeCertificate(i.Certificate))
+	}
+
+	u := schemeWahay + "://" + i.MeetingID()
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	if f := i.fragment(); f != "" {
+		u += "#" + f
+	}
+
+	return u
+}
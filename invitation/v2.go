@@ -0,0 +1,324 @@
+package invitation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The version 2 of the invitations is a compact string containing
+// all the information needed to join a meeting:
+//
+//   wahay2:<base64url(version | fields... | checksum)>
+//
+// Every field is encoded as: type (1 byte) | length (uvarint) | value.
+// Fields with an unknown type are ignored, so new fields can be added
+// without breaking older versions of Wahay. The checksum is
+// the first 4 bytes of the SHA-256 of everything before it.
+
+// V2Prefix is the prefix of the invitations using the version 2 format
+const V2Prefix = "wahay2:"
+
+const (
+	v2Version        = 2
+	v2ChecksumLength = 4
+	v2MaxLength      = 4096
+	clientAuthLength = 32
+	onionPubkeyLen   = 32
+)
+
+const (
+	fieldOnion byte = iota + 1
+	fieldPort
+	fieldToken
+	fieldPassword
+	fieldClientAuth
+	fieldExpiry
+	fieldTitle
+	fieldFingerprint
+	fieldCertificate
+)
+
+var (
+	// ErrInvalidV2Invitation is an error to be trown when the
+	// invitation has the version 2 prefix but its content is corrupted
+	ErrInvalidV2Invitation = errors.New("the invitation is corrupted")
+
+	// ErrUnsupportedInvitationVersion is an error to be trown when the
+	// invitation was created by a newer version of Wahay
+	ErrUnsupportedInvitationVersion = errors.New("the invitation was created by a newer version of Wahay")
+
+	// ErrInvitationExpired is an error to be trown when the invitation
+	// has an expiry date and that date is in the past
+	ErrInvitationExpired = errors.New("the invitation has expired")
+
+	// ErrInvalidClientAuth is an error to be trown when the
+	// client authorization credential has an invalid format
+	ErrInvalidClientAuth = errors.New("the client authorization credential is not valid")
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func isV2(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), V2Prefix)
+}
+
+// EncodeV2 returns the invitation using the version 2 format.
+// Only invitations for onion services can be encoded
+func (i *Invitation) EncodeV2() (string, error) {
+	raw, err := i.encodeV2Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return V2Prefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (i *Invitation) encodeV2Bytes() ([]byte, error) {
+	pubkey, err := onionPubkey(i.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(v2Version)
+
+	writeField(&buf, fieldOnion, pubkey)
+
+	if i.Port != DefaultPort {
+		p := make([]byte, 2)
+		binary.BigEndian.PutUint16(p, uint16(i.Port))
+		writeField(&buf, fieldPort, p)
+	}
+
+	writeStringField(&buf, fieldToken, i.Token)
+	writeStringField(&buf, fieldPassword, i.Password)
+
+	if i.ClientAuth != "" {
+		key, err := decodeClientAuth(i.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+		writeField(&buf, fieldClientAuth, key)
+	}
+
+	if !i.Expiry.IsZero() {
+		e := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(e, uint64(i.Expiry.Unix()))
+		writeField(&buf, fieldExpiry, e[:n])
+	}
+
+	writeStringField(&buf, fieldTitle, i.Title)
+
+	if i.Fingerprint != "" {
+		f, err := hex.DecodeString(i.Fingerprint)
+		if err != nil || len(f) != fingerprintLength {
+			return nil, ErrInvalidFingerprint
+		}
+		writeField(&buf, fieldFingerprint, f)
+	}
+
+	if i.Certificate != nil {
+		writeField(&buf, fieldCertificate, i.Certificate)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	buf.Write(sum[:v2ChecksumLength])
+
+	return buf.Bytes(), nil
+}
+
+func writeField(buf *bytes.Buffer, t byte, value []byte) {
+	l := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(l, uint64(len(value)))
+
+	buf.WriteByte(t)
+	buf.Write(l[:n])
+	buf.Write(value)
+}
+
+func writeStringField(buf *bytes.Buffer, t byte, value string) {
+	if value != "" {
+		writeField(buf, t, []byte(value))
+	}
+}
+
+func parseV2(s string) (*Invitation, error) {
+	if len(s) > v2MaxLength {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s[len(V2Prefix):]))
+	if err != nil {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	return parseV2Bytes(raw)
+}
+
+func parseV2Bytes(raw []byte) (*Invitation, error) {
+	if len(raw) < 1+v2ChecksumLength {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	content := raw[:len(raw)-v2ChecksumLength]
+	sum := sha256.Sum256(content)
+	if !bytes.Equal(sum[:v2ChecksumLength], raw[len(raw)-v2ChecksumLength:]) {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	if content[0] != v2Version {
+		return nil, ErrUnsupportedInvitationVersion
+	}
+
+	inv := &Invitation{
+		Port: DefaultPort,
+	}
+
+	r := bytes.NewReader(content[1:])
+	for r.Len() > 0 {
+		t, value, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+
+		err = inv.setV2Field(t, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if inv.Host == "" {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	err := inv.setCertificate(inv.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.IsExpired() {
+		return nil, ErrInvitationExpired
+	}
+
+	return inv, nil
+}
+
+func readField(r *bytes.Reader) (byte, []byte, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, ErrInvalidV2Invitation
+	}
+
+	l, err := binary.ReadUvarint(r)
+	if err != nil || l > uint64(r.Len()) {
+		return 0, nil, ErrInvalidV2Invitation
+	}
+
+	value := make([]byte, l)
+	_, _ = r.Read(value)
+
+	return t, value, nil
+}
+
+func (i *Invitation) setV2Field(t byte, value []byte) error {
+	switch t {
+	case fieldOnion:
+		if len(value) != onionPubkeyLen {
+			return ErrInvalidV2Invitation
+		}
+		i.Host = OnionAddress(value)
+	case fieldPort:
+		if len(value) != 2 {
+			return ErrInvalidPort
+		}
+		i.Port = int(binary.BigEndian.Uint16(value))
+		if i.Port == 0 {
+			return ErrInvalidPort
+		}
+	case fieldToken:
+		i.Token = string(value)
+	case fieldPassword:
+		i.Password = string(value)
+	case fieldClientAuth:
+		if len(value) != clientAuthLength {
+			return ErrInvalidClientAuth
+		}
+		i.ClientAuth = b32.EncodeToString(value)
+	case fieldExpiry:
+		e, n := binary.Uvarint(value)
+		if n <= 0 {
+			return ErrInvalidV2Invitation
+		}
+		i.Expiry = time.Unix(int64(e), 0)
+	case fieldTitle:
+		if !utf8.Valid(value) {
+			return ErrInvalidV2Invitation
+		}
+		i.Title = string(value)
+	case fieldFingerprint:
+		if len(value) != fingerprintLength {
+			return ErrInvalidFingerprint
+		}
+		i.Fingerprint = hex.EncodeToString(value)
+	case fieldCertificate:
+		err := checkCertificate(value)
+		if err != nil {
+			return err
+		}
+		i.Certificate = value
+	}
+
+	// Unknown fields come from newer versions and can be ignored
+	return nil
+}
+
+func onionPubkey(host string) ([]byte, error) {
+	h, err := NormalizeOnionAddress(host)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _ := base32.StdEncoding.DecodeString(strings.ToUpper(strings.TrimSuffix(h, onionSuffix)))
+
+	return decoded[:onionPubkeyLen], nil
+}
+
+// OnionAddress builds the version 3 onion address for
+// the given public key, following the section 6 of the Tor rend-spec-v3
+func OnionAddress(pubkey []byte) string {
+	h := sha3.New256()
+	_, _ = h.Write([]byte(onionChecksumPrefix))
+	_, _ = h.Write(pubkey)
+	_, _ = h.Write([]byte{onionV3Version})
+	checksum := h.Sum(nil)[:2]
+
+	var address []byte
+	address = append(address, pubkey...)
+	address = append(address, checksum...)
+	address = append(address, onionV3Version)
+
+	return strings.ToLower(base32.StdEncoding.EncodeToString(address)) + onionSuffix
+}
+
+func decodeClientAuth(key string) ([]byte, error) {
+	k, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(key, "=")))
+	if err != nil || len(k) != clientAuthLength {
+		return nil, ErrInvalidClientAuth
+	}
+	return k, nil
+}
+
+// IsExpired returns true if the invitation has an expiry date in the past
+func (i *Invitation) IsExpired() bool {
+	return !i.Expiry.IsZero() && time.Now().After(i.Expiry)
+}
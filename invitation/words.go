@@ -0,0 +1,133 @@
+package invitation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+)
+
+// The words encoding is an alternative representation of the version 2
+// invitations, meant to be read aloud over a phone and typed back in the
+// join screen. Every byte of the version 2 invitation is replaced by a
+// word of the list below, so the checksum of the invitation also
+// detects the words that were misheard or mistyped.
+
+const (
+	wordsPerLine = 6
+
+	// An invitation has at least the version, the onion
+	// field and the checksum, so shorter texts are not words
+	wordsMinLength = 1 + 2 + onionPubkeyLen + v2ChecksumLength
+)
+
+var (
+	// ErrUnknownWord is an error to be trown when the invitation words
+	// contain a word that is not part of the Wahay word list
+	ErrUnknownWord = errors.New("the invitation contains a word that is not valid")
+
+	// ErrInvalidWordsChecksum is an error to be trown when all the words of
+	// the invitation are valid but some of them are wrong or missing
+	ErrInvalidWordsChecksum = errors.New("some words of the invitation are wrong or missing")
+)
+
+// The words are short, common and easy to tell apart when
+// spoken. The list must never change, since the position of
+// every word is the value of the byte it represents
+var wordList = strings.Fields(`
+	acid acorn actor adult agent alarm album alley amber angle ankle apple april apron arena armor
+	arrow atlas attic autumn award bacon badge baker bamboo banjo barrel basket beach beard beaver bench
+	berry bishop blanket blossom bottle boxer bracket bread brick bridge broom bucket buffalo bundle butter cabin
+	cactus camel candle canoe canyon carpet carrot castle cattle cellar cement chair chalk cherry chess chicken
+	circus clover cobra coconut coffee comet copper corner cotton cousin coyote crayon cricket crystal curtain cushion
+	daisy dancer desert diamond dinner doctor dolphin donkey dragon drawer dream drum eagle elbow engine express
+	fabric falcon farmer feather fence ferry fiddle finger flute forest fossil fox frog galaxy garden garlic
+	ginger giraffe glacier glove goat gorilla grape gravel guitar hammer harbor harvest helmet hermit honey horizon
+	hornet husband igloo insect island ivory jacket jaguar jelly jersey jewel jigsaw jungle kettle kidney kitten
+	koala ladder lagoon lantern laptop lemon leopard lettuce lizard lobster locket magnet mango marble meadow melon
+	mirror monkey mosaic muffin museum napkin needle nephew noodle nugget oasis ocean olive onion orange orchid
+	ostrich otter oven oyster paddle palace panda panther parrot peanut pebble pelican pepper piano pickle pigeon
+	pillow pilot pirate planet pocket potato pretzel puzzle pyramid quarry quartz rabbit raccoon radio raisin ranger
+	raven record reptile ribbon rocket saddle salmon sandal scarf scooter seal shadow shovel silver sparrow spider
+	squirrel statue stomach sugar sunset swallow sweater tablet teapot temple thunder tiger tomato tongue tractor trumpet
+	tulip tunnel turtle unicorn valley velvet violin volcano waffle walnut walrus window wizard yogurt zebra zipper
+`)
+
+var wordIndex = indexWords(wordList)
+
+func indexWords(words []string) map[string]byte {
+	index := make(map[string]byte, len(words))
+	for i, w := range words {
+		index[w] = byte(i)
+	}
+	return index
+}
+
+// EncodeWords returns the invitation as a list of words, grouped in lines.
+// The title and the certificate are not included, to keep the list as
+// short as possible. The fingerprint is kept, so the certificate can
+// still be verified when it's requested from the meeting
+func (i *Invitation) EncodeWords() (string, error) {
+	short := *i
+	short.Title = ""
+	short.Certificate = nil
+
+	raw, err := short.encodeV2Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{}
+	for len(raw) > 0 {
+		n := wordsPerLine
+		if len(raw) < n {
+			n = len(raw)
+		}
+
+		line := make([]string, n)
+		for j, b := range raw[:n] {
+			line[j] = wordList[b]
+		}
+
+		lines = append(lines, strings.Join(line, " "))
+		raw = raw[n:]
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// splitWords splits the given text in words, accepting the separators
+// people usually type when copying a list of words by hand
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '-' || r == ',' || r == '.'
+	})
+}
+
+func isWords(s string) bool {
+	return len(splitWords(s)) >= wordsMinLength
+}
+
+func parseWords(s string) (*Invitation, error) {
+	words := splitWords(s)
+	if len(words) > v2MaxLength {
+		return nil, ErrInvalidV2Invitation
+	}
+
+	raw := make([]byte, len(words))
+	for i, w := range words {
+		b, ok := wordIndex[w]
+		if !ok {
+			return nil, ErrUnknownWord
+		}
+		raw[i] = b
+	}
+
+	content := raw[:len(raw)-v2ChecksumLength]
+	sum := sha256.Sum256(content)
+	if !bytes.Equal(sum[:v2ChecksumLength], raw[len(raw)-v2ChecksumLength:]) {
+		return nil, ErrInvalidWordsChecksum
+	}
+
+	return parseV2Bytes(raw)
+}
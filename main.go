@@ -2,11 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/coyim/gotk3adapter/gdka"
 	"github.com/coyim/gotk3adapter/gliba"
 	"github.com/coyim/gotk3adapter/gtka"
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 	"github.com/digitalautonomy/wahay/gui"
 	log "github.com/sirupsen/logrus"
 )
@@ -25,29 +27,92 @@ var BuildTimestamp = "UNKNOWN"
 
 func main() {
 	config.ProcessCommandLineArguments()
+	gui.SetLanguage(*config.Language)
+
+	config.CurrentBuild = config.BuildInfo{
+		Commit:      BuildCommit,
+		ShortCommit: BuildShortCommit,
+		Tag:         BuildTag,
+		Timestamp:   BuildTimestamp,
+	}
 
 	if *config.Version {
 		fmt.Printf("Wahay (commit: %s (%s) tag: %s built: %s)\n", BuildShortCommit, BuildCommit, BuildTag, BuildTimestamp)
 		return
 	}
 
+	if *config.Panic {
+		err := config.SignalPanicToRunningSession()
+		if err != nil {
+			exitWithError("Wahay panic", err)
+		}
+		return
+	}
+
 	initLogging()
 
+	if *config.Debug {
+		startProfiling()
+	}
+
+	if *config.SelfTest {
+		runSelfTest()
+		return
+	}
+
+	if *config.Join != "" {
+		runHeadlessJoin()
+		return
+	}
+
+	if *config.Host || *config.Headless {
+		runHeadlessHost()
+		return
+	}
+
 	runClient()
 }
 
 func initLogging() {
-	log.SetLevel(log.InfoLevel)
+	o := config.LoggingOptions{
+		Level:        log.InfoLevel,
+		JSON:         *config.LogJSON,
+		ReportCaller: *config.DebugFunctionCalls,
+		Unredacted:   *config.LogUnredacted,
+		BufferSize:   *config.LogBufferSize,
+	}
+
 	if *config.Debug {
-		log.SetLevel(log.DebugLevel)
+		o.Level = log.DebugLevel
 	}
 	if *config.Trace {
-		log.SetLevel(log.TraceLevel)
+		o.Level = log.TraceLevel
+	}
+
+	levels, err := config.ParseLogLevels(*config.LogLevels)
+	if err != nil {
+		exitWithError("Wahay", err)
 	}
-	log.SetReportCaller(*config.DebugFunctionCalls)
+	o.Levels = levels
+
+	config.InitLogging(o)
+}
+
+// exitWithError tells the user what went wrong and exits with the
+// code of the error, so scripts can tell one problem from another
+func exitWithError(prefix string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", prefix, err)
+	if hint := failure.HintOf(err); hint != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", gui.Sprintf(hint))
+	}
+	os.Exit(failure.ExitCode(err))
 }
 
 func runClient() {
+	if gui.ActivateRunningInstance() {
+		return
+	}
+
 	g := gui.CreateGraphics(gtka.Real, gliba.Real, gdka.Real)
 	gui.NewGTK(g).Loop()
 }
@@ -6,6 +6,8 @@ CURRENT_VERSION=$(echo 0~$(date "+%Y%m%d%H%M%S"))
 
 
 mkdir -p ubuntu/ubuntu/usr/bin
+mkdir -p ubuntu/ubuntu/etc/apparmor.d
+cp apparmor/usr.bin.wahay apparmor/wahay-tor apparmor/wahay-mumble ubuntu/ubuntu/etc/apparmor.d
 
 if [ $1 == "local"  ]
 then
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/digitalautonomy/wahay/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// startProfiling exposes the pprof endpoints, and the timings of the
+// host and join steps, so performance problems can be diagnosed on the
+// computer of the user. The server only listens on localhost
+func startProfiling() {
+	l, err := net.Listen("tcp", net.JoinHostPort(config.DefaultHost, strconv.Itoa(*config.DebugProfilingPort)))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "profiling",
+		}).Errorf("the profiling endpoints can't be started: %s", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/timings", serveTimings)
+
+	s := &http.Server{
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+		// CPU profiles and traces take as long as the client asks for
+		WriteTimeout: 5 * time.Minute,
+	}
+
+	log.Infof("Profiling endpoints available at http://%s/debug/pprof/", l.Addr())
+
+	go func() {
+		err := s.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			log.WithFields(log.Fields{
+				"context": "profiling",
+			}).Errorf("the profiling server failed: %s", err)
+		}
+	}()
+}
+
+func serveTimings(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(config.Timings())
+}
@@ -0,0 +1,365 @@
+package qr
+
+import (
+	"image"
+	"math"
+	"math/bits"
+)
+
+// maxFormatErrors is the number of wrong bits that can be corrected
+// in the format information
+const maxFormatErrors = 3
+
+// Decode reads the content of the QR code in the image. The code must
+// be straight, not rotated or distorted, and surrounded by light pixels,
+// like in a screenshot or in an image saved by Wahay
+func Decode(img image.Image) ([]byte, error) {
+	g := newGrid(img)
+	if g == nil {
+		return nil, ErrNotFound
+	}
+
+	level, mask, ok := g.readFormat()
+	if !ok {
+		return nil, ErrDamaged
+	}
+
+	c := newCode(g.version, level)
+	c.drawFunctionPatterns()
+
+	raw := make([]byte, numRawDataModules(g.version)/8)
+	i := 0
+	c.eachDataModule(func(x, y int) {
+		if i < len(raw)*8 && g.dark(x, y) != masked(mask, x, y) {
+			raw[i>>3] |= 1 << uint(7-i&7)
+		}
+		i++
+	})
+
+	data, err := removeErrorCorrection(raw, g.version, level)
+	if err != nil {
+		return nil, err
+	}
+
+	return readSegments(data, g.version)
+}
+
+// grid finds the modules of the code in the pixels of the image
+type grid struct {
+	pixels  [][]bool
+	left    int
+	top     int
+	module  float64
+	moduleY float64
+	version int
+	size    int
+}
+
+func newGrid(img image.Image) *grid {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	lum := make([][]int, h)
+	minLum, maxLum := math.MaxInt32, 0
+	for y := 0; y < h; y++ {
+		lum[y] = make([]int, w)
+		for x := 0; x < w; x++ {
+			r, gr, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// Transparent pixels are taken as light
+			l := int((299*r+587*gr+114*bl)/1000) + int(0xFFFF-a)
+			lum[y][x] = l
+			if l < minLum {
+				minLum = l
+			}
+			if l > maxLum {
+				maxLum = l
+			}
+		}
+	}
+
+	if maxLum-minLum < 0x2000 {
+		return nil
+	}
+	threshold := (minLum + maxLum) / 2
+
+	g := &grid{pixels: make([][]bool, h)}
+	left, top, right, bottom := w, h, -1, -1
+	for y := 0; y < h; y++ {
+		g.pixels[y] = make([]bool, w)
+		for x := 0; x < w; x++ {
+			dark := lum[y][x] < threshold
+			g.pixels[y][x] = dark
+			if dark {
+				left, right = minInt(left, x), maxInt(right, x)
+				top, bottom = minInt(top, y), maxInt(bottom, y)
+			}
+		}
+	}
+
+	if right < 0 {
+		return nil
+	}
+
+	// The top row of the finder pattern in the top left corner
+	// is seven dark modules long
+	run := g.darkRun(left, top)
+	run = g.darkRun(left, top+run/14)
+	if run < 7 {
+		return nil
+	}
+
+	width := float64(right - left + 1)
+	height := float64(bottom - top + 1)
+
+	size := int(math.Floor(width/(float64(run)/7) + 0.5))
+	version := int(math.Floor(float64(size-17)/4 + 0.5))
+	if version < minVersion || version > maxVersion {
+		return nil
+	}
+	size = version*4 + 17
+
+	g.left, g.top = left, top
+	g.version, g.size = version, size
+	g.module = width / float64(size)
+	g.moduleY = height / float64(size)
+
+	if math.Abs(g.module-g.moduleY) > g.module/4 {
+		return nil
+	}
+
+	return g
+}
+
+func (g *grid) darkRun(x, y int) int {
+	if y >= len(g.pixels) {
+		return 0
+	}
+
+	row := g.pixels[y]
+	run := 0
+	for x+run < len(row) && row[x+run] {
+		run++
+	}
+	return run
+}
+
+// dark returns if the module at the given column and row is dark,
+// looking at the pixel in its center
+func (g *grid) dark(x, y int) bool {
+	px := g.left + int((float64(x)+0.5)*g.module)
+	py := g.top + int((float64(y)+0.5)*g.moduleY)
+	if py < 0 || py >= len(g.pixels) || px < 0 || px >= len(g.pixels[py]) {
+		return false
+	}
+	return g.pixels[py][px]
+}
+
+// readFormat reads both copies of the format information and returns
+// the level and mask closest to any of them
+func (g *grid) readFormat() (Level, int, bool) {
+	first, second := 0, 0
+
+	for i := 0; i <= 5; i++ {
+		first |= bitValue(g.dark(8, i)) << uint(i)
+	}
+	first |= bitValue(g.dark(8, 7)) << 6
+	first |= bitValue(g.dark(8, 8)) << 7
+	first |= bitValue(g.dark(7, 8)) << 8
+	for i := 9; i < 15; i++ {
+		first |= bitValue(g.dark(14-i, 8)) << uint(i)
+	}
+
+	for i := 0; i < 8; i++ {
+		second |= bitValue(g.dark(g.size-1-i, 8)) << uint(i)
+	}
+	for i := 8; i < 15; i++ {
+		second |= bitValue(g.dark(8, g.size-15+i)) << uint(i)
+	}
+
+	bestLevel, bestMask, bestDistance := Low, 0, maxFormatErrors+1
+	for level := Low; level <= High; level++ {
+		for mask := 0; mask < 8; mask++ {
+			f := formatInformation(level, mask)
+			d := minInt(bits.OnesCount(uint(f^first)), bits.OnesCount(uint(f^second)))
+			if d < bestDistance {
+				bestLevel, bestMask, bestDistance = level, mask, d
+			}
+		}
+	}
+
+	return bestLevel, bestMask, bestDistance <= maxFormatErrors
+}
+
+// removeErrorCorrection separates the interleaved blocks and returns
+// their data. The blocks are only checked, a damaged block is not corrected
+func removeErrorCorrection(raw []byte, version int, level Level) ([]byte, error) {
+	numBlocks, numShortBlocks, shortBlockLen, blockEccLen := blockLayout(version, level)
+	shortDataLen := shortBlockLen - blockEccLen
+
+	blocks := make([][]byte, numBlocks)
+	for j := range blocks {
+		blocks[j] = make([]byte, shortBlockLen+1)
+	}
+
+	k := 0
+	for i := 0; i <= shortBlockLen; i++ {
+		for j := range blocks {
+			if i != shortDataLen || j >= numShortBlocks {
+				blocks[j][i] = raw[k]
+				k++
+			}
+		}
+	}
+
+	divisor := reedSolomonDivisor(blockEccLen)
+
+	var result []byte
+	for j, block := range blocks {
+		dataLen := shortDataLen
+		if j >= numShortBlocks {
+			dataLen++
+		}
+
+		data := block[:dataLen]
+		ecc := block[len(block)-blockEccLen:]
+		if string(reedSolomonRemainder(data, divisor)) != string(ecc) {
+			return nil, ErrDamaged
+		}
+
+		result = append(result, data...)
+	}
+
+	return result, nil
+}
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) left() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		b := (r.data[r.pos>>3] >> uint(7-r.pos&7)) & 1
+		v = v<<1 | int(b)
+		r.pos++
+	}
+	return v
+}
+
+// readSegments returns the content of the segments of the code, until
+// the terminator or the end of the data
+func readSegments(data []byte, version int) ([]byte, error) {
+	r := &bitReader{data: data}
+
+	var result []byte
+	for r.left() >= 4 {
+		mode := r.read(4)
+		if mode == 0 {
+			break
+		}
+
+		if mode == modeECI {
+			// The character set is not used, the content is returned as it is
+			if r.left() < 8 {
+				return nil, ErrDamaged
+			}
+			first := r.read(8)
+			extra := 0
+			if first&0x80 != 0 {
+				extra = 1
+				if first&0x40 != 0 {
+					extra = 2
+				}
+			}
+			if r.left() < extra*8 {
+				return nil, ErrDamaged
+			}
+			r.read(extra * 8)
+			continue
+		}
+
+		if mode != modeNumeric && mode != modeAlphanumeric && mode != modeByte {
+			return nil, ErrDamaged
+		}
+
+		countBits := charCountBits(mode, version)
+		if r.left() < countBits {
+			return nil, ErrDamaged
+		}
+		count := r.read(countBits)
+
+		var err error
+		result, err = readSegment(r, mode, count, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func readSegment(r *bitReader, mode, count int, result []byte) ([]byte, error) {
+	switch mode {
+	case modeByte:
+		if r.left() < count*8 {
+			return nil, ErrDamaged
+		}
+		for i := 0; i < count; i++ {
+			result = append(result, byte(r.read(8)))
+		}
+
+	case modeAlphanumeric:
+		for ; count >= 2; count -= 2 {
+			if r.left() < 11 {
+				return nil, ErrDamaged
+			}
+			v := r.read(11)
+			if v/45 >= len(alphanumericChars) {
+				return nil, ErrDamaged
+			}
+			result = append(result, alphanumericChars[v/45], alphanumericChars[v%45])
+		}
+		if count == 1 {
+			if r.left() < 6 {
+				return nil, ErrDamaged
+			}
+			v := r.read(6)
+			if v >= len(alphanumericChars) {
+				return nil, ErrDamaged
+			}
+			result = append(result, alphanumericChars[v])
+		}
+
+	case modeNumeric:
+		for ; count > 0; count -= 3 {
+			digits := minInt(count, 3)
+			n := [4]int{0, 4, 7, 10}[digits]
+			if r.left() < n {
+				return nil, ErrDamaged
+			}
+			v := r.read(n)
+			for d := digits - 1; d >= 0; d-- {
+				result = append(result, byte('0'+v/int(math.Pow10(d))%10))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func bitValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
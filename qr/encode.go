@@ -0,0 +1,254 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is the number of light modules around the code that
+// readers need to find it
+const quietZone = 4
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, bit(value, i))
+	}
+}
+
+func charCountBits(mode, version int) int {
+	group := 0
+	if version >= 27 {
+		group = 2
+	} else if version >= 10 {
+		group = 1
+	}
+
+	switch mode {
+	case modeNumeric:
+		return [3]int{10, 12, 14}[group]
+	case modeAlphanumeric:
+		return [3]int{9, 11, 13}[group]
+	}
+	return [3]int{8, 16, 16}[group]
+}
+
+// Encode returns the QR code of the content, using the smallest version
+// where it fits with the given error correction level
+func Encode(content []byte, level Level) (*Code, error) {
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		used := 4 + charCountBits(modeByte, version) + len(content)*8
+		if used <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	capacity := numDataCodewords(version, level) * 8
+
+	var bb bitBuffer
+	bb.append(modeByte, 4)
+	bb.append(len(content), charCountBits(modeByte, version))
+	for _, b := range content {
+		bb.append(int(b), 8)
+	}
+
+	// The terminator, the padding to a byte and the pad codewords
+	bb.append(0, minInt(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	data := make([]byte, len(bb)/8)
+	for i, b := range bb {
+		if b {
+			data[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addErrorCorrection(data, version, level))
+	c.chooseMask()
+
+	return c, nil
+}
+
+// addErrorCorrection splits the data in blocks, adds the error
+// correction to every block and interleaves the codewords of all them
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks, numShortBlocks, shortBlockLen, blockEccLen := blockLayout(version, level)
+	divisor := reedSolomonDivisor(blockEccLen)
+
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		length := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			length++
+		}
+
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+length]...)
+		k += length
+
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Short blocks get a placeholder, so all have the same length
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, numRawDataModules(version)/8)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	c.eachDataModule(func(x, y int) {
+		if i < len(data)*8 {
+			c.set(x, y, (data[i>>3]>>uint(7-i&7))&1 != 0)
+		}
+		i++
+	})
+}
+
+// chooseMask applies the mask that makes the code easier to read,
+// the one with the lowest penalty
+func (c *Code) chooseMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// penalty scores the features of the code that make it hard to read:
+// long runs of modules of the same color, blocks of the same color,
+// patterns that look like the finder and an unbalanced amount of dark modules
+func (c *Code) penalty() int {
+	result := 0
+	dark := 0
+
+	line := func(get func(i int) bool) {
+		run := 0
+		var last bool
+		for i := 0; i < c.Size; i++ {
+			v := get(i)
+			if i > 0 && v == last {
+				run++
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			} else {
+				run = 1
+				last = v
+			}
+		}
+
+		// Finder-like patterns, with four light modules at one of their sides
+		for i := -4; i < c.Size; i++ {
+			at := func(j int) bool {
+				if i+j < 0 || i+j >= c.Size {
+					return false
+				}
+				return get(i + j)
+			}
+			core := at(4) && !at(5) && at(6) && at(7) && at(8) && !at(9) && at(10)
+			if core && !at(0) && !at(1) && !at(2) && !at(3) {
+				result += 40
+			}
+			if at(0) && !at(1) && at(2) && at(3) && at(4) && !at(5) && at(6) &&
+				!at(7) && !at(8) && !at(9) && !at(10) {
+				result += 40
+			}
+		}
+	}
+
+	for y := 0; y < c.Size; y++ {
+		line(func(x int) bool { return c.Dark(x, y) })
+	}
+	for x := 0; x < c.Size; x++ {
+		line(func(y int) bool { return c.Dark(x, y) })
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			v := c.Dark(x, y)
+			if v {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size &&
+				v == c.Dark(x+1, y) && v == c.Dark(x, y+1) && v == c.Dark(x+1, y+1) {
+				result += 3
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+
+	return result
+}
+
+// Image draws the code with every module as a square of scale pixels,
+// surrounded by the quiet zone readers need
+func (c *Code) Image(scale int) *image.Gray {
+	side := (c.Size + quietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			v := color.Gray{Y: 0xFF}
+			if c.Dark(px/scale-quietZone, py/scale-quietZone) {
+				v = color.Gray{Y: 0x00}
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+
+	return img
+}
+
+// PNG returns the image of the code in PNG format
+func (c *Code) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, c.Image(scale))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type QRSuite struct{}
+
+var _ = Suite(&QRSuite{})
+
+func (s *QRSuite) Test_numDataCodewords_matchesTheByteCapacityOfTheStandard(c *C) {
+	byteCapacity := func(version int, level Level) int {
+		return (numDataCodewords(version, level)*8 - 4 - charCountBits(modeByte, version)) / 8
+	}
+
+	c.Assert(byteCapacity(1, Low), Equals, 17)
+	c.Assert(byteCapacity(1, Medium), Equals, 14)
+	c.Assert(byteCapacity(1, Quartile), Equals, 11)
+	c.Assert(byteCapacity(1, High), Equals, 7)
+	c.Assert(byteCapacity(10, Medium), Equals, 213)
+	c.Assert(byteCapacity(40, Low), Equals, 2953)
+	c.Assert(byteCapacity(40, Medium), Equals, 2331)
+	c.Assert(byteCapacity(40, Quartile), Equals, 1663)
+	c.Assert(byteCapacity(40, High), Equals, 1273)
+}
+
+func (s *QRSuite) Test_Encode_usesTheSmallestVersion(c *C) {
+	code, err := Encode([]byte("wahay"), Medium)
+	c.Assert(err, IsNil)
+	c.Assert(code.Size, Equals, 21)
+
+	code, err = Encode(bytes.Repeat([]byte("a"), 15), Medium)
+	c.Assert(err, IsNil)
+	c.Assert(code.Size, Equals, 25)
+}
+
+func (s *QRSuite) Test_Encode_failsWhenTheContentDoesntFit(c *C) {
+	_, err := Encode(bytes.Repeat([]byte("a"), 2332), Medium)
+	c.Assert(err, Equals, ErrTooLong)
+}
+
+func (s *QRSuite) Test_Encode_drawsTheFinderPatterns(c *C) {
+	code, err := Encode([]byte("wahay"), Medium)
+	c.Assert(err, IsNil)
+
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for i := 0; i < 7; i++ {
+			c.Assert(code.Dark(corner[0]+i, corner[1]), Equals, true)
+			c.Assert(code.Dark(corner[0], corner[1]+i), Equals, true)
+		}
+		c.Assert(code.Dark(corner[0]+1, corner[1]+1), Equals, false)
+		c.Assert(code.Dark(corner[0]+3, corner[1]+3), Equals, true)
+	}
+}
+
+func (s *QRSuite) Test_Decode_readsTheEncodedContent(c *C) {
+	contents := []string{
+		"wahay",
+		"wahay://qvdjpoqcg572ibylv673qr76iwashlazh6spm47ly37w65iwwmkbmtid.onion:64738?t=abc#fp=SHA256:0123",
+		strings.Repeat("0123456789abcdef", 40),
+	}
+
+	for _, content := range contents {
+		for level := Low; level <= High; level++ {
+			for _, scale := range []int{1, 3, 8} {
+				code, err := Encode([]byte(content), level)
+				c.Assert(err, IsNil)
+
+				decoded, err := Decode(code.Image(scale))
+				c.Assert(err, IsNil)
+				c.Assert(string(decoded), Equals, content)
+			}
+		}
+	}
+}
+
+func (s *QRSuite) Test_Decode_readsThePNGImage(c *C) {
+	code, err := Encode([]byte("an invitation"), Medium)
+	c.Assert(err, IsNil)
+
+	content, err := code.PNG(4)
+	c.Assert(err, IsNil)
+
+	img, err := png.Decode(bytes.NewReader(content))
+	c.Assert(err, IsNil)
+
+	decoded, err := Decode(img)
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, "an invitation")
+}
+
+func (s *QRSuite) Test_Decode_failsWithoutACode(c *C) {
+	img := image.NewGray(image.Rect(0, 0, 50, 50))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	_, err := Decode(img)
+	c.Assert(err, Equals, ErrNotFound)
+}
+
+func (s *QRSuite) Test_Decode_detectsADamagedCode(c *C) {
+	code, err := Encode([]byte("an invitation"), Medium)
+	c.Assert(err, IsNil)
+
+	img := code.Image(2)
+
+	// A module in the data area, next to the bottom right corner
+	x, y := (code.Size-1+quietZone)*2, (code.Size-1+quietZone)*2
+	v := img.GrayAt(x, y).Y ^ 0xFF
+	img.SetGray(x, y, color.Gray{Y: v})
+	img.SetGray(x+1, y, color.Gray{Y: v})
+	img.SetGray(x, y+1, color.Gray{Y: v})
+	img.SetGray(x+1, y+1, color.Gray{Y: v})
+
+	_, err = Decode(img)
+	c.Assert(err, Equals, ErrDamaged)
+}
@@ -0,0 +1,107 @@
+// Package scheduler tells when the meetings planned in advance have to
+// be hosted. A meeting is due some time before it begins, so it can be
+// reached on time, and it's still due if Wahay starts late, as long as
+// the meeting was not expected to be finished already
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Meeting is a meeting planned in advance
+type Meeting struct {
+	ID string
+
+	// Due is when the meeting has to be hosted,
+	// and End when it's expected to finish
+	Due time.Time
+	End time.Time
+}
+
+// Scheduler calls a function when every meeting is due
+type Scheduler struct {
+	sync.Mutex
+	onDue   func(id string)
+	timers  map[string]*time.Timer
+	stopped bool
+}
+
+// New returns a scheduler that calls onDue with the ID of every
+// meeting when it's due, in a goroutine of its own
+func New(onDue func(id string)) *Scheduler {
+	return &Scheduler{
+		onDue:  onDue,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// Schedule waits for the meeting, replacing the one with the same ID.
+// It returns false when the meeting is over, so it will never be due
+func (s *Scheduler) Schedule(m Meeting) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cancel(m.ID)
+
+	now := time.Now()
+	if s.stopped || !m.End.After(now) {
+		return false
+	}
+
+	wait := m.Due.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(wait, func() {
+		s.Lock()
+		current := s.timers[m.ID] == t
+		if current {
+			delete(s.timers, m.ID)
+		}
+		s.Unlock()
+
+		if current {
+			s.onDue(m.ID)
+		}
+	})
+	s.timers[m.ID] = t
+
+	return true
+}
+
+// Cancel stops waiting for the meeting with the given ID
+func (s *Scheduler) Cancel(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cancel(id)
+}
+
+func (s *Scheduler) cancel(id string) {
+	if t, ok := s.timers[id]; ok {
+		t.Stop()
+		delete(s.timers, id)
+	}
+}
+
+// Pending returns how many meetings are not due yet
+func (s *Scheduler) Pending() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.timers)
+}
+
+// Stop cancels all the meetings, and no more can be scheduled
+func (s *Scheduler) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	for id := range s.timers {
+		s.cancel(id)
+	}
+	s.stopped = true
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SchedulerSuite struct{}
+
+var _ = Suite(&SchedulerSuite{})
+
+func dueMeetings() (*Scheduler, <-chan string) {
+	due := make(chan string, 10)
+	return New(func(id string) { due <- id }), due
+}
+
+func receive(due <-chan string) string {
+	select {
+	case id := <-due:
+		return id
+	case <-time.After(2 * time.Second):
+		return ""
+	}
+}
+
+func (s *SchedulerSuite) Test_Schedule_tellsWhenTheMeetingIsDue(c *C) {
+	sch, due := dueMeetings()
+	now := time.Now()
+
+	ok := sch.Schedule(Meeting{ID: "weekly", Due: now.Add(50 * time.Millisecond), End: now.Add(time.Hour)})
+
+	c.Assert(ok, Equals, true)
+	c.Assert(sch.Pending(), Equals, 1)
+	c.Assert(receive(due), Equals, "weekly")
+	c.Assert(sch.Pending(), Equals, 0)
+}
+
+func (s *SchedulerSuite) Test_Schedule_makesTheMeetingsThatAlreadyBegunDueNow(c *C) {
+	sch, due := dueMeetings()
+	now := time.Now()
+
+	ok := sch.Schedule(Meeting{ID: "late", Due: now.Add(-time.Hour), End: now.Add(time.Hour)})
+
+	c.Assert(ok, Equals, true)
+	c.Assert(receive(due), Equals, "late")
+}
+
+func (s *SchedulerSuite) Test_Schedule_ignoresTheMeetingsThatAreOver(c *C) {
+	sch, _ := dueMeetings()
+	now := time.Now()
+
+	ok := sch.Schedule(Meeting{ID: "old", Due: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)})
+
+	c.Assert(ok, Equals, false)
+	c.Assert(sch.Pending(), Equals, 0)
+}
+
+func (s *SchedulerSuite) Test_Schedule_replacesTheMeetingWithTheSameID(c *C) {
+	sch, due := dueMeetings()
+	now := time.Now()
+
+	sch.Schedule(Meeting{ID: "weekly", Due: now.Add(time.Hour), End: now.Add(2 * time.Hour)})
+	sch.Schedule(Meeting{ID: "weekly", Due: now, End: now.Add(time.Hour)})
+
+	c.Assert(receive(due), Equals, "weekly")
+	c.Assert(sch.Pending(), Equals, 0)
+}
+
+func (s *SchedulerSuite) Test_Cancel_stopsWaitingForTheMeeting(c *C) {
+	sch, due := dueMeetings()
+	now := time.Now()
+
+	sch.Schedule(Meeting{ID: "weekly", Due: now.Add(50 * time.Millisecond), End: now.Add(time.Hour)})
+	sch.Cancel("weekly")
+
+	select {
+	case id := <-due:
+		c.Errorf("the meeting %s was due after being canceled", id)
+	case <-time.After(150 * time.Millisecond):
+	}
+	c.Assert(sch.Pending(), Equals, 0)
+}
+
+func (s *SchedulerSuite) Test_Stop_doesNotLetScheduleMoreMeetings(c *C) {
+	sch, _ := dueMeetings()
+	now := time.Now()
+
+	sch.Schedule(Meeting{ID: "weekly", Due: now.Add(time.Hour), End: now.Add(2 * time.Hour)})
+	sch.Stop()
+
+	c.Assert(sch.Pending(), Equals, 0)
+	c.Assert(sch.Schedule(Meeting{ID: "daily", Due: now.Add(time.Hour), End: now.Add(2 * time.Hour)}), Equals, false)
+}
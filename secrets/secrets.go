@@ -0,0 +1,118 @@
+// Package secrets keeps the secrets of Wahay outside of its files, in
+// the keyring of the desktop. The keyring is reached through the
+// freedesktop Secret Service, which GNOME Keyring and KWallet implement,
+// so the secrets are protected by the session of the user
+package secrets
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+var (
+	errNotAvailable = failure.New("secrets.not-available", failure.CategoryConfiguration, "the keyring of the system is not available", "install secret-tool and a keyring like GNOME Keyring or KWallet")
+	errNotSaved     = failure.New("secrets.not-saved", failure.CategoryConfiguration, "the secret can't be saved in the keyring of the system", "check that the keyring is unlocked")
+)
+
+// Store keeps secrets by name
+type Store interface {
+	// Lookup returns the secret with the given name, and
+	// false if there is no such secret
+	Lookup(name string) (string, bool)
+
+	// Save keeps the secret, replacing the one with the same name
+	Save(name, secret string) error
+
+	// Forget removes the secret with the given name
+	Forget(name string) error
+}
+
+const applicationAttribute = "wahay"
+
+// secretService is the Store of the keyring of the desktop. It uses
+// secret-tool, which gets the secrets through its standard input and
+// output, so they never show up in the list of processes
+type secretService struct {
+	tool string
+}
+
+// SecretService returns the Store of the keyring of the desktop
+func SecretService() (Store, error) {
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, errNotAvailable
+	}
+	return &secretService{tool: tool}, nil
+}
+
+func attributes(name string) []string {
+	return []string{"application", applicationAttribute, "secret", name}
+}
+
+func (s *secretService) Lookup(name string) (string, bool) {
+	/* #nosec G204 */
+	out, err := exec.Command(s.tool, append([]string{"lookup"}, attributes(name)...)...).Output()
+	if err != nil {
+		return "", false
+	}
+
+	secret := strings.TrimSuffix(string(out), "\n")
+	return secret, secret != ""
+}
+
+func (s *secretService) Save(name, secret string) error {
+	args := append([]string{"store", "--label=Wahay: " + name}, attributes(name)...)
+
+	/* #nosec G204 */
+	cmd := exec.Command(s.tool, args...)
+	cmd.Stdin = bytes.NewBufferString(secret)
+
+	if cmd.Run() != nil {
+		return errNotSaved
+	}
+	return nil
+}
+
+func (s *secretService) Forget(name string) error {
+	/* #nosec G204 */
+	return exec.Command(s.tool, append([]string{"clear"}, attributes(name)...)...).Run()
+}
+
+type memory struct {
+	sync.Mutex
+	secrets map[string]string
+}
+
+// Memory returns a Store that forgets the secrets when
+// Wahay finishes, for when there is no keyring
+func Memory() Store {
+	return &memory{secrets: make(map[string]string)}
+}
+
+func (m *memory) Lookup(name string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	secret, ok := m.secrets[name]
+	return secret, ok
+}
+
+func (m *memory) Save(name, secret string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.secrets[name] = secret
+	return nil
+}
+
+func (m *memory) Forget(name string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.secrets, name)
+	return nil
+}
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SecretsSuite struct{}
+
+var _ = Suite(&SecretsSuite{})
+
+// fakeSecretTool behaves like secret-tool, keeping every
+// secret in a file named after its attributes
+const fakeSecretTool = `#!/bin/sh
+dir=$(dirname "$0")
+cmd=$1
+shift
+[ "$cmd" = "store" ] && shift
+file="$dir/$(echo "$@" | tr ' ' '_')"
+case "$cmd" in
+store) cat > "$file" ;;
+lookup) [ -f "$file" ] && cat "$file" || exit 1 ;;
+clear) rm -f "$file" ;;
+esac
+`
+
+func withFakeSecretTool(c *C) Store {
+	dir := c.MkDir()
+	tool := filepath.Join(dir, "secret-tool")
+	c.Assert(ioutil.WriteFile(tool, []byte(fakeSecretTool), 0700), IsNil)
+	return &secretService{tool: tool}
+}
+
+func (s *SecretsSuite) Test_secretService_keepsTheSecrets(c *C) {
+	store := withFakeSecretTool(c)
+
+	c.Assert(store.Save("master-password", "a secret"), IsNil)
+
+	secret, ok := store.Lookup("master-password")
+	c.Assert(ok, Equals, true)
+	c.Assert(secret, Equals, "a secret")
+
+	_, ok = store.Lookup("something else")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *SecretsSuite) Test_secretService_forgetsTheSecrets(c *C) {
+	store := withFakeSecretTool(c)
+
+	c.Assert(store.Save("master-password", "a secret"), IsNil)
+	c.Assert(store.Forget("master-password"), IsNil)
+
+	_, ok := store.Lookup("master-password")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *SecretsSuite) Test_secretService_failsWhenTheKeyringFails(c *C) {
+	store := &secretService{tool: filepath.Join(c.MkDir(), "missing")}
+
+	c.Assert(store.Save("master-password", "a secret"), Equals, errNotSaved)
+	_, ok := store.Lookup("master-password")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *SecretsSuite) Test_SecretService_isNotAvailableWithoutSecretTool(c *C) {
+	path := os.Getenv("PATH")
+	defer func() {
+		_ = os.Setenv("PATH", path)
+	}()
+	_ = os.Setenv("PATH", c.MkDir())
+
+	_, err := SecretService()
+	c.Assert(err, Equals, errNotAvailable)
+}
+
+func (s *SecretsSuite) Test_Memory_keepsTheSecretsUntilForgotten(c *C) {
+	store := Memory()
+
+	c.Assert(store.Save("onion key", "key"), IsNil)
+	secret, ok := store.Lookup("onion key")
+	c.Assert(ok, Equals, true)
+	c.Assert(secret, Equals, "key")
+
+	c.Assert(store.Forget("onion key"), IsNil)
+	_, ok = store.Lookup("onion key")
+	c.Assert(ok, Equals, false)
+}
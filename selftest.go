@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/digitalautonomy/wahay/client"
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/gui"
+	"github.com/digitalautonomy/wahay/selftest"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+// runSelfTest checks, step by step, that Wahay can work in this
+// computer, printing the result of every step. It exits with an
+// error code when one of them fails
+func runSelfTest() {
+	conf := headlessConfig()
+	if *config.MumbleBinary != "" {
+		conf.SetMumbleBinaryPath(*config.MumbleBinary)
+	}
+
+	var t tor.Instance
+	defer func() {
+		if t != nil {
+			t.Destroy()
+		}
+	}()
+
+	e := selftest.Environment{
+		Tor: func() (tor.Instance, error) {
+			var err error
+			t, err = newHeadlessTor(conf)
+			return t, err
+		},
+		Mumble: func() error {
+			c := client.InitSystem(conf, nil)
+			defer c.Destroy()
+			if !c.IsValid() {
+				return c.LastError()
+			}
+			return nil
+		},
+		Certificate: func() error {
+			_, err := client.NewIdentity(conf.GetCertificateKey())
+			return err
+		},
+	}
+
+	fmt.Println(gui.Sprintf("Checking that Wahay can work in this computer..."))
+
+	var failed error
+	ok := selftest.RunSteps(selftest.Steps(e), func(r selftest.StepResult) {
+		printSelfTestResult(r)
+		if !r.Passed() && !r.Skipped && failed == nil {
+			failed = r.Err
+		}
+	})
+
+	if !ok {
+		if t != nil {
+			t.Destroy()
+			t = nil
+		}
+		os.Exit(failure.ExitCode(failed))
+	}
+
+	fmt.Println(gui.Sprintf("Everything works"))
+}
+
+func printSelfTestResult(r selftest.StepResult) {
+	switch {
+	case r.Skipped:
+		fmt.Printf("SKIP  %s\n", gui.Sprintf(r.Step.Description))
+	case r.Passed():
+		fmt.Printf("PASS  %s (%s)\n", gui.Sprintf(r.Step.Description), r.Duration.Round(time.Millisecond))
+	default:
+		fmt.Printf("FAIL  %s: %s\n", gui.Sprintf(r.Step.Description), r.Err)
+		if hint := failure.HintOf(r.Err); hint != "" {
+			fmt.Printf("      %s\n", gui.Sprintf(hint))
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package selftest checks, one step after the other, that everything
+// Wahay needs for the meetings works in this computer: Tor connects
+// to the network, an onion service can be created and reached through
+// Tor, Mumble can be found and a certificate can be generated. It
+// tells which step fails, so the user knows where to look
+package selftest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/digitalautonomy/wahay/tor"
+)
+
+var (
+	errLoopbackMismatch = failure.New("selftest.loopback-mismatch", failure.CategoryTor, "the onion service answered something different from what was sent", "")
+	errNotPublished     = failure.New("selftest.not-published", failure.CategoryTor, "the onion service was not published in time", "check the connection of Tor to the network")
+	errSkipped          = failure.New("selftest.skipped", failure.CategoryUnknown, "skipped, since a step it needs has failed", "")
+)
+
+const (
+	// loopbackTimeout is how long the connection to the onion service
+	// can take. Reaching a new onion service can be slow
+	loopbackTimeout = 2 * time.Minute
+
+	// publicationTimeout is how long the onion service can take to be published
+	publicationTimeout = 3 * time.Minute
+
+	loopbackServicePort = 80
+	loopbackHost        = "127.0.0.1"
+)
+
+// Step is one of the checks of the self-test
+type Step struct {
+	// Name identifies the step, like "tor"
+	Name string
+
+	// Description tells what the step checks, in English
+	Description string
+
+	// Needs is the name of the step that must pass before this one
+	Needs string
+
+	Run func() error
+}
+
+// StepResult is what happened in a step
+type StepResult struct {
+	Step     Step
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Passed returns true when the step worked
+func (r StepResult) Passed() bool {
+	return r.Err == nil && !r.Skipped
+}
+
+// RunSteps runs the steps in order, calling report after each one. The steps
+// that need one that failed are skipped. It returns true when all passed
+func RunSteps(steps []Step, report func(StepResult)) bool {
+	failed := map[string]bool{}
+	all := true
+
+	for _, s := range steps {
+		r := StepResult{Step: s}
+
+		if s.Needs != "" && failed[s.Needs] {
+			r.Skipped = true
+			r.Err = errSkipped
+		} else {
+			start := time.Now()
+			r.Err = s.Run()
+			r.Duration = time.Since(start)
+		}
+
+		if !r.Passed() {
+			failed[s.Name] = true
+			all = false
+		}
+
+		if report != nil {
+			report(r)
+		}
+	}
+
+	return all
+}
+
+// Environment is what the steps need from the rest of Wahay, which
+// is different when Wahay runs with the GUI and without it
+type Environment struct {
+	// Tor returns our Tor instance, connected to the network
+	Tor func() (tor.Instance, error)
+
+	// Mumble checks that a Mumble binary that can be used is found
+	Mumble func() error
+
+	// Certificate generates a certificate like the one of the meetings
+	Certificate func() error
+}
+
+// Steps returns the checks of the self-test for the given
+// environment, in the order they have to be run
+func Steps(e Environment) []Step {
+	var t tor.Instance
+
+	return []Step{
+		{
+			Name:        "tor",
+			Description: "Tor connects to the network",
+			Run: func() (err error) {
+				t, err = e.Tor()
+				return err
+			},
+		},
+		{
+			Name:        "onion",
+			Description: "an onion service can be created and reached through Tor",
+			Needs:       "tor",
+			Run: func() error {
+				return checkOnionLoopback(t)
+			},
+		},
+		{
+			Name:        "mumble",
+			Description: "Mumble is found",
+			Run:         e.Mumble,
+		},
+		{
+			Name:        "certificate",
+			Description: "a certificate can be generated",
+			Run:         e.Certificate,
+		},
+	}
+}
+
+// checkOnionLoopback creates a throwaway onion service for a local
+// listener that echoes what it receives, and connects to it through
+// Tor, as the participants of a meeting would do
+func checkOnionLoopback(t tor.Instance) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(loopbackHost, "0"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+
+	go echo(l)
+
+	o, err := t.NewOnionServiceWithMultiplePorts([]tor.OnionPort{{
+		DestinationHost: loopbackHost,
+		DestinationPort: l.Addr().(*net.TCPAddr).Port,
+		ServicePort:     loopbackServicePort,
+	}})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = o.Delete()
+	}()
+
+	err = waitForPublication(o)
+	if err != nil {
+		return err
+	}
+
+	return loopback(t, net.JoinHostPort(o.ID(), strconv.Itoa(loopbackServicePort)))
+}
+
+func waitForPublication(o tor.Onion) error {
+	published := make(chan error, 1)
+	o.WhenPublished(func(err error) {
+		published <- err
+	})
+
+	select {
+	case err := <-published:
+		return err
+	case <-time.After(publicationTimeout):
+		return errNotPublished
+	}
+}
+
+func echo(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer func() {
+				_ = c.Close()
+			}()
+			_ = c.SetDeadline(time.Now().Add(loopbackTimeout))
+			_, _ = io.Copy(c, c)
+		}()
+	}
+}
+
+// loopback sends a random message to the address and checks that it comes back
+func loopback(t tor.Instance, address string) error {
+	c, err := t.Dial("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	_ = c.SetDeadline(time.Now().Add(loopbackTimeout))
+
+	sent := randomMessage()
+	_, err = c.Write(sent)
+	if err != nil {
+		return err
+	}
+
+	received := make([]byte, len(sent))
+	_, err = io.ReadFull(c, received)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sent, received) {
+		return errLoopbackMismatch
+	}
+
+	return nil
+}
+
+func randomMessage() []byte {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return []byte(hex.EncodeToString(b) + "\n")
+}
@@ -0,0 +1,103 @@
+package selftest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/digitalautonomy/wahay/tor"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SelfTestSuite struct{}
+
+var _ = Suite(&SelfTestSuite{})
+
+func passing(name string) Step {
+	return Step{Name: name, Run: func() error { return nil }}
+}
+
+func (s *SelfTestSuite) Test_RunSteps_reportsEveryStepInOrder(c *C) {
+	var reported []string
+
+	ok := RunSteps([]Step{passing("tor"), passing("mumble")}, func(r StepResult) {
+		reported = append(reported, r.Step.Name)
+		c.Assert(r.Passed(), Equals, true)
+	})
+
+	c.Assert(ok, Equals, true)
+	c.Assert(reported, DeepEquals, []string{"tor", "mumble"})
+}
+
+func (s *SelfTestSuite) Test_RunSteps_skipsTheStepsThatNeedOneThatFailed(c *C) {
+	broken := errors.New("no Tor")
+	ran := false
+
+	results := map[string]StepResult{}
+	ok := RunSteps([]Step{
+		{Name: "tor", Run: func() error { return broken }},
+		{Name: "onion", Needs: "tor", Run: func() error {
+			ran = true
+			return nil
+		}},
+		passing("mumble"),
+	}, func(r StepResult) {
+		results[r.Step.Name] = r
+	})
+
+	c.Assert(ok, Equals, false)
+	c.Assert(ran, Equals, false)
+	c.Assert(results["tor"].Err, Equals, broken)
+	c.Assert(results["onion"].Skipped, Equals, true)
+	c.Assert(results["onion"].Passed(), Equals, false)
+	c.Assert(results["mumble"].Passed(), Equals, true)
+}
+
+// directDialer connects without Tor, for checking the loopback
+type directDialer struct {
+	tor.Instance
+}
+
+func (directDialer) Dial(network, address string) (net.Conn, error) {
+	return net.Dial(network, address)
+}
+
+func (s *SelfTestSuite) Test_loopback_getsBackWhatIsSent(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	go echo(l)
+
+	c.Assert(loopback(directDialer{}, l.Addr().String()), IsNil)
+}
+
+func (s *SelfTestSuite) Test_loopback_failsWhenTheAnswerIsDifferent(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		received := make([]byte, len(randomMessage()))
+		_, _ = io.ReadFull(conn, received)
+		_, _ = conn.Write(bytes.Repeat([]byte{'x'}, len(received)))
+	}()
+
+	c.Assert(loopback(directDialer{}, l.Addr().String()), Equals, errLoopbackMismatch)
+}
@@ -1,9 +1,12 @@
 package tor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 
@@ -286,6 +289,69 @@ func (s *TorAcceptanceSuite) Test_thatThingsWillFailIfTheresNoSystemTor(c *C) {
 	c.Assert(e, ErrorMatches, "no Tor binary found")
 }
 
+func (s *TorAcceptanceSuite) Test_thatTheConfiguredTorControlPortIsTheOnlyOneUsed(c *C) {
+	mockAll()
+	defer setDefaultFacades()
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	log.SetOutput(ioutil.Discard)
+
+	tc := &mockTorgoController{}
+	tc.authNoneReturn = errors.New("couldn't auth")
+	tc.authCookieReturn = errors.New("couldn't auth")
+	tc.authPassReturn = nil
+	tc.getVersionReturn1 = "4.0.1"
+	tc.getVersionReturn2 = nil
+
+	mocktorgof.newControllerReturn1 = tc
+	mockhttpf.checkConnectionReturn = true
+
+	conf := &config.ApplicationConfig{
+		TorControlAddress:  "127.0.0.1:9151",
+		TorControlPassword: "the password",
+	}
+
+	ix, e := NewInstance(conf, nil)
+
+	c.Assert(e, IsNil)
+	c.Assert(mocktorgof.newControllerArg, Equals, "127.0.0.1:9151")
+	c.Assert(tc.authPassArg, Equals, "the password")
+
+	i := ix.(*instance)
+	c.Assert(i.controlPort, Equals, 9151)
+	c.Assert(i.password, Equals, "the password")
+	c.Assert(i.isLocal, Equals, true)
+	c.Assert(i.runningTor, IsNil)
+}
+
+func (s *TorAcceptanceSuite) Test_thatOurTorWillNotBeStarted_whenTheConfiguredTorIsNotUsable(c *C) {
+	mockAll()
+	defer setDefaultFacades()
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	log.SetOutput(ioutil.Discard)
+
+	mocktorgof.newControllerReturn2 = errors.New("no connection possible")
+	mockexecf.lookPathReturn1 = systemTorBinary
+
+	_, e := NewInstance(&config.ApplicationConfig{TorControlAddress: "127.0.0.1:9151"}, nil)
+
+	c.Assert(e, ErrorMatches, "error: we can't use system Tor instance: .*")
+	c.Assert(mocktorgof.newControllerArg, Equals, "127.0.0.1:9151")
+}
+
+func (s *TorAcceptanceSuite) Test_thatAnInvalidTorControlAddressIsReported(c *C) {
+	mockAll()
+	defer setDefaultFacades()
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	log.SetOutput(ioutil.Discard)
+
+	_, e := NewInstance(&config.ApplicationConfig{TorControlAddress: "localhost"}, nil)
+
+	c.Assert(e, ErrorMatches, "the address of the Tor control port is not valid: localhost")
+}
+
 func (s *TorAcceptanceSuite) Test_thatThingsWillFailIfTheresASystemTorWithOldVersion(c *C) {
 	mockAll()
 	defer setDefaultFacades()
@@ -689,3 +755,23 @@ func (m *mockHTTPImplementation) HTTPRequest(host string, port int, u string) (s
 	testPrint("HTTPRequest(%v, %v, %v)\n", host, port, u)
 	return "", nil
 }
+
+func (m *mockHTTPImplementation) HTTPDownload(host string, port int, u string, w io.Writer, limit int64, progress DownloadProgress) (int64, error) {
+	testPrint("HTTPDownload(%v, %v, %v, %v)\n", host, port, u, limit)
+	return 0, nil
+}
+
+func (m *mockHTTPImplementation) HTTPPost(host string, port int, u, contentType string, body []byte) error {
+	testPrint("HTTPPost(%v, %v, %v, %v)\n", host, port, u, contentType)
+	return nil
+}
+
+func (m *mockHTTPImplementation) Dial(host string, port int, network, address string) (net.Conn, error) {
+	testPrint("Dial(%v, %v, %v, %v)\n", host, port, network, address)
+	return nil, errors.New("not implemented in tests")
+}
+
+func (m *mockHTTPImplementation) DialIsolated(ctx context.Context, host string, port int, network, address string) (net.Conn, error) {
+	testPrint("DialIsolated(%v, %v, %v, %v)\n", host, port, network, address)
+	return nil, errors.New("not implemented in tests")
+}
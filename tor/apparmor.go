@@ -0,0 +1,129 @@
+package tor
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AppArmorProfileTor is the name of the AppArmor profile used for
+	// the Tor process started by Wahay
+	AppArmorProfileTor = "wahay-tor"
+
+	// AppArmorProfileMumble is the name of the AppArmor profile used
+	// for the Mumble client started by Wahay
+	AppArmorProfileMumble = "wahay-mumble"
+)
+
+const (
+	appArmorEnabledFile  = "/sys/module/apparmor/parameters/enabled"
+	appArmorProfilesFile = "/sys/kernel/security/apparmor/profiles"
+	appArmorCurrentFile  = "/proc/self/attr/current"
+	appArmorExecCommand  = "aa-exec"
+)
+
+type appArmorState struct {
+	aaExec   string
+	profiles map[string]bool
+}
+
+var appArmorOnce sync.Once
+var appArmor *appArmorState
+
+func detectAppArmor() *appArmorState {
+	appArmorOnce.Do(func() {
+		appArmor = &appArmorState{
+			profiles: map[string]bool{},
+		}
+
+		enabled, err := ioutil.ReadFile(appArmorEnabledFile)
+		if err != nil || strings.TrimSpace(string(enabled)) != "Y" {
+			log.Info("AppArmor is not enabled in this system, spawned processes will not be confined")
+			return
+		}
+
+		aaExec, err := exec.LookPath(appArmorExecCommand)
+		if err != nil {
+			log.Infof("AppArmor is enabled but %s is not available, spawned processes will not be confined", appArmorExecCommand)
+			return
+		}
+
+		appArmor.aaExec = aaExec
+		appArmor.loadProfiles()
+
+		log.WithFields(log.Fields{
+			"wahay":  currentAppArmorConfinement(),
+			"tor":    appArmor.hasProfile(AppArmorProfileTor),
+			"mumble": appArmor.hasProfile(AppArmorProfileMumble),
+		}).Info("AppArmor confinement status")
+	})
+
+	return appArmor
+}
+
+func (s *appArmorState) loadProfiles() {
+	content, err := ioutil.ReadFile(appArmorProfilesFile)
+	if err != nil {
+		log.Debugf("AppArmor loaded profiles can't be read: %s", err)
+		return
+	}
+
+	// Every line has the form: "profile-name (mode)"
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			s.profiles[fields[0]] = true
+		}
+	}
+}
+
+func (s *appArmorState) hasProfile(profile string) bool {
+	return s.aaExec != "" && s.profiles[profile]
+}
+
+func currentAppArmorConfinement() string {
+	content, err := ioutil.ReadFile(appArmorCurrentFile)
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// confineCommand changes the given command so it's started
+// under the given AppArmor profile, if that profile is available
+func confineCommand(cmd *exec.Cmd, profile string) {
+	s := detectAppArmor()
+	if !s.hasProfile(profile) {
+		log.WithFields(log.Fields{
+			"profile": profile,
+			"command": cmd.Path,
+		}).Debug("Starting process without AppArmor confinement")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"profile": profile,
+		"command": cmd.Path,
+	}).Info("Starting process confined by AppArmor")
+
+	args := []string{s.aaExec, "-p", profile, "--", cmd.Path}
+	if len(cmd.Args) > 1 {
+		args = append(args, cmd.Args[1:]...)
+	}
+
+	cmd.Path = s.aaExec
+	cmd.Args = args
+}
+
+// ConfineWithAppArmor returns a command modifier that starts
+// the command under the given AppArmor profile when the system
+// supports it. Otherwise the command is not modified
+func ConfineWithAppArmor(profile string) ModifyCommand {
+	return func(cmd *exec.Cmd) {
+		confineCommand(cmd, profile)
+	}
+}
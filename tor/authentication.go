@@ -1,5 +1,18 @@
 package tor
 
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/wybiral/torgo"
+)
+
 type authenticationMethod func(tc torgoController) error
 
 func authenticateNone(tc torgoController) error {
@@ -28,3 +41,105 @@ func authenticateAny(am ...authenticationMethod) func(torgoController) error {
 		return e
 	}
 }
+
+// authenticateCookieFile authenticates with the cookie file of Tor,
+// proving we can read it with SAFECOOKIE when Tor supports it, so the
+// cookie itself is never sent to the control port
+var authenticateCookieFile = authenticateAny(authenticateSafeCookie, authenticateCookie)
+
+const (
+	safeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	safeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+	safeCookieNonceSize = 32
+)
+
+var (
+	errSafeCookieNotSupported = errors.New("the control port doesn't support SAFECOOKIE authentication")
+	errSafeCookieServerHash   = errors.New("the control port doesn't know the cookie")
+)
+
+// authenticateSafeCookie fails without sending anything when SAFECOOKIE
+// can't be used, since Tor closes the connection after a failed attempt
+func authenticateSafeCookie(tc torgoController) error {
+	c, ok := tc.(*torgo.Controller)
+	if !ok || c.CookieFile == "" || !containsString(c.AuthMethods, "SAFECOOKIE") {
+		return errSafeCookieNotSupported
+	}
+
+	cookie, err := ioutil.ReadFile(c.CookieFile)
+	if err != nil {
+		return err
+	}
+
+	clientNonce := make([]byte, safeCookieNonceSize)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+
+	reply, err := controlCommand(c, "AUTHCHALLENGE SAFECOOKIE %s", hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+
+	serverHash, serverNonce, err := parseAuthChallenge(reply)
+	if err != nil {
+		return err
+	}
+
+	message := append(append(append([]byte{}, cookie...), clientNonce...), serverNonce...)
+	if !hmac.Equal(serverHash, safeCookieHash(safeCookieServerKey, message)) {
+		return errSafeCookieServerHash
+	}
+
+	_, err = controlCommand(c, "AUTHENTICATE %s", hex.EncodeToString(safeCookieHash(safeCookieClientKey, message)))
+	return err
+}
+
+func safeCookieHash(key string, message []byte) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	_, _ = h.Write(message)
+	return h.Sum(nil)
+}
+
+// parseAuthChallenge reads a reply like:
+// AUTHCHALLENGE SERVERHASH=<hex> SERVERNONCE=<hex>
+func parseAuthChallenge(reply string) (serverHash, serverNonce []byte, err error) {
+	for _, f := range strings.Fields(reply) {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "SERVERHASH":
+			serverHash, err = hex.DecodeString(kv[1])
+		case "SERVERNONCE":
+			serverNonce, err = hex.DecodeString(kv[1])
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(serverHash) != sha256.Size || len(serverNonce) != safeCookieNonceSize {
+		return nil, nil, fmt.Errorf("invalid reply to AUTHCHALLENGE: %s", reply)
+	}
+
+	return serverHash, serverNonce, nil
+}
+
+// controlCommand sends a command that torgo doesn't implement
+// and returns the reply, when its status is 250
+func controlCommand(c *torgo.Controller, format string, args ...interface{}) (string, error) {
+	id, err := c.Text.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+
+	c.Text.StartResponse(id)
+	defer c.Text.EndResponse(id)
+
+	_, msg, err := c.Text.ReadResponse(250)
+	return msg, err
+}
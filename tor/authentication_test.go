@@ -0,0 +1,104 @@
+package tor
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wybiral/torgo"
+	. "gopkg.in/check.v1"
+)
+
+type TorAuthenticationSuite struct{}
+
+var _ = Suite(&TorAuthenticationSuite{})
+
+// fakeSafeCookieControlPort answers the SAFECOOKIE authentication the way
+// Tor does, sending in the end if the client proved it knows the cookie
+func fakeSafeCookieControlPort(conn net.Conn, cookie []byte, authenticated chan<- bool) {
+	text := textproto.NewConn(conn)
+	defer func() {
+		_ = text.Close()
+	}()
+
+	l, err := text.ReadLine()
+	if err != nil || !strings.HasPrefix(l, "AUTHCHALLENGE SAFECOOKIE ") {
+		authenticated <- false
+		return
+	}
+
+	clientNonce, _ := hex.DecodeString(strings.TrimPrefix(l, "AUTHCHALLENGE SAFECOOKIE "))
+	serverNonce := []byte(strings.Repeat("n", safeCookieNonceSize))
+	message := append(append(append([]byte{}, cookie...), clientNonce...), serverNonce...)
+
+	_ = text.PrintfLine("250 AUTHCHALLENGE SERVERHASH=%s SERVERNONCE=%s",
+		hex.EncodeToString(safeCookieHash(safeCookieServerKey, message)),
+		hex.EncodeToString(serverNonce))
+
+	l, err = text.ReadLine()
+	ok := err == nil && l == "AUTHENTICATE "+hex.EncodeToString(safeCookieHash(safeCookieClientKey, message))
+	if ok {
+		_ = text.PrintfLine("250 OK")
+	} else {
+		_ = text.PrintfLine("515 Authentication failed")
+	}
+	authenticated <- ok
+}
+
+func (s *TorAuthenticationSuite) Test_authenticateSafeCookie_provesItKnowsTheCookie(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-cookie")
+	c.Assert(err, IsNil)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	cookie := []byte(strings.Repeat("c", 32))
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	c.Assert(ioutil.WriteFile(cookieFile, cookie, 0600), IsNil)
+
+	client, server := net.Pipe()
+	authenticated := make(chan bool, 1)
+	go fakeSafeCookieControlPort(server, cookie, authenticated)
+
+	tc := &torgo.Controller{
+		AuthMethods: []string{"COOKIE", "SAFECOOKIE"},
+		CookieFile:  cookieFile,
+		Text:        textproto.NewConn(client),
+	}
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	c.Assert(authenticateSafeCookie(tc), IsNil)
+	c.Assert(<-authenticated, Equals, true)
+}
+
+func (s *TorAuthenticationSuite) Test_authenticateSafeCookie_isNotTriedWhenTorDoesntSupportIt(c *C) {
+	tc := &torgo.Controller{
+		AuthMethods: []string{"COOKIE"},
+		CookieFile:  "/nonexistent/control_auth_cookie",
+	}
+
+	c.Assert(authenticateSafeCookie(tc), Equals, errSafeCookieNotSupported)
+	c.Assert(authenticateSafeCookie(&mockTorgoController{}), Equals, errSafeCookieNotSupported)
+}
+
+func (s *TorAuthenticationSuite) Test_authenticateCookieFile_usesTheCookieWithoutSafeCookie(c *C) {
+	tc := &mockTorgoController{}
+
+	c.Assert(authenticateCookieFile(tc), IsNil)
+	c.Assert(tc.authCookieCalled, Equals, 1)
+}
+
+func (s *TorAuthenticationSuite) Test_parseSocksListeners_returnsTheFirstPort(c *C) {
+	p, ok := parseSocksListeners(`net/listeners/socks="unix:/run/tor/socks" "127.0.0.1:9150" "127.0.0.1:9050"`)
+	c.Assert(ok, Equals, true)
+	c.Assert(p, Equals, 9150)
+
+	_, ok = parseSocksListeners(`net/listeners/socks=`)
+	c.Assert(ok, Equals, false)
+}
@@ -2,8 +2,6 @@ package tor
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"os/exec"
 	"path/filepath"
 	"regexp"
@@ -11,6 +9,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 const libTorsocks = "libtorsocks.so"
@@ -34,7 +33,7 @@ var libSuffixes = []string{
 }
 
 var (
-	errInvalidCommand = errors.New("invalid command")
+	errInvalidCommand = failure.New("tor.invalid-command", failure.CategoryTor, "invalid command", "")
 )
 
 type binary struct {
@@ -47,15 +46,19 @@ type binary struct {
 var (
 	// ErrInvalidTorPath is an error to be trown where custom paths
 	// to find the Tor binary are empty or don't exists
-	ErrInvalidTorPath = errors.New("invalid Tor path")
+	ErrInvalidTorPath = failure.New("tor.invalid-path", failure.CategoryConfiguration, "invalid Tor path", "check the location of Tor in the settings")
 
 	// ErrTorVersionNotCompatible is an error to be trown where some
 	// Tor binary is found but the version is incompatible
-	ErrTorVersionNotCompatible = errors.New("incompatible Tor version")
+	ErrTorVersionNotCompatible = failure.New("tor.incompatible-version", failure.CategoryTor, "incompatible Tor version", "install a newer version of Tor")
 
 	// ErrInvalidConfiguredTorBinary is an error to be trown where the user
 	// configure a custom path for Tor binary and it's no valid
-	ErrInvalidConfiguredTorBinary = errors.New("invalid Tor binary user configured path")
+	ErrInvalidConfiguredTorBinary = failure.New("tor.invalid-configured-binary", failure.CategoryConfiguration, "invalid Tor binary user configured path", "check the location of Tor in the settings")
+
+	// ErrLibTorsocksNotFound is an error to be trown when the
+	// torsocks library can't be found in the system
+	ErrLibTorsocksNotFound = failure.New("tor.libtorsocks-not-found", failure.CategoryTor, "libtorsocks not found", "install torsocks in the system")
 )
 
 func findTorBinary(conf *config.ApplicationConfig) (b *binary, err error) {
@@ -64,7 +67,8 @@ func findTorBinary(conf *config.ApplicationConfig) (b *binary, err error) {
 		findTorBinaryInDataDir,
 		findTorBinaryInCurrentWorkingDir,
 		findTorBinaryInWahayDir,
-		findTorBinaryInSystem,
+		findTorBinaryInSystem(conf),
+		findTorBinaryInBundle,
 	}
 
 	for _, cb := range functions {
@@ -170,25 +174,40 @@ func findTorBinaryInWahayDir() (b *binary, fatalErr error) {
 	return b, nil
 }
 
-func findTorBinaryInSystem() (b *binary, fatalErr error) {
-	path, err := execf.LookPath("tor")
-	if err != nil {
-		return nil, nil
-	}
+func findTorBinaryInSystem(conf *config.ApplicationConfig) func() (b *binary, fatalErr error) {
+	return func() (*binary, error) {
+		path, err := conf.LookPath("tor", execf.LookPath)
+		if err != nil {
+			path = findTorInSystemDirs()
+		}
+		if path == "" {
+			return nil, nil
+		}
 
-	log.Debugf("findTorBinaryInSystem(%s)", path)
+		log.Debugf("findTorBinaryInSystem(%s)", path)
 
-	b, errTorBinary := isThereConfiguredTorBinary(path)
+		b, errTorBinary := isThereConfiguredTorBinary(path)
 
-	// Ensure we have torsocks available in the system
-	if errTorBinary == nil {
-		errTorsocks := findTorsocksBinary()
-		if errTorsocks != nil {
-			return b, errTorsocks
+		// Ensure we have torsocks available in the system
+		if errTorBinary == nil {
+			errTorsocks := findTorsocksBinary()
+			if errTorsocks != nil {
+				return b, errTorsocks
+			}
 		}
+
+		return b, nil
 	}
+}
 
-	return b, nil
+func findTorInSystemDirs() string {
+	for _, d := range torSystemDirs {
+		path := filepath.Join(d, torExecutable)
+		if filesystemf.FileExists(path) {
+			return path
+		}
+	}
+	return ""
 }
 
 func isThereConfiguredTorBinary(path string) (b *binary, err error) {
@@ -228,7 +247,7 @@ func getBinaryForPath(path string) (b *binary, err error) {
 
 	if checkIfBinaryIsBundled(b) {
 		b.isBundle = true
-		b.env = append(b.env, fmt.Sprintf("LD_LIBRARY_PATH=%s", filepath.Dir(path)))
+		b.env = append(b.env, libraryPathEnv(filepath.Dir(path)))
 	}
 
 	b.isValid = isTorVersionCompatible(b)
@@ -280,6 +299,21 @@ func isTorVersionCompatible(b *binary) bool {
 	return diff >= 0
 }
 
+// binaryVersion returns the version of the Tor binary, or an empty
+// string when it can't be found out
+func binaryVersion(b *binary) string {
+	output, err := execTorCommand(b.path, []string{"--version"}, func(cmd *exec.Cmd) {
+		if b.isBundle {
+			cmd.Env = append(cmd.Env, b.env...)
+		}
+	})
+	if err != nil {
+		return ""
+	}
+
+	return extractVersionFrom(output)
+}
+
 func execTorCommand(bin string, args []string, cm ModifyCommand) ([]byte, error) {
 	output, err := execf.ExecWithModify(bin, args, cm)
 	if len(output) == 0 || err != nil {
@@ -337,7 +371,7 @@ func findLibTorsocks(filePath string) (string, error) {
 		}
 	}
 
-	return "", errors.New("libtorsocks not found")
+	return "", ErrLibTorsocksNotFound
 }
 
 func listPossibleTorBinary(path string) []string {
@@ -348,7 +382,7 @@ func listPossibleTorBinary(path string) []string {
 	for _, match := range matches {
 		filename := filepath.Base(match)
 
-		if filename == "tor" {
+		if filename == torExecutable {
 			result = append(result, match)
 		} else {
 			diff, err := compareVersions(extractVersionFrom([]byte(filename)), minSupportedVersion)
@@ -373,6 +407,8 @@ func (b *binary) start(configFile string) (*runningTor, error) {
 		cmd.Env = append(osf.Environ(), b.env...)
 	}
 
+	confineCommand(cmd, AppArmorProfileTor)
+
 	if err := execf.StartCommand(cmd); err != nil {
 		cancelFunc()
 		return nil, err
@@ -0,0 +1,279 @@
+package tor
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// While our Tor instance connects to the network, it reports every
+// phase in STATUS_CLIENT events of the control port, together with the
+// problems it finds. We follow them so the user can see how far it is,
+// and stop it instead of waiting for the timeout when it doesn't advance.
+
+// bootstrapCheckInterval is how often the connectivity of
+// our instance is checked while it's bootstrapping
+const bootstrapCheckInterval = 3 * time.Second
+
+// ErrBootstrapCanceled is returned when the user stops our
+// Tor instance before it's connected to the network
+var ErrBootstrapCanceled = failure.New("tor.bootstrap-canceled", failure.CategoryTor, "the connection to the Tor network was canceled", "")
+
+var errTorExited = failure.New("tor.exited", failure.CategoryTor, "Tor stopped while it was connecting to the network", "")
+
+// BootstrapProgress is the state of the connection of our Tor instance to the network
+type BootstrapProgress struct {
+	// Percent goes from 0 to 100, when Tor is connected
+	Percent int
+	// Phase is the tag Tor gives to the phase, like "loading_descriptors"
+	Phase string
+	// Summary describes the phase, in English
+	Summary string
+	// Warning is the last problem reported by Tor, if any
+	Warning string
+}
+
+// Bootstrap follows our Tor instance while it connects to
+// the network, letting the user cancel it at any moment
+type Bootstrap struct {
+	progress chan BootstrapProgress
+	canceled chan bool
+	cancel   sync.Once
+	finish   sync.Once
+}
+
+// NewBootstrap returns a Bootstrap to give to NewInstanceWithBootstrap
+func NewBootstrap() *Bootstrap {
+	return &Bootstrap{
+		progress: make(chan BootstrapProgress, 100),
+		canceled: make(chan bool),
+	}
+}
+
+// Progress returns the channel that receives every change in the
+// bootstrap. It's closed when Tor is ready, or it can't be used
+func (b *Bootstrap) Progress() <-chan BootstrapProgress {
+	return b.progress
+}
+
+// Cancel stops our Tor instance if it's still connecting to the network
+func (b *Bootstrap) Cancel() {
+	b.cancel.Do(func() {
+		close(b.canceled)
+	})
+}
+
+func (b *Bootstrap) canceledChannel() <-chan bool {
+	if b == nil {
+		return nil
+	}
+	return b.canceled
+}
+
+// report sends the progress without blocking the bootstrap,
+// so it doesn't matter if nobody reads it
+func (b *Bootstrap) report(p BootstrapProgress) {
+	if b == nil {
+		return
+	}
+
+	select {
+	case b.progress <- p:
+	default:
+	}
+}
+
+func (b *Bootstrap) done() {
+	if b == nil {
+		return
+	}
+
+	b.finish.Do(func() {
+		close(b.progress)
+	})
+}
+
+// waitForBootstrap waits until our instance is connected to the network,
+// reporting its progress. The events are only used for showing it, it's
+// the checker the one that decides when Tor can be used
+func (i *instance) waitForBootstrap(checker basicConnectivity, b *Bootstrap) error {
+	var stream EventStream
+	eventsSupported := true
+
+	openStream := func() {
+		if stream != nil || !eventsSupported {
+			return
+		}
+
+		var err error
+		stream, err = i.GetController().NewEventStream("STATUS_CLIENT", "WARN")
+		if failure.Is(err, ErrEventsNotSupported) {
+			eventsSupported = false
+		}
+	}
+
+	defer func() {
+		if stream != nil {
+			_ = stream.Close()
+		}
+	}()
+
+	timeout := time.NewTimer(torStartupTimeout)
+	defer timeout.Stop()
+
+	ticker := time.NewTicker(bootstrapCheckInterval)
+	defer ticker.Stop()
+
+	// Tor stops by itself when it can't use its ports
+	var exited <-chan bool
+	if i.runningTor != nil {
+		exited = i.runningTor.finishChannel
+	}
+
+	var progress BootstrapProgress
+	for {
+		var events <-chan string
+		if stream != nil {
+			events = stream.Events()
+		}
+
+		select {
+		case <-b.canceledChannel():
+			return ErrBootstrapCanceled
+
+		case <-timeout.C:
+			return ErrTorConnectionTimeout
+
+		case <-exited:
+			return errTorExited
+
+		case e, ok := <-events:
+			if !ok {
+				_ = stream.Close()
+				stream = nil
+				continue
+			}
+
+			if p, changed := processBootstrapEvent(progress, e); changed {
+				progress = p
+				b.report(p)
+			}
+
+		case <-ticker.C:
+			openStream()
+
+			_, errTotal, errPartial := checker.check()
+			if errTotal != nil {
+				return errTotal
+			}
+
+			if errPartial == nil {
+				return nil
+			}
+
+			log.WithFields(log.Fields{
+				"time":     time.Now(),
+				"progress": progress.Percent,
+			}).Errorf("The following error occurred while checking Tor connectivity: %s", errPartial.Error())
+		}
+	}
+}
+
+// processBootstrapEvent returns the progress after the given event,
+// and if it changed
+func processBootstrapEvent(p BootstrapProgress, event string) (BootstrapProgress, bool) {
+	fields := strings.SplitN(event, " ", 4)
+
+	switch {
+	case len(fields) == 4 && fields[0] == "STATUS_CLIENT" && fields[2] == "BOOTSTRAP":
+		// STATUS_CLIENT Severity BOOTSTRAP PROGRESS=num TAG=tag SUMMARY=text [WARNING=text ...]
+		args := parseEventArguments(fields[3])
+
+		percent, err := strconv.Atoi(args["PROGRESS"])
+		if err != nil {
+			return p, false
+		}
+
+		result := BootstrapProgress{
+			Percent: percent,
+			Phase:   args["TAG"],
+			Summary: args["SUMMARY"],
+		}
+
+		if fields[1] == "WARN" || fields[1] == "ERR" {
+			result.Warning = args["WARNING"]
+		} else if result.Phase == p.Phase {
+			// Tor doesn't repeat the warning, so it's kept until the phase changes
+			result.Warning = p.Warning
+		}
+
+		return result, result != p
+
+	case len(fields) > 1 && fields[0] == "WARN":
+		// WARN Message, from the log of Tor
+		w := strings.TrimPrefix(event, "WARN ")
+		if w == p.Warning {
+			return p, false
+		}
+		p.Warning = w
+		return p, true
+	}
+
+	return p, false
+}
+
+// parseEventArguments returns the KEYWORD=value arguments of an
+// event. The values can be quoted, with backslash escapes
+func parseEventArguments(s string) map[string]string {
+	result := map[string]string{}
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+
+		eq := strings.IndexByte(s, '=')
+		sp := strings.IndexByte(s, ' ')
+		if eq < 0 || (sp >= 0 && sp < eq) {
+			// An argument without value
+			if sp < 0 {
+				break
+			}
+			s = s[sp:]
+			continue
+		}
+
+		key := s[:eq]
+		s = s[eq+1:]
+
+		if !strings.HasPrefix(s, "\"") {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			result[key] = s[:end]
+			s = s[end:]
+			continue
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			value.WriteByte(s[i])
+		}
+		result[key] = value.String()
+
+		if i < len(s) {
+			i++
+		}
+		s = s[i:]
+	}
+
+	return result
+}
@@ -0,0 +1,85 @@
+package tor
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TorBootstrapSuite struct{}
+
+var _ = Suite(&TorBootstrapSuite{})
+
+type neverReadyChecker struct{}
+
+func (*neverReadyChecker) check() (string, error, error) {
+	return "", nil, ErrPartialTorNoControlPort
+}
+
+func (s *TorBootstrapSuite) Test_processBootstrapEvent_readsTheProgress(c *C) {
+	p, changed := processBootstrapEvent(BootstrapProgress{},
+		`STATUS_CLIENT NOTICE BOOTSTRAP PROGRESS=50 TAG=loading_descriptors SUMMARY="Loading relay descriptors"`)
+
+	c.Assert(changed, Equals, true)
+	c.Assert(p, DeepEquals, BootstrapProgress{
+		Percent: 50,
+		Phase:   "loading_descriptors",
+		Summary: "Loading relay descriptors",
+	})
+
+	_, changed = processBootstrapEvent(p, `STATUS_CLIENT NOTICE BOOTSTRAP PROGRESS=50 TAG=loading_descriptors SUMMARY="Loading relay descriptors"`)
+	c.Assert(changed, Equals, false)
+}
+
+func (s *TorBootstrapSuite) Test_processBootstrapEvent_keepsTheWarningsOfThePhase(c *C) {
+	p, _ := processBootstrapEvent(BootstrapProgress{},
+		`STATUS_CLIENT WARN BOOTSTRAP PROGRESS=10 TAG=conn_done SUMMARY="Connected to a relay" WARNING="Connection refused \"here\"" REASON=CONNECTREFUSED COUNT=1 RECOMMENDATION=ignore`)
+	c.Assert(p.Percent, Equals, 10)
+	c.Assert(p.Warning, Equals, `Connection refused "here"`)
+
+	p, _ = processBootstrapEvent(p, `STATUS_CLIENT NOTICE BOOTSTRAP PROGRESS=10 TAG=conn_done SUMMARY="Connected to a relay"`)
+	c.Assert(p.Warning, Equals, `Connection refused "here"`)
+
+	p, _ = processBootstrapEvent(p, "WARN Problem bootstrapping. Stuck at 10%")
+	c.Assert(p.Warning, Equals, "Problem bootstrapping. Stuck at 10%")
+	c.Assert(p.Percent, Equals, 10)
+
+	p, _ = processBootstrapEvent(p, `STATUS_CLIENT NOTICE BOOTSTRAP PROGRESS=100 TAG=done SUMMARY="Done"`)
+	c.Assert(p, DeepEquals, BootstrapProgress{Percent: 100, Phase: "done", Summary: "Done"})
+}
+
+func (s *TorBootstrapSuite) Test_processBootstrapEvent_ignoresOtherEvents(c *C) {
+	for _, e := range []string{
+		"STATUS_CLIENT NOTICE CIRCUIT_ESTABLISHED",
+		"STATUS_CLIENT NOTICE BOOTSTRAP TAG=done",
+		"CIRC 1 BUILT",
+	} {
+		_, changed := processBootstrapEvent(BootstrapProgress{}, e)
+		c.Assert(changed, Equals, false, Commentf("event: %s", e))
+	}
+}
+
+func (s *TorBootstrapSuite) Test_waitForBootstrap_returnsWhenItsCanceled(c *C) {
+	b := NewBootstrap()
+	b.Cancel()
+	b.Cancel()
+
+	i := &instance{}
+	c.Assert(i.waitForBootstrap(&neverReadyChecker{}, b), Equals, ErrBootstrapCanceled)
+
+	b.done()
+	_, open := <-b.Progress()
+	c.Assert(open, Equals, false)
+}
+
+func (s *TorBootstrapSuite) Test_waitForBootstrap_returnsWhenTorStops(c *C) {
+	r := &runningTor{finishChannel: make(chan bool, 1)}
+	r.finishChannel <- true
+
+	i := &instance{runningTor: r}
+	c.Assert(i.waitForBootstrap(&neverReadyChecker{}, NewBootstrap()), Equals, errTorExited)
+}
+
+func (s *TorBootstrapSuite) Test_findAvailableTorPorts_keepsTheGivenPorts(c *C) {
+	controlPort, routePort := findAvailableTorPorts(9251, 9250)
+	c.Assert(controlPort, Equals, 9251)
+	c.Assert(routePort, Equals, 9250)
+}
@@ -0,0 +1,301 @@
+package tor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The pluggable transports that can be used in the bridges
+const (
+	TransportObfs4     = "obfs4"
+	TransportSnowflake = "snowflake"
+	TransportMeek      = "meek_lite"
+)
+
+// transportPlugins are the binaries that implement every pluggable
+// transport, in the order they are looked for. Lyrebird is the new
+// name of obfs4proxy, and both also implement meek
+var transportPlugins = map[string][]string{
+	TransportObfs4:     {"lyrebird", "obfs4proxy"},
+	TransportMeek:      {"lyrebird", "obfs4proxy"},
+	TransportSnowflake: {"snowflake-client"},
+}
+
+// transportAliases are other names given to the transports in bridge lines
+var transportAliases = map[string]string{
+	"meek": TransportMeek,
+}
+
+// pluggableTransportsDir is the directory where the Tor Browser
+// bundle keeps the pluggable transports, next to its Tor binary
+const pluggableTransportsDir = "PluggableTransports"
+
+// bundlePluggableTransportsDir is where the Tor expert bundle
+// keeps them, and so the Tor installed by Wahay
+const bundlePluggableTransportsDir = "pluggable_transports"
+
+// moatBuiltinURL returns the built-in bridges of the Tor Project, the
+// same ones distributed with Tor Browser
+var moatBuiltinURL = "https://bridges.torproject.org/moat/circumvention/builtin"
+
+const moatRequestTimeout = 30 * time.Second
+
+var (
+	// ErrInvalidBridge is returned when a bridge line can't be used
+	ErrInvalidBridge = failure.New("tor.invalid-bridge", failure.CategoryInput, "the bridge line is not valid", "copy the complete line, as given by the Tor Project")
+
+	// ErrTransportPluginNotFound is returned when the pluggable transport of a bridge is not installed
+	ErrTransportPluginNotFound = failure.New("tor.transport-plugin-not-found", failure.CategoryTor, "the pluggable transport of the bridges is not installed", "install obfs4proxy or lyrebird for obfs4 and meek bridges, and snowflake-client for snowflake bridges")
+
+	// ErrBridgesNotAvailable is returned when the built-in bridges can't be requested
+	ErrBridgesNotAvailable = failure.New("tor.bridges-not-available", failure.CategoryTor, "the bridges can't be requested from the Tor Project", "get bridges from https://bridges.torproject.org using a different network and paste them")
+)
+
+// bridge is a bridge line, without the leading "Bridge" keyword
+// Tor uses in its configuration
+type bridge struct {
+	transport string
+	line      string
+}
+
+// ParseBridges validates the given bridge lines, one per line, and
+// returns them normalized. Empty lines and comments are skipped
+func ParseBridges(text string) ([]string, error) {
+	var result []string
+	for _, l := range strings.Split(text, "\n") {
+		b, ok, err := parseBridge(l)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, b.line)
+		}
+	}
+	return result, nil
+}
+
+func parseBridge(l string) (*bridge, bool, error) {
+	fields := strings.Fields(l)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "bridge") {
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil, false, nil
+	}
+
+	transport := ""
+	if !isBridgeAddress(fields[0]) {
+		transport = strings.ToLower(fields[0])
+		if alias, ok := transportAliases[transport]; ok {
+			transport = alias
+		}
+		if _, ok := transportPlugins[transport]; !ok {
+			return nil, false, ErrInvalidBridge.Wrapf("unknown pluggable transport %s", fields[0])
+		}
+
+		fields[0] = transport
+		if len(fields) < 2 || !isBridgeAddress(fields[1]) {
+			return nil, false, ErrInvalidBridge.Wrapf("missing the address in: %s", l)
+		}
+	}
+
+	// After the address it can come the fingerprint of the bridge
+	// and then the arguments of the pluggable transport
+	next := 1
+	if transport != "" {
+		next = 2
+	}
+	if len(fields) > next && !strings.Contains(fields[next], "=") && !isFingerprint(fields[next]) {
+		return nil, false, ErrInvalidBridge.Wrapf("invalid fingerprint in: %s", l)
+	}
+
+	return &bridge{
+		transport: transport,
+		line:      strings.Join(fields, " "),
+	}, true, nil
+}
+
+func isBridgeAddress(s string) bool {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || host == "" {
+		return false
+	}
+
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
+
+func isFingerprint(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 20
+}
+
+// findTransportPlugin looks for the binary of the transport in the
+// given directories, and then in the system
+func findTransportPlugin(transport string, dirs []string) (string, bool) {
+	for _, name := range transportPlugins[transport] {
+		for _, d := range dirs {
+			p := filepath.Join(d, name)
+			if filesystemf.FileExists(p) && !filesystemf.IsADirectory(p) {
+				return p, true
+			}
+		}
+
+		if p, err := execf.LookPath(name); err == nil {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// bridgesConfiguration returns the lines of the Tor configuration that
+// use the given bridges, with the pluggable transports they need
+func bridgesConfiguration(lines []string, pluginDirs []string) (string, error) {
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	// The binaries are shared by several transports, and
+	// every binary is configured once with all of them
+	plugins := map[string][]string{}
+
+	var buf bytes.Buffer
+	buf.WriteString("\n## Connect to Tor through bridges\nUseBridges 1\n")
+
+	for _, l := range lines {
+		b, ok, err := parseBridge(l)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+
+		if b.transport != "" {
+			p, found := findTransportPlugin(b.transport, pluginDirs)
+			if !found {
+				return "", ErrTransportPluginNotFound.Wrapf("no binary found for %s", b.transport)
+			}
+			if !containsString(plugins[p], b.transport) {
+				plugins[p] = append(plugins[p], b.transport)
+			}
+		}
+
+		fmt.Fprintf(&buf, "Bridge %s\n", b.line)
+	}
+
+	paths := make([]string, 0, len(plugins))
+	for p := range plugins {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "ClientTransportPlugin %s exec %s\n", strings.Join(plugins[p], ","), p)
+	}
+
+	return buf.String(), nil
+}
+
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// useBridges makes our Tor instance connect through the given bridges,
+// writing its configuration again. It must be called before starting it
+func (i *instance) useBridges(lines []string) error {
+	var dirs []string
+	if i.binary != nil && i.binary.path != "" {
+		d := filepath.Dir(i.binary.path)
+		dirs = append(dirs, d, filepath.Join(d, pluggableTransportsDir), filepath.Join(d, bundlePluggableTransportsDir))
+	}
+
+	c, err := bridgesConfiguration(lines, dirs)
+	if err != nil {
+		return err
+	}
+
+	if c == "" {
+		return nil
+	}
+
+	log.Infof("Connecting to Tor through %d bridges", len(lines))
+
+	i.bridgesConfig = c
+	return i.writeToFile()
+}
+
+// FetchBuiltinBridges requests the built-in bridges of the given
+// transport from the Tor Project. It's done without Tor, since the bridges
+// are needed when it can't connect, so the network can see the request
+func FetchBuiltinBridges(transport string) ([]string, error) {
+	client := &http.Client{Timeout: moatRequestTimeout}
+
+	resp, err := client.Post(moatBuiltinURL, "application/vnd.api+json", strings.NewReader("{}"))
+	if err != nil {
+		return nil, ErrBridgesNotAvailable.Wrap(err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrBridgesNotAvailable.Wrapf("unexpected response: %s", resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ErrBridgesNotAvailable.Wrap(err)
+	}
+
+	return parseBuiltinBridges(content, transport)
+}
+
+func parseBuiltinBridges(content []byte, transport string) ([]string, error) {
+	var builtin map[string][]string
+	err := json.Unmarshal(content, &builtin)
+	if err != nil {
+		return nil, ErrBridgesNotAvailable.Wrap(err)
+	}
+
+	name := transport
+	if transport == TransportMeek {
+		name = "meek"
+	}
+
+	var result []string
+	for _, l := range builtin[name] {
+		b, ok, err := parseBridge(l)
+		if err == nil && ok {
+			result = append(result, b.line)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, ErrBridgesNotAvailable.Wrapf("no %s bridges were received", transport)
+	}
+
+	return result, nil
+}
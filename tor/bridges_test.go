@@ -0,0 +1,92 @@
+package tor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/digitalautonomy/wahay/failure"
+	. "gopkg.in/check.v1"
+)
+
+type TorBridgesSuite struct{}
+
+var _ = Suite(&TorBridgesSuite{})
+
+const (
+	testObfs4Bridge     = "obfs4 192.0.2.1:443 0123456789ABCDEF0123456789ABCDEF01234567 cert=dGVzdA iat-mode=0"
+	testSnowflakeBridge = "snowflake 192.0.2.3:80 2B280B23E1107BB62ABFC40DDCC8824814F80A72 fingerprint=2B280B23E1107BB62ABFC40DDCC8824814F80A72 url=https://snowflake-broker.torproject.net/"
+)
+
+func (s *TorBridgesSuite) Test_ParseBridges_normalizesTheLines(c *C) {
+	bridges, err := ParseBridges("Bridge " + testObfs4Bridge + "\n\n# a comment\n  meek 192.0.2.2:2 url=https://meek.example/\n192.0.2.4:9001\n")
+
+	c.Assert(err, IsNil)
+	c.Assert(bridges, DeepEquals, []string{
+		testObfs4Bridge,
+		"meek_lite 192.0.2.2:2 url=https://meek.example/",
+		"192.0.2.4:9001",
+	})
+}
+
+func (s *TorBridgesSuite) Test_ParseBridges_rejectsInvalidLines(c *C) {
+	for _, l := range []string{
+		"unknown 192.0.2.1:443",
+		"obfs4 cert=dGVzdA",
+		"obfs4 192.0.2.1:443 nofingerprint",
+		"192.0.2.1:99999",
+	} {
+		_, err := ParseBridges(l)
+		c.Assert(failure.Is(err, ErrInvalidBridge), Equals, true, Commentf("line: %s", l))
+	}
+}
+
+func (s *TorBridgesSuite) Test_bridgesConfiguration_isEmptyWithoutBridges(c *C) {
+	conf, err := bridgesConfiguration(nil, nil)
+
+	c.Assert(err, IsNil)
+	c.Assert(conf, Equals, "")
+}
+
+func (s *TorBridgesSuite) Test_bridgesConfiguration_usesThePluggableTransports(c *C) {
+	dir, err := ioutil.TempDir("", "wahay-bridges")
+	c.Assert(err, IsNil)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	lyrebird := filepath.Join(dir, "lyrebird")
+	snowflake := filepath.Join(dir, "snowflake-client")
+	c.Assert(ioutil.WriteFile(lyrebird, []byte{}, 0700), IsNil)
+	c.Assert(ioutil.WriteFile(snowflake, []byte{}, 0700), IsNil)
+
+	conf, err := bridgesConfiguration([]string{
+		testObfs4Bridge,
+		testSnowflakeBridge,
+		"meek_lite 192.0.2.2:2 url=https://meek.example/",
+	}, []string{dir})
+
+	c.Assert(err, IsNil)
+	c.Assert(conf, Equals, "\n## Connect to Tor through bridges\n"+
+		"UseBridges 1\n"+
+		"Bridge "+testObfs4Bridge+"\n"+
+		"Bridge "+testSnowflakeBridge+"\n"+
+		"Bridge meek_lite 192.0.2.2:2 url=https://meek.example/\n"+
+		"ClientTransportPlugin obfs4,meek_lite exec "+lyrebird+"\n"+
+		"ClientTransportPlugin snowflake exec "+snowflake+"\n")
+}
+
+func (s *TorBridgesSuite) Test_parseBuiltinBridges_returnsTheBridgesOfTheTransport(c *C) {
+	content := []byte(`{"obfs4": ["` + testObfs4Bridge + `", "invalid"], "meek": ["meek_lite 192.0.2.2:2 url=https://meek.example/"]}`)
+
+	bridges, err := parseBuiltinBridges(content, TransportObfs4)
+	c.Assert(err, IsNil)
+	c.Assert(bridges, DeepEquals, []string{testObfs4Bridge})
+
+	bridges, err = parseBuiltinBridges(content, TransportMeek)
+	c.Assert(err, IsNil)
+	c.Assert(bridges, DeepEquals, []string{"meek_lite 192.0.2.2:2 url=https://meek.example/"})
+
+	_, err = parseBuiltinBridges(content, TransportSnowflake)
+	c.Assert(failure.Is(err, ErrBridgesNotAvailable), Equals, true)
+}
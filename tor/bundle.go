@@ -0,0 +1,561 @@
+package tor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// When there is no usable Tor in the system, Wahay can install the Tor
+// expert bundle of the Tor Project in its data directory. What to install
+// is described by a manifest signed with the key Wahay is built with, and
+// the manifest has the SHA-256 digest of the bundle for every system.
+// The digests of the unpacked files are kept, so they are checked again
+// every time before running Tor. Once Tor is running, a newer manifest is
+// looked for over Tor and the new version is used from the next start.
+
+// The manifest of the Tor bundle and the ed25519 public key that signs it,
+// encoded in base64. They are set when building, from the TOR_BUNDLE_MANIFEST_URL
+// and TOR_BUNDLE_SIGNING_KEY variables of the Makefile. Without them
+// Tor is never installed
+var (
+	torBundleManifestURL string
+	torBundleSigningKey  string
+)
+
+const (
+	torBundleDir = "wahay/tor-bundle"
+
+	// torBundleStateFile keeps the version and the digests of the
+	// unpacked files, next to them
+	torBundleStateFile = "bundle.json"
+
+	// torBundleSignatureSuffix is added to the URL of the
+	// manifest for getting its detached signature
+	torBundleSignatureSuffix = ".sig"
+
+	// torBundleArchivePrefix is the directory of the archive with
+	// Tor, its libraries and the pluggable transports
+	torBundleArchivePrefix = "tor/"
+
+	maxTorBundleManifestSize = 64 * 1024
+	maxTorBundleSize         = 128 * 1024 * 1024
+	torBundleRequestTimeout  = 5 * time.Minute
+)
+
+var (
+	// ErrTorBundleNotAvailable is returned when there is no Tor
+	// bundle that Wahay can install in this system
+	ErrTorBundleNotAvailable = failure.New("tor.bundle-not-available", failure.CategoryTor, "there is no known Tor to install for this system", "install Tor in the system")
+
+	// ErrTorBundleDownloadFailed is returned when the Tor bundle or its manifest can't be downloaded
+	ErrTorBundleDownloadFailed = failure.New("tor.bundle-download-failed", failure.CategoryTor, "Tor couldn't be downloaded", "check your connection to the Internet, or install Tor in the system")
+
+	// ErrTorBundleInvalid is returned when the signature or the digest of the downloaded Tor is not the expected one
+	ErrTorBundleInvalid = failure.New("tor.bundle-invalid", failure.CategoryTor, "the downloaded Tor is not the expected one", "")
+)
+
+// torBundleManifest describes the latest Tor bundle, with
+// the file to download for every system, like "linux-amd64"
+type torBundleManifest struct {
+	Version string                   `json:"version"`
+	Files   map[string]torBundleFile `json:"files"`
+}
+
+type torBundleFile struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// torBundleState is what is installed, with the
+// digest of every file, by its relative path
+type torBundleState struct {
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// torBundleFetcher downloads the content of the URL into w, failing
+// when it's bigger than the limit
+type torBundleFetcher func(u string, w io.Writer, limit int64) error
+
+func torBundleSystem() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+func torBundlePath() string {
+	return filepath.Join(config.XdgDataHome(), torBundleDir)
+}
+
+func torBundlePublicKey() (ed25519.PublicKey, bool) {
+	if torBundleManifestURL == "" {
+		return nil, false
+	}
+
+	k, err := base64.StdEncoding.DecodeString(torBundleSigningKey)
+	if err != nil || len(k) != ed25519.PublicKeySize {
+		return nil, false
+	}
+
+	return ed25519.PublicKey(k), true
+}
+
+// CanInstallTorBundle returns true if Wahay knows where
+// to find a Tor bundle and how to verify it
+func CanInstallTorBundle() bool {
+	_, ok := torBundlePublicKey()
+	return ok && runtime.GOOS == "linux"
+}
+
+// fetchTorBundleManifest downloads the manifest and its detached
+// signature, returning the manifest only if the signature is valid
+func fetchTorBundleManifest(fetch torBundleFetcher) (*torBundleManifest, error) {
+	key, ok := torBundlePublicKey()
+	if !ok {
+		return nil, ErrTorBundleNotAvailable
+	}
+
+	var content, signature strings.Builder
+	err := fetch(torBundleManifestURL, &content, maxTorBundleManifestSize)
+	if err != nil {
+		return nil, ErrTorBundleDownloadFailed.Wrap(err)
+	}
+
+	err = fetch(torBundleManifestURL+torBundleSignatureSuffix, &signature, maxTorBundleManifestSize)
+	if err != nil {
+		return nil, ErrTorBundleDownloadFailed.Wrap(err)
+	}
+
+	return parseTorBundleManifest(key, []byte(content.String()), []byte(signature.String()))
+}
+
+// parseTorBundleManifest checks the signature of the manifest, which can be
+// given in raw bytes or encoded in base64, before reading anything of it
+func parseTorBundleManifest(key ed25519.PublicKey, content, signature []byte) (*torBundleManifest, error) {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return nil, ErrTorBundleInvalid.Wrapf("the signature of the manifest is not valid: %s", err)
+		}
+		signature = decoded
+	}
+
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(key, content, signature) {
+		return nil, ErrTorBundleInvalid.Wrapf("the signature of the manifest doesn't match")
+	}
+
+	m := &torBundleManifest{}
+	err := json.Unmarshal(content, m)
+	if err != nil {
+		return nil, ErrTorBundleInvalid.Wrap(err)
+	}
+
+	return m, nil
+}
+
+// fileFor returns the bundle for the given system
+func (m *torBundleManifest) fileFor(system string) (torBundleFile, error) {
+	f, ok := m.Files[system]
+	if !ok || f.URL == "" {
+		return f, ErrTorBundleNotAvailable.Wrapf("no Tor bundle for %s", system)
+	}
+
+	d, err := hex.DecodeString(f.SHA256)
+	if err != nil || len(d) != sha256.Size {
+		return f, ErrTorBundleInvalid.Wrapf("invalid digest for %s", system)
+	}
+
+	return f, nil
+}
+
+// installTorBundle downloads the bundle of the manifest, verifies it and
+// unpacks it in the data directory of Wahay, replacing the one installed
+func installTorBundle(fetch torBundleFetcher, m *torBundleManifest) error {
+	f, err := m.fileFor(torBundleSystem())
+	if err != nil {
+		return err
+	}
+
+	dir := torBundlePath()
+	config.EnsureDir(filepath.Dir(dir), 0700)
+
+	archive, err := ioutil.TempFile(filepath.Dir(dir), "tor-bundle.")
+	if err != nil {
+		return ErrTorBundleDownloadFailed.Wrap(err)
+	}
+
+	// Nothing is left behind when the installation fails
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archive.Name())
+	}()
+
+	h := sha256.New()
+	err = fetch(f.URL, io.MultiWriter(archive, h), maxTorBundleSize)
+	if err != nil {
+		return ErrTorBundleDownloadFailed.Wrap(err)
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), f.SHA256) {
+		return ErrTorBundleInvalid.Wrapf("the digest of %s doesn't match", f.URL)
+	}
+
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return ErrTorBundleInvalid.Wrap(err)
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), "tor-bundle-new.")
+	if err != nil {
+		return ErrTorBundleInvalid.Wrap(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	state, err := unpackTorBundle(archive, tmp)
+	if err != nil {
+		return err
+	}
+	state.Version = m.Version
+
+	err = writeTorBundleState(tmp, state)
+	if err != nil {
+		return ErrTorBundleInvalid.Wrap(err)
+	}
+
+	_ = os.RemoveAll(dir)
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		return ErrTorBundleInvalid.Wrap(err)
+	}
+
+	log.Infof("Tor %s installed in: %s", m.Version, dir)
+
+	return nil
+}
+
+// unpackTorBundle extracts the files of the Tor directory of the archive
+// into dir, returning their digests. Only regular files and links to files
+// in the same directory are accepted, and every path is checked to stay
+// in dir once its links are followed, so nothing is written out of dir
+func unpackTorBundle(r io.Reader, dir string) (*torBundleState, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, ErrTorBundleInvalid.Wrap(err)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrTorBundleInvalid.Wrap(err)
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+
+	state := &torBundleState{Files: map[string]string{}}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrTorBundleInvalid.Wrap(err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, ErrTorBundleInvalid.Wrapf("invalid file in the bundle: %s", hdr.Name)
+		}
+
+		if !strings.HasPrefix(name, torBundleArchivePrefix) {
+			continue
+		}
+
+		rel := strings.TrimPrefix(name, torBundleArchivePrefix)
+
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if !resolvesInside(root, target) {
+			return nil, ErrTorBundleInvalid.Wrapf("invalid file in the bundle: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			config.EnsureDir(target, 0700)
+
+		case tar.TypeSymlink:
+			if !isTorBundleLinkName(hdr.Linkname) || !resolvesInside(root, filepath.Join(filepath.Dir(target), hdr.Linkname)) {
+				return nil, ErrTorBundleInvalid.Wrapf("invalid link in the bundle: %s", hdr.Name)
+			}
+			config.EnsureDir(filepath.Dir(target), 0700)
+			err = os.Symlink(hdr.Linkname, target)
+			if err != nil {
+				return nil, ErrTorBundleInvalid.Wrap(err)
+			}
+
+		case tar.TypeReg:
+			config.EnsureDir(filepath.Dir(target), 0700)
+			digest, err := writeTorBundleFile(target, tr, os.FileMode(hdr.Mode)&0700|0600)
+			if err != nil {
+				return nil, ErrTorBundleInvalid.Wrap(err)
+			}
+			state.Files[rel] = digest
+
+		default:
+			return nil, ErrTorBundleInvalid.Wrapf("invalid file in the bundle: %s", hdr.Name)
+		}
+	}
+
+	if _, ok := state.Files["tor"]; !ok {
+		return nil, ErrTorBundleInvalid.Wrapf("the bundle doesn't contain Tor")
+	}
+
+	return state, nil
+}
+
+// isTorBundleLinkName returns whether the target of a link is a file
+// in the same directory, which "." and ".." are not
+func isTorBundleLinkName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// resolvesInside returns whether the path is still in the root directory
+// once the links in it are followed. Only the part of the path that
+// already exists can be resolved
+func resolvesInside(root, p string) bool {
+	existing, rest := p, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			rel, err := filepath.Rel(root, filepath.Join(resolved, rest))
+			return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+func writeTorBundleFile(target string, r io.Reader, mode os.FileMode) (string, error) {
+	f, err := os.OpenFile(filepath.Clean(target), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), io.LimitReader(r, maxTorBundleSize))
+	cerr := f.Close()
+	if err != nil {
+		return "", err
+	}
+	if cerr != nil {
+		return "", cerr
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeTorBundleState(dir string, s *torBundleState) error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, torBundleStateFile), content, 0600)
+}
+
+func readTorBundleState(dir string) (*torBundleState, error) {
+	content, err := ioutil.ReadFile(filepath.Clean(filepath.Join(dir, torBundleStateFile)))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &torBundleState{}
+	err = json.Unmarshal(content, s)
+	return s, err
+}
+
+// verifyTorBundle checks that the unpacked files are the ones installed, so
+// a Tor changed since the installation is never run. It returns the version
+func verifyTorBundle(dir string) (string, bool) {
+	s, err := readTorBundleState(dir)
+	if err != nil || len(s.Files) == 0 {
+		return "", false
+	}
+
+	for rel, digest := range s.Files {
+		f, err := os.Open(filepath.Clean(filepath.Join(dir, filepath.FromSlash(rel))))
+		if err != nil {
+			return "", false
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		_ = f.Close()
+		if err != nil || !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), digest) {
+			log.WithFields(log.Fields{
+				"context": "tor-bundle",
+				"file":    rel,
+			}).Warning("a file of the installed Tor has changed")
+			return "", false
+		}
+	}
+
+	return s.Version, true
+}
+
+// findTorBinaryInBundle returns the Tor installed by Wahay,
+// removing it when its files have changed since the installation
+func findTorBinaryInBundle() (b *binary, fatalErr error) {
+	dir := torBundlePath()
+	if !CanInstallTorBundle() || !filesystemf.FileExists(filepath.Join(dir, torBundleStateFile)) {
+		return nil, nil
+	}
+
+	log.Debugf("findTorBinaryInBundle(%s)", dir)
+
+	if _, ok := verifyTorBundle(dir); !ok {
+		log.Warningf("The installed Tor at %s is not the expected one, removing it", dir)
+		_ = os.RemoveAll(dir)
+		return nil, nil
+	}
+
+	b, _ = getBinaryForPath(filepath.Join(dir, torExecutable))
+	if b != nil && b.isValid {
+		// The libraries of the bundle are next to the binary
+		b.isBundle = true
+		b.env = []string{libraryPathEnv(dir)}
+	}
+
+	return b, nil
+}
+
+// installTorBundleWhenNeeded installs the Tor bundle when no other Tor can
+// be used. It's downloaded without Tor, so the network can see the request,
+// and the signature of the manifest protects what is downloaded
+func installTorBundleWhenNeeded() (*binary, error) {
+	if !CanInstallTorBundle() {
+		return nil, ErrTorBinaryNotFound
+	}
+
+	log.Info("No usable Tor was found, installing the Tor bundle")
+
+	m, err := fetchTorBundleManifest(fetchWithoutTor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = installTorBundle(fetchWithoutTor, m)
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := findTorBinaryInBundle()
+	if b == nil || !b.isValid {
+		return nil, ErrTorBundleInvalid.Wrapf("the installed Tor can't be run")
+	}
+
+	return b, nil
+}
+
+// updateTorBundle installs the newest Tor bundle, downloading it
+// over the running Tor. The new version is used from the next start
+func updateTorBundle(i Instance) {
+	installed, ok := verifyTorBundle(torBundlePath())
+	if !ok {
+		return
+	}
+
+	fetch := func(u string, w io.Writer, limit int64) error {
+		_, err := i.HTTPDownload(u, w, limit, nil)
+		return err
+	}
+
+	m, err := fetchTorBundleManifest(fetch)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "tor-bundle",
+		}).Debugf("the newest Tor bundle can't be known: %s", err)
+		return
+	}
+
+	if !isNewerTorBundleVersion(m.Version, installed) {
+		return
+	}
+
+	log.Infof("Updating the installed Tor from %s to %s", installed, m.Version)
+
+	// The running Tor keeps its files open, so they can be replaced
+	err = installTorBundle(fetch, m)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"context": "tor-bundle",
+		}).Errorf("the installed Tor can't be updated: %s", err)
+	}
+}
+
+// isNewerTorBundleVersion compares all the numbers of
+// the Tor versions, like 0.4.8.12 and 0.4.8.9
+func isNewerTorBundleVersion(v, installed string) bool {
+	a := strings.Split(v, ".")
+	b := strings.Split(installed, ".")
+
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := 0, 0
+		if i < len(a) {
+			x, _ = strconv.Atoi(a[i])
+		}
+		if i < len(b) {
+			y, _ = strconv.Atoi(b[i])
+		}
+		if x != y {
+			return x > y
+		}
+	}
+
+	return false
+}
+
+func fetchWithoutTor(u string, w io.Writer, limit int64) error {
+	client := &http.Client{Timeout: torBundleRequestTimeout}
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	_, err = copyWithLimit(w, resp.Body, limit, resp.ContentLength, nil)
+	return err
+}
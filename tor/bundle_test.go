@@ -0,0 +1,227 @@
+package tor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"golang.org/x/crypto/ed25519"
+	. "gopkg.in/check.v1"
+)
+
+type TorBundleSuite struct {
+	dir     string
+	private ed25519.PrivateKey
+}
+
+var _ = Suite(&TorBundleSuite{})
+
+func (s *TorBundleSuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "wahay-bundle")
+	c.Assert(err, IsNil)
+
+	public, private, err := ed25519.GenerateKey(nil)
+	c.Assert(err, IsNil)
+	s.private = private
+
+	torBundleManifestURL = "https://example.org/tor-bundle.json"
+	torBundleSigningKey = base64.StdEncoding.EncodeToString(public)
+	c.Assert(os.Setenv("XDG_DATA_HOME", s.dir), IsNil)
+}
+
+func (s *TorBundleSuite) TearDownTest(c *C) {
+	torBundleManifestURL = ""
+	torBundleSigningKey = ""
+	_ = os.Unsetenv("XDG_DATA_HOME")
+	_ = os.RemoveAll(s.dir)
+}
+
+type testArchiveFile struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+func testTorArchive(c *C, files []testArchiveFile) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.name,
+			Mode:     0755,
+			Size:     int64(len(f.content)),
+			Typeflag: f.typeflag,
+			Linkname: f.linkname,
+		}
+		if f.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		c.Assert(tw.WriteHeader(hdr), IsNil)
+		_, err := tw.Write([]byte(f.content))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(tw.Close(), IsNil)
+	c.Assert(gz.Close(), IsNil)
+
+	return buf.Bytes()
+}
+
+func fetcherFor(contents map[string][]byte) torBundleFetcher {
+	return func(u string, w io.Writer, limit int64) error {
+		content, ok := contents[u]
+		if !ok {
+			return errors.New("not found")
+		}
+		_, err := w.Write(content)
+		return err
+	}
+}
+
+func (s *TorBundleSuite) Test_parseTorBundleManifest_checksTheSignature(c *C) {
+	content := []byte(`{"version": "0.4.8.12", "files": {"linux-amd64": {"url": "https://example.org/tor.tar.gz", "sha256": "00"}}}`)
+	signature := ed25519.Sign(s.private, content)
+	key, ok := torBundlePublicKey()
+	c.Assert(ok, Equals, true)
+
+	m, err := parseTorBundleManifest(key, content, signature)
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "0.4.8.12")
+
+	m, err = parseTorBundleManifest(key, content, []byte(base64.StdEncoding.EncodeToString(signature)+"\n"))
+	c.Assert(err, IsNil)
+	c.Assert(m.Version, Equals, "0.4.8.12")
+
+	_, err = parseTorBundleManifest(key, append(content, ' '), signature)
+	c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true)
+
+	_, err = m.fileFor("linux-amd64")
+	c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true)
+
+	_, err = m.fileFor("plan9-386")
+	c.Assert(failure.Is(err, ErrTorBundleNotAvailable), Equals, true)
+}
+
+func (s *TorBundleSuite) Test_unpackTorBundle_rejectsFilesOutOfTheDirectory(c *C) {
+	for _, f := range []testArchiveFile{
+		{name: "tor/../../evil", content: "x", typeflag: tar.TypeReg},
+		{name: "tor/libevil.so", typeflag: tar.TypeSymlink, linkname: "/usr/lib/libevil.so"},
+	} {
+		archive := testTorArchive(c, []testArchiveFile{{name: "tor/tor", content: "tor", typeflag: tar.TypeReg}, f})
+
+		_, err := unpackTorBundle(bytes.NewReader(archive), c.MkDir())
+		c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true, Commentf("file: %s", f.name))
+	}
+
+	archive := testTorArchive(c, []testArchiveFile{{name: "data/geoip", content: "geoip", typeflag: tar.TypeReg}})
+	_, err := unpackTorBundle(bytes.NewReader(archive), c.MkDir())
+	c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true)
+}
+
+func (s *TorBundleSuite) Test_unpackTorBundle_rejectsTheLinksOutOfTheDirectory(c *C) {
+	for _, files := range [][]testArchiveFile{
+		{{name: "tor/l", typeflag: tar.TypeSymlink, linkname: ".."}, {name: "tor/l/x", content: "x", typeflag: tar.TypeReg}},
+		{{name: "tor/l", typeflag: tar.TypeSymlink, linkname: "."}},
+		{{name: "tor/l", typeflag: tar.TypeSymlink, linkname: ""}},
+		{{name: "tor/l", typeflag: tar.TypeSymlink, linkname: `..\x`}},
+	} {
+		archive := testTorArchive(c, append([]testArchiveFile{{name: "tor/tor", content: "tor", typeflag: tar.TypeReg}}, files...))
+
+		parent := c.MkDir()
+		dir := filepath.Join(parent, "tor")
+		c.Assert(os.Mkdir(dir, 0700), IsNil)
+
+		_, err := unpackTorBundle(bytes.NewReader(archive), dir)
+		c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true, Commentf("link: %q", files[0].linkname))
+
+		_, err = os.Lstat(filepath.Join(parent, "x"))
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+func (s *TorBundleSuite) Test_unpackTorBundle_rejectsFilesThroughALinkOutOfTheDirectory(c *C) {
+	parent := c.MkDir()
+	dir := filepath.Join(parent, "tor")
+	c.Assert(os.Mkdir(dir, 0700), IsNil)
+	c.Assert(os.Symlink(parent, filepath.Join(dir, "l")), IsNil)
+
+	archive := testTorArchive(c, []testArchiveFile{
+		{name: "tor/tor", content: "tor", typeflag: tar.TypeReg},
+		{name: "tor/l/x", content: "x", typeflag: tar.TypeReg},
+	})
+
+	_, err := unpackTorBundle(bytes.NewReader(archive), dir)
+	c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true)
+
+	_, err = os.Lstat(filepath.Join(parent, "x"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *TorBundleSuite) Test_installTorBundle_verifiesAndUnpacksTheBundle(c *C) {
+	archive := testTorArchive(c, []testArchiveFile{
+		{name: "tor/", typeflag: tar.TypeDir},
+		{name: "tor/tor", content: "the tor binary", typeflag: tar.TypeReg},
+		{name: "tor/libevent-2.1.so.7", content: "a library", typeflag: tar.TypeReg},
+		{name: "tor/libevent.so", typeflag: tar.TypeSymlink, linkname: "libevent-2.1.so.7"},
+		{name: "tor/pluggable_transports/lyrebird", content: "lyrebird", typeflag: tar.TypeReg},
+		{name: "data/geoip", content: "geoip", typeflag: tar.TypeReg},
+	})
+	digest := sha256.Sum256(archive)
+
+	m := &torBundleManifest{
+		Version: "0.4.8.12",
+		Files: map[string]torBundleFile{
+			torBundleSystem(): {URL: "https://example.org/tor.tar.gz", SHA256: hex.EncodeToString(digest[:])},
+		},
+	}
+
+	err := installTorBundle(fetcherFor(map[string][]byte{"https://example.org/tor.tar.gz": append([]byte{}, archive...)}), m)
+	c.Assert(err, IsNil)
+
+	dir := torBundlePath()
+	content, err := ioutil.ReadFile(filepath.Join(dir, "pluggable_transports", "lyrebird"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "lyrebird")
+	c.Assert(filesystemf.FileExists(filepath.Join(dir, "geoip")), Equals, false)
+
+	version, ok := verifyTorBundle(dir)
+	c.Assert(ok, Equals, true)
+	c.Assert(version, Equals, "0.4.8.12")
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "tor"), []byte("changed"), 0700), IsNil)
+	_, ok = verifyTorBundle(dir)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *TorBundleSuite) Test_installTorBundle_failsWhenTheDigestDoesntMatch(c *C) {
+	m := &torBundleManifest{
+		Version: "0.4.8.12",
+		Files: map[string]torBundleFile{
+			torBundleSystem(): {URL: "https://example.org/tor.tar.gz", SHA256: hex.EncodeToString(make([]byte, sha256.Size))},
+		},
+	}
+
+	err := installTorBundle(fetcherFor(map[string][]byte{"https://example.org/tor.tar.gz": []byte("something else")}), m)
+	c.Assert(failure.Is(err, ErrTorBundleInvalid), Equals, true)
+	c.Assert(filesystemf.FileExists(torBundlePath()), Equals, false)
+}
+
+func (s *TorBundleSuite) Test_isNewerTorBundleVersion_comparesAllTheNumbers(c *C) {
+	c.Assert(isNewerTorBundleVersion("0.4.8.12", "0.4.8.9"), Equals, true)
+	c.Assert(isNewerTorBundleVersion("0.4.9", "0.4.8.12"), Equals, true)
+	c.Assert(isNewerTorBundleVersion("0.4.8.9", "0.4.8.9"), Equals, false)
+	c.Assert(isNewerTorBundleVersion("0.4.7.16", "0.4.8.9"), Equals, false)
+}
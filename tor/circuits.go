@@ -0,0 +1,183 @@
+package tor
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wybiral/torgo"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// NEWNYM makes Tor use new circuits for the new connections, but the
+// connection Mumble already has keeps the old one. So the circuits to the
+// onion service of the meeting are also closed, and Mumble, which
+// reconnects by itself when the connection is lost, gets a new one.
+
+// ErrCircuitsNotRenewed is returned when Tor doesn't accept
+// the commands for building new circuits
+var ErrCircuitsNotRenewed = failure.New("tor.circuits-not-renewed", failure.CategoryTor, "the Tor circuits can't be renewed", "")
+
+func (cntrl *controller) RenewCircuits(serviceID string) error {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	tc, err := cntrl.authenticatedTorController()
+	if err != nil {
+		return err
+	}
+
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return ErrCircuitsNotRenewed
+	}
+
+	_, err = controlCommand(c, "SIGNAL NEWNYM")
+	if err != nil {
+		return ErrCircuitsNotRenewed.Wrap(err)
+	}
+
+	if serviceID == "" {
+		return nil
+	}
+
+	status, err := controlDataCommand(c, "GETINFO circuit-status")
+	if err != nil {
+		return ErrCircuitsNotRenewed.Wrap(err)
+	}
+
+	circuits := circuitsTo(status, strings.TrimSuffix(serviceID, ".onion"))
+	for _, id := range circuits {
+		_, err = controlCommand(c, "CLOSECIRCUIT %s", id)
+		if err != nil {
+			// The circuit might have been closed in the meantime
+			log.WithFields(log.Fields{
+				"context": "circuits",
+				"circuit": id,
+			}).Debugf("the circuit can't be closed: %s", err)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"context":  "circuits",
+		"closedTo": len(circuits),
+	}).Debug("The Tor circuits have been renewed")
+
+	return nil
+}
+
+// ResetIntroductionPoints makes our onion service use new introduction
+// points. Tor uploads a new descriptor when they change, so the service
+// is published again, without touching the circuits of the clients
+func (cntrl *controller) ResetIntroductionPoints(serviceID string) error {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	tc, err := cntrl.authenticatedTorController()
+	if err != nil {
+		return err
+	}
+
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return ErrCircuitsNotRenewed
+	}
+
+	status, err := controlDataCommand(c, "GETINFO circuit-status")
+	if err != nil {
+		return ErrCircuitsNotRenewed.Wrap(err)
+	}
+
+	circuits := circuitsWithPurpose(status, strings.TrimSuffix(serviceID, ".onion"), "HS_SERVICE_INTRO")
+	for _, id := range circuits {
+		_, err = controlCommand(c, "CLOSECIRCUIT %s", id)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"context": "circuits",
+				"circuit": id,
+			}).Debugf("the circuit can't be closed: %s", err)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"context":     "circuits",
+		"introPoints": len(circuits),
+	}).Debug("The introduction points of the onion service have been reset")
+
+	return nil
+}
+
+// circuitsTo returns the identifiers of the circuits used for reaching the
+// onion service, given the circuit-status of Tor, which contains lines like
+// "ID STATUS PATH BUILD_FLAGS=... PURPOSE=HS_CLIENT_REND HS_STATE=... REND_QUERY=address"
+func circuitsTo(status []string, address string) []string {
+	return circuitsWithPurpose(status, address, "HS_CLIENT")
+}
+
+// circuitsWithPurpose returns the identifiers of the circuits of the
+// onion service with a purpose that starts with the given one
+func circuitsWithPurpose(status []string, address, purpose string) []string {
+	var result []string
+	for _, l := range status {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if eventHasValue(fields, "REND_QUERY", address) && strings.HasPrefix(eventValue(fields, "PURPOSE"), purpose) {
+			result = append(result, fields[0])
+		}
+	}
+	return result
+}
+
+func eventValue(fields []string, key string) string {
+	for _, f := range fields {
+		if strings.HasPrefix(f, key+"=") {
+			return strings.TrimPrefix(f, key+"=")
+		}
+	}
+	return ""
+}
+
+// controlDataCommand sends a command whose reply can have several lines
+// of data, like GETINFO of the lists, and returns those lines
+func controlDataCommand(c *torgo.Controller, format string, args ...interface{}) ([]string, error) {
+	id, err := c.Text.Cmd(format, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Text.StartResponse(id)
+	defer c.Text.EndResponse(id)
+
+	var result []string
+	for {
+		l, err := c.Text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(l) < 4 || !strings.HasPrefix(l, "250") {
+			return nil, fmt.Errorf("unexpected reply: %s", l)
+		}
+
+		switch l[3] {
+		case ' ':
+			return result, nil
+		case '-':
+			if kv := strings.SplitN(l[4:], "=", 2); len(kv) == 2 && kv[1] != "" {
+				result = append(result, kv[1])
+			}
+		case '+':
+			lines, err := c.Text.ReadDotLines()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, lines...)
+		default:
+			return nil, fmt.Errorf("unexpected reply: %s", l)
+		}
+	}
+}
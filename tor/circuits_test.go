@@ -0,0 +1,64 @@
+package tor
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TorCircuitsSuite struct{}
+
+var _ = Suite(&TorCircuitsSuite{})
+
+func (s *TorCircuitsSuite) Test_circuitsTo_returnsTheCircuitsOfTheOnionService(c *C) {
+	status := []string{
+		"1 BUILT $AAAA~relay1,$BBBB~relay2 BUILD_FLAGS=NEED_CAPACITY PURPOSE=GENERAL TIME_CREATED=2020-01-01T00:00:00.000000",
+		"2 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_CLIENT_REND HS_STATE=HSCR_JOINED REND_QUERY=abcdef",
+		"3 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_CLIENT_INTRO HS_STATE=HSCI_DONE REND_QUERY=abcdef",
+		"4 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_CLIENT_REND HS_STATE=HSCR_JOINED REND_QUERY=other",
+		"5 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_SERVICE_REND HS_STATE=HSSR_JOINED REND_QUERY=abcdef",
+	}
+
+	c.Assert(circuitsTo(status, "abcdef"), DeepEquals, []string{"2", "3"})
+	c.Assert(circuitsTo(nil, "abcdef"), IsNil)
+}
+
+func (s *TorCircuitsSuite) Test_circuitsWithPurpose_returnsTheIntroductionCircuitsOfOurOnionService(c *C) {
+	status := []string{
+		"1 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_SERVICE_INTRO HS_STATE=HSSI_ESTABLISHED REND_QUERY=abcdef",
+		"2 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_SERVICE_REND HS_STATE=HSSR_JOINED REND_QUERY=abcdef",
+		"3 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_SERVICE_INTRO HS_STATE=HSSI_ESTABLISHED REND_QUERY=other",
+		"4 BUILT $AAAA~relay1 BUILD_FLAGS=IS_INTERNAL PURPOSE=HS_CLIENT_INTRO HS_STATE=HSCI_DONE REND_QUERY=abcdef",
+	}
+
+	c.Assert(circuitsWithPurpose(status, "abcdef", "HS_SERVICE_INTRO"), DeepEquals, []string{"1"})
+}
+
+func (s *TorCircuitsSuite) Test_controlDataCommand_readsTheLinesOfData(c *C) {
+	tc, received := fakeController("250+circuit-status=", "1 BUILT PURPOSE=GENERAL", "2 BUILT PURPOSE=GENERAL", ".", "250 OK")
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	lines, err := controlDataCommand(tc, "GETINFO circuit-status")
+	c.Assert(err, IsNil)
+	c.Assert(lines, DeepEquals, []string{"1 BUILT PURPOSE=GENERAL", "2 BUILT PURPOSE=GENERAL"})
+	c.Assert(<-received, Equals, "GETINFO circuit-status")
+}
+
+func (s *TorCircuitsSuite) Test_controlDataCommand_acceptsAnEmptyList(c *C) {
+	tc, _ := fakeController("250-circuit-status=", "250 OK")
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	lines, err := controlDataCommand(tc, "GETINFO circuit-status")
+	c.Assert(err, IsNil)
+	c.Assert(lines, HasLen, 0)
+}
+
+func (s *TorCircuitsSuite) Test_RenewCircuits_failsWithoutARealController(c *C) {
+	cntrl := &controller{
+		c: &mockTorgoController{},
+	}
+
+	c.Assert(cntrl.RenewCircuits("abcdef.onion"), Equals, ErrCircuitsNotRenewed)
+}
@@ -0,0 +1,191 @@
+package tor
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/wybiral/torgo"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The client authorization of the version 3 onion services uses x25519
+// keys. The service knows the public key of every authorized client, and
+// only the clients with one of the private keys can reach it
+
+const clientAuthKeyLength = 32
+
+var (
+	// ErrInvalidClientAuthKey is returned when a client authorization key doesn't have the right format
+	ErrInvalidClientAuthKey = failure.New("tor.invalid-client-auth-key", failure.CategoryInput, "the client authorization key is not valid", "copy the complete invitation, as given by the host")
+
+	// ErrClientAuthNotSupported is returned when the Tor in use can't do client authorization
+	ErrClientAuthNotSupported = failure.New("tor.client-auth-not-supported", failure.CategoryTor, "the Tor in use doesn't support client authorization", "install Tor 0.4.6 or newer")
+)
+
+var clientAuthEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ClientAuthKey is the key pair that authorizes one participant
+// to reach an onion service
+type ClientAuthKey struct {
+	private []byte
+	public  []byte
+}
+
+// NewClientAuthKey generates a new key for authorizing a participant
+func NewClientAuthKey() (*ClientAuthKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return clientAuthKeyFromSeed(seed), nil
+}
+
+// clientAuthKeyFromSeed derives the x25519 key pair from an ed25519 one.
+// The scalar of both is the same, and the Montgomery form of the ed25519
+// public key is the x25519 public key: u = (1 + y) / (1 - y)
+func clientAuthKeyFromSeed(seed []byte) *ClientAuthKey {
+	edPublic := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+	h := sha512.Sum512(seed)
+	private := h[:clientAuthKeyLength]
+	private[0] &= 248
+	private[31] &= 127
+	private[31] |= 64
+
+	return &ClientAuthKey{
+		private: private,
+		public:  montgomeryFromEdwards(edPublic),
+	}
+}
+
+// curve25519P is the prime of the field of Curve25519: 2^255 - 19
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+func montgomeryFromEdwards(edPublic []byte) []byte {
+	// The point is encoded as y in little endian, with the
+	// sign of x in the highest bit, which is not needed
+	le := make([]byte, len(edPublic))
+	copy(le, edPublic)
+	le[len(le)-1] &= 0x7F
+	y := new(big.Int).SetBytes(reverseBytes(le))
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve25519P)
+	den.ModInverse(den, curve25519P)
+
+	u := num.Mul(num, den)
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, clientAuthKeyLength)
+	b := u.Bytes()
+	copy(out[clientAuthKeyLength-len(b):], b)
+	return reverseBytes(out)
+}
+
+func reverseBytes(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+// PrivateKey returns the private key given to the participant, in
+// the base32 format used in the invitations and in Tor Browser
+func (k *ClientAuthKey) PrivateKey() string {
+	return clientAuthEncoding.EncodeToString(k.private)
+}
+
+// PublicKey returns the public key that the onion service authorizes,
+// in the base32 format used by Tor
+func (k *ClientAuthKey) PublicKey() string {
+	return clientAuthEncoding.EncodeToString(k.public)
+}
+
+func decodeClientAuthKey(key string) ([]byte, error) {
+	k, err := clientAuthEncoding.DecodeString(strings.ToUpper(strings.TrimRight(key, "=")))
+	if err != nil || len(k) != clientAuthKeyLength {
+		return nil, ErrInvalidClientAuthKey
+	}
+	return k, nil
+}
+
+// addOnionWithClientAuth creates the onion service like torgo does,
+// authorizing only the clients with the given public keys
+func addOnionWithClientAuth(tc torgoController, onion *torgo.Onion, clientAuth []string) error {
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return ErrClientAuthNotSupported
+	}
+
+	req := fmt.Sprintf("ADD_ONION %s:%s Flags=V3Auth", onion.PrivateKeyType, onion.PrivateKey)
+	for remotePort, localAddr := range onion.Ports {
+		req += fmt.Sprintf(" Port=%d,%s", remotePort, localAddr)
+	}
+	for _, k := range clientAuth {
+		if _, err := decodeClientAuthKey(k); err != nil {
+			return err
+		}
+		req += " ClientAuthV3=" + k
+	}
+
+	reply, err := controlCommand(c, "%s", req)
+	if err != nil {
+		return ErrClientAuthNotSupported.Wrap(err)
+	}
+
+	for _, l := range strings.Split(reply, "\n") {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) == 2 && kv[0] == "ServiceID" {
+			onion.ServiceID = kv[1]
+		} else if len(kv) == 2 && kv[0] == "PrivateKey" {
+			if t, k, err := parseOnionKey(kv[1]); err == nil {
+				onion.PrivateKeyType, onion.PrivateKey = t, k
+			}
+		}
+	}
+
+	if onion.ServiceID == "" {
+		return fmt.Errorf("invalid reply to ADD_ONION: %s", reply)
+	}
+
+	return nil
+}
+
+// addOnionClientAuth gives Tor the private key for reaching the onion
+// service. It's kept only in memory, until Tor is restarted or it's removed
+func addOnionClientAuth(tc torgoController, serviceID, privateKey string) error {
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return ErrClientAuthNotSupported
+	}
+
+	k, err := decodeClientAuthKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = controlCommand(c, "ONION_CLIENT_AUTH_ADD %s x25519:%s", serviceID, base64.StdEncoding.EncodeToString(k))
+	if err != nil {
+		return ErrClientAuthNotSupported.Wrap(err)
+	}
+	return nil
+}
+
+func removeOnionClientAuth(tc torgoController, serviceID string) error {
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return ErrClientAuthNotSupported
+	}
+
+	_, err := controlCommand(c, "ONION_CLIENT_AUTH_REMOVE %s", serviceID)
+	return err
+}
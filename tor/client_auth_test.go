@@ -0,0 +1,113 @@
+package tor
+
+import (
+	"bytes"
+	"net"
+	"net/textproto"
+
+	"github.com/digitalautonomy/wahay/failure"
+	"github.com/wybiral/torgo"
+	. "gopkg.in/check.v1"
+)
+
+type TorClientAuthSuite struct{}
+
+var _ = Suite(&TorClientAuthSuite{})
+
+// fakeControlPort answers the first command with the given reply lines,
+// sending the command it received
+func fakeControlPort(conn net.Conn, reply []string, received chan<- string) {
+	text := textproto.NewConn(conn)
+	defer func() {
+		_ = text.Close()
+	}()
+
+	l, err := text.ReadLine()
+	if err != nil {
+		received <- ""
+		return
+	}
+
+	for _, r := range reply {
+		_ = text.PrintfLine("%s", r)
+	}
+	received <- l
+}
+
+func fakeController(reply ...string) (*torgo.Controller, <-chan string) {
+	client, server := net.Pipe()
+	received := make(chan string, 1)
+	go fakeControlPort(server, reply, received)
+
+	return &torgo.Controller{Text: textproto.NewConn(client)}, received
+}
+
+func (s *TorClientAuthSuite) Test_clientAuthKeyFromSeed_generatesTheX25519Keys(c *C) {
+	k := clientAuthKeyFromSeed(bytes.Repeat([]byte{7}, 32))
+
+	c.Assert(k.PrivateKey(), Equals, "FCWTT7X5P6R6EAFJYYTO55MZ4YNC2BK4JCUCRCSOPZGEXSRZFB4A")
+	c.Assert(k.PublicKey(), Equals, "OYOYR3EDAQJZDHP6TVGR2VXRPZSTZDEZICBN6WYTPOIKBLTO352A")
+}
+
+func (s *TorClientAuthSuite) Test_NewClientAuthKey_generatesDifferentKeys(c *C) {
+	k1, err := NewClientAuthKey()
+	c.Assert(err, IsNil)
+	k2, err := NewClientAuthKey()
+	c.Assert(err, IsNil)
+
+	c.Assert(k1.PrivateKey(), Not(Equals), k2.PrivateKey())
+	c.Assert(k1.PublicKey(), HasLen, 52)
+}
+
+func (s *TorClientAuthSuite) Test_decodeClientAuthKey_rejectsInvalidKeys(c *C) {
+	for _, k := range []string{"", "not a key", "OYOYR3EDAQJZDHP6TVGR2VXRPZ"} {
+		_, err := decodeClientAuthKey(k)
+		c.Assert(failure.Is(err, ErrInvalidClientAuthKey), Equals, true, Commentf("key: %s", k))
+	}
+
+	_, err := decodeClientAuthKey("oyoyr3edaqjzdhp6tvgr2vxrpzstzdezicbn6wytpoikblto352a")
+	c.Assert(err, IsNil)
+}
+
+func (s *TorClientAuthSuite) Test_addOnionWithClientAuth_authorizesTheKeys(c *C) {
+	tc, received := fakeController("250-ServiceID=abcdef", "250 OK")
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	onion := &torgo.Onion{
+		Ports:          map[int]string{64738: "127.0.0.1:12345"},
+		PrivateKeyType: "NEW",
+		PrivateKey:     "ED25519-V3",
+	}
+
+	err := addOnionWithClientAuth(tc, onion, []string{"OYOYR3EDAQJZDHP6TVGR2VXRPZSTZDEZICBN6WYTPOIKBLTO352A"})
+	c.Assert(err, IsNil)
+	c.Assert(onion.ServiceID, Equals, "abcdef")
+	c.Assert(<-received, Equals, "ADD_ONION NEW:ED25519-V3 Flags=V3Auth Port=64738,127.0.0.1:12345 "+
+		"ClientAuthV3=OYOYR3EDAQJZDHP6TVGR2VXRPZSTZDEZICBN6WYTPOIKBLTO352A")
+}
+
+func (s *TorClientAuthSuite) Test_addOnionClientAuth_sendsThePrivateKey(c *C) {
+	tc, received := fakeController("250 OK")
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	err := addOnionClientAuth(tc, "abcdef", "FCWTT7X5P6R6EAFJYYTO55MZ4YNC2BK4JCUCRCSOPZGEXSRZFB4A")
+	c.Assert(err, IsNil)
+	c.Assert(<-received, Equals, "ONION_CLIENT_AUTH_ADD abcdef x25519:KK05/v1/o+IAqcYm7vWZ5hotBVxIqCiKTn5MS8o5KHg=")
+}
+
+func (s *TorClientAuthSuite) Test_addOnionClientAuth_failsWhenTorDoesntSupportIt(c *C) {
+	tc, _ := fakeController("510 Unrecognized command \"ONION_CLIENT_AUTH_ADD\"")
+	defer func() {
+		_ = tc.Text.Close()
+	}()
+
+	err := addOnionClientAuth(tc, "abcdef", "FCWTT7X5P6R6EAFJYYTO55MZ4YNC2BK4JCUCRCSOPZGEXSRZFB4A")
+	c.Assert(failure.Is(err, ErrClientAuthNotSupported), Equals, true)
+
+	err = addOnionClientAuth(&mockTorgoController{}, "abcdef", "FCWTT7X5P6R6EAFJYYTO55MZ4YNC2BK4JCUCRCSOPZGEXSRZFB4A")
+	c.Assert(err, Equals, ErrClientAuthNotSupported)
+}
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 	log "github.com/sirupsen/logrus"
+	"github.com/wybiral/torgo"
 )
 
 // basicConnectivity is used to check whether Tor can connect in different ways
@@ -20,14 +23,26 @@ type connectivity struct {
 	controlPort int
 	password    string
 	authType    string
+
+	// discoverRoutePort makes the checker ask Tor for its SOCKS
+	// port, keeping routePort only when Tor doesn't tell it
+	discoverRoutePort bool
 }
 
 func newCustomChecker(host string, routePort, controlPort int) basicConnectivity {
 	return newChecker(host, routePort, controlPort, "")
 }
 
-func newDefaultChecker() basicConnectivity {
-	return newChecker(defaultControlHost, defaultSocksPort, defaultControlPort, *config.TorControlPassword)
+// newSystemChecker checks a Tor that is already running, which
+// might not use the default SOCKS port
+func newSystemChecker(host string, routePort, controlPort int, password string) *connectivity {
+	return &connectivity{
+		host:              host,
+		routePort:         routePort,
+		controlPort:       controlPort,
+		password:          password,
+		discoverRoutePort: true,
+	}
 }
 
 // newChecker can check connectivity on custom ports, and optionally
@@ -71,7 +86,7 @@ func (c *connectivity) checkTorControlAuth() bool {
 
 	authCallback := authenticateAny(
 		withNewTorgoController(where, c.settingAuthType("none", authenticateNone)),
-		withNewTorgoController(where, c.settingAuthType("cookie", authenticateCookie)),
+		withNewTorgoController(where, c.settingAuthType("cookie", authenticateCookieFile)),
 		withNewTorgoController(where, c.settingAuthType("password", authenticatePassword(c.password))))
 
 	return authCallback(nil) == nil
@@ -84,7 +99,7 @@ func (c *connectivity) tryAuthenticate(tc torgoController) error {
 	case "password":
 		return authenticatePassword(c.password)(tc)
 	case "cookie":
-		return authenticateCookie(tc)
+		return authenticateCookieFile(tc)
 	default:
 		return errors.New("no valid authentication type")
 	}
@@ -116,7 +131,60 @@ func (c *connectivity) checkControlPortVersion() bool {
 		return false
 	}
 
-	return diff >= 0
+	if diff < 0 {
+		return false
+	}
+
+	if c.discoverRoutePort {
+		if p, ok := socksPortOf(tc); ok {
+			c.routePort = p
+		}
+	}
+
+	return true
+}
+
+// socksPortOf asks Tor for the first SOCKS port it listens on.
+// It's reached on the same host as the control port
+func socksPortOf(tc torgoController) (int, bool) {
+	tgc, ok := tc.(*torgo.Controller)
+	if !ok {
+		return 0, false
+	}
+
+	reply, err := controlCommand(tgc, "GETINFO net/listeners/socks")
+	if err != nil {
+		log.Debugf("socksPortOf() - can't get the SOCKS listeners: %v", err)
+		return 0, false
+	}
+
+	return parseSocksListeners(reply)
+}
+
+// parseSocksListeners reads a reply like:
+// net/listeners/socks="127.0.0.1:9050" "unix:/run/tor/socks"
+func parseSocksListeners(reply string) (int, bool) {
+	kv := strings.SplitN(strings.Split(reply, "\n")[0], "=", 2)
+	if len(kv) != 2 {
+		return 0, false
+	}
+
+	for _, l := range strings.Fields(kv[1]) {
+		if u, err := strconv.Unquote(l); err == nil {
+			l = u
+		}
+
+		_, port, err := net.SplitHostPort(l)
+		if err != nil {
+			continue
+		}
+
+		if p, err := strconv.Atoi(port); err == nil && config.CheckPort(p) {
+			return p, true
+		}
+	}
+
+	return 0, false
 }
 
 type checkTorResult struct {
@@ -131,19 +199,19 @@ func (c *connectivity) checkConnectionOverTor() bool {
 var (
 	// ErrPartialTorNoControlPort is an error to be trown when a valid Tor
 	// control port cannot be found
-	ErrPartialTorNoControlPort = errors.New("no Tor control port found")
+	ErrPartialTorNoControlPort = failure.New("tor.no-control-port", failure.CategoryTor, "no Tor control port found", "check that Tor is running with its control port enabled")
 
 	// ErrPartialTorNoValidAuth is an error to be trown when the system
 	// cannot authenticate to the Tor control port
-	ErrPartialTorNoValidAuth = errors.New("no Tor control port valid authentication")
+	ErrPartialTorNoValidAuth = failure.New("tor.no-valid-authentication", failure.CategoryTor, "no Tor control port valid authentication", "check the authentication configured for the Tor control port")
 
 	// ErrPartialTorTooOld is an error that shows that the control port is running
 	// a version that is too old
-	ErrPartialTorTooOld = errors.New("the Tor control port is running a too old version of Tor")
+	ErrPartialTorTooOld = failure.New("tor.too-old", failure.CategoryTor, "the Tor control port is running a too old version of Tor", "install a newer version of Tor")
 
 	// ErrFatalTorNoConnectionAllowed is a fatal error that it's trown when
 	// the system cannot make a connection over the Tor network
-	ErrFatalTorNoConnectionAllowed = errors.New("no connection over Tor allowed")
+	ErrFatalTorNoConnectionAllowed = failure.New("tor.connection-blocked", failure.CategoryTor, "no connection over Tor allowed", "the Tor network might be blocked in this network")
 )
 
 func (c *connectivity) check() (authType string, errTotal error, errPartial error) {
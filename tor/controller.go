@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/digitalautonomy/wahay/config"
 	log "github.com/sirupsen/logrus"
@@ -20,24 +21,53 @@ type Control interface {
 	SetPassword(string)
 	UseCookieAuth()
 	CreateNewOnionServiceWithMultiplePorts(ports []OnionPort) (serviceID string, err error)
+	CreateNewOnionServiceWithClientAuth(ports []OnionPort, clientAuth []string) (serviceID string, err error)
+
+	// CreateOnionServiceWithKey creates the onion service with the given
+	// private key, or with a new one when it's empty. It returns the key,
+	// so the same onion address can be published again later
+	CreateOnionServiceWithKey(ports []OnionPort, privateKey string) (serviceID, key string, err error)
+
+	// CreateOnionService works like CreateOnionServiceWithKey, letting
+	// only the given clients reach the onion service when there are any
+	CreateOnionService(ports []OnionPort, clientAuth []string, privateKey string) (serviceID, key string, err error)
 	CreateNewOnionService(destinationHost string, destinationPort int, port int) (serviceID string, err error)
 	DeleteOnionService(serviceID string) error
 	DeleteOnionServices()
-}
+	NewEventStream(events ...string) (EventStream, error)
 
-type controller struct {
-	torHost  string
-	torPort  int
-	authType *authenticationMethod
-	password string
-	c        torgoController
-	tc       func(string) (torgoController, error)
+	// AddOnionClientAuth makes Tor use the private key when
+	// connecting to the onion service, until it's removed
+	AddOnionClientAuth(serviceID, privateKey string) error
+	RemoveOnionClientAuths()
+
+	// RenewCircuits signals NEWNYM and closes the circuits
+	// to the onion service, so new ones are built
+	RenewCircuits(serviceID string) error
+
+	// ResetIntroductionPoints closes the circuits of the onion service
+	// to its introduction points, so Tor chooses other ones and
+	// uploads a new descriptor
+	ResetIntroductionPoints(serviceID string) error
 }
 
-// TODO[OB] - I'm not a huge fan of this being global
-// Is there any reason why this is not on the controller?
+// The same controller is used for publishing the onion services of the
+// hosted meetings and for what is needed to join meetings, like the keys
+// of the onion services with client authorization, even at the same time
+// when the host joins their own meeting. The commands for Tor are sent
+// through a single control connection, so they are sent one at a time
 
-var onions = []string{}
+type controller struct {
+	sync.Mutex
+	torHost     string
+	torPort     int
+	authType    *authenticationMethod
+	password    string
+	c           torgoController
+	tc          func(string) (torgoController, error)
+	clientAuths []string
+	onions      []string
+}
 
 // createController takes the Tor information given
 // and returns a controlling interface
@@ -64,7 +94,7 @@ func (cntrl *controller) SetPassword(p string) {
 }
 
 func (cntrl *controller) UseCookieAuth() {
-	var a authenticationMethod = authenticateCookie
+	var a authenticationMethod = authenticateCookieFile
 	cntrl.authType = &a
 }
 
@@ -74,6 +104,17 @@ type OnionPort struct {
 	ServicePort     int
 	DestinationPort int
 	DestinationHost string
+
+	// DestinationSocket is the path of a Unix socket that the onion service
+	// connects to, instead of the host and the port, when it's given
+	DestinationSocket string
+}
+
+func (p OnionPort) target() string {
+	if p.DestinationSocket != "" {
+		return "unix:" + p.DestinationSocket
+	}
+	return net.JoinHostPort(p.DestinationHost, strconv.Itoa(p.DestinationPort))
 }
 
 // TODO[OB] - It seems this would be nicer if there was just one interface
@@ -81,25 +122,44 @@ type OnionPort struct {
 
 func (cntrl *controller) CreateNewOnionServiceWithMultiplePorts(ports []OnionPort) (serviceID string, err error) {
 	log.Debugf("CreateNewOnionServiceWithMultiplePorts(%v)", ports)
-	tc, err := cntrl.getTorController()
+	serviceID, _, err = cntrl.createOnionService(ports, nil, "")
+	return
+}
+
+// CreateNewOnionServiceWithClientAuth creates an onion service that
+// can only be reached by the clients with the given public keys
+func (cntrl *controller) CreateNewOnionServiceWithClientAuth(ports []OnionPort, clientAuth []string) (serviceID string, err error) {
+	log.Debugf("CreateNewOnionServiceWithClientAuth(%v, %d clients)", ports, len(clientAuth))
+	serviceID, _, err = cntrl.createOnionService(ports, clientAuth, "")
+	return
+}
+
+func (cntrl *controller) CreateOnionServiceWithKey(ports []OnionPort, privateKey string) (serviceID, key string, err error) {
+	log.Debugf("CreateOnionServiceWithKey(%v, existing key: %v)", ports, privateKey != "")
+	return cntrl.createOnionService(ports, nil, privateKey)
+}
+
+func (cntrl *controller) CreateOnionService(ports []OnionPort, clientAuth []string, privateKey string) (serviceID, key string, err error) {
+	log.Debugf("CreateOnionService(%v, %d clients, existing key: %v)", ports, len(clientAuth), privateKey != "")
+	return cntrl.createOnionService(ports, clientAuth, privateKey)
+}
+
+func (cntrl *controller) createOnionService(ports []OnionPort, clientAuth []string, privateKey string) (serviceID, key string, err error) {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	log.Debug("createOnionService() - authenticating")
+	tc, err := cntrl.authenticatedTorController()
 	if err != nil {
 		return
 	}
 
-	log.Debug("CreateNewOnionServiceWithMultiplePorts() - authenticating")
-	if cntrl.authType != nil {
-		err = (*cntrl.authType)(tc)
-		if err != nil {
-			return
-		}
-	}
-
 	invalidPorts := []string{}
 	finalPorts := make(map[int]string)
 	for _, p := range ports {
-		host := net.JoinHostPort(p.DestinationHost, strconv.Itoa(p.DestinationPort))
+		host := p.target()
 
-		if !config.CheckPort(p.ServicePort) || !config.CheckPort(p.DestinationPort) {
+		if !config.CheckPort(p.ServicePort) || (p.DestinationSocket == "" && !config.CheckPort(p.DestinationPort)) {
 			invalidPorts = append(invalidPorts, host)
 			continue
 		}
@@ -108,26 +168,41 @@ func (cntrl *controller) CreateNewOnionServiceWithMultiplePorts(ports []OnionPor
 	}
 
 	if len(finalPorts) == 0 {
-		return "", errors.New("invalid source port")
+		return "", "", errors.New("invalid source port")
 	} else if len(invalidPorts) > 0 {
-		return "", fmt.Errorf("some ports are invalid: %v", invalidPorts)
+		return "", "", fmt.Errorf("some ports are invalid: %v", invalidPorts)
 	}
 
 	onion := &torgo.Onion{
 		Ports:          finalPorts,
 		PrivateKeyType: "NEW",
-		PrivateKey:     "ED25519-V3",
+		PrivateKey:     onionKeyType,
 	}
 
-	err = tc.AddOnion(onion)
+	if privateKey != "" {
+		onion.PrivateKeyType, onion.PrivateKey, err = parseOnionKey(privateKey)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if len(clientAuth) > 0 {
+		err = addOnionWithClientAuth(tc, onion, clientAuth)
+	} else {
+		err = tc.AddOnion(onion)
+	}
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	serviceID = fmt.Sprintf("%s.onion", onion.ServiceID)
-	onions = append(onions, serviceID)
+	cntrl.onions = append(cntrl.onions, serviceID)
+
+	if privateKey == "" && onion.PrivateKeyType != "NEW" {
+		privateKey = onion.PrivateKeyType + ":" + onion.PrivateKey
+	}
 
-	return serviceID, nil
+	return serviceID, privateKey, nil
 }
 
 func (cntrl *controller) CreateNewOnionService(destinationHost string, destinationPort int,
@@ -141,8 +216,21 @@ func (cntrl *controller) CreateNewOnionService(destinationHost string, destinati
 }
 
 func (cntrl *controller) DeleteOnionService(serviceID string) error {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	return cntrl.deleteOnionService(serviceID)
+}
+
+// deleteOnionService must be called with the lock held
+func (cntrl *controller) deleteOnionService(serviceID string) error {
+	tc, err := cntrl.getTorController()
+	if err != nil {
+		return err
+	}
+
 	s := strings.TrimSuffix(serviceID, ".onion")
-	err := cntrl.c.DeleteOnion(s)
+	err = tc.DeleteOnion(s)
 	if err != nil {
 		return err
 	}
@@ -150,11 +238,11 @@ func (cntrl *controller) DeleteOnionService(serviceID string) error {
 	// TODO[OB] - In order to avoid this messy code, it might be
 	// easier to make the onions variable a map instead of a list.
 
-	for i := range onions {
-		if onions[i] == serviceID {
-			onions[i] = onions[len(onions)-1]
-			onions[len(onions)-1] = ""
-			onions = onions[:len(onions)-1]
+	for i := range cntrl.onions {
+		if cntrl.onions[i] == serviceID {
+			cntrl.onions[i] = cntrl.onions[len(cntrl.onions)-1]
+			cntrl.onions[len(cntrl.onions)-1] = ""
+			cntrl.onions = cntrl.onions[:len(cntrl.onions)-1]
 			break
 		}
 	}
@@ -163,9 +251,65 @@ func (cntrl *controller) DeleteOnionService(serviceID string) error {
 }
 
 func (cntrl *controller) DeleteOnionServices() {
-	for _, o := range onions {
-		_ = cntrl.DeleteOnionService(o)
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	for _, o := range append([]string{}, cntrl.onions...) {
+		_ = cntrl.deleteOnionService(o)
+	}
+}
+
+func (cntrl *controller) AddOnionClientAuth(serviceID, privateKey string) error {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	tc, err := cntrl.authenticatedTorController()
+	if err != nil {
+		return err
+	}
+
+	s := strings.TrimSuffix(serviceID, ".onion")
+	err = addOnionClientAuth(tc, s, privateKey)
+	if err != nil {
+		return err
+	}
+
+	cntrl.clientAuths = append(cntrl.clientAuths, s)
+	return nil
+}
+
+// RemoveOnionClientAuths removes the private keys given to Tor, which
+// would stay in the Tor of the system after Wahay is closed otherwise
+func (cntrl *controller) RemoveOnionClientAuths() {
+	cntrl.Lock()
+	defer cntrl.Unlock()
+
+	if cntrl.c == nil {
+		return
+	}
+
+	for _, s := range cntrl.clientAuths {
+		_ = removeOnionClientAuth(cntrl.c, s)
 	}
+	cntrl.clientAuths = nil
+}
+
+// authenticatedTorController returns the control connection, after
+// authenticating in it. It must be called with the lock held
+func (cntrl *controller) authenticatedTorController() (torgoController, error) {
+	tc, err := cntrl.getTorController()
+	if err != nil {
+		return nil, err
+	}
+
+	if cntrl.authType != nil {
+		err = (*cntrl.authType)(tc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tc, nil
 }
 
 func (cntrl *controller) getTorController() (torgoController, error) {
@@ -1,7 +1,9 @@
 package tor
 
 import (
+	"encoding/base64"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/wybiral/torgo"
@@ -194,6 +196,27 @@ func (s *WahayTorSuite) Test_controller_CreateNewOnionService_createsOnionWithGi
 	c.Assert(o.Ports[7877], Equals, "127.0.42.1:42")
 }
 
+func (s *WahayTorSuite) Test_controller_CreateNewOnionServiceWithMultiplePorts_connectsToTheGivenSocket(c *C) {
+	mock := &controllerMock{}
+
+	cntrl := &controller{
+		torHost: "127.1.2.3",
+		torPort: 9052,
+		tc:      mock.createTestGotor,
+	}
+
+	_, e := cntrl.CreateNewOnionServiceWithMultiplePorts([]OnionPort{
+		{ServicePort: 8181, DestinationSocket: "/tmp/wahay/cert.sock"},
+		{ServicePort: 64738, DestinationPort: 42, DestinationHost: "127.0.42.1"},
+	})
+
+	c.Assert(e, IsNil)
+	o := mock.addOnionArg1
+	c.Assert(o.Ports, HasLen, 2)
+	c.Assert(o.Ports[8181], Equals, "unix:/tmp/wahay/cert.sock")
+	c.Assert(o.Ports[64738], Equals, "127.0.42.1:42")
+}
+
 func (s *WahayTorSuite) Test_controller_CreateNewOnionService_signalsErrorForInvalidPorts(c *C) {
 	mock := &controllerMock{}
 
@@ -235,6 +258,29 @@ func (s *WahayTorSuite) Test_controller_CreateNewOnionService_returnsTheServiceI
 	c.Assert(serviceID, Equals, "123abcfff.onion")
 }
 
+func (s *WahayTorSuite) Test_controller_CreateOnionServiceWithKey_usesTheGivenKey(c *C) {
+	mock := &controllerMock{}
+	mock.addOnionAddServiceInfo = "123abcfff"
+
+	cntrl := &controller{
+		torHost: "127.1.2.3",
+		torPort: 9052,
+		tc:      mock.createTestGotor,
+	}
+
+	key := onionKeyType + ":" + base64.StdEncoding.EncodeToString(make([]byte, onionKeyLength))
+	serviceID, k, e := cntrl.CreateOnionServiceWithKey([]OnionPort{{ServicePort: 7877, DestinationPort: 42, DestinationHost: "127.0.42.1"}}, key)
+
+	c.Assert(e, IsNil)
+	c.Assert(serviceID, Equals, "123abcfff.onion")
+	c.Assert(k, Equals, key)
+	c.Assert(mock.addOnionArg1.PrivateKeyType, Equals, onionKeyType)
+	c.Assert(mock.addOnionArg1.PrivateKey, Equals, strings.TrimPrefix(key, onionKeyType+":"))
+
+	_, _, e = cntrl.CreateOnionServiceWithKey([]OnionPort{{ServicePort: 7877, DestinationPort: 42, DestinationHost: "127.0.42.1"}}, "RSA1024:abc")
+	c.Assert(e, Equals, ErrInvalidOnionKey)
+}
+
 func (s *WahayTorSuite) Test_controller_DeleteOnionService_returnsErrorIfServiceIDIsEmpty(c *C) {
 	mock := &controllerMock{}
 	mock.deleteOnionReturnError = errors.New("the service ID cannot be empty")
@@ -271,3 +317,29 @@ func (s *WahayTorSuite) Test_controller_DeleteOnionService_returnsErrorIfFails(c
 	//error if delete fail
 	c.Assert(e, ErrorMatches, "service deletion error")
 }
+
+func (s *WahayTorSuite) Test_controller_DeleteOnionServices_deletesTheServicesOfTheController(c *C) {
+	mock := &controllerMock{addOnionAddServiceInfo: "abcdef"}
+
+	cntrl := &controller{
+		torHost: "127.1.2.3",
+		torPort: 9052,
+		tc:      mock.createTestGotor,
+	}
+	other := &controller{
+		torHost: "127.1.2.3",
+		torPort: 9052,
+		tc:      mock.createTestGotor,
+	}
+
+	_, e := cntrl.CreateNewOnionService("127.1.2.3", 9052, 7877)
+	c.Assert(e, IsNil)
+
+	other.DeleteOnionServices()
+	c.Assert(mock.deleteOnionCalled, Equals, false)
+
+	cntrl.DeleteOnionServices()
+	c.Assert(mock.deleteOnionCalled, Equals, true)
+	c.Assert(*mock.deleteOnionArg, Equals, "abcdef")
+	c.Assert(cntrl.onions, HasLen, 0)
+}
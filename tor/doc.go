@@ -16,7 +16,8 @@ Tor instance, and if that's not possible, start its own Tor instance that we hav
 NewInstance function should only be called once, at startup.
 
 All the top level functions that use Tor in this package, such as NewOnionServiceWithMultiplePorts and NewService, will
-use the instance and controller inside of the global instance in the package.
+use the instance and controller inside of the global instance in the package. The same instance is used for hosting
+and for joining meetings at the same time, so the controller can be used from several goroutines.
 
 */
 package tor
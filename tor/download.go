@@ -0,0 +1,70 @@
+package tor
+
+import (
+	"io"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// DownloadProgress is called while a download over Tor advances. The
+// total is -1 when the server doesn't tell the size of the response
+type DownloadProgress func(done, total int64)
+
+const (
+	// maxHTTPResponseSize is the biggest response accepted by HTTPrequest,
+	// which keeps the whole response in memory. Bigger contents
+	// should be streamed with HTTPDownload
+	maxHTTPResponseSize = 1024 * 1024
+
+	downloadChunkSize = 32 * 1024
+)
+
+var (
+	// ErrResponseTooLarge is an error to be trown when a response
+	// over Tor is bigger than the size allowed by the caller
+	ErrResponseTooLarge = failure.New("tor.response-too-large", failure.CategoryMeeting, "the response is too large", "")
+)
+
+// copyWithLimit copies the content of r into w, reporting the progress
+// and failing as soon as more than limit bytes are received. A limit
+// of zero or less means that the size is not limited
+func copyWithLimit(w io.Writer, r io.Reader, limit, total int64, progress DownloadProgress) (int64, error) {
+	if limit > 0 {
+		if total > limit {
+			return 0, ErrResponseTooLarge
+		}
+
+		// We read one more byte to know if the content goes over the limit
+		r = io.LimitReader(r, limit+1)
+	}
+
+	buf := make([]byte, downloadChunkSize)
+	done := int64(0)
+
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if limit > 0 && done+int64(n) > limit {
+				return done, ErrResponseTooLarge
+			}
+
+			_, werr := w.Write(buf[:n])
+			if werr != nil {
+				return done, werr
+			}
+
+			done += int64(n)
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+
+		if err == io.EOF {
+			return done, nil
+		}
+
+		if err != nil {
+			return done, err
+		}
+	}
+}
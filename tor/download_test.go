@@ -0,0 +1,46 @@
+package tor
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type WahayTorDownloadSuite struct{}
+
+var _ = Suite(&WahayTorDownloadSuite{})
+
+func (s *WahayTorDownloadSuite) Test_copyWithLimit_copiesTheContentAndReportsTheProgress(c *C) {
+	var out bytes.Buffer
+	var last int64
+
+	n, err := copyWithLimit(&out, strings.NewReader("hello world"), 20, 11, func(done, total int64) {
+		c.Assert(total, Equals, int64(11))
+		last = done
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(11))
+	c.Assert(last, Equals, int64(11))
+	c.Assert(out.String(), Equals, "hello world")
+}
+
+func (s *WahayTorDownloadSuite) Test_copyWithLimit_failsWhenTheContentIsBiggerThanTheLimit(c *C) {
+	var out bytes.Buffer
+
+	_, err := copyWithLimit(&out, strings.NewReader("hello world"), 5, -1, nil)
+	c.Assert(err, Equals, ErrResponseTooLarge)
+	c.Assert(out.Len() <= 5, Equals, true)
+
+	_, err = copyWithLimit(&out, strings.NewReader("hello world"), 5, 11, nil)
+	c.Assert(err, Equals, ErrResponseTooLarge)
+}
+
+func (s *WahayTorDownloadSuite) Test_copyWithLimit_acceptsAnySizeWithoutLimit(c *C) {
+	var out bytes.Buffer
+
+	n, err := copyWithLimit(&out, strings.NewReader("hello world"), 0, -1, nil)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(11))
+}
@@ -0,0 +1,133 @@
+package tor
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wybiral/torgo"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// EventStream delivers the asynchronous events that Tor sends
+// through the control port, without the status code
+type EventStream interface {
+	Events() <-chan string
+	Close() error
+}
+
+// ErrEventsNotSupported is an error to be trown when the
+// control connection can't be used for receiving events
+var ErrEventsNotSupported = failure.New("tor.events-not-supported", failure.CategoryTor, "the Tor controller doesn't support events", "")
+
+type eventStream struct {
+	sync.Mutex
+	c      *torgo.Controller
+	events chan string
+	done   chan bool
+	closed bool
+}
+
+// NewEventStream opens a new control connection subscribed to the given
+// events. A separate connection is used so the events are never mixed
+// with the replies to the commands sent by the controller
+func (cntrl *controller) NewEventStream(events ...string) (EventStream, error) {
+	tc, err := cntrl.tc(net.JoinHostPort(cntrl.torHost, strconv.Itoa(cntrl.torPort)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the real controller gives access to the connection,
+	// which is needed for reading the asynchronous replies
+	c, ok := tc.(*torgo.Controller)
+	if !ok {
+		return nil, ErrEventsNotSupported
+	}
+
+	if cntrl.authType != nil {
+		err = (*cntrl.authType)(tc)
+		if err != nil {
+			_ = c.Text.Close()
+			return nil, err
+		}
+	}
+
+	id, err := c.Text.Cmd("SETEVENTS %s", strings.Join(events, " "))
+	if err != nil {
+		_ = c.Text.Close()
+		return nil, err
+	}
+
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(250)
+	c.Text.EndResponse(id)
+	if err != nil {
+		_ = c.Text.Close()
+		return nil, err
+	}
+
+	s := &eventStream{
+		c:      c,
+		events: make(chan string, 100),
+		done:   make(chan bool),
+	}
+
+	go s.read()
+
+	return s, nil
+}
+
+func (s *eventStream) read() {
+	defer close(s.events)
+
+	for {
+		line, err := s.c.Text.ReadLine()
+		if err != nil {
+			if !s.isClosed() {
+				log.WithFields(log.Fields{
+					"context": "events",
+				}).Debugf("the Tor event stream has finished: %s", err)
+			}
+			return
+		}
+
+		// Multi-line events are not used by Wahay, so only the
+		// single-line ones, with the "650 " prefix, are delivered
+		if !strings.HasPrefix(line, "650 ") {
+			continue
+		}
+
+		select {
+		case s.events <- strings.TrimPrefix(line, "650 "):
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *eventStream) isClosed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed
+}
+
+func (s *eventStream) Events() <-chan string {
+	return s.events
+}
+
+func (s *eventStream) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.Unlock()
+
+	return s.c.Text.Close()
+}
@@ -1,8 +1,12 @@
 package tor
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -11,6 +15,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/digitalautonomy/wahay/config"
 	"github.com/wybiral/torgo"
@@ -62,6 +68,10 @@ type torgoFacade interface {
 type httpFacade interface {
 	CheckConnectionOverTor(host string, port int) bool
 	HTTPRequest(host string, port int, url string) (string, error)
+	HTTPDownload(host string, port int, url string, w io.Writer, limit int64, progress DownloadProgress) (int64, error)
+	HTTPPost(host string, port int, url, contentType string, body []byte) error
+	Dial(host string, port int, network, address string) (net.Conn, error)
+	DialIsolated(ctx context.Context, host string, port int, network, address string) (net.Conn, error)
 }
 
 var osf osFacade
@@ -187,7 +197,17 @@ func (*realTorgoImplementation) NewController(a string) (torgoController, error)
 	return torgo.NewController(a)
 }
 
-type realHTTPImplementation struct{}
+// httpOverTorTimeout is generous because onion services
+// can take a long time to answer the first request
+const httpOverTorTimeout = 2 * time.Minute
+
+// realHTTPImplementation keeps one client for every SOCKS proxy, so the
+// requests to the same onion service, like the ones for the files shared
+// in a meeting, reuse the connection instead of building a new circuit
+type realHTTPImplementation struct {
+	sync.Mutex
+	clients map[string]*http.Client
+}
 
 func (*realHTTPImplementation) CheckConnectionOverTor(host string, port int) bool {
 	proxyURL, err := url.Parse("socks5://" + net.JoinHostPort(host, strconv.Itoa(port)))
@@ -219,35 +239,121 @@ func (*realHTTPImplementation) CheckConnectionOverTor(host string, port int) boo
 	return v.IsTor
 }
 
-func (*realHTTPImplementation) HTTPRequest(host string, port int, u string) (string, error) {
+func dialerOverTor(host string, port int) (proxy.Dialer, error) {
 	proxyURL, err := url.Parse("socks5://" + net.JoinHostPort(host, strconv.Itoa(port)))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	return proxy.FromURL(proxyURL, proxy.Direct)
+}
+
+func (h *realHTTPImplementation) httpClientOverTor(host string, port int) (*http.Client, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if c, ok := h.clients[address]; ok {
+		return c, nil
+	}
+
+	dialer, err := dialerOverTor(host, port)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	t := &http.Transport{Dial: dialer.Dial}
-	client := &http.Client{Transport: t}
+	c := &http.Client{Transport: t, Timeout: httpOverTorTimeout}
 
-	resp, err := client.Get(u)
+	if h.clients == nil {
+		h.clients = make(map[string]*http.Client)
+	}
+	h.clients[address] = c
+
+	return c, nil
+}
+
+func (h *realHTTPImplementation) HTTPRequest(host string, port int, u string) (string, error) {
+	var content bytes.Buffer
+
+	_, err := h.HTTPDownload(host, port, u, &content, maxHTTPResponseSize, nil)
 	if err != nil {
 		return "", err
 	}
 
+	return content.String(), nil
+}
+
+func (h *realHTTPImplementation) HTTPDownload(host string, port int, u string, w io.Writer, limit int64, progress DownloadProgress) (int64, error) {
+	client, err := h.httpClientOverTor(host, port)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return 0, err
+	}
+
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("invalid request")
+		return 0, errors.New("invalid request")
 	}
 
-	content, err := ioutil.ReadAll(resp.Body)
+	return copyWithLimit(w, resp.Body, limit, resp.ContentLength, progress)
+}
+
+func (h *realHTTPImplementation) HTTPPost(host string, port int, u, contentType string, body []byte) error {
+	client, err := h.httpClientOverTor(host, port)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	resp, err := client.Post(u, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the request failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (*realHTTPImplementation) Dial(host string, port int, network, address string) (net.Conn, error) {
+	dialer, err := dialerOverTor(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	return dialer.Dial(network, address)
+}
+
+// socksIsolationLength is the length of the random SOCKS credentials.
+// Tor uses different circuits for connections with different ones
+const socksIsolationLength = 16
+
+func (*realHTTPImplementation) DialIsolated(ctx context.Context, host string, port int, network, address string) (net.Conn, error) {
+	credentials := make([]byte, socksIsolationLength)
+	err := config.RandomString(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &proxy.Auth{User: string(credentials), Password: string(credentials)}
+	dialer, err := proxy.SOCKS5("tcp", net.JoinHostPort(host, strconv.Itoa(port)), auth, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return dialer.Dial(network, address)
 	}
 
-	return string(content), nil
+	return d.DialContext(ctx, network, address)
 }
@@ -2,8 +2,10 @@ package tor
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
@@ -14,6 +16,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/digitalautonomy/wahay/config"
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 const (
@@ -30,8 +33,45 @@ type Instance interface {
 	Destroy()
 	GetController() Control
 	HTTPrequest(url string) (string, error)
+	HTTPDownload(url string, w io.Writer, limit int64, progress DownloadProgress) (int64, error)
+	HTTPPost(url, contentType string, body []byte) error
+	Dial(network, address string) (net.Conn, error)
+
+	// DialIsolated works like Dial, through circuits
+	// that no other connection of ours uses
+	DialIsolated(ctx context.Context, network, address string) (net.Conn, error)
+
 	NewService(string, []string, ModifyCommand) (Service, error)
 	NewOnionServiceWithMultiplePorts([]OnionPort) (Onion, error)
+
+	// NewOnionServiceWithClientAuth creates an onion service that can only
+	// be reached by the clients with the given public keys
+	NewOnionServiceWithClientAuth([]OnionPort, []string) (Onion, error)
+
+	// NewOnionServiceWithKey creates an onion service with the given
+	// private key, so it has the same address every time it's created.
+	// A new key is generated when it's empty
+	NewOnionServiceWithKey([]OnionPort, string) (Onion, error)
+
+	// AuthorizeOnion makes Tor use the private key for
+	// reaching an onion service that needs client authorization
+	AuthorizeOnion(serviceID, privateKey string) error
+
+	// RenewCircuits makes Tor use new circuits, reconnecting
+	// to the onion service through them when it's given
+	RenewCircuits(serviceID string) error
+
+	// MeasureTraffic counts what goes through Tor
+	// to the onion service, until it's closed
+	MeasureTraffic(serviceID string) (TrafficMeter, error)
+
+	// Version returns the version of the Tor binary, or an empty
+	// string when Tor is not run by Wahay
+	Version() string
+
+	// SocksAddress returns the host and the port of the SOCKS proxy of
+	// Tor, for the applications that can't be run through torsocks
+	SocksAddress() (string, int)
 }
 
 type instance struct {
@@ -41,6 +81,8 @@ type instance struct {
 	socksPort       int
 	controlHost     string
 	controlPort     int
+	fixedSocksPort  int
+	fixedCtrlPort   int
 	dataDirectory   string
 	password        string
 	useCookie       bool
@@ -50,6 +92,7 @@ type instance struct {
 	controller      Control
 	runningTor      *runningTor
 	binary          *binary
+	bridgesConfig   string
 	onInitCallbacks []func(Instance)
 }
 
@@ -58,6 +101,17 @@ func (i *instance) setBinary(b *binary, pathTorsocks string) {
 	i.pathTorsocks = pathTorsocks
 }
 
+func (i *instance) Version() string {
+	if i.binary == nil {
+		return ""
+	}
+	return binaryVersion(i.binary)
+}
+
+func (i *instance) SocksAddress() (string, int) {
+	return i.controlHost, i.socksPort
+}
+
 func (i *instance) init() {
 	for _, f := range i.onInitCallbacks {
 		f(i)
@@ -80,6 +134,27 @@ func (i *instance) HTTPrequest(u string) (string, error) {
 	return httpf.HTTPRequest(i.controlHost, i.socksPort, u)
 }
 
+// HTTPDownload streams the response of the given url to w, failing if
+// it's bigger than limit bytes. It returns the number of bytes received
+func (i *instance) HTTPDownload(u string, w io.Writer, limit int64, progress DownloadProgress) (int64, error) {
+	return httpf.HTTPDownload(i.controlHost, i.socksPort, u, w, limit, progress)
+}
+
+func (i *instance) HTTPPost(u, contentType string, body []byte) error {
+	return httpf.HTTPPost(i.controlHost, i.socksPort, u, contentType, body)
+}
+
+// Dial connects to the given address through Tor
+func (i *instance) Dial(network, address string) (net.Conn, error) {
+	return httpf.Dial(i.controlHost, i.socksPort, network, address)
+}
+
+// DialIsolated connects to the given address through Tor, using
+// circuits that no other connection uses
+func (i *instance) DialIsolated(ctx context.Context, network, address string) (net.Conn, error) {
+	return httpf.DialIsolated(ctx, i.controlHost, i.socksPort, network, address)
+}
+
 type runningTor struct {
 	cmd               *exec.Cmd
 	ctx               context.Context
@@ -93,12 +168,39 @@ type runningTor struct {
 type Onion interface {
 	ID() string
 	Delete() error
+
+	// WhenPublished calls the given function once the onion service
+	// can be reached by others, or with the error that made us
+	// stop waiting for it. The service might work even then
+	WhenPublished(func(error))
+
+	// PrivateKey returns the key of the onion service, for publishing
+	// it in the same address again. It's only known for the services
+	// created with NewOnionServiceWithKey
+	PrivateKey() string
+
+	// CheckReachable connects to the given port of the onion service
+	// through circuits of its own, like a participant would
+	CheckReachable(ctx context.Context, port int) error
+
+	// Republish makes Tor publish the onion service again,
+	// through new introduction points
+	Republish() error
+
+	// Restart creates the onion service again in Tor, with the
+	// same address, the same ports and the same clients
+	Restart() error
 }
 
 type onion struct {
-	id    string
-	ports []OnionPort
-	t     Instance
+	sync.Mutex
+	id          string
+	key         string
+	withKey     bool
+	clientAuth  []string
+	ports       []OnionPort
+	t           Instance
+	publication *onionPublication
 }
 
 func (s *onion) ID() string {
@@ -106,56 +208,147 @@ func (s *onion) ID() string {
 }
 
 func (s *onion) Delete() error {
+	s.publication.cancel()
+
 	c := s.t.GetController()
 	return c.DeleteOnionService(s.id)
 }
 
+func (s *onion) WhenPublished(f func(error)) {
+	s.publication.whenPublished(f)
+}
+
+func (s *onion) PrivateKey() string {
+	if !s.withKey {
+		return ""
+	}
+	return s.key
+}
+
 // NewOnionServiceWithMultiplePorts creates a new Onion service for the current Tor controller
 func (i *instance) NewOnionServiceWithMultiplePorts(ports []OnionPort) (Onion, error) {
 	log.Debugf("NewOnionServiceWithMultiplePorts(%v)", ports)
+	return i.newOnionService(ports, nil, false, "")
+}
+
+// NewOnionServiceWithClientAuth creates a new Onion service for the current
+// Tor controller, that only authorized clients can reach
+func (i *instance) NewOnionServiceWithClientAuth(ports []OnionPort, clientAuth []string) (Onion, error) {
+	log.Debugf("NewOnionServiceWithClientAuth(%v)", ports)
+	return i.newOnionService(ports, clientAuth, false, "")
+}
+
+// NewOnionServiceWithKey creates a new Onion service for the current
+// Tor controller, with the given private key or a new one
+func (i *instance) NewOnionServiceWithKey(ports []OnionPort, privateKey string) (Onion, error) {
+	log.Debugf("NewOnionServiceWithKey(%v)", ports)
+	return i.newOnionService(ports, nil, true, privateKey)
+}
+
+func (i *instance) newOnionService(ports []OnionPort, clientAuth []string, withKey bool, privateKey string) (Onion, error) {
 	controller := i.GetController()
 
-	serviceID, err := controller.CreateNewOnionServiceWithMultiplePorts(ports)
+	// We subscribe to the events before creating the service,
+	// otherwise the first ones could be missed
+	events, eerr := controller.NewEventStream("HS_DESC", "CIRC")
+	if eerr != nil {
+		log.Debugf("newOnionService(): the publication can't be followed: %s", eerr)
+	}
+
+	// The key is kept even when it was not asked for,
+	// so the service can be restarted with the same address
+	serviceID, privateKey, err := controller.CreateOnionService(ports, clientAuth, privateKey)
 	if err != nil {
+		if events != nil {
+			_ = events.Close()
+		}
 		return nil, err
 	}
 
 	s := &onion{
-		id:    serviceID,
-		ports: ports,
-		t:     i,
+		id:          serviceID,
+		key:         privateKey,
+		withKey:     withKey,
+		clientAuth:  clientAuth,
+		ports:       ports,
+		t:           i,
+		publication: newOnionPublication(serviceID),
+	}
+
+	if events != nil {
+		go s.publication.follow(events, publicationTimeout)
+	} else {
+		s.publication.finish(ErrOnionPublicationUnknown)
 	}
 
 	return s, nil
 }
 
+// AuthorizeOnion makes our Tor use the private key for reaching the onion service
+func (i *instance) AuthorizeOnion(serviceID, privateKey string) error {
+	return i.GetController().AddOnionClientAuth(serviceID, privateKey)
+}
+
+// RenewCircuits makes our Tor build new circuits for the onion service
+func (i *instance) RenewCircuits(serviceID string) error {
+	return i.GetController().RenewCircuits(serviceID)
+}
+
 var (
 	// ErrTorBinaryNotFound is an error to be trown when wasn't
 	// possible to find any available or valid Tor binary
-	ErrTorBinaryNotFound = errors.New("no Tor binary found")
+	ErrTorBinaryNotFound = failure.New("tor.binary-not-found", failure.CategoryTor, "no Tor binary found", "install Tor in the system")
 
 	// ErrTorInstanceCantStart is an error to be trown when the
 	// Tor instance cannot be started
-	ErrTorInstanceCantStart = errors.New("the Tor instance cannot start")
+	ErrTorInstanceCantStart = failure.New("tor.cant-start", failure.CategoryTor, "the Tor instance cannot start", "")
 
 	// ErrTorConnectionTimeout is an error to be trown when the
 	// connection to the Tor network using our instance wasn't possible
-	ErrTorConnectionTimeout = errors.New("connection over Tor timeout")
+	ErrTorConnectionTimeout = failure.New("tor.connection-timeout", failure.CategoryTor, "connection over Tor timeout", "the Tor network might be blocked in this network")
+
+	// ErrTorPortInUse is an error to be trown when a port given for our
+	// Tor instance is used by another application
+	ErrTorPortInUse = failure.New("tor.port-in-use", failure.CategoryConfiguration, "a port of the Tor started by Wahay is already in use", "choose other ports in the settings, or let Wahay choose them")
+
+	errSystemTorNotUsable = failure.New("tor.system-not-usable", failure.CategoryTor, "error: we can't use system Tor instance", "")
+
+	// ErrInvalidControlAddress is returned when the configured
+	// Tor control port is not a host and a port
+	ErrInvalidControlAddress = failure.New("tor.invalid-control-address", failure.CategoryConfiguration, "the address of the Tor control port is not valid", "use the host and the port of the ControlPort of Tor, like 127.0.0.1:9051")
 )
 
 // NewInstance initializes and returns the Instance for working with Tor.
 // This function should be called only once during the system initialization
 func NewInstance(conf *config.ApplicationConfig, onInit func(Instance)) (Instance, error) {
+	return NewInstanceWithBootstrap(conf, onInit, nil)
+}
+
+// NewInstanceWithBootstrap works like NewInstance, reporting the progress
+// of our Tor instance while it connects to the network to the given
+// Bootstrap, which is finished when this function returns
+func NewInstanceWithBootstrap(conf *config.ApplicationConfig, onInit func(Instance), bs *Bootstrap) (Instance, error) {
+	defer bs.done()
+
 	// Checking if the system Tor can be used.
 	// This should work for system like Tails, where Tor is
 	// already available in the system.
-	i, err := systemInstance()
+	i, err := systemInstance(conf)
 	if err == nil {
 		log.Infof("Using System Tor")
 		return i, nil
 	}
 
+	// When a running Tor is configured, we don't start a second one
+	// that could connect in ways the user didn't intend
+	if conf.GetTorControlAddress() != "" {
+		return nil, err
+	}
+
 	b, err := findTorBinary(conf)
+	if err == ErrTorBinaryNotFound && CanInstallTorBundle() {
+		b, err = installTorBundleWhenNeeded()
+	}
 	if b == nil || err != nil {
 		if err != nil {
 			return nil, err
@@ -165,33 +358,123 @@ func NewInstance(conf *config.ApplicationConfig, onInit func(Instance)) (Instanc
 
 	log.Infof("Using Tor binary found in: %s", b.path)
 
-	i, err = getOurInstance(b, conf, onInit)
+	i, err = getOurInstance(b, conf, onInit, bs)
 	if err != nil {
 		log.Debugf("tor.NewInstance() error: %s", err)
 		return nil, err
 	}
 
+	if filepath.Dir(b.path) == torBundlePath() {
+		go updateTorBundle(i)
+	}
+
 	return i, nil
 }
 
 const torStartupTimeout = 2 * time.Minute
 
-func systemInstance() (Instance, error) {
-	checker := newDefaultChecker()
+// runningTorAddress is the control port of a Tor that could be already
+// running, with the SOCKS port used when it doesn't tell us its own
+type runningTorAddress struct {
+	host        string
+	controlPort int
+	socksPort   int
+}
 
-	log.Debugf("checking system instance...")
+// torBrowserControlPort and torBrowserSocksPort are the ports
+// of the Tor started by Tor Browser
+const (
+	torBrowserControlPort = 9151
+	torBrowserSocksPort   = 9150
+)
+
+// runningTorAddresses returns where to look for a running Tor, which is only
+// the configured control port when there is one. Otherwise the system Tor
+// and then Tor Browser are tried
+func runningTorAddresses(conf *config.ApplicationConfig) ([]runningTorAddress, error) {
+	if a := conf.GetTorControlAddress(); a != "" {
+		host, port, err := parseControlAddress(a)
+		if err != nil {
+			return nil, err
+		}
+
+		return []runningTorAddress{{host, port, defaultSocksPort}}, nil
+	}
+
+	return []runningTorAddress{
+		{*config.TorHost, *config.TorPort, *config.TorRoutePort},
+		{defaultControlHost, torBrowserControlPort, torBrowserSocksPort},
+	}, nil
+}
+
+// ValidateControlAddress checks that the given address of
+// a Tor control port has a host and a port
+func ValidateControlAddress(a string) error {
+	_, _, err := parseControlAddress(a)
+	return err
+}
+
+func parseControlAddress(a string) (string, int, error) {
+	host, port, err := net.SplitHostPort(a)
+	if err != nil || host == "" {
+		return "", 0, ErrInvalidControlAddress.Wrapf("%s", a)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || !config.CheckPort(p) {
+		return "", 0, ErrInvalidControlAddress.Wrapf("%s", a)
+	}
+
+	return host, p, nil
+}
+
+// runningTorPassword returns the password of the control port, given
+// in the command line or else in the configuration
+func runningTorPassword(conf *config.ApplicationConfig) string {
+	if *config.TorControlPassword != "" {
+		return *config.TorControlPassword
+	}
+	return conf.GetTorControlPassword()
+}
+
+func systemInstance(conf *config.ApplicationConfig) (Instance, error) {
+	addresses, err := runningTorAddresses(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	password := runningTorPassword(conf)
+
+	for _, a := range addresses {
+		var i *instance
+		i, err = runningTorInstance(a, password)
+		if err == nil {
+			return i, nil
+		}
+	}
+
+	return nil, err
+}
+
+func runningTorInstance(a runningTorAddress, password string) (*instance, error) {
+	checker := newSystemChecker(a.host, a.socksPort, a.controlPort, password)
+
+	log.Debugf("checking system instance on %s:%d...", a.host, a.controlPort)
 	authType, total, partial := checker.check()
 
 	if total != nil || partial != nil {
 		log.Debugf("system instance not possible to use, because: %v - %v", total, partial)
-		return nil, errors.New("error: we can't use system Tor instance")
+		if total != nil {
+			return nil, errSystemTorNotUsable.Wrap(total)
+		}
+		return nil, errSystemTorNotUsable.Wrap(partial)
 	}
 
 	i := &instance{
 		started:     true,
-		controlHost: defaultControlHost,
-		controlPort: defaultControlPort,
-		socksPort:   defaultSocksPort,
+		controlHost: a.host,
+		controlPort: a.controlPort,
+		socksPort:   checker.routePort,
 		useCookie:   false,
 		isLocal:     true,
 	}
@@ -199,14 +482,14 @@ func systemInstance() (Instance, error) {
 	if authType == "cookie" {
 		i.useCookie = true
 	} else if authType == "password" {
-		i.password = *config.TorControlPassword
+		i.password = password
 	}
 
 	return i, nil
 }
 
-func getOurInstance(b *binary, conf *config.ApplicationConfig, onInit func(Instance)) (*instance, error) {
-	i, _ := newInstance(conf.IsLogsEnabled())
+func getOurInstance(b *binary, conf *config.ApplicationConfig, onInit func(Instance), bs *Bootstrap) (*instance, error) {
+	i, _ := newInstance(conf.IsLogsEnabled(), conf.GetTorSocksPort(), conf.GetTorControlPort())
 
 	if onInit != nil {
 		i.onInit(onInit)
@@ -215,38 +498,88 @@ func getOurInstance(b *binary, conf *config.ApplicationConfig, onInit func(Insta
 	i.setBinary(b, conf.GetPathTorSocks())
 	i.init()
 
-	err := i.Start()
+	err := i.useBridges(conf.GetTorBridges())
 	if err != nil {
 		return nil, err
 	}
 
-	checker := newCustomChecker(i.controlHost, i.socksPort, i.controlPort)
+	err = i.startWithFreePorts(bs)
+	if err != nil {
+		i.Destroy()
+		return nil, err
+	}
 
-	timeout := time.Now().Add(torStartupTimeout)
-	for {
-		time.Sleep(3 * time.Second)
+	return i, nil
+}
 
-		_, errTotal, errPartial := checker.check()
-		if errTotal != nil {
-			return nil, errTotal
+// torStartAttempts is how many times our instance is started with
+// other ports, when another application takes them while Tor starts
+const torStartAttempts = 3
+
+// startWithFreePorts starts our instance and waits until it's connected.
+// The ports are found free before starting Tor, but another application,
+// like Tor Browser, can take them in the meantime. Then Tor stops, and
+// it's started again with other ports, unless they were given by the user
+func (i *instance) startWithFreePorts(bs *Bootstrap) error {
+	for attempt := 1; ; attempt++ {
+		err := i.Start()
+		if err != nil {
+			return err
 		}
 
-		if time.Now().After(timeout) {
-			return nil, ErrTorConnectionTimeout
+		checker := newCustomChecker(i.controlHost, i.socksPort, i.controlPort)
+
+		err = i.waitForBootstrap(checker, bs)
+		if err != errTorExited {
+			return err
 		}
 
-		if errPartial == nil {
-			return i, nil
+		port := i.takenPort()
+		if port == 0 {
+			return ErrTorInstanceCantStart.Wrap(err)
+		}
+
+		if port == i.fixedSocksPort || port == i.fixedCtrlPort || attempt == torStartAttempts {
+			return ErrTorPortInUse.Wrapf("%d", port)
 		}
 
 		log.WithFields(log.Fields{
-			"time": time.Now(),
-		}).Error(fmt.Sprintf("The following error occurred while checking Tor connectivity: %s", errPartial.Error()))
+			"port": port,
+		}).Warning("A port of our Tor was taken while it started, so it's started again with other ports")
+
+		err = i.changePorts()
+		if err != nil {
+			return err
+		}
 	}
 }
 
-func newInstance(enableLogs bool) (*instance, error) {
-	i := createOurInstance(enableLogs)
+// takenPort returns the port of our instance that another application
+// is using, once Tor has stopped, or zero when they are all free
+func (i *instance) takenPort() int {
+	for _, p := range []int{i.socksPort, i.controlPort} {
+		if !osf.IsPortAvailable(p) {
+			return p
+		}
+	}
+	return 0
+}
+
+// changePorts finds other free ports for our instance, which must
+// not be running, and forgets the controller of the old ones
+func (i *instance) changePorts() error {
+	i.Lock()
+	i.controller = nil
+	i.Unlock()
+
+	i.runningTor = nil
+	i.controlPort, i.socksPort = findAvailableTorPorts(i.fixedCtrlPort, i.fixedSocksPort)
+
+	return i.writeToFile()
+}
+
+func newInstance(enableLogs bool, socksPort, controlPort int) (*instance, error) {
+	i := createOurInstance(enableLogs, socksPort, controlPort)
 
 	err := i.createConfigFile()
 
@@ -273,7 +606,12 @@ func (i *instance) Start() error {
 }
 
 // GetController returns a controller for the instance `i`
+// GetController returns the controller of the instance, which is
+// created the first time and shared by everything that uses Tor
 func (i *instance) GetController() Control {
+	i.Lock()
+	defer i.Unlock()
+
 	log.Debugf("instance(%#v).GetController()", i)
 	if i.controller == nil {
 		i.controller = createController(i.controlHost, i.controlPort)
@@ -299,9 +637,14 @@ func (i *instance) Destroy() {
 		}
 	}
 
-	if i.controller != nil {
-		i.controller.DeleteOnionServices()
-		i.controller = nil
+	i.Lock()
+	c := i.controller
+	i.controller = nil
+	i.Unlock()
+
+	if c != nil {
+		c.RemoveOnionClientAuths()
+		c.DeleteOnionServices()
 	}
 
 	if i.runningTor != nil {
@@ -326,20 +669,23 @@ func (i *instance) exec(command string, args []string, pre ModifyCommand) (*Runn
 	/* #nosec G204 */
 	cmd := exec.CommandContext(ctx, command, args...)
 
-	pathTorsocks, err := findLibTorsocks(i.pathTorsocks)
-	if err != nil {
-		cancelFunc()
-		return nil, errors.New("error: libtorsocks.so was not found")
-	}
+	cmd.Env = osf.Environ()
 
-	pwd := [32]byte{}
-	_ = config.RandomString(pwd[:])
+	if usesTorsocks {
+		pathTorsocks, err := findLibTorsocks(i.pathTorsocks)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
 
-	cmd.Env = osf.Environ()
-	cmd.Env = append(cmd.Env, fmt.Sprintf("LD_PRELOAD=%s", pathTorsocks))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_PASSWORD=%s", string(pwd[:])))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_TOR_ADDRESS=%s", i.controlHost))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_TOR_PORT=%d", i.socksPort))
+		pwd := [32]byte{}
+		_ = config.RandomString(pwd[:])
+
+		cmd.Env = append(cmd.Env, fmt.Sprintf("LD_PRELOAD=%s", pathTorsocks))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_PASSWORD=%s", string(pwd[:])))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_TOR_ADDRESS=%s", i.controlHost))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("TORSOCKS_TOR_PORT=%d", i.socksPort))
+	}
 
 	if pre != nil {
 		pre(cmd)
@@ -347,7 +693,7 @@ func (i *instance) exec(command string, args []string, pre ModifyCommand) (*Runn
 
 	if *config.Debug {
 		cmd.Stdout = osf.Stdout()
-		cmd.Stderr = osf.Stderr()
+		cmd.Stderr = alsoTo(cmd.Stderr, osf.Stderr())
 	}
 
 	if err := execf.StartCommand(cmd); err != nil {
@@ -364,23 +710,27 @@ func (i *instance) exec(command string, args []string, pre ModifyCommand) (*Runn
 	return rc, nil
 }
 
-func createOurInstance(enableLogs bool) *instance {
+// createOurInstance returns our instance with the given ports, finding
+// free ones for those that are zero
+func createOurInstance(enableLogs bool, fixedSocksPort, fixedCtrlPort int) *instance {
 	d := filesystemf.TempDir("tor")
 
-	controlPort, routePort := findAvailableTorPorts()
+	controlPort, routePort := findAvailableTorPorts(fixedCtrlPort, fixedSocksPort)
 
 	i := &instance{
-		started:       false,
-		configFile:    filepath.Join(d, torConfigName),
-		controlHost:   defaultControlHost,
-		controlPort:   controlPort,
-		socksPort:     routePort,
-		dataDirectory: filepath.Join(d, torConfigData),
-		enableLogs:    enableLogs,
-		password:      "", // our instance don't use authentication with password
-		useCookie:     true,
-		isLocal:       false,
-		controller:    nil,
+		started:        false,
+		configFile:     filepath.Join(d, torConfigName),
+		controlHost:    defaultControlHost,
+		controlPort:    controlPort,
+		socksPort:      routePort,
+		fixedSocksPort: fixedSocksPort,
+		fixedCtrlPort:  fixedCtrlPort,
+		dataDirectory:  filepath.Join(d, torConfigData),
+		enableLogs:     enableLogs,
+		password:       "", // our instance don't use authentication with password
+		useCookie:      true,
+		isLocal:        false,
+		controller:     nil,
 	}
 
 	return i
@@ -394,9 +744,14 @@ func findAvailablePort(initial int) int {
 	return port
 }
 
-func findAvailableTorPorts() (controlPort, routePort int) {
-	controlPort = findAvailablePort(defaultControlPort)
-	routePort = findAvailablePort(defaultSocksPort)
+func findAvailableTorPorts(fixedCtrlPort, fixedSocksPort int) (controlPort, routePort int) {
+	controlPort, routePort = fixedCtrlPort, fixedSocksPort
+	if controlPort == 0 {
+		controlPort = findAvailablePort(defaultControlPort)
+	}
+	if routePort == 0 {
+		routePort = findAvailablePort(defaultSocksPort)
+	}
 	return
 }
 
@@ -440,6 +795,14 @@ func (i *instance) getConfigFileContents() []byte {
 		)
 	}
 
+	// Tor quits by itself when Wahay is not running anymore, even when
+	// Wahay crashes or is killed and can't stop it. It doesn't depend on
+	// the process group or the parent death signal of Linux, so it works
+	// the same on macOS and Windows
+	content += fmt.Sprintf("\n__OwningControllerProcess %d\n", os.Getpid())
+
+	content += i.bridgesConfig
+
 	return []byte(content)
 }
 
@@ -455,7 +818,5 @@ func (r *runningTor) waitForFinish() {
 	e := execf.WaitCommand(r.cmd)
 	r.finished = true
 	r.finishedWithError = e
-	// TODO: Maybe here, we should check if the failure was because
-	// of taken ports, regenerate the ports and try again?
 	r.finishChannel <- true
 }
@@ -0,0 +1,65 @@
+package tor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// ErrOnionNotRestarted is an error to be trown when the onion
+// service can't be created again, like when its key is not known
+var ErrOnionNotRestarted = failure.New("tor.onion-not-restarted", failure.CategoryHosting, "the onion service can't be published again", "stop the meeting and host it again")
+
+// CheckReachable dials the onion service through our own Tor, with
+// credentials of its own for the SOCKS proxy, so Tor reaches it through
+// new circuits instead of the ones used by the participants
+func (s *onion) CheckReachable(ctx context.Context, port int) error {
+	c, err := s.t.DialIsolated(ctx, "tcp", net.JoinHostPort(s.id, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
+
+func (s *onion) Republish() error {
+	return s.t.GetController().ResetIntroductionPoints(s.id)
+}
+
+// Restart only creates the service again with the same address,
+// so the invitations that have been given keep working
+func (s *onion) Restart() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.key == "" {
+		return ErrOnionNotRestarted
+	}
+
+	c := s.t.GetController()
+	err := c.DeleteOnionService(s.id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"serviceID": s.id,
+		}).Debugf("Restart(): the onion service can't be removed: %s", err)
+	}
+
+	id, _, err := c.CreateOnionService(s.ports, s.clientAuth, s.key)
+	if err != nil {
+		return ErrOnionNotRestarted.Wrap(err)
+	}
+
+	if !strings.EqualFold(id, s.id) {
+		log.WithFields(log.Fields{
+			"serviceID": s.id,
+			"newID":     id,
+		}).Warning("Restart(): the onion service has been created with another address")
+	}
+
+	return nil
+}
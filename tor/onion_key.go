@@ -0,0 +1,67 @@
+package tor
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// The private keys of the onion services are given to Tor, and received
+// from it, like "ED25519-V3:<the expanded ed25519 key in base64>". With
+// the same key, the onion service is published in the same address
+
+const (
+	onionKeyType = "ED25519-V3"
+
+	// onionKeyLength is the size of the expanded ed25519 keys
+	onionKeyLength = 64
+)
+
+// ErrInvalidOnionKey is returned when the private key of an onion service doesn't have the right format
+var ErrInvalidOnionKey = failure.New("tor.invalid-onion-key", failure.CategoryInput, "the private key of the onion service is not valid", "")
+
+// parseOnionKey returns the type and the key, as torgo expects them
+func parseOnionKey(key string) (string, string, error) {
+	kv := strings.SplitN(strings.TrimSpace(key), ":", 2)
+	if len(kv) != 2 || kv[0] != onionKeyType {
+		return "", "", ErrInvalidOnionKey
+	}
+
+	k, err := base64.StdEncoding.DecodeString(kv[1])
+	if err != nil || len(k) != onionKeyLength {
+		return "", "", ErrInvalidOnionKey
+	}
+
+	return kv[0], kv[1], nil
+}
+
+// NewOnionKey generates the private key of an onion service that has
+// never been published, so its address is known beforehand. It returns
+// the key, like Tor gives it, and the public key the address comes from
+func NewOnionKey() (string, []byte, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", nil, err
+	}
+
+	key, public := onionKeyFromSeed(seed)
+	return key, public, nil
+}
+
+// onionKeyFromSeed expands the ed25519 key like Tor does: the
+// clamped scalar followed by the rest of the hash of the seed
+func onionKeyFromSeed(seed []byte) (string, []byte) {
+	public := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+	h := sha512.Sum512(seed)
+	h[0] &= 248
+	h[31] &= 127
+	h[31] |= 64
+
+	return onionKeyType + ":" + base64.StdEncoding.EncodeToString(h[:]), []byte(public)
+}
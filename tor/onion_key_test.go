@@ -0,0 +1,48 @@
+package tor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	. "gopkg.in/check.v1"
+)
+
+type TorOnionKeySuite struct{}
+
+var _ = Suite(&TorOnionKeySuite{})
+
+func (s *TorOnionKeySuite) Test_onionKeyFromSeed_generatesAKeyThatTorAccepts(c *C) {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	key, public := onionKeyFromSeed(seed)
+
+	kt, kv, err := parseOnionKey(key)
+	c.Assert(err, IsNil)
+	c.Assert(kt, Equals, onionKeyType)
+
+	expanded, _ := base64.StdEncoding.DecodeString(kv)
+	c.Assert(expanded[0]&7, Equals, byte(0))
+	c.Assert(expanded[31]&192, Equals, byte(64))
+
+	c.Assert(public, DeepEquals, []byte(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)))
+}
+
+func (s *TorOnionKeySuite) Test_NewOnionKey_generatesADifferentKeyEveryTime(c *C) {
+	k1, p1, err := NewOnionKey()
+	c.Assert(err, IsNil)
+	k2, p2, err := NewOnionKey()
+	c.Assert(err, IsNil)
+
+	c.Assert(k1, Not(Equals), k2)
+	c.Assert(bytes.Equal(p1, p2), Equals, false)
+	c.Assert(strings.HasPrefix(k1, onionKeyType+":"), Equals, true)
+}
+
+func (s *TorOnionKeySuite) Test_parseOnionKey_rejectsTheKeysOfOtherTypes(c *C) {
+	_, _, err := parseOnionKey("RSA1024:" + base64.StdEncoding.EncodeToString(make([]byte, onionKeyLength)))
+	c.Assert(err, Equals, ErrInvalidOnionKey)
+
+	_, _, err = parseOnionKey(onionKeyType + ":" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	c.Assert(err, Equals, ErrInvalidOnionKey)
+}
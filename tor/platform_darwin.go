@@ -0,0 +1,29 @@
+//go:build darwin
+// +build darwin
+
+package tor
+
+import "fmt"
+
+const (
+	torExecutable = "tor"
+
+	// usesTorsocks is false since macOS ignores the LD_PRELOAD of
+	// torsocks. The services are configured to use the SOCKS proxy
+	// of Tor instead
+	usesTorsocks = false
+)
+
+// torSystemDirs are where Tor is installed by Homebrew and MacPorts. The
+// applications opened from the Finder don't have them in their PATH
+var torSystemDirs = []string{
+	"/opt/homebrew/bin",
+	"/usr/local/bin",
+	"/opt/local/bin",
+}
+
+// libraryPathEnv returns the environment variable that makes
+// a bundled binary find the libraries in the given directory
+func libraryPathEnv(dir string) string {
+	return fmt.Sprintf("DYLD_LIBRARY_PATH=%s", dir)
+}
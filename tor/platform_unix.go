@@ -0,0 +1,22 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package tor
+
+import "fmt"
+
+const (
+	torExecutable = "tor"
+
+	// usesTorsocks is true when the services are run through torsocks
+	usesTorsocks = true
+)
+
+// torSystemDirs are where Tor is looked for when it's not in the PATH
+var torSystemDirs = []string{}
+
+// libraryPathEnv returns the environment variable that makes
+// a bundled binary find the libraries in the given directory
+func libraryPathEnv(dir string) string {
+	return fmt.Sprintf("LD_LIBRARY_PATH=%s", dir)
+}
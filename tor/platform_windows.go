@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package tor
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	torExecutable = "tor.exe"
+
+	// usesTorsocks is false since torsocks doesn't work on Windows. The
+	// services are configured to use the SOCKS proxy of Tor instead
+	usesTorsocks = false
+)
+
+// torSystemDirs are where Tor is looked for when it's not in the PATH
+var torSystemDirs = []string{}
+
+// libraryPathEnv returns the environment variable that makes
+// a bundled binary find the libraries in the given directory
+func libraryPathEnv(dir string) string {
+	return fmt.Sprintf("PATH=%s%c%s", dir, os.PathListSeparator, os.Getenv("PATH"))
+}
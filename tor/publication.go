@@ -0,0 +1,198 @@
+package tor
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
+)
+
+// An onion service is not reachable as soon as it's created: Tor has
+// to establish its introduction points and then upload the descriptor
+// to the directories responsible for it. Instead of waiting for a
+// fixed time, we follow the HS_DESC and CIRC events of the control
+// port and consider the service published once enough directories
+// have received the descriptor.
+
+const (
+	publicationTimeout = 3 * time.Minute
+
+	// publishedDescriptorsQuorum is the amount of directories that must
+	// have the descriptor before we stop waiting for the other uploads.
+	// Clients ask a few of the responsible directories, so a single
+	// upload is only accepted when no other upload is pending
+	publishedDescriptorsQuorum = 4
+)
+
+var (
+	// ErrOnionPublicationTimeout is an error to be trown when the
+	// descriptor of the onion service wasn't uploaded in time
+	ErrOnionPublicationTimeout = failure.New("tor.publication-timeout", failure.CategoryHosting, "the onion service wasn't published in time", "the Tor network might be slow, try to host the meeting again")
+
+	// ErrOnionPublicationUnknown is an error to be trown when
+	// the publication of the onion service can't be followed
+	ErrOnionPublicationUnknown = failure.New("tor.publication-unknown", failure.CategoryHosting, "the publication of the onion service can't be followed", "")
+)
+
+type onionPublication struct {
+	sync.Mutex
+	address       string
+	introCircuits map[string]bool
+	pending       int
+	uploaded      int
+	failed        int
+	done          bool
+	err           error
+	callbacks     []func(error)
+	stream        EventStream
+}
+
+func newOnionPublication(serviceID string) *onionPublication {
+	return &onionPublication{
+		address:       strings.TrimSuffix(serviceID, ".onion"),
+		introCircuits: make(map[string]bool),
+	}
+}
+
+// follow reads the events of the given stream until the onion service is
+// published, the timeout expires or the stream finishes. The stream is
+// closed afterwards, since it's only used by this publication
+func (p *onionPublication) follow(stream EventStream, timeout time.Duration) {
+	p.Lock()
+	p.stream = stream
+	p.Unlock()
+
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-stream.Events():
+			if !ok {
+				p.finish(ErrOnionPublicationUnknown)
+				return
+			}
+
+			if p.process(e) {
+				p.finish(nil)
+				return
+			}
+		case <-timer.C:
+			p.finish(ErrOnionPublicationTimeout)
+			return
+		}
+	}
+}
+
+// process updates the state of the publication with the given event,
+// returning true when the onion service can be considered published
+func (p *onionPublication) process(event string) bool {
+	fields := strings.Fields(event)
+	if len(fields) < 3 {
+		return false
+	}
+
+	switch fields[0] {
+	case "HS_DESC":
+		// HS_DESC Action HSAddress AuthType HsDir ...
+		if fields[2] != p.address {
+			return false
+		}
+
+		switch fields[1] {
+		case "UPLOAD":
+			p.pending++
+		case "UPLOADED":
+			p.uploaded++
+			p.uploadFinished()
+		case "FAILED":
+			p.failed++
+			p.uploadFinished()
+		}
+	case "CIRC":
+		// CIRC CircuitID CircStatus [Path] [KEYWORD=value ...]
+		if fields[2] == "BUILT" &&
+			eventHasValue(fields, "HS_STATE", "HSSI_ESTABLISHED") &&
+			eventHasValue(fields, "REND_QUERY", p.address) {
+			p.introCircuits[fields[1]] = true
+		}
+	}
+
+	return p.uploaded >= publishedDescriptorsQuorum ||
+		(p.uploaded > 0 && p.pending == 0)
+}
+
+func (p *onionPublication) uploadFinished() {
+	if p.pending > 0 {
+		p.pending--
+	}
+}
+
+func eventHasValue(fields []string, key, value string) bool {
+	for _, f := range fields {
+		if f == key+"="+value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *onionPublication) finish(err error) {
+	p.Lock()
+	if p.done {
+		p.Unlock()
+		return
+	}
+	p.done = true
+	p.err = err
+	callbacks := p.callbacks
+	p.callbacks = nil
+	p.Unlock()
+
+	log.WithFields(log.Fields{
+		"context":      "publication",
+		"introPoints":  len(p.introCircuits),
+		"descriptors":  p.uploaded,
+		"failedUpload": p.failed,
+	}).Debugf("Finished the publication of the onion service: %v", err)
+
+	for _, f := range callbacks {
+		f(err)
+	}
+}
+
+// whenPublished calls f once the onion service is published, or
+// with the error that made us stop waiting for it
+func (p *onionPublication) whenPublished(f func(error)) {
+	p.Lock()
+	if !p.done {
+		p.callbacks = append(p.callbacks, f)
+		p.Unlock()
+		return
+	}
+	err := p.err
+	p.Unlock()
+
+	f(err)
+}
+
+// cancel stops following the publication without
+// calling anything, because the service is gone
+func (p *onionPublication) cancel() {
+	p.Lock()
+	p.done = true
+	p.callbacks = nil
+	stream := p.stream
+	p.Unlock()
+
+	if stream != nil {
+		_ = stream.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package tor
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type WahayTorPublicationSuite struct{}
+
+var _ = Suite(&WahayTorPublicationSuite{})
+
+const testOnionAddress = "qvdjpoqcg572ibylv673qr76iwashlazh6spm47ly37w65iwwmkbmtid"
+
+type fakeEventStream struct {
+	events chan string
+	closed bool
+}
+
+func (s *fakeEventStream) Events() <-chan string {
+	return s.events
+}
+
+func (s *fakeEventStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_isPublishedWhenAllTheUploadsHaveFinished(c *C) {
+	p := newOnionPublication(testOnionAddress + ".onion")
+
+	c.Assert(p.process("HS_DESC UPLOAD "+testOnionAddress+" UNKNOWN $AAAA"), Equals, false)
+	c.Assert(p.process("HS_DESC UPLOAD "+testOnionAddress+" UNKNOWN $BBBB"), Equals, false)
+	c.Assert(p.process("HS_DESC UPLOADED "+testOnionAddress+" UNKNOWN $AAAA"), Equals, false)
+	c.Assert(p.process("HS_DESC FAILED "+testOnionAddress+" UNKNOWN $BBBB REASON=UPLOAD_REJECTED"), Equals, true)
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_isPublishedWhenEnoughDirectoriesHaveTheDescriptor(c *C) {
+	p := newOnionPublication(testOnionAddress)
+
+	for i := 0; i < 8; i++ {
+		c.Assert(p.process("HS_DESC UPLOAD "+testOnionAddress+" UNKNOWN $AAAA"), Equals, false)
+	}
+
+	for i := 0; i < publishedDescriptorsQuorum-1; i++ {
+		c.Assert(p.process("HS_DESC UPLOADED "+testOnionAddress+" UNKNOWN $AAAA"), Equals, false)
+	}
+
+	c.Assert(p.process("HS_DESC UPLOADED "+testOnionAddress+" UNKNOWN $AAAA"), Equals, true)
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_ignoresTheEventsOfOtherServices(c *C) {
+	p := newOnionPublication(testOnionAddress)
+
+	c.Assert(p.process("HS_DESC UPLOAD otheraddress UNKNOWN $AAAA"), Equals, false)
+	c.Assert(p.process("HS_DESC UPLOADED otheraddress UNKNOWN $AAAA"), Equals, false)
+	c.Assert(p.uploaded, Equals, 0)
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_countsTheIntroductionPoints(c *C) {
+	p := newOnionPublication(testOnionAddress)
+
+	p.process("CIRC 12 BUILT $AAAA~a,$BBBB~b PURPOSE=HS_SERVICE_INTRO HS_STATE=HSSI_ESTABLISHED REND_QUERY=" + testOnionAddress)
+	p.process("CIRC 12 BUILT $AAAA~a,$BBBB~b PURPOSE=HS_SERVICE_INTRO HS_STATE=HSSI_ESTABLISHED REND_QUERY=" + testOnionAddress)
+	p.process("CIRC 13 EXTENDED $AAAA~a PURPOSE=HS_SERVICE_INTRO HS_STATE=HSSI_CONNECTING REND_QUERY=" + testOnionAddress)
+
+	c.Assert(len(p.introCircuits), Equals, 1)
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_follow_callsTheWaitingFunctionsWhenPublished(c *C) {
+	p := newOnionPublication(testOnionAddress)
+	stream := &fakeEventStream{events: make(chan string, 2)}
+
+	result := make(chan error, 1)
+	p.whenPublished(func(err error) {
+		result <- err
+	})
+
+	stream.events <- "HS_DESC UPLOAD " + testOnionAddress + " UNKNOWN $AAAA"
+	stream.events <- "HS_DESC UPLOADED " + testOnionAddress + " UNKNOWN $AAAA"
+
+	p.follow(stream, time.Minute)
+
+	c.Assert(<-result, IsNil)
+	c.Assert(stream.closed, Equals, true)
+
+	p.whenPublished(func(err error) {
+		c.Assert(err, IsNil)
+	})
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_follow_failsAfterTheTimeout(c *C) {
+	p := newOnionPublication(testOnionAddress)
+	stream := &fakeEventStream{events: make(chan string)}
+
+	var result error
+	p.whenPublished(func(err error) {
+		result = err
+	})
+
+	p.follow(stream, time.Millisecond)
+
+	c.Assert(result, Equals, ErrOnionPublicationTimeout)
+}
+
+func (s *WahayTorPublicationSuite) Test_onionPublication_cancel_doesntCallTheWaitingFunctions(c *C) {
+	p := newOnionPublication(testOnionAddress)
+
+	called := false
+	p.whenPublished(func(error) {
+		called = true
+	})
+
+	p.cancel()
+	p.finish(nil)
+
+	c.Assert(called, Equals, false)
+}
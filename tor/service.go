@@ -1,10 +1,29 @@
 package tor
 
+import (
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// maxServiceOutput is how much of the last error output of a command
+// is kept, so it can be added to the diagnostics when the command fails
+const maxServiceOutput = 16 * 1024
+
 // Service is a representation of a service running through Tor
 type Service interface {
 	Close()
 	IsClosed() bool
 	OnClose(func())
+
+	// Err returns the error the command finished with when it stopped
+	// by itself. It's nil while it runs, when it exits normally
+	// and when it's stopped with Close
+	Err() error
+
+	// Output returns the last part of what the command has
+	// written to its error output
+	Output() string
 }
 
 type service struct {
@@ -15,11 +34,20 @@ type service struct {
 	finished          bool
 	finishedWithError error
 	finishChannel     chan bool
+	closing           bool
+	output            *outputTail
 }
 
 // NewService creates a new Tor command service
 func (i *instance) NewService(cmd string, args []string, modifier ModifyCommand) (Service, error) {
-	rc, err := i.exec(cmd, args, modifier)
+	output := &outputTail{max: maxServiceOutput}
+
+	rc, err := i.exec(cmd, args, func(c *exec.Cmd) {
+		if modifier != nil {
+			modifier(c)
+		}
+		c.Stderr = alsoTo(c.Stderr, output)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +58,7 @@ func (i *instance) NewService(cmd string, args []string, modifier ModifyCommand)
 		finished:          false,
 		finishedWithError: nil,
 		finishChannel:     make(chan bool),
+		output:            output,
 	}
 
 	s.listenToFinish()
@@ -42,9 +71,21 @@ func (s *service) IsClosed() bool {
 }
 
 func (s *service) Close() {
+	s.closing = true
 	s.rc.CancelFunc()
 }
 
+func (s *service) Err() error {
+	if !s.finished || s.closing {
+		return nil
+	}
+	return s.finishedWithError
+}
+
+func (s *service) Output() string {
+	return s.output.String()
+}
+
 func (s *service) OnClose(f func()) {
 	s.onCloseFunctions = append(s.onCloseFunctions, f)
 }
@@ -54,8 +95,8 @@ func (s *service) listenToFinish() {
 
 	go func() {
 		e := execf.WaitCommand(s.rc.Cmd)
-		s.finished = true
 		s.finishedWithError = e
+		s.finished = true
 		s.finishChannel <- true
 	}()
 }
@@ -73,3 +114,37 @@ func (s *service) closeWhenFinish() {
 		}
 	}()
 }
+
+// outputTail keeps the last bytes written to it
+type outputTail struct {
+	sync.Mutex
+	max  int
+	data []byte
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.data = append(t.data, p...)
+	if len(t.data) > t.max {
+		t.data = append([]byte(nil), t.data[len(t.data)-t.max:]...)
+	}
+
+	return len(p), nil
+}
+
+func (t *outputTail) String() string {
+	t.Lock()
+	defer t.Unlock()
+
+	return string(t.data)
+}
+
+// alsoTo returns a writer that writes to w too, when there is already one
+func alsoTo(current io.Writer, w io.Writer) io.Writer {
+	if current == nil {
+		return w
+	}
+	return io.MultiWriter(current, w)
+}
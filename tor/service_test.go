@@ -0,0 +1,31 @@
+package tor
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type TorServiceSuite struct{}
+
+var _ = Suite(&TorServiceSuite{})
+
+func (s *TorServiceSuite) Test_outputTail_keepsOnlyTheLastBytes(c *C) {
+	t := &outputTail{max: 10}
+
+	n, err := t.Write([]byte("first line\n"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 11)
+
+	_, _ = t.Write([]byte("crash!\n"))
+
+	c.Assert(t.String(), Equals, "ne\ncrash!\n")
+}
+
+func (s *TorServiceSuite) Test_outputTail_keepsEverythingBelowTheLimit(c *C) {
+	t := &outputTail{max: maxServiceOutput}
+
+	_, _ = t.Write([]byte(strings.Repeat("a", 100)))
+
+	c.Assert(t.String(), HasLen, 100)
+}
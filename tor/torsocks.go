@@ -1,18 +1,21 @@
 package tor
 
 import (
-	"errors"
-
 	log "github.com/sirupsen/logrus"
+
+	"github.com/digitalautonomy/wahay/failure"
 )
 
 var (
 	// ErrTorsocksNotInstalled is an error to be trown where
 	// torsocks is not installed in the system
-	ErrTorsocksNotInstalled = errors.New("torsocks not available")
+	ErrTorsocksNotInstalled = failure.New("tor.torsocks-not-installed", failure.CategoryTor, "torsocks not available", "install torsocks in the system")
 )
 
 func findTorsocksBinary() (fatalErr error) {
+	if !usesTorsocks {
+		return nil
+	}
 	return findTorsocksInSystem()
 }
 
@@ -0,0 +1,119 @@
+package tor
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The traffic of a meeting is measured with the STREAM and STREAM_BW
+// events of the control port. The first ones tell which SOCKS
+// connections go to the onion service of the meeting, and the second
+// ones how many bytes every connection has carried since the last event
+
+// TrafficStats is how much has gone through Tor to an onion service
+type TrafficStats struct {
+	// Sent is what the applications have sent through the SOCKS
+	// connections, and Received what they have received
+	Sent     int64
+	Received int64
+}
+
+// TrafficMeter follows the traffic to an onion service until it's closed
+type TrafficMeter interface {
+	Stats() TrafficStats
+	Close()
+}
+
+type trafficMeter struct {
+	sync.Mutex
+	address string
+	streams map[string]bool
+	stats   TrafficStats
+	stream  EventStream
+}
+
+// MeasureTraffic starts counting what goes through our Tor to the
+// given onion service, from the connections made after this call
+func (i *instance) MeasureTraffic(serviceID string) (TrafficMeter, error) {
+	events, err := i.GetController().NewEventStream("STREAM", "STREAM_BW")
+	if err != nil {
+		return nil, err
+	}
+
+	m := newTrafficMeter(serviceID)
+	m.stream = events
+	go m.follow(events)
+
+	return m, nil
+}
+
+func newTrafficMeter(serviceID string) *trafficMeter {
+	return &trafficMeter{
+		address: strings.TrimSuffix(strings.ToLower(serviceID), ".onion"),
+		streams: make(map[string]bool),
+	}
+}
+
+func (m *trafficMeter) follow(stream EventStream) {
+	for e := range stream.Events() {
+		m.process(e)
+	}
+}
+
+func (m *trafficMeter) process(event string) {
+	fields := strings.Fields(event)
+	if len(fields) < 4 {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	switch fields[0] {
+	case "STREAM":
+		// STREAM StreamID StreamStatus CircuitID Target [KEYWORD=value ...]
+		switch fields[2] {
+		case "CLOSED", "FAILED":
+			delete(m.streams, fields[1])
+		default:
+			if len(fields) > 4 && m.isOurTarget(fields[4]) {
+				m.streams[fields[1]] = true
+			}
+		}
+	case "STREAM_BW":
+		// STREAM_BW StreamID BytesWritten BytesRead [Time]. The bytes are
+		// written to the application and read from it
+		if !m.streams[fields[1]] {
+			return
+		}
+		written, err1 := strconv.ParseInt(fields[2], 10, 64)
+		read, err2 := strconv.ParseInt(fields[3], 10, 64)
+		if err1 == nil && err2 == nil {
+			m.stats.Received += written
+			m.stats.Sent += read
+		}
+	}
+}
+
+func (m *trafficMeter) isOurTarget(target string) bool {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".onion") == m.address
+}
+
+func (m *trafficMeter) Stats() TrafficStats {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.stats
+}
+
+func (m *trafficMeter) Close() {
+	if m.stream != nil {
+		_ = m.stream.Close()
+	}
+}
@@ -0,0 +1,32 @@
+package tor
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TorTrafficSuite struct{}
+
+var _ = Suite(&TorTrafficSuite{})
+
+func (s *TorTrafficSuite) Test_trafficMeter_countsTheStreamsToTheOnionService(c *C) {
+	m := newTrafficMeter(testOnionAddress + ".onion")
+
+	m.process("STREAM 10 NEW 0 " + testOnionAddress + ".onion:64738 SOURCE_ADDR=127.0.0.1:40000 PURPOSE=USER")
+	m.process("STREAM 11 NEW 0 example.com:443 SOURCE_ADDR=127.0.0.1:40001 PURPOSE=USER")
+	m.process("STREAM_BW 10 300 200 2020-01-01T00:00:00.000000")
+	m.process("STREAM_BW 11 5000 5000 2020-01-01T00:00:00.000000")
+	m.process("STREAM_BW 10 100 50 2020-01-01T00:00:01.000000")
+
+	c.Assert(m.Stats(), DeepEquals, TrafficStats{Sent: 250, Received: 400})
+}
+
+func (s *TorTrafficSuite) Test_trafficMeter_stopsCountingClosedStreams(c *C) {
+	m := newTrafficMeter(testOnionAddress)
+
+	m.process("STREAM 10 SUCCEEDED 3 " + testOnionAddress + ".onion:64738")
+	m.process("STREAM_BW 10 10 10")
+	m.process("STREAM 10 CLOSED 3 " + testOnionAddress + ".onion:64738 REASON=DONE")
+	m.process("STREAM_BW 10 10 10")
+
+	c.Assert(m.Stats(), DeepEquals, TrafficStats{Sent: 10, Received: 10})
+}